@@ -0,0 +1,67 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"school-management-system/internal/backup"
+	"school-management-system/internal/config"
+	"school-management-system/internal/models"
+	"school-management-system/pkg/database"
+)
+
+// Restores a backup file into an empty database configured by the usual
+// DB_* environment variables. The schema is migrated first; the restore
+// refuses to run if any table already has rows, and is rolled back unless
+// every table's row count matches the backup.
+//
+// Usage: DB_PATH=restored.db go run ./cmd/restore -file backups/backup-....db.gz -checksum <sha256>
+func main() {
+	file := flag.String("file", "", "backup file to restore")
+	checksum := flag.String("checksum", "", "expected SHA-256 of the backup file, as shown by GET /api/admin/backups/:id")
+	verifyOnly := flag.Bool("verify", false, "only verify the backup file, do not restore it")
+	flag.Parse()
+
+	if *file == "" {
+		log.Fatal("A backup file is required: pass -file")
+	}
+
+	if *verifyOnly {
+		manifest, err := backup.Verify(*file, *checksum)
+		if err != nil {
+			log.Fatal("Backup verification failed: ", err)
+		}
+		printManifest("Verified", manifest)
+		return
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		log.Fatal("Failed to load config:", err)
+	}
+
+	db, err := database.ConnectDB(cfg)
+	if err != nil {
+		log.Fatal("Failed to connect DB:", err)
+	}
+	defer database.CloseDB()
+
+	if err := db.AutoMigrate(models.All()...); err != nil {
+		log.Fatal("Failed to migrate database:", err)
+	}
+
+	manifest, err := backup.Restore(*file, *checksum, db)
+	if err != nil {
+		log.Fatal("Restore failed: ", err)
+	}
+	printManifest("Restored", manifest)
+}
+
+func printManifest(verb string, manifest *backup.Manifest) {
+	var rows int64
+	for _, t := range manifest.Tables {
+		fmt.Printf("  %-28s %d\n", t.Name, t.Rows)
+		rows += t.Rows
+	}
+	fmt.Printf("%s %d rows in %d tables (%s backup)\n", verb, rows, len(manifest.Tables), manifest.Format)
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"school-management-system/internal/config"
+	"school-management-system/internal/models"
+	"school-management-system/internal/service"
+	"time"
+)
+
+// registerJobs defines the recurring background jobs
+func registerJobs(
+	scheduler service.JobSchedulerService,
+	cfg *config.Config,
+	paymentService service.PaymentService,
+	assignmentService service.AssignmentService,
+	announcementService service.AnnouncementService,
+	attendanceAutomationService *service.AttendanceAutomationService,
+	backupService service.BackupService,
+	auditLogService service.AuditLogService,
+	emailOutbox service.EmailOutboxService,
+	realtimeService service.RealtimeService,
+	messageService service.MessageService,
+	enrollmentService service.EnrollmentService,
+) error {
+	jobs := []service.JobDefinition{
+		{
+			Name:        "payments.mark_overdue",
+			Description: "Mark pending payments past their due date as overdue",
+			Schedule:    "5 * * * *",
+			Run: func(ctx context.Context) (string, error) {
+				n, err := paymentService.MarkOverdue(time.Now())
+				return fmt.Sprintf("%d payments marked overdue", n), err
+			},
+		},
+		{
+			Name:        "payments.send_reminders",
+			Description: "Remind students of payments due within three days, and weekly of overdue payments",
+			Schedule:    "0 8 * * *",
+			Run: func(ctx context.Context) (string, error) {
+				n, err := paymentService.SendReminders(time.Now())
+				return fmt.Sprintf("%d reminders sent", n), err
+			},
+		},
+		{
+			Name:        "assignments.due_reminders",
+			Description: "Remind students of assignments due within a day that they have not submitted",
+			Schedule:    "0 * * * *",
+			Run: func(ctx context.Context) (string, error) {
+				n, err := assignmentService.SendDueReminders(time.Now())
+				return fmt.Sprintf("%d students reminded", n), err
+			},
+		},
+		{
+			Name:        "attendance.low_alerts",
+			Description: fmt.Sprintf("Alert students and guardians when attendance in a course is below %.0f%%", cfg.AttendanceAlertThreshold),
+			Schedule:    "0 7 * * mon",
+			Run: func(ctx context.Context) (string, error) {
+				n, err := attendanceAutomationService.SendLowAttendanceAlerts(cfg.AttendanceAlertThreshold)
+				return fmt.Sprintf("%d alerts sent", n), err
+			},
+		},
+		{
+			Name:        "announcements.expire",
+			Description: "Deactivate announcements past their expiry time",
+			Schedule:    "*/15 * * * *",
+			Run: func(ctx context.Context) (string, error) {
+				n, err := announcementService.ExpireDue(time.Now())
+				return fmt.Sprintf("%d announcements expired", n), err
+			},
+		},
+		{
+			Name:        "announcements.publish",
+			Description: "Publish scheduled announcements whose time has come",
+			Schedule:    "* * * * *",
+			Run: func(ctx context.Context) (string, error) {
+				n, err := announcementService.PublishDue(time.Now())
+				return fmt.Sprintf("%d announcements published", n), err
+			},
+		},
+		{
+			Name:        "announcements.sync_audiences",
+			Description: "Deliver live announcements to users who have since joined their audience",
+			Schedule:    "*/15 * * * *",
+			Run: func(ctx context.Context) (string, error) {
+				n, err := announcementService.SyncAudiences(time.Now())
+				return fmt.Sprintf("%d recipients added", n), err
+			},
+		},
+		{
+			Name:        "enrollments.promote_waitlists",
+			Description: "Give free seats to waitlisted students in the order they joined",
+			Schedule:    "*/15 * * * *",
+			Run: func(ctx context.Context) (string, error) {
+				n, err := enrollmentService.PromoteWaitlists(ctx)
+				return fmt.Sprintf("%d students promoted", n), err
+			},
+		},
+		{
+			Name:        "enrollments.activate",
+			Description: "Start approved enrollments whose term has begun",
+			Schedule:    "10 * * * *",
+			Run: func(ctx context.Context) (string, error) {
+				n, err := enrollmentService.ActivateStarted(ctx, time.Now())
+				return fmt.Sprintf("%d enrollments started", n), err
+			},
+		},
+		{
+			Name:        "backups.create",
+			Description: "Write a database backup and apply backup retention",
+			Schedule:    cfg.BackupSchedule,
+			Run: func(ctx context.Context) (string, error) {
+				backup, err := backupService.Run(nil, models.BackupTriggerScheduled, "Scheduled backup")
+				if err != nil {
+					return "", err
+				}
+				return fmt.Sprintf("backup %d written, %d bytes", backup.ID, backup.Size), nil
+			},
+		},
+		{
+			Name:        "audit_logs.purge",
+			Description: fmt.Sprintf("Delete audit log entries older than %d days", cfg.AuditRetentionDays),
+			Schedule:    "30 3 * * *",
+			Run: func(ctx context.Context) (string, error) {
+				if cfg.AuditRetentionDays <= 0 {
+					return "retention disabled", nil
+				}
+				return "", auditLogService.CleanupOldLogs(cfg.AuditRetentionDays)
+			},
+		},
+		{
+			Name:        "job_runs.purge",
+			Description: fmt.Sprintf("Delete job run history older than %d days", cfg.JobRunRetentionDays),
+			Schedule:    "45 3 * * *",
+			Run: func(ctx context.Context) (string, error) {
+				n, err := scheduler.PurgeRuns(cfg.JobRunRetentionDays)
+				return fmt.Sprintf("%d runs deleted", n), err
+			},
+		},
+		{
+			Name:        "emails.purge",
+			Description: fmt.Sprintf("Delete delivered emails older than %d days", cfg.EmailRetentionDays),
+			Schedule:    "50 3 * * *",
+			Run: func(ctx context.Context) (string, error) {
+				n, err := emailOutbox.PurgeSent(cfg.EmailRetentionDays)
+				return fmt.Sprintf("%d emails deleted", n), err
+			},
+		},
+		{
+			Name:        "realtime_events.purge",
+			Description: fmt.Sprintf("Delete pushed events older than %d hours", cfg.RealtimeRetentionHours),
+			Schedule:    "20 * * * *",
+			Run: func(ctx context.Context) (string, error) {
+				n, err := realtimeService.Purge(time.Duration(cfg.RealtimeRetentionHours) * time.Hour)
+				return fmt.Sprintf("%d events deleted", n), err
+			},
+		},
+		{
+			Name:        "messages.sync_course_channels",
+			Description: "Bring course channel members in line with teachers and active enrollments",
+			Schedule:    "*/15 * * * *",
+			Run: func(ctx context.Context) (string, error) {
+				return "course channels synced", messageService.SyncCourseChannels()
+			},
+		},
+		{
+			Name:        "messages.purge_attachments",
+			Description: "Delete message attachments uploaded more than a day ago but never sent",
+			Schedule:    "40 3 * * *",
+			Run: func(ctx context.Context) (string, error) {
+				n, err := messageService.PurgeUnclaimedAttachments(24 * time.Hour)
+				return fmt.Sprintf("%d attachments deleted", n), err
+			},
+		},
+	}
+
+	for _, job := range jobs {
+		if err := scheduler.Register(job); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -6,14 +6,20 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"school-management-system/internal/audit"
+	"school-management-system/internal/authz"
 	"school-management-system/internal/config"
 	"school-management-system/internal/handlers"
+	"school-management-system/internal/mail"
 	"school-management-system/internal/middleware"
 	"school-management-system/internal/models"
+	"school-management-system/internal/realtime"
 	"school-management-system/internal/repository"
 	"school-management-system/internal/service"
+	"school-management-system/internal/sms"
 	"school-management-system/pkg/database"
 	"school-management-system/pkg/logger"
+	"school-management-system/pkg/utils"
 	"syscall"
 	"time"
 
@@ -48,34 +54,17 @@ func main() {
 	// Auto migrate models with timing
 	appLogger.Info("Running database migrations...")
 	migStart := time.Now()
-	err = db.AutoMigrate(
-		&models.User{},
-		&models.Student{},
-		&models.Teacher{},
-		&models.Course{},
-		&models.Enrollment{},
-		&models.Grade{},
-		&models.Attendance{},
-		&models.Assignment{},
-		&models.AssignmentSubmission{},
-		&models.SystemSetting{},
-		&models.AuditLog{},
-		&models.Notification{},
-		&models.Announcement{},
-		&models.Message{},
-		&models.Payment{},
-		&models.TimeTable{},
-		&models.GradeTranscript{},
-		&models.Backup{},
-		&models.ImportBatch{},
-		&models.AssignmentRubric{},
-		&models.RubricScore{},
-	)
+	err = db.AutoMigrate(models.All()...)
 	if err != nil {
 		appLogger.Fatal("Failed to migrate database:", err)
 	}
 	appLogger.Infof("Database migrations completed in %s", time.Since(migStart).String())
 
+	// Audit changes to grades, attendance, payments, enrollments and users
+	if err := audit.Register(db); err != nil {
+		appLogger.Fatal("Failed to set up the audit trail:", err)
+	}
+
 	// Create admin user if needed
 	adminStart := time.Now()
 	createAdminUser(db, cfg, appLogger)
@@ -94,7 +83,7 @@ func main() {
 
 	// New feature repositories
 	systemSettingRepo := repository.NewSystemSettingRepository()
-	// auditLogRepo := repository.NewAuditLogRepository() // Used internally by middleware
+	auditLogRepo := repository.NewAuditLogRepository()
 	notificationRepo := repository.NewNotificationRepository()
 	announcementRepo := repository.NewAnnouncementRepository()
 	messageRepo := repository.NewMessageRepository()
@@ -105,48 +94,131 @@ func main() {
 	importBatchRepo := repository.NewImportBatchRepository()
 	rubricRepo := repository.NewAssignmentRubricRepository()
 	rubricScoreRepo := repository.NewRubricScoreRepository()
+	academicTermRepo := repository.NewAcademicTermRepository()
+	courseSectionRepo := repository.NewCourseSectionRepository()
+	transferCreditRepo := repository.NewTransferCreditRepository()
+	gradingScaleRepo := repository.NewGradingScaleRepository()
+	gradeCategoryRepo := repository.NewGradeCategoryRepository()
+	guardianRepo := repository.NewGuardianRepository()
+	mfaRepo := repository.NewMFARepository()
+	loginThrottleRepo := repository.NewLoginThrottleRepository()
+	roomRepo := repository.NewRoomRepository()
+	teacherAvailabilityRepo := repository.NewTeacherAvailabilityRepository()
 
 	// Initialize services
-	authService := service.NewAuthService(userRepo, cfg.JWTSecret, cfg.JWTExpiry)
+	var mailTransport mail.Transport = &mail.MaildirTransport{Dir: cfg.MailDir}
+	if cfg.MailTransport == "smtp" {
+		mailTransport = &mail.SMTPTransport{Host: cfg.SMTPHost, Port: cfg.SMTPPort, Username: cfg.SMTPEmail, Password: cfg.SMTPPass}
+	} else {
+		appLogger.Infof("Writing outgoing email to maildir %s", cfg.MailDir)
+	}
+	emailOutbox := service.NewEmailOutboxService(repository.NewOutboxRepository(), service.OutboxOptions{
+		Transport:   mailTransport,
+		From:        cfg.SMTPEmail,
+		FromName:    cfg.SMTPName,
+		MaxAttempts: cfg.EmailMaxAttempts,
+	})
+	emailOutbox.Start()
+	emailTemplateService := service.NewEmailTemplateService(repository.NewEmailTemplateRepository())
+	if err := emailTemplateService.SeedDefaults(); err != nil {
+		appLogger.WithError(err).Warn("Failed to seed email templates, built-in content will be used")
+	}
+	emailService := service.NewEmailService(emailOutbox, emailTemplateService)
+	realtimeRepo := repository.NewRealtimeEventRepository()
+	var realtimeBroker realtime.Broker = realtime.NewMemoryBroker()
+	if cfg.RealtimeBroker == "database" {
+		realtimeBroker = service.NewDatabaseBroker(realtimeRepo, time.Second)
+	}
+	realtimeService := service.NewRealtimeService(realtimeRepo, service.RealtimeOptions{Broker: realtimeBroker})
+	realtimeService.Start()
+	var smsProvider sms.Provider = sms.LogProvider{}
+	if cfg.SMSProvider == "twilio" {
+		smsProvider = &sms.TwilioProvider{AccountSID: cfg.TwilioAccountSID, AuthToken: cfg.TwilioAuthToken, From: cfg.SMSFrom}
+	}
+	notifier := service.NewNotificationDispatcher(notificationRepo, repository.NewNotificationPreferenceRepository(),
+		repository.NewNotificationDeliveryRepository(), userRepo, guardianRepo, emailService, service.DispatcherOptions{
+			SMS:                  smsProvider,
+			AllowPrivateWebhooks: cfg.WebhookAllowPrivate,
+			Realtime:             realtimeService,
+		})
+	notifier.Start()
+	passwordPolicy, err := utils.NewPasswordPolicy(cfg.PasswordMinLength, cfg.PasswordHistorySize, cfg.PasswordBreachedList)
+	if err != nil {
+		appLogger.Warnf("Breached password list not loaded, continuing without it: %v", err)
+		passwordPolicy, _ = utils.NewPasswordPolicy(cfg.PasswordMinLength, cfg.PasswordHistorySize, "")
+	}
+	tokenRepo := repository.NewTokenRepository()
+	mfaService := service.NewMFAService(mfaRepo, systemSettingRepo, cfg.MFAIssuer)
+	lockoutPolicy := service.DefaultLockoutPolicy()
+	lockoutPolicy.MaxFailures = cfg.LockoutMaxFailures
+	lockoutPolicy.IPMaxFailures = cfg.LockoutIPMaxFailures
+	lockoutPolicy.LockDuration = time.Duration(cfg.LockoutDuration) * time.Minute
+	loginProtectionService := service.NewLoginProtectionService(loginThrottleRepo, auditLogRepo,
+		notificationRepo, userRepo, emailService, lockoutPolicy)
+	authService := service.NewAuthService(userRepo, tokenRepo, emailService, mfaService, loginProtectionService, passwordPolicy, cfg.JWTSecret,
+		time.Duration(cfg.JWTAccessExpiry)*time.Minute, time.Duration(cfg.JWTRefreshExpiry)*time.Hour,
+		service.AccountOptions{
+			BaseURL:                  cfg.AppBaseURL,
+			ResetTokenExpiry:         time.Duration(cfg.PasswordResetExpiry) * time.Minute,
+			VerificationTokenExpiry:  48 * time.Hour,
+			RequireEmailVerification: cfg.RequireEmailVerification,
+		})
 	userService := service.NewUserService(userRepo)
 	courseService := service.NewCourseService(courseRepo)
 	studentService := service.NewStudentService(studentRepo)
-	enrollmentService := service.NewEnrollmentService(enrollmentRepo)
-	gradeService := service.NewGradeService(gradeRepo)
-	attendanceService := service.NewAttendanceService(attendanceRepo)
+	attendanceService := service.NewAttendanceService(attendanceRepo, notifier)
 	teacherService := service.NewTeacherService(teacherRepo)
-	assignmentService := service.NewAssignmentService(assignmentRepo)
-	assignmentSubmissionService := service.NewAssignmentSubmissionService(assignmentSubmissionRepo)
 
 	// New feature services
 	systemSettingService := service.NewSystemSettingService(systemSettingRepo)
-	// auditLogService := service.NewAuditLogService(auditLogRepo) // Used internally by middleware
-	notificationService := service.NewNotificationService(notificationRepo)
-	announcementService := service.NewAnnouncementService(announcementRepo)
-	messageService := service.NewMessageService(messageRepo)
-	paymentService := service.NewPaymentService(paymentRepo)
-	timetableService := service.NewTimeTableService(timetableRepo)
-	gradeTranscriptService := service.NewGradeTranscriptService(gradeTranscriptRepo)
-	backupService := service.NewBackupService(backupRepo)
-	importBatchService := service.NewImportBatchService(importBatchRepo)
-	emailHost := os.Getenv("SMTP_HOST")
-	emailPort := os.Getenv("SMTP_PORT")
-	emailAddr := os.Getenv("SMTP_EMAIL")
-	emailName := os.Getenv("SMTP_NAME")
-	emailPass := os.Getenv("SMTP_PASS")
-	emailService := service.NewEmailService(emailHost, emailPort, emailAddr, emailName, emailPass)
+	auditLogService := service.NewAuditLogService(auditLogRepo)
+	notificationService := service.NewNotificationService(notificationRepo, notifier, realtimeService)
+	announcementService := service.NewAnnouncementService(announcementRepo, userRepo, emailService, realtimeService)
+	messageService := service.NewMessageService(messageRepo, repository.NewConversationRepository(), userRepo, courseRepo, systemSettingRepo, realtimeService, service.MessagingOptions{
+		AttachmentDir:     cfg.AttachmentDir,
+		MaxAttachmentSize: cfg.AttachmentMaxSize,
+	})
+	paymentService := service.NewPaymentService(paymentRepo, notifier)
+	timetableService := service.NewTimeTableService(timetableRepo, courseSectionRepo, teacherAvailabilityRepo, teacherRepo)
+	gradingScaleService := service.NewGradingScaleService(gradingScaleRepo, courseRepo)
+	gradeTranscriptService := service.NewGradeTranscriptService(gradeTranscriptRepo, gradeRepo, academicTermRepo, transferCreditRepo, systemSettingRepo, gradingScaleService)
+	enrollmentService := service.NewEnrollmentService(enrollmentRepo, courseRepo, courseSectionRepo, academicTermRepo, repository.NewCourseRequisiteRepository(), timetableRepo, gradeRepo, transferCreditRepo, gradingScaleService, gradeTranscriptService, notifier)
+	registrationService := service.NewRegistrationService(repository.NewRegistrationRepository(), academicTermRepo, courseSectionRepo,
+		studentRepo, enrollmentRepo, systemSettingRepo, enrollmentService)
+	gradeService := service.NewGradeService(gradeRepo, courseSectionRepo, gradingScaleService, gradeTranscriptService, notifier)
+	gradebookService := service.NewGradebookService(gradeCategoryRepo, assignmentRepo, assignmentSubmissionRepo, enrollmentRepo, gradeRepo, courseRepo, gradingScaleService, gradeTranscriptService)
+	assignmentService := service.NewAssignmentService(assignmentRepo, gradebookService, notifier)
+	assignmentSubmissionService := service.NewAssignmentSubmissionService(assignmentSubmissionRepo, gradebookService)
+	backupService := service.NewBackupService(backupRepo, db, service.BackupOptions{
+		Dir:            cfg.BackupDir,
+		RetentionCount: cfg.BackupRetentionCount,
+		RetentionDays:  cfg.BackupRetentionDays,
+	})
+	importBatchService := service.NewImportBatchService(importBatchRepo, db, gradingScaleService, gradeTranscriptService, enrollmentService)
+	academicTermService := service.NewAcademicTermService(academicTermRepo, gradeTranscriptService)
+	courseSectionService := service.NewCourseSectionService(courseSectionRepo, courseRepo, academicTermRepo)
+	roomService := service.NewRoomService(roomRepo, repository.NewResourceRepository(), repository.NewBookingRepository(), timetableRepo,
+		academicTermRepo, enrollmentRepo)
+	timetableGeneratorService := service.NewTimetableGeneratorService(repository.NewTimetableDraftRepository(), timetableRepo, academicTermRepo,
+		courseSectionRepo, enrollmentRepo, roomRepo, teacherAvailabilityRepo)
 	searchService := service.NewSearchService(announcementRepo, paymentRepo, studentRepo)
-	exportService := service.NewExportService(db)
-	attendanceAutomationService := service.NewAttendanceAutomationService(emailService)
-	gradeAutoCalculationService := service.NewGradeAutoCalculationService(gradeTranscriptService, emailService)
+	exportService := service.NewExportService(db, gradingScaleService)
+	guardianService := service.NewGuardianService(guardianRepo, userRepo, studentRepo)
+	parentPortalService := service.NewParentPortalService(gradeRepo, attendanceRepo, enrollmentRepo, assignmentRepo, assignmentSubmissionRepo, timetableRepo, paymentRepo, announcementRepo)
+	attendanceAutomationService := service.NewAttendanceAutomationService(emailService, guardianService)
+	gradeAutoCalculationService := service.NewGradeAutoCalculationService(gradeTranscriptService, gradingScaleService, notifier)
 
 	// New feature handlers
 	systemSettingHandler := handlers.NewSystemSettingHandler(systemSettingService)
 	notificationHandler := handlers.NewNotificationHandler(notificationService)
+	notificationPreferenceHandler := handlers.NewNotificationPreferenceHandler(notifier)
+	realtimeHandler := handlers.NewRealtimeHandler(realtimeService, time.Duration(cfg.RealtimeHeartbeat)*time.Second)
 	messageHandler := handlers.NewMessageHandler(messageService)
 	announcementHandler := handlers.NewAnnouncementHandler(announcementService)
 	paymentHandler := handlers.NewPaymentHandler(paymentService)
 	timetableHandler := handlers.NewTimeTableHandler(timetableService)
+	timetableGeneratorHandler := handlers.NewTimetableGeneratorHandler(timetableGeneratorService)
+	roomHandler := handlers.NewRoomHandler(roomService)
 	gradeTranscriptHandler := handlers.NewGradeTranscriptHandler(gradeTranscriptService)
 	backupHandler := handlers.NewBackupHandler(backupService)
 	importBatchHandler := handlers.NewImportBatchHandler(importBatchService)
@@ -155,19 +227,53 @@ func main() {
 	attendanceAutomationHandler := handlers.NewAttendanceAutomationHandler(attendanceAutomationService)
 	gradeAutoCalcHandler := handlers.NewGradeAutoCalcHandler(gradeAutoCalculationService)
 	rubricHandler := handlers.NewRubricHandler(rubricRepo, rubricScoreRepo)
+	academicTermHandler := handlers.NewAcademicTermHandler(academicTermService)
+	gradingScaleHandler := handlers.NewGradingScaleHandler(gradingScaleService)
+	gradebookHandler := handlers.NewGradebookHandler(gradebookService)
+	courseSectionHandler := handlers.NewCourseSectionHandler(courseSectionService)
+	guardianHandler := handlers.NewGuardianHandler(guardianService, parentPortalService)
+	auditLogHandler := handlers.NewAuditLogHandler(auditLogService)
 
 	// Initialize handlers
 	authHandler := handlers.NewAuthHandler(authService)
-	userHandler := handlers.NewUserHandler(userService)
+	mfaHandler := handlers.NewMFAHandler(mfaService, userService, authService)
+	lockoutHandler := handlers.NewLockoutHandler(loginProtectionService, userService)
+	userHandler := handlers.NewUserHandler(userService, authService)
 	courseHandler := handlers.NewCourseHandler(courseService)
 	studentHandler := handlers.NewStudentHandler(studentService)
 	enrollmentHandler := handlers.NewEnrollmentHandler(enrollmentService, studentService)
+	registrationHandler := handlers.NewRegistrationHandler(registrationService, studentService)
 	gradeHandler := handlers.NewGradeHandler(gradeService, studentService)
 	attendanceHandler := handlers.NewAttendanceHandler(attendanceService, studentService)
 	adminHandler := handlers.NewAdminHandler(userService, courseService, studentService)
 	teacherHandler := handlers.NewTeacherHandler(teacherService)
 	assignmentHandler := handlers.NewAssignmentHandler(assignmentService, assignmentSubmissionService, studentService)
 
+	// Background jobs
+	if err := backupService.MarkInterrupted(); err != nil {
+		appLogger.WithError(err).Warn("Failed to clean up interrupted backups")
+	}
+	if n, err := messageService.BackfillConversations(); err != nil {
+		appLogger.WithError(err).Warn("Failed to move messages into conversations")
+	} else if n > 0 {
+		appLogger.Infof("Moved %d messages into conversations", n)
+	}
+	if n, err := enrollmentService.MigrateLegacyStatuses(context.Background()); err != nil {
+		appLogger.WithError(err).Warn("Failed to update legacy enrollment statuses")
+	} else if n > 0 {
+		appLogger.Infof("Moved %d enrollments from legacy statuses", n)
+	}
+	jobScheduler := service.NewJobSchedulerService(repository.NewScheduledJobRepository(), service.SchedulerOptions{})
+	if err := registerJobs(jobScheduler, cfg, paymentService, assignmentService, announcementService, attendanceAutomationService, backupService, auditLogService, emailOutbox, realtimeService, messageService, enrollmentService); err != nil {
+		appLogger.Fatalf("Failed to register background jobs: %v", err)
+	}
+	if cfg.SchedulerEnabled {
+		jobScheduler.Start()
+	}
+	jobHandler := handlers.NewJobHandler(jobScheduler)
+	emailOutboxHandler := handlers.NewEmailOutboxHandler(emailOutbox)
+	emailTemplateHandler := handlers.NewEmailTemplateHandler(emailTemplateService)
+
 	// Setup router with comprehensive middleware
 	router := gin.New()
 
@@ -193,76 +299,121 @@ func main() {
 	// Public routes
 	public := router.Group("/api/auth")
 	public.Use(middleware.AuthRateLimit()) // Stricter rate limiting for auth
+	public.Use(audit.Middleware())
 	{
 		public.POST("/login", authHandler.Login)
 		public.POST("/register", authHandler.Register)
+		public.POST("/refresh", authHandler.Refresh)
+		public.POST("/forgot-password", authHandler.ForgotPassword)
+		public.POST("/reset-password", authHandler.ResetPassword)
+		public.GET("/verify-email", authHandler.VerifyEmail)
+		public.POST("/verify-email", authHandler.VerifyEmail)
+		public.POST("/mfa/enroll", authHandler.EnrollMFA)
+		public.POST("/mfa/verify", authHandler.VerifyMFA)
 	}
 
 	// Protected routes
 	api := router.Group("/api")
 	api.Use(middleware.AuthMiddleware(authService))
+	api.Use(audit.Middleware())
+	api.Use(authz.Middleware(authz.NewPolicy(authz.NewDBRelations(db))))
 	{
-		api.GET("/users", userHandler.GetAllUsers)
-		api.GET("/users/:id", userHandler.GetUser)
-		api.PUT("/users/:id", userHandler.UpdateUser)
-		api.PATCH("/users/:id/status", userHandler.UpdateUserStatus)
+		api.POST("/auth/logout", authHandler.Logout)
+		api.POST("/auth/change-password", authHandler.ChangePassword)
+		api.POST("/auth/resend-verification", authHandler.ResendVerification)
+		api.GET("/auth/mfa", mfaHandler.Status)
+		api.POST("/auth/mfa/setup", mfaHandler.Setup)
+		api.POST("/auth/mfa/enable", mfaHandler.Enable)
+		api.POST("/auth/mfa/disable", mfaHandler.Disable)
+		api.POST("/auth/mfa/recovery-codes", mfaHandler.RegenerateRecoveryCodes)
+
+		api.GET("/users", authz.Require(authz.ActionList, authz.All(authz.KindUser)), userHandler.GetAllUsers)
+		api.GET("/users/:id", authz.Require(authz.ActionRead, authz.Record(authz.KindUser, "id")), userHandler.GetUser)
+		api.PUT("/users/:id", authz.Require(authz.ActionUpdate, authz.Record(authz.KindUser, "id")), userHandler.UpdateUser)
+		api.PATCH("/users/:id/status", authz.Require(authz.ActionUpdate, authz.All(authz.KindUser)), userHandler.UpdateUserStatus)
 
 		api.GET("/profile", userHandler.GetProfile)
 		api.PUT("/profile", userHandler.UpdateProfile)
 
-		api.POST("/courses", courseHandler.CreateCourse)
+		api.POST("/courses", authz.Require(authz.ActionCreate, authz.All(authz.KindCourse)), courseHandler.CreateCourse)
 		api.GET("/courses", courseHandler.GetAllCourses)
 		api.GET("/courses/:id", courseHandler.GetCourse)
-		api.PUT("/courses/:id", courseHandler.UpdateCourse)
-		api.DELETE("/courses/:id", courseHandler.DeleteCourse)
+		api.PUT("/courses/:id", authz.Require(authz.ActionUpdate, authz.All(authz.KindCourse)), courseHandler.UpdateCourse)
+		api.DELETE("/courses/:id", authz.Require(authz.ActionDelete, authz.All(authz.KindCourse)), courseHandler.DeleteCourse)
 		api.GET("/courses/by-department", courseHandler.GetCoursesByDepartment)
-
-		api.POST("/students", studentHandler.CreateStudent)
-		api.GET("/students", studentHandler.GetAllStudents)
-		api.GET("/students/:id", studentHandler.GetStudent)
-		api.PUT("/students/:id", studentHandler.UpdateStudent)
-		api.DELETE("/students/:id", studentHandler.DeleteStudent)
-
-		api.POST("/enrollments", enrollmentHandler.EnrollStudent)
-		api.GET("/enrollments/:id", enrollmentHandler.GetEnrollment)
-		api.PUT("/enrollments/:id/status", enrollmentHandler.UpdateEnrollmentStatus)
-		api.DELETE("/enrollments/:id", enrollmentHandler.RemoveEnrollment)
-		api.GET("/enrollments/by-student/:studentId", enrollmentHandler.GetStudentEnrollments)
-		api.GET("/enrollments/by-course/:courseId", enrollmentHandler.GetCourseEnrollments)
+		api.GET("/courses/:id/requisites", enrollmentHandler.GetRequisites)
+		api.POST("/courses/:id/requisites", authz.Require(authz.ActionUpdate, authz.All(authz.KindCourse)), enrollmentHandler.AddRequisite)
+		api.DELETE("/courses/:id/requisites/:requisite_id", authz.Require(authz.ActionUpdate, authz.All(authz.KindCourse)), enrollmentHandler.RemoveRequisite)
+
+		// Academic terms and course sections
+		api.GET("/terms", academicTermHandler.GetAll)
+		api.GET("/terms/current", academicTermHandler.GetCurrent)
+		api.GET("/terms/:id", academicTermHandler.GetByID)
+		api.GET("/sections/:id", courseSectionHandler.GetByID)
+		api.GET("/sections/term/:term_id", courseSectionHandler.GetByTerm)
+		api.GET("/sections/course/:course_id", courseSectionHandler.GetByCourse)
+		api.GET("/sections/teacher/:teacher_id", courseSectionHandler.GetByTeacher)
+
+		// Grading scales
+		api.GET("/grading-scales", gradingScaleHandler.GetAll)
+		api.GET("/grading-scales/:id", gradingScaleHandler.GetByID)
+		api.GET("/grading-scales/course/:course_id", gradingScaleHandler.GetForCourse)
+
+		api.POST("/students", authz.Require(authz.ActionCreate, authz.All(authz.KindStudent)), studentHandler.CreateStudent)
+		api.GET("/students", authz.Require(authz.ActionList, authz.All(authz.KindStudent)), studentHandler.GetAllStudents)
+		api.GET("/students/:id", authz.Require(authz.ActionRead, authz.Student(authz.KindStudent, "id")), studentHandler.GetStudent)
+		api.PUT("/students/:id", authz.Require(authz.ActionUpdate, authz.Student(authz.KindStudent, "id")), studentHandler.UpdateStudent)
+		api.DELETE("/students/:id", authz.Require(authz.ActionDelete, authz.Student(authz.KindStudent, "id")), studentHandler.DeleteStudent)
+
+		api.POST("/enrollments", authz.Require(authz.ActionCreate, authz.All(authz.KindEnrollment)), enrollmentHandler.EnrollStudent)
+		api.GET("/enrollments/:id", authz.Require(authz.ActionRead, authz.Record(authz.KindEnrollment, "id")), enrollmentHandler.GetEnrollment)
+		api.PUT("/enrollments/:id/status", authz.Require(authz.ActionUpdate, authz.Record(authz.KindEnrollment, "id")), enrollmentHandler.UpdateEnrollmentStatus)
+		api.DELETE("/enrollments/:id", authz.Require(authz.ActionDelete, authz.Record(authz.KindEnrollment, "id")), enrollmentHandler.RemoveEnrollment)
+		api.GET("/enrollments/:id/history", authz.Require(authz.ActionRead, authz.Record(authz.KindEnrollment, "id")), enrollmentHandler.GetHistory)
+		api.GET("/enrollments/by-student/:studentId", authz.Require(authz.ActionRead, authz.Student(authz.KindEnrollment, "studentId")), enrollmentHandler.GetStudentEnrollments)
+		api.GET("/enrollments/by-course/:courseId", authz.Require(authz.ActionList, authz.Course(authz.KindEnrollment, "courseId")), enrollmentHandler.GetCourseEnrollments)
+		api.GET("/enrollments/by-section/:sectionId", authz.Require(authz.ActionList, authz.Section(authz.KindEnrollment, "sectionId")), enrollmentHandler.GetSectionEnrollments)
 
 		api.POST("/grades", gradeHandler.RecordGrade)
-		api.GET("/grades/:id", gradeHandler.GetGrade)
-		api.PUT("/grades/:id", gradeHandler.UpdateGrade)
-		api.DELETE("/grades/:id", gradeHandler.DeleteGrade)
-		api.GET("/grades/by-student/:studentId", gradeHandler.GetStudentGrades)
-		api.GET("/grades/by-course/:courseId", gradeHandler.GetCourseGrades)
-		api.GET("/grades/average/:studentId", gradeHandler.GetAverageGrade)
+		api.GET("/grades/:id", authz.Require(authz.ActionRead, authz.Record(authz.KindGrade, "id")), gradeHandler.GetGrade)
+		api.PUT("/grades/:id", authz.Require(authz.ActionUpdate, authz.Record(authz.KindGrade, "id")), gradeHandler.UpdateGrade)
+		api.DELETE("/grades/:id", authz.Require(authz.ActionDelete, authz.Record(authz.KindGrade, "id")), gradeHandler.DeleteGrade)
+		api.GET("/grades/by-student/:studentId", authz.Require(authz.ActionRead, authz.Student(authz.KindGrade, "studentId")), gradeHandler.GetStudentGrades)
+		api.GET("/grades/by-course/:courseId", authz.Require(authz.ActionList, authz.Course(authz.KindGrade, "courseId")), gradeHandler.GetCourseGrades)
+		api.GET("/grades/average/:studentId", authz.Require(authz.ActionRead, authz.Student(authz.KindGrade, "studentId")), gradeHandler.GetAverageGrade)
 
 		api.POST("/attendance", attendanceHandler.RecordAttendance)
-		api.GET("/attendance/:id", attendanceHandler.GetAttendance)
-		api.PUT("/attendance/:id", attendanceHandler.UpdateAttendance)
-		api.DELETE("/attendance/:id", attendanceHandler.DeleteAttendance)
-		api.GET("/attendance/by-student/:studentId", attendanceHandler.GetStudentAttendance)
-		api.GET("/attendance/by-course/:courseId", attendanceHandler.GetCourseAttendance)
-		api.GET("/attendance/student-course/:studentId/:courseId", attendanceHandler.GetStudentCourseAttendance)
-		api.GET("/attendance/stats/:studentId/:courseId", attendanceHandler.GetAttendanceStats)
+		api.GET("/attendance/:id", authz.Require(authz.ActionRead, authz.Record(authz.KindAttendance, "id")), attendanceHandler.GetAttendance)
+		api.PUT("/attendance/:id", authz.Require(authz.ActionUpdate, authz.Record(authz.KindAttendance, "id")), attendanceHandler.UpdateAttendance)
+		api.DELETE("/attendance/:id", authz.Require(authz.ActionDelete, authz.Record(authz.KindAttendance, "id")), attendanceHandler.DeleteAttendance)
+		api.GET("/attendance/by-student/:studentId", authz.Require(authz.ActionRead, authz.Student(authz.KindAttendance, "studentId")), attendanceHandler.GetStudentAttendance)
+		api.GET("/attendance/by-course/:courseId", authz.Require(authz.ActionList, authz.Course(authz.KindAttendance, "courseId")), attendanceHandler.GetCourseAttendance)
+		api.GET("/attendance/student-course/:studentId/:courseId", authz.Require(authz.ActionRead, authz.StudentInCourse(authz.KindAttendance, "studentId", "courseId")), attendanceHandler.GetStudentCourseAttendance)
+		api.GET("/attendance/stats/:studentId/:courseId", authz.Require(authz.ActionRead, authz.StudentInCourse(authz.KindAttendance, "studentId", "courseId")), attendanceHandler.GetAttendanceStats)
 
 		// Assignments
 		api.POST("/assignments", assignmentHandler.CreateAssignment)
-		api.GET("/assignments/:id", assignmentHandler.GetAssignment)
-		api.PUT("/assignments/:id", assignmentHandler.UpdateAssignment)
-		api.DELETE("/assignments/:id", assignmentHandler.DeleteAssignment)
+		api.GET("/assignments/:id", authz.Require(authz.ActionRead, authz.Record(authz.KindAssignment, "id")), assignmentHandler.GetAssignment)
+		api.PUT("/assignments/:id", authz.Require(authz.ActionUpdate, authz.Record(authz.KindAssignment, "id")), assignmentHandler.UpdateAssignment)
+		api.DELETE("/assignments/:id", authz.Require(authz.ActionDelete, authz.Record(authz.KindAssignment, "id")), assignmentHandler.DeleteAssignment)
 		api.GET("/assignments/course/:course_id", assignmentHandler.GetAssignmentsByCourse)
 
 		// Assignment submissions
 		api.POST("/assignments/submit", assignmentHandler.SubmitAssignment)
-		api.GET("/submissions/assignment/:assignment_id", assignmentHandler.GetSubmissionsByAssignment)
-		api.PUT("/submissions/:submission_id/grade", assignmentHandler.GradeSubmission)
+		api.GET("/submissions/assignment/:assignment_id", authz.Require(authz.ActionList, authz.RecordAs(authz.KindAssignment, "assignment_id", authz.KindSubmission)), assignmentHandler.GetSubmissionsByAssignment)
+		api.PUT("/submissions/:submission_id/grade", authz.Require(authz.ActionGrade, authz.Record(authz.KindSubmission, "submission_id")), assignmentHandler.GradeSubmission)
 
 		admin := api.Group("/admin")
 		admin.Use(middleware.RoleMiddleware(models.RoleAdmin))
 		{
 			admin.DELETE("/users/:id", userHandler.DeleteUser)
+			admin.DELETE("/users/:id/mfa", mfaHandler.Reset)
+			admin.POST("/users/:id/unlock", lockoutHandler.UnlockUser)
+			admin.GET("/lockouts", lockoutHandler.GetLocked)
+			admin.DELETE("/lockouts/ip/:ip", lockoutHandler.UnlockIP)
+			admin.GET("/audit-logs", auditLogHandler.List)
+			admin.GET("/audit-logs/:id", auditLogHandler.GetByID)
+			admin.GET("/audit-logs/entity/:entity/:id", auditLogHandler.GetEntityHistory)
 			admin.POST("/users", adminHandler.CreateUserAdmin)
 			admin.GET("/users", adminHandler.GetAllUsersAdmin)
 			admin.GET("/dashboard", adminHandler.GetDashboardStats)
@@ -271,6 +422,9 @@ func main() {
 			admin.GET("/enrollments", enrollmentHandler.GetAllEnrollments)
 			admin.POST("/enrollments/:id/approve", enrollmentHandler.ApproveEnrollment)
 			admin.POST("/enrollments/:id/reject", enrollmentHandler.RejectEnrollment)
+			admin.POST("/registration/override", registrationHandler.Override)
+			admin.PUT("/registration/priorities", registrationHandler.SetPriority)
+			admin.DELETE("/registration/priorities/:term_id/:student_id", registrationHandler.DeletePriority)
 
 			admin.POST("/teachers", teacherHandler.CreateTeacher)
 			admin.GET("/teachers", teacherHandler.GetAllTeachers)
@@ -279,24 +433,53 @@ func main() {
 			admin.DELETE("/teachers/:id", teacherHandler.DeleteTeacher)
 			admin.GET("/teachers/by-department", teacherHandler.GetTeachersByDepartment)
 			admin.GET("/teachers/:id/courses", teacherHandler.GetTeacherCourses)
+
+			admin.POST("/terms", academicTermHandler.Create)
+			admin.PUT("/terms/:id", academicTermHandler.Update)
+			admin.PATCH("/terms/:id/status", academicTermHandler.UpdateStatus)
+			admin.DELETE("/terms/:id", academicTermHandler.Delete)
+
+			admin.POST("/sections", courseSectionHandler.Create)
+			admin.PUT("/sections/:id", courseSectionHandler.Update)
+			admin.DELETE("/sections/:id", courseSectionHandler.Delete)
+
+			admin.POST("/grading-scales", gradingScaleHandler.Create)
+			admin.PUT("/grading-scales/:id", gradingScaleHandler.Update)
+			admin.DELETE("/grading-scales/:id", gradingScaleHandler.Delete)
+
+			admin.POST("/guardians", guardianHandler.Link)
+			admin.PUT("/guardians/:id", guardianHandler.Update)
+			admin.DELETE("/guardians/:id", guardianHandler.Unlink)
+			admin.GET("/guardians/student/:student_id", guardianHandler.GetByStudent)
 		}
 
 		teacher := api.Group("/teacher")
 		teacher.Use(middleware.RoleMiddleware(models.RoleTeacher))
 		{
 			teacher.POST("/grades", gradeHandler.RecordGrade)
-			teacher.PUT("/grades/:id", gradeHandler.UpdateGrade)
+			teacher.PUT("/grades/:id", authz.Require(authz.ActionUpdate, authz.Record(authz.KindGrade, "id")), gradeHandler.UpdateGrade)
 			teacher.POST("/attendance", attendanceHandler.RecordAttendance)
-			teacher.PUT("/attendance/:id", attendanceHandler.UpdateAttendance)
+			teacher.PUT("/attendance/:id", authz.Require(authz.ActionUpdate, authz.Record(authz.KindAttendance, "id")), attendanceHandler.UpdateAttendance)
 
 			teacher.GET("/assignments", assignmentHandler.GetAssignmentsByTeacher)
-			teacher.GET("/submissions/assignment/:assignment_id", assignmentHandler.GetSubmissionsByAssignment)
-			teacher.PUT("/submissions/:submission_id/grade", assignmentHandler.GradeSubmission)
+			teacher.GET("/submissions/assignment/:assignment_id", authz.Require(authz.ActionList, authz.RecordAs(authz.KindAssignment, "assignment_id", authz.KindSubmission)), assignmentHandler.GetSubmissionsByAssignment)
+			teacher.PUT("/submissions/:submission_id/grade", authz.Require(authz.ActionGrade, authz.Record(authz.KindSubmission, "submission_id")), assignmentHandler.GradeSubmission)
+
+			// Gradebook
+			teacher.POST("/gradebook/categories", gradebookHandler.CreateCategory)
+			teacher.PUT("/gradebook/categories/:id", authz.Require(authz.ActionUpdate, authz.Record(authz.KindGradebook, "id")), gradebookHandler.UpdateCategory)
+			teacher.DELETE("/gradebook/categories/:id", authz.Require(authz.ActionDelete, authz.Record(authz.KindGradebook, "id")), gradebookHandler.DeleteCategory)
+			teacher.GET("/gradebook/course/:course_id", authz.Require(authz.ActionRead, authz.Course(authz.KindGradebook, "course_id")), gradebookHandler.GetGradebook)
+			teacher.POST("/gradebook/course/:course_id/recompute", authz.Require(authz.ActionUpdate, authz.Course(authz.KindGradebook, "course_id")), gradebookHandler.Recompute)
+			api.GET("/gradebook/categories/course/:course_id", authz.Require(authz.ActionRead, authz.Course(authz.KindGradebook, "course_id")), gradebookHandler.GetCourseCategories)
 
 			// Notifications
 			api.POST("/notifications", notificationHandler.Create)
 			api.GET("/notifications", notificationHandler.GetMyNotifications)
 			api.GET("/notifications/unread", notificationHandler.GetUnread)
+			api.GET("/events/stream", realtimeHandler.Stream)
+			api.GET("/notifications/preferences", notificationPreferenceHandler.GetMine)
+			api.PUT("/notifications/preferences", notificationPreferenceHandler.UpdateMine)
 			api.PUT("/notifications/:id/read", notificationHandler.MarkAsRead)
 			api.PUT("/notifications/mark-all-read", notificationHandler.MarkAllAsRead)
 			api.DELETE("/notifications/:id", notificationHandler.Delete)
@@ -306,71 +489,119 @@ func main() {
 			api.GET("/messages/inbox", messageHandler.GetInbox)
 			api.GET("/messages/conversation/:user_id", messageHandler.GetConversation)
 			api.GET("/messages/unread", messageHandler.CountUnread)
+			api.PUT("/messages/:id", messageHandler.Edit)
+			api.DELETE("/messages/:id", messageHandler.Delete)
 			api.PUT("/messages/:id/read", messageHandler.MarkAsRead)
+			api.POST("/messages/broadcasts", middleware.RoleMiddleware(models.RoleTeacher, models.RoleAdmin), messageHandler.Broadcast)
+			api.POST("/messages/attachments", messageHandler.UploadAttachment)
+			api.GET("/messages/attachments/:id", messageHandler.DownloadAttachment)
+			api.POST("/messages/groups", messageHandler.CreateGroup)
+			api.GET("/messages/courses/:course_id/channel", messageHandler.GetCourseChannel)
+			api.GET("/messages/conversations/:id", messageHandler.GetConversationByID)
+			api.GET("/messages/conversations/:id/messages", messageHandler.GetMessages)
+			api.PUT("/messages/conversations/:id/read", messageHandler.MarkConversationRead)
+			api.POST("/messages/conversations/:id/participants", messageHandler.AddParticipants)
+			api.DELETE("/messages/conversations/:id/participants/:user_id", messageHandler.RemoveParticipant)
 
 			// Announcements
 			api.GET("/announcements", announcementHandler.GetAll)
 			api.GET("/announcements/active", announcementHandler.GetActive)
-			api.POST("/announcements", announcementHandler.Create)
-			api.PUT("/announcements/:id", announcementHandler.Update)
-			api.DELETE("/announcements/:id", announcementHandler.Delete)
+			api.POST("/announcements", authz.Require(authz.ActionCreate, authz.All(authz.KindAnnouncement)), announcementHandler.Create)
+			api.PUT("/announcements/:id", authz.Require(authz.ActionUpdate, authz.Record(authz.KindAnnouncement, "id")), announcementHandler.Update)
+			api.DELETE("/announcements/:id", authz.Require(authz.ActionDelete, authz.Record(authz.KindAnnouncement, "id")), announcementHandler.Delete)
+			api.POST("/announcements/:id/read", announcementHandler.MarkRead)
+			api.POST("/announcements/:id/acknowledge", announcementHandler.Acknowledge)
+			api.GET("/announcements/:id/receipts", authz.Require(authz.ActionUpdate, authz.Record(authz.KindAnnouncement, "id")), announcementHandler.GetReceipts)
+			api.GET("/announcements/:id/recipients", authz.Require(authz.ActionUpdate, authz.Record(authz.KindAnnouncement, "id")), announcementHandler.GetRecipients)
 
 			// Advanced Search
 			api.GET("/search/announcements", searchHandler.SearchAnnouncements)
-			api.GET("/search/payments", searchHandler.SearchPayments)
-			api.GET("/search/students", searchHandler.SearchStudents)
-			api.GET("/search/grades", searchHandler.SearchGradesByRange)
-			api.GET("/search/overdue-payments", searchHandler.SearchOverduePayments)
+			api.GET("/search/payments", authz.Require(authz.ActionList, authz.All(authz.KindPayment)), searchHandler.SearchPayments)
+			api.GET("/search/students", authz.Require(authz.ActionList, authz.All(authz.KindStudent)), searchHandler.SearchStudents)
+			api.GET("/search/grades", authz.Require(authz.ActionList, authz.CourseQuery(authz.KindGrade, "course_id")), searchHandler.SearchGradesByRange)
+			api.GET("/search/overdue-payments", authz.Require(authz.ActionList, authz.All(authz.KindPayment)), searchHandler.SearchOverduePayments)
 
 			// CSV Exports
-			api.GET("/export/payments", exportHandler.ExportPaymentsCSV)
-			api.GET("/export/grades", exportHandler.ExportGradesCSV)
-			api.GET("/export/attendance", exportHandler.ExportAttendanceCSV)
-			api.GET("/export/transcript/:student_id", exportHandler.ExportStudentTranscript)
-			api.GET("/export/enrollments", exportHandler.ExportEnrollments)
+			api.GET("/export/payments", authz.Require(authz.ActionRead, authz.StudentQuery(authz.KindPayment, "student_id")), exportHandler.ExportPaymentsCSV)
+			api.GET("/export/grades", authz.Require(authz.ActionList, authz.CourseQuery(authz.KindGrade, "course_id")), exportHandler.ExportGradesCSV)
+			api.GET("/export/attendance", authz.Require(authz.ActionList, authz.CourseQuery(authz.KindAttendance, "course_id")), exportHandler.ExportAttendanceCSV)
+			api.GET("/export/transcript/:student_id", authz.Require(authz.ActionRead, authz.Student(authz.KindTranscript, "student_id")), exportHandler.ExportStudentTranscript)
+			api.GET("/export/enrollments", authz.Require(authz.ActionList, authz.CourseQuery(authz.KindEnrollment, "course_id")), exportHandler.ExportEnrollments)
 
 			// Attendance Automation
-			api.GET("/attendance/stats/course/:course_id", attendanceAutomationHandler.GetAttendanceStats)
-			api.GET("/attendance/percentage/:student_id/:course_id", attendanceAutomationHandler.GetStudentAttendancePercentage)
-			api.POST("/attendance/check-low", attendanceAutomationHandler.CheckLowAttendance)
-			api.GET("/attendance/low/:threshold", attendanceAutomationHandler.GetStudentsWithLowAttendance)
-			api.GET("/attendance/report/:course_id", attendanceAutomationHandler.GetAttendanceReport)
+			api.GET("/attendance/stats/course/:course_id", authz.Require(authz.ActionList, authz.Course(authz.KindAttendance, "course_id")), attendanceAutomationHandler.GetAttendanceStats)
+			api.GET("/attendance/percentage/:student_id/:course_id", authz.Require(authz.ActionRead, authz.StudentInCourse(authz.KindAttendance, "student_id", "course_id")), attendanceAutomationHandler.GetStudentAttendancePercentage)
+			api.POST("/attendance/check-low", authz.Require(authz.ActionCreate, authz.All(authz.KindReport)), attendanceAutomationHandler.CheckLowAttendance)
+			api.GET("/attendance/low/:threshold", authz.Require(authz.ActionList, authz.All(authz.KindAttendance)), attendanceAutomationHandler.GetStudentsWithLowAttendance)
+			api.GET("/attendance/report/:course_id", authz.Require(authz.ActionList, authz.Course(authz.KindAttendance, "course_id")), attendanceAutomationHandler.GetAttendanceReport)
 
 			// Grade Auto-Calculation
 			api.POST("/grades/auto", gradeAutoCalcHandler.RecordGradeWithAutoCalc)
-			api.GET("/grades/course-average/:course_id", gradeAutoCalcHandler.GetCourseAverage)
-			api.GET("/grades/distribution/:course_id", gradeAutoCalcHandler.GetGradeDistribution)
-			api.GET("/grades/student-stats/:student_id", gradeAutoCalcHandler.GetStudentGradeStats)
+			api.GET("/grades/course-average/:course_id", authz.Require(authz.ActionList, authz.Course(authz.KindGrade, "course_id")), gradeAutoCalcHandler.GetCourseAverage)
+			api.GET("/grades/distribution/:course_id", authz.Require(authz.ActionList, authz.Course(authz.KindGrade, "course_id")), gradeAutoCalcHandler.GetGradeDistribution)
+			api.GET("/grades/student-stats/:student_id", authz.Require(authz.ActionRead, authz.Student(authz.KindGrade, "student_id")), gradeAutoCalcHandler.GetStudentGradeStats)
 
 			// Rubrics
 			api.POST("/rubrics", rubricHandler.CreateRubric)
 			api.GET("/rubrics/:id", rubricHandler.GetRubric)
 			api.GET("/rubrics/assignment/:assignment_id", rubricHandler.GetRubricsByAssignment)
-			api.PUT("/rubrics/:id", rubricHandler.UpdateRubric)
-			api.DELETE("/rubrics/:id", rubricHandler.DeleteRubric)
-			api.POST("/rubrics/score", rubricHandler.ScoreSubmission)
-			api.GET("/rubrics/score/:submission_id", rubricHandler.GetSubmissionScore)
+			api.PUT("/rubrics/:id", authz.Require(authz.ActionUpdate, authz.Record(authz.KindRubric, "id")), rubricHandler.UpdateRubric)
+			api.DELETE("/rubrics/:id", authz.Require(authz.ActionDelete, authz.Record(authz.KindRubric, "id")), rubricHandler.DeleteRubric)
+			api.POST("/rubrics/score/:submission_id", rubricHandler.ScoreSubmission)
+			api.GET("/rubrics/score/:submission_id", authz.Require(authz.ActionRead, authz.Record(authz.KindSubmission, "submission_id")), rubricHandler.GetSubmissionScore)
 
 			// Timetable
 			api.GET("/timetable", timetableHandler.GetAll)
 			api.GET("/timetable/course/:course_id", timetableHandler.GetByCourseID)
+			api.GET("/timetable/section/:section_id", timetableHandler.GetBySectionID)
 			api.GET("/timetable/teacher/:teacher_id", timetableHandler.GetByTeacherID)
 			api.GET("/timetable/day/:day", timetableHandler.GetByDay)
-			api.POST("/timetable", timetableHandler.Create)
-			api.PUT("/timetable/:id", timetableHandler.Update)
-			api.DELETE("/timetable/:id", timetableHandler.Delete)
+			api.POST("/timetable", authz.Require(authz.ActionCreate, authz.All(authz.KindTimetable)), timetableHandler.Create)
+			api.PUT("/timetable/:id", authz.Require(authz.ActionUpdate, authz.All(authz.KindTimetable)), timetableHandler.Update)
+			api.DELETE("/timetable/:id", authz.Require(authz.ActionDelete, authz.All(authz.KindTimetable)), timetableHandler.Delete)
+			api.POST("/timetable/generate", authz.Require(authz.ActionUpdate, authz.All(authz.KindTimetable)), timetableGeneratorHandler.Generate)
+			api.GET("/timetable/conflicts", authz.Require(authz.ActionUpdate, authz.All(authz.KindTimetable)), timetableGeneratorHandler.Conflicts)
+			api.GET("/timetable/drafts/:id", authz.Require(authz.ActionUpdate, authz.All(authz.KindTimetable)), timetableGeneratorHandler.GetDraft)
+			api.POST("/timetable/drafts/:id/commit", authz.Require(authz.ActionUpdate, authz.All(authz.KindTimetable)), timetableGeneratorHandler.Commit)
+			api.DELETE("/timetable/drafts/:id", authz.Require(authz.ActionUpdate, authz.All(authz.KindTimetable)), timetableGeneratorHandler.Discard)
+			api.GET("/teachers/:id/availability", timetableHandler.GetTeacherAvailability)
+			api.PUT("/teachers/:id/availability", authz.Require(authz.ActionUpdate, authz.All(authz.KindTimetable)), timetableHandler.SetTeacherAvailability)
+
+			// Rooms
+			api.GET("/rooms", roomHandler.List)
+			api.GET("/rooms/free", roomHandler.FindFree)
+			api.GET("/rooms/utilization", authz.Require(authz.ActionList, authz.All(authz.KindReport)), roomHandler.Utilization)
+			api.GET("/rooms/:id", roomHandler.GetByID)
+			api.POST("/rooms", authz.Require(authz.ActionCreate, authz.All(authz.KindTimetable)), roomHandler.Create)
+			api.PUT("/rooms/:id", authz.Require(authz.ActionUpdate, authz.All(authz.KindTimetable)), roomHandler.Update)
+			api.DELETE("/rooms/:id", authz.Require(authz.ActionDelete, authz.All(authz.KindTimetable)), roomHandler.Delete)
+			api.GET("/resources", roomHandler.ListResources)
+			api.GET("/resources/:id", roomHandler.GetResource)
+			api.POST("/resources", authz.Require(authz.ActionCreate, authz.All(authz.KindTimetable)), roomHandler.CreateResource)
+			api.PUT("/resources/:id", authz.Require(authz.ActionUpdate, authz.All(authz.KindTimetable)), roomHandler.UpdateResource)
+			api.DELETE("/resources/:id", authz.Require(authz.ActionDelete, authz.All(authz.KindTimetable)), roomHandler.DeleteResource)
+
+			// Room and resource bookings
+			api.GET("/bookings", authz.Require(authz.ActionList, authz.All(authz.KindBooking)), roomHandler.ListBookings)
+			api.GET("/bookings/:id", authz.Require(authz.ActionRead, authz.Record(authz.KindBooking, "id")), roomHandler.GetBooking)
+			api.POST("/bookings", authz.Require(authz.ActionCreate, authz.All(authz.KindBooking)), roomHandler.CreateBooking)
+			api.POST("/bookings/:id/cancel", authz.Require(authz.ActionUpdate, authz.Record(authz.KindBooking, "id")), roomHandler.CancelBooking)
 
 			// Grade Transcripts
-			api.GET("/transcripts/student/:student_id", gradeTranscriptHandler.GetByStudentID)
-			api.GET("/transcripts/latest/:student_id", gradeTranscriptHandler.GetLatest)
-			api.GET("/transcripts/gpa/:student_id", gradeTranscriptHandler.GetGPA)
+			api.GET("/transcripts/student/:student_id", authz.Require(authz.ActionRead, authz.Student(authz.KindTranscript, "student_id")), gradeTranscriptHandler.GetByStudentID)
+			api.GET("/transcripts/latest/:student_id", authz.Require(authz.ActionRead, authz.Student(authz.KindTranscript, "student_id")), gradeTranscriptHandler.GetLatest)
+			api.GET("/transcripts/gpa/:student_id", authz.Require(authz.ActionRead, authz.Student(authz.KindTranscript, "student_id")), gradeTranscriptHandler.GetGPA)
+			api.GET("/transcripts/transfer-credits/:student_id", authz.Require(authz.ActionRead, authz.Student(authz.KindTranscript, "student_id")), gradeTranscriptHandler.GetTransferCredits)
+			admin.POST("/transcripts/regenerate/:student_id", gradeTranscriptHandler.Regenerate)
+			admin.POST("/transfer-credits", gradeTranscriptHandler.AddTransferCredit)
+			admin.DELETE("/transfer-credits/:id", gradeTranscriptHandler.DeleteTransferCredit)
 
 			// Payments
-			api.POST("/payments", paymentHandler.Create)
-			api.GET("/payments/student/:student_id", paymentHandler.GetByStudent)
-			api.GET("/payments", paymentHandler.GetAll)
-			api.PUT("/payments/:id", paymentHandler.Update)
-			api.GET("/payments/balance/:student_id", paymentHandler.GetStudentBalance)
+			api.POST("/payments", authz.Require(authz.ActionCreate, authz.All(authz.KindPayment)), paymentHandler.Create)
+			api.GET("/payments/student/:student_id", authz.Require(authz.ActionRead, authz.Student(authz.KindPayment, "student_id")), paymentHandler.GetByStudent)
+			api.GET("/payments", authz.Require(authz.ActionList, authz.All(authz.KindPayment)), paymentHandler.GetAll)
+			api.PUT("/payments/:id", authz.Require(authz.ActionUpdate, authz.Record(authz.KindPayment, "id")), paymentHandler.Update)
+			api.GET("/payments/balance/:student_id", authz.Require(authz.ActionRead, authz.Student(authz.KindPayment, "student_id")), paymentHandler.GetStudentBalance)
 
 			// System Settings (admin only)
 			admin.GET("/settings", systemSettingHandler.GetAll)
@@ -380,13 +611,41 @@ func main() {
 			admin.DELETE("/settings/:id", systemSettingHandler.Delete)
 
 			// Backups (admin only)
+			admin.POST("/backups", backupHandler.Create)
+			admin.GET("/jobs", jobHandler.GetAll)
+			admin.GET("/jobs/runs", jobHandler.GetRuns)
+			admin.GET("/jobs/:name", jobHandler.GetByName)
+			admin.GET("/jobs/:name/runs", jobHandler.GetRuns)
+			admin.POST("/jobs/:name/trigger", jobHandler.Trigger)
+			admin.POST("/jobs/:name/pause", jobHandler.Pause)
+			admin.POST("/jobs/:name/resume", jobHandler.Resume)
+
+			// Email outbox
+			admin.GET("/emails", emailOutboxHandler.GetAll)
+			admin.GET("/emails/stats", emailOutboxHandler.GetStats)
+			admin.GET("/emails/:id", emailOutboxHandler.GetByID)
+			admin.POST("/emails/:id/retry", emailOutboxHandler.Retry)
+			admin.GET("/notification-deliveries", notificationPreferenceHandler.GetDeliveries)
+
+			// Email templates
+			admin.GET("/email-templates", emailTemplateHandler.GetAll)
+			admin.GET("/email-templates/:key", emailTemplateHandler.GetByKey)
+			admin.POST("/email-templates/:key/preview", emailTemplateHandler.Preview)
+			admin.PUT("/email-templates/:key/:locale", emailTemplateHandler.Save)
+			admin.DELETE("/email-templates/:key/:locale", emailTemplateHandler.Delete)
+
 			admin.GET("/backups", backupHandler.GetAll)
 			admin.GET("/backups/latest", backupHandler.GetLatest)
 			admin.GET("/backups/:id", backupHandler.GetByID)
+			admin.POST("/backups/:id/verify", backupHandler.Verify)
+			admin.GET("/backups/:id/download", backupHandler.Download)
 			admin.DELETE("/backups/:id", backupHandler.Delete)
 
 			// Import batches (admin only)
+			admin.POST("/imports", importBatchHandler.Upload)
 			admin.GET("/imports", importBatchHandler.GetAll)
+			admin.GET("/imports/columns/:entity", importBatchHandler.Columns)
+			admin.GET("/imports/:id/errors", importBatchHandler.Errors)
 			admin.GET("/imports/:id", importBatchHandler.GetByID)
 			admin.GET("/imports/status/:status", importBatchHandler.GetByStatus)
 			admin.DELETE("/imports/:id", importBatchHandler.Delete)
@@ -396,11 +655,29 @@ func main() {
 		student.Use(middleware.RoleMiddleware(models.RoleStudent))
 		{
 			student.GET("/enrollments", enrollmentHandler.GetMyEnrollments)
+			student.GET("/registration/catalog", registrationHandler.Catalog)
+			student.GET("/registration/cart", registrationHandler.GetCart)
+			student.POST("/registration/cart", registrationHandler.AddToCart)
+			student.DELETE("/registration/cart/:section_id", registrationHandler.RemoveFromCart)
+			student.POST("/registration/validate", registrationHandler.Validate)
+			student.POST("/registration/register", registrationHandler.Register)
 			student.GET("/grades", gradeHandler.GetMyGrades)
 			student.GET("/attendance", attendanceHandler.GetMyAttendance)
 
 			student.GET("/assignments/submissions", assignmentHandler.GetSubmissionsByStudent)
 		}
+
+		parent := api.Group("/parent")
+		parent.Use(middleware.RoleMiddleware(models.RoleParent))
+		{
+			parent.GET("/children", guardianHandler.GetMyChildren)
+			parent.GET("/children/:student_id/grades", authz.Require(authz.ActionRead, authz.Student(authz.KindGrade, "student_id")), guardianHandler.GetChildGrades)
+			parent.GET("/children/:student_id/attendance", authz.Require(authz.ActionRead, authz.Student(authz.KindAttendance, "student_id")), guardianHandler.GetChildAttendance)
+			parent.GET("/children/:student_id/assignments", authz.Require(authz.ActionRead, authz.Student(authz.KindSubmission, "student_id")), guardianHandler.GetChildAssignments)
+			parent.GET("/children/:student_id/timetable", authz.Require(authz.ActionRead, authz.Student(authz.KindEnrollment, "student_id")), guardianHandler.GetChildTimetable)
+			parent.GET("/children/:student_id/balance", authz.Require(authz.ActionRead, authz.Student(authz.KindPayment, "student_id")), guardianHandler.GetChildBalance)
+			parent.GET("/announcements", guardianHandler.GetAnnouncements)
+		}
 	}
 
 	server := &http.Server{
@@ -422,6 +699,10 @@ func main() {
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 	appLogger.Info("Shutting down server...")
+	jobScheduler.Stop()
+	notifier.Stop()
+	emailOutbox.Stop()
+	realtimeService.Stop()
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
@@ -449,15 +730,16 @@ func createAdminUser(db *gorm.DB, cfg *config.Config, logger *logrus.Logger) {
 
 	if count == 0 {
 		adminUser := &models.User{
-			FirstName:   "Admin",
-			LastName:    "User",
-			Email:       adminEmail,
-			Password:    adminPass,
-			Phone:       "1234567890",
-			Role:        models.RoleAdmin,
-			DateOfBirth: time.Now().AddDate(-30, 0, 0),
-			Address:     "School Address",
-			IsActive:    true,
+			FirstName:     "Admin",
+			LastName:      "User",
+			Email:         adminEmail,
+			Password:      adminPass,
+			Phone:         "1234567890",
+			Role:          models.RoleAdmin,
+			DateOfBirth:   time.Now().AddDate(-30, 0, 0),
+			Address:       "School Address",
+			IsActive:      true,
+			EmailVerified: true,
 		}
 
 		if err := db.Create(adminUser).Error; err != nil {
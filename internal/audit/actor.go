@@ -0,0 +1,48 @@
+package audit
+
+import (
+	"context"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Actor is who a change is attributed to. The zero Actor is the system:
+// changes made without one on the context, such as those of background
+// jobs and startup tasks, are logged with user ID 0.
+type Actor struct {
+	UserID    uint
+	IPAddress string
+	RequestID string
+}
+
+type actorKey struct{}
+
+// WithActor returns a copy of ctx that attributes changes to actor
+func WithActor(ctx context.Context, actor Actor) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFrom returns the actor on ctx, or the system if there is none
+func ActorFrom(ctx context.Context) Actor {
+	if ctx == nil {
+		return Actor{}
+	}
+	actor, _ := ctx.Value(actorKey{}).(Actor)
+	return actor
+}
+
+// Middleware puts the authenticated user, client IP and request ID on the
+// request context, so the changes the handler makes through services given
+// c.Request.Context() are attributed to them. It runs after the auth
+// middleware; on public routes the user is 0.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		actor := Actor{
+			UserID:    c.GetUint("user_id"),
+			IPAddress: c.ClientIP(),
+			RequestID: c.GetString("request_id"),
+		}
+		c.Request = c.Request.WithContext(WithActor(c.Request.Context(), actor))
+		c.Next()
+	}
+}
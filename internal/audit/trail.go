@@ -0,0 +1,286 @@
+// Package audit records who changed what. Register installs GORM callbacks
+// that write an AuditLog row for every create, update and delete of a
+// grade, attendance record, payment, enrollment or user, whichever code
+// path made it, with snapshots of the row before and after. Changes are
+// attributed to the Actor on the statement's context.
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
+
+	"school-management-system/internal/models"
+	"school-management-system/pkg/logger"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// entities maps the audited tables to the entity names used in the log
+var entities = map[string]string{
+	"grades":      "grade",
+	"attendances": "attendance",
+	"payments":    "payment",
+	"enrollments": "enrollment",
+	"users":       "user",
+}
+
+// redactedColumns are never written to the audit log
+var redactedColumns = map[string]bool{
+	"password":   true,
+	"token_hash": true,
+	"secret":     true,
+	"code_hash":  true,
+}
+
+// beforeKey holds the rows an update or delete is about to change
+const beforeKey = "audit:before"
+
+// Register installs the audit callbacks on db. Entries are written on the
+// statement's connection, so a change made in a transaction is logged in
+// it and rolled back with it.
+func Register(db *gorm.DB) error {
+	callbacks := db.Callback()
+	steps := []error{
+		callbacks.Create().After("gorm:create").Register("audit:create", recordCreate),
+		callbacks.Update().Before("gorm:update").Register("audit:before_update", loadBefore),
+		callbacks.Update().After("gorm:update").Register("audit:update", recordUpdate),
+		callbacks.Delete().Before("gorm:delete").Register("audit:before_delete", loadBefore),
+		callbacks.Delete().After("gorm:delete").Register("audit:delete", recordDelete),
+	}
+	for _, err := range steps {
+		if err != nil {
+			return fmt.Errorf("register audit callbacks: %w", err)
+		}
+	}
+	return nil
+}
+
+// audited returns the entity name of the statement's table, if it is one
+// that is audited
+func audited(db *gorm.DB) (string, bool) {
+	if db.Statement.Schema == nil {
+		return "", false
+	}
+	entity, ok := entities[db.Statement.Schema.Table]
+	return entity, ok
+}
+
+func recordCreate(db *gorm.DB) {
+	entity, ok := audited(db)
+	if !ok || db.Error != nil || db.Statement.RowsAffected == 0 {
+		return
+	}
+	ids := primaryKeys(db)
+	if len(ids) == 0 {
+		return
+	}
+	var entries []models.AuditLog
+	for _, row := range snapshot(db, ids) {
+		entries = append(entries, entry(db, models.AuditActionCreate, entity, nil, row))
+	}
+	write(db, entity, entries)
+}
+
+// loadBefore snapshots the rows an update or delete will change, found by
+// the statement's conditions and the primary key of its model
+func loadBefore(db *gorm.DB) {
+	if _, ok := audited(db); !ok || db.Error != nil {
+		return
+	}
+	query := db.Session(&gorm.Session{NewDB: true}).Unscoped().Model(reflect.New(db.Statement.Schema.ModelType).Interface())
+	scoped := false
+	if c, ok := db.Statement.Clauses["WHERE"]; ok {
+		if where, ok := c.Expression.(clause.Where); ok && len(where.Exprs) > 0 {
+			query = query.Clauses(where)
+			scoped = true
+		}
+	}
+	if ids := primaryKeys(db); len(ids) > 0 {
+		query = query.Where("id IN ?", ids)
+		scoped = true
+	}
+	if !scoped {
+		// GORM refuses updates and deletes without conditions
+		return
+	}
+	var rows []map[string]interface{}
+	if err := query.Find(&rows).Error; err != nil {
+		logger.GetLogger().WithError(err).WithField("table", db.Statement.Schema.Table).Error("Failed to read rows for the audit log")
+		return
+	}
+	for _, row := range rows {
+		redact(row)
+	}
+	db.InstanceSet(beforeKey, rows)
+}
+
+func recordUpdate(db *gorm.DB) {
+	entity, before := changed(db)
+	if len(before) == 0 {
+		return
+	}
+	ids := make([]uint, 0, len(before))
+	for _, row := range before {
+		ids = append(ids, rowID(row))
+	}
+	after := map[uint]map[string]interface{}{}
+	for _, row := range snapshot(db, ids) {
+		after[rowID(row)] = row
+	}
+
+	var entries []models.AuditLog
+	for _, old := range before {
+		updated, ok := after[rowID(old)]
+		if !ok {
+			continue
+		}
+		e := entry(db, models.AuditActionUpdate, entity, old, updated)
+		if e.OldValue == e.NewValue {
+			// Nothing changed
+			continue
+		}
+		entries = append(entries, e)
+	}
+	write(db, entity, entries)
+}
+
+func recordDelete(db *gorm.DB) {
+	entity, before := changed(db)
+	var entries []models.AuditLog
+	for _, old := range before {
+		entries = append(entries, entry(db, models.AuditActionDelete, entity, old, nil))
+	}
+	write(db, entity, entries)
+}
+
+// changed returns the rows loadBefore saw, if the statement went on to
+// change any
+func changed(db *gorm.DB) (string, []map[string]interface{}) {
+	entity, ok := audited(db)
+	if !ok || db.Error != nil || db.Statement.RowsAffected == 0 {
+		return "", nil
+	}
+	value, ok := db.InstanceGet(beforeKey)
+	if !ok {
+		return "", nil
+	}
+	rows, _ := value.([]map[string]interface{})
+	return entity, rows
+}
+
+// entry describes a change to one row; old is nil for creates and updated
+// is nil for deletes
+func entry(db *gorm.DB, action, entity string, old, updated map[string]interface{}) models.AuditLog {
+	actor := ActorFrom(db.Statement.Context)
+	row := updated
+	if row == nil {
+		row = old
+	}
+	e := models.AuditLog{
+		UserID:    actor.UserID,
+		Action:    action,
+		Entity:    entity,
+		EntityID:  rowID(row),
+		IPAddress: actor.IPAddress,
+		RequestID: actor.RequestID,
+		Status:    "success",
+		CreatedAt: time.Now().Unix(),
+	}
+	if old != nil {
+		e.OldValue = encode(old)
+	}
+	if updated != nil {
+		e.NewValue = encode(updated)
+	}
+	return e
+}
+
+func write(db *gorm.DB, entity string, entries []models.AuditLog) {
+	if len(entries) == 0 {
+		return
+	}
+	if err := db.Session(&gorm.Session{NewDB: true}).Create(&entries).Error; err != nil {
+		logger.GetLogger().WithError(err).WithFields(logrus.Fields{
+			"entity":  entity,
+			"entries": len(entries),
+		}).Error("Failed to write audit log")
+	}
+}
+
+// primaryKeys returns the non-zero IDs of the statement's model or, for
+// batch statements, models
+func primaryKeys(db *gorm.DB) []uint {
+	field := db.Statement.Schema.PrioritizedPrimaryField
+	if field == nil {
+		return nil
+	}
+	var values []reflect.Value
+	switch rv := reflect.Indirect(db.Statement.ReflectValue); rv.Kind() {
+	case reflect.Struct:
+		values = append(values, rv)
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			values = append(values, reflect.Indirect(rv.Index(i)))
+		}
+	}
+
+	var ids []uint
+	for _, v := range values {
+		if v.Kind() != reflect.Struct || v.Type() != db.Statement.Schema.ModelType {
+			continue
+		}
+		if id, zero := field.ValueOf(db.Statement.Context, v); !zero {
+			ids = append(ids, toUint(id))
+		}
+	}
+	return ids
+}
+
+// snapshot reads the stored rows with the given IDs as column/value pairs,
+// with secrets redacted
+func snapshot(db *gorm.DB, ids []uint) []map[string]interface{} {
+	var rows []map[string]interface{}
+	err := db.Session(&gorm.Session{NewDB: true}).Unscoped().
+		Model(reflect.New(db.Statement.Schema.ModelType).Interface()).
+		Where("id IN ?", ids).Find(&rows).Error
+	if err != nil {
+		logger.GetLogger().WithError(err).WithField("table", db.Statement.Schema.Table).Error("Failed to read rows for the audit log")
+		return nil
+	}
+	for _, row := range rows {
+		redact(row)
+	}
+	return rows
+}
+
+func redact(row map[string]interface{}) {
+	for column := range row {
+		if redactedColumns[column] {
+			row[column] = "[redacted]"
+		}
+	}
+}
+
+func rowID(row map[string]interface{}) uint {
+	return toUint(row["id"])
+}
+
+func toUint(v interface{}) uint {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	switch rv.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return uint(rv.Uint())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return uint(rv.Int())
+	}
+	return 0
+}
+
+func encode(row map[string]interface{}) string {
+	data, _ := json.Marshal(row)
+	return string(data)
+}
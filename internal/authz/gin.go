@@ -0,0 +1,171 @@
+package authz
+
+import (
+	"net/http"
+	"strconv"
+
+	"school-management-system/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	policyKey  = "authz_policy"
+	subjectKey = "authz_subject"
+)
+
+// Middleware loads the authenticated user's Subject into the request. It
+// must run after AuthMiddleware has set user_id and user_role.
+func Middleware(p *Policy) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role, _ := c.Get("user_role")
+		roleName, _ := role.(string)
+
+		c.Set(policyKey, p)
+		c.Set(subjectKey, p.SubjectFor(c.GetUint("user_id"), models.UserRole(roleName)))
+		c.Next()
+	}
+}
+
+// CurrentSubject returns the Subject loaded by Middleware.
+func CurrentSubject(c *gin.Context) Subject {
+	s, _ := c.Get(subjectKey)
+	subject, _ := s.(Subject)
+	return subject
+}
+
+// Authorize checks the current subject against the resource and, if denied,
+// aborts the request with 403. Handlers use it for resources they only know
+// after reading the request body.
+func Authorize(c *gin.Context, action Action, r Resource) bool {
+	v, _ := c.Get(policyKey)
+	p, ok := v.(*Policy)
+	if !ok || !p.Can(CurrentSubject(c), action, r) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You do not have access to this resource"})
+		c.Abort()
+		return false
+	}
+	return true
+}
+
+// AuthorizeRecord looks up a stored record by ID and authorizes action on
+// it, aborting with 404 or 403 on failure.
+func AuthorizeRecord(c *gin.Context, action Action, kind Kind, id uint) bool {
+	v, _ := c.Get(policyKey)
+	p, ok := v.(*Policy)
+	if !ok {
+		return Authorize(c, action, Resource{Kind: kind})
+	}
+
+	r, found := p.Lookup(kind, id)
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Resource not found"})
+		c.Abort()
+		return false
+	}
+	return Authorize(c, action, r)
+}
+
+// ResourceFunc builds the Resource a route acts on from the request. It
+// returns false if the resource does not exist.
+type ResourceFunc func(c *gin.Context, p *Policy) (Resource, bool)
+
+// Require is route middleware that authorizes action on the resource built
+// by target, responding 404 for missing records and 403 when denied.
+func Require(action Action, target ResourceFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		v, _ := c.Get(policyKey)
+		p, ok := v.(*Policy)
+		if !ok {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You do not have access to this resource"})
+			c.Abort()
+			return
+		}
+
+		r, found := target(c, p)
+		if !found {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Resource not found"})
+			c.Abort()
+			return
+		}
+
+		if Authorize(c, action, r) {
+			c.Next()
+		}
+	}
+}
+
+func paramID(c *gin.Context, name string) uint {
+	id, _ := strconv.ParseUint(c.Param(name), 10, 32)
+	return uint(id)
+}
+
+// All targets every record of a kind.
+func All(kind Kind) ResourceFunc {
+	return func(*gin.Context, *Policy) (Resource, bool) {
+		return Resource{Kind: kind}, true
+	}
+}
+
+// Student targets a student's records of a kind, by path parameter.
+func Student(kind Kind, param string) ResourceFunc {
+	return func(c *gin.Context, _ *Policy) (Resource, bool) {
+		return Resource{Kind: kind, StudentID: paramID(c, param)}, true
+	}
+}
+
+// StudentQuery targets a student's records of a kind, by query parameter.
+// Without the parameter it targets every record of the kind.
+func StudentQuery(kind Kind, name string) ResourceFunc {
+	return func(c *gin.Context, _ *Policy) (Resource, bool) {
+		id, _ := strconv.ParseUint(c.Query(name), 10, 32)
+		return Resource{Kind: kind, StudentID: uint(id)}, true
+	}
+}
+
+// Course targets a course's records of a kind, by path parameter.
+func Course(kind Kind, param string) ResourceFunc {
+	return func(c *gin.Context, _ *Policy) (Resource, bool) {
+		return Resource{Kind: kind, CourseID: paramID(c, param)}, true
+	}
+}
+
+// CourseQuery targets a course's records of a kind, by query parameter.
+func CourseQuery(kind Kind, name string) ResourceFunc {
+	return func(c *gin.Context, _ *Policy) (Resource, bool) {
+		id, _ := strconv.ParseUint(c.Query(name), 10, 32)
+		return Resource{Kind: kind, CourseID: uint(id)}, true
+	}
+}
+
+// Section targets a section's records of a kind, by path parameter.
+func Section(kind Kind, param string) ResourceFunc {
+	return func(c *gin.Context, _ *Policy) (Resource, bool) {
+		return Resource{Kind: kind, SectionID: paramID(c, param)}, true
+	}
+}
+
+// StudentInCourse targets one student's records of a kind in one course.
+func StudentInCourse(kind Kind, studentParam, courseParam string) ResourceFunc {
+	return func(c *gin.Context, _ *Policy) (Resource, bool) {
+		return Resource{Kind: kind, StudentID: paramID(c, studentParam), CourseID: paramID(c, courseParam)}, true
+	}
+}
+
+// Record targets a stored record, looked up by its ID path parameter.
+func Record(kind Kind, param string) ResourceFunc {
+	return func(c *gin.Context, p *Policy) (Resource, bool) {
+		return p.Lookup(kind, paramID(c, param))
+	}
+}
+
+// RecordAs looks up a stored record and targets its related records of
+// another kind, e.g. the submissions of an assignment.
+func RecordAs(lookup Kind, param string, kind Kind) ResourceFunc {
+	return func(c *gin.Context, p *Policy) (Resource, bool) {
+		r, ok := p.Lookup(lookup, paramID(c, param))
+		r.Kind = kind
+		r.OwnerUserID = 0
+		return r, ok
+	}
+}
@@ -0,0 +1,260 @@
+// Package authz decides whether a user may perform an action on a resource,
+// based on their role and their relationship to the records involved.
+package authz
+
+import "school-management-system/internal/models"
+
+// Action is what a subject wants to do with a resource.
+type Action string
+
+const (
+	ActionRead   Action = "read"
+	ActionList   Action = "list"
+	ActionCreate Action = "create"
+	ActionUpdate Action = "update"
+	ActionDelete Action = "delete"
+	ActionGrade  Action = "grade"
+)
+
+// Kind is the type of record being accessed.
+type Kind string
+
+const (
+	KindUser         Kind = "user"
+	KindStudent      Kind = "student"
+	KindCourse       Kind = "course"
+	KindEnrollment   Kind = "enrollment"
+	KindGrade        Kind = "grade"
+	KindAttendance   Kind = "attendance"
+	KindPayment      Kind = "payment"
+	KindTranscript   Kind = "transcript"
+	KindAssignment   Kind = "assignment"
+	KindSubmission   Kind = "submission"
+	KindRubric       Kind = "rubric"
+	KindGradebook    Kind = "gradebook"
+	KindTimetable    Kind = "timetable"
+	KindAnnouncement Kind = "announcement"
+	KindReport       Kind = "report"
+	KindBooking      Kind = "booking"
+	KindNotification Kind = "notification"
+)
+
+// Resource describes the record being accessed by what it relates to. Zero
+// IDs mean "not scoped": a Resource with only a CourseID is a whole course's
+// records, one with no IDs is every record of that kind.
+type Resource struct {
+	Kind        Kind
+	StudentID   uint
+	CourseID    uint
+	SectionID   uint
+	OwnerUserID uint // the user who created or owns the record
+}
+
+// StudentRecord describes one student's record in a course, or in a section
+// when only the section is known, as submitted in a request body.
+func StudentRecord(kind Kind, studentID, courseID uint, sectionID *uint) Resource {
+	r := Resource{Kind: kind, StudentID: studentID, CourseID: courseID}
+	if sectionID != nil {
+		r.SectionID = *sectionID
+	}
+	return r
+}
+
+// Subject is the authenticated user together with the relationships that
+// decide what they can see.
+type Subject struct {
+	UserID    uint
+	Role      models.UserRole
+	StudentID uint   // set for students
+	TeacherID uint   // set for teachers
+	Children  []uint // student IDs linked to a parent
+}
+
+// Relations answers the relationship questions the policy needs. The
+// database-backed implementation is NewDBRelations.
+type Relations interface {
+	StudentIDForUser(userID uint) uint
+	TeacherIDForUser(userID uint) uint
+	ChildrenOfUser(userID uint) []uint
+	CourseOfSection(sectionID uint) uint
+	// TeachesCourse reports whether the teacher teaches the course or one of
+	// its sections.
+	TeachesCourse(teacherID, courseID uint) bool
+	// TeachesStudent reports whether the student is enrolled in a course or
+	// section the teacher teaches, or has completed one; courseID 0 means any
+	// course. Requested, waitlisted and ended enrollments do not count.
+	TeachesStudent(teacherID, studentID, courseID uint) bool
+	// Lookup resolves a stored record to the Resource it represents.
+	Lookup(kind Kind, id uint) (Resource, bool)
+}
+
+// Policy evaluates authorization rules against a Relations source.
+type Policy struct {
+	rel Relations
+}
+
+func NewPolicy(rel Relations) *Policy {
+	return &Policy{rel: rel}
+}
+
+// SubjectFor loads the relationships of an authenticated user.
+func (p *Policy) SubjectFor(userID uint, role models.UserRole) Subject {
+	s := Subject{UserID: userID, Role: role}
+	switch role {
+	case models.RoleStudent:
+		s.StudentID = p.rel.StudentIDForUser(userID)
+	case models.RoleTeacher:
+		s.TeacherID = p.rel.TeacherIDForUser(userID)
+	case models.RoleParent:
+		s.Children = p.rel.ChildrenOfUser(userID)
+	}
+	return s
+}
+
+// Lookup resolves a stored record to a Resource.
+func (p *Policy) Lookup(kind Kind, id uint) (Resource, bool) {
+	return p.rel.Lookup(kind, id)
+}
+
+// Can reports whether the subject may perform the action on the resource.
+// Admins may do anything; everyone else is limited to records they own, are
+// linked to, or teach.
+func (p *Policy) Can(s Subject, action Action, r Resource) bool {
+	if s.Role == models.RoleAdmin {
+		return true
+	}
+	if r.CourseID == 0 && r.SectionID != 0 {
+		r.CourseID = p.rel.CourseOfSection(r.SectionID)
+	}
+
+	switch r.Kind {
+	case KindUser:
+		return (action == ActionRead || action == ActionUpdate) && r.OwnerUserID != 0 && r.OwnerUserID == s.UserID
+
+	case KindCourse, KindTimetable:
+		return action == ActionRead || action == ActionList
+
+	case KindAnnouncement:
+		switch action {
+		case ActionRead, ActionList:
+			return true
+		case ActionCreate:
+			return s.Role == models.RoleTeacher
+		}
+		return r.OwnerUserID != 0 && r.OwnerUserID == s.UserID
+
+	case KindBooking:
+		switch action {
+		case ActionRead, ActionList:
+			return true
+		case ActionCreate:
+			return s.Role == models.RoleTeacher
+		}
+		return r.OwnerUserID != 0 && r.OwnerUserID == s.UserID
+
+	case KindNotification:
+		// Sending one: OwnerUserID is the recipient, who must be a student
+		// the teacher teaches or the parent of one
+		return action == ActionCreate && s.Role == models.RoleTeacher && p.teachesUser(s, r.OwnerUserID)
+
+	case KindAssignment, KindRubric:
+		if action == ActionRead || action == ActionList {
+			return true
+		}
+		return p.teachesCourse(s, r.CourseID)
+
+	case KindGradebook, KindReport:
+		return p.teachesCourse(s, r.CourseID)
+
+	case KindStudent, KindTranscript, KindPayment:
+		if action != ActionRead {
+			return false
+		}
+		return p.canReadStudentRecord(s, r, r.Kind != KindPayment)
+
+	case KindEnrollment:
+		switch action {
+		case ActionRead, ActionList:
+			return p.canReadStudentRecord(s, r, true)
+		}
+		// Students enroll themselves through registration, which applies its
+		// windows and credit limit
+		return false
+
+	case KindGrade, KindAttendance:
+		switch action {
+		case ActionRead, ActionList:
+			return p.canReadStudentRecord(s, r, true)
+		case ActionCreate, ActionUpdate, ActionDelete, ActionGrade:
+			return p.teachesStudentInCourse(s, r)
+		}
+		return false
+
+	case KindSubmission:
+		switch action {
+		case ActionRead, ActionList:
+			return p.canReadStudentRecord(s, r, true)
+		case ActionCreate, ActionUpdate:
+			return s.Role == models.RoleStudent && r.StudentID != 0 && r.StudentID == s.StudentID
+		case ActionGrade:
+			return p.teachesStudentInCourse(s, r)
+		}
+		return false
+	}
+
+	return false
+}
+
+// canReadStudentRecord applies the shared read rules for records that belong
+// to a student: the student, their parents and (if teachersMay) the teachers
+// who teach them. Reading a whole course's records needs the course's
+// teacher; reading every record of a kind is admin only.
+func (p *Policy) canReadStudentRecord(s Subject, r Resource, teachersMay bool) bool {
+	if r.StudentID == 0 {
+		return teachersMay && r.CourseID != 0 && p.teachesCourse(s, r.CourseID)
+	}
+
+	switch s.Role {
+	case models.RoleStudent:
+		return s.StudentID != 0 && s.StudentID == r.StudentID
+	case models.RoleParent:
+		for _, child := range s.Children {
+			if child == r.StudentID {
+				return true
+			}
+		}
+	case models.RoleTeacher:
+		return teachersMay && s.TeacherID != 0 && p.rel.TeachesStudent(s.TeacherID, r.StudentID, r.CourseID)
+	}
+	return false
+}
+
+func (p *Policy) teachesCourse(s Subject, courseID uint) bool {
+	return s.Role == models.RoleTeacher && s.TeacherID != 0 && courseID != 0 &&
+		p.rel.TeachesCourse(s.TeacherID, courseID)
+}
+
+// teachesStudentInCourse is the write rule for grades and attendance: the
+// teacher must teach this course and the student must be enrolled in it.
+func (p *Policy) teachesStudentInCourse(s Subject, r Resource) bool {
+	return p.teachesCourse(s, r.CourseID) && r.StudentID != 0 &&
+		p.rel.TeachesStudent(s.TeacherID, r.StudentID, r.CourseID)
+}
+
+// teachesUser reports whether a user is a student the teacher teaches, or
+// a parent of one
+func (p *Policy) teachesUser(s Subject, userID uint) bool {
+	if s.TeacherID == 0 || userID == 0 {
+		return false
+	}
+	students := p.rel.ChildrenOfUser(userID)
+	if id := p.rel.StudentIDForUser(userID); id != 0 {
+		students = append(students, id)
+	}
+	for _, studentID := range students {
+		if p.rel.TeachesStudent(s.TeacherID, studentID, 0) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,137 @@
+package authz
+
+import (
+	"school-management-system/internal/models"
+
+	"gorm.io/gorm"
+)
+
+type dbRelations struct {
+	db *gorm.DB
+}
+
+// NewDBRelations answers relationship questions from the database.
+func NewDBRelations(db *gorm.DB) Relations {
+	return &dbRelations{db: db}
+}
+
+func (r *dbRelations) StudentIDForUser(userID uint) uint {
+	var student models.Student
+	if err := r.db.Select("id").Where("user_id = ?", userID).First(&student).Error; err != nil {
+		return 0
+	}
+	return student.ID
+}
+
+func (r *dbRelations) TeacherIDForUser(userID uint) uint {
+	var teacher models.Teacher
+	if err := r.db.Select("id").Where("user_id = ?", userID).First(&teacher).Error; err != nil {
+		return 0
+	}
+	return teacher.ID
+}
+
+// ChildrenOfUser returns the students a parent is linked to as a guardian.
+func (r *dbRelations) ChildrenOfUser(userID uint) []uint {
+	var ids []uint
+	r.db.Model(&models.Guardian{}).Where("user_id = ?", userID).Pluck("student_id", &ids)
+	return ids
+}
+
+func (r *dbRelations) CourseOfSection(sectionID uint) uint {
+	var section models.CourseSection
+	if err := r.db.Select("course_id").First(&section, sectionID).Error; err != nil {
+		return 0
+	}
+	return section.CourseID
+}
+
+func (r *dbRelations) TeachesCourse(teacherID, courseID uint) bool {
+	var count int64
+	r.db.Model(&models.Course{}).Where("id = ? AND teacher_id = ?", courseID, teacherID).Count(&count)
+	if count > 0 {
+		return true
+	}
+	r.db.Model(&models.CourseSection{}).Where("course_id = ? AND teacher_id = ?", courseID, teacherID).Count(&count)
+	return count > 0
+}
+
+// taughtStatuses are the statuses of enrollments that put a student in a
+// teacher's class: seated, or finished and still being graded
+var taughtStatuses = []string{models.EnrollmentApproved, models.EnrollmentActive, models.EnrollmentCompleted}
+
+func (r *dbRelations) TeachesStudent(teacherID, studentID, courseID uint) bool {
+	q := r.db.Model(&models.Enrollment{}).
+		Joins("JOIN courses ON courses.id = enrollments.course_id").
+		Joins("LEFT JOIN course_sections ON course_sections.id = enrollments.section_id").
+		Where("enrollments.student_id = ? AND enrollments.status IN ?", studentID, taughtStatuses).
+		Where("(courses.teacher_id = ? OR course_sections.teacher_id = ?)", teacherID, teacherID)
+	if courseID != 0 {
+		q = q.Where("enrollments.course_id = ?", courseID)
+	}
+
+	var count int64
+	q.Count(&count)
+	return count > 0
+}
+
+func (r *dbRelations) Lookup(kind Kind, id uint) (Resource, bool) {
+	res := Resource{Kind: kind}
+	var err error
+
+	switch kind {
+	case KindUser:
+		res.OwnerUserID = id
+		return res, true
+	case KindStudent, KindTranscript:
+		res.StudentID = id
+		return res, true
+	case KindGrade:
+		var g models.Grade
+		err = r.db.Select("student_id", "course_id").First(&g, id).Error
+		res.StudentID, res.CourseID = g.StudentID, g.CourseID
+	case KindAttendance:
+		var a models.Attendance
+		err = r.db.Select("student_id", "course_id").First(&a, id).Error
+		res.StudentID, res.CourseID = a.StudentID, a.CourseID
+	case KindEnrollment:
+		var e models.Enrollment
+		err = r.db.Select("student_id", "course_id").First(&e, id).Error
+		res.StudentID, res.CourseID = e.StudentID, e.CourseID
+	case KindPayment:
+		var p models.Payment
+		err = r.db.Select("student_id").First(&p, id).Error
+		res.StudentID = p.StudentID
+	case KindAssignment:
+		var a models.Assignment
+		err = r.db.Select("course_id", "created_by").First(&a, id).Error
+		res.CourseID, res.OwnerUserID = a.CourseID, a.CreatedBy
+	case KindSubmission:
+		var s models.AssignmentSubmission
+		err = r.db.Preload("Assignment").First(&s, id).Error
+		res.StudentID, res.CourseID = s.StudentID, s.Assignment.CourseID
+	case KindRubric:
+		var rubric models.AssignmentRubric
+		if err = r.db.Select("assignment_id").First(&rubric, id).Error; err == nil {
+			res, ok := r.Lookup(KindAssignment, rubric.AssignmentID)
+			res.Kind = kind
+			return res, ok
+		}
+	case KindGradebook:
+		var c models.GradeCategory
+		err = r.db.Select("course_id").First(&c, id).Error
+		res.CourseID = c.CourseID
+	case KindAnnouncement:
+		var a models.Announcement
+		err = r.db.Select("created_by").First(&a, id).Error
+		res.OwnerUserID = a.CreatedBy
+	case KindBooking:
+		var b models.Booking
+		err = r.db.Select("booked_by").First(&b, id).Error
+		res.OwnerUserID = b.BookedBy
+	default:
+		return res, false
+	}
+
+	return res, err == nil
+}
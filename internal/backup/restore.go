@@ -0,0 +1,121 @@
+package backup
+
+import (
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+const restoreBatchSize = 200
+
+// ErrTargetNotEmpty is returned when restoring into a database that
+// already holds data.
+var ErrTargetNotEmpty = errors.New("target database is not empty")
+
+// Restore loads the snapshot at path into target in a single transaction.
+// The target must already have the schema (run migrations first) and no
+// rows in any of the snapshot's tables. After loading, the row count of
+// every table is checked against the snapshot; on any mismatch nothing is
+// kept.
+func Restore(path, checksum string, target *gorm.DB) (*Manifest, error) {
+	if checksum != "" {
+		sum, err := Checksum(path)
+		if err != nil {
+			return nil, err
+		}
+		if sum != checksum {
+			return nil, errors.New("backup checksum does not match")
+		}
+	}
+
+	src, err := openSource(path)
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+	manifest := src.Manifest()
+
+	err = target.Transaction(func(tx *gorm.DB) error {
+		for _, t := range manifest.Tables {
+			if !tx.Migrator().HasTable(t.Name) {
+				return fmt.Errorf("table %s does not exist in the target database, run migrations first", t.Name)
+			}
+			var count int64
+			if err := tx.Table(t.Name).Count(&count).Error; err != nil {
+				return err
+			}
+			if count > 0 {
+				return fmt.Errorf("%w: table %s has %d rows", ErrTargetNotEmpty, t.Name, count)
+			}
+		}
+
+		var (
+			table string
+			batch []map[string]interface{}
+		)
+		flush := func() error {
+			if len(batch) == 0 {
+				return nil
+			}
+			if err := tx.Table(table).Create(&batch).Error; err != nil {
+				return fmt.Errorf("restoring table %s: %v", table, err)
+			}
+			batch = batch[:0]
+			return nil
+		}
+
+		if err := src.Each(func(name string, row map[string]interface{}) error {
+			if name != table || len(batch) == restoreBatchSize {
+				if err := flush(); err != nil {
+					return err
+				}
+				table = name
+			}
+			batch = append(batch, row)
+			return nil
+		}); err != nil {
+			return err
+		}
+		if err := flush(); err != nil {
+			return err
+		}
+
+		for _, t := range manifest.Tables {
+			var count int64
+			if err := tx.Table(t.Name).Count(&count).Error; err != nil {
+				return err
+			}
+			if count != t.Rows {
+				return fmt.Errorf("restored %d rows into table %s, expected %d", count, t.Name, t.Rows)
+			}
+		}
+
+		if tx.Dialector.Name() == "postgres" {
+			return resetSequences(tx, manifest)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// resetSequences moves each serial ID sequence past the restored rows so
+// new inserts do not collide with them
+func resetSequences(tx *gorm.DB, manifest *Manifest) error {
+	for _, t := range manifest.Tables {
+		if t.Rows == 0 || !tx.Migrator().HasColumn(t.Name, "id") {
+			continue
+		}
+		err := tx.Exec(fmt.Sprintf(
+			`SELECT setval(pg_get_serial_sequence('%[1]s', 'id'), MAX(id)) FROM %[1]q HAVING pg_get_serial_sequence('%[1]s', 'id') IS NOT NULL`,
+			t.Name,
+		)).Error
+		if err != nil {
+			return fmt.Errorf("resetting sequence for %s: %v", t.Name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,540 @@
+// Package backup writes and restores compressed database snapshots. SQLite
+// databases are copied with VACUUM INTO; other databases are dumped table by
+// table as newline-delimited JSON. Both are gzip compressed and checksummed.
+package backup
+
+import (
+	"bufio"
+	"compress/gzip"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	glebarez "github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// Snapshot formats
+const (
+	FormatSQLite = "sqlite"
+	FormatJSON   = "json"
+)
+
+const manifestVersion = 1
+
+// sqliteHeader starts every SQLite database file
+const sqliteHeader = "SQLite format 3\x00"
+
+// Manifest describes a snapshot. Tables are listed in restore order,
+// referenced tables before the tables that reference them.
+type Manifest struct {
+	Format    string      `json:"format"`
+	Version   int         `json:"version"`
+	CreatedAt int64       `json:"created_at"`
+	Tables    []TableInfo `json:"tables"`
+}
+
+type TableInfo struct {
+	Name string `json:"name"`
+	Rows int64  `json:"rows"`
+}
+
+// Result describes a snapshot file that was written
+type Result struct {
+	Path     string
+	Size     int64
+	Checksum string
+	Manifest *Manifest
+}
+
+// rowLine is one row of a JSON dump
+type rowLine struct {
+	Table string                 `json:"table"`
+	Row   map[string]interface{} `json:"row"`
+}
+
+// FormatFor returns the snapshot format used for db
+func FormatFor(db *gorm.DB) string {
+	if db.Dialector.Name() == "sqlite" {
+		return FormatSQLite
+	}
+	return FormatJSON
+}
+
+// Create writes a compressed snapshot of db in the given format to
+// dir/name. The file appears only once it is complete.
+func Create(db *gorm.DB, format, dir, name string) (*Result, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, err
+	}
+	path := filepath.Join(dir, name)
+	part := path + ".part"
+	defer os.Remove(part)
+
+	var (
+		result *Result
+		err    error
+	)
+	switch format {
+	case FormatSQLite:
+		result, err = createSQLite(db, part)
+	case FormatJSON:
+		result, err = createJSON(db, part)
+	default:
+		err = fmt.Errorf("unknown backup format %q", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.Rename(part, path); err != nil {
+		return nil, err
+	}
+	result.Path = path
+	return result, nil
+}
+
+func createSQLite(db *gorm.DB, part string) (*Result, error) {
+	raw := part + ".db"
+	os.Remove(raw)
+	defer os.Remove(raw)
+
+	// VACUUM INTO writes a consistent, compacted copy without blocking writers
+	if err := db.Exec("VACUUM INTO ?", raw).Error; err != nil {
+		return nil, fmt.Errorf("vacuum into failed: %v", err)
+	}
+
+	copyDB, err := openSQLite(raw)
+	if err != nil {
+		return nil, err
+	}
+	manifest, err := readManifest(copyDB, FormatSQLite)
+	closeDB(copyDB)
+	if err != nil {
+		return nil, err
+	}
+
+	in, err := os.Open(raw)
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+
+	result, err := writeCompressed(part, func(w io.Writer) error {
+		_, err := io.Copy(w, in)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	result.Manifest = manifest
+	return result, nil
+}
+
+func createJSON(db *gorm.DB, part string) (*Result, error) {
+	opts := &sql.TxOptions{ReadOnly: true}
+	if db.Dialector.Name() == "postgres" {
+		opts.Isolation = sql.LevelRepeatableRead
+	} else {
+		opts = nil
+	}
+
+	var result *Result
+	err := db.Transaction(func(tx *gorm.DB) error {
+		manifest, err := readManifest(tx, FormatJSON)
+		if err != nil {
+			return err
+		}
+
+		result, err = writeCompressed(part, func(w io.Writer) error {
+			enc := json.NewEncoder(w)
+			if err := enc.Encode(manifest); err != nil {
+				return err
+			}
+			for _, table := range manifest.Tables {
+				if err := eachRow(tx, table.Name, func(row map[string]interface{}) error {
+					return enc.Encode(rowLine{Table: table.Name, Row: row})
+				}); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		result.Manifest = manifest
+		return nil
+	}, opts)
+	return result, err
+}
+
+// writeCompressed gzips what write produces into path, recording the size
+// and checksum of the compressed file.
+func writeCompressed(path string, write func(w io.Writer) error) (*Result, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o640)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	counter := &countingWriter{}
+	gz := gzip.NewWriter(io.MultiWriter(f, hash, counter))
+	if err := write(gz); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	if err := f.Sync(); err != nil {
+		return nil, err
+	}
+	return &Result{Size: counter.n, Checksum: hex.EncodeToString(hash.Sum(nil))}, nil
+}
+
+// Checksum returns the SHA-256 of a file, as stored for each backup
+func Checksum(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// Verify checks that the file at path has the expected checksum and can be
+// read to the end, returning its manifest.
+func Verify(path, checksum string) (*Manifest, error) {
+	sum, err := Checksum(path)
+	if err != nil {
+		return nil, err
+	}
+	if checksum != "" && sum != checksum {
+		return nil, errors.New("backup checksum does not match")
+	}
+
+	src, err := openSource(path)
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+
+	counts := map[string]int64{}
+	if err := src.Each(func(table string, _ map[string]interface{}) error {
+		counts[table]++
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	for _, t := range src.Manifest().Tables {
+		if counts[t.Name] != t.Rows {
+			return nil, fmt.Errorf("backup has %d rows for table %s, expected %d", counts[t.Name], t.Name, t.Rows)
+		}
+	}
+	return src.Manifest(), nil
+}
+
+// readManifest lists the tables of db in restore order with row counts
+func readManifest(db *gorm.DB, format string) (*Manifest, error) {
+	tables, err := orderedTables(db)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := &Manifest{Format: format, Version: manifestVersion, CreatedAt: time.Now().Unix()}
+	for _, name := range tables {
+		var count int64
+		if err := db.Table(name).Count(&count).Error; err != nil {
+			return nil, err
+		}
+		manifest.Tables = append(manifest.Tables, TableInfo{Name: name, Rows: count})
+	}
+	return manifest, nil
+}
+
+// orderedTables returns the user tables of db sorted so that every table
+// comes after the tables its foreign keys reference.
+func orderedTables(db *gorm.DB) ([]string, error) {
+	all, err := db.Migrator().GetTables()
+	if err != nil {
+		return nil, err
+	}
+
+	var tables []string
+	for _, t := range all {
+		if !strings.HasPrefix(t, "sqlite_") {
+			tables = append(tables, t)
+		}
+	}
+	sort.Strings(tables)
+
+	parents, err := foreignKeyParents(db, tables)
+	if err != nil {
+		return nil, err
+	}
+
+	ordered := make([]string, 0, len(tables))
+	placed := map[string]bool{}
+	for len(ordered) < len(tables) {
+		progress := false
+		for _, t := range tables {
+			if placed[t] {
+				continue
+			}
+			ready := true
+			for _, p := range parents[t] {
+				if p != t && !placed[p] {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				ordered = append(ordered, t)
+				placed[t] = true
+				progress = true
+			}
+		}
+		if !progress {
+			// Reference cycle: keep the remaining tables in name order
+			for _, t := range tables {
+				if !placed[t] {
+					ordered = append(ordered, t)
+					placed[t] = true
+				}
+			}
+		}
+	}
+	return ordered, nil
+}
+
+// foreignKeyParents maps each table to the tables it references
+func foreignKeyParents(db *gorm.DB, tables []string) (map[string][]string, error) {
+	known := map[string]bool{}
+	for _, t := range tables {
+		known[t] = true
+	}
+	parents := map[string][]string{}
+
+	if db.Dialector.Name() == "sqlite" {
+		for _, t := range tables {
+			var keys []map[string]interface{}
+			if err := db.Raw(fmt.Sprintf("PRAGMA foreign_key_list(%q)", t)).Scan(&keys).Error; err != nil {
+				return nil, err
+			}
+			for _, k := range keys {
+				if p, ok := k["table"].(string); ok && known[p] {
+					parents[t] = append(parents[t], p)
+				}
+			}
+		}
+		return parents, nil
+	}
+
+	var refs []struct {
+		Child  string
+		Parent string
+	}
+	err := db.Raw(`SELECT tc.table_name AS child, ccu.table_name AS parent
+		FROM information_schema.table_constraints tc
+		JOIN information_schema.constraint_column_usage ccu
+			ON tc.constraint_name = ccu.constraint_name AND tc.table_schema = ccu.table_schema
+		WHERE tc.constraint_type = 'FOREIGN KEY' AND tc.table_schema = current_schema()`).Scan(&refs).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range refs {
+		if known[r.Parent] {
+			parents[r.Child] = append(parents[r.Child], r.Parent)
+		}
+	}
+	return parents, nil
+}
+
+// eachRow streams every row of a table as column/value pairs
+func eachRow(db *gorm.DB, table string, fn func(row map[string]interface{}) error) error {
+	rows, err := db.Table(table).Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		row := map[string]interface{}{}
+		if err := db.ScanRows(rows, &row); err != nil {
+			return err
+		}
+		for k, v := range row {
+			if b, ok := v.([]byte); ok {
+				row[k] = string(b)
+			}
+		}
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func openSQLite(path string) (*gorm.DB, error) {
+	return gorm.Open(glebarez.Open(path), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+}
+
+func closeDB(db *gorm.DB) {
+	if sqlDB, err := db.DB(); err == nil {
+		sqlDB.Close()
+	}
+}
+
+type countingWriter struct{ n int64 }
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.n += int64(len(p))
+	return len(p), nil
+}
+
+// source reads the rows of a snapshot file in manifest order
+type source interface {
+	Manifest() *Manifest
+	Each(fn func(table string, row map[string]interface{}) error) error
+	Close()
+}
+
+// openSource opens a snapshot file of either format
+func openSource(path string) (source, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("not a backup file: %v", err)
+	}
+	r := bufio.NewReader(gz)
+
+	head, _ := r.Peek(len(sqliteHeader))
+	if string(head) == sqliteHeader {
+		defer f.Close()
+		return openSQLiteSource(r)
+	}
+	return openJSONSource(f, r)
+}
+
+type sqliteSource struct {
+	db       *gorm.DB
+	path     string
+	manifest *Manifest
+}
+
+func openSQLiteSource(r io.Reader) (source, error) {
+	tmp, err := os.CreateTemp("", "restore-*.db")
+	if err != nil {
+		return nil, err
+	}
+	_, err = io.Copy(tmp, r)
+	tmp.Close()
+	if err != nil {
+		os.Remove(tmp.Name())
+		return nil, err
+	}
+
+	db, err := openSQLite(tmp.Name())
+	if err != nil {
+		os.Remove(tmp.Name())
+		return nil, err
+	}
+	manifest, err := readManifest(db, FormatSQLite)
+	if err != nil {
+		closeDB(db)
+		os.Remove(tmp.Name())
+		return nil, err
+	}
+	return &sqliteSource{db: db, path: tmp.Name(), manifest: manifest}, nil
+}
+
+func (s *sqliteSource) Manifest() *Manifest { return s.manifest }
+
+func (s *sqliteSource) Each(fn func(table string, row map[string]interface{}) error) error {
+	for _, t := range s.manifest.Tables {
+		if err := eachRow(s.db, t.Name, func(row map[string]interface{}) error {
+			return fn(t.Name, row)
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *sqliteSource) Close() {
+	closeDB(s.db)
+	os.Remove(s.path)
+}
+
+type jsonSource struct {
+	file     *os.File
+	dec      *json.Decoder
+	manifest *Manifest
+}
+
+func openJSONSource(f *os.File, r io.Reader) (source, error) {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+
+	var manifest Manifest
+	if err := dec.Decode(&manifest); err != nil || manifest.Format != FormatJSON {
+		f.Close()
+		return nil, errors.New("not a backup file: missing manifest")
+	}
+	if manifest.Version > manifestVersion {
+		f.Close()
+		return nil, fmt.Errorf("backup format version %d is newer than supported", manifest.Version)
+	}
+	return &jsonSource{file: f, dec: dec, manifest: &manifest}, nil
+}
+
+func (s *jsonSource) Manifest() *Manifest { return s.manifest }
+
+func (s *jsonSource) Each(fn func(table string, row map[string]interface{}) error) error {
+	for {
+		var line rowLine
+		err := s.dec.Decode(&line)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("corrupt backup: %v", err)
+		}
+		for k, v := range line.Row {
+			if n, ok := v.(json.Number); ok {
+				if i, err := n.Int64(); err == nil {
+					line.Row[k] = i
+				} else if f, err := n.Float64(); err == nil {
+					line.Row[k] = f
+				}
+			}
+		}
+		if err := fn(line.Table, line.Row); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *jsonSource) Close() {
+	s.file.Close()
+}
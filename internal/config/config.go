@@ -23,8 +23,55 @@ type Config struct {
 	DBDriver   string
 	DBPath     string
 
-	JWTSecret string
-	JWTExpiry int
+	JWTSecret        string
+	JWTAccessExpiry  int // minutes
+	JWTRefreshExpiry int // hours
+
+	AppBaseURL               string
+	PasswordMinLength        int
+	PasswordHistorySize      int
+	PasswordBreachedList     string
+	PasswordResetExpiry      int // minutes
+	RequireEmailVerification bool
+	MFAIssuer                string
+
+	LockoutMaxFailures   int
+	LockoutIPMaxFailures int
+	LockoutDuration      int // minutes
+
+	AuditRetentionDays int
+
+	BackupDir            string
+	BackupSchedule       string // cron expression, empty disables scheduled backups
+	BackupRetentionCount int
+	BackupRetentionDays  int
+
+	SchedulerEnabled         bool
+	JobRunRetentionDays      int
+	AttendanceAlertThreshold float64
+
+	SMTPHost           string
+	SMTPPort           string
+	SMTPEmail          string
+	SMTPName           string
+	SMTPPass           string
+	MailTransport      string // "smtp" or "maildir"
+	MailDir            string
+	EmailMaxAttempts   int
+	EmailRetentionDays int
+
+	SMSProvider         string // "log" or "twilio"
+	TwilioAccountSID    string
+	TwilioAuthToken     string
+	SMSFrom             string
+	WebhookAllowPrivate bool
+
+	RealtimeBroker         string // "memory" or "database"
+	RealtimeHeartbeat      int    // seconds
+	RealtimeRetentionHours int
+
+	AttachmentDir     string
+	AttachmentMaxSize int64 // bytes
 }
 
 // LoadConfig reads configuration from environment variables with sensible defaults.
@@ -48,12 +95,156 @@ func LoadConfig() (*Config, error) {
 	cfg.DBPath = getEnv("DB_PATH", "school.db")
 
 	cfg.JWTSecret = getEnv("JWT_SECRET", "changeme")
-	jwtExpiryStr := getEnv("JWT_EXPIRY", "24")
-	jwtExpiry, err := strconv.Atoi(jwtExpiryStr)
+	accessExpiry, err := strconv.Atoi(getEnv("JWT_ACCESS_EXPIRY", "15"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid JWT_ACCESS_EXPIRY: %v", err)
+	}
+	cfg.JWTAccessExpiry = accessExpiry
+	refreshExpiry, err := strconv.Atoi(getEnv("JWT_REFRESH_EXPIRY", "168"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid JWT_REFRESH_EXPIRY: %v", err)
+	}
+	cfg.JWTRefreshExpiry = refreshExpiry
+
+	cfg.AppBaseURL = getEnv("APP_BASE_URL", "http://localhost:8080")
+	minLength, err := strconv.Atoi(getEnv("PASSWORD_MIN_LENGTH", "8"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid PASSWORD_MIN_LENGTH: %v", err)
+	}
+	cfg.PasswordMinLength = minLength
+	historySize, err := strconv.Atoi(getEnv("PASSWORD_HISTORY_SIZE", "5"))
 	if err != nil {
-		return nil, fmt.Errorf("invalid JWT_EXPIRY: %v", err)
+		return nil, fmt.Errorf("invalid PASSWORD_HISTORY_SIZE: %v", err)
+	}
+	cfg.PasswordHistorySize = historySize
+	cfg.PasswordBreachedList = getEnv("PASSWORD_BREACHED_LIST", "data/breached_passwords.txt")
+	resetExpiry, err := strconv.Atoi(getEnv("PASSWORD_RESET_EXPIRY", "30"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid PASSWORD_RESET_EXPIRY: %v", err)
+	}
+	cfg.PasswordResetExpiry = resetExpiry
+	requireVerification, err := strconv.ParseBool(getEnv("REQUIRE_EMAIL_VERIFICATION", "false"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid REQUIRE_EMAIL_VERIFICATION: %v", err)
+	}
+	cfg.RequireEmailVerification = requireVerification
+	cfg.MFAIssuer = getEnv("MFA_ISSUER", "School Management System")
+
+	maxFailures, err := strconv.Atoi(getEnv("LOCKOUT_MAX_FAILURES", "5"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid LOCKOUT_MAX_FAILURES: %v", err)
+	}
+	cfg.LockoutMaxFailures = maxFailures
+	ipMaxFailures, err := strconv.Atoi(getEnv("LOCKOUT_IP_MAX_FAILURES", "20"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid LOCKOUT_IP_MAX_FAILURES: %v", err)
+	}
+	cfg.LockoutIPMaxFailures = ipMaxFailures
+	lockDuration, err := strconv.Atoi(getEnv("LOCKOUT_DURATION", "15"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid LOCKOUT_DURATION: %v", err)
+	}
+	cfg.LockoutDuration = lockDuration
+
+	retentionDays, err := strconv.Atoi(getEnv("AUDIT_RETENTION_DAYS", "365"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid AUDIT_RETENTION_DAYS: %v", err)
+	}
+	cfg.AuditRetentionDays = retentionDays
+
+	cfg.BackupDir = getEnv("BACKUP_DIR", "backups")
+	cfg.BackupSchedule = getEnv("BACKUP_SCHEDULE", "0 2 * * *")
+	backupCount, err := strconv.Atoi(getEnv("BACKUP_RETENTION_COUNT", "7"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid BACKUP_RETENTION_COUNT: %v", err)
+	}
+	cfg.BackupRetentionCount = backupCount
+	backupDays, err := strconv.Atoi(getEnv("BACKUP_RETENTION_DAYS", "30"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid BACKUP_RETENTION_DAYS: %v", err)
+	}
+	cfg.BackupRetentionDays = backupDays
+
+	schedulerEnabled, err := strconv.ParseBool(getEnv("SCHEDULER_ENABLED", "true"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid SCHEDULER_ENABLED: %v", err)
+	}
+	cfg.SchedulerEnabled = schedulerEnabled
+	runRetention, err := strconv.Atoi(getEnv("JOB_RUN_RETENTION_DAYS", "90"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid JOB_RUN_RETENTION_DAYS: %v", err)
+	}
+	cfg.JobRunRetentionDays = runRetention
+	alertThreshold, err := strconv.ParseFloat(getEnv("ATTENDANCE_ALERT_THRESHOLD", "80"), 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ATTENDANCE_ALERT_THRESHOLD: %v", err)
+	}
+	cfg.AttendanceAlertThreshold = alertThreshold
+
+	cfg.SMTPHost = getEnv("SMTP_HOST", "")
+	cfg.SMTPPort = getEnv("SMTP_PORT", "587")
+	cfg.SMTPEmail = getEnv("SMTP_EMAIL", "no-reply@school.local")
+	cfg.SMTPName = getEnv("SMTP_NAME", "School Management System")
+	cfg.SMTPPass = getEnv("SMTP_PASS", "")
+	// Without an SMTP server, mail is written to a local maildir
+	defaultTransport := "maildir"
+	if cfg.SMTPHost != "" {
+		defaultTransport = "smtp"
+	}
+	cfg.MailTransport = getEnv("MAIL_TRANSPORT", defaultTransport)
+	if cfg.MailTransport != "smtp" && cfg.MailTransport != "maildir" {
+		return nil, fmt.Errorf("invalid MAIL_TRANSPORT: %q (want smtp or maildir)", cfg.MailTransport)
+	}
+	cfg.MailDir = getEnv("MAIL_DIR", "maildir")
+	maxAttempts, err := strconv.Atoi(getEnv("EMAIL_MAX_ATTEMPTS", "8"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid EMAIL_MAX_ATTEMPTS: %v", err)
+	}
+	cfg.EmailMaxAttempts = maxAttempts
+	emailRetention, err := strconv.Atoi(getEnv("EMAIL_RETENTION_DAYS", "30"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid EMAIL_RETENTION_DAYS: %v", err)
+	}
+	cfg.EmailRetentionDays = emailRetention
+
+	cfg.SMSProvider = getEnv("SMS_PROVIDER", "log")
+	if cfg.SMSProvider != "log" && cfg.SMSProvider != "twilio" {
+		return nil, fmt.Errorf("invalid SMS_PROVIDER: %q (want log or twilio)", cfg.SMSProvider)
+	}
+	cfg.TwilioAccountSID = getEnv("TWILIO_ACCOUNT_SID", "")
+	cfg.TwilioAuthToken = getEnv("TWILIO_AUTH_TOKEN", "")
+	cfg.SMSFrom = getEnv("SMS_FROM", "")
+	if cfg.SMSProvider == "twilio" && (cfg.TwilioAccountSID == "" || cfg.TwilioAuthToken == "" || cfg.SMSFrom == "") {
+		return nil, fmt.Errorf("SMS_PROVIDER=twilio requires TWILIO_ACCOUNT_SID, TWILIO_AUTH_TOKEN and SMS_FROM")
+	}
+	allowPrivate, err := strconv.ParseBool(getEnv("WEBHOOK_ALLOW_PRIVATE", "false"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid WEBHOOK_ALLOW_PRIVATE: %v", err)
+	}
+	cfg.WebhookAllowPrivate = allowPrivate
+
+	// The database broker lets several instances share connected clients
+	cfg.RealtimeBroker = getEnv("REALTIME_BROKER", "memory")
+	if cfg.RealtimeBroker != "memory" && cfg.RealtimeBroker != "database" {
+		return nil, fmt.Errorf("invalid REALTIME_BROKER: %q (want memory or database)", cfg.RealtimeBroker)
+	}
+	heartbeat, err := strconv.Atoi(getEnv("REALTIME_HEARTBEAT", "25"))
+	if err != nil || heartbeat <= 0 {
+		return nil, fmt.Errorf("invalid REALTIME_HEARTBEAT: %q", getEnv("REALTIME_HEARTBEAT", "25"))
+	}
+	cfg.RealtimeHeartbeat = heartbeat
+	realtimeRetention, err := strconv.Atoi(getEnv("REALTIME_RETENTION_HOURS", "24"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid REALTIME_RETENTION_HOURS: %v", err)
+	}
+	cfg.RealtimeRetentionHours = realtimeRetention
+
+	cfg.AttachmentDir = getEnv("ATTACHMENT_DIR", "attachments")
+	attachmentMB, err := strconv.Atoi(getEnv("ATTACHMENT_MAX_MB", "10"))
+	if err != nil || attachmentMB <= 0 {
+		return nil, fmt.Errorf("invalid ATTACHMENT_MAX_MB: %q", getEnv("ATTACHMENT_MAX_MB", "10"))
 	}
-	cfg.JWTExpiry = jwtExpiry
+	cfg.AttachmentMaxSize = int64(attachmentMB) << 20
 
 	return cfg, nil
 }
@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"school-management-system/internal/models"
+	"school-management-system/internal/service"
+	"school-management-system/pkg/errors"
+	"school-management-system/pkg/response"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AcademicTermHandler struct {
+	service service.AcademicTermService
+}
+
+func NewAcademicTermHandler(svc service.AcademicTermService) *AcademicTermHandler {
+	return &AcademicTermHandler{service: svc}
+}
+
+type AcademicTermRequest struct {
+	Name               string `json:"name"`
+	Code               string `json:"code"`
+	AcademicYear       string `json:"academic_year"`
+	StartDate          string `json:"start_date"`          // YYYY-MM-DD
+	EndDate            string `json:"end_date"`            // YYYY-MM-DD
+	RegistrationStart  string `json:"registration_start"`  // YYYY-MM-DD
+	RegistrationEnd    string `json:"registration_end"`    // YYYY-MM-DD
+	GradingDeadline    string `json:"grading_deadline"`    // YYYY-MM-DD
+	DropDeadline       string `json:"drop_deadline"`       // YYYY-MM-DD
+	WithdrawalDeadline string `json:"withdrawal_deadline"` // YYYY-MM-DD
+	Status             string `json:"status"`
+}
+
+// apply copies the non-empty request fields onto term.
+func (req *AcademicTermRequest) apply(term *models.AcademicTerm) error {
+	if req.Name != "" {
+		term.Name = req.Name
+	}
+	if req.Code != "" {
+		term.Code = req.Code
+	}
+	if req.AcademicYear != "" {
+		term.AcademicYear = req.AcademicYear
+	}
+	if req.Status != "" {
+		term.Status = models.TermStatus(req.Status)
+	}
+
+	dates := []struct {
+		field string
+		value string
+		dest  *time.Time
+	}{
+		{"start_date", req.StartDate, &term.StartDate},
+		{"end_date", req.EndDate, &term.EndDate},
+		{"registration_start", req.RegistrationStart, &term.RegistrationStart},
+		{"registration_end", req.RegistrationEnd, &term.RegistrationEnd},
+		{"grading_deadline", req.GradingDeadline, &term.GradingDeadline},
+		{"drop_deadline", req.DropDeadline, &term.DropDeadline},
+		{"withdrawal_deadline", req.WithdrawalDeadline, &term.WithdrawalDeadline},
+	}
+	for _, d := range dates {
+		if d.value == "" {
+			continue
+		}
+		parsed, err := time.Parse("2006-01-02", d.value)
+		if err != nil {
+			return errors.InvalidFieldFormat(d.field, "YYYY-MM-DD")
+		}
+		*d.dest = parsed
+	}
+	return nil
+}
+
+func (h *AcademicTermHandler) Create(c *gin.Context) {
+	var req AcademicTermRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+
+	term := &models.AcademicTerm{}
+	if err := req.apply(term); err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	if err := h.service.CreateTerm(term); err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+	response.Created(c, "Academic term created", term)
+}
+
+func (h *AcademicTermHandler) GetAll(c *gin.Context) {
+	page := 1
+	if p := c.Query("page"); p != "" {
+		if parsed, err := strconv.Atoi(p); err == nil {
+			page = parsed
+		}
+	}
+	limit := 20
+
+	terms, total, err := h.service.GetAllTerms(page, limit)
+	if err != nil {
+		response.Error(c, errors.InternalError("Failed to fetch academic terms"))
+		return
+	}
+	response.Paginated(c, "Academic terms fetched", terms, page, limit, total)
+}
+
+func (h *AcademicTermHandler) GetByID(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	term, err := h.service.GetTermByID(uint(id))
+	if err != nil {
+		response.Error(c, errors.NotFound("Academic term not found"))
+		return
+	}
+	response.Success(c, "Academic term fetched", term)
+}
+
+func (h *AcademicTermHandler) GetCurrent(c *gin.Context) {
+	term, err := h.service.GetCurrentTerm()
+	if err != nil {
+		response.Error(c, errors.NotFound("No current academic term"))
+		return
+	}
+	response.Success(c, "Current academic term fetched", term)
+}
+
+func (h *AcademicTermHandler) Update(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	var req AcademicTermRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+
+	term, err := h.service.GetTermByID(uint(id))
+	if err != nil {
+		response.Error(c, errors.NotFound("Academic term not found"))
+		return
+	}
+	if err := req.apply(term); err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	if err := h.service.UpdateTerm(term); err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+	response.Success(c, "Academic term updated", term)
+}
+
+func (h *AcademicTermHandler) UpdateStatus(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	var req struct {
+		Status string `json:"status" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+
+	term, err := h.service.UpdateTermStatus(uint(id), models.TermStatus(req.Status))
+	if err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+	response.Success(c, "Academic term status updated", term)
+}
+
+func (h *AcademicTermHandler) Delete(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err := h.service.DeleteTerm(uint(id)); err != nil {
+		response.Error(c, errors.InternalError("Failed to delete academic term"))
+		return
+	}
+	response.NoContent(c)
+}
@@ -1,8 +1,10 @@
 package handlers
 
 import (
+	stderrors "errors"
 	"school-management-system/internal/models"
 	"school-management-system/internal/service"
+	"school-management-system/pkg/errors"
 	"school-management-system/pkg/response"
 	"strconv"
 
@@ -17,14 +19,66 @@ func NewAnnouncementHandler(svc service.AnnouncementService) *AnnouncementHandle
 	return &AnnouncementHandler{service: svc}
 }
 
+// announcementError responds to an announcement error, with fallback as the
+// message for unexpected ones
+func announcementError(c *gin.Context, err error, fallback string) {
+	switch {
+	case stderrors.Is(err, service.ErrInvalidAnnouncement):
+		response.BadRequest(c, err.Error())
+	case stderrors.Is(err, service.ErrAnnouncementForbidden):
+		response.Error(c, errors.Forbidden(err.Error()))
+	case stderrors.Is(err, service.ErrAnnouncementNotFound):
+		response.Error(c, errors.NotFound("Announcement not found"))
+	default:
+		response.Error(c, errors.InternalError(fallback))
+	}
+}
+
+// announcementTargets is the audience of an announcement in a request. An
+// announcement reaches everyone matching any of them.
+type announcementTargets struct {
+	Roles       []string `json:"roles"`
+	CourseIDs   []uint   `json:"course_ids"`
+	SectionIDs  []uint   `json:"section_ids"`
+	GradeLevels []string `json:"grade_levels"`
+	UserIDs     []uint   `json:"user_ids"`
+}
+
+func (t *announcementTargets) models() []models.AnnouncementTarget {
+	var targets []models.AnnouncementTarget
+	for _, role := range t.Roles {
+		targets = append(targets, models.AnnouncementTarget{Kind: models.AnnouncementTargetRole, Value: role})
+	}
+	for _, id := range t.CourseIDs {
+		targets = append(targets, models.AnnouncementTarget{Kind: models.AnnouncementTargetCourse, Value: strconv.FormatUint(uint64(id), 10)})
+	}
+	for _, id := range t.SectionIDs {
+		targets = append(targets, models.AnnouncementTarget{Kind: models.AnnouncementTargetSection, Value: strconv.FormatUint(uint64(id), 10)})
+	}
+	for _, level := range t.GradeLevels {
+		targets = append(targets, models.AnnouncementTarget{Kind: models.AnnouncementTargetGradeLevel, Value: level})
+	}
+	for _, id := range t.UserIDs {
+		targets = append(targets, models.AnnouncementTarget{Kind: models.AnnouncementTargetUser, Value: strconv.FormatUint(uint64(id), 10)})
+	}
+	return targets
+}
+
+// Create posts an announcement to everyone (admins only), to a legacy role
+// audience, or to targets. It is published at publish_at, or straight away.
 func (h *AnnouncementHandler) Create(c *gin.Context) {
 	userID := c.GetUint("user_id")
 	var req struct {
-		Title     string `json:"title" binding:"required"`
-		Content   string `json:"content" binding:"required"`
-		Audience  string `json:"audience" binding:"required"`
-		Priority  string `json:"priority"`
-		ExpiresAt int64  `json:"expires_at"`
+		Title                   string               `json:"title" binding:"required"`
+		Content                 string               `json:"content" binding:"required"`
+		Audience                string               `json:"audience"`
+		Targets                 *announcementTargets `json:"targets"`
+		IncludeGuardians        bool                 `json:"include_guardians"`
+		RequiresAcknowledgement bool                 `json:"requires_acknowledgement"`
+		SendEmail               bool                 `json:"send_email"`
+		Priority                string               `json:"priority"`
+		PublishAt               int64                `json:"publish_at"`
+		ExpiresAt               int64                `json:"expires_at"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
 		response.BadRequest(c, "Invalid input: "+err.Error())
@@ -32,83 +86,126 @@ func (h *AnnouncementHandler) Create(c *gin.Context) {
 	}
 
 	announcement := &models.Announcement{
-		Title:     req.Title,
-		Content:   req.Content,
-		CreatedBy: userID,
-		Audience:  req.Audience,
-		Priority:  req.Priority,
-		IsActive:  true,
-		ExpiresAt: req.ExpiresAt,
+		Title:                   req.Title,
+		Content:                 req.Content,
+		CreatedBy:               userID,
+		Audience:                req.Audience,
+		Priority:                req.Priority,
+		IsActive:                true,
+		ExpiresAt:               req.ExpiresAt,
+		PublishAt:               req.PublishAt,
+		IncludeGuardians:        req.IncludeGuardians,
+		RequiresAcknowledgement: req.RequiresAcknowledgement,
+		SendEmail:               req.SendEmail,
+	}
+	if req.Targets != nil {
+		announcement.Targets = req.Targets.models()
 	}
 
-	if err := h.service.Create(announcement); err != nil {
-		response.Error(c, err)
+	if err := h.service.Create(announcement, userID); err != nil {
+		announcementError(c, err, "Failed to create announcement")
 		return
 	}
 	response.Created(c, "Announcement created", announcement)
 }
 
+// GetAll lists every announcement for admins, and the user's own for
+// anyone else
 func (h *AnnouncementHandler) GetAll(c *gin.Context) {
-	page := 1
-	if p := c.Query("page"); p != "" {
-		if parsed, err := strconv.Atoi(p); err == nil {
-			page = parsed
-		}
+	page, limit := messagePage(c)
+	var createdBy uint
+	if role, _ := c.Get("user_role"); role != string(models.RoleAdmin) {
+		createdBy = c.GetUint("user_id")
 	}
-	limit := 20
 
-	announcements, total, err := h.service.GetAll(page, limit)
+	announcements, total, err := h.service.GetAll(createdBy, page, limit)
 	if err != nil {
-		response.Error(c, err)
+		response.Error(c, errors.InternalError("Failed to fetch announcements"))
 		return
 	}
 
-	response.Paginated(c, "Announcements fetched", announcements, page, limit, int64(total))
+	response.Paginated(c, "Announcements fetched", announcements, page, limit, total)
 }
 
+// GetActive lists the live announcements delivered to the user with their
+// read and acknowledgement times. ?unread=true leaves out those read.
 func (h *AnnouncementHandler) GetActive(c *gin.Context) {
-	page := 1
-	if p := c.Query("page"); p != "" {
-		if parsed, err := strconv.Atoi(p); err == nil {
-			page = parsed
-		}
-	}
-	limit := 20
+	page, limit := messagePage(c)
+	unreadOnly, _ := strconv.ParseBool(c.Query("unread"))
 
-	announcements, total, err := h.service.GetActive(page, limit)
+	announcements, total, err := h.service.GetActive(c.GetUint("user_id"), unreadOnly, page, limit)
 	if err != nil {
-		response.Error(c, err)
+		response.Error(c, errors.InternalError("Failed to fetch announcements"))
 		return
 	}
 
-	response.Paginated(c, "Active announcements fetched", announcements, page, limit, int64(total))
+	response.Paginated(c, "Active announcements fetched", announcements, page, limit, total)
 }
 
+// Update changes the given fields of an announcement. Targets, when given,
+// replace the audience.
 func (h *AnnouncementHandler) Update(c *gin.Context) {
 	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
 	var req struct {
-		Title     string `json:"title"`
-		Content   string `json:"content"`
-		Audience  string `json:"audience"`
-		Priority  string `json:"priority"`
-		IsActive  bool   `json:"is_active"`
-		ExpiresAt int64  `json:"expires_at"`
+		Title                   *string              `json:"title"`
+		Content                 *string              `json:"content"`
+		Audience                *string              `json:"audience"`
+		Targets                 *announcementTargets `json:"targets"`
+		IncludeGuardians        *bool                `json:"include_guardians"`
+		RequiresAcknowledgement *bool                `json:"requires_acknowledgement"`
+		SendEmail               *bool                `json:"send_email"`
+		Priority                *string              `json:"priority"`
+		IsActive                *bool                `json:"is_active"`
+		PublishAt               *int64               `json:"publish_at"`
+		ExpiresAt               *int64               `json:"expires_at"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
 		response.BadRequest(c, "Invalid input: "+err.Error())
 		return
 	}
 
-	ann, _ := h.service.GetByID(uint(id))
-	ann.Title = req.Title
-	ann.Content = req.Content
-	ann.Audience = req.Audience
-	ann.Priority = req.Priority
-	ann.IsActive = req.IsActive
-	ann.ExpiresAt = req.ExpiresAt
+	ann, err := h.service.GetByID(uint(id))
+	if err != nil {
+		announcementError(c, err, "Failed to fetch announcement")
+		return
+	}
+	if req.Title != nil {
+		ann.Title = *req.Title
+	}
+	if req.Content != nil {
+		ann.Content = *req.Content
+	}
+	if req.Audience != nil {
+		ann.Audience = *req.Audience
+		ann.Targets = nil
+	}
+	if req.Targets != nil {
+		ann.Targets = req.Targets.models()
+	}
+	if req.IncludeGuardians != nil {
+		ann.IncludeGuardians = *req.IncludeGuardians
+	}
+	if req.RequiresAcknowledgement != nil {
+		ann.RequiresAcknowledgement = *req.RequiresAcknowledgement
+	}
+	if req.SendEmail != nil {
+		ann.SendEmail = *req.SendEmail
+	}
+	if req.Priority != nil {
+		ann.Priority = *req.Priority
+	}
+	if req.IsActive != nil {
+		ann.IsActive = *req.IsActive
+	}
+	if req.PublishAt != nil && ann.PublishedAt == 0 {
+		ann.PublishAt = *req.PublishAt
+	}
+	if req.ExpiresAt != nil {
+		ann.ExpiresAt = *req.ExpiresAt
+	}
 
-	if err := h.service.Update(ann); err != nil {
-		response.Error(c, err)
+	if err := h.service.Update(ann, c.GetUint("user_id")); err != nil {
+		announcementError(c, err, "Failed to update announcement")
 		return
 	}
 	response.Success(c, "Announcement updated", ann)
@@ -117,8 +214,55 @@ func (h *AnnouncementHandler) Update(c *gin.Context) {
 func (h *AnnouncementHandler) Delete(c *gin.Context) {
 	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err := h.service.Delete(uint(id)); err != nil {
-		response.Error(c, err)
+		response.Error(c, errors.InternalError("Failed to delete announcement"))
+		return
+	}
+	response.NoContent(c)
+}
+
+// MarkRead records that the user has read an announcement
+func (h *AnnouncementHandler) MarkRead(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err := h.service.MarkRead(uint(id), c.GetUint("user_id")); err != nil {
+		announcementError(c, err, "Failed to mark announcement read")
+		return
+	}
+	response.NoContent(c)
+}
+
+// Acknowledge records the user's acknowledgement of an announcement that
+// requires one
+func (h *AnnouncementHandler) Acknowledge(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err := h.service.Acknowledge(uint(id), c.GetUint("user_id")); err != nil {
+		announcementError(c, err, "Failed to acknowledge announcement")
 		return
 	}
 	response.NoContent(c)
 }
+
+// GetReceipts counts an announcement's recipients and how many have read
+// and acknowledged it
+func (h *AnnouncementHandler) GetReceipts(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	counts, err := h.service.GetReceiptCounts(uint(id))
+	if err != nil {
+		announcementError(c, err, "Failed to count receipts")
+		return
+	}
+	response.Success(c, "Receipts fetched", counts)
+}
+
+// GetRecipients lists an announcement's recipients with their receipts.
+// ?status=unacknowledged finds who has yet to return a permission slip.
+func (h *AnnouncementHandler) GetRecipients(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	page, limit := messagePage(c)
+
+	recipients, total, err := h.service.GetRecipients(uint(id), c.Query("status"), page, limit)
+	if err != nil {
+		announcementError(c, err, "Failed to fetch recipients")
+		return
+	}
+	response.Paginated(c, "Recipients fetched", recipients, page, limit, total)
+}
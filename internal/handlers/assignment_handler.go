@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"net/http"
+	"school-management-system/internal/authz"
 	"school-management-system/internal/models"
 	"school-management-system/internal/service"
 	"strconv"
@@ -26,6 +27,7 @@ func NewAssignmentHandler(assignmentService service.AssignmentService, submissio
 
 type CreateAssignmentRequest struct {
 	CourseID    uint      `json:"course_id" binding:"required"`
+	CategoryID  *uint     `json:"category_id"`
 	Title       string    `json:"title" binding:"required"`
 	Description string    `json:"description"`
 	DueDate     time.Time `json:"due_date" binding:"required"`
@@ -51,19 +53,24 @@ func (h *AssignmentHandler) CreateAssignment(c *gin.Context) {
 	}
 
 	// Get teacher ID from context (set by auth middleware)
-	teacherID, exists := c.Get("user_id")
-	if !exists {
+	teacherID := c.GetUint("user_id")
+	if teacherID == 0 {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
 		return
 	}
 
+	if !authz.Authorize(c, authz.ActionCreate, authz.Resource{Kind: authz.KindAssignment, CourseID: req.CourseID}) {
+		return
+	}
+
 	assignment := &models.Assignment{
 		CourseID:    req.CourseID,
+		CategoryID:  req.CategoryID,
 		Title:       req.Title,
 		Description: req.Description,
 		DueDate:     req.DueDate,
 		MaxScore:    req.MaxScore,
-		CreatedBy:   teacherID.(uint),
+		CreatedBy:   teacherID,
 	}
 
 	err := h.assignmentService.CreateAssignment(assignment)
@@ -114,13 +121,13 @@ func (h *AssignmentHandler) GetAssignmentsByCourse(c *gin.Context) {
 }
 
 func (h *AssignmentHandler) GetAssignmentsByTeacher(c *gin.Context) {
-	teacherID, exists := c.Get("user_id")
-	if !exists {
+	teacherID := c.GetUint("user_id")
+	if teacherID == 0 {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
 		return
 	}
 
-	assignments, err := h.assignmentService.GetAssignmentsByTeacher(teacherID.(uint))
+	assignments, err := h.assignmentService.GetAssignmentsByTeacher(teacherID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch assignments"})
 		return
@@ -151,20 +158,20 @@ func (h *AssignmentHandler) UpdateAssignment(c *gin.Context) {
 		return
 	}
 
-	// Check if user is the creator
-	userID, exists := c.Get("user_id")
-	if !exists || assignment.CreatedBy != userID.(uint) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "You can only update your own assignments"})
+	// Moving an assignment needs the same access to the new course
+	if req.CourseID != assignment.CourseID &&
+		!authz.Authorize(c, authz.ActionCreate, authz.Resource{Kind: authz.KindAssignment, CourseID: req.CourseID}) {
 		return
 	}
 
 	assignment.CourseID = req.CourseID
+	assignment.CategoryID = req.CategoryID
 	assignment.Title = req.Title
 	assignment.Description = req.Description
 	assignment.DueDate = req.DueDate
 	assignment.MaxScore = req.MaxScore
 
-	err = h.assignmentService.UpdateAssignment(assignment)
+	err = h.assignmentService.UpdateAssignment(c.Request.Context(), assignment)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
@@ -183,20 +190,12 @@ func (h *AssignmentHandler) DeleteAssignment(c *gin.Context) {
 		return
 	}
 
-	assignment, err := h.assignmentService.GetAssignmentByID(uint(id))
-	if err != nil {
+	if _, err := h.assignmentService.GetAssignmentByID(uint(id)); err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Check if user is the creator
-	userID, exists := c.Get("user_id")
-	if !exists || assignment.CreatedBy != userID.(uint) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "You can only delete your own assignments"})
-		return
-	}
-
-	err = h.assignmentService.DeleteAssignment(uint(id))
+	err = h.assignmentService.DeleteAssignment(c.Request.Context(), uint(id))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -213,15 +212,14 @@ func (h *AssignmentHandler) SubmitAssignment(c *gin.Context) {
 		return
 	}
 
-	studentID, exists := c.Get("user_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+	studentID := authz.CurrentSubject(c).StudentID
+	if !authz.Authorize(c, authz.ActionCreate, authz.Resource{Kind: authz.KindSubmission, StudentID: studentID}) {
 		return
 	}
 
 	submission := &models.AssignmentSubmission{
 		AssignmentID: req.AssignmentID,
-		StudentID:    studentID.(uint),
+		StudentID:    studentID,
 		FileURL:      req.FileURL,
 		Status:       "submitted",
 	}
@@ -252,8 +250,7 @@ func (h *AssignmentHandler) GetSubmissionsByAssignment(c *gin.Context) {
 		return
 	}
 
-	userID, exists := c.Get("user_id")
-	if !exists || assignment.CreatedBy != userID.(uint) {
+	if assignment.CreatedBy != c.GetUint("user_id") {
 		c.JSON(http.StatusForbidden, gin.H{"error": "You can only view submissions for your own assignments"})
 		return
 	}
@@ -271,13 +268,7 @@ func (h *AssignmentHandler) GetSubmissionsByAssignment(c *gin.Context) {
 }
 
 func (h *AssignmentHandler) GetSubmissionsByStudent(c *gin.Context) {
-	studentID, exists := c.Get("user_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
-		return
-	}
-
-	submissions, err := h.submissionService.GetSubmissionsByStudent(studentID.(uint))
+	submissions, err := h.submissionService.GetSubmissionsByStudent(authz.CurrentSubject(c).StudentID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch submissions"})
 		return
@@ -315,13 +306,12 @@ func (h *AssignmentHandler) GradeSubmission(c *gin.Context) {
 		return
 	}
 
-	userID, exists := c.Get("user_id")
-	if !exists || assignment.CreatedBy != userID.(uint) {
+	if assignment.CreatedBy != c.GetUint("user_id") {
 		c.JSON(http.StatusForbidden, gin.H{"error": "You can only grade submissions for your own assignments"})
 		return
 	}
 
-	err = h.submissionService.GradeSubmission(uint(submissionID), req.Score, req.Feedback)
+	err = h.submissionService.GradeSubmission(c.Request.Context(), uint(submissionID), req.Score, req.Feedback)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"net/http"
+	"school-management-system/internal/authz"
 	"school-management-system/internal/models"
 	"school-management-system/internal/service"
 	"strconv"
@@ -24,7 +25,8 @@ func NewAttendanceHandler(attendanceService service.AttendanceService, studentSe
 
 type RecordAttendanceRequest struct {
 	StudentID uint   `json:"student_id" binding:"required"`
-	CourseID  uint   `json:"course_id" binding:"required"`
+	CourseID  uint   `json:"course_id"`
+	SectionID *uint  `json:"section_id"`
 	Status    string `json:"status" binding:"required,oneof=present absent late excused"`
 	Remarks   string `json:"remarks"`
 	Date      string `json:"date"`
@@ -37,9 +39,14 @@ func (h *AttendanceHandler) RecordAttendance(c *gin.Context) {
 		return
 	}
 
+	if !authz.Authorize(c, authz.ActionCreate, authz.StudentRecord(authz.KindAttendance, req.StudentID, req.CourseID, req.SectionID)) {
+		return
+	}
+
 	attendance := &models.Attendance{
 		StudentID: req.StudentID,
 		CourseID:  req.CourseID,
+		SectionID: req.SectionID,
 		Status:    req.Status,
 		Remarks:   req.Remarks,
 		Date:      time.Now(),
@@ -51,7 +58,7 @@ func (h *AttendanceHandler) RecordAttendance(c *gin.Context) {
 		}
 	}
 
-	err := h.attendanceService.RecordAttendance(attendance)
+	err := h.attendanceService.RecordAttendance(c.Request.Context(), attendance)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
@@ -188,7 +195,7 @@ func (h *AttendanceHandler) UpdateAttendance(c *gin.Context) {
 		Remarks: req.Remarks,
 	}
 
-	err = h.attendanceService.UpdateAttendance(attendance)
+	err = h.attendanceService.UpdateAttendance(c.Request.Context(), attendance)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
@@ -204,7 +211,7 @@ func (h *AttendanceHandler) DeleteAttendance(c *gin.Context) {
 		return
 	}
 
-	err = h.attendanceService.DeleteAttendance(uint(id))
+	err = h.attendanceService.DeleteAttendance(c.Request.Context(), uint(id))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
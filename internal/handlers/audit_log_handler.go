@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"school-management-system/internal/repository"
+	"school-management-system/internal/service"
+	"school-management-system/pkg/errors"
+	"school-management-system/pkg/response"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AuditLogHandler struct {
+	service service.AuditLogService
+}
+
+func NewAuditLogHandler(s service.AuditLogService) *AuditLogHandler {
+	return &AuditLogHandler{service: s}
+}
+
+// List searches the audit log. Query parameters: user_id, entity,
+// entity_id, action, from and to (YYYY-MM-DD, inclusive), page and limit.
+func (h *AuditLogHandler) List(c *gin.Context) {
+	var filter repository.AuditLogFilter
+
+	if v := c.Query("user_id"); v != "" {
+		id, err := strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			response.BadRequest(c, "Invalid user_id")
+			return
+		}
+		filter.UserID = uint(id)
+	}
+	if v := c.Query("entity_id"); v != "" {
+		id, err := strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			response.BadRequest(c, "Invalid entity_id")
+			return
+		}
+		filter.EntityID = uint(id)
+	}
+	filter.Entity = c.Query("entity")
+	filter.Action = c.Query("action")
+
+	if v := c.Query("from"); v != "" {
+		from, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			response.BadRequest(c, "Invalid from date, expected YYYY-MM-DD")
+			return
+		}
+		filter.From = from.Unix()
+	}
+	if v := c.Query("to"); v != "" {
+		to, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			response.BadRequest(c, "Invalid to date, expected YYYY-MM-DD")
+			return
+		}
+		filter.To = to.AddDate(0, 0, 1).Unix() - 1
+	}
+
+	page := 1
+	if p, err := strconv.Atoi(c.Query("page")); err == nil && p > 0 {
+		page = p
+	}
+	limit := 50
+	if l, err := strconv.Atoi(c.Query("limit")); err == nil && l > 0 && l <= 200 {
+		limit = l
+	}
+
+	logs, total, err := h.service.Search(filter, page, limit)
+	if err != nil {
+		response.Error(c, errors.InternalError("Failed to fetch audit logs"))
+		return
+	}
+	response.Paginated(c, "Audit logs fetched", logs, page, limit, total)
+}
+
+func (h *AuditLogHandler) GetByID(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.BadRequest(c, "Invalid audit log ID")
+		return
+	}
+
+	log, err := h.service.GetByID(uint(id))
+	if err != nil {
+		response.Error(c, errors.NotFound("Audit log not found"))
+		return
+	}
+	response.Success(c, "Audit log fetched", log)
+}
+
+// GetEntityHistory returns every audited change to one record, newest first
+func (h *AuditLogHandler) GetEntityHistory(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.BadRequest(c, "Invalid entity ID")
+		return
+	}
+
+	logs, err := h.service.GetByEntity(c.Param("entity"), uint(id))
+	if err != nil {
+		response.Error(c, errors.InternalError("Failed to fetch audit logs"))
+		return
+	}
+	response.Success(c, "Audit logs fetched", logs)
+}
@@ -1,7 +1,10 @@
 package handlers
 
 import (
+	stderrors "errors"
+	"school-management-system/internal/models"
 	"school-management-system/internal/service"
+	"school-management-system/pkg/errors"
 	"school-management-system/pkg/response"
 	"strconv"
 
@@ -16,6 +19,28 @@ func NewBackupHandler(svc service.BackupService) *BackupHandler {
 	return &BackupHandler{service: svc}
 }
 
+// Create starts a backup in the background. Poll GET /backups/:id for its
+// status.
+func (h *BackupHandler) Create(c *gin.Context) {
+	var req struct {
+		Description string `json:"description"`
+	}
+	c.ShouldBindJSON(&req)
+
+	userID := c.GetUint("user_id")
+	backup, err := h.service.Start(&userID, models.BackupTriggerManual, req.Description)
+	if stderrors.Is(err, service.ErrBackupRunning) {
+		response.Conflict(c, err.Error())
+		return
+	}
+	if err != nil {
+		response.Error(c, errors.InternalError("Failed to start backup"))
+		return
+	}
+
+	response.Accepted(c, "Backup started", backup)
+}
+
 func (h *BackupHandler) GetAll(c *gin.Context) {
 	page := 1
 	if p := c.Query("page"); p != "" {
@@ -37,7 +62,7 @@ func (h *BackupHandler) GetAll(c *gin.Context) {
 func (h *BackupHandler) GetLatest(c *gin.Context) {
 	backup, err := h.service.GetLatestCompleted()
 	if err != nil {
-		response.Error(c, err)
+		response.Error(c, errors.NotFound("No completed backup found"))
 		return
 	}
 
@@ -49,18 +74,63 @@ func (h *BackupHandler) GetByID(c *gin.Context) {
 
 	backup, err := h.service.GetByID(uint(id))
 	if err != nil {
-		response.Error(c, err)
+		response.Error(c, errors.NotFound("Backup not found"))
 		return
 	}
 
 	response.Success(c, "Backup fetched", backup)
 }
 
+// Verify checks the backup file against its recorded checksum and reads it
+// through, returning the tables and row counts it holds
+func (h *BackupHandler) Verify(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+
+	if _, err := h.service.GetByID(uint(id)); err != nil {
+		response.Error(c, errors.NotFound("Backup not found"))
+		return
+	}
+
+	manifest, err := h.service.Verify(uint(id))
+	if err != nil {
+		response.BadRequest(c, "Backup verification failed: "+err.Error())
+		return
+	}
+
+	response.Success(c, "Backup verified", manifest)
+}
+
+func (h *BackupHandler) Download(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+
+	backup, err := h.service.GetByID(uint(id))
+	if err != nil {
+		response.Error(c, errors.NotFound("Backup not found"))
+		return
+	}
+	if backup.Status != models.BackupStatusCompleted {
+		response.BadRequest(c, "Backup is not completed")
+		return
+	}
+
+	c.Header("X-Checksum-SHA256", backup.Checksum)
+	c.FileAttachment(backup.Location, backup.BackupName)
+}
+
 func (h *BackupHandler) Delete(c *gin.Context) {
 	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
 
+	if _, err := h.service.GetByID(uint(id)); err != nil {
+		response.Error(c, errors.NotFound("Backup not found"))
+		return
+	}
+
 	if err := h.service.Delete(uint(id)); err != nil {
-		response.Error(c, err)
+		if stderrors.Is(err, service.ErrBackupRunning) {
+			response.Conflict(c, err.Error())
+			return
+		}
+		response.Error(c, errors.InternalError("Failed to delete backup"))
 		return
 	}
 
@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"school-management-system/internal/models"
+	"school-management-system/internal/service"
+	"school-management-system/pkg/errors"
+	"school-management-system/pkg/response"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CourseSectionHandler struct {
+	service service.CourseSectionService
+}
+
+func NewCourseSectionHandler(svc service.CourseSectionService) *CourseSectionHandler {
+	return &CourseSectionHandler{service: svc}
+}
+
+func (h *CourseSectionHandler) Create(c *gin.Context) {
+	var req struct {
+		CourseID     uint   `json:"course_id" binding:"required"`
+		TermID       uint   `json:"term_id" binding:"required"`
+		SectionCode  string `json:"section_code"`
+		TeacherID    uint   `json:"teacher_id"`
+		Room         string `json:"room"`
+		Schedule     string `json:"schedule"`
+		Capacity     int    `json:"capacity"`
+		WeeklyHours  int    `json:"weekly_hours"`
+		RoomFeatures string `json:"room_features"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+
+	section := &models.CourseSection{
+		CourseID:     req.CourseID,
+		TermID:       req.TermID,
+		SectionCode:  req.SectionCode,
+		TeacherID:    req.TeacherID,
+		Room:         req.Room,
+		Schedule:     req.Schedule,
+		Capacity:     req.Capacity,
+		WeeklyHours:  req.WeeklyHours,
+		RoomFeatures: req.RoomFeatures,
+	}
+
+	if err := h.service.CreateSection(section); err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+	response.Created(c, "Course section created", section)
+}
+
+func (h *CourseSectionHandler) GetByID(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	section, err := h.service.GetSectionByID(uint(id))
+	if err != nil {
+		response.Error(c, errors.NotFound("Course section not found"))
+		return
+	}
+
+	seats, err := h.service.GetSeatsRemaining(section)
+	if err != nil {
+		response.Error(c, errors.InternalError("Failed to count section enrollments"))
+		return
+	}
+	response.Success(c, "Course section fetched", gin.H{"section": section, "seats_remaining": seats})
+}
+
+func (h *CourseSectionHandler) GetByTerm(c *gin.Context) {
+	termID, _ := strconv.ParseUint(c.Param("term_id"), 10, 32)
+	page := 1
+	if p := c.Query("page"); p != "" {
+		if parsed, err := strconv.Atoi(p); err == nil {
+			page = parsed
+		}
+	}
+	limit := 50
+
+	sections, total, err := h.service.GetTermSections(uint(termID), page, limit)
+	if err != nil {
+		response.Error(c, errors.InternalError("Failed to fetch course sections"))
+		return
+	}
+	response.Paginated(c, "Course sections fetched", sections, page, limit, total)
+}
+
+func (h *CourseSectionHandler) GetByCourse(c *gin.Context) {
+	courseID, _ := strconv.ParseUint(c.Param("course_id"), 10, 32)
+	sections, err := h.service.GetCourseSections(uint(courseID))
+	if err != nil {
+		response.Error(c, errors.InternalError("Failed to fetch course sections"))
+		return
+	}
+	response.Success(c, "Course sections fetched", sections)
+}
+
+func (h *CourseSectionHandler) GetByTeacher(c *gin.Context) {
+	teacherID, _ := strconv.ParseUint(c.Param("teacher_id"), 10, 32)
+	termID, _ := strconv.ParseUint(c.Query("term_id"), 10, 32)
+	sections, err := h.service.GetTeacherSections(uint(teacherID), uint(termID))
+	if err != nil {
+		response.Error(c, errors.InternalError("Failed to fetch course sections"))
+		return
+	}
+	response.Success(c, "Course sections fetched", sections)
+}
+
+func (h *CourseSectionHandler) Update(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	var req struct {
+		SectionCode  string  `json:"section_code"`
+		TeacherID    uint    `json:"teacher_id"`
+		Room         string  `json:"room"`
+		Schedule     string  `json:"schedule"`
+		Capacity     *int    `json:"capacity"`
+		Status       string  `json:"status"`
+		WeeklyHours  *int    `json:"weekly_hours"`
+		RoomFeatures *string `json:"room_features"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+
+	section, err := h.service.GetSectionByID(uint(id))
+	if err != nil {
+		response.Error(c, errors.NotFound("Course section not found"))
+		return
+	}
+	if req.SectionCode != "" {
+		section.SectionCode = req.SectionCode
+	}
+	if req.TeacherID != 0 {
+		section.TeacherID = req.TeacherID
+	}
+	if req.Room != "" {
+		section.Room = req.Room
+	}
+	if req.Schedule != "" {
+		section.Schedule = req.Schedule
+	}
+	if req.Capacity != nil {
+		section.Capacity = *req.Capacity
+	}
+	if req.Status != "" {
+		section.Status = req.Status
+	}
+	if req.WeeklyHours != nil {
+		section.WeeklyHours = *req.WeeklyHours
+	}
+	if req.RoomFeatures != nil {
+		section.RoomFeatures = *req.RoomFeatures
+	}
+
+	if err := h.service.UpdateSection(section); err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+	response.Success(c, "Course section updated", section)
+}
+
+func (h *CourseSectionHandler) Delete(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err := h.service.DeleteSection(uint(id)); err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+	response.NoContent(c)
+}
@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	stderrors "errors"
+	"school-management-system/internal/models"
+	"school-management-system/internal/service"
+	"school-management-system/pkg/errors"
+	"school-management-system/pkg/response"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type EmailOutboxHandler struct {
+	service service.EmailOutboxService
+}
+
+func NewEmailOutboxHandler(svc service.EmailOutboxService) *EmailOutboxHandler {
+	return &EmailOutboxHandler{service: svc}
+}
+
+// GetAll lists queued and delivered emails, newest first. Filter with
+// ?status=pending|sent|failed.
+func (h *EmailOutboxHandler) GetAll(c *gin.Context) {
+	page := 1
+	if p := c.Query("page"); p != "" {
+		if parsed, err := strconv.Atoi(p); err == nil && parsed > 0 {
+			page = parsed
+		}
+	}
+	limit := 20
+	if l := c.Query("limit"); l != "" {
+		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 && parsed <= 100 {
+			limit = parsed
+		}
+	}
+
+	status := c.Query("status")
+	switch status {
+	case "", models.OutboxStatusPending, models.OutboxStatusSent, models.OutboxStatusFailed:
+	default:
+		response.BadRequest(c, "status must be pending, sent or failed")
+		return
+	}
+
+	emails, total, err := h.service.GetAll(status, page, limit)
+	if err != nil {
+		response.Error(c, errors.InternalError("Failed to fetch emails"))
+		return
+	}
+
+	response.Paginated(c, "Emails fetched", emails, page, limit, total)
+}
+
+func (h *EmailOutboxHandler) GetByID(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.BadRequest(c, "Invalid email ID")
+		return
+	}
+
+	email, err := h.service.GetByID(uint(id))
+	if err != nil {
+		response.Error(c, errors.NotFound("Email not found"))
+		return
+	}
+
+	response.Success(c, "Email fetched", email)
+}
+
+// GetStats counts emails by status
+func (h *EmailOutboxHandler) GetStats(c *gin.Context) {
+	stats, err := h.service.Stats()
+	if err != nil {
+		response.Error(c, errors.InternalError("Failed to fetch email stats"))
+		return
+	}
+
+	response.Success(c, "Email stats fetched", stats)
+}
+
+// Retry requeues a failed email for its dead-lettered recipients
+func (h *EmailOutboxHandler) Retry(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.BadRequest(c, "Invalid email ID")
+		return
+	}
+
+	err = h.service.Retry(uint(id))
+	switch {
+	case stderrors.Is(err, service.ErrOutboxNotFound):
+		response.Error(c, errors.NotFound("Email not found"))
+		return
+	case stderrors.Is(err, service.ErrEmailNotFailed):
+		response.Conflict(c, err.Error())
+		return
+	case err != nil:
+		response.Error(c, errors.InternalError("Failed to retry email"))
+		return
+	}
+
+	email, _ := h.service.GetByID(uint(id))
+	response.Accepted(c, "Email requeued", email)
+}
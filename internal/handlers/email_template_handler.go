@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	stderrors "errors"
+	"school-management-system/internal/models"
+	"school-management-system/internal/service"
+	"school-management-system/pkg/errors"
+	"school-management-system/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+type EmailTemplateHandler struct {
+	service service.EmailTemplateService
+}
+
+func NewEmailTemplateHandler(svc service.EmailTemplateService) *EmailTemplateHandler {
+	return &EmailTemplateHandler{service: svc}
+}
+
+type emailTemplateRequest struct {
+	Subject  string `json:"subject"`
+	HTMLBody string `json:"html_body"`
+	TextBody string `json:"text_body"`
+}
+
+type emailTemplatePreviewRequest struct {
+	emailTemplateRequest
+	Locale string                 `json:"locale"`
+	Data   map[string]interface{} `json:"data"`
+}
+
+// GetAll lists the emails the system sends and their locale variants
+func (h *EmailTemplateHandler) GetAll(c *gin.Context) {
+	templates, err := h.service.List()
+	if err != nil {
+		response.Error(c, errors.InternalError("Failed to fetch email templates"))
+		return
+	}
+
+	response.Success(c, "Email templates fetched", templates)
+}
+
+// GetByKey returns an email's sample data and all of its locale variants
+func (h *EmailTemplateHandler) GetByKey(c *gin.Context) {
+	template, err := h.service.Get(c.Param("key"))
+	if stderrors.Is(err, service.ErrEmailTemplateNotFound) {
+		response.Error(c, errors.NotFound("Email template not found"))
+		return
+	}
+	if err != nil {
+		response.Error(c, errors.InternalError("Failed to fetch email template"))
+		return
+	}
+
+	response.Success(c, "Email template fetched", template)
+}
+
+// Save creates or replaces the variant of an email for a locale
+func (h *EmailTemplateHandler) Save(c *gin.Context) {
+	var req emailTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+
+	template := &models.EmailTemplate{
+		Key:      c.Param("key"),
+		Locale:   c.Param("locale"),
+		Subject:  req.Subject,
+		HTMLBody: req.HTMLBody,
+		TextBody: req.TextBody,
+	}
+	if !h.handleError(c, h.service.Save(template, c.GetUint("user_id")), "Failed to save email template") {
+		return
+	}
+
+	response.Success(c, "Email template saved", template)
+}
+
+// Delete removes a locale variant. Deleting the default locale restores
+// the built-in content.
+func (h *EmailTemplateHandler) Delete(c *gin.Context) {
+	if !h.handleError(c, h.service.Delete(c.Param("key"), c.Param("locale")), "Failed to delete email template") {
+		return
+	}
+
+	response.Success(c, "Email template deleted", nil)
+}
+
+// Preview renders an email with sample data. The request may carry draft
+// templates to preview before saving them.
+func (h *EmailTemplateHandler) Preview(c *gin.Context) {
+	var req emailTemplatePreviewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+
+	rendered, err := h.service.Preview(&models.EmailTemplate{
+		Key:      c.Param("key"),
+		Locale:   req.Locale,
+		Subject:  req.Subject,
+		HTMLBody: req.HTMLBody,
+		TextBody: req.TextBody,
+	}, req.Data)
+	if !h.handleError(c, err, "Failed to preview email template") {
+		return
+	}
+
+	response.Success(c, "Email template preview", rendered)
+}
+
+// handleError writes the response for err and reports whether the
+// request may continue
+func (h *EmailTemplateHandler) handleError(c *gin.Context, err error, message string) bool {
+	switch {
+	case err == nil:
+		return true
+	case stderrors.Is(err, service.ErrEmailTemplateNotFound):
+		response.Error(c, errors.NotFound("Email template not found"))
+	case stderrors.Is(err, service.ErrInvalidLocale), stderrors.Is(err, service.ErrInvalidEmailTemplate):
+		response.BadRequest(c, err.Error())
+	default:
+		response.Error(c, errors.InternalError(message))
+	}
+	return false
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"school-management-system/internal/models"
 	"school-management-system/internal/service"
@@ -24,8 +25,12 @@ func NewEnrollmentHandler(enrollmentService service.EnrollmentService, studentSe
 
 type EnrollStudentRequest struct {
 	StudentID uint   `json:"student_id" binding:"required"`
-	CourseID  uint   `json:"course_id" binding:"required"`
+	CourseID  uint   `json:"course_id"`
+	SectionID *uint  `json:"section_id"`
 	Status    string `json:"status"`
+	// Waitlist puts the student on the waitlist of a full course or section
+	// instead of rejecting them. Defaults to true.
+	Waitlist *bool `json:"waitlist"`
 }
 
 func (h *EnrollmentHandler) EnrollStudent(c *gin.Context) {
@@ -35,23 +40,47 @@ func (h *EnrollmentHandler) EnrollStudent(c *gin.Context) {
 		return
 	}
 
+	// Only staff choose the status; anyone else's enrollment follows the rules
+	if role, _ := c.Get("user_role"); role != string(models.RoleAdmin) {
+		req.Status = ""
+	}
+
 	enrollment := &models.Enrollment{
 		StudentID:  req.StudentID,
 		CourseID:   req.CourseID,
+		SectionID:  req.SectionID,
 		EnrolledAt: time.Now(),
-		Status:     "active",
+		Status:     req.Status,
 	}
 
-	if req.Status != "" {
-		enrollment.Status = req.Status
-	}
-
-	err := h.enrollmentService.EnrollStudent(enrollment)
+	err := h.enrollmentService.EnrollStudent(c.Request.Context(), enrollment, service.EnrollOptions{
+		AllowWaitlist: req.Waitlist == nil || *req.Waitlist,
+		ActorID:       c.GetUint("user_id"),
+	})
 	if err != nil {
+		var rejection *service.EnrollmentRejectedError
+		if errors.As(err, &rejection) {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error(), "reasons": rejection.Reasons})
+			return
+		}
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
+	if enrollment.Status == models.EnrollmentWaitlisted {
+		position, err := h.enrollmentService.GetWaitlistPosition(enrollment)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch waitlist position"})
+			return
+		}
+		c.JSON(http.StatusAccepted, gin.H{
+			"message":           "Course is full, student added to the waitlist",
+			"enrollment":        enrollment,
+			"waitlist_position": position,
+		})
+		return
+	}
+
 	c.JSON(http.StatusCreated, gin.H{
 		"message":    "Student enrolled successfully",
 		"enrollment": enrollment,
@@ -71,6 +100,16 @@ func (h *EnrollmentHandler) GetEnrollment(c *gin.Context) {
 		return
 	}
 
+	if enrollment.Status == models.EnrollmentWaitlisted {
+		position, err := h.enrollmentService.GetWaitlistPosition(enrollment)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch waitlist position"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"enrollment": enrollment, "waitlist_position": position})
+		return
+	}
+
 	c.JSON(http.StatusOK, enrollment)
 }
 
@@ -130,15 +169,38 @@ func (h *EnrollmentHandler) GetCourseEnrollments(c *gin.Context) {
 	})
 }
 
-func (h *EnrollmentHandler) UpdateEnrollmentStatus(c *gin.Context) {
-	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+func (h *EnrollmentHandler) GetSectionEnrollments(c *gin.Context) {
+	sectionID, err := strconv.ParseUint(c.Param("sectionId"), 10, 32)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid enrollment ID"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid section ID"})
 		return
 	}
 
+	page := 1
+	limit := 50
+
+	if p := c.Query("page"); p != "" {
+		if val, err := strconv.Atoi(p); err == nil && val > 0 {
+			page = val
+		}
+	}
+
+	enrollments, total, err := h.enrollmentService.GetSectionEnrollments(uint(sectionID), page, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch enrollments"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":  enrollments,
+		"total": total,
+	})
+}
+
+func (h *EnrollmentHandler) UpdateEnrollmentStatus(c *gin.Context) {
 	var req struct {
 		Status string `json:"status" binding:"required"`
+		Reason string `json:"reason"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -146,13 +208,54 @@ func (h *EnrollmentHandler) UpdateEnrollmentStatus(c *gin.Context) {
 		return
 	}
 
-	err = h.enrollmentService.UpdateEnrollmentStatus(uint(id), req.Status)
+	h.changeStatus(c, req.Status, req.Reason, "Enrollment status updated")
+}
+
+// changeStatus moves the enrollment in the path to a new status and
+// responds with the result
+func (h *EnrollmentHandler) changeStatus(c *gin.Context, status, reason, message string) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid enrollment ID"})
+		return
+	}
+
+	enrollment, err := h.enrollmentService.ChangeStatus(c.Request.Context(), uint(id), status, c.GetUint("user_id"), reason)
+	if err != nil {
+		var rejection *service.EnrollmentRejectedError
+		switch {
+		case errors.As(err, &rejection):
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error(), "reasons": rejection.Reasons})
+		case errors.Is(err, service.ErrInvalidTransition), errors.Is(err, service.ErrDeadlinePassed):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case err.Error() == "enrollment not found":
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":    message,
+		"enrollment": enrollment,
+	})
+}
+
+func (h *EnrollmentHandler) GetHistory(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid enrollment ID"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Enrollment status updated"})
+	history, err := h.enrollmentService.GetHistory(uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": history})
 }
 
 func (h *EnrollmentHandler) RemoveEnrollment(c *gin.Context) {
@@ -162,7 +265,7 @@ func (h *EnrollmentHandler) RemoveEnrollment(c *gin.Context) {
 		return
 	}
 
-	err = h.enrollmentService.RemoveEnrollment(uint(id))
+	err = h.enrollmentService.RemoveEnrollment(c.Request.Context(), uint(id))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
@@ -215,36 +318,21 @@ func (h *EnrollmentHandler) GetAllEnrollments(c *gin.Context) {
 	})
 }
 
-func (h *EnrollmentHandler) ApproveEnrollment(c *gin.Context) {
-	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid enrollment ID"})
-		return
-	}
-
-	err = h.enrollmentService.UpdateEnrollmentStatus(uint(id), "approved")
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
+// statusReason reads the optional reason given when approving or rejecting
+func statusReason(c *gin.Context) string {
+	var req struct {
+		Reason string `json:"reason"`
 	}
+	c.ShouldBindJSON(&req)
+	return req.Reason
+}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Enrollment approved"})
+func (h *EnrollmentHandler) ApproveEnrollment(c *gin.Context) {
+	h.changeStatus(c, models.EnrollmentApproved, statusReason(c), "Enrollment approved")
 }
 
 func (h *EnrollmentHandler) RejectEnrollment(c *gin.Context) {
-	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid enrollment ID"})
-		return
-	}
-
-	err = h.enrollmentService.UpdateEnrollmentStatus(uint(id), "rejected")
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
-
-	c.JSON(http.StatusOK, gin.H{"message": "Enrollment rejected"})
+	h.changeStatus(c, models.EnrollmentRejected, statusReason(c), "Enrollment rejected")
 }
 
 func (h *EnrollmentHandler) GetMyEnrollments(c *gin.Context) {
@@ -293,3 +381,83 @@ func (h *EnrollmentHandler) GetMyEnrollments(c *gin.Context) {
 		"total": total,
 	})
 }
+
+func (h *EnrollmentHandler) GetRequisites(c *gin.Context) {
+	courseID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid course ID"})
+		return
+	}
+
+	requisites, err := h.enrollmentService.GetRequisites(uint(courseID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch requisites"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": requisites})
+}
+
+func (h *EnrollmentHandler) AddRequisite(c *gin.Context) {
+	courseID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid course ID"})
+		return
+	}
+
+	var req struct {
+		RequiredCourseID uint   `json:"required_course_id" binding:"required"`
+		Kind             string `json:"kind"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	requisite := &models.CourseRequisite{
+		CourseID:         uint(courseID),
+		RequiredCourseID: req.RequiredCourseID,
+		Kind:             req.Kind,
+	}
+	if err := h.enrollmentService.AddRequisite(requisite); err != nil {
+		if errors.Is(err, service.ErrInvalidRequisite) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err.Error() == "course not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add requisite"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":   "Requisite added",
+		"requisite": requisite,
+	})
+}
+
+func (h *EnrollmentHandler) RemoveRequisite(c *gin.Context) {
+	courseID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid course ID"})
+		return
+	}
+	id, err := strconv.ParseUint(c.Param("requisite_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid requisite ID"})
+		return
+	}
+
+	if err := h.enrollmentService.RemoveRequisite(uint(courseID), uint(id)); err != nil {
+		if errors.Is(err, service.ErrRequisiteNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove requisite"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Requisite removed"})
+}
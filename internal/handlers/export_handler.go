@@ -74,7 +74,7 @@ func (h *ExportHandler) ExportAttendanceCSV(c *gin.Context) {
 
 // ExportStudentTranscript exports student transcript as CSV
 func (h *ExportHandler) ExportStudentTranscript(c *gin.Context) {
-	studentID, _ := strconv.ParseUint(c.Query("student_id"), 10, 32)
+	studentID, _ := strconv.ParseUint(c.Param("student_id"), 10, 32)
 
 	data, err := h.exportService.ExportStudentTranscriptCSV(uint(studentID))
 	if err != nil {
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"school-management-system/internal/authz"
 	"school-management-system/internal/models"
 	"school-management-system/internal/service"
 	"school-management-system/pkg/response"
@@ -21,7 +22,8 @@ func NewGradeAutoCalcHandler(svc *service.GradeAutoCalculationService) *GradeAut
 func (h *GradeAutoCalcHandler) RecordGradeWithAutoCalc(c *gin.Context) {
 	var req struct {
 		StudentID uint    `json:"student_id" binding:"required"`
-		CourseID  uint    `json:"course_id" binding:"required"`
+		CourseID  uint    `json:"course_id"`
+		SectionID *uint   `json:"section_id"`
 		Score     float64 `json:"score" binding:"required"`
 	}
 
@@ -30,13 +32,23 @@ func (h *GradeAutoCalcHandler) RecordGradeWithAutoCalc(c *gin.Context) {
 		return
 	}
 
+	if req.CourseID == 0 && (req.SectionID == nil || *req.SectionID == 0) {
+		response.BadRequest(c, "Invalid input: course_id or section_id is required")
+		return
+	}
+
+	if !authz.Authorize(c, authz.ActionCreate, authz.StudentRecord(authz.KindGrade, req.StudentID, req.CourseID, req.SectionID)) {
+		return
+	}
+
 	grade := &models.Grade{
 		StudentID: req.StudentID,
 		CourseID:  req.CourseID,
+		SectionID: req.SectionID,
 		Score:     req.Score,
 	}
 
-	if err := h.service.RecordGradeAndAutoCalculate(grade); err != nil {
+	if err := h.service.RecordGradeAndAutoCalculate(c.Request.Context(), grade); err != nil {
 		response.Error(c, err)
 		return
 	}
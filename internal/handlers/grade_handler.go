@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"net/http"
+	"school-management-system/internal/authz"
 	"school-management-system/internal/models"
 	"school-management-system/internal/service"
 	"strconv"
@@ -24,7 +25,8 @@ func NewGradeHandler(gradeService service.GradeService, studentService service.S
 
 type RecordGradeRequest struct {
 	StudentID uint    `json:"student_id" binding:"required"`
-	CourseID  uint    `json:"course_id" binding:"required"`
+	CourseID  uint    `json:"course_id"`
+	SectionID *uint   `json:"section_id"`
 	Grade     string  `json:"grade"`
 	Score     float64 `json:"score" binding:"required"`
 	MaxScore  float64 `json:"max_score"`
@@ -39,9 +41,14 @@ func (h *GradeHandler) RecordGrade(c *gin.Context) {
 		return
 	}
 
+	if !authz.Authorize(c, authz.ActionCreate, authz.StudentRecord(authz.KindGrade, req.StudentID, req.CourseID, req.SectionID)) {
+		return
+	}
+
 	grade := &models.Grade{
 		StudentID: req.StudentID,
 		CourseID:  req.CourseID,
+		SectionID: req.SectionID,
 		Grade:     req.Grade,
 		Score:     req.Score,
 		MaxScore:  req.MaxScore,
@@ -54,7 +61,7 @@ func (h *GradeHandler) RecordGrade(c *gin.Context) {
 		grade.MaxScore = 100
 	}
 
-	err := h.gradeService.RecordGrade(grade)
+	err := h.gradeService.RecordGrade(c.Request.Context(), grade)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
@@ -159,7 +166,7 @@ func (h *GradeHandler) UpdateGrade(c *gin.Context) {
 		Remarks:  req.Remarks,
 	}
 
-	err = h.gradeService.UpdateGrade(grade)
+	err = h.gradeService.UpdateGrade(c.Request.Context(), grade)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
@@ -175,7 +182,7 @@ func (h *GradeHandler) DeleteGrade(c *gin.Context) {
 		return
 	}
 
-	err = h.gradeService.DeleteGrade(uint(id))
+	err = h.gradeService.DeleteGrade(c.Request.Context(), uint(id))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"school-management-system/internal/models"
 	"school-management-system/internal/service"
 	"school-management-system/pkg/errors"
 	"school-management-system/pkg/response"
@@ -51,11 +52,90 @@ func (h *GradeTranscriptHandler) GetLatest(c *gin.Context) {
 func (h *GradeTranscriptHandler) GetGPA(c *gin.Context) {
 	studentID, _ := strconv.ParseUint(c.Param("student_id"), 10, 32)
 
-	gpa, err := h.service.CalculateGPA(uint(studentID))
+	latest, err := h.service.GetLatestByStudent(uint(studentID))
 	if err != nil {
-		response.Error(c, errors.InternalError("Failed to calculate GPA"))
+		response.Success(c, "GPA calculated", gin.H{"student_id": studentID, "gpa": 0.0})
 		return
 	}
 
-	response.Success(c, "GPA calculated", gin.H{"student_id": studentID, "gpa": gpa})
+	response.Success(c, "GPA calculated", gin.H{
+		"student_id":          studentID,
+		"gpa":                 latest.CumulativeGPA,
+		"term_gpa":            latest.GPA,
+		"term_id":             latest.TermID,
+		"credits_attempted":   latest.CumulativeCredits,
+		"credits_earned":      latest.CumulativeEarnedCredits,
+		"transcript_official": latest.IsOfficial,
+	})
+}
+
+func (h *GradeTranscriptHandler) Regenerate(c *gin.Context) {
+	studentID, _ := strconv.ParseUint(c.Param("student_id"), 10, 32)
+
+	transcripts, err := h.service.RegenerateForStudent(uint(studentID))
+	if err != nil {
+		response.Error(c, errors.InternalError("Failed to regenerate transcript"))
+		return
+	}
+
+	response.Success(c, "Transcript regenerated", transcripts)
+}
+
+func (h *GradeTranscriptHandler) GetTransferCredits(c *gin.Context) {
+	studentID, _ := strconv.ParseUint(c.Param("student_id"), 10, 32)
+
+	credits, err := h.service.GetTransferCredits(uint(studentID))
+	if err != nil {
+		response.Error(c, errors.InternalError("Failed to fetch transfer credits"))
+		return
+	}
+
+	response.Success(c, "Transfer credits fetched", credits)
+}
+
+func (h *GradeTranscriptHandler) AddTransferCredit(c *gin.Context) {
+	var req struct {
+		StudentID          uint    `json:"student_id" binding:"required"`
+		TermID             *uint   `json:"term_id"`
+		Institution        string  `json:"institution" binding:"required"`
+		CourseTitle        string  `json:"course_title"`
+		EquivalentCourseID *uint   `json:"equivalent_course_id"`
+		Credits            float64 `json:"credits" binding:"required"`
+		Grade              string  `json:"grade"`
+		CountsInGPA        bool    `json:"counts_in_gpa"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+
+	credit := &models.TransferCredit{
+		StudentID:          req.StudentID,
+		TermID:             req.TermID,
+		Institution:        req.Institution,
+		CourseTitle:        req.CourseTitle,
+		EquivalentCourseID: req.EquivalentCourseID,
+		Credits:            req.Credits,
+		Grade:              req.Grade,
+		CountsInGPA:        req.CountsInGPA,
+	}
+	credit.ApprovedBy = c.GetUint("user_id")
+
+	if err := h.service.AddTransferCredit(credit); err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+
+	response.Created(c, "Transfer credit added", credit)
+}
+
+func (h *GradeTranscriptHandler) DeleteTransferCredit(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+
+	if err := h.service.DeleteTransferCredit(uint(id)); err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+
+	response.NoContent(c)
 }
@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"school-management-system/internal/authz"
+	"school-management-system/internal/models"
+	"school-management-system/internal/service"
+	"school-management-system/pkg/errors"
+	"school-management-system/pkg/response"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type GradebookHandler struct {
+	service service.GradebookService
+}
+
+func NewGradebookHandler(svc service.GradebookService) *GradebookHandler {
+	return &GradebookHandler{service: svc}
+}
+
+type GradeCategoryRequest struct {
+	CourseID   uint    `json:"course_id"`
+	Name       string  `json:"name" binding:"required"`
+	Weight     float64 `json:"weight"`
+	DropLowest int     `json:"drop_lowest"`
+}
+
+func (h *GradebookHandler) CreateCategory(c *gin.Context) {
+	var req GradeCategoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+
+	if !authz.Authorize(c, authz.ActionCreate, authz.Resource{Kind: authz.KindGradebook, CourseID: req.CourseID}) {
+		return
+	}
+
+	category := &models.GradeCategory{
+		CourseID:   req.CourseID,
+		Name:       req.Name,
+		Weight:     req.Weight,
+		DropLowest: req.DropLowest,
+	}
+	if err := h.service.CreateCategory(c.Request.Context(), category); err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+	response.Created(c, "Grade category created", category)
+}
+
+func (h *GradebookHandler) GetCourseCategories(c *gin.Context) {
+	courseID, _ := strconv.ParseUint(c.Param("course_id"), 10, 32)
+	categories, err := h.service.GetCourseCategories(uint(courseID))
+	if err != nil {
+		response.Error(c, errors.InternalError("Failed to fetch grade categories"))
+		return
+	}
+	response.Success(c, "Grade categories fetched", categories)
+}
+
+func (h *GradebookHandler) UpdateCategory(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+
+	var req GradeCategoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+
+	category := &models.GradeCategory{
+		ID:         uint(id),
+		Name:       req.Name,
+		Weight:     req.Weight,
+		DropLowest: req.DropLowest,
+	}
+	if err := h.service.UpdateCategory(c.Request.Context(), category); err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+	response.Success(c, "Grade category updated", category)
+}
+
+func (h *GradebookHandler) DeleteCategory(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err := h.service.DeleteCategory(c.Request.Context(), uint(id)); err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+	response.NoContent(c)
+}
+
+// GetGradebook returns the students × assignments matrix with category
+// subtotals and the running course percentage.
+func (h *GradebookHandler) GetGradebook(c *gin.Context) {
+	courseID, _ := strconv.ParseUint(c.Param("course_id"), 10, 32)
+	book, err := h.service.GetGradebook(uint(courseID))
+	if err != nil {
+		response.Error(c, errors.NotFound(err.Error()))
+		return
+	}
+	response.Success(c, "Gradebook fetched", book)
+}
+
+func (h *GradebookHandler) Recompute(c *gin.Context) {
+	courseID, _ := strconv.ParseUint(c.Param("course_id"), 10, 32)
+	updated, err := h.service.RecomputeCourse(c.Request.Context(), uint(courseID))
+	if err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+	response.Success(c, "Course grades recomputed", gin.H{"course_id": courseID, "students_updated": updated})
+}
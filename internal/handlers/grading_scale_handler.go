@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"school-management-system/internal/models"
+	"school-management-system/internal/service"
+	"school-management-system/pkg/errors"
+	"school-management-system/pkg/response"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type GradingScaleHandler struct {
+	service service.GradingScaleService
+}
+
+func NewGradingScaleHandler(svc service.GradingScaleService) *GradingScaleHandler {
+	return &GradingScaleHandler{service: svc}
+}
+
+type GradingScaleRequest struct {
+	Name        string `json:"name" binding:"required"`
+	Description string `json:"description"`
+	Scope       string `json:"scope" binding:"required"`
+	Department  string `json:"department"`
+	CourseID    *uint  `json:"course_id"`
+	Bands       []struct {
+		Letter        string  `json:"letter" binding:"required"`
+		MinPercentage float64 `json:"min_percentage"`
+		GradePoints   float64 `json:"grade_points"`
+		IsPassing     bool    `json:"is_passing"`
+	} `json:"bands" binding:"required"`
+}
+
+func (r *GradingScaleRequest) apply(scale *models.GradingScale) {
+	scale.Name = r.Name
+	scale.Description = r.Description
+	scale.Scope = r.Scope
+	scale.Department = r.Department
+	scale.CourseID = r.CourseID
+	scale.Bands = make([]models.GradingBand, 0, len(r.Bands))
+	for _, b := range r.Bands {
+		scale.Bands = append(scale.Bands, models.GradingBand{
+			Letter:        b.Letter,
+			MinPercentage: b.MinPercentage,
+			GradePoints:   b.GradePoints,
+			IsPassing:     b.IsPassing,
+		})
+	}
+}
+
+func (h *GradingScaleHandler) Create(c *gin.Context) {
+	var req GradingScaleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+
+	scale := &models.GradingScale{}
+	req.apply(scale)
+
+	if err := h.service.CreateScale(scale); err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+	response.Created(c, "Grading scale created", scale)
+}
+
+func (h *GradingScaleHandler) GetAll(c *gin.Context) {
+	scales, err := h.service.GetAllScales()
+	if err != nil {
+		response.Error(c, errors.InternalError("Failed to fetch grading scales"))
+		return
+	}
+	response.Success(c, "Grading scales fetched", scales)
+}
+
+func (h *GradingScaleHandler) GetByID(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	scale, err := h.service.GetScaleByID(uint(id))
+	if err != nil {
+		response.Error(c, errors.NotFound("Grading scale not found"))
+		return
+	}
+	response.Success(c, "Grading scale fetched", scale)
+}
+
+// GetForCourse returns the scale in effect for a course, falling back to the
+// department, school or built-in scale.
+func (h *GradingScaleHandler) GetForCourse(c *gin.Context) {
+	courseID, _ := strconv.ParseUint(c.Param("course_id"), 10, 32)
+	scale, err := h.service.GetScaleForCourse(uint(courseID))
+	if err != nil {
+		response.Error(c, errors.NotFound("Course not found"))
+		return
+	}
+	response.Success(c, "Grading scale fetched", scale)
+}
+
+func (h *GradingScaleHandler) Update(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+
+	var req GradingScaleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+
+	scale := &models.GradingScale{ID: uint(id)}
+	req.apply(scale)
+
+	if err := h.service.UpdateScale(scale); err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+	response.Success(c, "Grading scale updated", scale)
+}
+
+func (h *GradingScaleHandler) Delete(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err := h.service.DeleteScale(uint(id)); err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+	response.NoContent(c)
+}
@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"school-management-system/internal/models"
+	"school-management-system/internal/service"
+	"school-management-system/pkg/errors"
+	"school-management-system/pkg/response"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type GuardianHandler struct {
+	guardianService service.GuardianService
+	portalService   service.ParentPortalService
+}
+
+func NewGuardianHandler(guardianSvc service.GuardianService, portalSvc service.ParentPortalService) *GuardianHandler {
+	return &GuardianHandler{guardianService: guardianSvc, portalService: portalSvc}
+}
+
+type LinkGuardianRequest struct {
+	UserID                uint   `json:"user_id" binding:"required"`
+	StudentID             uint   `json:"student_id" binding:"required"`
+	Relationship          string `json:"relationship" binding:"required"`
+	HasCustody            *bool  `json:"has_custody"`
+	IsEmergencyContact    bool   `json:"is_emergency_contact"`
+	ReceivesNotifications *bool  `json:"receives_notifications"`
+}
+
+type UpdateGuardianRequest struct {
+	Relationship          string `json:"relationship" binding:"required"`
+	HasCustody            bool   `json:"has_custody"`
+	IsEmergencyContact    bool   `json:"is_emergency_contact"`
+	ReceivesNotifications bool   `json:"receives_notifications"`
+}
+
+// Link connects a parent account to a student (admin)
+func (h *GuardianHandler) Link(c *gin.Context) {
+	var req LinkGuardianRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+
+	guardian := &models.Guardian{
+		UserID:                req.UserID,
+		StudentID:             req.StudentID,
+		Relationship:          req.Relationship,
+		HasCustody:            true,
+		IsEmergencyContact:    req.IsEmergencyContact,
+		ReceivesNotifications: true,
+	}
+	if req.HasCustody != nil {
+		guardian.HasCustody = *req.HasCustody
+	}
+	if req.ReceivesNotifications != nil {
+		guardian.ReceivesNotifications = *req.ReceivesNotifications
+	}
+
+	if err := h.guardianService.LinkGuardian(guardian); err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+	response.Created(c, "Guardian linked", guardian)
+}
+
+func (h *GuardianHandler) Update(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+
+	var req UpdateGuardianRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+
+	guardian := &models.Guardian{
+		ID:                    uint(id),
+		Relationship:          req.Relationship,
+		HasCustody:            req.HasCustody,
+		IsEmergencyContact:    req.IsEmergencyContact,
+		ReceivesNotifications: req.ReceivesNotifications,
+	}
+	if err := h.guardianService.UpdateGuardian(guardian); err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+	response.Success(c, "Guardian updated", guardian)
+}
+
+func (h *GuardianHandler) Unlink(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err := h.guardianService.UnlinkGuardian(uint(id)); err != nil {
+		response.Error(c, errors.NotFound(err.Error()))
+		return
+	}
+	response.NoContent(c)
+}
+
+func (h *GuardianHandler) GetByStudent(c *gin.Context) {
+	studentID, _ := strconv.ParseUint(c.Param("student_id"), 10, 32)
+	guardians, err := h.guardianService.GetGuardiansOfStudent(uint(studentID))
+	if err != nil {
+		response.Error(c, errors.InternalError("Failed to fetch guardians"))
+		return
+	}
+	response.Success(c, "Guardians fetched", guardians)
+}
+
+// Parent portal. The routes check that the parent is linked to :student_id.
+
+func (h *GuardianHandler) GetMyChildren(c *gin.Context) {
+	links, err := h.guardianService.GetChildren(c.GetUint("user_id"))
+	if err != nil {
+		response.Error(c, errors.InternalError("Failed to fetch children"))
+		return
+	}
+	response.Success(c, "Children fetched", links)
+}
+
+func (h *GuardianHandler) GetChildGrades(c *gin.Context) {
+	studentID, _ := strconv.ParseUint(c.Param("student_id"), 10, 32)
+	grades, err := h.portalService.GetChildGrades(uint(studentID))
+	if err != nil {
+		response.Error(c, errors.InternalError("Failed to fetch grades"))
+		return
+	}
+	response.Success(c, "Grades fetched", grades)
+}
+
+func (h *GuardianHandler) GetChildAttendance(c *gin.Context) {
+	studentID, _ := strconv.ParseUint(c.Param("student_id"), 10, 32)
+	page := 1
+	if p := c.Query("page"); p != "" {
+		if parsed, err := strconv.Atoi(p); err == nil {
+			page = parsed
+		}
+	}
+	limit := 20
+
+	records, total, err := h.portalService.GetChildAttendance(uint(studentID), page, limit)
+	if err != nil {
+		response.Error(c, errors.InternalError("Failed to fetch attendance"))
+		return
+	}
+	response.Paginated(c, "Attendance fetched", records, page, limit, total)
+}
+
+func (h *GuardianHandler) GetChildAssignments(c *gin.Context) {
+	studentID, _ := strconv.ParseUint(c.Param("student_id"), 10, 32)
+	assignments, err := h.portalService.GetChildAssignments(uint(studentID))
+	if err != nil {
+		response.Error(c, errors.InternalError("Failed to fetch assignments"))
+		return
+	}
+	response.Success(c, "Assignments fetched", assignments)
+}
+
+func (h *GuardianHandler) GetChildTimetable(c *gin.Context) {
+	studentID, _ := strconv.ParseUint(c.Param("student_id"), 10, 32)
+	slots, err := h.portalService.GetChildTimetable(uint(studentID))
+	if err != nil {
+		response.Error(c, errors.InternalError("Failed to fetch timetable"))
+		return
+	}
+	response.Success(c, "Timetable fetched", slots)
+}
+
+func (h *GuardianHandler) GetChildBalance(c *gin.Context) {
+	studentID, _ := strconv.ParseUint(c.Param("student_id"), 10, 32)
+	balance, err := h.portalService.GetChildBalance(uint(studentID))
+	if err != nil {
+		response.Error(c, errors.InternalError("Failed to get balance"))
+		return
+	}
+	response.Success(c, "Balance fetched", balance)
+}
+
+func (h *GuardianHandler) GetAnnouncements(c *gin.Context) {
+	page := 1
+	if p := c.Query("page"); p != "" {
+		if parsed, err := strconv.Atoi(p); err == nil {
+			page = parsed
+		}
+	}
+	limit := 20
+
+	announcements, total, err := h.portalService.GetAnnouncements(c.GetUint("user_id"), page, limit)
+	if err != nil {
+		response.Error(c, errors.InternalError("Failed to fetch announcements"))
+		return
+	}
+	response.Paginated(c, "Announcements fetched", announcements, page, limit, total)
+}
@@ -1,13 +1,22 @@
 package handlers
 
 import (
+	"encoding/csv"
+	stderrors "errors"
+	"fmt"
+	"io"
 	"school-management-system/internal/service"
+	"school-management-system/pkg/errors"
 	"school-management-system/pkg/response"
 	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 )
 
+// maxImportFileSize bounds an uploaded import file
+const maxImportFileSize = 8 << 20
+
 type ImportBatchHandler struct {
 	service service.ImportBatchService
 }
@@ -16,6 +25,92 @@ func NewImportBatchHandler(svc service.ImportBatchService) *ImportBatchHandler {
 	return &ImportBatchHandler{service: svc}
 }
 
+// Upload accepts a CSV or XLSX file as multipart form field "file" along
+// with entity_type and an optional dry_run flag, and processes it in the
+// background. Poll GET /imports/:id for progress.
+func (h *ImportBatchHandler) Upload(c *gin.Context) {
+	entityType := strings.ToLower(c.PostForm("entity_type"))
+	dryRun, _ := strconv.ParseBool(c.PostForm("dry_run"))
+
+	header, err := c.FormFile("file")
+	if err != nil {
+		response.BadRequest(c, "file is required")
+		return
+	}
+	if header.Size > maxImportFileSize {
+		response.BadRequest(c, "file is too large, the limit is 8MB")
+		return
+	}
+	file, err := header.Open()
+	if err != nil {
+		response.BadRequest(c, "failed to read file")
+		return
+	}
+	defer file.Close()
+	data, err := io.ReadAll(io.LimitReader(file, maxImportFileSize))
+	if err != nil {
+		response.BadRequest(c, "failed to read file")
+		return
+	}
+
+	batch, err := h.service.Start(c.Request.Context(), entityType, header.Filename, data, dryRun, c.GetUint("user_id"))
+	if stderrors.Is(err, service.ErrUnknownImportEntity) || stderrors.Is(err, service.ErrImportFileType) {
+		response.BadRequest(c, err.Error())
+		return
+	}
+	if err != nil {
+		response.Error(c, errors.InternalError("Failed to start import"))
+		return
+	}
+
+	response.Accepted(c, "Import started", batch)
+}
+
+// Columns lists the required and optional columns for an entity type
+func (h *ImportBatchHandler) Columns(c *gin.Context) {
+	required, optional, err := h.service.Columns(c.Param("entity"))
+	if err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+
+	response.Success(c, "Import columns fetched", gin.H{
+		"entity_type": c.Param("entity"),
+		"required":    required,
+		"optional":    optional,
+	})
+}
+
+// Errors returns a batch's rejected rows, as CSV when format=csv
+func (h *ImportBatchHandler) Errors(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+
+	batch, err := h.service.GetByID(uint(id))
+	if err != nil {
+		response.Error(c, errors.NotFound("Import batch not found"))
+		return
+	}
+	rowErrors, err := h.service.RowErrors(batch)
+	if err != nil {
+		response.Error(c, errors.InternalError("Failed to read import errors"))
+		return
+	}
+
+	if c.Query("format") != "csv" {
+		response.Success(c, "Import errors fetched", rowErrors)
+		return
+	}
+
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="import-%d-errors.csv"`, batch.ID))
+	w := csv.NewWriter(c.Writer)
+	w.Write([]string{"row", "column", "value", "error"})
+	for _, e := range rowErrors {
+		w.Write([]string{strconv.Itoa(e.Row), e.Column, e.Value, e.Message})
+	}
+	w.Flush()
+}
+
 func (h *ImportBatchHandler) GetAll(c *gin.Context) {
 	page := 1
 	if p := c.Query("page"); p != "" {
@@ -39,7 +134,7 @@ func (h *ImportBatchHandler) GetByID(c *gin.Context) {
 
 	batch, err := h.service.GetByID(uint(id))
 	if err != nil {
-		response.Error(c, err)
+		response.Error(c, errors.NotFound("Import batch not found"))
 		return
 	}
 
@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	stderrors "errors"
+	"school-management-system/internal/service"
+	"school-management-system/pkg/errors"
+	"school-management-system/pkg/response"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type JobHandler struct {
+	service service.JobSchedulerService
+}
+
+func NewJobHandler(svc service.JobSchedulerService) *JobHandler {
+	return &JobHandler{service: svc}
+}
+
+func (h *JobHandler) GetAll(c *gin.Context) {
+	jobs, err := h.service.GetAll()
+	if err != nil {
+		response.Error(c, errors.InternalError("Failed to fetch jobs"))
+		return
+	}
+
+	response.Success(c, "Jobs fetched", jobs)
+}
+
+func (h *JobHandler) GetByName(c *gin.Context) {
+	job, err := h.service.GetByName(c.Param("name"))
+	if err != nil {
+		response.Error(c, errors.NotFound("Job not found"))
+		return
+	}
+
+	response.Success(c, "Job fetched", job)
+}
+
+// GetRuns lists run history, newest first, for one job or for all jobs
+func (h *JobHandler) GetRuns(c *gin.Context) {
+	page := 1
+	if p := c.Query("page"); p != "" {
+		if parsed, err := strconv.Atoi(p); err == nil && parsed > 0 {
+			page = parsed
+		}
+	}
+	limit := 20
+	if l := c.Query("limit"); l != "" {
+		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 && parsed <= 100 {
+			limit = parsed
+		}
+	}
+
+	name := c.Param("name")
+	if name != "" {
+		if _, err := h.service.GetByName(name); err != nil {
+			response.Error(c, errors.NotFound("Job not found"))
+			return
+		}
+	}
+
+	runs, total, err := h.service.GetRuns(name, page, limit)
+	if err != nil {
+		response.Error(c, errors.InternalError("Failed to fetch job runs"))
+		return
+	}
+
+	response.Paginated(c, "Job runs fetched", runs, page, limit, total)
+}
+
+// Trigger starts a job now in the background. Poll the job's runs for the
+// outcome.
+func (h *JobHandler) Trigger(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	run, err := h.service.Trigger(c.Param("name"), &userID)
+	switch {
+	case stderrors.Is(err, service.ErrJobNotFound):
+		response.Error(c, errors.NotFound("Job not found"))
+		return
+	case stderrors.Is(err, service.ErrJobRunning):
+		response.Conflict(c, err.Error())
+		return
+	case err != nil:
+		response.Error(c, errors.InternalError("Failed to start job"))
+		return
+	}
+
+	response.Accepted(c, "Job started", run)
+}
+
+func (h *JobHandler) Pause(c *gin.Context) {
+	h.setPaused(c, true)
+}
+
+func (h *JobHandler) Resume(c *gin.Context) {
+	h.setPaused(c, false)
+}
+
+func (h *JobHandler) setPaused(c *gin.Context, paused bool) {
+	job, err := h.service.SetPaused(c.Param("name"), paused)
+	if stderrors.Is(err, service.ErrJobNotFound) {
+		response.Error(c, errors.NotFound("Job not found"))
+		return
+	}
+	if err != nil {
+		response.Error(c, errors.InternalError("Failed to update job"))
+		return
+	}
+
+	message := "Job resumed"
+	if paused {
+		message = "Job paused"
+	}
+	response.Success(c, message, job)
+}
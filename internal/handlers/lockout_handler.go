@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"school-management-system/internal/service"
+	"school-management-system/pkg/errors"
+	"school-management-system/pkg/response"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type LockoutHandler struct {
+	protectionService service.LoginProtectionService
+	userService       service.UserService
+}
+
+func NewLockoutHandler(protectionSvc service.LoginProtectionService, userSvc service.UserService) *LockoutHandler {
+	return &LockoutHandler{protectionService: protectionSvc, userService: userSvc}
+}
+
+// GetLocked lists accounts and IP addresses that are currently locked out
+func (h *LockoutHandler) GetLocked(c *gin.Context) {
+	locked, err := h.protectionService.GetLocked()
+	if err != nil {
+		response.Error(c, errors.InternalError("Failed to fetch lockouts"))
+		return
+	}
+	response.Success(c, "Lockouts fetched", locked)
+}
+
+// UnlockUser clears the lockout and failure count of a user's account
+func (h *LockoutHandler) UnlockUser(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.BadRequest(c, "Invalid user ID")
+		return
+	}
+
+	user, err := h.userService.GetUserByID(uint(id))
+	if err != nil {
+		response.Error(c, errors.NotFound("User not found"))
+		return
+	}
+
+	if err := h.protectionService.UnlockAccount(user.Email, c.GetUint("user_id")); err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+	response.Success(c, "Account unlocked", nil)
+}
+
+// UnlockIP clears the lockout and failure count of a client IP address
+func (h *LockoutHandler) UnlockIP(c *gin.Context) {
+	if err := h.protectionService.UnlockIP(c.Param("ip"), c.GetUint("user_id")); err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+	response.Success(c, "IP address unlocked", nil)
+}
@@ -1,7 +1,9 @@
 package handlers
 
 import (
-	"school-management-system/internal/models"
+	stderrors "errors"
+	"io"
+	"net/http"
 	"school-management-system/internal/service"
 	"school-management-system/pkg/errors"
 	"school-management-system/pkg/response"
@@ -18,59 +20,100 @@ func NewMessageHandler(svc service.MessageService) *MessageHandler {
 	return &MessageHandler{service: svc}
 }
 
-func (h *MessageHandler) SendMessage(c *gin.Context) {
-	senderID := c.GetUint("user_id")
-	var req struct {
-		ReceiverID uint   `json:"receiver_id" binding:"required"`
-		Content    string `json:"content" binding:"required"`
+// messageError responds to a messaging error, with fallback as the message
+// for unexpected ones
+func messageError(c *gin.Context, err error, fallback string) {
+	switch {
+	case stderrors.Is(err, service.ErrInvalidMessage):
+		response.BadRequest(c, err.Error())
+	case stderrors.Is(err, service.ErrAttachmentTooLarge):
+		response.Error(c, errors.NewAppError("PAYLOAD_TOO_LARGE", err.Error(), http.StatusRequestEntityTooLarge))
+	case stderrors.Is(err, service.ErrMessagingForbidden):
+		response.Error(c, errors.Forbidden(err.Error()))
+	case stderrors.Is(err, service.ErrMessageNotFound):
+		response.Error(c, errors.NotFound("Message not found"))
+	case stderrors.Is(err, service.ErrConversationNotFound):
+		response.Error(c, errors.NotFound("Conversation not found"))
+	case stderrors.Is(err, service.ErrRecipientNotFound), stderrors.Is(err, service.ErrAttachmentNotFound):
+		response.Error(c, errors.NotFound(err.Error()))
+	default:
+		response.Error(c, errors.InternalError(fallback))
+	}
+}
+
+// messagePage reads ?page and ?limit, defaulting to the first 20
+func messagePage(c *gin.Context) (int, int) {
+	page := 1
+	if p := c.Query("page"); p != "" {
+		if parsed, err := strconv.Atoi(p); err == nil && parsed > 0 {
+			page = parsed
+		}
 	}
+	limit := 20
+	if l := c.Query("limit"); l != "" {
+		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 && parsed <= 100 {
+			limit = parsed
+		}
+	}
+	return page, limit
+}
+
+// SendMessage posts to a conversation by conversation_id, or directly to
+// receiver_id. Attach files uploaded to /messages/attachments with
+// attachment_ids.
+func (h *MessageHandler) SendMessage(c *gin.Context) {
+	var req service.SendMessageRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		response.BadRequest(c, err.Error())
 		return
 	}
 
-	msg := &models.Message{
-		SenderID:   senderID,
-		ReceiverID: req.ReceiverID,
-		Content:    req.Content,
-		IsRead:     false,
-	}
-	if err := h.service.Create(msg); err != nil {
-		response.Error(c, errors.InternalError("Failed to send message"))
+	msg, err := h.service.Send(c.GetUint("user_id"), req)
+	if err != nil {
+		messageError(c, err, "Failed to send message")
 		return
 	}
 	response.Created(c, "Message sent", msg)
 }
 
-func (h *MessageHandler) GetInbox(c *gin.Context) {
-	userID := c.GetUint("user_id")
-	page := 1
-	if p := c.Query("page"); p != "" {
-		if parsed, err := strconv.Atoi(p); err == nil {
-			page = parsed
-		}
+// Broadcast posts from a course's teacher to its enrolled students, or to
+// their guardians. Replies come back privately.
+func (h *MessageHandler) Broadcast(c *gin.Context) {
+	var req struct {
+		CourseID      uint   `json:"course_id" binding:"required"`
+		Audience      string `json:"audience" binding:"required"`
+		Content       string `json:"content"`
+		AttachmentIDs []uint `json:"attachment_ids"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, err.Error())
+		return
 	}
-	limit := 20
 
-	msgs, total, err := h.service.GetByReceiverID(userID, page, limit)
+	msg, err := h.service.Broadcast(c.GetUint("user_id"), req.CourseID, req.Audience, req.Content, req.AttachmentIDs)
 	if err != nil {
-		response.Error(c, errors.InternalError("Failed to fetch messages"))
+		messageError(c, err, "Failed to send broadcast")
 		return
 	}
+	response.Created(c, "Broadcast sent", msg)
+}
 
-	response.Paginated(c, "Messages fetched", msgs, page, limit, total)
+// GetInbox lists the user's conversations with their last message and
+// unread count, most recently active first
+func (h *MessageHandler) GetInbox(c *gin.Context) {
+	page, limit := messagePage(c)
+	threads, total, err := h.service.GetInbox(c.GetUint("user_id"), page, limit)
+	if err != nil {
+		response.Error(c, errors.InternalError("Failed to fetch messages"))
+		return
+	}
+	response.Paginated(c, "Messages fetched", threads, page, limit, total)
 }
 
 func (h *MessageHandler) GetConversation(c *gin.Context) {
 	userID := c.GetUint("user_id")
 	otherID, _ := strconv.ParseUint(c.Param("user_id"), 10, 32)
-	page := 1
-	if p := c.Query("page"); p != "" {
-		if parsed, err := strconv.Atoi(p); err == nil {
-			page = parsed
-		}
-	}
-	limit := 20
+	page, limit := messagePage(c)
 
 	msgs, total, err := h.service.GetConversation(userID, uint(otherID), page, limit)
 	if err != nil {
@@ -93,9 +136,185 @@ func (h *MessageHandler) CountUnread(c *gin.Context) {
 
 func (h *MessageHandler) MarkAsRead(c *gin.Context) {
 	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
-	if err := h.service.MarkAsRead(uint(id)); err != nil {
-		response.Error(c, errors.InternalError("Failed to mark as read"))
+	if err := h.service.MarkAsRead(uint(id), c.GetUint("user_id")); err != nil {
+		messageError(c, err, "Failed to mark as read")
+		return
+	}
+	response.NoContent(c)
+}
+
+// Edit replaces the content of one of the user's messages
+func (h *MessageHandler) Edit(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	var req struct {
+		Content string `json:"content"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+
+	msg, err := h.service.Edit(uint(id), c.GetUint("user_id"), req.Content)
+	if err != nil {
+		messageError(c, err, "Failed to edit message")
+		return
+	}
+	response.Success(c, "Message updated", msg)
+}
+
+// Delete removes a message, leaving a placeholder in its thread
+func (h *MessageHandler) Delete(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err := h.service.Delete(uint(id), c.GetUint("user_id")); err != nil {
+		messageError(c, err, "Failed to delete message")
 		return
 	}
 	response.NoContent(c)
 }
+
+// CreateGroup starts a group conversation with the given users
+func (h *MessageHandler) CreateGroup(c *gin.Context) {
+	var req struct {
+		Title          string `json:"title" binding:"required"`
+		ParticipantIDs []uint `json:"participant_ids" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+
+	conversation, err := h.service.CreateGroup(c.GetUint("user_id"), req.Title, req.ParticipantIDs)
+	if err != nil {
+		messageError(c, err, "Failed to create group")
+		return
+	}
+	response.Created(c, "Group created", conversation)
+}
+
+func (h *MessageHandler) GetConversationByID(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	conversation, err := h.service.GetConversationByID(uint(id), c.GetUint("user_id"))
+	if err != nil {
+		messageError(c, err, "Failed to fetch conversation")
+		return
+	}
+	response.Success(c, "Conversation fetched", conversation)
+}
+
+// GetMessages lists a conversation's messages, newest first
+func (h *MessageHandler) GetMessages(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	page, limit := messagePage(c)
+
+	msgs, total, err := h.service.GetMessages(uint(id), c.GetUint("user_id"), page, limit)
+	if err != nil {
+		messageError(c, err, "Failed to fetch messages")
+		return
+	}
+	response.Paginated(c, "Messages fetched", msgs, page, limit, total)
+}
+
+// MarkConversationRead marks everything in a conversation read
+func (h *MessageHandler) MarkConversationRead(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err := h.service.MarkConversationRead(uint(id), c.GetUint("user_id")); err != nil {
+		messageError(c, err, "Failed to mark as read")
+		return
+	}
+	response.NoContent(c)
+}
+
+// AddParticipants adds users to a group the user owns
+func (h *MessageHandler) AddParticipants(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	var req struct {
+		UserIDs []uint `json:"user_ids" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+
+	userID := c.GetUint("user_id")
+	if err := h.service.AddParticipants(uint(id), userID, req.UserIDs); err != nil {
+		messageError(c, err, "Failed to add participants")
+		return
+	}
+	conversation, err := h.service.GetConversationByID(uint(id), userID)
+	if err != nil {
+		messageError(c, err, "Failed to fetch conversation")
+		return
+	}
+	response.Success(c, "Participants added", conversation)
+}
+
+// RemoveParticipant removes a user from a group, or lets the user leave
+func (h *MessageHandler) RemoveParticipant(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	userID, _ := strconv.ParseUint(c.Param("user_id"), 10, 32)
+	if err := h.service.RemoveParticipant(uint(id), c.GetUint("user_id"), uint(userID)); err != nil {
+		messageError(c, err, "Failed to remove participant")
+		return
+	}
+	response.NoContent(c)
+}
+
+// GetCourseChannel returns the channel of a course the user teaches or is
+// enrolled in
+func (h *MessageHandler) GetCourseChannel(c *gin.Context) {
+	courseID, _ := strconv.ParseUint(c.Param("course_id"), 10, 32)
+	conversation, err := h.service.GetCourseChannel(uint(courseID), c.GetUint("user_id"))
+	if err != nil {
+		messageError(c, err, "Failed to fetch course channel")
+		return
+	}
+	response.Success(c, "Course channel fetched", conversation)
+}
+
+// UploadAttachment stores the multipart form field "file" for sending with
+// a message
+func (h *MessageHandler) UploadAttachment(c *gin.Context) {
+	header, err := c.FormFile("file")
+	if err != nil {
+		response.BadRequest(c, "file is required")
+		return
+	}
+	limit := h.service.MaxAttachmentSize()
+	if header.Size > limit {
+		messageError(c, service.ErrAttachmentTooLarge, "")
+		return
+	}
+	file, err := header.Open()
+	if err != nil {
+		response.BadRequest(c, "failed to read file")
+		return
+	}
+	defer file.Close()
+	data, err := io.ReadAll(io.LimitReader(file, limit+1))
+	if err != nil {
+		response.BadRequest(c, "failed to read file")
+		return
+	}
+
+	attachment, err := h.service.UploadAttachment(c.GetUint("user_id"), header.Filename, data)
+	if err != nil {
+		messageError(c, err, "Failed to store attachment")
+		return
+	}
+	response.Created(c, "Attachment uploaded", attachment)
+}
+
+// DownloadAttachment sends an attachment as a download. It is never shown
+// inline, so an uploaded page cannot run in the application's origin.
+func (h *MessageHandler) DownloadAttachment(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	attachment, path, err := h.service.GetAttachment(uint(id), c.GetUint("user_id"))
+	if err != nil {
+		messageError(c, err, "Failed to fetch attachment")
+		return
+	}
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Header("Content-Security-Policy", "default-src 'none'; sandbox")
+	c.Header("Content-Type", attachment.ContentType)
+	c.FileAttachment(path, attachment.FileName)
+}
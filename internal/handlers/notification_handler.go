@@ -1,6 +1,8 @@
 package handlers
 
 import (
+	stderrors "errors"
+	"school-management-system/internal/authz"
 	"school-management-system/internal/models"
 	"school-management-system/internal/service"
 	"school-management-system/pkg/errors"
@@ -54,7 +56,12 @@ func (h *NotificationHandler) GetUnread(c *gin.Context) {
 
 func (h *NotificationHandler) MarkAsRead(c *gin.Context) {
 	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
-	if err := h.service.MarkAsRead(uint(id)); err != nil {
+	err := h.service.MarkAsRead(uint(id), c.GetUint("user_id"))
+	if stderrors.Is(err, service.ErrNotificationNotFound) {
+		response.Error(c, errors.NotFound("Notification not found"))
+		return
+	}
+	if err != nil {
 		response.Error(c, errors.InternalError("Failed to mark notification as read"))
 		return
 	}
@@ -90,6 +97,15 @@ func (h *NotificationHandler) Create(c *gin.Context) {
 		response.BadRequest(c, err.Error())
 		return
 	}
+	switch req.Type {
+	case models.ChannelInApp, models.ChannelEmail, models.ChannelSMS, models.ChannelWebhook:
+	default:
+		response.BadRequest(c, "type must be in-app, email, sms or webhook")
+		return
+	}
+	if !authz.Authorize(c, authz.ActionCreate, authz.Resource{Kind: authz.KindNotification, OwnerUserID: req.UserID}) {
+		return
+	}
 
 	notif := &models.Notification{
 		UserID:  req.UserID,
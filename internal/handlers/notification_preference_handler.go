@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	stderrors "errors"
+	"school-management-system/internal/models"
+	"school-management-system/internal/service"
+	"school-management-system/pkg/errors"
+	"school-management-system/pkg/response"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type NotificationPreferenceHandler struct {
+	dispatcher service.NotificationDispatcher
+}
+
+func NewNotificationPreferenceHandler(dispatcher service.NotificationDispatcher) *NotificationPreferenceHandler {
+	return &NotificationPreferenceHandler{dispatcher: dispatcher}
+}
+
+// GetMine returns the current user's quiet hours, webhook and the channels
+// each event is sent on
+func (h *NotificationPreferenceHandler) GetMine(c *gin.Context) {
+	prefs, err := h.dispatcher.GetPreferences(c.GetUint("user_id"))
+	if err != nil {
+		response.Error(c, errors.InternalError("Failed to fetch notification preferences"))
+		return
+	}
+	response.Success(c, "Notification preferences fetched", prefs)
+}
+
+// UpdateMine changes the current user's notification preferences. Only
+// the fields and events given are changed.
+func (h *NotificationPreferenceHandler) UpdateMine(c *gin.Context) {
+	var req service.NotificationPreferencesUpdate
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+
+	prefs, err := h.dispatcher.UpdatePreferences(c.GetUint("user_id"), &req)
+	if stderrors.Is(err, service.ErrInvalidNotificationPreference) {
+		response.BadRequest(c, err.Error())
+		return
+	}
+	if err != nil {
+		response.Error(c, errors.InternalError("Failed to update notification preferences"))
+		return
+	}
+	response.Success(c, "Notification preferences updated", prefs)
+}
+
+// GetDeliveries lists email, SMS and webhook deliveries, newest first.
+// Filter with ?status=pending|sent|failed|skipped.
+func (h *NotificationPreferenceHandler) GetDeliveries(c *gin.Context) {
+	page := 1
+	if p := c.Query("page"); p != "" {
+		if parsed, err := strconv.Atoi(p); err == nil && parsed > 0 {
+			page = parsed
+		}
+	}
+	limit := 20
+	if l := c.Query("limit"); l != "" {
+		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 && parsed <= 100 {
+			limit = parsed
+		}
+	}
+
+	status := c.Query("status")
+	switch status {
+	case "", models.DeliveryStatusPending, models.DeliveryStatusSent, models.DeliveryStatusFailed, models.DeliveryStatusSkipped:
+	default:
+		response.BadRequest(c, "status must be pending, sent, failed or skipped")
+		return
+	}
+
+	deliveries, total, err := h.dispatcher.GetDeliveries(status, page, limit)
+	if err != nil {
+		response.Error(c, errors.InternalError("Failed to fetch notification deliveries"))
+		return
+	}
+	response.Paginated(c, "Notification deliveries fetched", deliveries, page, limit, total)
+}
@@ -40,7 +40,7 @@ func (h *PaymentHandler) Create(c *gin.Context) {
 		PaymentMethod: req.PaymentMethod,
 	}
 
-	if err := h.service.Create(payment); err != nil {
+	if err := h.service.Create(c.Request.Context(), payment); err != nil {
 		response.Error(c, errors.InternalError("Failed to create payment"))
 		return
 	}
@@ -111,7 +111,7 @@ func (h *PaymentHandler) Update(c *gin.Context) {
 		payment.TransactionID = req.TransactionID
 	}
 
-	if err := h.service.Update(payment); err != nil {
+	if err := h.service.Update(c.Request.Context(), payment); err != nil {
 		response.Error(c, errors.InternalError("Failed to update payment"))
 		return
 	}
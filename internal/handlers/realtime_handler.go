@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"school-management-system/internal/realtime"
+	"school-management-system/internal/service"
+	"school-management-system/pkg/errors"
+	"school-management-system/pkg/response"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type RealtimeHandler struct {
+	service   service.RealtimeService
+	heartbeat time.Duration
+}
+
+// NewRealtimeHandler creates the stream handler, which sends a heartbeat
+// comment after each quiet heartbeat interval so proxies keep the
+// connection open
+func NewRealtimeHandler(svc service.RealtimeService, heartbeat time.Duration) *RealtimeHandler {
+	if heartbeat <= 0 {
+		heartbeat = 25 * time.Second
+	}
+	return &RealtimeHandler{service: svc, heartbeat: heartbeat}
+}
+
+// Stream pushes the user's events as Server-Sent Events until the client
+// disconnects. Clients resume after a reconnect by sending the id of the
+// last event they received in the Last-Event-ID header, or the
+// last_event_id query parameter.
+func (h *RealtimeHandler) Stream(c *gin.Context) {
+	var lastEventID uint64
+	last := c.GetHeader("Last-Event-ID")
+	if last == "" {
+		last = c.Query("last_event_id")
+	}
+	if last != "" {
+		parsed, err := strconv.ParseUint(last, 10, 64)
+		if err != nil {
+			response.BadRequest(c, "Invalid Last-Event-ID")
+			return
+		}
+		lastEventID = parsed
+	}
+
+	role, _ := c.Get("user_role")
+	roleName, _ := role.(string)
+	client, replay, err := h.service.Connect(c.GetUint("user_id"), roleName, lastEventID)
+	if err != nil {
+		response.Error(c, errors.InternalError("Failed to open event stream"))
+		return
+	}
+	defer h.service.Disconnect(client)
+
+	// The server's write timeout would otherwise end the stream
+	controller := http.NewResponseController(c.Writer)
+	_ = controller.SetWriteDeadline(time.Time{})
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	if _, err := fmt.Fprint(c.Writer, "retry: 3000\n\n"); err != nil {
+		return
+	}
+	var replayed uint64
+	for _, event := range replay {
+		if writeSSE(c.Writer, event) != nil {
+			return
+		}
+		replayed = event.ID
+	}
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(h.heartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case <-client.Done():
+			// Dropped for falling behind; the client reconnects and resumes
+			return
+		case event := <-client.Events():
+			if event.ID <= replayed {
+				continue
+			}
+			if writeSSE(c.Writer, event) != nil {
+				return
+			}
+			c.Writer.Flush()
+			heartbeat.Reset(h.heartbeat)
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(c.Writer, ": heartbeat\n\n"); err != nil {
+				return
+			}
+			c.Writer.Flush()
+		}
+	}
+}
+
+func writeSSE(w gin.ResponseWriter, event realtime.Event) error {
+	_, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, event.Data)
+	return err
+}
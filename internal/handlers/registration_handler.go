@@ -0,0 +1,245 @@
+package handlers
+
+import (
+	stderrors "errors"
+	"net/http"
+	"school-management-system/internal/models"
+	"school-management-system/internal/repository"
+	"school-management-system/internal/service"
+	"school-management-system/pkg/errors"
+	"school-management-system/pkg/response"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type RegistrationHandler struct {
+	service        service.RegistrationService
+	studentService service.StudentService
+}
+
+func NewRegistrationHandler(svc service.RegistrationService, studentService service.StudentService) *RegistrationHandler {
+	return &RegistrationHandler{service: svc, studentService: studentService}
+}
+
+// registrationError responds to a registration error, with fallback as the
+// message for unexpected ones
+func registrationError(c *gin.Context, err error, fallback string) {
+	var rejection *service.EnrollmentRejectedError
+	switch {
+	case stderrors.As(err, &rejection):
+		response.Error(c, errors.NewAppError("ENROLLMENT_REJECTED", err.Error(), http.StatusUnprocessableEntity).
+			WithDetails(rejection.Reasons))
+	case stderrors.Is(err, service.ErrRegistrationInvalid):
+		response.BadRequest(c, err.Error())
+	case stderrors.Is(err, service.ErrCartItemNotFound), stderrors.Is(err, service.ErrRegistrationMissing),
+		stderrors.Is(err, service.ErrNoOpenTerm):
+		response.Error(c, errors.NotFound(err.Error()))
+	default:
+		response.Error(c, errors.InternalError(fallback))
+	}
+}
+
+// currentStudent returns the student record of the signed in user,
+// responding with 404 if there is none
+func (h *RegistrationHandler) currentStudent(c *gin.Context) (*models.Student, bool) {
+	student, err := h.studentService.GetStudentByUserID(c.GetUint("user_id"))
+	if err != nil {
+		response.Error(c, errors.NotFound("Student record not found"))
+		return nil, false
+	}
+	return student, true
+}
+
+// Catalog lists the open sections of ?term_id, or of the term whose
+// registration is open, with the seats each has left. ?search matches
+// course codes and names; ?department narrows to one department.
+func (h *RegistrationHandler) Catalog(c *gin.Context) {
+	page, limit := messagePage(c)
+	termID, _ := strconv.ParseUint(c.Query("term_id"), 10, 32)
+	filter := repository.CatalogFilter{TermID: uint(termID), Search: c.Query("search"), Department: c.Query("department")}
+
+	term, entries, total, err := h.service.Catalog(filter, page, limit, time.Now())
+	if err != nil {
+		registrationError(c, err, "Failed to fetch catalog")
+		return
+	}
+	response.Paginated(c, "Catalog for "+term.Name+" fetched", entries, page, limit, total)
+}
+
+func (h *RegistrationHandler) GetCart(c *gin.Context) {
+	student, ok := h.currentStudent(c)
+	if !ok {
+		return
+	}
+	items, err := h.service.GetCart(student.ID)
+	if err != nil {
+		registrationError(c, err, "Failed to fetch cart")
+		return
+	}
+	response.Success(c, "Cart fetched", items)
+}
+
+func (h *RegistrationHandler) AddToCart(c *gin.Context) {
+	student, ok := h.currentStudent(c)
+	if !ok {
+		return
+	}
+	var req struct {
+		SectionID uint `json:"section_id" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "Invalid input: "+err.Error())
+		return
+	}
+
+	item, err := h.service.AddToCart(student.ID, req.SectionID)
+	if err != nil {
+		registrationError(c, err, "Failed to add section to cart")
+		return
+	}
+	response.Created(c, "Section added to cart", item)
+}
+
+func (h *RegistrationHandler) RemoveFromCart(c *gin.Context) {
+	student, ok := h.currentStudent(c)
+	if !ok {
+		return
+	}
+	sectionID, err := strconv.ParseUint(c.Param("section_id"), 10, 32)
+	if err != nil {
+		response.BadRequest(c, "Invalid section ID")
+		return
+	}
+	if err := h.service.RemoveFromCart(student.ID, uint(sectionID)); err != nil {
+		registrationError(c, err, "Failed to remove section from cart")
+		return
+	}
+	response.NoContent(c)
+}
+
+// registrationRequest is the body of validate and register. Full sections
+// are waitlisted unless waitlist is false, when they fail registration.
+type registrationRequest struct {
+	Waitlist *bool `json:"waitlist"`
+}
+
+func (r *registrationRequest) allowWaitlist() bool {
+	return r.Waitlist == nil || *r.Waitlist
+}
+
+// Validate checks the student's cart against every registration rule
+// without registering
+func (h *RegistrationHandler) Validate(c *gin.Context) {
+	student, ok := h.currentStudent(c)
+	if !ok {
+		return
+	}
+	var req registrationRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			response.BadRequest(c, "Invalid input: "+err.Error())
+			return
+		}
+	}
+
+	result, err := h.service.Validate(student.ID, req.allowWaitlist(), time.Now())
+	if err != nil {
+		registrationError(c, err, "Failed to validate cart")
+		return
+	}
+	response.Success(c, "Cart validated", result)
+}
+
+// Register enrolls the student in every section of the cart, or, if any of
+// them breaks a rule, in none, responding 422 with the result of validating
+func (h *RegistrationHandler) Register(c *gin.Context) {
+	student, ok := h.currentStudent(c)
+	if !ok {
+		return
+	}
+	var req registrationRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			response.BadRequest(c, "Invalid input: "+err.Error())
+			return
+		}
+	}
+
+	result, err := h.service.Register(c.Request.Context(), student.ID, req.allowWaitlist(), c.GetUint("user_id"), time.Now())
+	if stderrors.Is(err, service.ErrRegistrationInvalid) && result != nil {
+		response.Error(c, errors.NewAppError("REGISTRATION_REJECTED", "Registration rejected, nothing was registered",
+			http.StatusUnprocessableEntity).WithDetails(result))
+		return
+	}
+	if err != nil {
+		registrationError(c, err, "Failed to register")
+		return
+	}
+	response.Created(c, "Registered", result)
+}
+
+// Override enrolls a student in a section past every registration rule,
+// for the reason given
+func (h *RegistrationHandler) Override(c *gin.Context) {
+	var req struct {
+		StudentID uint   `json:"student_id" binding:"required"`
+		SectionID uint   `json:"section_id" binding:"required"`
+		Reason    string `json:"reason" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "Invalid input: "+err.Error())
+		return
+	}
+
+	enrollment, err := h.service.Override(c.Request.Context(), req.StudentID, req.SectionID, c.GetUint("user_id"), req.Reason)
+	if err != nil {
+		if err.Error() == "section not found" {
+			response.Error(c, errors.NotFound("Section not found"))
+			return
+		}
+		registrationError(c, err, "Failed to enroll student")
+		return
+	}
+	response.Created(c, "Student enrolled by override", enrollment)
+}
+
+// SetPriority sets when a student may start registering for a term,
+// instead of the term's registration start
+func (h *RegistrationHandler) SetPriority(c *gin.Context) {
+	var req struct {
+		TermID    uint      `json:"term_id" binding:"required"`
+		StudentID uint      `json:"student_id" binding:"required"`
+		OpensAt   time.Time `json:"opens_at" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "Invalid input: "+err.Error())
+		return
+	}
+
+	priority := &models.RegistrationPriority{TermID: req.TermID, StudentID: req.StudentID, OpensAt: req.OpensAt}
+	if err := h.service.SetPriority(priority); err != nil {
+		registrationError(c, err, "Failed to set registration priority")
+		return
+	}
+	response.Success(c, "Registration priority set", priority)
+}
+
+func (h *RegistrationHandler) DeletePriority(c *gin.Context) {
+	termID, err := strconv.ParseUint(c.Param("term_id"), 10, 32)
+	if err != nil {
+		response.BadRequest(c, "Invalid term ID")
+		return
+	}
+	studentID, err := strconv.ParseUint(c.Param("student_id"), 10, 32)
+	if err != nil {
+		response.BadRequest(c, "Invalid student ID")
+		return
+	}
+	if err := h.service.DeletePriority(uint(termID), uint(studentID)); err != nil {
+		registrationError(c, err, "Failed to delete registration priority")
+		return
+	}
+	response.NoContent(c)
+}
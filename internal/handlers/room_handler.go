@@ -0,0 +1,462 @@
+package handlers
+
+import (
+	stderrors "errors"
+	"net/http"
+	"school-management-system/internal/models"
+	"school-management-system/internal/repository"
+	"school-management-system/internal/service"
+	"school-management-system/pkg/errors"
+	"school-management-system/pkg/response"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type RoomHandler struct {
+	service service.RoomService
+}
+
+func NewRoomHandler(svc service.RoomService) *RoomHandler {
+	return &RoomHandler{service: svc}
+}
+
+// roomError responds to a room, resource or booking error, with fallback
+// as the message for unexpected ones
+func roomError(c *gin.Context, err error, fallback string) {
+	var conflict *service.BookingConflictError
+	switch {
+	case stderrors.As(err, &conflict):
+		response.Error(c, errors.NewAppError("BOOKING_CONFLICT", err.Error(), http.StatusConflict).
+			WithDetails(conflict.Conflicts))
+	case stderrors.Is(err, service.ErrInvalidRoom), stderrors.Is(err, service.ErrInvalidResource),
+		stderrors.Is(err, service.ErrInvalidBooking):
+		response.BadRequest(c, err.Error())
+	case stderrors.Is(err, service.ErrRoomNotFound), stderrors.Is(err, service.ErrResourceNotFound),
+		stderrors.Is(err, service.ErrBookingNotFound), stderrors.Is(err, service.ErrTimetableMissing):
+		response.Error(c, errors.NotFound(err.Error()))
+	case stderrors.Is(err, service.ErrBookingCancelled):
+		response.Error(c, errors.Conflict(err.Error()))
+	default:
+		response.Error(c, errors.InternalError(fallback))
+	}
+}
+
+// List returns the room registry, only active rooms with ?active=true
+func (h *RoomHandler) List(c *gin.Context) {
+	rooms, err := h.service.List(c.Query("active") == "true")
+	if err != nil {
+		roomError(c, err, "Failed to fetch rooms")
+		return
+	}
+	response.Success(c, "Rooms fetched", rooms)
+}
+
+func (h *RoomHandler) GetByID(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.BadRequest(c, "Invalid room ID")
+		return
+	}
+	room, err := h.service.GetByID(uint(id))
+	if err != nil {
+		roomError(c, err, "Failed to fetch room")
+		return
+	}
+	response.Success(c, "Room fetched", room)
+}
+
+func (h *RoomHandler) Create(c *gin.Context) {
+	var req struct {
+		Name     string `json:"name" binding:"required"`
+		Building string `json:"building"`
+		Capacity int    `json:"capacity"`
+		Features string `json:"features"`
+		IsActive *bool  `json:"is_active"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "Invalid input: "+err.Error())
+		return
+	}
+
+	room := &models.Room{Name: req.Name, Building: req.Building, Capacity: req.Capacity, Features: req.Features, IsActive: true}
+	if req.IsActive != nil {
+		room.IsActive = *req.IsActive
+	}
+	if err := h.service.Create(room); err != nil {
+		roomError(c, err, "Failed to create room")
+		return
+	}
+	response.Created(c, "Room created", room)
+}
+
+func (h *RoomHandler) Update(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.BadRequest(c, "Invalid room ID")
+		return
+	}
+	var req struct {
+		Name     *string `json:"name"`
+		Building *string `json:"building"`
+		Capacity *int    `json:"capacity"`
+		Features *string `json:"features"`
+		IsActive *bool   `json:"is_active"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "Invalid input: "+err.Error())
+		return
+	}
+
+	room, err := h.service.GetByID(uint(id))
+	if err != nil {
+		roomError(c, err, "Failed to update room")
+		return
+	}
+	if req.Name != nil {
+		room.Name = *req.Name
+	}
+	if req.Building != nil {
+		room.Building = *req.Building
+	}
+	if req.Capacity != nil {
+		room.Capacity = *req.Capacity
+	}
+	if req.Features != nil {
+		room.Features = *req.Features
+	}
+	if req.IsActive != nil {
+		room.IsActive = *req.IsActive
+	}
+	if err := h.service.Update(room); err != nil {
+		roomError(c, err, "Failed to update room")
+		return
+	}
+	response.Success(c, "Room updated", room)
+}
+
+func (h *RoomHandler) Delete(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.BadRequest(c, "Invalid room ID")
+		return
+	}
+	if err := h.service.Delete(uint(id)); err != nil {
+		roomError(c, err, "Failed to delete room")
+		return
+	}
+	response.NoContent(c)
+}
+
+// queryUint parses an optional ID query parameter, responding 400 if it is
+// not a number
+func queryUint(c *gin.Context, name string) (uint, bool) {
+	value := c.Query(name)
+	if value == "" {
+		return 0, true
+	}
+	id, err := strconv.ParseUint(value, 10, 32)
+	if err != nil {
+		response.BadRequest(c, "Invalid "+name)
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// queryDate parses an optional YYYY-MM-DD query parameter, responding 400
+// if it is not a date
+func queryDate(c *gin.Context, name string) (time.Time, bool) {
+	value := c.Query(name)
+	if value == "" {
+		return time.Time{}, true
+	}
+	date, err := service.ParseBookingDate(value)
+	if err != nil {
+		response.BadRequest(c, "Invalid "+name+": "+err.Error())
+		return time.Time{}, false
+	}
+	return date, true
+}
+
+// FindFree lists the active rooms free from ?start_time to ?end_time on a
+// ?date, or every week on ?day_of_week of ?term_id, smallest first.
+// ?capacity, ?features and ?building narrow the rooms.
+func (h *RoomHandler) FindFree(c *gin.Context) {
+	date, ok := queryDate(c, "date")
+	if !ok {
+		return
+	}
+	termID, ok := queryUint(c, "term_id")
+	if !ok {
+		return
+	}
+	capacity, _ := strconv.Atoi(c.Query("capacity"))
+
+	rooms, err := h.service.FindFreeRooms(service.FreeRoomQuery{
+		Date:        date,
+		DayOfWeek:   c.Query("day_of_week"),
+		TermID:      termID,
+		StartTime:   c.Query("start_time"),
+		EndTime:     c.Query("end_time"),
+		MinCapacity: capacity,
+		Features:    c.Query("features"),
+		Building:    c.Query("building"),
+	})
+	if err != nil {
+		roomError(c, err, "Failed to search rooms")
+		return
+	}
+	response.Success(c, "Free rooms fetched", rooms)
+}
+
+// Utilization reports how much each room is used by the timetable of
+// ?term_id, and by bookings from ?from to ?to, the term's dates by default
+func (h *RoomHandler) Utilization(c *gin.Context) {
+	termID, ok := queryUint(c, "term_id")
+	if !ok {
+		return
+	}
+	if termID == 0 {
+		response.BadRequest(c, "term_id is required")
+		return
+	}
+	from, ok := queryDate(c, "from")
+	if !ok {
+		return
+	}
+	to, ok := queryDate(c, "to")
+	if !ok {
+		return
+	}
+
+	report, err := h.service.Utilization(termID, from, to)
+	if err != nil {
+		roomError(c, err, "Failed to build utilization report")
+		return
+	}
+	response.Success(c, "Room utilization fetched", report)
+}
+
+// ListResources returns the bookable resources, only those of ?type or
+// kept in ?room_id when given
+func (h *RoomHandler) ListResources(c *gin.Context) {
+	roomID, ok := queryUint(c, "room_id")
+	if !ok {
+		return
+	}
+	resources, err := h.service.ListResources(c.Query("type"), roomID)
+	if err != nil {
+		roomError(c, err, "Failed to fetch resources")
+		return
+	}
+	response.Success(c, "Resources fetched", resources)
+}
+
+func (h *RoomHandler) GetResource(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.BadRequest(c, "Invalid resource ID")
+		return
+	}
+	resource, err := h.service.GetResource(uint(id))
+	if err != nil {
+		roomError(c, err, "Failed to fetch resource")
+		return
+	}
+	response.Success(c, "Resource fetched", resource)
+}
+
+func (h *RoomHandler) CreateResource(c *gin.Context) {
+	var req struct {
+		Name     string `json:"name" binding:"required"`
+		Type     string `json:"type"`
+		RoomID   *uint  `json:"room_id"`
+		Quantity *int   `json:"quantity"`
+		IsActive *bool  `json:"is_active"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "Invalid input: "+err.Error())
+		return
+	}
+
+	resource := &models.Resource{Name: req.Name, Type: req.Type, RoomID: req.RoomID, Quantity: 1, IsActive: true}
+	if req.Quantity != nil {
+		resource.Quantity = *req.Quantity
+	}
+	if req.IsActive != nil {
+		resource.IsActive = *req.IsActive
+	}
+	if err := h.service.CreateResource(resource); err != nil {
+		roomError(c, err, "Failed to create resource")
+		return
+	}
+	response.Created(c, "Resource created", resource)
+}
+
+// UpdateResource changes a resource; a room_id of 0 means it is no longer
+// kept in a room
+func (h *RoomHandler) UpdateResource(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.BadRequest(c, "Invalid resource ID")
+		return
+	}
+	var req struct {
+		Name     *string `json:"name"`
+		Type     *string `json:"type"`
+		RoomID   *uint   `json:"room_id"`
+		Quantity *int    `json:"quantity"`
+		IsActive *bool   `json:"is_active"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "Invalid input: "+err.Error())
+		return
+	}
+
+	resource, err := h.service.GetResource(uint(id))
+	if err != nil {
+		roomError(c, err, "Failed to update resource")
+		return
+	}
+	if req.Name != nil {
+		resource.Name = *req.Name
+	}
+	if req.Type != nil {
+		resource.Type = *req.Type
+	}
+	if req.RoomID != nil {
+		resource.RoomID, resource.Room = req.RoomID, nil
+	}
+	if req.Quantity != nil {
+		resource.Quantity = *req.Quantity
+	}
+	if req.IsActive != nil {
+		resource.IsActive = *req.IsActive
+	}
+	if err := h.service.UpdateResource(resource); err != nil {
+		roomError(c, err, "Failed to update resource")
+		return
+	}
+	response.Success(c, "Resource updated", resource)
+}
+
+func (h *RoomHandler) DeleteResource(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.BadRequest(c, "Invalid resource ID")
+		return
+	}
+	if err := h.service.DeleteResource(uint(id)); err != nil {
+		roomError(c, err, "Failed to delete resource")
+		return
+	}
+	response.NoContent(c)
+}
+
+// ListBookings returns bookings by date, filtered by ?room_id,
+// ?resource_id and ?from and ?to dates. ?mine=true returns only the
+// current user's, and ?include_cancelled=true cancelled ones too.
+func (h *RoomHandler) ListBookings(c *gin.Context) {
+	page, limit := messagePage(c)
+	var filter repository.BookingFilter
+	var ok bool
+	if filter.RoomID, ok = queryUint(c, "room_id"); !ok {
+		return
+	}
+	if filter.ResourceID, ok = queryUint(c, "resource_id"); !ok {
+		return
+	}
+	if filter.From, ok = queryDate(c, "from"); !ok {
+		return
+	}
+	if filter.To, ok = queryDate(c, "to"); !ok {
+		return
+	}
+	if c.Query("mine") == "true" {
+		filter.BookedBy = c.GetUint("user_id")
+	}
+	filter.IncludeCancelled = c.Query("include_cancelled") == "true"
+
+	bookings, total, err := h.service.ListBookings(filter, page, limit)
+	if err != nil {
+		roomError(c, err, "Failed to fetch bookings")
+		return
+	}
+	response.Paginated(c, "Bookings fetched", bookings, page, limit, total)
+}
+
+func (h *RoomHandler) GetBooking(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.BadRequest(c, "Invalid booking ID")
+		return
+	}
+	booking, err := h.service.GetBooking(uint(id))
+	if err != nil {
+		roomError(c, err, "Failed to fetch booking")
+		return
+	}
+	response.Success(c, "Booking fetched", booking)
+}
+
+// CreateBooking books a room, or a quantity of a resource, on a date,
+// responding 409 with what it clashes with if it is taken
+func (h *RoomHandler) CreateBooking(c *gin.Context) {
+	var req struct {
+		RoomID     *uint  `json:"room_id"`
+		ResourceID *uint  `json:"resource_id"`
+		Quantity   int    `json:"quantity"`
+		Title      string `json:"title" binding:"required"`
+		Purpose    string `json:"purpose"`
+		Attendees  int    `json:"attendees"`
+		Date       string `json:"date" binding:"required"`
+		StartTime  string `json:"start_time" binding:"required"`
+		EndTime    string `json:"end_time" binding:"required"`
+		Notes      string `json:"notes"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "Invalid input: "+err.Error())
+		return
+	}
+	date, err := service.ParseBookingDate(req.Date)
+	if err != nil {
+		roomError(c, err, "Failed to create booking")
+		return
+	}
+
+	booking := &models.Booking{
+		RoomID:     req.RoomID,
+		ResourceID: req.ResourceID,
+		Quantity:   req.Quantity,
+		Title:      req.Title,
+		Purpose:    req.Purpose,
+		Attendees:  req.Attendees,
+		Date:       date,
+		StartTime:  req.StartTime,
+		EndTime:    req.EndTime,
+		Notes:      req.Notes,
+		BookedBy:   c.GetUint("user_id"),
+	}
+	if err := h.service.Book(booking); err != nil {
+		roomError(c, err, "Failed to create booking")
+		return
+	}
+	response.Created(c, "Booking created", booking)
+}
+
+// CancelBooking frees what a booking reserved; the booking is kept,
+// cancelled
+func (h *RoomHandler) CancelBooking(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.BadRequest(c, "Invalid booking ID")
+		return
+	}
+	booking, err := h.service.CancelBooking(uint(id), c.GetUint("user_id"))
+	if err != nil {
+		roomError(c, err, "Failed to cancel booking")
+		return
+	}
+	response.Success(c, "Booking cancelled", booking)
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"school-management-system/internal/authz"
 	"school-management-system/internal/models"
 	"school-management-system/internal/repository"
 	"school-management-system/pkg/response"
@@ -36,6 +37,10 @@ func (h *RubricHandler) CreateRubric(c *gin.Context) {
 		return
 	}
 
+	if !authz.AuthorizeRecord(c, authz.ActionUpdate, authz.KindAssignment, req.AssignmentID) {
+		return
+	}
+
 	rubric := &models.AssignmentRubric{
 		AssignmentID: req.AssignmentID,
 		Name:         req.Name,
@@ -158,6 +163,10 @@ func (h *RubricHandler) ScoreSubmission(c *gin.Context) {
 		return
 	}
 
+	if !authz.AuthorizeRecord(c, authz.ActionGrade, authz.KindSubmission, uint(submissionID)) {
+		return
+	}
+
 	score := &models.RubricScore{
 		SubmissionID:      uint(submissionID),
 		RubricID:          req.RubricID,
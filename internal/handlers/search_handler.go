@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"school-management-system/internal/models"
 	"school-management-system/internal/service"
 	"school-management-system/pkg/response"
 	"strconv"
@@ -28,8 +29,13 @@ func (h *SearchHandler) SearchAnnouncements(c *gin.Context) {
 		}
 	}
 	limit := 20
+	// Only admins search every announcement; others search those they received
+	var recipientID uint
+	if role, _ := c.Get("user_role"); role != string(models.RoleAdmin) {
+		recipientID = c.GetUint("user_id")
+	}
 
-	announcements, total, err := h.searchService.SearchAnnouncementsAdvanced(query, audience, priority, page, limit)
+	announcements, total, err := h.searchService.SearchAnnouncementsAdvanced(recipientID, query, audience, priority, page, limit)
 	if err != nil {
 		response.Error(c, err)
 		return
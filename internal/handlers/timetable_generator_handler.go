@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"school-management-system/internal/service"
+	"school-management-system/pkg/response"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type TimetableGeneratorHandler struct {
+	service service.TimetableGeneratorService
+}
+
+func NewTimetableGeneratorHandler(svc service.TimetableGeneratorService) *TimetableGeneratorHandler {
+	return &TimetableGeneratorHandler{service: svc}
+}
+
+// Generate solves a timetable for a term's sections and saves it as a
+// draft to preview; nothing in the timetable changes until it is committed
+func (h *TimetableGeneratorHandler) Generate(c *gin.Context) {
+	var req service.GenerateTimetableOptions
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "Invalid input: "+err.Error())
+		return
+	}
+	if req.TermID == 0 {
+		response.BadRequest(c, "term_id is required")
+		return
+	}
+
+	preview, err := h.service.Generate(req, c.GetUint("user_id"))
+	if err != nil {
+		timetableError(c, err, "Failed to generate timetable")
+		return
+	}
+	response.Created(c, "Timetable draft generated", preview)
+}
+
+func (h *TimetableGeneratorHandler) GetDraft(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.BadRequest(c, "Invalid draft ID")
+		return
+	}
+	preview, err := h.service.GetDraft(uint(id))
+	if err != nil {
+		timetableError(c, err, "Failed to fetch timetable draft")
+		return
+	}
+	response.Success(c, "Timetable draft fetched", preview)
+}
+
+// Commit replaces the timetable of the draft's sections with the draft.
+// Drafts leaving classes unplaced need "allow_partial": true.
+func (h *TimetableGeneratorHandler) Commit(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.BadRequest(c, "Invalid draft ID")
+		return
+	}
+	var req struct {
+		AllowPartial bool `json:"allow_partial"`
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			response.BadRequest(c, "Invalid input: "+err.Error())
+			return
+		}
+	}
+
+	preview, err := h.service.Commit(uint(id), req.AllowPartial, c.GetUint("user_id"))
+	if err != nil {
+		timetableError(c, err, "Failed to commit timetable draft")
+		return
+	}
+	response.Success(c, "Timetable draft committed", preview)
+}
+
+func (h *TimetableGeneratorHandler) Discard(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.BadRequest(c, "Invalid draft ID")
+		return
+	}
+	if err := h.service.Discard(uint(id)); err != nil {
+		timetableError(c, err, "Failed to discard timetable draft")
+		return
+	}
+	response.NoContent(c)
+}
+
+// Conflicts reports every rule the timetable of ?term_id breaks
+func (h *TimetableGeneratorHandler) Conflicts(c *gin.Context) {
+	termID, err := strconv.ParseUint(c.Query("term_id"), 10, 32)
+	if err != nil || termID == 0 {
+		response.BadRequest(c, "term_id is required")
+		return
+	}
+	conflicts, err := h.service.Conflicts(uint(termID))
+	if err != nil {
+		timetableError(c, err, "Failed to check timetable")
+		return
+	}
+	response.Success(c, "Timetable checked", conflicts)
+}
@@ -1,6 +1,8 @@
 package handlers
 
 import (
+	stderrors "errors"
+	"net/http"
 	"school-management-system/internal/models"
 	"school-management-system/internal/service"
 	"school-management-system/pkg/errors"
@@ -18,9 +20,29 @@ func NewTimeTableHandler(svc service.TimeTableService) *TimeTableHandler {
 	return &TimeTableHandler{service: svc}
 }
 
+// timetableError responds to a timetable error, with fallback as the
+// message for unexpected ones
+func timetableError(c *gin.Context, err error, fallback string) {
+	var conflict *service.TimetableConflictError
+	switch {
+	case stderrors.As(err, &conflict):
+		response.Error(c, errors.NewAppError("TIMETABLE_CONFLICT", err.Error(), http.StatusConflict).
+			WithDetails(conflict.Conflicts))
+	case stderrors.Is(err, service.ErrInvalidTimetable):
+		response.BadRequest(c, err.Error())
+	case stderrors.Is(err, service.ErrTimetableMissing):
+		response.Error(c, errors.NotFound(err.Error()))
+	case stderrors.Is(err, service.ErrTimetableDraftClosed), stderrors.Is(err, service.ErrTimetableDraftIncomplete):
+		response.Error(c, errors.Conflict(err.Error()))
+	default:
+		response.Error(c, errors.InternalError(fallback))
+	}
+}
+
 func (h *TimeTableHandler) Create(c *gin.Context) {
 	var req struct {
-		CourseID  uint   `json:"course_id" binding:"required"`
+		CourseID  uint   `json:"course_id"`
+		SectionID *uint  `json:"section_id"`
 		TeacherID uint   `json:"teacher_id" binding:"required"`
 		DayOfWeek string `json:"day_of_week" binding:"required"`
 		StartTime string `json:"start_time" binding:"required"`
@@ -32,8 +54,14 @@ func (h *TimeTableHandler) Create(c *gin.Context) {
 		return
 	}
 
+	if req.CourseID == 0 && (req.SectionID == nil || *req.SectionID == 0) {
+		response.Error(c, errors.MissingRequiredField("course_id"))
+		return
+	}
+
 	timetable := &models.TimeTable{
 		CourseID:  req.CourseID,
+		SectionID: req.SectionID,
 		TeacherID: req.TeacherID,
 		DayOfWeek: req.DayOfWeek,
 		StartTime: req.StartTime,
@@ -43,7 +71,7 @@ func (h *TimeTableHandler) Create(c *gin.Context) {
 	}
 
 	if err := h.service.Create(timetable); err != nil {
-		response.Error(c, errors.InternalError("Failed to create timetable"))
+		timetableError(c, err, "Failed to create timetable")
 		return
 	}
 	response.Created(c, "Timetable created", timetable)
@@ -59,6 +87,16 @@ func (h *TimeTableHandler) GetByCourseID(c *gin.Context) {
 	response.Success(c, "Timetable fetched", timetables)
 }
 
+func (h *TimeTableHandler) GetBySectionID(c *gin.Context) {
+	sectionID, _ := strconv.ParseUint(c.Param("section_id"), 10, 32)
+	timetables, err := h.service.GetBySectionID(uint(sectionID))
+	if err != nil {
+		response.Error(c, errors.InternalError("Failed to fetch timetable"))
+		return
+	}
+	response.Success(c, "Timetable fetched", timetables)
+}
+
 func (h *TimeTableHandler) GetByTeacherID(c *gin.Context) {
 	teacherID, _ := strconv.ParseUint(c.Param("teacher_id"), 10, 32)
 	timetables, err := h.service.GetByTeacherID(uint(teacherID))
@@ -102,7 +140,11 @@ func (h *TimeTableHandler) Update(c *gin.Context) {
 		return
 	}
 
-	timetable, _ := h.service.GetByID(uint(id))
+	timetable, err := h.service.GetByID(uint(id))
+	if err != nil {
+		response.Error(c, errors.NotFound("Timetable not found"))
+		return
+	}
 	if req.DayOfWeek != "" {
 		timetable.DayOfWeek = req.DayOfWeek
 	}
@@ -118,7 +160,7 @@ func (h *TimeTableHandler) Update(c *gin.Context) {
 	timetable.IsActive = req.IsActive
 
 	if err := h.service.Update(timetable); err != nil {
-		response.Error(c, errors.InternalError("Failed to update timetable"))
+		timetableError(c, err, "Failed to update timetable")
 		return
 	}
 	response.Success(c, "Timetable updated", timetable)
@@ -132,3 +174,49 @@ func (h *TimeTableHandler) Delete(c *gin.Context) {
 	}
 	response.NoContent(c)
 }
+
+func (h *TimeTableHandler) GetTeacherAvailability(c *gin.Context) {
+	teacherID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.BadRequest(c, "Invalid teacher ID")
+		return
+	}
+	windows, err := h.service.GetTeacherAvailability(uint(teacherID))
+	if err != nil {
+		response.Error(c, errors.InternalError("Failed to fetch availability"))
+		return
+	}
+	response.Success(c, "Availability fetched", windows)
+}
+
+// SetTeacherAvailability replaces the weekly times a teacher can teach,
+// which generated timetables keep to. An empty list makes the teacher
+// available all week.
+func (h *TimeTableHandler) SetTeacherAvailability(c *gin.Context) {
+	teacherID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.BadRequest(c, "Invalid teacher ID")
+		return
+	}
+	var req struct {
+		Windows []struct {
+			DayOfWeek string `json:"day_of_week" binding:"required"`
+			StartTime string `json:"start_time" binding:"required"`
+			EndTime   string `json:"end_time" binding:"required"`
+		} `json:"windows" binding:"dive"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "Invalid input: "+err.Error())
+		return
+	}
+
+	windows := make([]models.TeacherAvailability, len(req.Windows))
+	for i, window := range req.Windows {
+		windows[i] = models.TeacherAvailability{DayOfWeek: window.DayOfWeek, StartTime: window.StartTime, EndTime: window.EndTime}
+	}
+	if err := h.service.SetTeacherAvailability(uint(teacherID), windows); err != nil {
+		timetableError(c, err, "Failed to set availability")
+		return
+	}
+	response.Success(c, "Availability set", windows)
+}
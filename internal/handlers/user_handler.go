@@ -13,24 +13,26 @@ import (
 
 type UserHandler struct {
 	userService service.UserService
+	authService service.AuthService
 	logger      *logrus.Logger
 }
 
-func NewUserHandler(userService service.UserService) *UserHandler {
+func NewUserHandler(userService service.UserService, authService service.AuthService) *UserHandler {
 	return &UserHandler{
 		userService: userService,
+		authService: authService,
 		logger:      logger.GetLogger(),
 	}
 }
 
 func (h *UserHandler) GetProfile(c *gin.Context) {
-	userID, exists := c.Get("user_id")
-	if !exists {
+	userID := c.GetUint("user_id")
+	if userID == 0 {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
 		return
 	}
 
-	user, err := h.userService.GetUserByID(uint(userID.(float64)))
+	user, err := h.userService.GetUserByID(userID)
 	if err != nil {
 		h.logger.WithError(err).Error("Failed to get user profile")
 		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
@@ -44,8 +46,8 @@ func (h *UserHandler) GetProfile(c *gin.Context) {
 }
 
 func (h *UserHandler) UpdateProfile(c *gin.Context) {
-	userID, exists := c.Get("user_id")
-	if !exists {
+	userID := c.GetUint("user_id")
+	if userID == 0 {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
 		return
 	}
@@ -61,7 +63,18 @@ func (h *UserHandler) UpdateProfile(c *gin.Context) {
 	delete(updateData, "is_active")
 	delete(updateData, "password")
 
-	user, err := h.userService.UpdateUser(uint(userID.(float64)), updateData)
+	// The locale picks the language of the user's emails
+	if value, ok := updateData["locale"]; ok {
+		locale, isString := value.(string)
+		normalized, valid := service.NormalizeLocale(locale)
+		if !isString || !valid {
+			c.JSON(http.StatusBadRequest, gin.H{"error": service.ErrInvalidLocale.Error()})
+			return
+		}
+		updateData["locale"] = normalized
+	}
+
+	user, err := h.userService.UpdateUser(c.Request.Context(), userID, updateData)
 	if err != nil {
 		h.logger.WithError(err).Error("Failed to update user profile")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update profile"})
@@ -87,7 +100,7 @@ func (h *UserHandler) UpdateUserStatus(c *gin.Context) {
 	}
 
 	var statusData struct {
-		IsActive bool `json:"is_active" binding:"required"`
+		IsActive *bool `json:"is_active" binding:"required"`
 	}
 
 	if err := c.ShouldBindJSON(&statusData); err != nil {
@@ -96,16 +109,24 @@ func (h *UserHandler) UpdateUserStatus(c *gin.Context) {
 	}
 
 	updateData := map[string]interface{}{
-		"is_active": statusData.IsActive,
+		"is_active": *statusData.IsActive,
 	}
 
-	user, err := h.userService.UpdateUser(uint(id), updateData)
+	user, err := h.userService.UpdateUser(c.Request.Context(), uint(id), updateData)
 	if err != nil {
 		h.logger.WithError(err).Error("Failed to update user status")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user status"})
 		return
 	}
 
+	// Deactivated users lose their sessions; their access tokens are
+	// rejected by AuthMiddleware from the next request.
+	if !user.IsActive {
+		if err := h.authService.RevokeUserSessions(user.ID); err != nil {
+			h.logger.WithError(err).Error("Failed to revoke user sessions")
+		}
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"message": "User status updated successfully",
 		"user": gin.H{
@@ -165,7 +186,7 @@ func (h *UserHandler) UpdateUser(c *gin.Context) {
 		return
 	}
 
-	user, err := h.userService.UpdateUser(uint(id), updateData)
+	user, err := h.userService.UpdateUser(c.Request.Context(), uint(id), updateData)
 	if err != nil {
 		h.logger.WithError(err).Error("Failed to update user")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
@@ -183,7 +204,7 @@ func (h *UserHandler) DeleteUser(c *gin.Context) {
 		return
 	}
 
-	if err := h.userService.DeleteUser(uint(id)); err != nil {
+	if err := h.userService.DeleteUser(c.Request.Context(), uint(id)); err != nil {
 		h.logger.WithError(err).Error("Failed to delete user")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete user"})
 		return
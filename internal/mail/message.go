@@ -0,0 +1,94 @@
+// Package mail builds email messages and delivers them over SMTP or into a
+// maildir on disk.
+package mail
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+// Message is one email to one or more recipients
+type Message struct {
+	From     string
+	FromName string
+	To       []string
+	Subject  string
+	Body     string
+	IsHTML   bool
+	TextBody string // plain-text alternative to an HTML body
+
+	MessageID string
+	Date      time.Time
+}
+
+var headerBreaks = strings.NewReplacer("\r\n", " ", "\r", " ", "\n", " ")
+
+// Bytes renders the message in RFC 5322 format. Non-ASCII header text is
+// MIME encoded and the body is quoted-printable, so long HTML lines stay
+// within SMTP's line length limit. An HTML message with a TextBody is sent
+// as multipart/alternative, so clients that cannot show HTML show the text.
+func (m *Message) Bytes() []byte {
+	var buf bytes.Buffer
+
+	from := (&mail.Address{Name: m.FromName, Address: m.From}).String()
+	date := m.Date
+	if date.IsZero() {
+		date = time.Now()
+	}
+	contentType := "text/plain"
+	if m.IsHTML {
+		contentType = "text/html"
+	}
+
+	// Line breaks in a header value would start a new header
+	header := func(name, value string) {
+		fmt.Fprintf(&buf, "%s: %s\r\n", name, headerBreaks.Replace(value))
+	}
+	header("From", from)
+	header("To", strings.Join(m.To, ", "))
+	header("Subject", mime.QEncoding.Encode("utf-8", m.Subject))
+	header("Date", date.Format(time.RFC1123Z))
+	if m.MessageID != "" {
+		header("Message-ID", "<"+m.MessageID+">")
+	}
+	header("MIME-Version", "1.0")
+
+	if !m.IsHTML || m.TextBody == "" {
+		header("Content-Type", contentType+"; charset=UTF-8")
+		header("Content-Transfer-Encoding", "quoted-printable")
+		buf.WriteString("\r\n")
+		writeQuotedPrintable(&buf, m.Body)
+		return buf.Bytes()
+	}
+
+	// Parts go from least to most preferred, so the HTML part comes last
+	parts := multipart.NewWriter(&buf)
+	header("Content-Type", "multipart/alternative; boundary="+parts.Boundary())
+	buf.WriteString("\r\n")
+	for _, part := range []struct{ contentType, body string }{
+		{"text/plain", m.TextBody},
+		{"text/html", m.Body},
+	} {
+		w, _ := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType + "; charset=UTF-8"},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		writeQuotedPrintable(w, part.body)
+	}
+	parts.Close()
+	return buf.Bytes()
+}
+
+func writeQuotedPrintable(w io.Writer, body string) {
+	qp := quotedprintable.NewWriter(w)
+	qp.Write([]byte(body))
+	qp.Close()
+}
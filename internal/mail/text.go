@@ -0,0 +1,92 @@
+package mail
+
+import (
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+var (
+	blankLines = regexp.MustCompile(`\n{3,}`)
+	spaces     = regexp.MustCompile(`[ \t\r\n]+`)
+)
+
+// blockTags start on a new line in the text version
+var blockTags = map[string]bool{
+	"p": true, "div": true, "h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"ul": true, "ol": true, "li": true, "table": true, "tr": true, "blockquote": true, "hr": true,
+}
+
+// HTMLToText converts an HTML email body into a plain-text alternative.
+// Block elements become paragraphs, list items become "- " lines and
+// links keep their address in parentheses.
+func HTMLToText(body string) string {
+	var out strings.Builder
+	var hrefs []string
+	skip := 0
+
+	z := html.NewTokenizer(strings.NewReader(body))
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			break
+		}
+		token := z.Token()
+		switch tt {
+		case html.StartTagToken, html.SelfClosingTagToken:
+			switch token.Data {
+			case "head", "style", "script", "title":
+				if tt == html.StartTagToken {
+					skip++
+				}
+			case "br":
+				out.WriteString("\n")
+			case "li":
+				out.WriteString("\n- ")
+			case "a":
+				href := ""
+				for _, attr := range token.Attr {
+					if attr.Key == "href" {
+						href = attr.Val
+					}
+				}
+				hrefs = append(hrefs, href)
+			default:
+				if blockTags[token.Data] {
+					out.WriteString("\n\n")
+				}
+			}
+		case html.EndTagToken:
+			switch token.Data {
+			case "head", "style", "script", "title":
+				if skip > 0 {
+					skip--
+				}
+			case "a":
+				if n := len(hrefs); n > 0 {
+					if href := hrefs[n-1]; href != "" && !strings.HasPrefix(href, "#") {
+						out.WriteString(" (" + href + ")")
+					}
+					hrefs = hrefs[:n-1]
+				}
+			case "td", "th":
+				out.WriteString(" ")
+			default:
+				if blockTags[token.Data] && token.Data != "li" {
+					out.WriteString("\n\n")
+				}
+			}
+		case html.TextToken:
+			if skip == 0 {
+				out.WriteString(spaces.ReplaceAllString(token.Data, " "))
+			}
+		}
+	}
+
+	lines := strings.Split(out.String(), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(line)
+	}
+	return strings.TrimSpace(blankLines.ReplaceAllString(strings.Join(lines, "\n"), "\n\n"))
+}
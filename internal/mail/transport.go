@@ -0,0 +1,88 @@
+package mail
+
+import (
+	"errors"
+	"fmt"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+)
+
+// Transport delivers a rendered message to a single recipient
+type Transport interface {
+	Send(from, to string, message []byte) error
+}
+
+// PermanentError marks a failure that retrying will not fix, such as a
+// rejected recipient address
+type PermanentError struct {
+	Err error
+}
+
+func (e *PermanentError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *PermanentError) Unwrap() error {
+	return e.Err
+}
+
+// IsPermanent reports whether err should not be retried. SMTP replies in
+// the 5xx range are permanent.
+func IsPermanent(err error) bool {
+	var permanent *PermanentError
+	if errors.As(err, &permanent) {
+		return true
+	}
+	var reply *textproto.Error
+	return errors.As(err, &reply) && reply.Code >= 500
+}
+
+// SMTPTransport sends through an SMTP server, authenticating with PLAIN
+// auth when a username is set
+type SMTPTransport struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+}
+
+func (t *SMTPTransport) Send(from, to string, message []byte) error {
+	var auth smtp.Auth
+	if t.Username != "" {
+		auth = smtp.PlainAuth("", t.Username, t.Password, t.Host)
+	}
+	return smtp.SendMail(t.Host+":"+t.Port, auth, from, []string{to}, message)
+}
+
+// MaildirTransport writes each message into the new/ folder of a maildir,
+// for development and tests. Any mail client that reads maildirs can open
+// it.
+type MaildirTransport struct {
+	Dir string
+}
+
+var maildirSeq uint64
+
+func (t *MaildirTransport) Send(from, to string, message []byte) error {
+	for _, sub := range []string{"tmp", "new", "cur"} {
+		if err := os.MkdirAll(filepath.Join(t.Dir, sub), 0o755); err != nil {
+			return err
+		}
+	}
+
+	host, _ := os.Hostname()
+	name := fmt.Sprintf("%d.M%dP%dQ%d.%s", time.Now().Unix(), time.Now().Nanosecond()/1000, os.Getpid(),
+		atomic.AddUint64(&maildirSeq, 1), host)
+
+	// Maildir delivery: write under tmp/, then move into new/ in one step
+	tmp := filepath.Join(t.Dir, "tmp", name)
+	envelope := fmt.Sprintf("Return-Path: <%s>\r\nDelivered-To: %s\r\n", from, to)
+	if err := os.WriteFile(tmp, append([]byte(envelope), message...), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(t.Dir, "new", name))
+}
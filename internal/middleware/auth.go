@@ -35,8 +35,24 @@ func AuthMiddleware(authService service.AuthService) gin.HandlerFunc {
 			return
 		}
 
-		c.Set("user_id", claims["user_id"])
+		if err := authService.CheckRevoked(claims); err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Token has been revoked"})
+			c.Abort()
+			return
+		}
+
+		// Handlers read user_id as a uint, with c.GetUint; JSON numbers in
+		// the claims decode as float64, so it is converted once here.
+		userID, ok := claims["user_id"].(float64)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token claims"})
+			c.Abort()
+			return
+		}
+
+		c.Set("user_id", uint(userID))
 		c.Set("user_role", claims["role"])
+		c.Set("token_claims", claims)
 		c.Next()
 	}
 }
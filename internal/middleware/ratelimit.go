@@ -94,9 +94,11 @@ var rateLimitStore = NewRateLimitStore()
 // limit: max requests, window: time duration for limit
 func RateLimitMiddleware(limit int, window time.Duration) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		clientIP := c.ClientIP()
+		// Each limit counts separately, so the API-wide limit does not use
+		// up the stricter auth limit
+		clientID := fmt.Sprintf("%d/%s:%s", limit, window, c.ClientIP())
 
-		if !rateLimitStore.IsAllowed(clientIP, limit, window) {
+		if !rateLimitStore.IsAllowed(clientID, limit, window) {
 			c.Header("X-RateLimit-Limit", fmt.Sprintf("%d", limit))
 			c.Header("X-RateLimit-Window", window.String())
 			c.Header("Retry-After", fmt.Sprintf("%d", int(window.Seconds())))
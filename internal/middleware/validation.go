@@ -8,13 +8,14 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-// ValidationMiddleware validates request content type
+// ValidationMiddleware validates request content type. File uploads are
+// sent as multipart/form-data; everything else must be JSON.
 func ValidationMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		if c.Request.Method != "GET" && c.Request.Method != "DELETE" {
 			contentType := c.ContentType()
-			if contentType != "application/json" && contentType != "" {
-				response.Error(c, errors.BadRequest("invalid content-type, expected application/json"))
+			if contentType != "application/json" && contentType != "multipart/form-data" && contentType != "" {
+				response.Error(c, errors.BadRequest("invalid content-type, expected application/json or multipart/form-data"))
 				c.Abort()
 				return
 			}
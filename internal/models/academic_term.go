@@ -0,0 +1,78 @@
+package models
+
+import (
+	"time"
+)
+
+type TermStatus string
+
+const (
+	TermStatusPlanned      TermStatus = "planned"
+	TermStatusRegistration TermStatus = "registration"
+	TermStatusActive       TermStatus = "active"
+	TermStatusGrading      TermStatus = "grading"
+	TermStatusClosed       TermStatus = "closed"
+)
+
+// AcademicTerm is a teaching period (semester, trimester, quarter) that
+// course sections, enrollments, grades and transcripts belong to.
+type AcademicTerm struct {
+	ID                 uint       `gorm:"primaryKey" json:"id"`
+	Name               string     `gorm:"size:100;not null" json:"name"` // e.g. "Fall 2025"
+	Code               string     `gorm:"size:20;index" json:"code"`     // e.g. "2025FA"
+	AcademicYear       string     `gorm:"size:20" json:"academic_year"`  // e.g. "2025-2026"
+	StartDate          time.Time  `gorm:"not null" json:"start_date"`
+	EndDate            time.Time  `gorm:"not null" json:"end_date"`
+	RegistrationStart  time.Time  `json:"registration_start"`
+	RegistrationEnd    time.Time  `json:"registration_end"`
+	GradingDeadline    time.Time  `json:"grading_deadline"`
+	DropDeadline       time.Time  `json:"drop_deadline"`       // last day to drop; students withdraw, with a W grade, after it
+	WithdrawalDeadline time.Time  `json:"withdrawal_deadline"` // last day to withdraw
+	Status             TermStatus `gorm:"size:20;default:'planned'" json:"status"`
+	CreatedAt          time.Time  `json:"created_at"`
+	UpdatedAt          time.Time  `json:"updated_at"`
+
+	// Relations
+	Sections []CourseSection `gorm:"foreignKey:TermID" json:"sections,omitempty"`
+}
+
+func (AcademicTerm) TableName() string {
+	return "academic_terms"
+}
+
+// Contains reports whether t falls within the term's teaching dates.
+func (t *AcademicTerm) Contains(at time.Time) bool {
+	return !at.Before(t.StartDate) && !at.After(t.EndDate)
+}
+
+// IsRegistrationOpen reports whether students may register at the given time.
+func (t *AcademicTerm) IsRegistrationOpen(at time.Time) bool {
+	return t.IsRegistrationOpenFrom(t.RegistrationStart, at)
+}
+
+// IsRegistrationOpenFrom reports whether a student whose registration
+// starts at start, instead of the term's registration start, may register
+// at the given time
+func (t *AcademicTerm) IsRegistrationOpenFrom(start, at time.Time) bool {
+	if start.IsZero() || t.RegistrationEnd.IsZero() {
+		return t.Status == TermStatusRegistration
+	}
+	return !at.Before(start) && !at.After(t.RegistrationEnd)
+}
+
+// PastDropDeadline reports whether the last day to drop courses is over.
+// A term without a drop deadline never passes it.
+func (t *AcademicTerm) PastDropDeadline(at time.Time) bool {
+	return pastDeadline(t.DropDeadline, at)
+}
+
+// PastWithdrawalDeadline reports whether the last day to withdraw from
+// courses is over
+func (t *AcademicTerm) PastWithdrawalDeadline(at time.Time) bool {
+	return pastDeadline(t.WithdrawalDeadline, at)
+}
+
+// pastDeadline reports whether at is after the whole of a deadline's day
+func pastDeadline(deadline, at time.Time) bool {
+	return !deadline.IsZero() && !at.Before(deadline.AddDate(0, 0, 1))
+}
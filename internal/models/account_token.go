@@ -0,0 +1,35 @@
+package models
+
+// Account token purposes
+const (
+	TokenPurposePasswordReset     = "password_reset"
+	TokenPurposeEmailVerification = "email_verification"
+)
+
+// AccountToken is a single-use token emailed to a user to reset their
+// password or verify their email address. Only its SHA-256 hash is stored.
+type AccountToken struct {
+	ID        uint   `gorm:"primaryKey" json:"id"`
+	UserID    uint   `gorm:"index;not null" json:"user_id"`
+	Purpose   string `gorm:"size:30;index;not null" json:"purpose"`
+	TokenHash string `gorm:"size:64;uniqueIndex;not null" json:"-"`
+	ExpiresAt int64  `gorm:"index" json:"expires_at"`
+	UsedAt    int64  `json:"used_at"`
+	CreatedAt int64  `json:"created_at"`
+}
+
+func (AccountToken) TableName() string {
+	return "account_tokens"
+}
+
+// PasswordHistory keeps previous password hashes so they cannot be reused
+type PasswordHistory struct {
+	ID           uint   `gorm:"primaryKey" json:"id"`
+	UserID       uint   `gorm:"index;not null" json:"user_id"`
+	PasswordHash string `gorm:"size:255;not null" json:"-"`
+	CreatedAt    int64  `json:"created_at"`
+}
+
+func (PasswordHistory) TableName() string {
+	return "password_histories"
+}
@@ -1,21 +1,87 @@
 package models
 
+// Announcement audiences. An announcement with targets is "targeted" and
+// reaches the union of them; one without reaches everyone, or for older
+// announcements the role named by its audience.
+const (
+	AnnouncementAudienceAll      = "all"
+	AnnouncementAudienceTargeted = "targeted"
+)
+
+// Announcement target kinds
+const (
+	AnnouncementTargetRole       = "role"        // users with the role
+	AnnouncementTargetCourse     = "course"      // a course's enrolled students and teachers
+	AnnouncementTargetSection    = "section"     // a section's enrolled students and teacher
+	AnnouncementTargetGradeLevel = "grade_level" // students in the grade level
+	AnnouncementTargetUser       = "user"        // one user
+)
+
 type Announcement struct {
 	ID        uint   `gorm:"primaryKey" json:"id"`
 	Title     string `json:"title"`
 	Content   string `json:"content"`
 	CreatedBy uint   `json:"created_by"`
-	Audience  string `json:"audience"` // all, students, teachers, specific_class
+	Audience  string `json:"audience"` // all, targeted; students, teachers, parents on older announcements
 	Priority  string `json:"priority"` // low, normal, high
 	IsActive  bool   `json:"is_active"`
 	ExpiresAt int64  `json:"expires_at"`
 
+	// PublishAt schedules the announcement; until PublishedAt is set it
+	// has no recipients and is seen only by its author and admins.
+	PublishAt   int64 `gorm:"index" json:"publish_at"`
+	PublishedAt int64 `json:"published_at"`
+	// IncludeGuardians adds the guardians of every targeted student
+	IncludeGuardians bool `json:"include_guardians"`
+	// RequiresAcknowledgement asks each recipient to confirm it, as for a
+	// permission slip
+	RequiresAcknowledgement bool `json:"requires_acknowledgement"`
+	// SendEmail emails the announcement to its recipients when published
+	SendEmail bool  `json:"send_email"`
+	EmailedAt int64 `json:"emailed_at,omitempty"`
+
 	CreatedAt int64 `json:"created_at"`
 	UpdatedAt int64 `json:"updated_at"`
 
-	CreatedByUser User `gorm:"foreignKey:CreatedBy" json:"created_by_user,omitempty"`
+	CreatedByUser User                 `gorm:"foreignKey:CreatedBy" json:"created_by_user,omitempty"`
+	Targets       []AnnouncementTarget `gorm:"foreignKey:AnnouncementID;constraint:OnDelete:CASCADE" json:"targets,omitempty"`
+
+	// The reading user's receipt, on announcements listed for them
+	ReadAt         int64 `gorm:"-" json:"read_at,omitempty"`
+	AcknowledgedAt int64 `gorm:"-" json:"acknowledged_at,omitempty"`
 }
 
 func (Announcement) TableName() string {
 	return "announcements"
 }
+
+// AnnouncementTarget is one audience an announcement is addressed to. Value
+// is the role or grade level, or the ID of the course, section or user.
+type AnnouncementTarget struct {
+	ID             uint   `gorm:"primaryKey" json:"id"`
+	AnnouncementID uint   `gorm:"not null;index" json:"announcement_id"`
+	Kind           string `gorm:"size:20;not null" json:"kind"`
+	Value          string `gorm:"size:50;not null" json:"value"`
+}
+
+func (AnnouncementTarget) TableName() string {
+	return "announcement_targets"
+}
+
+// AnnouncementRecipient is a user an announcement was delivered to, with
+// whether they have read and acknowledged it
+type AnnouncementRecipient struct {
+	ID             uint  `gorm:"primaryKey" json:"id"`
+	AnnouncementID uint  `gorm:"not null;uniqueIndex:idx_announcement_recipient" json:"announcement_id"`
+	UserID         uint  `gorm:"not null;uniqueIndex:idx_announcement_recipient;index" json:"user_id"`
+	ReadAt         int64 `json:"read_at"`
+	AcknowledgedAt int64 `json:"acknowledged_at"`
+	CreatedAt      int64 `json:"created_at"`
+
+	User         User         `gorm:"foreignKey:UserID" json:"user,omitempty"`
+	Announcement Announcement `gorm:"foreignKey:AnnouncementID" json:"-"`
+}
+
+func (AnnouncementRecipient) TableName() string {
+	return "announcement_recipients"
+}
@@ -2,24 +2,55 @@ package models
 
 import (
 	"time"
+
+	"gorm.io/gorm"
 )
 
 type Assignment struct {
 	ID          uint      `gorm:"primaryKey" json:"id"`
 	CourseID    uint      `json:"course_id"`
+	SectionID   *uint     `gorm:"index" json:"section_id,omitempty"` // set for work given to one section only
+	TermID      *uint     `gorm:"index" json:"term_id,omitempty"`
+	CategoryID  *uint     `gorm:"index" json:"category_id,omitempty"`
 	Title       string    `gorm:"size:200;not null" json:"title"`
 	Description string    `gorm:"type:text" json:"description"`
 	DueDate     time.Time `json:"due_date"`
 	MaxScore    float64   `gorm:"default:100" json:"max_score"`
 	CreatedBy   uint      `json:"created_by"`
 	CreatedAt   time.Time `json:"created_at"`
+	// ReminderSentAt is set once students have been reminded the assignment is due
+	ReminderSentAt *time.Time `json:"-"`
 
 	// Relations
 	Course      Course                 `gorm:"foreignKey:CourseID" json:"course"`
 	Teacher     Teacher                `gorm:"foreignKey:CreatedBy" json:"teacher"`
+	Category    *GradeCategory         `gorm:"foreignKey:CategoryID" json:"category,omitempty"`
 	Submissions []AssignmentSubmission `json:"submissions,omitempty"`
 }
 
+func (a *Assignment) BeforeSave(tx *gorm.DB) error {
+	return resolveSection(tx, a.SectionID, &a.CourseID, &a.TermID)
+}
+
+// InOffering reports whether the assignment counts towards the offering of
+// its course in a term and section. Assignments from before they had a term
+// count for the term their due date falls in, and assignments without a
+// section count for every section of their term. Without a term every
+// assignment of the course counts.
+func (a *Assignment) InOffering(term *AcademicTerm, sectionID *uint) bool {
+	if term == nil {
+		return true
+	}
+	if a.TermID != nil && *a.TermID != 0 {
+		if *a.TermID != term.ID {
+			return false
+		}
+	} else if !term.Contains(a.DueDate) {
+		return false
+	}
+	return a.SectionID == nil || *a.SectionID == 0 || sectionID == nil || *a.SectionID == *sectionID
+}
+
 type AssignmentSubmission struct {
 	ID           uint       `gorm:"primaryKey" json:"id"`
 	AssignmentID uint       `json:"assignment_id"`
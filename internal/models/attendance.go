@@ -2,17 +2,26 @@ package models
 
 import (
 	"time"
+
+	"gorm.io/gorm"
 )
 
 type Attendance struct {
 	ID        uint      `gorm:"primaryKey" json:"id"`
 	StudentID uint      `json:"student_id"`
 	CourseID  uint      `json:"course_id"`
+	SectionID *uint     `gorm:"index" json:"section_id,omitempty"`
+	TermID    *uint     `gorm:"index" json:"term_id,omitempty"`
 	Date      time.Time `json:"date"`
 	Status    string    `gorm:"size:20;not null" json:"status"` // present, absent, late, excused
 	Remarks   string    `gorm:"type:text" json:"remarks"`
 
 	// Relations
-	Student Student `gorm:"foreignKey:StudentID" json:"student"`
-	Course  Course  `gorm:"foreignKey:CourseID" json:"course"`
+	Student Student        `gorm:"foreignKey:StudentID" json:"student"`
+	Course  Course         `gorm:"foreignKey:CourseID" json:"course"`
+	Section *CourseSection `gorm:"foreignKey:SectionID" json:"section,omitempty"`
+}
+
+func (a *Attendance) BeforeSave(tx *gorm.DB) error {
+	return resolveSection(tx, a.SectionID, &a.CourseID, &a.TermID)
 }
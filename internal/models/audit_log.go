@@ -1,17 +1,27 @@
 package models
 
+// Audit actions for record changes
+const (
+	AuditActionCreate = "create"
+	AuditActionUpdate = "update"
+	AuditActionDelete = "delete"
+)
+
+// AuditLog records who did what. For record changes OldValue and NewValue
+// hold JSON snapshots of the row before and after the change.
 type AuditLog struct {
 	ID        uint   `gorm:"primaryKey" json:"id"`
-	UserID    uint   `json:"user_id"`
-	Action    string `json:"action"`
-	Entity    string `json:"entity"`
-	EntityID  uint   `json:"entity_id"`
-	OldValue  string `json:"old_value"`
-	NewValue  string `json:"new_value"`
+	UserID    uint   `gorm:"index" json:"user_id"`
+	Action    string `gorm:"size:50;index" json:"action"`
+	Entity    string `gorm:"size:50;index:idx_audit_entity" json:"entity"`
+	EntityID  uint   `gorm:"index:idx_audit_entity" json:"entity_id"`
+	OldValue  string `gorm:"type:text" json:"old_value"`
+	NewValue  string `gorm:"type:text" json:"new_value"`
 	IPAddress string `json:"ip_address"`
+	RequestID string `gorm:"size:36;index" json:"request_id"`
 	Status    string `json:"status"`
 
-	CreatedAt int64 `json:"created_at"`
+	CreatedAt int64 `gorm:"index" json:"created_at"`
 }
 
 func (AuditLog) TableName() string {
@@ -1,16 +1,39 @@
 package models
 
+// Backup statuses
+const (
+	BackupStatusPending   = "pending"
+	BackupStatusRunning   = "running"
+	BackupStatusCompleted = "completed"
+	BackupStatusFailed    = "failed"
+)
+
+// Backup triggers
+const (
+	BackupTriggerManual    = "manual"
+	BackupTriggerScheduled = "scheduled"
+)
+
+// Backup is a compressed database snapshot on disk. Format is "sqlite" for
+// a VACUUM INTO copy of a SQLite database or "json" for a logical dump of
+// every table. Checksum is the SHA-256 of the file at Location.
 type Backup struct {
 	ID          uint   `gorm:"primaryKey" json:"id"`
 	BackupName  string `json:"backup_name"`
 	Description string `json:"description"`
 	Size        int64  `json:"size"` // in bytes
 	Location    string `json:"location"`
-	Status      string `json:"status"` // pending, completed, failed
-	CreatedBy   uint   `json:"created_by"`
+	Format      string `gorm:"size:10" json:"format"`
+	Checksum    string `gorm:"size:64" json:"checksum"`
+	Trigger     string `gorm:"size:20" json:"trigger"`
+	Status      string `gorm:"index" json:"status"` // pending, running, completed, failed
+	Error       string `gorm:"type:text" json:"error,omitempty"`
+	CreatedBy   *uint  `json:"created_by"` // nil for scheduled backups
 	CreatedAt   int64  `json:"created_at"`
+	CompletedAt int64  `json:"completed_at"`
+	VerifiedAt  int64  `json:"verified_at"`
 
-	CreatedByUser User `gorm:"foreignKey:CreatedBy" json:"created_by_user,omitempty"`
+	CreatedByUser *User `gorm:"foreignKey:CreatedBy" json:"created_by_user,omitempty"`
 }
 
 func (Backup) TableName() string {
@@ -0,0 +1,45 @@
+package models
+
+import "time"
+
+// Booking statuses
+const (
+	BookingConfirmed = "confirmed"
+	BookingCancelled = "cancelled"
+)
+
+// Booking purposes
+const (
+	BookingExam    = "exam"
+	BookingMeeting = "meeting"
+	BookingEvent   = "event"
+	BookingOther   = "other"
+)
+
+// Booking reserves a room, or Quantity of a resource, on a date outside
+// the weekly timetable, for an exam, meeting or event
+type Booking struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	RoomID      *uint     `gorm:"index" json:"room_id,omitempty"`
+	ResourceID  *uint     `gorm:"index" json:"resource_id,omitempty"`
+	Quantity    int       `json:"quantity,omitempty"` // of the resource
+	Title       string    `gorm:"size:200;not null" json:"title"`
+	Purpose     string    `gorm:"size:20" json:"purpose"`
+	Attendees   int       `json:"attendees,omitempty"`
+	Date        time.Time `gorm:"not null;index" json:"date"`
+	StartTime   string    `gorm:"size:5;not null" json:"start_time"` // HH:MM
+	EndTime     string    `gorm:"size:5;not null" json:"end_time"`   // HH:MM
+	Status      string    `gorm:"size:20;not null;index" json:"status"`
+	Notes       string    `gorm:"type:text" json:"notes"`
+	BookedBy    uint      `gorm:"index" json:"booked_by"`
+	CancelledBy uint      `json:"cancelled_by,omitempty"`
+	CreatedAt   int64     `json:"created_at"`
+	UpdatedAt   int64     `json:"updated_at"`
+
+	Room     *Room     `gorm:"foreignKey:RoomID" json:"room,omitempty"`
+	Resource *Resource `gorm:"foreignKey:ResourceID" json:"resource,omitempty"`
+}
+
+func (Booking) TableName() string {
+	return "bookings"
+}
@@ -0,0 +1,63 @@
+package models
+
+// Conversation kinds
+const (
+	ConversationDirect    = "direct"    // two users
+	ConversationGroup     = "group"     // users chosen by its creator
+	ConversationCourse    = "course"    // a course's teachers and enrolled students, kept in sync
+	ConversationBroadcast = "broadcast" // a teacher posting to a course audience; replies go to direct conversations
+)
+
+// Broadcast audiences
+const (
+	AudienceStudents  = "students"
+	AudienceGuardians = "guardians"
+)
+
+// Conversation participant roles
+const (
+	ParticipantOwner  = "owner"
+	ParticipantMember = "member"
+)
+
+// Conversation is a message thread with two or more participants
+type Conversation struct {
+	ID    uint   `gorm:"primaryKey" json:"id"`
+	Kind  string `gorm:"size:20;not null;uniqueIndex:idx_conversation_course" json:"kind"`
+	Title string `gorm:"size:200" json:"title"`
+	// DirectKey is "<lower user id>:<higher user id>" on direct
+	// conversations, so each pair of users has at most one
+	DirectKey *string `gorm:"size:40;uniqueIndex" json:"-"`
+	// A course has one channel, and one broadcast per teacher and audience
+	CourseID      *uint  `gorm:"uniqueIndex:idx_conversation_course" json:"course_id,omitempty"`
+	Audience      string `gorm:"size:20;uniqueIndex:idx_conversation_course" json:"audience,omitempty"`
+	CreatedBy     uint   `gorm:"uniqueIndex:idx_conversation_course" json:"created_by"`
+	LastMessageAt int64  `gorm:"index" json:"last_message_at"`
+	CreatedAt     int64  `json:"created_at"`
+	UpdatedAt     int64  `json:"updated_at"`
+
+	Participants []ConversationParticipant `gorm:"foreignKey:ConversationID" json:"participants,omitempty"`
+}
+
+func (Conversation) TableName() string {
+	return "conversations"
+}
+
+// ConversationParticipant is a user's membership of a conversation.
+// Messages with an id above LastReadMessageID are unread. Members who left,
+// or were removed by a course channel sync, keep their row with LeftAt set.
+type ConversationParticipant struct {
+	ID                uint   `gorm:"primaryKey" json:"id"`
+	ConversationID    uint   `gorm:"uniqueIndex:idx_participant_conversation_user;not null" json:"conversation_id"`
+	UserID            uint   `gorm:"uniqueIndex:idx_participant_conversation_user;index;not null" json:"user_id"`
+	Role              string `gorm:"size:20;not null" json:"role"`
+	LastReadMessageID uint   `json:"last_read_message_id"`
+	JoinedAt          int64  `json:"joined_at"`
+	LeftAt            int64  `json:"left_at,omitempty"`
+
+	User User `gorm:"foreignKey:UserID" json:"user,omitempty"`
+}
+
+func (ConversationParticipant) TableName() string {
+	return "conversation_participants"
+}
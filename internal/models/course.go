@@ -13,7 +13,8 @@ type Course struct {
 	MaxStudents int    `json:"max_students"`
 
 	// Relations
-	Teacher     Teacher      `gorm:"foreignKey:TeacherID" json:"teacher"`
-	Enrollments []Enrollment `json:"enrollments,omitempty"`
-	Grades      []Grade      `json:"grades,omitempty"`
+	Teacher     Teacher         `gorm:"foreignKey:TeacherID" json:"teacher"`
+	Enrollments []Enrollment    `json:"enrollments,omitempty"`
+	Grades      []Grade         `json:"grades,omitempty"`
+	Sections    []CourseSection `json:"sections,omitempty"`
 }
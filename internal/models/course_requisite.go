@@ -0,0 +1,24 @@
+package models
+
+// Requisite kinds. A prerequisite must be passed before enrolling; a
+// co-requisite may instead be taken at the same time.
+const (
+	RequisitePrerequisite = "prerequisite"
+	RequisiteCorequisite  = "corequisite"
+)
+
+// CourseRequisite is a course that must be passed, or for a co-requisite
+// passed or taken alongside, to enroll in CourseID
+type CourseRequisite struct {
+	ID               uint   `gorm:"primaryKey" json:"id"`
+	CourseID         uint   `gorm:"not null;uniqueIndex:idx_course_requisite" json:"course_id"`
+	RequiredCourseID uint   `gorm:"not null;uniqueIndex:idx_course_requisite;index" json:"required_course_id"`
+	Kind             string `gorm:"size:20;not null" json:"kind"`
+	CreatedAt        int64  `json:"created_at"`
+
+	RequiredCourse Course `gorm:"foreignKey:RequiredCourseID" json:"required_course,omitempty"`
+}
+
+func (CourseRequisite) TableName() string {
+	return "course_requisites"
+}
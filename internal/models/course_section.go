@@ -0,0 +1,55 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// CourseSection is one offering of a course in a specific academic term,
+// with its own teacher, room and capacity.
+type CourseSection struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	CourseID     uint      `gorm:"not null;uniqueIndex:idx_sections_course_term_code" json:"course_id"`
+	TermID       uint      `gorm:"not null;index;uniqueIndex:idx_sections_course_term_code" json:"term_id"`
+	SectionCode  string    `gorm:"size:20;not null;uniqueIndex:idx_sections_course_term_code" json:"section_code"` // e.g. "001"
+	TeacherID    uint      `gorm:"index" json:"teacher_id"`
+	Room         string    `gorm:"size:50" json:"room"`
+	Schedule     string    `gorm:"size:100" json:"schedule"`
+	Capacity     int       `json:"capacity"`
+	WeeklyHours  int       `json:"weekly_hours"`                         // hours of class a week; the course's credit hours if 0
+	RoomFeatures string    `gorm:"size:255" json:"room_features"`        // comma-separated features its room needs
+	Status       string    `gorm:"size:20;default:'open'" json:"status"` // open, closed, cancelled
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+
+	// Relations
+	Course  Course       `gorm:"foreignKey:CourseID" json:"course"`
+	Term    AcademicTerm `gorm:"foreignKey:TermID" json:"term"`
+	Teacher Teacher      `gorm:"foreignKey:TeacherID" json:"teacher"`
+}
+
+func (CourseSection) TableName() string {
+	return "course_sections"
+}
+
+// resolveSection copies the course and term of the referenced section onto a
+// record so that rows created with only a section_id stay queryable by course
+// and term.
+func resolveSection(tx *gorm.DB, sectionID *uint, courseID *uint, termID **uint) error {
+	if sectionID == nil || *sectionID == 0 {
+		return nil
+	}
+
+	var section CourseSection
+	if err := tx.Session(&gorm.Session{NewDB: true}).
+		Select("id", "course_id", "term_id").
+		First(&section, *sectionID).Error; err != nil {
+		return err
+	}
+
+	*courseID = section.CourseID
+	term := section.TermID
+	*termID = &term
+	return nil
+}
@@ -0,0 +1,25 @@
+package models
+
+// DefaultLocale is used when a user has no locale, or no template variant
+// matches theirs
+const DefaultLocale = "en"
+
+// EmailTemplate is one locale variant of an email. Subject and TextBody
+// are text/template sources and HTMLBody is an html/template source, so
+// values such as names and announcement content are escaped. When
+// TextBody is empty the plain-text alternative is derived from the HTML.
+type EmailTemplate struct {
+	ID        uint   `gorm:"primaryKey" json:"id"`
+	Key       string `gorm:"size:100;not null;uniqueIndex:idx_email_template_key_locale" json:"key"`
+	Locale    string `gorm:"size:10;not null;uniqueIndex:idx_email_template_key_locale" json:"locale"`
+	Subject   string `json:"subject"`
+	HTMLBody  string `gorm:"type:text" json:"html_body"`
+	TextBody  string `gorm:"type:text" json:"text_body"`
+	UpdatedBy *uint  `json:"updated_by,omitempty"`
+	CreatedAt int64  `json:"created_at"`
+	UpdatedAt int64  `json:"updated_at"`
+}
+
+func (EmailTemplate) TableName() string {
+	return "email_templates"
+}
@@ -2,16 +2,86 @@ package models
 
 import (
 	"time"
+
+	"gorm.io/gorm"
+)
+
+// Enrollment statuses. A request is approved or rejected by staff; an
+// approved or active enrollment holds a seat. A waitlisted enrollment holds
+// a place in the queue for a full section or course and is promoted, oldest
+// first, as seats free up: to active, or to approved while its term is still
+// to come. Students leave a course by dropping it, or after the term's drop
+// deadline by withdrawing.
+const (
+	EnrollmentRequested  = "requested"
+	EnrollmentApproved   = "approved"
+	EnrollmentRejected   = "rejected"
+	EnrollmentWaitlisted = "waitlisted"
+	EnrollmentActive     = "active"
+	EnrollmentDropped    = "dropped"
+	EnrollmentWithdrawn  = "withdrawn"
+	EnrollmentCompleted  = "completed"
 )
 
+// LegacyEnrollmentStatuses maps statuses saved before enrollments had a
+// lifecycle to the statuses that replaced them
+var LegacyEnrollmentStatuses = map[string]string{
+	"pending": EnrollmentRequested,
+}
+
+// EnrollmentHoldsSeat reports whether enrollments with a status take up a
+// seat
+func EnrollmentHoldsSeat(status string) bool {
+	return status == EnrollmentApproved || status == EnrollmentActive
+}
+
+// ValidEnrollmentStatus reports whether status is one of the enrollment
+// statuses
+func ValidEnrollmentStatus(status string) bool {
+	switch status {
+	case EnrollmentRequested, EnrollmentApproved, EnrollmentRejected, EnrollmentWaitlisted,
+		EnrollmentActive, EnrollmentDropped, EnrollmentWithdrawn, EnrollmentCompleted:
+		return true
+	}
+	return false
+}
+
 type Enrollment struct {
 	ID         uint      `gorm:"primaryKey" json:"id"`
 	StudentID  uint      `json:"student_id"`
 	CourseID   uint      `json:"course_id"`
+	SectionID  *uint     `gorm:"index" json:"section_id,omitempty"`
+	TermID     *uint     `gorm:"index" json:"term_id,omitempty"`
 	EnrolledAt time.Time `json:"enrolled_at"`
 	Status     string    `gorm:"size:20;default:'active'" json:"status"`
+	// WaitlistedAt orders the waitlist; it is kept after promotion
+	WaitlistedAt *time.Time `json:"waitlisted_at,omitempty"`
 
 	// Relations
-	Student Student `gorm:"foreignKey:StudentID" json:"student"`
-	Course  Course  `gorm:"foreignKey:CourseID" json:"course"`
+	Student Student        `gorm:"foreignKey:StudentID" json:"student"`
+	Course  Course         `gorm:"foreignKey:CourseID" json:"course"`
+	Section *CourseSection `gorm:"foreignKey:SectionID" json:"section,omitempty"`
+	Term    *AcademicTerm  `gorm:"foreignKey:TermID" json:"term,omitempty"`
+}
+
+func (e *Enrollment) BeforeSave(tx *gorm.DB) error {
+	return resolveSection(tx, e.SectionID, &e.CourseID, &e.TermID)
+}
+
+// EnrollmentTransition records one change of an enrollment's status. The
+// first has an empty FromStatus and records the enrollment being made.
+type EnrollmentTransition struct {
+	ID           uint   `gorm:"primaryKey" json:"id"`
+	EnrollmentID uint   `gorm:"not null;index" json:"enrollment_id"`
+	FromStatus   string `gorm:"size:20" json:"from_status"`
+	ToStatus     string `gorm:"size:20;not null" json:"to_status"`
+	ActorID      *uint  `json:"actor_id,omitempty"` // nil for changes made by the system
+	Reason       string `gorm:"size:500" json:"reason,omitempty"`
+	CreatedAt    int64  `gorm:"index" json:"created_at"`
+
+	Actor *User `gorm:"foreignKey:ActorID" json:"actor,omitempty"`
+}
+
+func (EnrollmentTransition) TableName() string {
+	return "enrollment_transitions"
 }
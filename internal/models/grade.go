@@ -2,21 +2,48 @@ package models
 
 import (
 	"time"
+
+	"gorm.io/gorm"
+)
+
+// Non-graded letters that can be recorded in place of a scored letter grade.
+const (
+	GradePass       = "P"  // pass/fail course, passed: earns credit, no grade points
+	GradeNoPass     = "NP" // pass/fail course, not passed
+	GradeIncomplete = "I"  // work outstanding: not yet attempted for credit
+	GradeWithdrawn  = "W"  // withdrew after the drop deadline: attempted, not earned
+)
+
+// Grade sources: entered by hand, computed from the course gradebook, or
+// recorded by withdrawing from the course.
+const (
+	GradeSourceManual     = "manual"
+	GradeSourceGradebook  = "gradebook"
+	GradeSourceWithdrawal = "withdrawal"
 )
 
 type Grade struct {
 	ID        uint      `gorm:"primaryKey" json:"id"`
 	StudentID uint      `json:"student_id"`
 	CourseID  uint      `json:"course_id"`
+	SectionID *uint     `gorm:"index" json:"section_id,omitempty"`
+	TermID    *uint     `gorm:"index" json:"term_id,omitempty"`
 	Grade     string    `gorm:"size:5" json:"grade"`
 	Score     float64   `json:"score"`
 	MaxScore  float64   `gorm:"default:100" json:"max_score"`
 	Remarks   string    `gorm:"type:text" json:"remarks"`
+	Source    string    `gorm:"size:20;default:'manual'" json:"source"`
 	GradedBy  uint      `json:"graded_by"`
 	GradedAt  time.Time `json:"graded_at"`
 
 	// Relations
-	Student Student `gorm:"foreignKey:StudentID" json:"student"`
-	Course  Course  `gorm:"foreignKey:CourseID" json:"course"`
-	Teacher Teacher `gorm:"foreignKey:GradedBy" json:"teacher"`
+	Student Student        `gorm:"foreignKey:StudentID" json:"student"`
+	Course  Course         `gorm:"foreignKey:CourseID" json:"course"`
+	Teacher Teacher        `gorm:"foreignKey:GradedBy" json:"teacher"`
+	Section *CourseSection `gorm:"foreignKey:SectionID" json:"section,omitempty"`
+	Term    *AcademicTerm  `gorm:"foreignKey:TermID" json:"term,omitempty"`
+}
+
+func (g *Grade) BeforeSave(tx *gorm.DB) error {
+	return resolveSection(tx, g.SectionID, &g.CourseID, &g.TermID)
 }
@@ -0,0 +1,20 @@
+package models
+
+// GradeCategory groups a course's assignments (homework, quizzes, exams) and
+// gives the group a weight in the course grade.
+type GradeCategory struct {
+	ID         uint    `gorm:"primaryKey" json:"id"`
+	CourseID   uint    `gorm:"index;not null" json:"course_id"`
+	Name       string  `gorm:"size:100;not null" json:"name"`
+	Weight     float64 `json:"weight"`      // percentage of the course grade
+	DropLowest int     `json:"drop_lowest"` // lowest N graded assignments ignored
+
+	CreatedAt int64 `json:"created_at"`
+	UpdatedAt int64 `json:"updated_at"`
+
+	Course Course `gorm:"foreignKey:CourseID" json:"-"`
+}
+
+func (GradeCategory) TableName() string {
+	return "grade_categories"
+}
@@ -3,19 +3,30 @@ package models
 type GradeTranscript struct {
 	ID                 uint    `gorm:"primaryKey" json:"id"`
 	StudentID          uint    `json:"student_id"`
+	TermID             *uint   `gorm:"index" json:"term_id,omitempty"`
 	GPA                float64 `json:"gpa"`
-	TotalCredits       float64 `json:"total_credits"`
-	EarnedCredits      float64 `json:"earned_credits"`
+	TotalCredits       float64 `json:"total_credits"`   // credits attempted
+	EarnedCredits      float64 `json:"earned_credits"`  // credits passed, including transfer credit
+	QualityCredits     float64 `json:"quality_credits"` // credits that carry grade points
 	GradePointsSum     float64 `json:"grade_points_sum"`
+	TransferCredits    float64 `json:"transfer_credits"`
 	TranscriptSemester string  `json:"transcript_semester"`
 	Year               int     `json:"year"`
-	IsOfficial         bool    `json:"is_official"`
-	GeneratedAt        int64   `json:"generated_at"`
+
+	CumulativeGPA            float64 `json:"cumulative_gpa"`
+	CumulativeCredits        float64 `json:"cumulative_credits"`
+	CumulativeEarnedCredits  float64 `json:"cumulative_earned_credits"`
+	CumulativeQualityCredits float64 `json:"cumulative_quality_credits"`
+	CumulativeGradePoints    float64 `json:"cumulative_grade_points"`
+
+	IsOfficial  bool  `json:"is_official"`
+	GeneratedAt int64 `json:"generated_at"`
 
 	CreatedAt int64 `json:"created_at"`
 	UpdatedAt int64 `json:"updated_at"`
 
-	Student Student `gorm:"foreignKey:StudentID" json:"student,omitempty"`
+	Student Student       `gorm:"foreignKey:StudentID" json:"student,omitempty"`
+	Term    *AcademicTerm `gorm:"foreignKey:TermID" json:"term,omitempty"`
 }
 
 func (GradeTranscript) TableName() string {
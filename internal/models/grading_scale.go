@@ -0,0 +1,81 @@
+package models
+
+// Grading scale scopes, from most to least specific.
+const (
+	GradingScopeCourse     = "course"
+	GradingScopeDepartment = "department"
+	GradingScopeSchool     = "school"
+)
+
+// GradingScale maps percentage scores to letters and grade points. A scale is
+// assigned to the whole school, a department or a single course; the most
+// specific one wins.
+type GradingScale struct {
+	ID          uint          `gorm:"primaryKey" json:"id"`
+	Name        string        `gorm:"size:100;not null" json:"name"`
+	Description string        `json:"description"`
+	Scope       string        `gorm:"size:20;index;not null" json:"scope"`
+	Department  string        `gorm:"size:100;index" json:"department,omitempty"`
+	CourseID    *uint         `gorm:"index" json:"course_id,omitempty"`
+	Bands       []GradingBand `gorm:"foreignKey:ScaleID;constraint:OnDelete:CASCADE" json:"bands"`
+
+	CreatedAt int64 `json:"created_at"`
+	UpdatedAt int64 `json:"updated_at"`
+}
+
+func (GradingScale) TableName() string {
+	return "grading_scales"
+}
+
+// GradingBand is one step of a GradingScale: scores at or above MinPercentage
+// (and below the next band) receive Letter and GradePoints.
+type GradingBand struct {
+	ID            uint    `gorm:"primaryKey" json:"id"`
+	ScaleID       uint    `gorm:"index;not null" json:"scale_id"`
+	Letter        string  `gorm:"size:10;not null" json:"letter"`
+	MinPercentage float64 `json:"min_percentage"`
+	GradePoints   float64 `json:"grade_points"`
+	IsPassing     bool    `json:"is_passing"`
+}
+
+func (GradingBand) TableName() string {
+	return "grading_bands"
+}
+
+// BandForScore returns the band a percentage score falls into, or nil if the
+// score is below every band.
+func (s *GradingScale) BandForScore(score float64) *GradingBand {
+	var best *GradingBand
+	for i := range s.Bands {
+		b := &s.Bands[i]
+		if score >= b.MinPercentage && (best == nil || b.MinPercentage > best.MinPercentage) {
+			best = b
+		}
+	}
+	return best
+}
+
+// BandForLetter returns the band with the given letter, or nil.
+func (s *GradingScale) BandForLetter(letter string) *GradingBand {
+	for i := range s.Bands {
+		if s.Bands[i].Letter == letter {
+			return &s.Bands[i]
+		}
+	}
+	return nil
+}
+
+// DefaultGradingScale is the A–F, 4.0 scale used when no scale is configured.
+func DefaultGradingScale() *GradingScale {
+	return &GradingScale{
+		Name:  "Standard A-F",
+		Scope: GradingScopeSchool,
+		Bands: []GradingBand{
+			{Letter: "A", MinPercentage: 90, GradePoints: 4.0, IsPassing: true},
+			{Letter: "B", MinPercentage: 80, GradePoints: 3.0, IsPassing: true},
+			{Letter: "C", MinPercentage: 70, GradePoints: 2.0, IsPassing: true},
+			{Letter: "D", MinPercentage: 60, GradePoints: 1.0, IsPassing: true},
+			{Letter: "F", MinPercentage: 0, GradePoints: 0.0, IsPassing: false},
+		},
+	}
+}
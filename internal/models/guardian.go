@@ -0,0 +1,32 @@
+package models
+
+// Guardian relationship types
+const (
+	GuardianMother   = "mother"
+	GuardianFather   = "father"
+	GuardianGuardian = "guardian"
+	GuardianOther    = "other"
+)
+
+// Guardian links a parent account to a student. A student may have several
+// guardians and a guardian several children.
+type Guardian struct {
+	ID                 uint   `gorm:"primaryKey" json:"id"`
+	UserID             uint   `gorm:"uniqueIndex:idx_guardian_user_student;not null" json:"user_id"`
+	StudentID          uint   `gorm:"uniqueIndex:idx_guardian_user_student;index;not null" json:"student_id"`
+	Relationship       string `gorm:"size:20;not null" json:"relationship"`
+	HasCustody         bool   `json:"has_custody"`
+	IsEmergencyContact bool   `json:"is_emergency_contact"`
+	// ReceivesNotifications controls whether grade and attendance emails
+	// sent to the student are copied to this guardian.
+	ReceivesNotifications bool  `json:"receives_notifications"`
+	CreatedAt             int64 `json:"created_at"`
+	UpdatedAt             int64 `json:"updated_at"`
+
+	User    User    `gorm:"foreignKey:UserID" json:"user,omitempty"`
+	Student Student `gorm:"foreignKey:StudentID" json:"student,omitempty"`
+}
+
+func (Guardian) TableName() string {
+	return "guardians"
+}
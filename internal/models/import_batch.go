@@ -1,20 +1,45 @@
 package models
 
+// Import batch statuses
+const (
+	ImportStatusPending    = "pending"
+	ImportStatusProcessing = "processing"
+	ImportStatusCompleted  = "completed"
+	ImportStatusFailed     = "failed"
+)
+
+// Import entity types
+const (
+	ImportEntityStudent    = "student"
+	ImportEntityTeacher    = "teacher"
+	ImportEntityCourse     = "course"
+	ImportEntityEnrollment = "enrollment"
+	ImportEntityGrade      = "grade"
+)
+
+// ImportBatch tracks one uploaded CSV or XLSX file. A dry run validates
+// every row without saving anything. Errors holds a JSON array of per-row
+// errors.
 type ImportBatch struct {
-	ID          uint   `gorm:"primaryKey" json:"id"`
-	EntityType  string `json:"entity_type"` // student, teacher, course, grades
-	FileName    string `json:"file_name"`
-	TotalRows   int    `json:"total_rows"`
-	SuccessRows int    `json:"success_rows"`
-	FailedRows  int    `json:"failed_rows"`
-	Status      string `json:"status"` // pending, processing, completed, failed
-	Errors      string `json:"errors"`
-	CreatedBy   uint   `json:"created_by"`
+	ID            uint   `gorm:"primaryKey" json:"id"`
+	EntityType    string `json:"entity_type"` // student, teacher, course, enrollment, grade
+	FileName      string `json:"file_name"`
+	DryRun        bool   `json:"dry_run"`
+	TotalRows     int    `json:"total_rows"`
+	ProcessedRows int    `json:"processed_rows"`
+	SuccessRows   int    `json:"success_rows"`
+	CreatedRows   int    `json:"created_rows"`
+	UpdatedRows   int    `json:"updated_rows"`
+	FailedRows    int    `json:"failed_rows"`
+	Status        string `gorm:"index" json:"status"` // pending, processing, completed, failed
+	Errors        string `gorm:"type:text" json:"errors"`
+	CreatedBy     uint   `json:"created_by"`
 
-	CreatedAt int64 `json:"created_at"`
-	UpdatedAt int64 `json:"updated_at"`
+	CreatedAt   int64 `json:"created_at"`
+	UpdatedAt   int64 `json:"updated_at"`
+	CompletedAt int64 `json:"completed_at"`
 
-	CreatedByUser User `gorm:"foreignKey:CreatedBy" json:"created_by_user,omitempty"`
+	CreatedByUser *User `gorm:"foreignKey:CreatedBy" json:"created_by_user,omitempty"`
 }
 
 func (ImportBatch) TableName() string {
@@ -0,0 +1,25 @@
+package models
+
+// Login throttle scopes
+const (
+	ThrottleScopeAccount = "account"
+	ThrottleScopeIP      = "ip"
+)
+
+// LoginThrottle counts recent failed logins for one account (keyed by the
+// normalized email, whether or not it exists) or one client IP. It is kept
+// in the database so limits hold across restarts and server instances.
+type LoginThrottle struct {
+	ID            uint   `gorm:"primaryKey" json:"id"`
+	Scope         string `gorm:"size:10;uniqueIndex:idx_login_throttle_scope_key;not null" json:"scope"`
+	Key           string `gorm:"size:255;uniqueIndex:idx_login_throttle_scope_key;not null" json:"key"`
+	Failures      int    `json:"failures"`
+	LastFailureAt int64  `json:"last_failure_at"`
+	LockedUntil   int64  `gorm:"index" json:"locked_until"`
+	CreatedAt     int64  `json:"created_at"`
+	UpdatedAt     int64  `json:"updated_at"`
+}
+
+func (LoginThrottle) TableName() string {
+	return "login_throttles"
+}
@@ -1,19 +1,49 @@
 package models
 
+// Message is one post in a conversation. ReceiverID is only set on direct
+// messages, where it names the other participant.
 type Message struct {
-	ID         uint   `gorm:"primaryKey" json:"id"`
-	SenderID   uint   `json:"sender_id"`
-	ReceiverID uint   `json:"receiver_id"`
-	Content    string `json:"content"`
-	IsRead     bool   `json:"is_read"`
-	ReadAt     int64  `json:"read_at"`
-	CreatedAt  int64  `json:"created_at"`
-	UpdatedAt  int64  `json:"updated_at"`
+	ID             uint   `gorm:"primaryKey" json:"id"`
+	ConversationID uint   `gorm:"index" json:"conversation_id"`
+	SenderID       uint   `gorm:"index" json:"sender_id"`
+	ReceiverID     uint   `json:"receiver_id,omitempty"`
+	Content        string `gorm:"type:text" json:"content"`
+	// ReplyToID is the message replied to. A private reply to a broadcast
+	// points at the broadcast message from the direct conversation.
+	ReplyToID *uint `json:"reply_to_id,omitempty"`
+	IsRead    bool  `json:"is_read"`
+	ReadAt    int64 `json:"read_at"`
+	EditedAt  int64 `json:"edited_at,omitempty"`
+	// DeletedAt marks a soft-deleted message. It stays in the thread as a
+	// placeholder with its content and attachments removed.
+	DeletedAt int64 `gorm:"index" json:"deleted_at,omitempty"`
+	DeletedBy uint  `json:"deleted_by,omitempty"`
+	CreatedAt int64 `json:"created_at"`
+	UpdatedAt int64 `json:"updated_at"`
 
-	Sender   User `gorm:"foreignKey:SenderID" json:"sender,omitempty"`
-	Receiver User `gorm:"foreignKey:ReceiverID" json:"receiver,omitempty"`
+	Sender      User                `gorm:"foreignKey:SenderID" json:"sender,omitempty"`
+	Receiver    User                `gorm:"foreignKey:ReceiverID" json:"receiver,omitempty"`
+	Attachments []MessageAttachment `gorm:"foreignKey:MessageID" json:"attachments,omitempty"`
 }
 
 func (Message) TableName() string {
 	return "messages"
 }
+
+// MessageAttachment is a file uploaded for a message. It is uploaded first,
+// with MessageID 0, and claimed by the message it is sent with.
+type MessageAttachment struct {
+	ID          uint   `gorm:"primaryKey" json:"id"`
+	MessageID   uint   `gorm:"index" json:"message_id"`
+	UploadedBy  uint   `gorm:"index;not null" json:"uploaded_by"`
+	FileName    string `gorm:"size:255;not null" json:"file_name"`
+	ContentType string `gorm:"size:100" json:"content_type"`
+	Size        int64  `json:"size"`
+	SHA256      string `gorm:"size:64" json:"sha256"`
+	StoragePath string `gorm:"size:500;not null" json:"-"`
+	CreatedAt   int64  `gorm:"index" json:"created_at"`
+}
+
+func (MessageAttachment) TableName() string {
+	return "message_attachments"
+}
@@ -0,0 +1,34 @@
+package models
+
+// UserMFA holds a user's TOTP enrollment. A row with Enabled false is an
+// enrollment that was started but not yet confirmed with a code.
+type UserMFA struct {
+	ID        uint   `gorm:"primaryKey" json:"id"`
+	UserID    uint   `gorm:"uniqueIndex;not null" json:"user_id"`
+	Secret    string `gorm:"size:64;not null" json:"-"`
+	Enabled   bool   `json:"enabled"`
+	EnabledAt int64  `json:"enabled_at"`
+	// LastUsedStep is the TOTP time step of the last accepted code, so the
+	// same code cannot be used twice
+	LastUsedStep int64 `json:"-"`
+	CreatedAt    int64 `json:"created_at"`
+	UpdatedAt    int64 `json:"updated_at"`
+}
+
+func (UserMFA) TableName() string {
+	return "user_mfa"
+}
+
+// MFARecoveryCode is a single-use code that can stand in for a TOTP code
+// when the user has lost their device. Only its SHA-256 hash is stored.
+type MFARecoveryCode struct {
+	ID        uint   `gorm:"primaryKey" json:"id"`
+	UserID    uint   `gorm:"index;not null" json:"user_id"`
+	CodeHash  string `gorm:"size:64;index;not null" json:"-"`
+	UsedAt    int64  `json:"used_at"`
+	CreatedAt int64  `json:"created_at"`
+}
+
+func (MFARecoveryCode) TableName() string {
+	return "mfa_recovery_codes"
+}
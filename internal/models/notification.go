@@ -7,6 +7,7 @@ type Notification struct {
 	Message   string `json:"message"`
 	Type      string `json:"type"` // email, sms, in-app
 	Subject   string `json:"subject"`
+	Event     string `gorm:"size:50;index" json:"event,omitempty"` // the event that raised the notification, if any
 	IsRead    bool   `json:"is_read"`
 	SentAt    int64  `json:"sent_at"`
 	CreatedAt int64  `json:"created_at"`
@@ -0,0 +1,133 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// Notification channels
+const (
+	ChannelInApp   = "in-app"
+	ChannelEmail   = "email"
+	ChannelSMS     = "sms"
+	ChannelWebhook = "webhook"
+)
+
+// Notification delivery statuses. A skipped delivery could not be made,
+// for example an SMS to a user without a phone number.
+const (
+	DeliveryStatusPending = "pending"
+	DeliveryStatusSent    = "sent"
+	DeliveryStatusFailed  = "failed"
+	DeliveryStatusSkipped = "skipped"
+)
+
+// NotificationPreference turns one channel on or off for one event. Without
+// a row the event's default channels apply.
+type NotificationPreference struct {
+	ID        uint   `gorm:"primaryKey" json:"-"`
+	UserID    uint   `gorm:"not null;uniqueIndex:idx_notification_pref" json:"-"`
+	Event     string `gorm:"size:50;not null;uniqueIndex:idx_notification_pref" json:"event"`
+	Channel   string `gorm:"size:20;not null;uniqueIndex:idx_notification_pref" json:"channel"`
+	Enabled   bool   `json:"enabled"`
+	UpdatedAt int64  `json:"updated_at"`
+}
+
+func (NotificationPreference) TableName() string {
+	return "notification_preferences"
+}
+
+// NotificationSettings holds a user's quiet hours and webhook. During quiet
+// hours email and SMS notifications are held until the hours end; in-app
+// notifications still arrive.
+type NotificationSettings struct {
+	UserID          uint   `gorm:"primaryKey" json:"-"`
+	QuietHoursStart string `gorm:"size:5" json:"quiet_hours_start"` // "22:00", empty for no quiet hours
+	QuietHoursEnd   string `gorm:"size:5" json:"quiet_hours_end"`
+	Timezone        string `gorm:"size:64" json:"timezone"` // IANA name, UTC when empty
+	WebhookURL      string `gorm:"size:500" json:"webhook_url"`
+	// WebhookSecret signs webhook requests so the receiver can verify them
+	WebhookSecret string `gorm:"size:64" json:"webhook_secret,omitempty"`
+	UpdatedAt     int64  `json:"updated_at"`
+}
+
+func (NotificationSettings) TableName() string {
+	return "notification_settings"
+}
+
+// ParseClock parses a time of day such as "07:30" into minutes after
+// midnight
+func ParseClock(value string) (int, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q, want HH:MM", value)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// QuietUntil reports whether now falls in the user's quiet hours, and if
+// so when they end. Quiet hours may span midnight, e.g. 22:00 to 07:00.
+func (s *NotificationSettings) QuietUntil(now time.Time) (time.Time, bool) {
+	if s == nil || s.QuietHoursStart == "" || s.QuietHoursEnd == "" {
+		return time.Time{}, false
+	}
+	start, err := ParseClock(s.QuietHoursStart)
+	if err != nil {
+		return time.Time{}, false
+	}
+	end, err := ParseClock(s.QuietHoursEnd)
+	if err != nil || start == end {
+		return time.Time{}, false
+	}
+	loc := time.UTC
+	if s.Timezone != "" {
+		if l, err := time.LoadLocation(s.Timezone); err == nil {
+			loc = l
+		}
+	}
+
+	local := now.In(loc)
+	minute := local.Hour()*60 + local.Minute()
+	midnight := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+	endToday := midnight.Add(time.Duration(end) * time.Minute)
+
+	if start < end {
+		if minute >= start && minute < end {
+			return endToday, true
+		}
+		return time.Time{}, false
+	}
+	// The window spans midnight
+	switch {
+	case minute >= start:
+		return midnight.AddDate(0, 0, 1).Add(time.Duration(end) * time.Minute), true
+	case minute < end:
+		return endToday, true
+	}
+	return time.Time{}, false
+}
+
+// NotificationDelivery is a notification waiting to go out, or gone out,
+// on an external channel. Payload holds what the channel needs to send
+// it, so deliveries held for quiet hours or retried after a failure can be
+// sent later.
+type NotificationDelivery struct {
+	ID             uint   `gorm:"primaryKey" json:"id"`
+	NotificationID uint   `gorm:"index" json:"notification_id,omitempty"`
+	UserID         uint   `gorm:"index;not null" json:"user_id"`
+	Event          string `gorm:"size:50;not null" json:"event"`
+	Channel        string `gorm:"size:20;not null" json:"channel"`
+	Status         string `gorm:"size:20;index" json:"status"`
+	Attempts       int    `json:"attempts"`
+	NotBefore      int64  `gorm:"index" json:"not_before"`
+	LastError      string `gorm:"type:text" json:"last_error,omitempty"`
+	Payload        string `gorm:"type:text" json:"-"`
+	LockedUntil    int64  `json:"-"`
+	SentAt         int64  `json:"sent_at"`
+	CreatedAt      int64  `json:"created_at"`
+	UpdatedAt      int64  `json:"updated_at"`
+}
+
+func (NotificationDelivery) TableName() string {
+	return "notification_deliveries"
+}
@@ -0,0 +1,61 @@
+package models
+
+// Outbox email statuses. An email is pending while any recipient still
+// has delivery attempts left, sent once every recipient has it, and
+// failed once no recipient is pending but at least one was dead-lettered.
+const (
+	OutboxStatusPending = "pending"
+	OutboxStatusSent    = "sent"
+	OutboxStatusFailed  = "failed"
+)
+
+// Outbox recipient statuses. A dead recipient will not be retried unless
+// an admin requeues the email.
+const (
+	RecipientStatusPending = "pending"
+	RecipientStatusSent    = "sent"
+	RecipientStatusDead    = "dead"
+)
+
+// OutboxEmail is a queued email. Services enqueue emails and a background
+// worker delivers them, retrying with exponential backoff. LockedUntil
+// is set while a worker is delivering the email, so each email is handled
+// by one server instance at a time.
+type OutboxEmail struct {
+	ID            uint   `gorm:"primaryKey" json:"id"`
+	Subject       string `json:"subject"`
+	Body          string `gorm:"type:text" json:"body"`
+	TextBody      string `gorm:"type:text" json:"text_body,omitempty"`
+	IsHTML        bool   `json:"is_html"`
+	Status        string `gorm:"index;size:20" json:"status"`
+	Attempts      int    `json:"attempts"`
+	NextAttemptAt int64  `gorm:"index" json:"next_attempt_at"`
+	LastError     string `gorm:"type:text" json:"last_error,omitempty"`
+	LockedUntil   int64  `json:"-"`
+	LockedBy      string `json:"-"`
+	SentAt        int64  `json:"sent_at"`
+
+	CreatedAt int64 `gorm:"index" json:"created_at"`
+	UpdatedAt int64 `json:"updated_at"`
+
+	Recipients []OutboxRecipient `gorm:"foreignKey:EmailID;constraint:OnDelete:CASCADE" json:"recipients,omitempty"`
+}
+
+func (OutboxEmail) TableName() string {
+	return "outbox_emails"
+}
+
+// OutboxRecipient tracks delivery of an outbox email to one address
+type OutboxRecipient struct {
+	ID        uint   `gorm:"primaryKey" json:"id"`
+	EmailID   uint   `gorm:"index;not null" json:"email_id"`
+	Address   string `json:"address"`
+	Status    string `gorm:"size:20" json:"status"`
+	Attempts  int    `json:"attempts"`
+	LastError string `gorm:"type:text" json:"last_error,omitempty"`
+	SentAt    int64  `json:"sent_at"`
+}
+
+func (OutboxRecipient) TableName() string {
+	return "outbox_recipients"
+}
@@ -1,16 +1,27 @@
 package models
 
+// Payment statuses
+const (
+	PaymentStatusPending   = "pending"
+	PaymentStatusPaid      = "paid"
+	PaymentStatusOverdue   = "overdue"
+	PaymentStatusCancelled = "cancelled"
+)
+
 type Payment struct {
 	ID            uint    `gorm:"primaryKey" json:"id"`
 	StudentID     uint    `json:"student_id"`
 	Amount        float64 `json:"amount"`
 	Description   string  `json:"description"`
-	Status        string  `json:"status"` // pending, paid, overdue, cancelled
+	Status        string  `gorm:"index" json:"status"` // pending, paid, overdue, cancelled
 	DueDate       int64   `json:"due_date"`
 	PaidDate      int64   `json:"paid_date"`
 	PaymentMethod string  `json:"payment_method"` // cash, check, online
 	TransactionID string  `json:"transaction_id"`
 
+	// ReminderSentAt is when the student was last reminded to pay
+	ReminderSentAt int64 `json:"reminder_sent_at"`
+
 	CreatedAt int64 `json:"created_at"`
 	UpdatedAt int64 `json:"updated_at"`
 
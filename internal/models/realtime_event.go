@@ -0,0 +1,17 @@
+package models
+
+// RealtimeEvent is an event pushed to connected clients, kept for a while
+// so a client that reconnects can resume from the last event it received.
+// UserID is 0 for broadcasts; Role then limits who receives them.
+type RealtimeEvent struct {
+	ID        uint64 `gorm:"primaryKey" json:"id"`
+	UserID    uint   `gorm:"index" json:"user_id"`
+	Role      string `gorm:"size:20" json:"role"`
+	Type      string `gorm:"size:50;not null" json:"type"`
+	Data      string `gorm:"type:text" json:"data"`
+	CreatedAt int64  `gorm:"index" json:"created_at"`
+}
+
+func (RealtimeEvent) TableName() string {
+	return "realtime_events"
+}
@@ -0,0 +1,37 @@
+package models
+
+// RefreshToken is a server-side record of an issued refresh token. Only the
+// SHA-256 hash of the token is stored. Each refresh rotates the token: the
+// old one is revoked and replaced by a new one in the same family, so a
+// revoked token being presented again means it was stolen and the whole
+// family is revoked.
+type RefreshToken struct {
+	ID           uint   `gorm:"primaryKey" json:"id"`
+	UserID       uint   `gorm:"index;not null" json:"user_id"`
+	TokenHash    string `gorm:"size:64;uniqueIndex;not null" json:"-"`
+	FamilyID     string `gorm:"size:36;index;not null" json:"family_id"`
+	ExpiresAt    int64  `gorm:"index" json:"expires_at"`
+	RevokedAt    int64  `json:"revoked_at"`
+	ReplacedByID *uint  `json:"replaced_by_id,omitempty"`
+	IPAddress    string `gorm:"size:45" json:"ip_address"`
+	UserAgent    string `gorm:"size:255" json:"user_agent"`
+	CreatedAt    int64  `json:"created_at"`
+}
+
+func (RefreshToken) TableName() string {
+	return "refresh_tokens"
+}
+
+// RevokedToken is an access token revoked before it expired, identified by
+// its jti claim. Rows can be purged once ExpiresAt has passed.
+type RevokedToken struct {
+	ID        uint   `gorm:"primaryKey" json:"id"`
+	JTI       string `gorm:"column:jti;size:36;uniqueIndex;not null" json:"jti"`
+	UserID    uint   `gorm:"index" json:"user_id"`
+	ExpiresAt int64  `gorm:"index" json:"expires_at"`
+	CreatedAt int64  `json:"created_at"`
+}
+
+func (RevokedToken) TableName() string {
+	return "revoked_tokens"
+}
@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// RegistrationCartItem is a section a student has picked to register for,
+// before registering
+type RegistrationCartItem struct {
+	ID        uint  `gorm:"primaryKey" json:"id"`
+	StudentID uint  `gorm:"not null;uniqueIndex:idx_cart_student_section" json:"student_id"`
+	SectionID uint  `gorm:"not null;uniqueIndex:idx_cart_student_section;index" json:"section_id"`
+	CreatedAt int64 `json:"created_at"`
+
+	Section CourseSection `gorm:"foreignKey:SectionID" json:"section"`
+}
+
+func (RegistrationCartItem) TableName() string {
+	return "registration_cart_items"
+}
+
+// RegistrationPriority lets a student register for a term from OpensAt,
+// ahead of (or after) the term's registration start
+type RegistrationPriority struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	TermID    uint      `gorm:"not null;uniqueIndex:idx_registration_priority" json:"term_id"`
+	StudentID uint      `gorm:"not null;uniqueIndex:idx_registration_priority;index" json:"student_id"`
+	OpensAt   time.Time `gorm:"not null" json:"opens_at"`
+	CreatedAt int64     `json:"created_at"`
+	UpdatedAt int64     `json:"updated_at"`
+}
+
+func (RegistrationPriority) TableName() string {
+	return "registration_priorities"
+}
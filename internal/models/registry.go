@@ -0,0 +1,67 @@
+package models
+
+// All returns every model with a database table, for migrations
+func All() []interface{} {
+	return []interface{}{
+		&User{},
+		&Student{},
+		&Teacher{},
+		&Course{},
+		&AcademicTerm{},
+		&CourseSection{},
+		&Enrollment{},
+		&EnrollmentTransition{},
+		&CourseRequisite{},
+		&RegistrationCartItem{},
+		&RegistrationPriority{},
+		&Grade{},
+		&Attendance{},
+		&Assignment{},
+		&AssignmentSubmission{},
+		&SystemSetting{},
+		&AuditLog{},
+		&Notification{},
+		&Announcement{},
+		&AnnouncementTarget{},
+		&AnnouncementRecipient{},
+		&Conversation{},
+		&ConversationParticipant{},
+		&Message{},
+		&MessageAttachment{},
+		&Payment{},
+		&TimeTable{},
+		&Room{},
+		&TeacherAvailability{},
+		&TimetableDraft{},
+		&TimetableDraftSlot{},
+		&Resource{},
+		&Booking{},
+		&GradeTranscript{},
+		&TransferCredit{},
+		&GradingScale{},
+		&GradingBand{},
+		&GradeCategory{},
+		&Guardian{},
+		&RefreshToken{},
+		&RevokedToken{},
+		&AccountToken{},
+		&PasswordHistory{},
+		&UserMFA{},
+		&MFARecoveryCode{},
+		&LoginThrottle{},
+		&Backup{},
+		&ImportBatch{},
+		&ScheduledJob{},
+		&JobRun{},
+		&SchedulerLease{},
+		&OutboxEmail{},
+		&OutboxRecipient{},
+		&EmailTemplate{},
+		&NotificationPreference{},
+		&NotificationSettings{},
+		&NotificationDelivery{},
+		&RealtimeEvent{},
+		&AssignmentRubric{},
+		&RubricScore{},
+	}
+}
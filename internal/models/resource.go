@@ -0,0 +1,21 @@
+package models
+
+// Resource is bookable equipment, such as projectors or sets of lab
+// benches, Quantity of which can be booked at once. RoomID is the room it
+// is kept in, if any.
+type Resource struct {
+	ID        uint   `gorm:"primaryKey" json:"id"`
+	Name      string `gorm:"size:100;uniqueIndex;not null" json:"name"`
+	Type      string `gorm:"size:50;index" json:"type"` // e.g. projector, lab_bench
+	RoomID    *uint  `gorm:"index" json:"room_id,omitempty"`
+	Quantity  int    `json:"quantity"`
+	IsActive  bool   `json:"is_active"`
+	CreatedAt int64  `json:"created_at"`
+	UpdatedAt int64  `json:"updated_at"`
+
+	Room *Room `gorm:"foreignKey:RoomID" json:"room,omitempty"`
+}
+
+func (Resource) TableName() string {
+	return "resources"
+}
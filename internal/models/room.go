@@ -0,0 +1,48 @@
+package models
+
+import (
+	"slices"
+	"strings"
+)
+
+// Room is a teaching space classes are timetabled in and that can be
+// booked. Its name is what TimeTable.Classroom and CourseSection.Room refer
+// to; Features lists the equipment fitted in it.
+type Room struct {
+	ID        uint   `gorm:"primaryKey" json:"id"`
+	Name      string `gorm:"size:50;uniqueIndex;not null" json:"name"`
+	Building  string `gorm:"size:100;index" json:"building"`
+	Capacity  int    `json:"capacity"`
+	Features  string `gorm:"size:255" json:"features"` // comma-separated, e.g. "lab,projector"
+	IsActive  bool   `json:"is_active"`
+	CreatedAt int64  `json:"created_at"`
+	UpdatedAt int64  `json:"updated_at"`
+}
+
+func (Room) TableName() string {
+	return "rooms"
+}
+
+// HasFeatures reports whether the room has every feature in a
+// comma-separated list
+func (r *Room) HasFeatures(required string) bool {
+	have := ParseFeatures(r.Features)
+	for _, feature := range ParseFeatures(required) {
+		if !slices.Contains(have, feature) {
+			return false
+		}
+	}
+	return true
+}
+
+// ParseFeatures splits a comma-separated feature list into lower case
+// names, dropping blanks
+func ParseFeatures(list string) []string {
+	var features []string
+	for _, feature := range strings.Split(list, ",") {
+		if feature = strings.ToLower(strings.TrimSpace(feature)); feature != "" {
+			features = append(features, feature)
+		}
+	}
+	return features
+}
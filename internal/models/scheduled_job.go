@@ -0,0 +1,75 @@
+package models
+
+// Job run statuses
+const (
+	JobRunRunning   = "running"
+	JobRunSucceeded = "succeeded"
+	JobRunFailed    = "failed"
+)
+
+// Job run triggers
+const (
+	JobTriggerScheduled = "scheduled"
+	JobTriggerManual    = "manual"
+)
+
+// ScheduledJob is a recurring background job. Jobs are defined in code and
+// recorded here on startup; the row holds the state shared by every server
+// instance. An empty Schedule means the job only runs when triggered.
+// RunningSince is set while an instance runs the job, so the same job
+// never runs twice at once.
+type ScheduledJob struct {
+	ID           uint   `gorm:"primaryKey" json:"id"`
+	Name         string `gorm:"uniqueIndex;size:100" json:"name"`
+	Description  string `json:"description"`
+	Schedule     string `json:"schedule"`
+	Paused       bool   `json:"paused"`
+	NextRunAt    int64  `gorm:"index" json:"next_run_at"`
+	RunningSince int64  `json:"running_since"`
+	RunningOn    string `json:"running_on,omitempty"`
+
+	LastRunAt      int64  `json:"last_run_at"`
+	LastStatus     string `json:"last_status"`
+	LastError      string `gorm:"type:text" json:"last_error,omitempty"`
+	LastDurationMs int64  `json:"last_duration_ms"`
+
+	CreatedAt int64 `json:"created_at"`
+	UpdatedAt int64 `json:"updated_at"`
+}
+
+func (ScheduledJob) TableName() string {
+	return "scheduled_jobs"
+}
+
+// JobRun is one execution of a scheduled job
+type JobRun struct {
+	ID          uint   `gorm:"primaryKey" json:"id"`
+	JobName     string `gorm:"index;size:100" json:"job_name"`
+	Trigger     string `json:"trigger"` // scheduled, manual
+	TriggeredBy *uint  `json:"triggered_by,omitempty"`
+	Instance    string `json:"instance"`
+	Status      string `gorm:"index" json:"status"` // running, succeeded, failed
+	Result      string `gorm:"type:text" json:"result,omitempty"`
+	Error       string `gorm:"type:text" json:"error,omitempty"`
+	StartedAt   int64  `gorm:"index" json:"started_at"`
+	FinishedAt  int64  `json:"finished_at"`
+	DurationMs  int64  `json:"duration_ms"`
+}
+
+func (JobRun) TableName() string {
+	return "job_runs"
+}
+
+// SchedulerLease elects the one server instance that starts scheduled
+// jobs. The holder renews it while running; once it expires another
+// instance may take over.
+type SchedulerLease struct {
+	Name      string `gorm:"primaryKey;size:100" json:"name"`
+	Holder    string `json:"holder"`
+	ExpiresAt int64  `json:"expires_at"`
+	UpdatedAt int64  `json:"updated_at"`
+}
+
+func (SchedulerLease) TableName() string {
+	return "scheduler_leases"
+}
@@ -0,0 +1,16 @@
+package models
+
+// TeacherAvailability is a weekly window a teacher can teach in. A teacher
+// without any windows is available all week.
+type TeacherAvailability struct {
+	ID        uint   `gorm:"primaryKey" json:"id"`
+	TeacherID uint   `gorm:"not null;index" json:"teacher_id"`
+	DayOfWeek string `gorm:"size:10;not null" json:"day_of_week"`
+	StartTime string `gorm:"size:5;not null" json:"start_time"` // HH:MM
+	EndTime   string `gorm:"size:5;not null" json:"end_time"`   // HH:MM
+	CreatedAt int64  `json:"created_at"`
+}
+
+func (TeacherAvailability) TableName() string {
+	return "teacher_availabilities"
+}
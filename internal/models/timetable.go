@@ -1,8 +1,12 @@
 package models
 
+import "gorm.io/gorm"
+
 type TimeTable struct {
 	ID        uint   `gorm:"primaryKey" json:"id"`
 	CourseID  uint   `json:"course_id"`
+	SectionID *uint  `gorm:"index" json:"section_id,omitempty"`
+	TermID    *uint  `gorm:"index" json:"term_id,omitempty"`
 	TeacherID uint   `json:"teacher_id"`
 	DayOfWeek string `json:"day_of_week"` // Monday, Tuesday, etc.
 	StartTime string `json:"start_time"`  // HH:MM format
@@ -12,10 +16,15 @@ type TimeTable struct {
 	CreatedAt int64  `json:"created_at"`
 	UpdatedAt int64  `json:"updated_at"`
 
-	Course  Course  `gorm:"foreignKey:CourseID" json:"course,omitempty"`
-	Teacher Teacher `gorm:"foreignKey:TeacherID" json:"teacher,omitempty"`
+	Course  Course         `gorm:"foreignKey:CourseID" json:"course,omitempty"`
+	Teacher Teacher        `gorm:"foreignKey:TeacherID" json:"teacher,omitempty"`
+	Section *CourseSection `gorm:"foreignKey:SectionID" json:"section,omitempty"`
 }
 
 func (TimeTable) TableName() string {
 	return "timetables"
 }
+
+func (t *TimeTable) BeforeSave(tx *gorm.DB) error {
+	return resolveSection(tx, t.SectionID, &t.CourseID, &t.TermID)
+}
@@ -0,0 +1,48 @@
+package models
+
+// Timetable draft statuses
+const (
+	TimetableDraftPreview   = "preview"
+	TimetableDraftCommitted = "committed"
+	TimetableDraftDiscarded = "discarded"
+)
+
+// TimetableDraft is a generated timetable for some of a term's sections,
+// kept for review until it is committed to the timetable or discarded.
+// Options and Report hold, as JSON, the generator's options and its score
+// with the classes it could not place.
+type TimetableDraft struct {
+	ID          uint   `gorm:"primaryKey" json:"id"`
+	TermID      uint   `gorm:"not null;index" json:"term_id"`
+	Status      string `gorm:"size:20;not null;index" json:"status"`
+	Score       int    `json:"score"`
+	Options     string `gorm:"type:text" json:"-"`
+	Report      string `gorm:"type:text" json:"-"`
+	CreatedBy   uint   `json:"created_by"`
+	CreatedAt   int64  `json:"created_at"`
+	CommittedAt int64  `json:"committed_at,omitempty"`
+
+	Slots []TimetableDraftSlot `gorm:"foreignKey:DraftID" json:"slots,omitempty"`
+}
+
+func (TimetableDraft) TableName() string {
+	return "timetable_drafts"
+}
+
+// TimetableDraftSlot is one weekly class of a draft, becoming a TimeTable
+// row when the draft is committed
+type TimetableDraftSlot struct {
+	ID        uint   `gorm:"primaryKey" json:"id"`
+	DraftID   uint   `gorm:"not null;index" json:"draft_id"`
+	SectionID uint   `gorm:"not null" json:"section_id"`
+	CourseID  uint   `json:"course_id"`
+	TeacherID uint   `json:"teacher_id"`
+	DayOfWeek string `gorm:"size:10" json:"day_of_week"`
+	StartTime string `gorm:"size:5" json:"start_time"`
+	EndTime   string `gorm:"size:5" json:"end_time"`
+	Classroom string `gorm:"size:50" json:"classroom"`
+}
+
+func (TimetableDraftSlot) TableName() string {
+	return "timetable_draft_slots"
+}
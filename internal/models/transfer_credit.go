@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+)
+
+// TransferCredit is credit a student earned at another institution and that
+// has been accepted towards their record here.
+type TransferCredit struct {
+	ID                 uint      `gorm:"primaryKey" json:"id"`
+	StudentID          uint      `gorm:"index;not null" json:"student_id"`
+	TermID             *uint     `gorm:"index" json:"term_id,omitempty"` // term the credit is posted in; nil for credit accepted at admission
+	Institution        string    `gorm:"size:200;not null" json:"institution"`
+	CourseTitle        string    `gorm:"size:200" json:"course_title"`
+	EquivalentCourseID *uint     `json:"equivalent_course_id,omitempty"`
+	Credits            float64   `json:"credits"`
+	Grade              string    `gorm:"size:5" json:"grade"`
+	CountsInGPA        bool      `gorm:"default:false" json:"counts_in_gpa"`
+	ApprovedBy         uint      `json:"approved_by"`
+	CreatedAt          time.Time `json:"created_at"`
+
+	Student          Student       `gorm:"foreignKey:StudentID" json:"student,omitempty"`
+	Term             *AcademicTerm `gorm:"foreignKey:TermID" json:"term,omitempty"`
+	EquivalentCourse *Course       `gorm:"foreignKey:EquivalentCourseID" json:"equivalent_course,omitempty"`
+}
+
+func (TransferCredit) TableName() string {
+	return "transfer_credits"
+}
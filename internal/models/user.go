@@ -28,8 +28,13 @@ type User struct {
 	Address      string    `gorm:"type:text" json:"address"`
 	ProfileImage string    `json:"profile_image"`
 	IsActive     bool      `gorm:"default:true" json:"is_active"`
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
+	// Locale picks the language of emails sent to the user, e.g. "en" or "fr-CA"
+	Locale string `gorm:"size:10;default:en" json:"locale"`
+	// EmailVerified is set once the user follows the link sent on registration
+	EmailVerified   bool       `json:"email_verified"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
 
 	// Relations
 	Student *Student `json:"student,omitempty"`
@@ -0,0 +1,59 @@
+package realtime
+
+import (
+	"context"
+	"sync"
+)
+
+// Broker carries events to every server instance. Each instance subscribes
+// once and delivers what it receives to its own Hub.
+type Broker interface {
+	Publish(ctx context.Context, event Event) error
+	Subscribe(handler func(Event)) (unsubscribe func())
+	Close() error
+}
+
+// MemoryBroker delivers events within one process. It is the default, and
+// enough when a single instance serves all clients.
+type MemoryBroker struct {
+	mu       sync.RWMutex
+	handlers map[int]func(Event)
+	next     int
+}
+
+func NewMemoryBroker() *MemoryBroker {
+	return &MemoryBroker{handlers: map[int]func(Event){}}
+}
+
+func (b *MemoryBroker) Publish(ctx context.Context, event Event) error {
+	b.mu.RLock()
+	handlers := make([]func(Event), 0, len(b.handlers))
+	for _, h := range b.handlers {
+		handlers = append(handlers, h)
+	}
+	b.mu.RUnlock()
+	for _, h := range handlers {
+		h(event)
+	}
+	return nil
+}
+
+func (b *MemoryBroker) Subscribe(handler func(Event)) func() {
+	b.mu.Lock()
+	id := b.next
+	b.next++
+	b.handlers[id] = handler
+	b.mu.Unlock()
+	return func() {
+		b.mu.Lock()
+		delete(b.handlers, id)
+		b.mu.Unlock()
+	}
+}
+
+func (b *MemoryBroker) Close() error {
+	b.mu.Lock()
+	b.handlers = map[int]func(Event){}
+	b.mu.Unlock()
+	return nil
+}
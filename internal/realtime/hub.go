@@ -0,0 +1,147 @@
+// Package realtime pushes events to connected clients. A Hub holds each
+// server instance's connections; a Broker carries events between instances.
+package realtime
+
+import (
+	"encoding/json"
+	"sync"
+)
+
+// Event types pushed to clients
+const (
+	TypeNotificationCreated   = "notification.created"
+	TypeNotificationRead      = "notification.read"
+	TypeMessageCreated        = "message.created"
+	TypeMessageRead           = "message.read"
+	TypeMessageUpdated        = "message.updated"
+	TypeMessageDeleted        = "message.deleted"
+	TypeAnnouncementPublished = "announcement.published"
+)
+
+// Event is pushed to one user, or broadcast when UserID is 0. A broadcast
+// with a Role only reaches users with that role.
+type Event struct {
+	ID        uint64          `json:"id"`
+	UserID    uint            `json:"user_id,omitempty"`
+	Role      string          `json:"role,omitempty"`
+	Type      string          `json:"type"`
+	Data      json.RawMessage `json:"data"`
+	CreatedAt int64           `json:"created_at"`
+}
+
+// For reports whether the event should reach a user
+func (e *Event) For(userID uint, role string) bool {
+	if e.UserID != 0 {
+		return e.UserID == userID
+	}
+	return e.Role == "" || e.Role == role
+}
+
+// Client is one connection. Events are buffered; a client that falls too
+// far behind is dropped and Done is closed, so it can reconnect and resume
+// from the last event it received.
+type Client struct {
+	UserID uint
+	Role   string
+	events chan Event
+	done   chan struct{}
+	once   sync.Once
+}
+
+func (c *Client) Events() <-chan Event {
+	return c.events
+}
+
+func (c *Client) Done() <-chan struct{} {
+	return c.done
+}
+
+func (c *Client) close() {
+	c.once.Do(func() { close(c.done) })
+}
+
+// Hub tracks the connected clients of this server instance by user
+type Hub struct {
+	mu         sync.RWMutex
+	clients    map[uint]map[*Client]struct{}
+	bufferSize int
+}
+
+// NewHub creates a hub whose clients buffer up to bufferSize events
+func NewHub(bufferSize int) *Hub {
+	if bufferSize <= 0 {
+		bufferSize = 64
+	}
+	return &Hub{clients: map[uint]map[*Client]struct{}{}, bufferSize: bufferSize}
+}
+
+// Register connects a client for the user
+func (h *Hub) Register(userID uint, role string) *Client {
+	c := &Client{
+		UserID: userID,
+		Role:   role,
+		events: make(chan Event, h.bufferSize),
+		done:   make(chan struct{}),
+	}
+	h.mu.Lock()
+	if h.clients[userID] == nil {
+		h.clients[userID] = map[*Client]struct{}{}
+	}
+	h.clients[userID][c] = struct{}{}
+	h.mu.Unlock()
+	return c
+}
+
+// Unregister disconnects a client
+func (h *Hub) Unregister(c *Client) {
+	h.mu.Lock()
+	if clients := h.clients[c.UserID]; clients != nil {
+		delete(clients, c)
+		if len(clients) == 0 {
+			delete(h.clients, c.UserID)
+		}
+	}
+	h.mu.Unlock()
+	c.close()
+}
+
+// Deliver hands an event to the clients it is for. It never blocks.
+func (h *Hub) Deliver(event Event) {
+	h.mu.RLock()
+	var targets []*Client
+	if event.UserID != 0 {
+		for c := range h.clients[event.UserID] {
+			targets = append(targets, c)
+		}
+	} else {
+		for _, clients := range h.clients {
+			for c := range clients {
+				if event.For(c.UserID, c.Role) {
+					targets = append(targets, c)
+				}
+			}
+		}
+	}
+	h.mu.RUnlock()
+
+	for _, c := range targets {
+		select {
+		case c.events <- event:
+		case <-c.done:
+		default:
+			// Too far behind; the client resumes from its last event
+			h.Unregister(c)
+		}
+	}
+}
+
+// Connections returns how many clients are connected, and how many users
+// they belong to
+func (h *Hub) Connections() (clients, users int) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for _, c := range h.clients {
+		clients += len(c)
+	}
+	return clients, len(h.clients)
+}
@@ -0,0 +1,91 @@
+package repository
+
+import (
+	"school-management-system/internal/models"
+	"school-management-system/pkg/database"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type AcademicTermRepository interface {
+	Create(term *models.AcademicTerm) error
+	FindByID(id uint) (*models.AcademicTerm, error)
+	FindByCode(code string) (*models.AcademicTerm, error)
+	FindAll(page, limit int) ([]models.AcademicTerm, int64, error)
+	FindAllOrdered() ([]models.AcademicTerm, error)
+	FindByStatus(status models.TermStatus) ([]models.AcademicTerm, error)
+	FindByDate(date time.Time) (*models.AcademicTerm, error)
+	Update(term *models.AcademicTerm) error
+	Delete(id uint) error
+}
+
+type academicTermRepository struct {
+	db *gorm.DB
+}
+
+func NewAcademicTermRepository() AcademicTermRepository {
+	return &academicTermRepository{db: database.DB}
+}
+
+func (r *academicTermRepository) Create(term *models.AcademicTerm) error {
+	return r.db.Create(term).Error
+}
+
+func (r *academicTermRepository) FindByID(id uint) (*models.AcademicTerm, error) {
+	var term models.AcademicTerm
+	err := r.db.First(&term, id).Error
+	return &term, err
+}
+
+func (r *academicTermRepository) FindByCode(code string) (*models.AcademicTerm, error) {
+	var term models.AcademicTerm
+	err := r.db.Where("code = ?", code).First(&term).Error
+	return &term, err
+}
+
+func (r *academicTermRepository) FindAll(page, limit int) ([]models.AcademicTerm, int64, error) {
+	var terms []models.AcademicTerm
+	var total int64
+
+	offset := (page - 1) * limit
+	err := r.db.Model(&models.AcademicTerm{}).Count(&total).
+		Order("start_date DESC").
+		Limit(limit).
+		Offset(offset).
+		Find(&terms).Error
+
+	return terms, total, err
+}
+
+func (r *academicTermRepository) FindAllOrdered() ([]models.AcademicTerm, error) {
+	return orderedTerms(r.db)
+}
+
+func orderedTerms(db *gorm.DB) ([]models.AcademicTerm, error) {
+	var terms []models.AcademicTerm
+	err := db.Order("start_date, id").Find(&terms).Error
+	return terms, err
+}
+
+func (r *academicTermRepository) FindByStatus(status models.TermStatus) ([]models.AcademicTerm, error) {
+	var terms []models.AcademicTerm
+	err := r.db.Where("status = ?", status).Order("start_date DESC").Find(&terms).Error
+	return terms, err
+}
+
+func (r *academicTermRepository) FindByDate(date time.Time) (*models.AcademicTerm, error) {
+	var term models.AcademicTerm
+	err := r.db.Where("start_date <= ? AND end_date >= ?", date, date).
+		Order("start_date DESC").
+		First(&term).Error
+	return &term, err
+}
+
+func (r *academicTermRepository) Update(term *models.AcademicTerm) error {
+	return r.db.Save(term).Error
+}
+
+func (r *academicTermRepository) Delete(id uint) error {
+	return r.db.Delete(&models.AcademicTerm{}, id).Error
+}
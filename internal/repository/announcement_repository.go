@@ -3,18 +3,49 @@ package repository
 import (
 	"school-management-system/internal/models"
 	"school-management-system/pkg/database"
+	"sort"
+	"strconv"
+	"strings"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// AnnouncementReceiptCounts summarizes how an announcement's recipients
+// have responded to it
+type AnnouncementReceiptCounts struct {
+	Recipients   int64 `json:"recipients"`
+	Read         int64 `json:"read"`
+	Acknowledged int64 `json:"acknowledged"`
+}
+
 type AnnouncementRepository interface {
 	Create(announcement *models.Announcement) error
 	FindByID(id uint) (*models.Announcement, error)
-	FindAll(page, limit int) ([]models.Announcement, int64, error)
-	FindActive(page, limit int) ([]models.Announcement, int64, error)
+	FindAll(createdBy uint, page, limit int) ([]models.Announcement, int64, error)
 	FindByAudience(audience string, page, limit int) ([]models.Announcement, int64, error)
 	Update(announcement *models.Announcement) error
 	Delete(id uint) error
+	DeactivateExpired(now int64) (int64, error)
+
+	FindDue(now int64) ([]models.Announcement, error)
+	FindLive(now int64) ([]models.Announcement, error)
+	MarkPublished(id uint, at int64) (bool, error)
+	MarkEmailed(id uint, at int64) error
+	AudienceUserIDs(announcement *models.Announcement) ([]uint, error)
+	TargetExists(target models.AnnouncementTarget) (bool, error)
+	Teaches(userID uint, target models.AnnouncementTarget) (bool, error)
+
+	RecipientUserIDs(announcementID uint) ([]uint, error)
+	AddRecipients(announcementID uint, userIDs []uint, at int64) (int64, error)
+	RemoveUnreadRecipients(announcementID uint, userIDs []uint) (int64, error)
+	FindForRecipient(userID uint, now int64, unreadOnly bool, page, limit int) ([]models.Announcement, int64, error)
+	Search(recipientID uint, now int64, query, audience, priority string, page, limit int) ([]models.Announcement, int64, error)
+	FindRecipient(announcementID, userID uint) (*models.AnnouncementRecipient, error)
+	MarkRead(announcementID, userID uint, at int64) (bool, error)
+	Acknowledge(announcementID, userID uint, at int64) (bool, error)
+	FindRecipients(announcementID uint, status string, page, limit int) ([]models.AnnouncementRecipient, int64, error)
+	CountReceipts(announcementID uint) (*AnnouncementReceiptCounts, error)
 }
 
 type announcementRepository struct {
@@ -31,16 +62,23 @@ func (r *announcementRepository) Create(announcement *models.Announcement) error
 
 func (r *announcementRepository) FindByID(id uint) (*models.Announcement, error) {
 	var announcement models.Announcement
-	err := r.db.Preload("CreatedByUser").First(&announcement, id).Error
+	err := r.db.Preload("CreatedByUser").Preload("Targets").First(&announcement, id).Error
 	return &announcement, err
 }
 
-func (r *announcementRepository) FindAll(page, limit int) ([]models.Announcement, int64, error) {
+// FindAll lists announcements, newest first. A non-zero createdBy narrows
+// them to one author's.
+func (r *announcementRepository) FindAll(createdBy uint, page, limit int) ([]models.Announcement, int64, error) {
 	var announcements []models.Announcement
 	var total int64
 	offset := (page - 1) * limit
-	err := r.db.Count(&total).
+	query := r.db.Model(&models.Announcement{})
+	if createdBy != 0 {
+		query = query.Where("created_by = ?", createdBy)
+	}
+	err := query.Count(&total).
 		Preload("CreatedByUser").
+		Preload("Targets").
 		Order("created_at DESC").
 		Limit(limit).
 		Offset(offset).
@@ -48,13 +86,13 @@ func (r *announcementRepository) FindAll(page, limit int) ([]models.Announcement
 	return announcements, total, err
 }
 
-func (r *announcementRepository) FindActive(page, limit int) ([]models.Announcement, int64, error) {
+func (r *announcementRepository) FindByAudience(audience string, page, limit int) ([]models.Announcement, int64, error) {
 	var announcements []models.Announcement
 	var total int64
 	offset := (page - 1) * limit
-	err := r.db.Where("is_active = ? AND (expires_at IS NULL OR expires_at > ?)", true, 0).
-		Count(&total).
+	err := r.db.Model(&models.Announcement{}).Where("audience = ?", audience).Count(&total).
 		Preload("CreatedByUser").
+		Preload("Targets").
 		Order("created_at DESC").
 		Limit(limit).
 		Offset(offset).
@@ -62,23 +100,401 @@ func (r *announcementRepository) FindActive(page, limit int) ([]models.Announcem
 	return announcements, total, err
 }
 
-func (r *announcementRepository) FindByAudience(audience string, page, limit int) ([]models.Announcement, int64, error) {
+// Update saves an announcement and replaces its targets
+func (r *announcementRepository) Update(announcement *models.Announcement) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit(clause.Associations).Save(announcement).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("announcement_id = ?", announcement.ID).Delete(&models.AnnouncementTarget{}).Error; err != nil {
+			return err
+		}
+		for i := range announcement.Targets {
+			announcement.Targets[i].ID = 0
+			announcement.Targets[i].AnnouncementID = announcement.ID
+		}
+		if len(announcement.Targets) == 0 {
+			return nil
+		}
+		return tx.Create(&announcement.Targets).Error
+	})
+}
+
+// Delete removes an announcement with its targets and receipts
+func (r *announcementRepository) Delete(id uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("announcement_id = ?", id).Delete(&models.AnnouncementTarget{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("announcement_id = ?", id).Delete(&models.AnnouncementRecipient{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.Announcement{}, id).Error
+	})
+}
+
+// DeactivateExpired turns off active announcements whose expiry has passed
+func (r *announcementRepository) DeactivateExpired(now int64) (int64, error) {
+	result := r.db.Model(&models.Announcement{}).
+		Where("is_active = ? AND expires_at > 0 AND expires_at <= ?", true, now).
+		Updates(map[string]interface{}{"is_active": false, "updated_at": now})
+	return result.RowsAffected, result.Error
+}
+
+// live restricts a query to announcements that are active, published and
+// not expired at now
+func live(db *gorm.DB, now int64) *gorm.DB {
+	return db.Where("announcements.is_active = ? AND announcements.published_at > 0", true).
+		Where("announcements.expires_at = 0 OR announcements.expires_at > ?", now)
+}
+
+// FindDue returns active, unpublished announcements whose publish time has
+// come, oldest first
+func (r *announcementRepository) FindDue(now int64) ([]models.Announcement, error) {
+	var announcements []models.Announcement
+	err := r.db.Preload("Targets").
+		Where("is_active = ? AND published_at = 0 AND publish_at <= ?", true, now).
+		Where("expires_at = 0 OR expires_at > ?", now).
+		Order("publish_at, id").
+		Find(&announcements).Error
+	return announcements, err
+}
+
+// FindLive returns the announcements currently shown to their recipients
+func (r *announcementRepository) FindLive(now int64) ([]models.Announcement, error) {
+	var announcements []models.Announcement
+	err := live(r.db.Preload("Targets"), now).Order("id").Find(&announcements).Error
+	return announcements, err
+}
+
+// MarkPublished records when an announcement was published, reporting
+// whether this call was the one to do it
+func (r *announcementRepository) MarkPublished(id uint, at int64) (bool, error) {
+	result := r.db.Model(&models.Announcement{}).
+		Where("id = ? AND published_at = 0", id).
+		Updates(map[string]interface{}{"published_at": at, "updated_at": at})
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r *announcementRepository) MarkEmailed(id uint, at int64) error {
+	return r.db.Model(&models.Announcement{}).Where("id = ?", id).Update("emailed_at", at).Error
+}
+
+// AudienceUserIDs resolves an announcement's targets to the active users
+// they currently cover, in ID order
+func (r *announcementRepository) AudienceUserIDs(announcement *models.Announcement) ([]uint, error) {
+	users := r.db.Model(&models.User{}).Where("is_active = ?", true)
+	if len(announcement.Targets) == 0 {
+		switch announcement.Audience {
+		case "", models.AnnouncementAudienceAll:
+		case "students":
+			users = users.Where("role = ?", models.RoleStudent)
+		case "teachers":
+			users = users.Where("role = ?", models.RoleTeacher)
+		case "parents":
+			users = users.Where("role = ?", models.RoleParent)
+		default:
+			return nil, nil
+		}
+		var ids []uint
+		err := users.Order("id").Pluck("id", &ids).Error
+		return ids, err
+	}
+
+	var conditions []string
+	var args []interface{}
+	var studentConditions []string
+	var studentArgs []interface{}
+	for _, target := range announcement.Targets {
+		switch target.Kind {
+		case models.AnnouncementTargetRole:
+			conditions = append(conditions, "role = ?")
+			args = append(args, target.Value)
+		case models.AnnouncementTargetUser:
+			conditions = append(conditions, "id = ?")
+			args = append(args, target.Value)
+		case models.AnnouncementTargetCourse:
+			id, _ := strconv.ParseUint(target.Value, 10, 32)
+			conditions = append(conditions, "id IN (?)")
+			args = append(args, r.db.Model(&models.Teacher{}).Select("user_id").Where("id IN (?) OR id IN (?)",
+				r.db.Model(&models.Course{}).Select("teacher_id").Where("id = ?", id),
+				r.db.Model(&models.CourseSection{}).Select("teacher_id").Where("course_id = ? AND status <> ?", id, "cancelled")))
+			studentConditions = append(studentConditions, "id IN (?)")
+			studentArgs = append(studentArgs, r.db.Model(&models.Enrollment{}).Select("student_id").Where("course_id = ? AND status = ?", id, "active"))
+		case models.AnnouncementTargetSection:
+			id, _ := strconv.ParseUint(target.Value, 10, 32)
+			conditions = append(conditions, "id IN (?)")
+			args = append(args, r.db.Model(&models.Teacher{}).Select("user_id").Where("id IN (?)",
+				r.db.Model(&models.CourseSection{}).Select("teacher_id").Where("id = ?", id)))
+			studentConditions = append(studentConditions, "id IN (?)")
+			studentArgs = append(studentArgs, r.db.Model(&models.Enrollment{}).Select("student_id").Where("section_id = ? AND status = ?", id, "active"))
+		case models.AnnouncementTargetGradeLevel:
+			studentConditions = append(studentConditions, "grade_level = ?")
+			studentArgs = append(studentArgs, target.Value)
+		}
+	}
+
+	if len(studentConditions) > 0 {
+		conditions = append(conditions, "id IN (?)")
+		args = append(args, r.db.Model(&models.Student{}).Select("user_id").Where(strings.Join(studentConditions, " OR "), studentArgs...))
+	}
+	if len(conditions) == 0 {
+		return nil, nil
+	}
+	targeted := func() *gorm.DB {
+		return r.db.Model(&models.User{}).Where("is_active = ?", true).Where(strings.Join(conditions, " OR "), args...)
+	}
+
+	var ids []uint
+	if err := targeted().Pluck("id", &ids).Error; err != nil {
+		return nil, err
+	}
+	if announcement.IncludeGuardians {
+		var guardianIDs []uint
+		err := r.db.Model(&models.User{}).
+			Where("is_active = ? AND id IN (?)", true, r.db.Model(&models.Guardian{}).Select("user_id").Where("student_id IN (?)",
+				r.db.Model(&models.Student{}).Select("id").Where("user_id IN (?)", targeted().Select("id")))).
+			Pluck("id", &guardianIDs).Error
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, guardianIDs...)
+	}
+	return sortedIDs(ids), nil
+}
+
+// TargetExists reports whether the course, section or user a target refers
+// to exists. Roles and grade levels are not checked.
+func (r *announcementRepository) TargetExists(target models.AnnouncementTarget) (bool, error) {
+	var model interface{}
+	switch target.Kind {
+	case models.AnnouncementTargetCourse:
+		model = &models.Course{}
+	case models.AnnouncementTargetSection:
+		model = &models.CourseSection{}
+	case models.AnnouncementTargetUser:
+		model = &models.User{}
+	default:
+		return true, nil
+	}
+	var count int64
+	err := r.db.Model(model).Where("id = ?", target.Value).Count(&count).Error
+	return count > 0, err
+}
+
+// Teaches reports whether the user teaches the course or section a target
+// refers to
+func (r *announcementRepository) Teaches(userID uint, target models.AnnouncementTarget) (bool, error) {
+	teacherIDs := r.db.Model(&models.Teacher{}).Select("id").Where("user_id = ?", userID)
+	var count int64
+	var err error
+	switch target.Kind {
+	case models.AnnouncementTargetCourse:
+		err = r.db.Model(&models.Course{}).
+			Where("id = ? AND (teacher_id IN (?) OR id IN (?))", target.Value, teacherIDs,
+				r.db.Model(&models.CourseSection{}).Select("course_id").Where("teacher_id IN (?) AND status <> ?", teacherIDs, "cancelled")).
+			Count(&count).Error
+	case models.AnnouncementTargetSection:
+		err = r.db.Model(&models.CourseSection{}).
+			Where("id = ? AND teacher_id IN (?)", target.Value, teacherIDs).
+			Count(&count).Error
+	}
+	return count > 0, err
+}
+
+func (r *announcementRepository) RecipientUserIDs(announcementID uint) ([]uint, error) {
+	var ids []uint
+	err := r.db.Model(&models.AnnouncementRecipient{}).
+		Where("announcement_id = ?", announcementID).
+		Order("user_id").
+		Pluck("user_id", &ids).Error
+	return ids, err
+}
+
+// AddRecipients delivers an announcement to users who do not have it yet,
+// returning how many were added
+func (r *announcementRepository) AddRecipients(announcementID uint, userIDs []uint, at int64) (int64, error) {
+	if len(userIDs) == 0 {
+		return 0, nil
+	}
+	recipients := make([]models.AnnouncementRecipient, len(userIDs))
+	for i, userID := range userIDs {
+		recipients[i] = models.AnnouncementRecipient{AnnouncementID: announcementID, UserID: userID, CreatedAt: at}
+	}
+	result := r.db.Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(&recipients, 500)
+	return result.RowsAffected, result.Error
+}
+
+// RemoveUnreadRecipients withdraws an announcement from those of the users
+// who have not read it yet
+func (r *announcementRepository) RemoveUnreadRecipients(announcementID uint, userIDs []uint) (int64, error) {
+	var removed int64
+	for start := 0; start < len(userIDs); start += 500 {
+		end := start + 500
+		if end > len(userIDs) {
+			end = len(userIDs)
+		}
+		result := r.db.Where("announcement_id = ? AND user_id IN ? AND read_at = 0 AND acknowledged_at = 0", announcementID, userIDs[start:end]).
+			Delete(&models.AnnouncementRecipient{})
+		if result.Error != nil {
+			return removed, result.Error
+		}
+		removed += result.RowsAffected
+	}
+	return removed, nil
+}
+
+// FindForRecipient lists the live announcements delivered to a user, newest
+// first, with the user's read and acknowledgement times filled in
+func (r *announcementRepository) FindForRecipient(userID uint, now int64, unreadOnly bool, page, limit int) ([]models.Announcement, int64, error) {
+	received := r.db.Model(&models.AnnouncementRecipient{}).Select("announcement_id").Where("user_id = ?", userID)
+	if unreadOnly {
+		received = received.Where("read_at = 0")
+	}
+	query := live(r.db.Model(&models.Announcement{}), now).Where("announcements.id IN (?)", received)
+
 	var announcements []models.Announcement
 	var total int64
-	offset := (page - 1) * limit
-	err := r.db.Where("audience = ?", audience).Count(&total).
-		Preload("CreatedByUser").
-		Order("created_at DESC").
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := query.Preload("CreatedByUser").
+		Order("announcements.published_at DESC, announcements.id DESC").
 		Limit(limit).
-		Offset(offset).
+		Offset((page - 1) * limit).
 		Find(&announcements).Error
+	if err != nil || len(announcements) == 0 {
+		return announcements, total, err
+	}
+
+	ids := make([]uint, len(announcements))
+	for i := range announcements {
+		ids[i] = announcements[i].ID
+	}
+	var receipts []models.AnnouncementRecipient
+	if err := r.db.Where("user_id = ? AND announcement_id IN ?", userID, ids).Find(&receipts).Error; err != nil {
+		return nil, 0, err
+	}
+	byAnnouncement := make(map[uint]models.AnnouncementRecipient, len(receipts))
+	for _, receipt := range receipts {
+		byAnnouncement[receipt.AnnouncementID] = receipt
+	}
+	for i := range announcements {
+		receipt := byAnnouncement[announcements[i].ID]
+		announcements[i].ReadAt = receipt.ReadAt
+		announcements[i].AcknowledgedAt = receipt.AcknowledgedAt
+	}
+	return announcements, total, nil
+}
+
+// Search finds active announcements whose title or content contains query,
+// optionally of one audience and priority. A non-zero recipientID narrows
+// them to the live announcements delivered to that user.
+func (r *announcementRepository) Search(recipientID uint, now int64, query, audience, priority string, page, limit int) ([]models.Announcement, int64, error) {
+	q := r.db.Model(&models.Announcement{}).Where("announcements.is_active = ?", true)
+	if recipientID != 0 {
+		received := r.db.Model(&models.AnnouncementRecipient{}).Select("announcement_id").Where("user_id = ?", recipientID)
+		q = live(q, now).Where("announcements.id IN (?)", received)
+	}
+	if query != "" {
+		q = q.Where("announcements.title LIKE ? OR announcements.content LIKE ?", "%"+query+"%", "%"+query+"%")
+	}
+	if audience != "" {
+		q = q.Where("announcements.audience = ?", audience)
+	}
+	if priority != "" {
+		q = q.Where("announcements.priority = ?", priority)
+	}
+
+	var announcements []models.Announcement
+	var total int64
+	if err := q.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := q.Offset((page - 1) * limit).Limit(limit).Find(&announcements).Error
 	return announcements, total, err
 }
 
-func (r *announcementRepository) Update(announcement *models.Announcement) error {
-	return r.db.Save(announcement).Error
+func (r *announcementRepository) FindRecipient(announcementID, userID uint) (*models.AnnouncementRecipient, error) {
+	var recipient models.AnnouncementRecipient
+	err := r.db.Where("announcement_id = ? AND user_id = ?", announcementID, userID).First(&recipient).Error
+	return &recipient, err
 }
 
-func (r *announcementRepository) Delete(id uint) error {
-	return r.db.Delete(&models.Announcement{}, id).Error
+// MarkRead records the first time a recipient read an announcement
+func (r *announcementRepository) MarkRead(announcementID, userID uint, at int64) (bool, error) {
+	result := r.db.Model(&models.AnnouncementRecipient{}).
+		Where("announcement_id = ? AND user_id = ? AND read_at = 0", announcementID, userID).
+		Update("read_at", at)
+	return result.RowsAffected > 0, result.Error
+}
+
+// Acknowledge records a recipient's acknowledgement, which also counts as
+// reading the announcement
+func (r *announcementRepository) Acknowledge(announcementID, userID uint, at int64) (bool, error) {
+	var acknowledged bool
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.AnnouncementRecipient{}).
+			Where("announcement_id = ? AND user_id = ? AND acknowledged_at = 0", announcementID, userID).
+			Update("acknowledged_at", at)
+		if result.Error != nil {
+			return result.Error
+		}
+		acknowledged = result.RowsAffected > 0
+		return tx.Model(&models.AnnouncementRecipient{}).
+			Where("announcement_id = ? AND user_id = ? AND read_at = 0", announcementID, userID).
+			Update("read_at", at).Error
+	})
+	return acknowledged, err
+}
+
+// FindRecipients lists an announcement's recipients by name. Status narrows
+// them to those who have or have not read or acknowledged it.
+func (r *announcementRepository) FindRecipients(announcementID uint, status string, page, limit int) ([]models.AnnouncementRecipient, int64, error) {
+	query := r.db.Model(&models.AnnouncementRecipient{}).Where("announcement_recipients.announcement_id = ?", announcementID)
+	switch status {
+	case "read":
+		query = query.Where("announcement_recipients.read_at > 0")
+	case "unread":
+		query = query.Where("announcement_recipients.read_at = 0")
+	case "acknowledged":
+		query = query.Where("announcement_recipients.acknowledged_at > 0")
+	case "unacknowledged":
+		query = query.Where("announcement_recipients.acknowledged_at = 0")
+	}
+
+	var recipients []models.AnnouncementRecipient
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := query.Joins("User").
+		Order("User__last_name, User__first_name, announcement_recipients.user_id").
+		Limit(limit).
+		Offset((page - 1) * limit).
+		Find(&recipients).Error
+	return recipients, total, err
+}
+
+func (r *announcementRepository) CountReceipts(announcementID uint) (*AnnouncementReceiptCounts, error) {
+	var counts AnnouncementReceiptCounts
+	err := r.db.Model(&models.AnnouncementRecipient{}).
+		Select("COUNT(*) AS recipients, "+
+			"COALESCE(SUM(CASE WHEN read_at > 0 THEN 1 ELSE 0 END), 0) AS read, "+
+			"COALESCE(SUM(CASE WHEN acknowledged_at > 0 THEN 1 ELSE 0 END), 0) AS acknowledged").
+		Where("announcement_id = ?", announcementID).
+		Scan(&counts).Error
+	return &counts, err
+}
+
+// sortedIDs returns ids in ascending order without duplicates
+func sortedIDs(ids []uint) []uint {
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	unique := ids[:0]
+	for i, id := range ids {
+		if i == 0 || id != ids[i-1] {
+			unique = append(unique, id)
+		}
+	}
+	return unique
 }
@@ -3,6 +3,7 @@ package repository
 import (
 	"school-management-system/internal/models"
 	"school-management-system/pkg/database"
+	"time"
 
 	"gorm.io/gorm"
 )
@@ -14,6 +15,9 @@ type AssignmentRepository interface {
 	Update(assignment *models.Assignment) error
 	Delete(id uint) error
 	FindAllByTeacherID(teacherID uint) ([]models.Assignment, error)
+	FindDueForReminder(now, dueBefore time.Time) ([]models.Assignment, error)
+	FindStudentsWithoutSubmission(assignment *models.Assignment) ([]models.Student, error)
+	MarkReminded(id uint, at time.Time) error
 }
 
 type AssignmentSubmissionRepository interface {
@@ -22,6 +26,7 @@ type AssignmentSubmissionRepository interface {
 	FindByAssignmentID(assignmentID uint) ([]models.AssignmentSubmission, error)
 	FindByStudentID(studentID uint) ([]models.AssignmentSubmission, error)
 	FindByAssignmentAndStudent(assignmentID, studentID uint) (*models.AssignmentSubmission, error)
+	FindByCourseID(courseID uint) ([]models.AssignmentSubmission, error)
 	Update(submission *models.AssignmentSubmission) error
 	Delete(id uint) error
 }
@@ -73,6 +78,36 @@ func (r *assignmentRepository) FindAllByTeacherID(teacherID uint) ([]models.Assi
 	return assignments, err
 }
 
+// FindDueForReminder returns assignments due between now and dueBefore
+// whose students have not been reminded yet
+func (r *assignmentRepository) FindDueForReminder(now, dueBefore time.Time) ([]models.Assignment, error) {
+	var assignments []models.Assignment
+	err := r.db.Where("due_date > ? AND due_date <= ? AND reminder_sent_at IS NULL", now, dueBefore).
+		Preload("Course").
+		Order("due_date ASC").
+		Find(&assignments).Error
+	return assignments, err
+}
+
+// FindStudentsWithoutSubmission returns the students actively enrolled in
+// the assignment's course who have not submitted it
+func (r *assignmentRepository) FindStudentsWithoutSubmission(assignment *models.Assignment) ([]models.Student, error) {
+	var students []models.Student
+	submitted := r.db.Model(&models.AssignmentSubmission{}).
+		Select("student_id").
+		Where("assignment_id = ? AND (submitted_at IS NOT NULL OR status IN ?)", assignment.ID, []string{"submitted", "graded"})
+	err := r.db.Where("id IN (?) AND id NOT IN (?)",
+		r.db.Model(&models.Enrollment{}).Select("student_id").Where("course_id = ? AND status = ?", assignment.CourseID, "active"),
+		submitted).
+		Preload("User").
+		Find(&students).Error
+	return students, err
+}
+
+func (r *assignmentRepository) MarkReminded(id uint, at time.Time) error {
+	return r.db.Model(&models.Assignment{}).Where("id = ?", id).Update("reminder_sent_at", at).Error
+}
+
 // AssignmentSubmission methods
 func (r *assignmentSubmissionRepository) Create(submission *models.AssignmentSubmission) error {
 	return r.db.Create(submission).Error
@@ -102,6 +137,14 @@ func (r *assignmentSubmissionRepository) FindByAssignmentAndStudent(assignmentID
 	return &submission, err
 }
 
+func (r *assignmentSubmissionRepository) FindByCourseID(courseID uint) ([]models.AssignmentSubmission, error) {
+	var submissions []models.AssignmentSubmission
+	err := r.db.Joins("JOIN assignments ON assignments.id = assignment_submissions.assignment_id").
+		Where("assignments.course_id = ?", courseID).
+		Find(&submissions).Error
+	return submissions, err
+}
+
 func (r *assignmentSubmissionRepository) Update(submission *models.AssignmentSubmission) error {
 	return r.db.Save(submission).Error
 }
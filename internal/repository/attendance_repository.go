@@ -1,6 +1,7 @@
 package repository
 
 import (
+	"context"
 	"school-management-system/internal/models"
 	"school-management-system/pkg/database"
 	"time"
@@ -9,14 +10,14 @@ import (
 )
 
 type AttendanceRepository interface {
-	Create(attendance *models.Attendance) error
+	Create(ctx context.Context, attendance *models.Attendance) error
 	FindByID(id uint) (*models.Attendance, error)
 	FindByStudentAndCourse(studentID, courseID uint, page, limit int) ([]models.Attendance, int64, error)
 	FindByStudentID(studentID uint, page, limit int) ([]models.Attendance, int64, error)
 	FindByCourseID(courseID uint, page, limit int) ([]models.Attendance, int64, error)
 	FindAll(page, limit int) ([]models.Attendance, int64, error)
-	Update(attendance *models.Attendance) error
-	Delete(id uint) error
+	Update(ctx context.Context, attendance *models.Attendance) error
+	Delete(ctx context.Context, id uint) error
 	FindByDateRange(startDate, endDate time.Time) ([]models.Attendance, error)
 	FindByStudentDateRange(studentID uint, startDate, endDate time.Time) ([]models.Attendance, error)
 	CountAttendanceByStudent(studentID, courseID uint) (present, absent, late int64, error error)
@@ -30,8 +31,8 @@ func NewAttendanceRepository() AttendanceRepository {
 	return &attendanceRepository{db: database.DB}
 }
 
-func (r *attendanceRepository) Create(attendance *models.Attendance) error {
-	return r.db.Create(attendance).Error
+func (r *attendanceRepository) Create(ctx context.Context, attendance *models.Attendance) error {
+	return r.db.WithContext(ctx).Create(attendance).Error
 }
 
 func (r *attendanceRepository) FindByID(id uint) (*models.Attendance, error) {
@@ -58,7 +59,7 @@ func (r *attendanceRepository) FindByStudentID(studentID uint, page, limit int)
 	var total int64
 
 	offset := (page - 1) * limit
-	err := r.db.Where("student_id = ?", studentID).Count(&total).
+	err := r.db.Model(&models.Attendance{}).Where("student_id = ?", studentID).Count(&total).
 		Preload("Course").
 		Limit(limit).
 		Offset(offset).
@@ -97,12 +98,12 @@ func (r *attendanceRepository) FindAll(page, limit int) ([]models.Attendance, in
 	return attendances, total, err
 }
 
-func (r *attendanceRepository) Update(attendance *models.Attendance) error {
-	return r.db.Save(attendance).Error
+func (r *attendanceRepository) Update(ctx context.Context, attendance *models.Attendance) error {
+	return r.db.WithContext(ctx).Save(attendance).Error
 }
 
-func (r *attendanceRepository) Delete(id uint) error {
-	return r.db.Delete(&models.Attendance{}, id).Error
+func (r *attendanceRepository) Delete(ctx context.Context, id uint) error {
+	return r.db.WithContext(ctx).Delete(&models.Attendance{}, id).Error
 }
 
 func (r *attendanceRepository) FindByDateRange(startDate, endDate time.Time) ([]models.Attendance, error) {
@@ -3,16 +3,29 @@ package repository
 import (
 	"school-management-system/internal/models"
 	"school-management-system/pkg/database"
+	"time"
 
 	"gorm.io/gorm"
 )
 
+// AuditLogFilter narrows an audit log search. Zero values match everything;
+// From and To are unix timestamps.
+type AuditLogFilter struct {
+	UserID   uint
+	Entity   string
+	EntityID uint
+	Action   string
+	From     int64
+	To       int64
+}
+
 type AuditLogRepository interface {
 	Create(log *models.AuditLog) error
 	FindByID(id uint) (*models.AuditLog, error)
 	FindByUserID(userID uint, page, limit int) ([]models.AuditLog, int64, error)
 	FindByEntity(entity string, entityID uint) ([]models.AuditLog, error)
 	FindAll(page, limit int) ([]models.AuditLog, int64, error)
+	Search(filter AuditLogFilter, page, limit int) ([]models.AuditLog, int64, error)
 	DeleteOlderThan(days int) error
 }
 
@@ -35,31 +48,51 @@ func (r *auditLogRepository) FindByID(id uint) (*models.AuditLog, error) {
 }
 
 func (r *auditLogRepository) FindByUserID(userID uint, page, limit int) ([]models.AuditLog, int64, error) {
-	var logs []models.AuditLog
-	var total int64
-	offset := (page - 1) * limit
-	err := r.db.Where("user_id = ?", userID).Count(&total).
-		Order("created_at DESC").
-		Limit(limit).
-		Offset(offset).
-		Find(&logs).Error
-	return logs, total, err
+	return r.Search(AuditLogFilter{UserID: userID}, page, limit)
 }
 
 func (r *auditLogRepository) FindByEntity(entity string, entityID uint) ([]models.AuditLog, error) {
 	var logs []models.AuditLog
 	err := r.db.Where("entity = ? AND entity_id = ?", entity, entityID).
-		Order("created_at DESC").
+		Order("created_at DESC, id DESC").
 		Find(&logs).Error
 	return logs, err
 }
 
 func (r *auditLogRepository) FindAll(page, limit int) ([]models.AuditLog, int64, error) {
+	return r.Search(AuditLogFilter{}, page, limit)
+}
+
+func (r *auditLogRepository) Search(filter AuditLogFilter, page, limit int) ([]models.AuditLog, int64, error) {
 	var logs []models.AuditLog
 	var total int64
+
+	query := r.db.Model(&models.AuditLog{})
+	if filter.UserID != 0 {
+		query = query.Where("user_id = ?", filter.UserID)
+	}
+	if filter.Entity != "" {
+		query = query.Where("entity = ?", filter.Entity)
+	}
+	if filter.EntityID != 0 {
+		query = query.Where("entity_id = ?", filter.EntityID)
+	}
+	if filter.Action != "" {
+		query = query.Where("action = ?", filter.Action)
+	}
+	if filter.From != 0 {
+		query = query.Where("created_at >= ?", filter.From)
+	}
+	if filter.To != 0 {
+		query = query.Where("created_at <= ?", filter.To)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
 	offset := (page - 1) * limit
-	err := r.db.Count(&total).
-		Order("created_at DESC").
+	err := query.Order("created_at DESC, id DESC").
 		Limit(limit).
 		Offset(offset).
 		Find(&logs).Error
@@ -67,5 +100,6 @@ func (r *auditLogRepository) FindAll(page, limit int) ([]models.AuditLog, int64,
 }
 
 func (r *auditLogRepository) DeleteOlderThan(days int) error {
-	return r.db.Where("created_at < datetime('now', '-' || ? || ' days')", days).Delete(&models.AuditLog{}).Error
+	cutoff := time.Now().AddDate(0, 0, -days).Unix()
+	return r.db.Where("created_at < ?", cutoff).Delete(&models.AuditLog{}).Error
 }
@@ -5,6 +5,7 @@ import (
 	"school-management-system/pkg/database"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type BackupRepository interface {
@@ -39,9 +40,11 @@ func (r *backupRepository) FindAll(page, limit int) ([]models.Backup, int64, err
 	var backups []models.Backup
 	var total int64
 	offset := (page - 1) * limit
-	err := r.db.Count(&total).
-		Preload("CreatedByUser").
-		Order("created_at DESC").
+	if err := r.db.Model(&models.Backup{}).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := r.db.Preload("CreatedByUser").
+		Order("created_at DESC, id DESC").
 		Limit(limit).
 		Offset(offset).
 		Find(&backups).Error
@@ -52,13 +55,13 @@ func (r *backupRepository) FindByStatus(status string) ([]models.Backup, error)
 	var backups []models.Backup
 	err := r.db.Where("status = ?", status).
 		Preload("CreatedByUser").
-		Order("created_at DESC").
+		Order("created_at DESC, id DESC").
 		Find(&backups).Error
 	return backups, err
 }
 
 func (r *backupRepository) Update(backup *models.Backup) error {
-	return r.db.Save(backup).Error
+	return r.db.Omit(clause.Associations).Save(backup).Error
 }
 
 func (r *backupRepository) Delete(id uint) error {
@@ -67,9 +70,9 @@ func (r *backupRepository) Delete(id uint) error {
 
 func (r *backupRepository) GetLatestCompleted() (*models.Backup, error) {
 	var backup models.Backup
-	err := r.db.Where("status = ?", "completed").
+	err := r.db.Where("status = ?", models.BackupStatusCompleted).
 		Preload("CreatedByUser").
-		Order("created_at DESC").
+		Order("created_at DESC, id DESC").
 		First(&backup).Error
 	return &backup, err
 }
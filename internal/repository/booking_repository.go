@@ -0,0 +1,136 @@
+package repository
+
+import (
+	"school-management-system/internal/models"
+	"school-management-system/pkg/database"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// BookingFilter narrows a booking listing. Zero fields match everything;
+// From and To bound the booking date, inclusive.
+type BookingFilter struct {
+	RoomID           uint
+	ResourceID       uint
+	BookedBy         uint
+	From             time.Time
+	To               time.Time
+	IncludeCancelled bool
+}
+
+type BookingRepository interface {
+	FindByID(id uint) (*models.Booking, error)
+	FindAll(filter BookingFilter, page, limit int) ([]models.Booking, int64, error)
+	FindConfirmedBetween(from, to time.Time) ([]models.Booking, error)
+	CountUpcomingForRoom(roomID uint, from time.Time) (int64, error)
+	CreateChecked(booking *models.Booking, check func(sameDay []models.Booking, classes []models.TimeTable) error) error
+	Cancel(id, actorID uint) (bool, error)
+}
+
+type bookingRepository struct {
+	db *gorm.DB
+}
+
+func NewBookingRepository() BookingRepository {
+	return &bookingRepository{db: database.DB}
+}
+
+func (r *bookingRepository) FindByID(id uint) (*models.Booking, error) {
+	var booking models.Booking
+	err := r.db.Preload("Room").Preload("Resource").First(&booking, id).Error
+	return &booking, err
+}
+
+func (r *bookingRepository) FindAll(filter BookingFilter, page, limit int) ([]models.Booking, int64, error) {
+	var bookings []models.Booking
+	var total int64
+	q := r.db.Model(&models.Booking{})
+	if filter.RoomID != 0 {
+		q = q.Where("room_id = ?", filter.RoomID)
+	}
+	if filter.ResourceID != 0 {
+		q = q.Where("resource_id = ?", filter.ResourceID)
+	}
+	if filter.BookedBy != 0 {
+		q = q.Where("booked_by = ?", filter.BookedBy)
+	}
+	if !filter.From.IsZero() {
+		q = q.Where("date >= ?", filter.From)
+	}
+	if !filter.To.IsZero() {
+		q = q.Where("date <= ?", filter.To)
+	}
+	if !filter.IncludeCancelled {
+		q = q.Where("status = ?", models.BookingConfirmed)
+	}
+	if err := q.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := q.Preload("Room").Preload("Resource").
+		Order("date, start_time, id").
+		Offset((page - 1) * limit).Limit(limit).
+		Find(&bookings).Error
+	return bookings, total, err
+}
+
+// FindConfirmedBetween returns the bookings that are not cancelled between
+// two dates, inclusive
+func (r *bookingRepository) FindConfirmedBetween(from, to time.Time) ([]models.Booking, error) {
+	var bookings []models.Booking
+	err := r.db.Where("status = ? AND date >= ? AND date <= ?", models.BookingConfirmed, from, to).
+		Order("date, start_time, id").
+		Find(&bookings).Error
+	return bookings, err
+}
+
+func (r *bookingRepository) CountUpcomingForRoom(roomID uint, from time.Time) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.Booking{}).
+		Where("room_id = ? AND status = ? AND date >= ?", roomID, models.BookingConfirmed, from).
+		Count(&count).Error
+	return count, err
+}
+
+// CreateChecked saves a booking if check allows it, given the confirmed
+// bookings of the same room or resource that day and, for a room, the
+// classes meeting that day. The room or resource row is locked first, so
+// concurrent bookings of it are checked and saved one at a time.
+func (r *bookingRepository) CreateChecked(booking *models.Booking, check func(sameDay []models.Booking, classes []models.TimeTable) error) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		q := tx.Where("status = ? AND date = ?", models.BookingConfirmed, booking.Date)
+		var classes []models.TimeTable
+		if booking.RoomID != nil {
+			if err := tx.Exec("UPDATE rooms SET id = id WHERE id = ?", *booking.RoomID).Error; err != nil {
+				return err
+			}
+			var err error
+			if classes, err = meetingsOnDate(tx, booking.Date); err != nil {
+				return err
+			}
+			q = q.Where("room_id = ?", *booking.RoomID)
+		} else {
+			if err := tx.Exec("UPDATE resources SET id = id WHERE id = ?", *booking.ResourceID).Error; err != nil {
+				return err
+			}
+			q = q.Where("resource_id = ?", *booking.ResourceID)
+		}
+		var sameDay []models.Booking
+		if err := q.Order("start_time, id").Find(&sameDay).Error; err != nil {
+			return err
+		}
+		if err := check(sameDay, classes); err != nil {
+			return err
+		}
+		return tx.Omit("Room", "Resource").Create(booking).Error
+	})
+}
+
+// Cancel marks a confirmed booking cancelled, reporting false if it was
+// not confirmed
+func (r *bookingRepository) Cancel(id, actorID uint) (bool, error) {
+	result := r.db.Model(&models.Booking{}).
+		Where("id = ? AND status = ?", id, models.BookingConfirmed).
+		Updates(map[string]interface{}{"status": models.BookingCancelled, "cancelled_by": actorID})
+	return result.RowsAffected > 0, result.Error
+}
@@ -0,0 +1,255 @@
+package repository
+
+import (
+	"school-management-system/internal/models"
+	"school-management-system/pkg/database"
+
+	"gorm.io/gorm"
+)
+
+type ConversationRepository interface {
+	Create(conversation *models.Conversation) error
+	FindByID(id uint) (*models.Conversation, error)
+	FindByDirectKey(key string) (*models.Conversation, error)
+	FindCourseChannel(courseID uint) (*models.Conversation, error)
+	FindBroadcast(ownerID, courseID uint, audience string) (*models.Conversation, error)
+	FindForUser(userID uint, page, limit int) ([]models.Conversation, int64, error)
+	FindByKind(kind string) ([]models.Conversation, error)
+	Touch(id uint, at int64) error
+
+	FindParticipant(conversationID, userID uint) (*models.ConversationParticipant, error)
+	FindActiveParticipants(conversationID uint) ([]models.ConversationParticipant, error)
+	CountActiveParticipants(conversationIDs []uint) (map[uint]int64, error)
+	AddParticipant(participant *models.ConversationParticipant) error
+	RemoveParticipant(conversationID, userID uint, at int64) error
+	MarkRead(conversationID, userID, messageID uint) (bool, error)
+
+	CountUnread(userID uint) (int64, error)
+	CountUnreadByConversation(userID uint, conversationIDs []uint) (map[uint]int64, error)
+
+	CourseTeacherUserIDs(courseID uint) ([]uint, error)
+	CourseStudentUserIDs(courseID uint) ([]uint, error)
+	CourseGuardianUserIDs(courseID uint) ([]uint, error)
+	CourseIDsForUser(userID uint) ([]uint, error)
+}
+
+type conversationRepository struct {
+	db *gorm.DB
+}
+
+func NewConversationRepository() ConversationRepository {
+	return &conversationRepository{db: database.DB}
+}
+
+// Create stores a conversation along with the participants set on it
+func (r *conversationRepository) Create(conversation *models.Conversation) error {
+	return r.db.Create(conversation).Error
+}
+
+func (r *conversationRepository) FindByID(id uint) (*models.Conversation, error) {
+	var conversation models.Conversation
+	err := r.db.First(&conversation, id).Error
+	return &conversation, err
+}
+
+func (r *conversationRepository) FindByDirectKey(key string) (*models.Conversation, error) {
+	var conversation models.Conversation
+	err := r.db.Where("direct_key = ?", key).First(&conversation).Error
+	return &conversation, err
+}
+
+func (r *conversationRepository) FindCourseChannel(courseID uint) (*models.Conversation, error) {
+	var conversation models.Conversation
+	err := r.db.Where("kind = ? AND course_id = ?", models.ConversationCourse, courseID).First(&conversation).Error
+	return &conversation, err
+}
+
+func (r *conversationRepository) FindBroadcast(ownerID, courseID uint, audience string) (*models.Conversation, error) {
+	var conversation models.Conversation
+	err := r.db.Where("kind = ? AND course_id = ? AND audience = ? AND created_by = ?",
+		models.ConversationBroadcast, courseID, audience, ownerID).First(&conversation).Error
+	return &conversation, err
+}
+
+// FindForUser returns the conversations the user takes part in, most
+// recently active first
+func (r *conversationRepository) FindForUser(userID uint, page, limit int) ([]models.Conversation, int64, error) {
+	var conversations []models.Conversation
+	var total int64
+	offset := (page - 1) * limit
+	query := r.db.Model(&models.Conversation{}).
+		Joins("JOIN conversation_participants p ON p.conversation_id = conversations.id").
+		Where("p.user_id = ? AND p.left_at = 0", userID)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := query.Order("conversations.last_message_at DESC, conversations.id DESC").
+		Limit(limit).
+		Offset(offset).
+		Find(&conversations).Error
+	return conversations, total, err
+}
+
+func (r *conversationRepository) FindByKind(kind string) ([]models.Conversation, error) {
+	var conversations []models.Conversation
+	err := r.db.Where("kind = ?", kind).Order("id").Find(&conversations).Error
+	return conversations, err
+}
+
+func (r *conversationRepository) Touch(id uint, at int64) error {
+	return r.db.Model(&models.Conversation{}).Where("id = ?", id).Update("last_message_at", at).Error
+}
+
+func (r *conversationRepository) FindParticipant(conversationID, userID uint) (*models.ConversationParticipant, error) {
+	var participant models.ConversationParticipant
+	err := r.db.Where("conversation_id = ? AND user_id = ?", conversationID, userID).First(&participant).Error
+	return &participant, err
+}
+
+func (r *conversationRepository) FindActiveParticipants(conversationID uint) ([]models.ConversationParticipant, error) {
+	var participants []models.ConversationParticipant
+	err := r.db.Where("conversation_id = ? AND left_at = 0", conversationID).
+		Preload("User").
+		Order("id").
+		Find(&participants).Error
+	return participants, err
+}
+
+func (r *conversationRepository) CountActiveParticipants(conversationIDs []uint) (map[uint]int64, error) {
+	var rows []struct {
+		ConversationID uint
+		Count          int64
+	}
+	err := r.db.Model(&models.ConversationParticipant{}).
+		Select("conversation_id, COUNT(*) AS count").
+		Where("conversation_id IN ? AND left_at = 0", conversationIDs).
+		Group("conversation_id").
+		Scan(&rows).Error
+	counts := make(map[uint]int64, len(rows))
+	for _, row := range rows {
+		counts[row.ConversationID] = row.Count
+	}
+	return counts, err
+}
+
+// AddParticipant adds a user to a conversation, or brings back one who left
+func (r *conversationRepository) AddParticipant(participant *models.ConversationParticipant) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var existing models.ConversationParticipant
+		err := tx.Where("conversation_id = ? AND user_id = ?", participant.ConversationID, participant.UserID).First(&existing).Error
+		if err == gorm.ErrRecordNotFound {
+			return tx.Create(participant).Error
+		}
+		if err != nil {
+			return err
+		}
+		participant.ID = existing.ID
+		participant.LastReadMessageID = existing.LastReadMessageID
+		return tx.Model(&existing).Updates(map[string]interface{}{
+			"role":      participant.Role,
+			"joined_at": participant.JoinedAt,
+			"left_at":   0,
+		}).Error
+	})
+}
+
+func (r *conversationRepository) RemoveParticipant(conversationID, userID uint, at int64) error {
+	return r.db.Model(&models.ConversationParticipant{}).
+		Where("conversation_id = ? AND user_id = ? AND left_at = 0", conversationID, userID).
+		Update("left_at", at).Error
+}
+
+// MarkRead moves the user's read marker forward to messageID. It reports
+// whether the marker moved.
+func (r *conversationRepository) MarkRead(conversationID, userID, messageID uint) (bool, error) {
+	result := r.db.Model(&models.ConversationParticipant{}).
+		Where("conversation_id = ? AND user_id = ? AND last_read_message_id < ?", conversationID, userID, messageID).
+		Update("last_read_message_id", messageID)
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r *conversationRepository) unread(userID uint) *gorm.DB {
+	return r.db.Table("messages m").
+		Joins("JOIN conversation_participants p ON p.conversation_id = m.conversation_id").
+		Where("p.user_id = ? AND p.left_at = 0 AND m.id > p.last_read_message_id AND m.sender_id <> ? AND m.deleted_at = 0", userID, userID)
+}
+
+func (r *conversationRepository) CountUnread(userID uint) (int64, error) {
+	var count int64
+	err := r.unread(userID).Count(&count).Error
+	return count, err
+}
+
+func (r *conversationRepository) CountUnreadByConversation(userID uint, conversationIDs []uint) (map[uint]int64, error) {
+	var rows []struct {
+		ConversationID uint
+		Count          int64
+	}
+	err := r.unread(userID).
+		Select("m.conversation_id AS conversation_id, COUNT(*) AS count").
+		Where("m.conversation_id IN ?", conversationIDs).
+		Group("m.conversation_id").
+		Scan(&rows).Error
+	counts := make(map[uint]int64, len(rows))
+	for _, row := range rows {
+		counts[row.ConversationID] = row.Count
+	}
+	return counts, err
+}
+
+// CourseTeacherUserIDs returns the users teaching the course or one of its
+// sections that is not cancelled
+func (r *conversationRepository) CourseTeacherUserIDs(courseID uint) ([]uint, error) {
+	var ids []uint
+	err := r.db.Model(&models.Teacher{}).
+		Where("id IN (?) OR id IN (?)",
+			r.db.Model(&models.Course{}).Select("teacher_id").Where("id = ?", courseID),
+			r.db.Model(&models.CourseSection{}).Select("teacher_id").Where("course_id = ? AND status <> ?", courseID, "cancelled")).
+		Order("user_id").
+		Distinct().
+		Pluck("user_id", &ids).Error
+	return ids, err
+}
+
+// CourseStudentUserIDs returns the users of the course's actively enrolled
+// students
+func (r *conversationRepository) CourseStudentUserIDs(courseID uint) ([]uint, error) {
+	var ids []uint
+	err := r.db.Model(&models.Student{}).
+		Where("id IN (?)", r.activeStudentIDs(courseID)).
+		Order("user_id").
+		Pluck("user_id", &ids).Error
+	return ids, err
+}
+
+// CourseGuardianUserIDs returns the guardians of the course's actively
+// enrolled students
+func (r *conversationRepository) CourseGuardianUserIDs(courseID uint) ([]uint, error) {
+	var ids []uint
+	err := r.db.Model(&models.Guardian{}).
+		Where("student_id IN (?)", r.activeStudentIDs(courseID)).
+		Order("user_id").
+		Distinct().
+		Pluck("user_id", &ids).Error
+	return ids, err
+}
+
+func (r *conversationRepository) activeStudentIDs(courseID uint) *gorm.DB {
+	return r.db.Model(&models.Enrollment{}).Select("student_id").Where("course_id = ? AND status = ?", courseID, "active")
+}
+
+// CourseIDsForUser returns the courses the user teaches or is actively
+// enrolled in
+func (r *conversationRepository) CourseIDsForUser(userID uint) ([]uint, error) {
+	teacherIDs := r.db.Model(&models.Teacher{}).Select("id").Where("user_id = ?", userID)
+	studentIDs := r.db.Model(&models.Student{}).Select("id").Where("user_id = ?", userID)
+	var ids []uint
+	err := r.db.Model(&models.Course{}).
+		Where("teacher_id IN (?) OR id IN (?) OR id IN (?)",
+			teacherIDs,
+			r.db.Model(&models.CourseSection{}).Select("course_id").Where("teacher_id IN (?) AND status <> ?", teacherIDs, "cancelled"),
+			r.db.Model(&models.Enrollment{}).Select("course_id").Where("student_id IN (?) AND status = ?", studentIDs, "active")).
+		Order("id").
+		Pluck("id", &ids).Error
+	return ids, err
+}
@@ -0,0 +1,48 @@
+package repository
+
+import (
+	"school-management-system/internal/models"
+	"school-management-system/pkg/database"
+
+	"gorm.io/gorm"
+)
+
+type CourseRequisiteRepository interface {
+	Create(requisite *models.CourseRequisite) error
+	FindByID(id uint) (*models.CourseRequisite, error)
+	FindByCourseID(courseID uint) ([]models.CourseRequisite, error)
+	Delete(id uint) error
+}
+
+type courseRequisiteRepository struct {
+	db *gorm.DB
+}
+
+func NewCourseRequisiteRepository() CourseRequisiteRepository {
+	return &courseRequisiteRepository{db: database.DB}
+}
+
+func (r *courseRequisiteRepository) Create(requisite *models.CourseRequisite) error {
+	return r.db.Create(requisite).Error
+}
+
+func (r *courseRequisiteRepository) FindByID(id uint) (*models.CourseRequisite, error) {
+	var requisite models.CourseRequisite
+	err := r.db.Preload("RequiredCourse").First(&requisite, id).Error
+	return &requisite, err
+}
+
+// FindByCourseID returns the courses required for a course, prerequisites
+// first
+func (r *courseRequisiteRepository) FindByCourseID(courseID uint) ([]models.CourseRequisite, error) {
+	var requisites []models.CourseRequisite
+	err := r.db.Where("course_id = ?", courseID).
+		Preload("RequiredCourse").
+		Order("kind DESC, required_course_id").
+		Find(&requisites).Error
+	return requisites, err
+}
+
+func (r *courseRequisiteRepository) Delete(id uint) error {
+	return r.db.Delete(&models.CourseRequisite{}, id).Error
+}
@@ -0,0 +1,107 @@
+package repository
+
+import (
+	"school-management-system/internal/models"
+	"school-management-system/pkg/database"
+
+	"gorm.io/gorm"
+)
+
+type CourseSectionRepository interface {
+	Create(section *models.CourseSection) error
+	FindByID(id uint) (*models.CourseSection, error)
+	FindByTermID(termID uint, page, limit int) ([]models.CourseSection, int64, error)
+	FindByCourseID(courseID uint) ([]models.CourseSection, error)
+	FindScheduledByTermID(termID uint) ([]models.CourseSection, error)
+	FindByTeacherID(teacherID uint, termID uint) ([]models.CourseSection, error)
+	FindByCourseTermAndCode(courseID, termID uint, sectionCode string) (*models.CourseSection, error)
+	Update(section *models.CourseSection) error
+	Delete(id uint) error
+	CountActiveEnrollments(sectionID uint) (int64, error)
+}
+
+type courseSectionRepository struct {
+	db *gorm.DB
+}
+
+func NewCourseSectionRepository() CourseSectionRepository {
+	return &courseSectionRepository{db: database.DB}
+}
+
+func (r *courseSectionRepository) Create(section *models.CourseSection) error {
+	return r.db.Create(section).Error
+}
+
+func (r *courseSectionRepository) FindByID(id uint) (*models.CourseSection, error) {
+	var section models.CourseSection
+	err := r.db.Preload("Course").Preload("Term").Preload("Teacher").First(&section, id).Error
+	return &section, err
+}
+
+func (r *courseSectionRepository) FindByTermID(termID uint, page, limit int) ([]models.CourseSection, int64, error) {
+	var sections []models.CourseSection
+	var total int64
+
+	offset := (page - 1) * limit
+	err := r.db.Model(&models.CourseSection{}).Where("term_id = ?", termID).Count(&total).
+		Preload("Course").
+		Preload("Teacher").
+		Order("course_id, section_code").
+		Limit(limit).
+		Offset(offset).
+		Find(&sections).Error
+
+	return sections, total, err
+}
+
+func (r *courseSectionRepository) FindByCourseID(courseID uint) ([]models.CourseSection, error) {
+	var sections []models.CourseSection
+	err := r.db.Where("course_id = ?", courseID).
+		Preload("Term").
+		Preload("Teacher").
+		Order("term_id DESC, section_code").
+		Find(&sections).Error
+	return sections, err
+}
+
+// FindScheduledByTermID returns the sections of a term that are not
+// cancelled, with their courses
+func (r *courseSectionRepository) FindScheduledByTermID(termID uint) ([]models.CourseSection, error) {
+	var sections []models.CourseSection
+	err := r.db.Where("term_id = ? AND status <> ?", termID, "cancelled").
+		Preload("Course").
+		Order("course_id, section_code").
+		Find(&sections).Error
+	return sections, err
+}
+
+func (r *courseSectionRepository) FindByTeacherID(teacherID uint, termID uint) ([]models.CourseSection, error) {
+	var sections []models.CourseSection
+	q := r.db.Where("teacher_id = ?", teacherID)
+	if termID != 0 {
+		q = q.Where("term_id = ?", termID)
+	}
+	err := q.Preload("Course").Preload("Term").Find(&sections).Error
+	return sections, err
+}
+
+func (r *courseSectionRepository) FindByCourseTermAndCode(courseID, termID uint, sectionCode string) (*models.CourseSection, error) {
+	var section models.CourseSection
+	err := r.db.Where("course_id = ? AND term_id = ? AND section_code = ?", courseID, termID, sectionCode).
+		First(&section).Error
+	return &section, err
+}
+
+func (r *courseSectionRepository) Update(section *models.CourseSection) error {
+	return r.db.Save(section).Error
+}
+
+func (r *courseSectionRepository) Delete(id uint) error {
+	return r.db.Delete(&models.CourseSection{}, id).Error
+}
+
+func (r *courseSectionRepository) CountActiveEnrollments(sectionID uint) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.Enrollment{}).Where("section_id = ? AND status IN ?", sectionID, enrollmentSeatStatuses).Count(&count).Error
+	return count, err
+}
@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"school-management-system/internal/models"
+	"school-management-system/pkg/database"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type EmailTemplateRepository interface {
+	FindByKey(key string) ([]models.EmailTemplate, error)
+	FindAll() ([]models.EmailTemplate, error)
+	FindVariant(key string, locales []string) (*models.EmailTemplate, error)
+	CreateIfMissing(template *models.EmailTemplate) error
+	Upsert(template *models.EmailTemplate) error
+	Delete(key, locale string) error
+}
+
+type emailTemplateRepository struct {
+	db *gorm.DB
+}
+
+func NewEmailTemplateRepository() EmailTemplateRepository {
+	return &emailTemplateRepository{db: database.DB}
+}
+
+func (r *emailTemplateRepository) FindByKey(key string) ([]models.EmailTemplate, error) {
+	var templates []models.EmailTemplate
+	err := r.db.Where("key = ?", key).Order("locale").Find(&templates).Error
+	return templates, err
+}
+
+func (r *emailTemplateRepository) FindAll() ([]models.EmailTemplate, error) {
+	var templates []models.EmailTemplate
+	err := r.db.Order("key, locale").Find(&templates).Error
+	return templates, err
+}
+
+// FindVariant returns the first of the given locales the template has a
+// variant for
+func (r *emailTemplateRepository) FindVariant(key string, locales []string) (*models.EmailTemplate, error) {
+	var templates []models.EmailTemplate
+	if err := r.db.Where("key = ? AND locale IN ?", key, locales).Find(&templates).Error; err != nil {
+		return nil, err
+	}
+	for _, locale := range locales {
+		for i := range templates {
+			if templates[i].Locale == locale {
+				return &templates[i], nil
+			}
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+// CreateIfMissing inserts the template unless the key already has a
+// variant for its locale
+func (r *emailTemplateRepository) CreateIfMissing(template *models.EmailTemplate) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "key"}, {Name: "locale"}},
+		DoNothing: true,
+	}).Create(template).Error
+}
+
+// Upsert creates the locale variant or replaces its content
+func (r *emailTemplateRepository) Upsert(template *models.EmailTemplate) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var existing models.EmailTemplate
+		err := tx.Where("key = ? AND locale = ?", template.Key, template.Locale).First(&existing).Error
+		if err == gorm.ErrRecordNotFound {
+			return tx.Create(template).Error
+		}
+		if err != nil {
+			return err
+		}
+		template.ID = existing.ID
+		template.CreatedAt = existing.CreatedAt
+		return tx.Save(template).Error
+	})
+}
+
+func (r *emailTemplateRepository) Delete(key, locale string) error {
+	result := r.db.Where("key = ? AND locale = ?", key, locale).Delete(&models.EmailTemplate{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
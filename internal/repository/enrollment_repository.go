@@ -1,22 +1,62 @@
 package repository
 
 import (
+	"context"
+	"errors"
 	"school-management-system/internal/models"
 	"school-management-system/pkg/database"
+	"time"
 
 	"gorm.io/gorm"
 )
 
+// ErrEnrollmentChanged is returned when an enrollment's status was changed
+// by someone else first
+var ErrEnrollmentChanged = errors.New("enrollment status has changed")
+
+// ErrCreditLimit is returned when enrollments would take a student over the
+// credit hours allowed in a term
+var ErrCreditLimit = errors.New("credit hour limit exceeded")
+
+var (
+	// enrollmentReleasedStatuses are the statuses of enrollments that are
+	// over, so the student may enroll again
+	enrollmentReleasedStatuses = []string{models.EnrollmentDropped, models.EnrollmentRejected,
+		models.EnrollmentWithdrawn, models.EnrollmentCompleted}
+	// enrollmentSeatStatuses are the statuses of enrollments holding a seat
+	enrollmentSeatStatuses = []string{models.EnrollmentApproved, models.EnrollmentActive}
+)
+
 type EnrollmentRepository interface {
-	Create(enrollment *models.Enrollment) error
+	Create(ctx context.Context, enrollment *models.Enrollment) error
 	FindByID(id uint) (*models.Enrollment, error)
 	FindByStudentAndCourse(studentID, courseID uint) (*models.Enrollment, error)
+	FindCurrentByStudentAndCourse(studentID, courseID uint) (*models.Enrollment, error)
+	FindByStudentAndSection(studentID, sectionID uint) (*models.Enrollment, error)
 	FindByStudentID(studentID uint, page, limit int) ([]models.Enrollment, int64, error)
 	FindByCourseID(courseID uint, page, limit int) ([]models.Enrollment, int64, error)
+	FindBySectionID(sectionID uint, page, limit int) ([]models.Enrollment, int64, error)
+	FindActiveByCourseID(courseID uint) ([]models.Enrollment, error)
+	FindActiveByStudentID(studentID uint) ([]models.Enrollment, error)
 	FindAll(page, limit int) ([]models.Enrollment, int64, error)
-	Update(enrollment *models.Enrollment) error
-	Delete(id uint) error
+	Update(ctx context.Context, enrollment *models.Enrollment) error
+	Delete(ctx context.Context, id uint) error
 	CountByCourseID(courseID uint) (int64, error)
+
+	EnrollWithinCapacity(ctx context.Context, enrollment *models.Enrollment, capacity int, waitlist bool, transition *models.EnrollmentTransition) (bool, error)
+	EnrollAllWithinCapacity(ctx context.Context, enrollments []*models.Enrollment, capacities []int, waitlist bool, maxCreditHours int, transitions []*models.EnrollmentTransition) (int, error)
+	CountSeats(courseID uint, sectionID *uint) (int, error)
+	HasOpenEnrollment(studentID, courseID uint, termID *uint) (bool, error)
+	FindOpenByStudentAndTerm(studentID, termID uint) ([]models.Enrollment, error)
+	FindTermSectionStudents(termID uint) ([]SectionStudent, error)
+	Transition(ctx context.Context, enrollment *models.Enrollment, transition *models.EnrollmentTransition, capacity int, grade *models.Grade) (bool, error)
+	FindTransitions(enrollmentID uint) ([]models.EnrollmentTransition, error)
+	FindApprovedStarted(now time.Time) ([]models.Enrollment, error)
+	FindWaitlisted(courseID uint, sectionID *uint) ([]models.Enrollment, error)
+	FindWaitlistedPools() ([]models.Enrollment, error)
+	WaitlistPosition(enrollment *models.Enrollment) (int64, error)
+	FindActiveTimetable(studentID uint) ([]models.TimeTable, error)
+	RenameStatus(ctx context.Context, from, to string) (int64, error)
 }
 
 type enrollmentRepository struct {
@@ -27,8 +67,8 @@ func NewEnrollmentRepository() EnrollmentRepository {
 	return &enrollmentRepository{db: database.DB}
 }
 
-func (r *enrollmentRepository) Create(enrollment *models.Enrollment) error {
-	return r.db.Create(enrollment).Error
+func (r *enrollmentRepository) Create(ctx context.Context, enrollment *models.Enrollment) error {
+	return r.db.WithContext(ctx).Create(enrollment).Error
 }
 
 func (r *enrollmentRepository) FindByID(id uint) (*models.Enrollment, error) {
@@ -43,6 +83,31 @@ func (r *enrollmentRepository) FindByStudentAndCourse(studentID, courseID uint)
 	return &enrollment, err
 }
 
+// FindCurrentByStudentAndCourse returns the student's newest active or
+// approved enrollment in a course, or their newest enrollment in it when
+// none is open, with its term.
+func (r *enrollmentRepository) FindCurrentByStudentAndCourse(studentID, courseID uint) (*models.Enrollment, error) {
+	var enrollment models.Enrollment
+	err := r.db.Where("student_id = ? AND course_id = ?", studentID, courseID).
+		Preload("Term").
+		Order("CASE WHEN status IN ('" + models.EnrollmentActive + "', '" + models.EnrollmentApproved + "') THEN 0 ELSE 1 END").
+		Order("enrolled_at DESC, id DESC").
+		First(&enrollment).Error
+	return &enrollment, err
+}
+
+// FindByStudentAndSection matches an enrollment in any section of the same
+// course and term as sectionID, so a student can't sit in two sections at once.
+// Enrollments from before terms existed have no term and match any term.
+func (r *enrollmentRepository) FindByStudentAndSection(studentID, sectionID uint) (*models.Enrollment, error) {
+	var enrollment models.Enrollment
+	err := r.db.Joins("JOIN course_sections cs ON cs.id = ?", sectionID).
+		Where("enrollments.student_id = ? AND enrollments.course_id = cs.course_id", studentID).
+		Where("(enrollments.term_id = cs.term_id OR enrollments.term_id IS NULL OR enrollments.term_id = 0)").
+		First(&enrollment).Error
+	return &enrollment, err
+}
+
 func (r *enrollmentRepository) FindByStudentID(studentID uint, page, limit int) ([]models.Enrollment, int64, error) {
 	var enrollments []models.Enrollment
 	var total int64
@@ -51,6 +116,8 @@ func (r *enrollmentRepository) FindByStudentID(studentID uint, page, limit int)
 	err := r.db.Where("student_id = ?", studentID).Count(&total).
 		Preload("Course").
 		Preload("Course.Teacher").
+		Preload("Section").
+		Preload("Term").
 		Limit(limit).
 		Offset(offset).
 		Find(&enrollments).Error
@@ -73,6 +140,42 @@ func (r *enrollmentRepository) FindByCourseID(courseID uint, page, limit int) ([
 	return enrollments, total, err
 }
 
+func (r *enrollmentRepository) FindBySectionID(sectionID uint, page, limit int) ([]models.Enrollment, int64, error) {
+	var enrollments []models.Enrollment
+	var total int64
+
+	offset := (page - 1) * limit
+	err := r.db.Model(&models.Enrollment{}).Where("section_id = ?", sectionID).Count(&total).
+		Preload("Student").
+		Preload("Student.User").
+		Limit(limit).
+		Offset(offset).
+		Find(&enrollments).Error
+
+	return enrollments, total, err
+}
+
+func (r *enrollmentRepository) FindActiveByCourseID(courseID uint) ([]models.Enrollment, error) {
+	var enrollments []models.Enrollment
+	err := r.db.Where("course_id = ? AND status = ?", courseID, "active").
+		Preload("Student").
+		Preload("Student.User").
+		Preload("Term").
+		Order("student_id ASC").
+		Find(&enrollments).Error
+	return enrollments, err
+}
+
+func (r *enrollmentRepository) FindActiveByStudentID(studentID uint) ([]models.Enrollment, error) {
+	var enrollments []models.Enrollment
+	err := r.db.Where("student_id = ? AND status = ?", studentID, "active").
+		Preload("Course").
+		Preload("Section").
+		Order("course_id ASC").
+		Find(&enrollments).Error
+	return enrollments, err
+}
+
 func (r *enrollmentRepository) FindAll(page, limit int) ([]models.Enrollment, int64, error) {
 	var enrollments []models.Enrollment
 	var total int64
@@ -88,12 +191,17 @@ func (r *enrollmentRepository) FindAll(page, limit int) ([]models.Enrollment, in
 	return enrollments, total, err
 }
 
-func (r *enrollmentRepository) Update(enrollment *models.Enrollment) error {
-	return r.db.Save(enrollment).Error
+func (r *enrollmentRepository) Update(ctx context.Context, enrollment *models.Enrollment) error {
+	return r.db.WithContext(ctx).Save(enrollment).Error
 }
 
-func (r *enrollmentRepository) Delete(id uint) error {
-	return r.db.Delete(&models.Enrollment{}, id).Error
+func (r *enrollmentRepository) Delete(ctx context.Context, id uint) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("enrollment_id = ?", id).Delete(&models.EnrollmentTransition{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.Enrollment{}, id).Error
+	})
 }
 
 func (r *enrollmentRepository) CountByCourseID(courseID uint) (int64, error) {
@@ -101,3 +209,362 @@ func (r *enrollmentRepository) CountByCourseID(courseID uint) (int64, error) {
 	err := r.db.Model(&models.Enrollment{}).Where("course_id = ? AND status = 'active'", courseID).Count(&count).Error
 	return count, err
 }
+
+// seatPool restricts a query to the enrollments competing for the same
+// seats: a section's, or for enrollments without one, the course's
+func seatPool(db *gorm.DB, courseID uint, sectionID *uint) *gorm.DB {
+	if sectionID != nil && *sectionID != 0 {
+		return db.Where("section_id = ?", *sectionID)
+	}
+	return db.Where("course_id = ? AND section_id IS NULL", courseID)
+}
+
+// lockSeatPool takes a write lock on the section or course row, so that
+// transactions counting its seats run one at a time
+func lockSeatPool(tx *gorm.DB, courseID uint, sectionID *uint) error {
+	if sectionID != nil && *sectionID != 0 {
+		return tx.Model(&models.CourseSection{}).Where("id = ?", *sectionID).Update("updated_at", time.Now()).Error
+	}
+	return tx.Exec("UPDATE courses SET max_students = max_students WHERE id = ?", courseID).Error
+}
+
+// countSeats counts the enrollments holding a seat in a section, or for
+// enrollments without one, a course
+func countSeats(tx *gorm.DB, courseID uint, sectionID *uint) (int, error) {
+	var taken int64
+	err := seatPool(tx.Model(&models.Enrollment{}), courseID, sectionID).
+		Where("status IN ?", enrollmentSeatStatuses).
+		Count(&taken).Error
+	return int(taken), err
+}
+
+// EnrollWithinCapacity saves an enrollment along with the transition that
+// records it being made. An approved or active one takes a seat if its
+// section, or without one its course, has one free, and is otherwise saved
+// as waitlisted if waitlist is set. A capacity of zero is unlimited. Seats
+// are counted under a lock on the section or course, so concurrent
+// enrollments cannot overfill it. It reports whether the enrollment was
+// saved, and returns gorm.ErrDuplicatedKey if the student already has an
+// enrollment in the course that is not over.
+func (r *enrollmentRepository) EnrollWithinCapacity(ctx context.Context, enrollment *models.Enrollment, capacity int, waitlist bool, transition *models.EnrollmentTransition) (bool, error) {
+	saved := false
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		saved, err = enrollWithinCapacity(tx, enrollment, capacity, waitlist, transition)
+		return err
+	})
+	return saved, err
+}
+
+// EnrollAllWithinCapacity saves several enrollments of one student as
+// EnrollWithinCapacity does, all or none. With a maxCreditHours, it returns
+// ErrCreditLimit and saves none if they would take the student over that
+// many credit hours in a term; the student is locked while their hours are
+// counted, so concurrent registrations cannot both pass. It returns the
+// index of the first enrollment that found no seat, or -1 once all are
+// saved.
+func (r *enrollmentRepository) EnrollAllWithinCapacity(ctx context.Context, enrollments []*models.Enrollment, capacities []int, waitlist bool, maxCreditHours int, transitions []*models.EnrollmentTransition) (int, error) {
+	full := -1
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if maxCreditHours > 0 && len(enrollments) > 0 {
+			if err := tx.Exec("UPDATE students SET id = id WHERE id = ?", enrollments[0].StudentID).Error; err != nil {
+				return err
+			}
+		}
+		terms := map[uint]bool{}
+		for i, enrollment := range enrollments {
+			saved, err := enrollWithinCapacity(tx, enrollment, capacities[i], waitlist, transitions[i])
+			if err != nil {
+				return err
+			}
+			if !saved {
+				full = i
+				return errNoSeat
+			}
+			if enrollment.TermID != nil {
+				terms[*enrollment.TermID] = true
+			}
+		}
+		if maxCreditHours == 0 {
+			return nil
+		}
+		for termID := range terms {
+			hours, err := termCreditHours(tx, enrollments[0].StudentID, termID)
+			if err != nil {
+				return err
+			}
+			if hours > maxCreditHours {
+				return ErrCreditLimit
+			}
+		}
+		return nil
+	})
+	if full >= 0 || err != nil {
+		// Nothing was saved
+		for _, enrollment := range enrollments {
+			enrollment.ID = 0
+		}
+	}
+	if full >= 0 {
+		return full, nil
+	}
+	return -1, err
+}
+
+// termCreditHours sums the credit hours of a student's enrollments in a
+// term that are not over
+func termCreditHours(tx *gorm.DB, studentID, termID uint) (int, error) {
+	var hours int
+	err := tx.Model(&models.Enrollment{}).
+		Select("COALESCE(SUM(courses.credit_hours), 0)").
+		Joins("JOIN courses ON courses.id = enrollments.course_id").
+		Where("enrollments.student_id = ? AND enrollments.term_id = ? AND enrollments.status NOT IN ?", studentID, termID, enrollmentReleasedStatuses).
+		Scan(&hours).Error
+	return hours, err
+}
+
+// errNoSeat rolls back a transaction that found a section or course full
+var errNoSeat = errors.New("no seat available")
+
+func enrollWithinCapacity(tx *gorm.DB, enrollment *models.Enrollment, capacity int, waitlist bool, transition *models.EnrollmentTransition) (bool, error) {
+	if err := lockSeatPool(tx, enrollment.CourseID, enrollment.SectionID); err != nil {
+		return false, err
+	}
+
+	existing := tx.Model(&models.Enrollment{}).Where("student_id = ? AND status NOT IN ?", enrollment.StudentID, enrollmentReleasedStatuses)
+	if enrollment.SectionID != nil && *enrollment.SectionID != 0 {
+		// Enrollments from before terms existed have no term and match any term
+		existing = existing.Where("course_id = (?) AND (term_id = (?) OR term_id IS NULL OR term_id = 0)",
+			tx.Model(&models.CourseSection{}).Select("course_id").Where("id = ?", *enrollment.SectionID),
+			tx.Model(&models.CourseSection{}).Select("term_id").Where("id = ?", *enrollment.SectionID))
+	} else {
+		existing = existing.Where("course_id = ?", enrollment.CourseID)
+	}
+	var duplicates int64
+	if err := existing.Count(&duplicates).Error; err != nil {
+		return false, err
+	}
+	if duplicates > 0 {
+		return false, gorm.ErrDuplicatedKey
+	}
+
+	if models.EnrollmentHoldsSeat(enrollment.Status) && capacity > 0 {
+		taken, err := countSeats(tx, enrollment.CourseID, enrollment.SectionID)
+		if err != nil {
+			return false, err
+		}
+		if taken >= capacity {
+			if !waitlist {
+				return false, nil
+			}
+			now := time.Now()
+			enrollment.Status = models.EnrollmentWaitlisted
+			enrollment.WaitlistedAt = &now
+		}
+	}
+	if err := tx.Create(enrollment).Error; err != nil {
+		return false, err
+	}
+	transition.EnrollmentID = enrollment.ID
+	transition.ToStatus = enrollment.Status
+	transition.CreatedAt = time.Now().Unix()
+	if err := tx.Create(transition).Error; err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// CountSeats counts the enrollments holding a seat in a section, or for
+// enrollments without one, a course
+func (r *enrollmentRepository) CountSeats(courseID uint, sectionID *uint) (int, error) {
+	return countSeats(r.db, courseID, sectionID)
+}
+
+// HasOpenEnrollment reports whether a student has an enrollment in a course
+// that is not over, in the given term if there is one. Enrollments from
+// before terms existed match any term.
+func (r *enrollmentRepository) HasOpenEnrollment(studentID, courseID uint, termID *uint) (bool, error) {
+	query := r.db.Model(&models.Enrollment{}).
+		Where("student_id = ? AND course_id = ? AND status NOT IN ?", studentID, courseID, enrollmentReleasedStatuses)
+	if termID != nil {
+		query = query.Where("term_id = ? OR term_id IS NULL OR term_id = 0", *termID)
+	}
+	var count int64
+	err := query.Count(&count).Error
+	return count > 0, err
+}
+
+// FindOpenByStudentAndTerm returns a student's enrollments in a term that
+// are not over, with their courses
+func (r *enrollmentRepository) FindOpenByStudentAndTerm(studentID, termID uint) ([]models.Enrollment, error) {
+	var enrollments []models.Enrollment
+	err := r.db.Where("student_id = ? AND term_id = ? AND status NOT IN ?", studentID, termID, enrollmentReleasedStatuses).
+		Preload("Course").
+		Order("id").
+		Find(&enrollments).Error
+	return enrollments, err
+}
+
+// SectionStudent is a student with an enrollment in a section
+type SectionStudent struct {
+	SectionID uint
+	StudentID uint
+}
+
+// FindTermSectionStudents returns who is enrolled in each section of a
+// term, for enrollments that are not over
+func (r *enrollmentRepository) FindTermSectionStudents(termID uint) ([]SectionStudent, error) {
+	var rows []SectionStudent
+	err := r.db.Model(&models.Enrollment{}).
+		Select("section_id, student_id").
+		Where("term_id = ? AND section_id IS NOT NULL AND status NOT IN ?", termID, enrollmentReleasedStatuses).
+		Order("student_id, section_id").
+		Scan(&rows).Error
+	return rows, err
+}
+
+// Transition moves an enrollment from transition.FromStatus to
+// transition.ToStatus and records the change, with grade if one is given.
+// Moving into a seat is checked against capacity under the same lock as
+// EnrollWithinCapacity, and reports false without a change if there is no
+// free seat. It returns ErrEnrollmentChanged if the enrollment is no longer
+// in FromStatus.
+func (r *enrollmentRepository) Transition(ctx context.Context, enrollment *models.Enrollment, transition *models.EnrollmentTransition, capacity int, grade *models.Grade) (bool, error) {
+	changed := false
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := lockSeatPool(tx, enrollment.CourseID, enrollment.SectionID); err != nil {
+			return err
+		}
+		takesSeat := models.EnrollmentHoldsSeat(transition.ToStatus) && !models.EnrollmentHoldsSeat(transition.FromStatus)
+		if takesSeat && capacity > 0 {
+			taken, err := countSeats(tx, enrollment.CourseID, enrollment.SectionID)
+			if err != nil {
+				return err
+			}
+			if taken >= capacity {
+				return nil
+			}
+		}
+
+		now := time.Now()
+		updates := map[string]interface{}{"status": transition.ToStatus}
+		if takesSeat {
+			updates["enrolled_at"] = now
+		}
+		if transition.ToStatus == models.EnrollmentWaitlisted {
+			updates["waitlisted_at"] = now
+		}
+		result := tx.Model(&models.Enrollment{}).
+			Where("id = ? AND status = ?", enrollment.ID, transition.FromStatus).
+			Updates(updates)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrEnrollmentChanged
+		}
+
+		transition.EnrollmentID = enrollment.ID
+		transition.CreatedAt = now.Unix()
+		if err := tx.Create(transition).Error; err != nil {
+			return err
+		}
+		if grade != nil {
+			if err := tx.Create(grade).Error; err != nil {
+				return err
+			}
+		}
+
+		enrollment.Status = transition.ToStatus
+		if takesSeat {
+			enrollment.EnrolledAt = now
+		}
+		if transition.ToStatus == models.EnrollmentWaitlisted {
+			enrollment.WaitlistedAt = &now
+		}
+		changed = true
+		return nil
+	})
+	return changed, err
+}
+
+// FindTransitions returns an enrollment's status history, oldest first
+func (r *enrollmentRepository) FindTransitions(enrollmentID uint) ([]models.EnrollmentTransition, error) {
+	var transitions []models.EnrollmentTransition
+	err := r.db.Where("enrollment_id = ?", enrollmentID).
+		Preload("Actor").
+		Order("created_at, id").
+		Find(&transitions).Error
+	return transitions, err
+}
+
+// FindApprovedStarted returns approved enrollments whose term has begun,
+// or that belong to no term
+func (r *enrollmentRepository) FindApprovedStarted(now time.Time) ([]models.Enrollment, error) {
+	var enrollments []models.Enrollment
+	err := r.db.Where("status = ?", models.EnrollmentApproved).
+		Where("term_id IS NULL OR term_id IN (?)",
+			r.db.Model(&models.AcademicTerm{}).Select("id").Where("start_date <= ?", now)).
+		Order("id").
+		Find(&enrollments).Error
+	return enrollments, err
+}
+
+// FindWaitlisted returns the waitlist of a section, or of a course's
+// enrollments without a section, first come first
+func (r *enrollmentRepository) FindWaitlisted(courseID uint, sectionID *uint) ([]models.Enrollment, error) {
+	var enrollments []models.Enrollment
+	query := seatPool(r.db.Where("status = ?", models.EnrollmentWaitlisted), courseID, sectionID)
+	err := query.Order("waitlisted_at, id").Find(&enrollments).Error
+	return enrollments, err
+}
+
+// FindWaitlistedPools returns one course and section pair for each
+// waitlist with anyone on it
+func (r *enrollmentRepository) FindWaitlistedPools() ([]models.Enrollment, error) {
+	var pools []models.Enrollment
+	err := r.db.Model(&models.Enrollment{}).
+		Select("course_id, section_id").
+		Where("status = ?", models.EnrollmentWaitlisted).
+		Group("course_id, section_id").
+		Order("course_id, section_id").
+		Find(&pools).Error
+	return pools, err
+}
+
+// WaitlistPosition returns where a waitlisted enrollment is in its queue,
+// counting from one
+func (r *enrollmentRepository) WaitlistPosition(enrollment *models.Enrollment) (int64, error) {
+	if enrollment.WaitlistedAt == nil {
+		return 0, nil
+	}
+	var ahead int64
+	query := seatPool(r.db.Model(&models.Enrollment{}).Where("status = ?", models.EnrollmentWaitlisted), enrollment.CourseID, enrollment.SectionID)
+	err := query.Where("waitlisted_at < ? OR (waitlisted_at = ? AND id < ?)", *enrollment.WaitlistedAt, *enrollment.WaitlistedAt, enrollment.ID).
+		Count(&ahead).Error
+	return ahead + 1, err
+}
+
+// FindActiveTimetable returns the class times of the enrollments in which
+// the student holds a seat: their sections' slots, or for enrollments without a section
+// the course's slots that belong to no section
+func (r *enrollmentRepository) FindActiveTimetable(studentID uint) ([]models.TimeTable, error) {
+	var slots []models.TimeTable
+	err := r.db.Model(&models.TimeTable{}).
+		Joins("JOIN enrollments e ON e.student_id = ? AND e.status IN ? AND "+
+			"((e.section_id IS NOT NULL AND timetables.section_id = e.section_id) OR "+
+			"(e.section_id IS NULL AND timetables.section_id IS NULL AND timetables.course_id = e.course_id))",
+			studentID, enrollmentSeatStatuses).
+		Where("timetables.is_active = ?", true).
+		Preload("Course").
+		Order("timetables.id").
+		Find(&slots).Error
+	return slots, err
+}
+
+// RenameStatus moves every enrollment with one status to another, returning
+// how many moved
+func (r *enrollmentRepository) RenameStatus(ctx context.Context, from, to string) (int64, error) {
+	result := r.db.WithContext(ctx).Model(&models.Enrollment{}).Where("status = ?", from).Update("status", to)
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,55 @@
+package repository
+
+import (
+	"school-management-system/internal/models"
+	"school-management-system/pkg/database"
+
+	"gorm.io/gorm"
+)
+
+type GradeCategoryRepository interface {
+	Create(category *models.GradeCategory) error
+	FindByID(id uint) (*models.GradeCategory, error)
+	FindByCourseID(courseID uint) ([]models.GradeCategory, error)
+	Update(category *models.GradeCategory) error
+	Delete(id uint) error
+	CountAssignments(categoryID uint) (int64, error)
+}
+
+type gradeCategoryRepository struct {
+	db *gorm.DB
+}
+
+func NewGradeCategoryRepository() GradeCategoryRepository {
+	return &gradeCategoryRepository{db: database.DB}
+}
+
+func (r *gradeCategoryRepository) Create(category *models.GradeCategory) error {
+	return r.db.Create(category).Error
+}
+
+func (r *gradeCategoryRepository) FindByID(id uint) (*models.GradeCategory, error) {
+	var category models.GradeCategory
+	err := r.db.First(&category, id).Error
+	return &category, err
+}
+
+func (r *gradeCategoryRepository) FindByCourseID(courseID uint) ([]models.GradeCategory, error) {
+	var categories []models.GradeCategory
+	err := r.db.Where("course_id = ?", courseID).Order("id ASC").Find(&categories).Error
+	return categories, err
+}
+
+func (r *gradeCategoryRepository) Update(category *models.GradeCategory) error {
+	return r.db.Save(category).Error
+}
+
+func (r *gradeCategoryRepository) Delete(id uint) error {
+	return r.db.Delete(&models.GradeCategory{}, id).Error
+}
+
+func (r *gradeCategoryRepository) CountAssignments(categoryID uint) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.Assignment{}).Where("category_id = ?", categoryID).Count(&count).Error
+	return count, err
+}
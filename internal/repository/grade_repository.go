@@ -1,6 +1,7 @@
 package repository
 
 import (
+	"context"
 	"school-management-system/internal/models"
 	"school-management-system/pkg/database"
 	"time"
@@ -9,16 +10,19 @@ import (
 )
 
 type GradeRepository interface {
-	Create(grade *models.Grade) error
+	Create(ctx context.Context, grade *models.Grade) error
 	FindByID(id uint) (*models.Grade, error)
 	FindByStudentAndCourse(studentID, courseID uint) (*models.Grade, error)
 	FindByStudentID(studentID uint, page, limit int) ([]models.Grade, int64, error)
 	FindByCourseID(courseID uint, page, limit int) ([]models.Grade, int64, error)
 	FindAll(page, limit int) ([]models.Grade, int64, error)
-	Update(grade *models.Grade) error
-	Delete(id uint) error
+	Update(ctx context.Context, grade *models.Grade) error
+	Delete(ctx context.Context, id uint) error
 	FindByTeacherID(teacherID uint, page, limit int) ([]models.Grade, int64, error)
 	FindGradesInDateRange(startDate, endDate time.Time) ([]models.Grade, error)
+	FindAllByStudentID(studentID uint) ([]models.Grade, error)
+	FindStudentIDsByTerm(termID uint) ([]uint, error)
+	FindGradebookGrade(studentID, courseID uint, termID *uint) (*models.Grade, error)
 }
 
 type gradeRepository struct {
@@ -29,8 +33,8 @@ func NewGradeRepository() GradeRepository {
 	return &gradeRepository{db: database.DB}
 }
 
-func (r *gradeRepository) Create(grade *models.Grade) error {
-	return r.db.Create(grade).Error
+func (r *gradeRepository) Create(ctx context.Context, grade *models.Grade) error {
+	return r.db.WithContext(ctx).Create(grade).Error
 }
 
 func (r *gradeRepository) FindByID(id uint) (*models.Grade, error) {
@@ -91,12 +95,12 @@ func (r *gradeRepository) FindAll(page, limit int) ([]models.Grade, int64, error
 	return grades, total, err
 }
 
-func (r *gradeRepository) Update(grade *models.Grade) error {
-	return r.db.Save(grade).Error
+func (r *gradeRepository) Update(ctx context.Context, grade *models.Grade) error {
+	return r.db.WithContext(ctx).Save(grade).Error
 }
 
-func (r *gradeRepository) Delete(id uint) error {
-	return r.db.Delete(&models.Grade{}, id).Error
+func (r *gradeRepository) Delete(ctx context.Context, id uint) error {
+	return r.db.WithContext(ctx).Delete(&models.Grade{}, id).Error
 }
 
 func (r *gradeRepository) FindByTeacherID(teacherID uint, page, limit int) ([]models.Grade, int64, error) {
@@ -123,3 +127,37 @@ func (r *gradeRepository) FindGradesInDateRange(startDate, endDate time.Time) ([
 
 	return grades, err
 }
+
+func (r *gradeRepository) FindAllByStudentID(studentID uint) ([]models.Grade, error) {
+	return studentGrades(r.db, studentID)
+}
+
+func studentGrades(db *gorm.DB, studentID uint) ([]models.Grade, error) {
+	var grades []models.Grade
+	err := db.Where("student_id = ?", studentID).
+		Preload("Course").
+		Order("graded_at, id").
+		Find(&grades).Error
+
+	return grades, err
+}
+
+func (r *gradeRepository) FindStudentIDsByTerm(termID uint) ([]uint, error) {
+	var ids []uint
+	err := r.db.Model(&models.Grade{}).Where("term_id = ?", termID).Distinct().Pluck("student_id", &ids).Error
+	return ids, err
+}
+
+// FindGradebookGrade returns the grade computed from the course gradebook for
+// a student, scoped to the term when one is given.
+func (r *gradeRepository) FindGradebookGrade(studentID, courseID uint, termID *uint) (*models.Grade, error) {
+	var grade models.Grade
+	q := r.db.Where("student_id = ? AND course_id = ? AND source = ?", studentID, courseID, models.GradeSourceGradebook)
+	if termID != nil {
+		q = q.Where("term_id = ?", *termID)
+	} else {
+		q = q.Where("term_id IS NULL")
+	}
+	err := q.First(&grade).Error
+	return &grade, err
+}
@@ -11,12 +11,26 @@ type GradeTranscriptRepository interface {
 	Create(transcript *models.GradeTranscript) error
 	FindByID(id uint) (*models.GradeTranscript, error)
 	FindByStudentID(studentID uint, page, limit int) ([]models.GradeTranscript, int64, error)
+	FindAllByStudent(studentID uint) ([]models.GradeTranscript, error)
 	FindByStudentAndYear(studentID uint, year int) (*models.GradeTranscript, error)
+	FindByStudentAndTerm(studentID, termID uint) (*models.GradeTranscript, error)
 	Update(transcript *models.GradeTranscript) error
 	Delete(id uint) error
 	FindLatestByStudent(studentID uint) (*models.GradeTranscript, error)
+	ReplaceForStudent(studentID uint, build func(TranscriptInputs) []models.GradeTranscript) ([]models.GradeTranscript, error)
 }
 
+// TranscriptInputs is what a student's transcript is built from
+type TranscriptInputs struct {
+	Grades    []models.Grade
+	Terms     []models.AcademicTerm
+	Transfers []models.TransferCredit
+}
+
+// transcriptChronology orders transcript rows by their term, with transfer
+// credit rows (no term) first.
+const transcriptChronology = "CASE WHEN grade_transcripts.term_id IS NULL THEN 0 ELSE 1 END, academic_terms.start_date, grade_transcripts.id"
+
 type gradeTranscriptRepository struct {
 	db *gorm.DB
 }
@@ -39,15 +53,26 @@ func (r *gradeTranscriptRepository) FindByStudentID(studentID uint, page, limit
 	var transcripts []models.GradeTranscript
 	var total int64
 	offset := (page - 1) * limit
-	err := r.db.Where("student_id = ?", studentID).Count(&total).
+	err := r.db.Model(&models.GradeTranscript{}).Where("grade_transcripts.student_id = ?", studentID).Count(&total).
+		Joins("LEFT JOIN academic_terms ON academic_terms.id = grade_transcripts.term_id").
 		Preload("Student").
-		Order("year DESC, transcript_semester DESC").
+		Preload("Term").
+		Order(transcriptChronology).
 		Limit(limit).
 		Offset(offset).
 		Find(&transcripts).Error
 	return transcripts, total, err
 }
 
+func (r *gradeTranscriptRepository) FindAllByStudent(studentID uint) ([]models.GradeTranscript, error) {
+	var transcripts []models.GradeTranscript
+	err := r.db.Where("grade_transcripts.student_id = ?", studentID).
+		Joins("LEFT JOIN academic_terms ON academic_terms.id = grade_transcripts.term_id").
+		Order(transcriptChronology).
+		Find(&transcripts).Error
+	return transcripts, err
+}
+
 func (r *gradeTranscriptRepository) FindByStudentAndYear(studentID uint, year int) (*models.GradeTranscript, error) {
 	var transcript models.GradeTranscript
 	err := r.db.Where("student_id = ? AND year = ?", studentID, year).
@@ -56,6 +81,14 @@ func (r *gradeTranscriptRepository) FindByStudentAndYear(studentID uint, year in
 	return &transcript, err
 }
 
+func (r *gradeTranscriptRepository) FindByStudentAndTerm(studentID, termID uint) (*models.GradeTranscript, error) {
+	var transcript models.GradeTranscript
+	err := r.db.Where("student_id = ? AND term_id = ?", studentID, termID).
+		Preload("Term").
+		First(&transcript).Error
+	return &transcript, err
+}
+
 func (r *gradeTranscriptRepository) Update(transcript *models.GradeTranscript) error {
 	return r.db.Save(transcript).Error
 }
@@ -66,9 +99,85 @@ func (r *gradeTranscriptRepository) Delete(id uint) error {
 
 func (r *gradeTranscriptRepository) FindLatestByStudent(studentID uint) (*models.GradeTranscript, error) {
 	var transcript models.GradeTranscript
-	err := r.db.Where("student_id = ?", studentID).
+	err := r.db.Where("grade_transcripts.student_id = ?", studentID).
+		Joins("LEFT JOIN academic_terms ON academic_terms.id = grade_transcripts.term_id").
 		Preload("Student").
-		Order("year DESC, transcript_semester DESC").
+		Preload("Term").
+		Order("CASE WHEN grade_transcripts.term_id IS NULL THEN 1 ELSE 0 END, academic_terms.start_date DESC, grade_transcripts.id DESC").
 		First(&transcript).Error
 	return &transcript, err
 }
+
+// ReplaceForStudent rebuilds a student's transcript with build and saves it:
+// rows of terms on file are updated in place, new terms get new rows and rows
+// of terms not built are removed. The student's row is locked before the
+// grades, terms and transfer credit are read, so rebuilds of one student run
+// one at a time, each from what the one before it saved, and cannot write a
+// stale transcript or both add a row for the same term.
+func (r *gradeTranscriptRepository) ReplaceForStudent(studentID uint, build func(TranscriptInputs) []models.GradeTranscript) ([]models.GradeTranscript, error) {
+	var rows []models.GradeTranscript
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("UPDATE students SET id = id WHERE id = ?", studentID).Error; err != nil {
+			return err
+		}
+		var inputs TranscriptInputs
+		var err error
+		if inputs.Grades, err = studentGrades(tx, studentID); err != nil {
+			return err
+		}
+		if inputs.Terms, err = orderedTerms(tx); err != nil {
+			return err
+		}
+		if inputs.Transfers, err = studentTransferCredits(tx, studentID); err != nil {
+			return err
+		}
+		rows = build(inputs)
+
+		var existing []models.GradeTranscript
+		if err := tx.Where("student_id = ?", studentID).Order("id").Find(&existing).Error; err != nil {
+			return err
+		}
+		byTerm := make(map[uint]models.GradeTranscript, len(existing))
+		var duplicates []uint
+		for _, row := range existing {
+			key := transcriptTermKey(row.TermID)
+			if _, ok := byTerm[key]; ok {
+				duplicates = append(duplicates, row.ID)
+				continue
+			}
+			byTerm[key] = row
+		}
+
+		for i := range rows {
+			row := &rows[i]
+			key := transcriptTermKey(row.TermID)
+			if prev, ok := byTerm[key]; ok {
+				row.ID, row.CreatedAt = prev.ID, prev.CreatedAt
+				delete(byTerm, key)
+				if err := tx.Omit("Student", "Term").Save(row).Error; err != nil {
+					return err
+				}
+			} else if err := tx.Omit("Student", "Term").Create(row).Error; err != nil {
+				return err
+			}
+		}
+
+		for _, stale := range byTerm {
+			duplicates = append(duplicates, stale.ID)
+		}
+		if len(duplicates) == 0 {
+			return nil
+		}
+		return tx.Delete(&models.GradeTranscript{}, duplicates).Error
+	})
+	return rows, err
+}
+
+// transcriptTermKey keys transcript rows by term, with 0 for the transfer
+// credit row
+func transcriptTermKey(termID *uint) uint {
+	if termID == nil {
+		return 0
+	}
+	return *termID
+}
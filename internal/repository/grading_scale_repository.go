@@ -0,0 +1,94 @@
+package repository
+
+import (
+	"school-management-system/internal/models"
+	"school-management-system/pkg/database"
+
+	"gorm.io/gorm"
+)
+
+type GradingScaleRepository interface {
+	Create(scale *models.GradingScale) error
+	FindByID(id uint) (*models.GradingScale, error)
+	FindAll() ([]models.GradingScale, error)
+	FindByCourseID(courseID uint) (*models.GradingScale, error)
+	FindByDepartment(department string) (*models.GradingScale, error)
+	FindSchoolDefault() (*models.GradingScale, error)
+	Update(scale *models.GradingScale) error
+	Delete(id uint) error
+}
+
+type gradingScaleRepository struct {
+	db *gorm.DB
+}
+
+func NewGradingScaleRepository() GradingScaleRepository {
+	return &gradingScaleRepository{db: database.DB}
+}
+
+func (r *gradingScaleRepository) withBands() *gorm.DB {
+	return r.db.Preload("Bands", func(db *gorm.DB) *gorm.DB {
+		return db.Order("min_percentage DESC")
+	})
+}
+
+func (r *gradingScaleRepository) Create(scale *models.GradingScale) error {
+	return r.db.Create(scale).Error
+}
+
+func (r *gradingScaleRepository) FindByID(id uint) (*models.GradingScale, error) {
+	var scale models.GradingScale
+	err := r.withBands().First(&scale, id).Error
+	return &scale, err
+}
+
+func (r *gradingScaleRepository) FindAll() ([]models.GradingScale, error) {
+	var scales []models.GradingScale
+	err := r.withBands().Order("scope, name").Find(&scales).Error
+	return scales, err
+}
+
+func (r *gradingScaleRepository) FindByCourseID(courseID uint) (*models.GradingScale, error) {
+	var scale models.GradingScale
+	err := r.withBands().
+		Where("scope = ? AND course_id = ?", models.GradingScopeCourse, courseID).
+		First(&scale).Error
+	return &scale, err
+}
+
+func (r *gradingScaleRepository) FindByDepartment(department string) (*models.GradingScale, error) {
+	var scale models.GradingScale
+	err := r.withBands().
+		Where("scope = ? AND department = ?", models.GradingScopeDepartment, department).
+		First(&scale).Error
+	return &scale, err
+}
+
+func (r *gradingScaleRepository) FindSchoolDefault() (*models.GradingScale, error) {
+	var scale models.GradingScale
+	err := r.withBands().Where("scope = ?", models.GradingScopeSchool).First(&scale).Error
+	return &scale, err
+}
+
+// Update saves the scale and replaces its bands with scale.Bands.
+func (r *gradingScaleRepository) Update(scale *models.GradingScale) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("scale_id = ?", scale.ID).Delete(&models.GradingBand{}).Error; err != nil {
+			return err
+		}
+		for i := range scale.Bands {
+			scale.Bands[i].ID = 0
+			scale.Bands[i].ScaleID = scale.ID
+		}
+		return tx.Session(&gorm.Session{FullSaveAssociations: true}).Save(scale).Error
+	})
+}
+
+func (r *gradingScaleRepository) Delete(id uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("scale_id = ?", id).Delete(&models.GradingBand{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.GradingScale{}, id).Error
+	})
+}
@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"school-management-system/internal/models"
+	"school-management-system/pkg/database"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type GuardianRepository interface {
+	Create(guardian *models.Guardian) error
+	FindByID(id uint) (*models.Guardian, error)
+	FindByUserAndStudent(userID, studentID uint) (*models.Guardian, error)
+	FindByUserID(userID uint) ([]models.Guardian, error)
+	FindByStudentID(studentID uint) ([]models.Guardian, error)
+	FindNotifiableByStudentID(studentID uint) ([]models.Guardian, error)
+	Update(guardian *models.Guardian) error
+	Delete(id uint) error
+}
+
+type guardianRepository struct {
+	db *gorm.DB
+}
+
+func NewGuardianRepository() GuardianRepository {
+	return &guardianRepository{db: database.DB}
+}
+
+func (r *guardianRepository) Create(guardian *models.Guardian) error {
+	return r.db.Create(guardian).Error
+}
+
+func (r *guardianRepository) FindByID(id uint) (*models.Guardian, error) {
+	var guardian models.Guardian
+	err := r.db.Preload("User").Preload("Student.User").First(&guardian, id).Error
+	return &guardian, err
+}
+
+func (r *guardianRepository) FindByUserAndStudent(userID, studentID uint) (*models.Guardian, error) {
+	var guardian models.Guardian
+	err := r.db.Where("user_id = ? AND student_id = ?", userID, studentID).First(&guardian).Error
+	return &guardian, err
+}
+
+func (r *guardianRepository) FindByUserID(userID uint) ([]models.Guardian, error) {
+	var guardians []models.Guardian
+	err := r.db.Where("user_id = ?", userID).
+		Preload("Student.User").
+		Order("id").
+		Find(&guardians).Error
+	return guardians, err
+}
+
+func (r *guardianRepository) FindByStudentID(studentID uint) ([]models.Guardian, error) {
+	var guardians []models.Guardian
+	err := r.db.Where("student_id = ?", studentID).
+		Preload("User").
+		Order("id").
+		Find(&guardians).Error
+	return guardians, err
+}
+
+// FindNotifiableByStudentID returns the student's guardians who opted in to
+// notifications and whose accounts are active.
+func (r *guardianRepository) FindNotifiableByStudentID(studentID uint) ([]models.Guardian, error) {
+	var guardians []models.Guardian
+	err := r.db.Joins("User").
+		Where("guardians.student_id = ? AND guardians.receives_notifications = ?", studentID, true).
+		Where("User.is_active = ?", true).
+		Find(&guardians).Error
+	return guardians, err
+}
+
+func (r *guardianRepository) Update(guardian *models.Guardian) error {
+	return r.db.Omit(clause.Associations).Save(guardian).Error
+}
+
+func (r *guardianRepository) Delete(id uint) error {
+	return r.db.Delete(&models.Guardian{}, id).Error
+}
@@ -5,6 +5,7 @@ import (
 	"school-management-system/pkg/database"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type ImportBatchRepository interface {
@@ -39,9 +40,11 @@ func (r *importBatchRepository) FindAll(page, limit int) ([]models.ImportBatch,
 	var batches []models.ImportBatch
 	var total int64
 	offset := (page - 1) * limit
-	err := r.db.Count(&total).
-		Preload("CreatedByUser").
-		Order("created_at DESC").
+	if err := r.db.Model(&models.ImportBatch{}).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := r.db.Preload("CreatedByUser").
+		Order("created_at DESC, id DESC").
 		Limit(limit).
 		Offset(offset).
 		Find(&batches).Error
@@ -52,9 +55,12 @@ func (r *importBatchRepository) FindByStatus(status string, page, limit int) ([]
 	var batches []models.ImportBatch
 	var total int64
 	offset := (page - 1) * limit
-	err := r.db.Where("status = ?", status).Count(&total).
+	if err := r.db.Model(&models.ImportBatch{}).Where("status = ?", status).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := r.db.Where("status = ?", status).
 		Preload("CreatedByUser").
-		Order("created_at DESC").
+		Order("created_at DESC, id DESC").
 		Limit(limit).
 		Offset(offset).
 		Find(&batches).Error
@@ -62,7 +68,7 @@ func (r *importBatchRepository) FindByStatus(status string, page, limit int) ([]
 }
 
 func (r *importBatchRepository) Update(batch *models.ImportBatch) error {
-	return r.db.Save(batch).Error
+	return r.db.Omit(clause.Associations).Save(batch).Error
 }
 
 func (r *importBatchRepository) Delete(id uint) error {
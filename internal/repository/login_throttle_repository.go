@@ -0,0 +1,74 @@
+package repository
+
+import (
+	"school-management-system/internal/models"
+	"school-management-system/pkg/database"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type LoginThrottleRepository interface {
+	Find(scope, key string) (*models.LoginThrottle, error)
+	RecordFailure(scope, key string, now, windowStart int64) (*models.LoginThrottle, error)
+	Lock(id uint, until int64) error
+	Reset(scope, key string) error
+	FindLocked(now int64) ([]models.LoginThrottle, error)
+}
+
+type loginThrottleRepository struct {
+	db *gorm.DB
+}
+
+func NewLoginThrottleRepository() LoginThrottleRepository {
+	return &loginThrottleRepository{db: database.DB}
+}
+
+func (r *loginThrottleRepository) Find(scope, key string) (*models.LoginThrottle, error) {
+	var throttle models.LoginThrottle
+	err := r.db.Where("scope = ? AND key = ?", scope, key).First(&throttle).Error
+	return &throttle, err
+}
+
+// RecordFailure atomically counts a failed attempt. Failures older than
+// windowStart are forgotten and counting restarts at one.
+func (r *loginThrottleRepository) RecordFailure(scope, key string, now, windowStart int64) (*models.LoginThrottle, error) {
+	var throttle models.LoginThrottle
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.LoginThrottle{
+			Scope:     scope,
+			Key:       key,
+			CreatedAt: now,
+			UpdatedAt: now,
+		}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Model(&models.LoginThrottle{}).
+			Where("scope = ? AND key = ?", scope, key).
+			Updates(map[string]interface{}{
+				"failures":        gorm.Expr("CASE WHEN last_failure_at < ? THEN 1 ELSE failures + 1 END", windowStart),
+				"last_failure_at": now,
+				"updated_at":      now,
+			}).Error; err != nil {
+			return err
+		}
+
+		return tx.Where("scope = ? AND key = ?", scope, key).First(&throttle).Error
+	})
+	return &throttle, err
+}
+
+func (r *loginThrottleRepository) Lock(id uint, until int64) error {
+	return r.db.Model(&models.LoginThrottle{}).Where("id = ?", id).Update("locked_until", until).Error
+}
+
+func (r *loginThrottleRepository) Reset(scope, key string) error {
+	return r.db.Where("scope = ? AND key = ?", scope, key).Delete(&models.LoginThrottle{}).Error
+}
+
+func (r *loginThrottleRepository) FindLocked(now int64) ([]models.LoginThrottle, error) {
+	var throttles []models.LoginThrottle
+	err := r.db.Where("locked_until > ?", now).Order("locked_until DESC").Find(&throttles).Error
+	return throttles, err
+}
@@ -8,14 +8,24 @@ import (
 )
 
 type MessageRepository interface {
-	Create(message *models.Message) error
+	Create(message *models.Message, attachmentIDs []uint) error
 	FindByID(id uint) (*models.Message, error)
-	FindByReceiverID(receiverID uint, page, limit int) ([]models.Message, int64, error)
 	FindConversation(userID1, userID2 uint, page, limit int) ([]models.Message, int64, error)
-	Update(message *models.Message) error
-	Delete(id uint) error
-	MarkAsRead(id uint) error
-	CountUnread(userID uint) (int64, error)
+	FindByConversationID(conversationID uint, page, limit int) ([]models.Message, int64, error)
+	FindLatestByConversationIDs(conversationIDs []uint) ([]models.Message, error)
+	FindLatestID(conversationID uint) (uint, error)
+	UpdateContent(id uint, content string, editedAt int64) error
+	SoftDelete(id, deletedBy uint, at int64) ([]models.MessageAttachment, error)
+	MarkDirectRead(conversationID, receiverID, upToID uint, at int64) error
+
+	CreateAttachment(attachment *models.MessageAttachment) error
+	FindAttachmentByID(id uint) (*models.MessageAttachment, error)
+	FindUnclaimedAttachments(before int64) ([]models.MessageAttachment, error)
+	DeleteAttachment(id uint) error
+
+	FindUnthreaded(limit int) ([]models.Message, error)
+	AssignConversation(userID1, userID2, conversationID uint) (int64, error)
+	LatestReadID(conversationID, userID uint) (uint, error)
 }
 
 type messageRepository struct {
@@ -26,59 +36,167 @@ func NewMessageRepository() MessageRepository {
 	return &messageRepository{db: database.DB}
 }
 
-func (r *messageRepository) Create(message *models.Message) error {
-	return r.db.Create(message).Error
+// Create stores a message, claims the sender's uploaded
+// attachments for it and moves its conversation to the top of the inbox.
+// It fails with gorm.ErrRecordNotFound if an attachment is not an unclaimed
+// upload of the sender.
+func (r *messageRepository) Create(message *models.Message, attachmentIDs []uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Sender", "Receiver", "Attachments").Create(message).Error; err != nil {
+			return err
+		}
+		if len(attachmentIDs) > 0 {
+			result := tx.Model(&models.MessageAttachment{}).
+				Where("id IN ? AND uploaded_by = ? AND message_id = 0", attachmentIDs, message.SenderID).
+				Update("message_id", message.ID)
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected != int64(len(attachmentIDs)) {
+				return gorm.ErrRecordNotFound
+			}
+			if err := tx.Where("message_id = ?", message.ID).Order("id").Find(&message.Attachments).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Model(&models.Conversation{}).Where("id = ?", message.ConversationID).
+			Update("last_message_at", message.CreatedAt).Error
+	})
 }
 
 func (r *messageRepository) FindByID(id uint) (*models.Message, error) {
 	var message models.Message
-	err := r.db.Preload("Sender").Preload("Receiver").First(&message, id).Error
+	err := r.db.Preload("Sender").Preload("Receiver").Preload("Attachments").First(&message, id).Error
 	return &message, err
 }
 
-func (r *messageRepository) FindByReceiverID(receiverID uint, page, limit int) ([]models.Message, int64, error) {
+func (r *messageRepository) FindConversation(userID1, userID2 uint, page, limit int) ([]models.Message, int64, error) {
 	var messages []models.Message
 	var total int64
 	offset := (page - 1) * limit
-	err := r.db.Where("receiver_id = ?", receiverID).Count(&total).
+	err := r.db.Where("(sender_id = ? AND receiver_id = ?) OR (sender_id = ? AND receiver_id = ?)", userID1, userID2, userID2, userID1).
+		Count(&total).
 		Preload("Sender").
 		Preload("Receiver").
-		Order("created_at DESC").
+		Preload("Attachments").
+		Order("created_at ASC").
 		Limit(limit).
 		Offset(offset).
 		Find(&messages).Error
 	return messages, total, err
 }
 
-func (r *messageRepository) FindConversation(userID1, userID2 uint, page, limit int) ([]models.Message, int64, error) {
+// FindByConversationID returns a page of a conversation's messages, newest
+// first
+func (r *messageRepository) FindByConversationID(conversationID uint, page, limit int) ([]models.Message, int64, error) {
 	var messages []models.Message
 	var total int64
 	offset := (page - 1) * limit
-	err := r.db.Where("(sender_id = ? AND receiver_id = ?) OR (sender_id = ? AND receiver_id = ?)", userID1, userID2, userID2, userID1).
+	err := r.db.Where("conversation_id = ?", conversationID).
+		Model(&models.Message{}).
 		Count(&total).
 		Preload("Sender").
-		Preload("Receiver").
-		Order("created_at ASC").
+		Preload("Attachments").
+		Order("id DESC").
 		Limit(limit).
 		Offset(offset).
 		Find(&messages).Error
 	return messages, total, err
 }
 
-func (r *messageRepository) Update(message *models.Message) error {
-	return r.db.Save(message).Error
+func (r *messageRepository) FindLatestByConversationIDs(conversationIDs []uint) ([]models.Message, error) {
+	var messages []models.Message
+	err := r.db.Where("id IN (?)", r.db.Model(&models.Message{}).
+		Select("MAX(id)").
+		Where("conversation_id IN ?", conversationIDs).
+		Group("conversation_id")).
+		Preload("Sender").
+		Preload("Attachments").
+		Find(&messages).Error
+	return messages, err
+}
+
+func (r *messageRepository) FindLatestID(conversationID uint) (uint, error) {
+	var id uint
+	err := r.db.Model(&models.Message{}).Select("COALESCE(MAX(id), 0)").
+		Where("conversation_id = ?", conversationID).Scan(&id).Error
+	return id, err
 }
 
-func (r *messageRepository) Delete(id uint) error {
-	return r.db.Delete(&models.Message{}, id).Error
+func (r *messageRepository) UpdateContent(id uint, content string, editedAt int64) error {
+	return r.db.Model(&models.Message{}).Where("id = ?", id).
+		Updates(map[string]interface{}{"content": content, "edited_at": editedAt, "updated_at": editedAt}).Error
+}
+
+// SoftDelete blanks a message and removes its attachments, returning them so
+// their files can be deleted
+func (r *messageRepository) SoftDelete(id, deletedBy uint, at int64) ([]models.MessageAttachment, error) {
+	var attachments []models.MessageAttachment
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Message{}).Where("id = ? AND deleted_at = 0", id).
+			Updates(map[string]interface{}{"content": "", "deleted_at": at, "deleted_by": deletedBy, "updated_at": at})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		if err := tx.Where("message_id = ?", id).Find(&attachments).Error; err != nil {
+			return err
+		}
+		return tx.Where("message_id = ?", id).Delete(&models.MessageAttachment{}).Error
+	})
+	return attachments, err
+}
+
+// MarkDirectRead sets the read time on the direct messages received up to
+// and including upToID
+func (r *messageRepository) MarkDirectRead(conversationID, receiverID, upToID uint, at int64) error {
+	return r.db.Model(&models.Message{}).
+		Where("conversation_id = ? AND receiver_id = ? AND id <= ? AND is_read = ?", conversationID, receiverID, upToID, false).
+		Updates(map[string]interface{}{"is_read": true, "read_at": at}).Error
+}
+
+func (r *messageRepository) CreateAttachment(attachment *models.MessageAttachment) error {
+	return r.db.Create(attachment).Error
+}
+
+func (r *messageRepository) FindAttachmentByID(id uint) (*models.MessageAttachment, error) {
+	var attachment models.MessageAttachment
+	err := r.db.First(&attachment, id).Error
+	return &attachment, err
+}
+
+// FindUnclaimedAttachments returns uploads never sent with a message
+func (r *messageRepository) FindUnclaimedAttachments(before int64) ([]models.MessageAttachment, error) {
+	var attachments []models.MessageAttachment
+	err := r.db.Where("message_id = 0 AND created_at < ?", before).Order("id").Find(&attachments).Error
+	return attachments, err
+}
+
+func (r *messageRepository) DeleteAttachment(id uint) error {
+	return r.db.Delete(&models.MessageAttachment{}, id).Error
+}
+
+// FindUnthreaded returns direct messages stored before conversations existed
+func (r *messageRepository) FindUnthreaded(limit int) ([]models.Message, error) {
+	var messages []models.Message
+	err := r.db.Where("conversation_id = 0").Order("id").Limit(limit).Find(&messages).Error
+	return messages, err
 }
 
-func (r *messageRepository) MarkAsRead(id uint) error {
-	return r.db.Model(&models.Message{}).Where("id = ?", id).Updates(map[string]interface{}{"is_read": true, "read_at": 0}).Error
+// AssignConversation moves the unthreaded messages between two users into
+// their direct conversation
+func (r *messageRepository) AssignConversation(userID1, userID2, conversationID uint) (int64, error) {
+	result := r.db.Model(&models.Message{}).
+		Where("conversation_id = 0 AND ((sender_id = ? AND receiver_id = ?) OR (sender_id = ? AND receiver_id = ?))", userID1, userID2, userID2, userID1).
+		Update("conversation_id", conversationID)
+	return result.RowsAffected, result.Error
 }
 
-func (r *messageRepository) CountUnread(userID uint) (int64, error) {
-	var count int64
-	err := r.db.Model(&models.Message{}).Where("receiver_id = ? AND is_read = ?", userID, false).Count(&count).Error
-	return count, err
+// LatestReadID returns the newest message of the conversation the user
+// received and has read
+func (r *messageRepository) LatestReadID(conversationID, userID uint) (uint, error) {
+	var id uint
+	err := r.db.Model(&models.Message{}).Select("COALESCE(MAX(id), 0)").
+		Where("conversation_id = ? AND receiver_id = ? AND is_read = ?", conversationID, userID, true).
+		Scan(&id).Error
+	return id, err
 }
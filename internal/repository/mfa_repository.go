@@ -0,0 +1,91 @@
+package repository
+
+import (
+	"school-management-system/internal/models"
+	"school-management-system/pkg/database"
+
+	"gorm.io/gorm"
+)
+
+type MFARepository interface {
+	FindByUserID(userID uint) (*models.UserMFA, error)
+	Save(mfa *models.UserMFA) error
+	Delete(userID uint) error
+	MarkStepUsed(userID uint, step int64) error
+	ReplaceRecoveryCodes(userID uint, codes []models.MFARecoveryCode) error
+	UseRecoveryCode(userID uint, hash string, now int64) error
+	CountUnusedRecoveryCodes(userID uint) (int64, error)
+}
+
+type mfaRepository struct {
+	db *gorm.DB
+}
+
+func NewMFARepository() MFARepository {
+	return &mfaRepository{db: database.DB}
+}
+
+func (r *mfaRepository) FindByUserID(userID uint) (*models.UserMFA, error) {
+	var mfa models.UserMFA
+	err := r.db.Where("user_id = ?", userID).First(&mfa).Error
+	return &mfa, err
+}
+
+func (r *mfaRepository) Save(mfa *models.UserMFA) error {
+	return r.db.Save(mfa).Error
+}
+
+// Delete removes the enrollment and its recovery codes
+func (r *mfaRepository) Delete(userID uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&models.MFARecoveryCode{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", userID).Delete(&models.UserMFA{}).Error
+	})
+}
+
+// MarkStepUsed records an accepted TOTP step. It returns
+// gorm.ErrRecordNotFound if that step (or a later one) was already used.
+func (r *mfaRepository) MarkStepUsed(userID uint, step int64) error {
+	res := r.db.Model(&models.UserMFA{}).
+		Where("user_id = ? AND last_used_step < ?", userID, step).
+		Update("last_used_step", step)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *mfaRepository) ReplaceRecoveryCodes(userID uint, codes []models.MFARecoveryCode) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&models.MFARecoveryCode{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&codes).Error
+	})
+}
+
+// UseRecoveryCode marks an unused code as used, returning
+// gorm.ErrRecordNotFound if there is no such unused code.
+func (r *mfaRepository) UseRecoveryCode(userID uint, hash string, now int64) error {
+	res := r.db.Model(&models.MFARecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at = 0", userID, hash).
+		Update("used_at", now)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *mfaRepository) CountUnusedRecoveryCodes(userID uint) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.MFARecoveryCode{}).Where("user_id = ? AND used_at = 0", userID).Count(&count).Error
+	return count, err
+}
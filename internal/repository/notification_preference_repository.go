@@ -0,0 +1,142 @@
+package repository
+
+import (
+	"school-management-system/internal/models"
+	"school-management-system/pkg/database"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type NotificationPreferenceRepository interface {
+	FindSettings(userID uint) (*models.NotificationSettings, error)
+	SaveSettings(settings *models.NotificationSettings) error
+	FindPreferences(userID uint) ([]models.NotificationPreference, error)
+	FindEventPreferences(userIDs []uint, event string) ([]models.NotificationPreference, error)
+	SetPreference(preference *models.NotificationPreference) error
+}
+
+type notificationPreferenceRepository struct {
+	db *gorm.DB
+}
+
+func NewNotificationPreferenceRepository() NotificationPreferenceRepository {
+	return &notificationPreferenceRepository{db: database.DB}
+}
+
+// FindSettings returns the user's settings, or empty settings if they have
+// never saved any
+func (r *notificationPreferenceRepository) FindSettings(userID uint) (*models.NotificationSettings, error) {
+	var settings models.NotificationSettings
+	err := r.db.Where("user_id = ?", userID).Limit(1).Find(&settings).Error
+	settings.UserID = userID
+	return &settings, err
+}
+
+func (r *notificationPreferenceRepository) SaveSettings(settings *models.NotificationSettings) error {
+	return r.db.Save(settings).Error
+}
+
+func (r *notificationPreferenceRepository) FindPreferences(userID uint) ([]models.NotificationPreference, error) {
+	var preferences []models.NotificationPreference
+	err := r.db.Where("user_id = ?", userID).Order("event, channel").Find(&preferences).Error
+	return preferences, err
+}
+
+func (r *notificationPreferenceRepository) FindEventPreferences(userIDs []uint, event string) ([]models.NotificationPreference, error) {
+	var preferences []models.NotificationPreference
+	err := r.db.Where("user_id IN ? AND event = ?", userIDs, event).Find(&preferences).Error
+	return preferences, err
+}
+
+func (r *notificationPreferenceRepository) SetPreference(preference *models.NotificationPreference) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "event"}, {Name: "channel"}},
+		DoUpdates: clause.AssignmentColumns([]string{"enabled", "updated_at"}),
+	}).Create(preference).Error
+}
+
+type NotificationDeliveryRepository interface {
+	Create(delivery *models.NotificationDelivery) error
+	FindAll(status string, page, limit int) ([]models.NotificationDelivery, int64, error)
+	ClaimDue(now, lockedUntil int64, limit int) ([]models.NotificationDelivery, error)
+	Finish(delivery *models.NotificationDelivery) error
+}
+
+type notificationDeliveryRepository struct {
+	db *gorm.DB
+}
+
+func NewNotificationDeliveryRepository() NotificationDeliveryRepository {
+	return &notificationDeliveryRepository{db: database.DB}
+}
+
+func (r *notificationDeliveryRepository) Create(delivery *models.NotificationDelivery) error {
+	return r.db.Create(delivery).Error
+}
+
+func (r *notificationDeliveryRepository) FindAll(status string, page, limit int) ([]models.NotificationDelivery, int64, error) {
+	var deliveries []models.NotificationDelivery
+	var total int64
+	offset := (page - 1) * limit
+	query := r.db.Model(&models.NotificationDelivery{})
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	err := query.Count(&total).
+		Order("created_at DESC, id DESC").
+		Limit(limit).
+		Offset(offset).
+		Find(&deliveries).Error
+	return deliveries, total, err
+}
+
+// ClaimDue locks up to limit pending deliveries that are due and not
+// locked by another worker. Each is claimed with a conditional update, so
+// two workers never send the same delivery.
+func (r *notificationDeliveryRepository) ClaimDue(now, lockedUntil int64, limit int) ([]models.NotificationDelivery, error) {
+	var ids []uint
+	err := r.db.Model(&models.NotificationDelivery{}).
+		Where("status = ? AND not_before <= ? AND locked_until < ?", models.DeliveryStatusPending, now, now).
+		Order("not_before, id").
+		Limit(limit).
+		Pluck("id", &ids).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var claimed []uint
+	for _, id := range ids {
+		result := r.db.Model(&models.NotificationDelivery{}).
+			Where("id = ? AND status = ? AND locked_until < ?", id, models.DeliveryStatusPending, now).
+			Update("locked_until", lockedUntil)
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		if result.RowsAffected == 1 {
+			claimed = append(claimed, id)
+		}
+	}
+	if len(claimed) == 0 {
+		return nil, nil
+	}
+
+	var deliveries []models.NotificationDelivery
+	err = r.db.Where("id IN ?", claimed).Order("not_before, id").Find(&deliveries).Error
+	return deliveries, err
+}
+
+// Finish saves a delivery's status, attempt count, next attempt time and
+// last error after a send, and clears the worker's claim on it
+func (r *notificationDeliveryRepository) Finish(delivery *models.NotificationDelivery) error {
+	return r.db.Model(&models.NotificationDelivery{}).Where("id = ?", delivery.ID).
+		Updates(map[string]interface{}{
+			"status":       delivery.Status,
+			"attempts":     delivery.Attempts,
+			"not_before":   delivery.NotBefore,
+			"last_error":   delivery.LastError,
+			"sent_at":      delivery.SentAt,
+			"locked_until": 0,
+			"updated_at":   delivery.UpdatedAt,
+		}).Error
+}
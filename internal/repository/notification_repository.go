@@ -14,7 +14,7 @@ type NotificationRepository interface {
 	FindUnread(userID uint) ([]models.Notification, error)
 	Update(notification *models.Notification) error
 	Delete(id uint) error
-	MarkAsRead(id uint) error
+	MarkAsRead(id, userID uint) error
 	MarkAllAsRead(userID uint) error
 }
 
@@ -66,8 +66,13 @@ func (r *notificationRepository) Delete(id uint) error {
 	return r.db.Delete(&models.Notification{}, id).Error
 }
 
-func (r *notificationRepository) MarkAsRead(id uint) error {
-	return r.db.Model(&models.Notification{}).Where("id = ?", id).Update("is_read", true).Error
+// MarkAsRead marks a notification read if it belongs to the user
+func (r *notificationRepository) MarkAsRead(id, userID uint) error {
+	result := r.db.Model(&models.Notification{}).Where("id = ? AND user_id = ?", id, userID).Update("is_read", true)
+	if result.Error == nil && result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return result.Error
 }
 
 func (r *notificationRepository) MarkAllAsRead(userID uint) error {
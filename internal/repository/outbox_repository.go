@@ -0,0 +1,166 @@
+package repository
+
+import (
+	"school-management-system/internal/models"
+	"school-management-system/pkg/database"
+
+	"gorm.io/gorm"
+)
+
+type OutboxRepository interface {
+	Create(email *models.OutboxEmail) error
+	FindByID(id uint) (*models.OutboxEmail, error)
+	FindAll(status string, page, limit int) ([]models.OutboxEmail, int64, error)
+	CountByStatus() (map[string]int64, error)
+	ClaimDue(now, lockedUntil int64, instance string, limit int) ([]models.OutboxEmail, error)
+	UpdateRecipient(recipient *models.OutboxRecipient) error
+	Finish(email *models.OutboxEmail) error
+	Requeue(id uint, now int64) error
+	DeleteSentBefore(cutoff int64) (int64, error)
+}
+
+type outboxRepository struct {
+	db *gorm.DB
+}
+
+func NewOutboxRepository() OutboxRepository {
+	return &outboxRepository{db: database.DB}
+}
+
+func (r *outboxRepository) Create(email *models.OutboxEmail) error {
+	return r.db.Create(email).Error
+}
+
+func (r *outboxRepository) FindByID(id uint) (*models.OutboxEmail, error) {
+	var email models.OutboxEmail
+	err := r.db.Preload("Recipients").First(&email, id).Error
+	return &email, err
+}
+
+func (r *outboxRepository) FindAll(status string, page, limit int) ([]models.OutboxEmail, int64, error) {
+	var emails []models.OutboxEmail
+	var total int64
+	offset := (page - 1) * limit
+	query := r.db.Model(&models.OutboxEmail{})
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	err := query.Count(&total).
+		Preload("Recipients").
+		Order("created_at DESC, id DESC").
+		Limit(limit).
+		Offset(offset).
+		Find(&emails).Error
+	return emails, total, err
+}
+
+func (r *outboxRepository) CountByStatus() (map[string]int64, error) {
+	var rows []struct {
+		Status string
+		Count  int64
+	}
+	err := r.db.Model(&models.OutboxEmail{}).Select("status, COUNT(*) AS count").Group("status").Scan(&rows).Error
+	counts := map[string]int64{
+		models.OutboxStatusPending: 0,
+		models.OutboxStatusSent:    0,
+		models.OutboxStatusFailed:  0,
+	}
+	for _, row := range rows {
+		counts[row.Status] = row.Count
+	}
+	return counts, err
+}
+
+// ClaimDue locks up to limit pending emails that are due for delivery and
+// not locked by another worker, and returns them with their recipients.
+// Each email is claimed with a conditional update, so two workers never
+// get the same email.
+func (r *outboxRepository) ClaimDue(now, lockedUntil int64, instance string, limit int) ([]models.OutboxEmail, error) {
+	var ids []uint
+	err := r.db.Model(&models.OutboxEmail{}).
+		Where("status = ? AND next_attempt_at <= ? AND locked_until < ?", models.OutboxStatusPending, now, now).
+		Order("next_attempt_at, id").
+		Limit(limit).
+		Pluck("id", &ids).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var claimed []uint
+	for _, id := range ids {
+		result := r.db.Model(&models.OutboxEmail{}).
+			Where("id = ? AND status = ? AND locked_until < ?", id, models.OutboxStatusPending, now).
+			Updates(map[string]interface{}{"locked_until": lockedUntil, "locked_by": instance})
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		if result.RowsAffected == 1 {
+			claimed = append(claimed, id)
+		}
+	}
+	if len(claimed) == 0 {
+		return nil, nil
+	}
+
+	var emails []models.OutboxEmail
+	err = r.db.Preload("Recipients").Where("id IN ?", claimed).Order("next_attempt_at, id").Find(&emails).Error
+	return emails, err
+}
+
+func (r *outboxRepository) UpdateRecipient(recipient *models.OutboxRecipient) error {
+	return r.db.Save(recipient).Error
+}
+
+// Finish records the outcome of a delivery attempt and releases the lock
+func (r *outboxRepository) Finish(email *models.OutboxEmail) error {
+	return r.db.Model(&models.OutboxEmail{}).Where("id = ?", email.ID).
+		Updates(map[string]interface{}{
+			"status":          email.Status,
+			"attempts":        email.Attempts,
+			"next_attempt_at": email.NextAttemptAt,
+			"last_error":      email.LastError,
+			"sent_at":         email.SentAt,
+			"locked_until":    0,
+			"locked_by":       "",
+			"updated_at":      email.UpdatedAt,
+		}).Error
+}
+
+// Requeue gives a failed email's dead recipients a fresh set of attempts
+func (r *outboxRepository) Requeue(id uint, now int64) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.OutboxEmail{}).
+			Where("id = ? AND status = ?", id, models.OutboxStatusFailed).
+			Updates(map[string]interface{}{
+				"status":          models.OutboxStatusPending,
+				"attempts":        0,
+				"next_attempt_at": now,
+				"updated_at":      now,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return tx.Model(&models.OutboxRecipient{}).
+			Where("email_id = ? AND status = ?", id, models.RecipientStatusDead).
+			Updates(map[string]interface{}{"status": models.RecipientStatusPending, "attempts": 0}).Error
+	})
+}
+
+// DeleteSentBefore removes delivered emails created before cutoff
+func (r *outboxRepository) DeleteSentBefore(cutoff int64) (int64, error) {
+	var deleted int64
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		old := tx.Model(&models.OutboxEmail{}).Select("id").
+			Where("status = ? AND created_at < ?", models.OutboxStatusSent, cutoff)
+		if err := tx.Where("email_id IN (?)", old).Delete(&models.OutboxRecipient{}).Error; err != nil {
+			return err
+		}
+		result := tx.Where("status = ? AND created_at < ?", models.OutboxStatusSent, cutoff).Delete(&models.OutboxEmail{})
+		deleted = result.RowsAffected
+		return result.Error
+	})
+	return deleted, err
+}
@@ -1,6 +1,7 @@
 package repository
 
 import (
+	"context"
 	"school-management-system/internal/models"
 	"school-management-system/pkg/database"
 
@@ -8,15 +9,19 @@ import (
 )
 
 type PaymentRepository interface {
-	Create(payment *models.Payment) error
+	Create(ctx context.Context, payment *models.Payment) error
 	FindByID(id uint) (*models.Payment, error)
 	FindByStudentID(studentID uint, page, limit int) ([]models.Payment, int64, error)
 	FindByStatus(status string, page, limit int) ([]models.Payment, int64, error)
 	FindAll(page, limit int) ([]models.Payment, int64, error)
-	Update(payment *models.Payment) error
-	Delete(id uint) error
+	Update(ctx context.Context, payment *models.Payment) error
+	Delete(ctx context.Context, id uint) error
 	SumByStudent(studentID uint) (float64, error)
+	SumOutstandingByStudent(studentID uint) (float64, error)
 	SumByStatus(status string) (float64, error)
+	MarkOverdue(now int64) (int64, error)
+	FindDueForReminder(dueBefore, remindedBefore int64) ([]models.Payment, error)
+	MarkReminded(id uint, at int64) error
 }
 
 type paymentRepository struct {
@@ -27,8 +32,8 @@ func NewPaymentRepository() PaymentRepository {
 	return &paymentRepository{db: database.DB}
 }
 
-func (r *paymentRepository) Create(payment *models.Payment) error {
-	return r.db.Create(payment).Error
+func (r *paymentRepository) Create(ctx context.Context, payment *models.Payment) error {
+	return r.db.WithContext(ctx).Create(payment).Error
 }
 
 func (r *paymentRepository) FindByID(id uint) (*models.Payment, error) {
@@ -76,12 +81,12 @@ func (r *paymentRepository) FindAll(page, limit int) ([]models.Payment, int64, e
 	return payments, total, err
 }
 
-func (r *paymentRepository) Update(payment *models.Payment) error {
-	return r.db.Save(payment).Error
+func (r *paymentRepository) Update(ctx context.Context, payment *models.Payment) error {
+	return r.db.WithContext(ctx).Save(payment).Error
 }
 
-func (r *paymentRepository) Delete(id uint) error {
-	return r.db.Delete(&models.Payment{}, id).Error
+func (r *paymentRepository) Delete(ctx context.Context, id uint) error {
+	return r.db.WithContext(ctx).Delete(&models.Payment{}, id).Error
 }
 
 func (r *paymentRepository) SumByStudent(studentID uint) (float64, error) {
@@ -94,6 +99,17 @@ func (r *paymentRepository) SumByStudent(studentID uint) (float64, error) {
 	return total, err
 }
 
+// SumOutstandingByStudent totals the student's pending and overdue payments.
+func (r *paymentRepository) SumOutstandingByStudent(studentID uint) (float64, error) {
+	var total float64
+	err := r.db.Model(&models.Payment{}).
+		Where("student_id = ? AND status IN ?", studentID, []string{"pending", "overdue"}).
+		Select("COALESCE(SUM(amount), 0)").
+		Row().
+		Scan(&total)
+	return total, err
+}
+
 func (r *paymentRepository) SumByStatus(status string) (float64, error) {
 	var total float64
 	err := r.db.Model(&models.Payment{}).
@@ -103,3 +119,50 @@ func (r *paymentRepository) SumByStatus(status string) (float64, error) {
 		Scan(&total)
 	return total, err
 }
+
+// MarkOverdue moves pending payments whose due date has passed to overdue.
+// Their reminder time is cleared so the overdue notice goes out promptly.
+func (r *paymentRepository) MarkOverdue(now int64) (int64, error) {
+	result := r.db.Model(&models.Payment{}).
+		Where("status = ? AND due_date > 0 AND due_date < ?", models.PaymentStatusPending, now).
+		Updates(map[string]interface{}{"status": models.PaymentStatusOverdue, "reminder_sent_at": 0, "updated_at": now})
+	return result.RowsAffected, result.Error
+}
+
+// FindDueForReminder returns pending payments due by dueBefore that have
+// not been reminded yet, and overdue payments last reminded before
+// remindedBefore.
+func (r *paymentRepository) FindDueForReminder(dueBefore, remindedBefore int64) ([]models.Payment, error) {
+	var payments []models.Payment
+	err := r.db.
+		Where("(status = ? AND due_date > 0 AND due_date <= ? AND reminder_sent_at = 0) OR (status = ? AND reminder_sent_at < ?)",
+			models.PaymentStatusPending, dueBefore, models.PaymentStatusOverdue, remindedBefore).
+		Order("due_date").
+		Find(&payments).Error
+	if err != nil || len(payments) == 0 {
+		return payments, err
+	}
+
+	// Preloading Student resolves against students.student_id, the
+	// student number, so load the students by primary key instead
+	ids := make([]uint, 0, len(payments))
+	for _, p := range payments {
+		ids = append(ids, p.StudentID)
+	}
+	var students []models.Student
+	if err := r.db.Preload("User").Where("id IN ?", ids).Find(&students).Error; err != nil {
+		return nil, err
+	}
+	byID := make(map[uint]models.Student, len(students))
+	for _, s := range students {
+		byID[s.ID] = s
+	}
+	for i := range payments {
+		payments[i].Student = byID[payments[i].StudentID]
+	}
+	return payments, nil
+}
+
+func (r *paymentRepository) MarkReminded(id uint, at int64) error {
+	return r.db.Model(&models.Payment{}).Where("id = ?", id).UpdateColumn("reminder_sent_at", at).Error
+}
@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"school-management-system/internal/models"
+	"school-management-system/pkg/database"
+
+	"gorm.io/gorm"
+)
+
+type RealtimeEventRepository interface {
+	Create(event *models.RealtimeEvent) error
+	FindForUser(userID uint, role string, afterID uint64, limit int) ([]models.RealtimeEvent, error)
+	FindAfter(afterID uint64, limit int) ([]models.RealtimeEvent, error)
+	FindRecentThrough(throughID uint64, createdSince int64) ([]models.RealtimeEvent, error)
+	FirstID() (uint64, error)
+	LatestID() (uint64, error)
+	DeleteBefore(createdBefore int64) (int64, error)
+}
+
+type realtimeEventRepository struct {
+	db *gorm.DB
+}
+
+func NewRealtimeEventRepository() RealtimeEventRepository {
+	return &realtimeEventRepository{db: database.DB}
+}
+
+func (r *realtimeEventRepository) Create(event *models.RealtimeEvent) error {
+	return r.db.Create(event).Error
+}
+
+// FindForUser returns the events after afterID that reach the user: their
+// own and broadcasts to everyone or to their role
+func (r *realtimeEventRepository) FindForUser(userID uint, role string, afterID uint64, limit int) ([]models.RealtimeEvent, error) {
+	var events []models.RealtimeEvent
+	err := r.db.Where("id > ?", afterID).
+		Where("user_id = ? OR (user_id = 0 AND (role = '' OR role = ?))", userID, role).
+		Order("id").
+		Limit(limit).
+		Find(&events).Error
+	return events, err
+}
+
+// FindAfter returns every event after afterID, oldest first
+func (r *realtimeEventRepository) FindAfter(afterID uint64, limit int) ([]models.RealtimeEvent, error) {
+	var events []models.RealtimeEvent
+	err := r.db.Where("id > ?", afterID).Order("id").Limit(limit).Find(&events).Error
+	return events, err
+}
+
+// FindRecentThrough returns the events up to throughID created at or after
+// createdSince, oldest first
+func (r *realtimeEventRepository) FindRecentThrough(throughID uint64, createdSince int64) ([]models.RealtimeEvent, error) {
+	var events []models.RealtimeEvent
+	err := r.db.Where("id <= ? AND created_at >= ?", throughID, createdSince).Order("id").Find(&events).Error
+	return events, err
+}
+
+// FirstID returns the id of the oldest event kept, or 0 when there are none
+func (r *realtimeEventRepository) FirstID() (uint64, error) {
+	var id uint64
+	err := r.db.Model(&models.RealtimeEvent{}).Select("COALESCE(MIN(id), 0)").Scan(&id).Error
+	return id, err
+}
+
+// LatestID returns the id of the newest event, or 0 when there are none
+func (r *realtimeEventRepository) LatestID() (uint64, error) {
+	var id uint64
+	err := r.db.Model(&models.RealtimeEvent{}).Select("COALESCE(MAX(id), 0)").Scan(&id).Error
+	return id, err
+}
+
+func (r *realtimeEventRepository) DeleteBefore(createdBefore int64) (int64, error) {
+	result := r.db.Where("created_at < ?", createdBefore).Delete(&models.RealtimeEvent{})
+	return result.RowsAffected, result.Error
+}
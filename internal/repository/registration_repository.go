@@ -0,0 +1,137 @@
+package repository
+
+import (
+	"school-management-system/internal/models"
+	"school-management-system/pkg/database"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// CatalogFilter narrows the sections offered in a term
+type CatalogFilter struct {
+	TermID     uint
+	Search     string // matched against course code and name
+	Department string
+}
+
+type RegistrationRepository interface {
+	FindCatalog(filter CatalogFilter, page, limit int) ([]models.CourseSection, int64, error)
+	CountSectionSeats(sectionIDs []uint) (map[uint]int, error)
+	FindCart(studentID uint) ([]models.RegistrationCartItem, error)
+	AddToCart(item *models.RegistrationCartItem) (bool, error)
+	RemoveFromCart(studentID, sectionID uint) (bool, error)
+	ClearCart(studentID uint, sectionIDs []uint) error
+	FindPriority(termID, studentID uint) (*models.RegistrationPriority, error)
+	SavePriority(priority *models.RegistrationPriority) error
+	DeletePriority(termID, studentID uint) (bool, error)
+}
+
+type registrationRepository struct {
+	db *gorm.DB
+}
+
+func NewRegistrationRepository() RegistrationRepository {
+	return &registrationRepository{db: database.DB}
+}
+
+// FindCatalog returns the open sections of a term, by course code
+func (r *registrationRepository) FindCatalog(filter CatalogFilter, page, limit int) ([]models.CourseSection, int64, error) {
+	var sections []models.CourseSection
+	var total int64
+
+	q := r.db.Model(&models.CourseSection{}).
+		Joins("JOIN courses ON courses.id = course_sections.course_id").
+		Where("course_sections.term_id = ? AND course_sections.status IN ?", filter.TermID, []string{"", "open"})
+	if filter.Search != "" {
+		like := "%" + filter.Search + "%"
+		q = q.Where("courses.course_code LIKE ? OR courses.name LIKE ?", like, like)
+	}
+	if filter.Department != "" {
+		q = q.Where("courses.department = ?", filter.Department)
+	}
+
+	offset := (page - 1) * limit
+	err := q.Count(&total).
+		Preload("Course").
+		Preload("Teacher").
+		Order("courses.course_code, course_sections.section_code").
+		Limit(limit).
+		Offset(offset).
+		Find(&sections).Error
+
+	return sections, total, err
+}
+
+// CountSectionSeats returns the number of seats taken in each section
+func (r *registrationRepository) CountSectionSeats(sectionIDs []uint) (map[uint]int, error) {
+	seats := make(map[uint]int, len(sectionIDs))
+	if len(sectionIDs) == 0 {
+		return seats, nil
+	}
+	var rows []struct {
+		SectionID uint
+		Taken     int
+	}
+	err := r.db.Model(&models.Enrollment{}).
+		Select("section_id, COUNT(*) AS taken").
+		Where("section_id IN ? AND status IN ?", sectionIDs, enrollmentSeatStatuses).
+		Group("section_id").
+		Scan(&rows).Error
+	for _, row := range rows {
+		seats[row.SectionID] = row.Taken
+	}
+	return seats, err
+}
+
+// FindCart returns the sections in a student's cart in the order they were
+// added
+func (r *registrationRepository) FindCart(studentID uint) ([]models.RegistrationCartItem, error) {
+	var items []models.RegistrationCartItem
+	err := r.db.Where("student_id = ?", studentID).
+		Preload("Section.Course").
+		Preload("Section.Term").
+		Order("id").
+		Find(&items).Error
+	return items, err
+}
+
+// AddToCart puts a section in a student's cart, reporting false if it is
+// already there
+func (r *registrationRepository) AddToCart(item *models.RegistrationCartItem) (bool, error) {
+	result := r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(item)
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r *registrationRepository) RemoveFromCart(studentID, sectionID uint) (bool, error) {
+	result := r.db.Where("student_id = ? AND section_id = ?", studentID, sectionID).Delete(&models.RegistrationCartItem{})
+	return result.RowsAffected > 0, result.Error
+}
+
+// ClearCart removes the given sections from a student's cart
+func (r *registrationRepository) ClearCart(studentID uint, sectionIDs []uint) error {
+	if len(sectionIDs) == 0 {
+		return nil
+	}
+	return r.db.Where("student_id = ? AND section_id IN ?", studentID, sectionIDs).Delete(&models.RegistrationCartItem{}).Error
+}
+
+func (r *registrationRepository) FindPriority(termID, studentID uint) (*models.RegistrationPriority, error) {
+	var priority models.RegistrationPriority
+	err := r.db.Where("term_id = ? AND student_id = ?", termID, studentID).First(&priority).Error
+	return &priority, err
+}
+
+// SavePriority sets when a student may register for a term, replacing any
+// time already set
+func (r *registrationRepository) SavePriority(priority *models.RegistrationPriority) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "term_id"}, {Name: "student_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"opens_at", "updated_at"}),
+	}).Create(priority).Error
+}
+
+func (r *registrationRepository) DeletePriority(termID, studentID uint) (bool, error) {
+	result := r.db.Where("term_id = ? AND student_id = ?", termID, studentID).Delete(&models.RegistrationPriority{})
+	return result.RowsAffected > 0, result.Error
+}
@@ -0,0 +1,65 @@
+package repository
+
+import (
+	"school-management-system/internal/models"
+	"school-management-system/pkg/database"
+
+	"gorm.io/gorm"
+)
+
+type ResourceRepository interface {
+	Create(resource *models.Resource) error
+	FindByID(id uint) (*models.Resource, error)
+	FindByName(name string) (*models.Resource, error)
+	FindAll(resourceType string, roomID uint) ([]models.Resource, error)
+	Update(resource *models.Resource) error
+	Delete(id uint) error
+}
+
+type resourceRepository struct {
+	db *gorm.DB
+}
+
+func NewResourceRepository() ResourceRepository {
+	return &resourceRepository{db: database.DB}
+}
+
+func (r *resourceRepository) Create(resource *models.Resource) error {
+	return r.db.Create(resource).Error
+}
+
+func (r *resourceRepository) FindByID(id uint) (*models.Resource, error) {
+	var resource models.Resource
+	err := r.db.Preload("Room").First(&resource, id).Error
+	return &resource, err
+}
+
+// FindByName looks a resource up by name, ignoring case
+func (r *resourceRepository) FindByName(name string) (*models.Resource, error) {
+	var resource models.Resource
+	err := r.db.Where("LOWER(name) = LOWER(?)", name).First(&resource).Error
+	return &resource, err
+}
+
+// FindAll returns resources, only those of a type or kept in a room when
+// they are given
+func (r *resourceRepository) FindAll(resourceType string, roomID uint) ([]models.Resource, error) {
+	var resources []models.Resource
+	q := r.db.Preload("Room").Order("name")
+	if resourceType != "" {
+		q = q.Where("LOWER(type) = LOWER(?)", resourceType)
+	}
+	if roomID != 0 {
+		q = q.Where("room_id = ?", roomID)
+	}
+	err := q.Find(&resources).Error
+	return resources, err
+}
+
+func (r *resourceRepository) Update(resource *models.Resource) error {
+	return r.db.Omit("Room").Save(resource).Error
+}
+
+func (r *resourceRepository) Delete(id uint) error {
+	return r.db.Delete(&models.Resource{}, id).Error
+}
@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"school-management-system/internal/models"
+	"school-management-system/pkg/database"
+
+	"gorm.io/gorm"
+)
+
+type RoomRepository interface {
+	Create(room *models.Room) error
+	FindByID(id uint) (*models.Room, error)
+	FindByName(name string) (*models.Room, error)
+	FindAll(activeOnly bool) ([]models.Room, error)
+	Update(room *models.Room) error
+	Delete(id uint) error
+}
+
+type roomRepository struct {
+	db *gorm.DB
+}
+
+func NewRoomRepository() RoomRepository {
+	return &roomRepository{db: database.DB}
+}
+
+func (r *roomRepository) Create(room *models.Room) error {
+	return r.db.Create(room).Error
+}
+
+func (r *roomRepository) FindByID(id uint) (*models.Room, error) {
+	var room models.Room
+	err := r.db.First(&room, id).Error
+	return &room, err
+}
+
+// FindByName looks a room up by name, ignoring case
+func (r *roomRepository) FindByName(name string) (*models.Room, error) {
+	var room models.Room
+	err := r.db.Where("LOWER(name) = LOWER(?)", name).First(&room).Error
+	return &room, err
+}
+
+func (r *roomRepository) FindAll(activeOnly bool) ([]models.Room, error) {
+	var rooms []models.Room
+	q := r.db.Order("name")
+	if activeOnly {
+		q = q.Where("is_active = ?", true)
+	}
+	err := q.Find(&rooms).Error
+	return rooms, err
+}
+
+func (r *roomRepository) Update(room *models.Room) error {
+	return r.db.Save(room).Error
+}
+
+func (r *roomRepository) Delete(id uint) error {
+	return r.db.Delete(&models.Room{}, id).Error
+}
@@ -0,0 +1,150 @@
+package repository
+
+import (
+	"school-management-system/internal/models"
+	"school-management-system/pkg/database"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ScheduledJobRepository interface {
+	Create(job *models.ScheduledJob) error
+	Define(name, description, schedule string, nextRunAt int64) error
+	FindByName(name string) (*models.ScheduledJob, error)
+	FindAll() ([]models.ScheduledJob, error)
+	SetPaused(name string, paused bool) error
+	Claim(name, instance string, now, staleBefore int64) (bool, error)
+	Finish(job *models.ScheduledJob) error
+
+	CreateRun(run *models.JobRun) error
+	UpdateRun(run *models.JobRun) error
+	FindRuns(jobName string, page, limit int) ([]models.JobRun, int64, error)
+	FailStaleRuns(startedBefore int64) error
+	DeleteRunsOlderThan(cutoff int64) (int64, error)
+
+	AcquireLease(name, holder string, now, expiresAt int64) (bool, error)
+	ReleaseLease(name, holder string) error
+}
+
+type scheduledJobRepository struct {
+	db *gorm.DB
+}
+
+func NewScheduledJobRepository() ScheduledJobRepository {
+	return &scheduledJobRepository{db: database.DB}
+}
+
+// Create inserts a job unless another instance registered it first
+func (r *scheduledJobRepository) Create(job *models.ScheduledJob) error {
+	return r.db.Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "name"}}, DoNothing: true}).Create(job).Error
+}
+
+// Define updates a job's definition without touching its run state
+func (r *scheduledJobRepository) Define(name, description, schedule string, nextRunAt int64) error {
+	return r.db.Model(&models.ScheduledJob{}).Where("name = ?", name).
+		Updates(map[string]interface{}{"description": description, "schedule": schedule, "next_run_at": nextRunAt}).Error
+}
+
+func (r *scheduledJobRepository) FindByName(name string) (*models.ScheduledJob, error) {
+	var job models.ScheduledJob
+	err := r.db.Where("name = ?", name).First(&job).Error
+	return &job, err
+}
+
+func (r *scheduledJobRepository) FindAll() ([]models.ScheduledJob, error) {
+	var jobs []models.ScheduledJob
+	err := r.db.Order("name").Find(&jobs).Error
+	return jobs, err
+}
+
+func (r *scheduledJobRepository) SetPaused(name string, paused bool) error {
+	result := r.db.Model(&models.ScheduledJob{}).Where("name = ?", name).Update("paused", paused)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// Claim marks a job as running on instance, unless another instance is
+// already running it. A claim older than staleBefore is assumed to belong
+// to an instance that died and is taken over.
+func (r *scheduledJobRepository) Claim(name, instance string, now, staleBefore int64) (bool, error) {
+	result := r.db.Model(&models.ScheduledJob{}).
+		Where("name = ? AND (running_since = 0 OR running_since < ?)", name, staleBefore).
+		Updates(map[string]interface{}{"running_since": now, "running_on": instance})
+	return result.RowsAffected == 1, result.Error
+}
+
+// Finish records the outcome of a run and releases the job's claim
+func (r *scheduledJobRepository) Finish(job *models.ScheduledJob) error {
+	return r.db.Model(&models.ScheduledJob{}).Where("name = ?", job.Name).
+		Updates(map[string]interface{}{
+			"running_since":    0,
+			"running_on":       "",
+			"next_run_at":      job.NextRunAt,
+			"last_run_at":      job.LastRunAt,
+			"last_status":      job.LastStatus,
+			"last_error":       job.LastError,
+			"last_duration_ms": job.LastDurationMs,
+			"updated_at":       job.UpdatedAt,
+		}).Error
+}
+
+func (r *scheduledJobRepository) CreateRun(run *models.JobRun) error {
+	return r.db.Create(run).Error
+}
+
+func (r *scheduledJobRepository) UpdateRun(run *models.JobRun) error {
+	return r.db.Save(run).Error
+}
+
+func (r *scheduledJobRepository) FindRuns(jobName string, page, limit int) ([]models.JobRun, int64, error) {
+	var runs []models.JobRun
+	var total int64
+	offset := (page - 1) * limit
+	query := r.db.Model(&models.JobRun{})
+	if jobName != "" {
+		query = query.Where("job_name = ?", jobName)
+	}
+	err := query.Count(&total).
+		Order("started_at DESC, id DESC").
+		Limit(limit).
+		Offset(offset).
+		Find(&runs).Error
+	return runs, total, err
+}
+
+// FailStaleRuns marks runs left running by an instance that died
+func (r *scheduledJobRepository) FailStaleRuns(startedBefore int64) error {
+	return r.db.Model(&models.JobRun{}).
+		Where("status = ? AND started_at < ?", models.JobRunRunning, startedBefore).
+		Updates(map[string]interface{}{"status": models.JobRunFailed, "error": "interrupted"}).Error
+}
+
+func (r *scheduledJobRepository) DeleteRunsOlderThan(cutoff int64) (int64, error) {
+	result := r.db.Where("started_at < ?", cutoff).Delete(&models.JobRun{})
+	return result.RowsAffected, result.Error
+}
+
+// AcquireLease takes or renews the named lease for holder until expiresAt.
+// It fails while another holder's lease is unexpired.
+func (r *scheduledJobRepository) AcquireLease(name, holder string, now, expiresAt int64) (bool, error) {
+	lease := models.SchedulerLease{Name: name, UpdatedAt: now}
+	if err := r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&lease).Error; err != nil {
+		return false, err
+	}
+	result := r.db.Model(&models.SchedulerLease{}).
+		Where("name = ? AND (holder = ? OR holder = '' OR expires_at < ?)", name, holder, now).
+		Updates(map[string]interface{}{"holder": holder, "expires_at": expiresAt, "updated_at": now})
+	return result.RowsAffected == 1, result.Error
+}
+
+func (r *scheduledJobRepository) ReleaseLease(name, holder string) error {
+	return r.db.Model(&models.SchedulerLease{}).
+		Where("name = ? AND holder = ?", name, holder).
+		Updates(map[string]interface{}{"holder": "", "expires_at": 0}).Error
+}
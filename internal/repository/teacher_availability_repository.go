@@ -0,0 +1,53 @@
+package repository
+
+import (
+	"school-management-system/internal/models"
+	"school-management-system/pkg/database"
+
+	"gorm.io/gorm"
+)
+
+type TeacherAvailabilityRepository interface {
+	FindByTeacherID(teacherID uint) ([]models.TeacherAvailability, error)
+	FindByTeacherIDs(teacherIDs []uint) ([]models.TeacherAvailability, error)
+	Replace(teacherID uint, windows []models.TeacherAvailability) error
+}
+
+type teacherAvailabilityRepository struct {
+	db *gorm.DB
+}
+
+func NewTeacherAvailabilityRepository() TeacherAvailabilityRepository {
+	return &teacherAvailabilityRepository{db: database.DB}
+}
+
+func (r *teacherAvailabilityRepository) FindByTeacherID(teacherID uint) ([]models.TeacherAvailability, error) {
+	return r.FindByTeacherIDs([]uint{teacherID})
+}
+
+func (r *teacherAvailabilityRepository) FindByTeacherIDs(teacherIDs []uint) ([]models.TeacherAvailability, error) {
+	var windows []models.TeacherAvailability
+	if len(teacherIDs) == 0 {
+		return windows, nil
+	}
+	err := r.db.Where("teacher_id IN ?", teacherIDs).Order("teacher_id, id").Find(&windows).Error
+	return windows, err
+}
+
+// Replace sets a teacher's availability to the given windows; none makes
+// the teacher available all week
+func (r *teacherAvailabilityRepository) Replace(teacherID uint, windows []models.TeacherAvailability) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("teacher_id = ?", teacherID).Delete(&models.TeacherAvailability{}).Error; err != nil {
+			return err
+		}
+		for i := range windows {
+			windows[i].ID = 0
+			windows[i].TeacherID = teacherID
+		}
+		if len(windows) == 0 {
+			return nil
+		}
+		return tx.Create(&windows).Error
+	})
+}
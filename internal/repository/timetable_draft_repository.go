@@ -0,0 +1,74 @@
+package repository
+
+import (
+	"school-management-system/internal/models"
+	"school-management-system/pkg/database"
+
+	"gorm.io/gorm"
+)
+
+type TimetableDraftRepository interface {
+	Create(draft *models.TimetableDraft) error
+	FindByID(id uint) (*models.TimetableDraft, error)
+	Commit(draft *models.TimetableDraft, sectionIDs []uint, slots []models.TimeTable, now int64) (bool, error)
+	Discard(id uint) (bool, error)
+}
+
+type timetableDraftRepository struct {
+	db *gorm.DB
+}
+
+func NewTimetableDraftRepository() TimetableDraftRepository {
+	return &timetableDraftRepository{db: database.DB}
+}
+
+// Create saves a draft with its slots
+func (r *timetableDraftRepository) Create(draft *models.TimetableDraft) error {
+	return r.db.Create(draft).Error
+}
+
+func (r *timetableDraftRepository) FindByID(id uint) (*models.TimetableDraft, error) {
+	var draft models.TimetableDraft
+	err := r.db.Preload("Slots", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).First(&draft, id).Error
+	return &draft, err
+}
+
+// Commit replaces the timetable of the given sections with slots and marks
+// the draft committed, all at once. It reports false, changing nothing, if
+// the draft is no longer a preview.
+func (r *timetableDraftRepository) Commit(draft *models.TimetableDraft, sectionIDs []uint, slots []models.TimeTable, now int64) (bool, error) {
+	committed := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.TimetableDraft{}).
+			Where("id = ? AND status = ?", draft.ID, models.TimetableDraftPreview).
+			Updates(map[string]interface{}{"status": models.TimetableDraftCommitted, "committed_at": now})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		if len(sectionIDs) > 0 {
+			if err := tx.Where("section_id IN ?", sectionIDs).Delete(&models.TimeTable{}).Error; err != nil {
+				return err
+			}
+		}
+		if len(slots) > 0 {
+			if err := tx.Create(&slots).Error; err != nil {
+				return err
+			}
+		}
+		committed = true
+		return nil
+	})
+	if committed {
+		draft.Status = models.TimetableDraftCommitted
+		draft.CommittedAt = now
+	}
+	return committed, err
+}
+
+// Discard marks a preview discarded, reporting false if it was not one
+func (r *timetableDraftRepository) Discard(id uint) (bool, error) {
+	result := r.db.Model(&models.TimetableDraft{}).
+		Where("id = ? AND status = ?", id, models.TimetableDraftPreview).
+		Update("status", models.TimetableDraftDiscarded)
+	return result.RowsAffected > 0, result.Error
+}
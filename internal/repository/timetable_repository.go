@@ -3,6 +3,7 @@ package repository
 import (
 	"school-management-system/internal/models"
 	"school-management-system/pkg/database"
+	"time"
 
 	"gorm.io/gorm"
 )
@@ -11,9 +12,13 @@ type TimeTableRepository interface {
 	Create(timetable *models.TimeTable) error
 	FindByID(id uint) (*models.TimeTable, error)
 	FindByCourseID(courseID uint) ([]models.TimeTable, error)
+	FindBySectionID(sectionID uint) ([]models.TimeTable, error)
 	FindByTeacherID(teacherID uint) ([]models.TimeTable, error)
 	FindByDayOfWeek(dayOfWeek string) ([]models.TimeTable, error)
 	FindAll() ([]models.TimeTable, error)
+	FindByTermID(termID uint) ([]models.TimeTable, error)
+	FindMeetingsOn(day string, termID uint) ([]models.TimeTable, error)
+	FindMeetingsOnDate(date time.Time) ([]models.TimeTable, error)
 	Update(timetable *models.TimeTable) error
 	Delete(id uint) error
 }
@@ -45,6 +50,15 @@ func (r *timetableRepository) FindByCourseID(courseID uint) ([]models.TimeTable,
 	return timetables, err
 }
 
+func (r *timetableRepository) FindBySectionID(sectionID uint) ([]models.TimeTable, error) {
+	var timetables []models.TimeTable
+	err := r.db.Where("section_id = ? AND is_active = ?", sectionID, true).
+		Preload("Course").
+		Preload("Teacher").
+		Find(&timetables).Error
+	return timetables, err
+}
+
 func (r *timetableRepository) FindByTeacherID(teacherID uint) ([]models.TimeTable, error) {
 	var timetables []models.TimeTable
 	err := r.db.Where("teacher_id = ? AND is_active = ?", teacherID, true).
@@ -69,6 +83,47 @@ func (r *timetableRepository) FindAll() ([]models.TimeTable, error) {
 	return timetables, err
 }
 
+// FindByTermID returns the active classes of a term, with those of courses
+// without terms, which meet every term
+func (r *timetableRepository) FindByTermID(termID uint) ([]models.TimeTable, error) {
+	var timetables []models.TimeTable
+	err := r.db.Where("(term_id = ? OR term_id IS NULL) AND is_active = ?", termID, true).
+		Preload("Course").
+		Order("id").
+		Find(&timetables).Error
+	return timetables, err
+}
+
+// FindMeetingsOn returns the active classes on a day of the week of a
+// term, with those of courses without terms; termID 0 means of any term
+func (r *timetableRepository) FindMeetingsOn(day string, termID uint) ([]models.TimeTable, error) {
+	var timetables []models.TimeTable
+	q := r.db.Where("LOWER(day_of_week) = LOWER(?) AND is_active = ?", day, true)
+	if termID != 0 {
+		q = q.Where("(term_id = ? OR term_id IS NULL)", termID)
+	}
+	err := q.Preload("Course").Order("start_time, id").Find(&timetables).Error
+	return timetables, err
+}
+
+// FindMeetingsOnDate returns the active classes meeting on a date: those
+// on its weekday in every term the date falls in, with those of courses
+// without terms. There are none on dates outside every term.
+func (r *timetableRepository) FindMeetingsOnDate(date time.Time) ([]models.TimeTable, error) {
+	return meetingsOnDate(r.db, date)
+}
+
+func meetingsOnDate(db *gorm.DB, date time.Time) ([]models.TimeTable, error) {
+	var timetables []models.TimeTable
+	terms := db.Model(&models.AcademicTerm{}).Select("id").Where("start_date <= ? AND end_date >= ?", date, date)
+	err := db.Where("LOWER(day_of_week) = LOWER(?) AND is_active = ?", date.Weekday().String(), true).
+		Where("(term_id IN (?) OR (term_id IS NULL AND EXISTS (?)))", terms, terms).
+		Preload("Course").
+		Order("start_time, id").
+		Find(&timetables).Error
+	return timetables, err
+}
+
 func (r *timetableRepository) Update(timetable *models.TimeTable) error {
 	return r.db.Save(timetable).Error
 }
@@ -0,0 +1,145 @@
+package repository
+
+import (
+	"school-management-system/internal/models"
+	"school-management-system/pkg/database"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type TokenRepository interface {
+	CreateRefreshToken(token *models.RefreshToken) error
+	FindRefreshTokenByHash(hash string) (*models.RefreshToken, error)
+	RotateRefreshToken(old *models.RefreshToken, next *models.RefreshToken, now int64) error
+	RevokeFamily(familyID string, now int64) error
+	RevokeAllForUser(userID uint, now int64) error
+	RevokeAccessToken(token *models.RevokedToken) error
+	IsAccessTokenRevoked(jti string) (bool, error)
+	PurgeExpired(now int64) (int64, error)
+
+	CreateAccountToken(token *models.AccountToken) error
+	FindAccountToken(purpose, hash string) (*models.AccountToken, error)
+	ConsumeAccountToken(id uint, now int64) error
+	InvalidateAccountTokens(userID uint, purpose string, now int64) error
+
+	AddPasswordHistory(entry *models.PasswordHistory) error
+	FindPasswordHistory(userID uint, limit int) ([]models.PasswordHistory, error)
+}
+
+type tokenRepository struct {
+	db *gorm.DB
+}
+
+func NewTokenRepository() TokenRepository {
+	return &tokenRepository{db: database.DB}
+}
+
+func (r *tokenRepository) CreateRefreshToken(token *models.RefreshToken) error {
+	return r.db.Create(token).Error
+}
+
+func (r *tokenRepository) FindRefreshTokenByHash(hash string) (*models.RefreshToken, error) {
+	var token models.RefreshToken
+	err := r.db.Where("token_hash = ?", hash).First(&token).Error
+	return &token, err
+}
+
+// RotateRefreshToken revokes old and stores next in its place. The revoke is
+// conditional so two concurrent refreshes with the same token cannot both
+// succeed; the loser gets gorm.ErrRecordNotFound.
+func (r *tokenRepository) RotateRefreshToken(old *models.RefreshToken, next *models.RefreshToken, now int64) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(next).Error; err != nil {
+			return err
+		}
+		res := tx.Model(&models.RefreshToken{}).
+			Where("id = ? AND revoked_at = 0", old.ID).
+			Updates(map[string]interface{}{"revoked_at": now, "replaced_by_id": next.ID})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+}
+
+func (r *tokenRepository) RevokeFamily(familyID string, now int64) error {
+	return r.db.Model(&models.RefreshToken{}).
+		Where("family_id = ? AND revoked_at = 0", familyID).
+		Update("revoked_at", now).Error
+}
+
+func (r *tokenRepository) RevokeAllForUser(userID uint, now int64) error {
+	return r.db.Model(&models.RefreshToken{}).
+		Where("user_id = ? AND revoked_at = 0", userID).
+		Update("revoked_at", now).Error
+}
+
+func (r *tokenRepository) RevokeAccessToken(token *models.RevokedToken) error {
+	return r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(token).Error
+}
+
+func (r *tokenRepository) IsAccessTokenRevoked(jti string) (bool, error) {
+	var count int64
+	err := r.db.Model(&models.RevokedToken{}).Where("jti = ?", jti).Count(&count).Error
+	return count > 0, err
+}
+
+// PurgeExpired deletes refresh tokens and revocation entries that have
+// expired and can no longer be presented.
+func (r *tokenRepository) PurgeExpired(now int64) (int64, error) {
+	refresh := r.db.Where("expires_at < ?", now).Delete(&models.RefreshToken{})
+	if refresh.Error != nil {
+		return 0, refresh.Error
+	}
+	revoked := r.db.Where("expires_at < ?", now).Delete(&models.RevokedToken{})
+	if revoked.Error != nil {
+		return 0, revoked.Error
+	}
+	account := r.db.Where("expires_at < ?", now).Delete(&models.AccountToken{})
+	return refresh.RowsAffected + revoked.RowsAffected + account.RowsAffected, account.Error
+}
+
+func (r *tokenRepository) CreateAccountToken(token *models.AccountToken) error {
+	return r.db.Create(token).Error
+}
+
+func (r *tokenRepository) FindAccountToken(purpose, hash string) (*models.AccountToken, error) {
+	var token models.AccountToken
+	err := r.db.Where("purpose = ? AND token_hash = ?", purpose, hash).First(&token).Error
+	return &token, err
+}
+
+// ConsumeAccountToken marks a token used. It returns gorm.ErrRecordNotFound
+// if the token was already used, so a token cannot be redeemed twice.
+func (r *tokenRepository) ConsumeAccountToken(id uint, now int64) error {
+	res := r.db.Model(&models.AccountToken{}).
+		Where("id = ? AND used_at = 0", id).
+		Update("used_at", now)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *tokenRepository) InvalidateAccountTokens(userID uint, purpose string, now int64) error {
+	return r.db.Model(&models.AccountToken{}).
+		Where("user_id = ? AND purpose = ? AND used_at = 0", userID, purpose).
+		Update("used_at", now).Error
+}
+
+func (r *tokenRepository) AddPasswordHistory(entry *models.PasswordHistory) error {
+	return r.db.Create(entry).Error
+}
+
+func (r *tokenRepository) FindPasswordHistory(userID uint, limit int) ([]models.PasswordHistory, error) {
+	var history []models.PasswordHistory
+	err := r.db.Where("user_id = ?", userID).Order("id DESC").Limit(limit).Find(&history).Error
+	return history, err
+}
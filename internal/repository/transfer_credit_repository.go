@@ -0,0 +1,58 @@
+package repository
+
+import (
+	"school-management-system/internal/models"
+	"school-management-system/pkg/database"
+
+	"gorm.io/gorm"
+)
+
+type TransferCreditRepository interface {
+	Create(credit *models.TransferCredit) error
+	FindByID(id uint) (*models.TransferCredit, error)
+	FindByStudentID(studentID uint) ([]models.TransferCredit, error)
+	FindStudentIDsByTerm(termID uint) ([]uint, error)
+	Delete(id uint) error
+}
+
+type transferCreditRepository struct {
+	db *gorm.DB
+}
+
+func NewTransferCreditRepository() TransferCreditRepository {
+	return &transferCreditRepository{db: database.DB}
+}
+
+func (r *transferCreditRepository) Create(credit *models.TransferCredit) error {
+	return r.db.Create(credit).Error
+}
+
+func (r *transferCreditRepository) FindByID(id uint) (*models.TransferCredit, error) {
+	var credit models.TransferCredit
+	err := r.db.First(&credit, id).Error
+	return &credit, err
+}
+
+func (r *transferCreditRepository) FindByStudentID(studentID uint) ([]models.TransferCredit, error) {
+	return studentTransferCredits(r.db, studentID)
+}
+
+func studentTransferCredits(db *gorm.DB, studentID uint) ([]models.TransferCredit, error) {
+	var credits []models.TransferCredit
+	err := db.Where("student_id = ?", studentID).
+		Preload("Term").
+		Preload("EquivalentCourse").
+		Order("created_at, id").
+		Find(&credits).Error
+	return credits, err
+}
+
+func (r *transferCreditRepository) FindStudentIDsByTerm(termID uint) ([]uint, error) {
+	var ids []uint
+	err := r.db.Model(&models.TransferCredit{}).Where("term_id = ?", termID).Distinct().Pluck("student_id", &ids).Error
+	return ids, err
+}
+
+func (r *transferCreditRepository) Delete(id uint) error {
+	return r.db.Delete(&models.TransferCredit{}, id).Error
+}
@@ -1,6 +1,7 @@
 package repository
 
 import (
+	"context"
 	"school-management-system/internal/models"
 	"school-management-system/pkg/database"
 
@@ -8,11 +9,12 @@ import (
 )
 
 type UserRepository interface {
-	Create(user *models.User) error
+	Create(ctx context.Context, user *models.User) error
 	FindByID(id uint) (*models.User, error)
 	FindByEmail(email string) (*models.User, error)
-	Update(user *models.User) error
-	Delete(id uint) error
+	FindByIDs(ids []uint) ([]models.User, error)
+	Update(ctx context.Context, user *models.User) error
+	Delete(ctx context.Context, id uint) error
 	FindAll(page, limit int, role models.UserRole) ([]models.User, int64, error)
 }
 
@@ -24,8 +26,8 @@ func NewUserRepository() UserRepository {
 	return &userRepository{db: database.DB}
 }
 
-func (r *userRepository) Create(user *models.User) error {
-	return r.db.Create(user).Error
+func (r *userRepository) Create(ctx context.Context, user *models.User) error {
+	return r.db.WithContext(ctx).Create(user).Error
 }
 
 func (r *userRepository) FindByID(id uint) (*models.User, error) {
@@ -34,18 +36,28 @@ func (r *userRepository) FindByID(id uint) (*models.User, error) {
 	return &user, err
 }
 
+// FindByIDs returns the users with the given IDs, in ID order
+func (r *userRepository) FindByIDs(ids []uint) ([]models.User, error) {
+	var users []models.User
+	if len(ids) == 0 {
+		return users, nil
+	}
+	err := r.db.Where("id IN ?", ids).Order("id").Find(&users).Error
+	return users, err
+}
+
 func (r *userRepository) FindByEmail(email string) (*models.User, error) {
 	var user models.User
 	err := r.db.Where("email = ?", email).First(&user).Error
 	return &user, err
 }
 
-func (r *userRepository) Update(user *models.User) error {
-	return r.db.Save(user).Error
+func (r *userRepository) Update(ctx context.Context, user *models.User) error {
+	return r.db.WithContext(ctx).Save(user).Error
 }
 
-func (r *userRepository) Delete(id uint) error {
-	return r.db.Delete(&models.User{}, id).Error
+func (r *userRepository) Delete(ctx context.Context, id uint) error {
+	return r.db.WithContext(ctx).Delete(&models.User{}, id).Error
 }
 
 func (r *userRepository) FindAll(page, limit int, role models.UserRole) ([]models.User, int64, error) {
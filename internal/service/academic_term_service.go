@@ -0,0 +1,206 @@
+package service
+
+import (
+	"errors"
+	"school-management-system/internal/models"
+	"school-management-system/internal/repository"
+	"school-management-system/pkg/logger"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+type AcademicTermService interface {
+	CreateTerm(term *models.AcademicTerm) error
+	GetTermByID(id uint) (*models.AcademicTerm, error)
+	GetAllTerms(page, limit int) ([]models.AcademicTerm, int64, error)
+	GetCurrentTerm() (*models.AcademicTerm, error)
+	GetTermForDate(date time.Time) (*models.AcademicTerm, error)
+	UpdateTerm(term *models.AcademicTerm) error
+	UpdateTermStatus(id uint, status models.TermStatus) (*models.AcademicTerm, error)
+	DeleteTerm(id uint) error
+}
+
+type academicTermService struct {
+	termRepo          repository.AcademicTermRepository
+	transcriptService GradeTranscriptService
+	logger            *logrus.Logger
+}
+
+func NewAcademicTermService(termRepo repository.AcademicTermRepository, transcriptService GradeTranscriptService) AcademicTermService {
+	return &academicTermService{
+		termRepo:          termRepo,
+		transcriptService: transcriptService,
+		logger:            logger.GetLogger(),
+	}
+}
+
+func validateTerm(term *models.AcademicTerm) error {
+	if term.Name == "" {
+		return errors.New("term name is required")
+	}
+	if term.StartDate.IsZero() || term.EndDate.IsZero() {
+		return errors.New("term start and end dates are required")
+	}
+	if !term.EndDate.After(term.StartDate) {
+		return errors.New("term end date must be after start date")
+	}
+	if !term.RegistrationStart.IsZero() && !term.RegistrationEnd.IsZero() &&
+		term.RegistrationEnd.Before(term.RegistrationStart) {
+		return errors.New("registration end must be after registration start")
+	}
+	if !term.GradingDeadline.IsZero() && term.GradingDeadline.Before(term.StartDate) {
+		return errors.New("grading deadline must be after term start date")
+	}
+	if !term.DropDeadline.IsZero() && !term.WithdrawalDeadline.IsZero() &&
+		term.WithdrawalDeadline.Before(term.DropDeadline) {
+		return errors.New("withdrawal deadline must not be before the drop deadline")
+	}
+	if !isValidTermStatus(term.Status) {
+		return errors.New("invalid term status")
+	}
+	return nil
+}
+
+func isValidTermStatus(status models.TermStatus) bool {
+	switch status {
+	case models.TermStatusPlanned, models.TermStatusRegistration, models.TermStatusActive,
+		models.TermStatusGrading, models.TermStatusClosed:
+		return true
+	}
+	return false
+}
+
+func (s *academicTermService) CreateTerm(term *models.AcademicTerm) error {
+	if term.Status == "" {
+		term.Status = models.TermStatusPlanned
+	}
+
+	if err := validateTerm(term); err != nil {
+		s.logger.WithError(err).WithField("name", term.Name).Warn("Invalid academic term")
+		return err
+	}
+
+	if term.Code != "" {
+		if _, err := s.termRepo.FindByCode(term.Code); err == nil {
+			s.logger.WithField("code", term.Code).Warn("Term code already exists")
+			return errors.New("term code already exists")
+		}
+	}
+
+	if err := s.termRepo.Create(term); err != nil {
+		s.logger.WithError(err).WithField("name", term.Name).Error("Failed to create academic term")
+		return errors.New("failed to create academic term")
+	}
+
+	s.logger.WithField("id", term.ID).WithField("name", term.Name).Info("Academic term created")
+	return nil
+}
+
+func (s *academicTermService) GetTermByID(id uint) (*models.AcademicTerm, error) {
+	term, err := s.termRepo.FindByID(id)
+	if err != nil {
+		s.logger.WithError(err).WithField("id", id).Warn("Academic term not found")
+		return nil, errors.New("academic term not found")
+	}
+
+	return term, nil
+}
+
+func (s *academicTermService) GetAllTerms(page, limit int) ([]models.AcademicTerm, int64, error) {
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 {
+		limit = 10
+	}
+
+	terms, total, err := s.termRepo.FindAll(page, limit)
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to fetch academic terms")
+		return nil, 0, err
+	}
+
+	return terms, total, nil
+}
+
+// GetCurrentTerm returns the term marked active, falling back to the term
+// whose dates contain today.
+func (s *academicTermService) GetCurrentTerm() (*models.AcademicTerm, error) {
+	active, err := s.termRepo.FindByStatus(models.TermStatusActive)
+	if err == nil && len(active) > 0 {
+		return &active[0], nil
+	}
+
+	return s.GetTermForDate(time.Now())
+}
+
+func (s *academicTermService) GetTermForDate(date time.Time) (*models.AcademicTerm, error) {
+	term, err := s.termRepo.FindByDate(date)
+	if err != nil {
+		return nil, errors.New("no academic term covers the given date")
+	}
+
+	return term, nil
+}
+
+func (s *academicTermService) UpdateTerm(term *models.AcademicTerm) error {
+	if term.ID == 0 {
+		return errors.New("term id is required")
+	}
+
+	if err := validateTerm(term); err != nil {
+		return err
+	}
+
+	if err := s.termRepo.Update(term); err != nil {
+		s.logger.WithError(err).WithField("id", term.ID).Error("Failed to update academic term")
+		return errors.New("failed to update academic term")
+	}
+
+	s.logger.WithField("id", term.ID).Info("Academic term updated")
+	return s.finalizeIfClosed(term)
+}
+
+func (s *academicTermService) UpdateTermStatus(id uint, status models.TermStatus) (*models.AcademicTerm, error) {
+	if !isValidTermStatus(status) {
+		return nil, errors.New("invalid term status")
+	}
+
+	term, err := s.GetTermByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	term.Status = status
+	if err := s.termRepo.Update(term); err != nil {
+		s.logger.WithError(err).WithField("id", id).Error("Failed to update academic term status")
+		return nil, errors.New("failed to update academic term")
+	}
+
+	s.logger.WithField("id", id).WithField("status", status).Info("Academic term status updated")
+	return term, s.finalizeIfClosed(term)
+}
+
+// finalizeIfClosed makes the transcripts of a closed term official.
+func (s *academicTermService) finalizeIfClosed(term *models.AcademicTerm) error {
+	if term.Status != models.TermStatusClosed {
+		return nil
+	}
+
+	if _, err := s.transcriptService.FinalizeTerm(term.ID); err != nil {
+		s.logger.WithError(err).WithField("id", term.ID).Error("Failed to finalize term transcripts")
+		return errors.New("term closed but transcripts could not be finalized")
+	}
+	return nil
+}
+
+func (s *academicTermService) DeleteTerm(id uint) error {
+	if err := s.termRepo.Delete(id); err != nil {
+		s.logger.WithError(err).WithField("id", id).Error("Failed to delete academic term")
+		return errors.New("failed to delete academic term")
+	}
+
+	s.logger.WithField("id", id).Info("Academic term deleted")
+	return nil
+}
@@ -1,52 +1,449 @@
 package service
 
 import (
+	"errors"
+	"fmt"
 	"school-management-system/internal/models"
+	"school-management-system/internal/realtime"
 	"school-management-system/internal/repository"
+	"school-management-system/pkg/logger"
+	"strconv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	ErrAnnouncementNotFound  = errors.New("announcement not found")
+	ErrInvalidAnnouncement   = errors.New("invalid announcement")
+	ErrAnnouncementForbidden = errors.New("announcement not allowed")
 )
 
+// announcementEmailBatch is how many recipients share one announcement
+// email
+const announcementEmailBatch = 100
+
 type AnnouncementService interface {
-	Create(announcement *models.Announcement) error
+	Create(announcement *models.Announcement, actorID uint) error
 	GetByID(id uint) (*models.Announcement, error)
-	GetAll(page, limit int) ([]models.Announcement, int64, error)
-	GetActive(page, limit int) ([]models.Announcement, int64, error)
+	GetAll(createdBy uint, page, limit int) ([]models.Announcement, int64, error)
+	GetActive(userID uint, unreadOnly bool, page, limit int) ([]models.Announcement, int64, error)
 	GetByAudience(audience string, page, limit int) ([]models.Announcement, int64, error)
-	Update(announcement *models.Announcement) error
+	Update(announcement *models.Announcement, actorID uint) error
 	Delete(id uint) error
+	ExpireDue(now time.Time) (int64, error)
+	PublishDue(now time.Time) (int, error)
+	SyncAudiences(now time.Time) (int64, error)
+	MarkRead(id, userID uint) error
+	Acknowledge(id, userID uint) error
+	GetReceiptCounts(id uint) (*repository.AnnouncementReceiptCounts, error)
+	GetRecipients(id uint, status string, page, limit int) ([]models.AnnouncementRecipient, int64, error)
 }
 
 type announcementService struct {
-	repo repository.AnnouncementRepository
+	repo     repository.AnnouncementRepository
+	users    repository.UserRepository
+	email    *EmailService
+	realtime RealtimeService
+	logger   *logrus.Logger
+}
+
+// NewAnnouncementService creates the announcement service. Email and
+// realtime are optional; without them announcements are only listed.
+func NewAnnouncementService(repo repository.AnnouncementRepository, users repository.UserRepository, email *EmailService, realtime RealtimeService) AnnouncementService {
+	return &announcementService{repo: repo, users: users, email: email, realtime: realtime, logger: logger.GetLogger()}
+}
+
+// legacyAudienceRoles maps the role audiences announcements were addressed
+// to before targets to the role they name
+var legacyAudienceRoles = map[string]models.UserRole{
+	"students": models.RoleStudent,
+	"teachers": models.RoleTeacher,
+	"parents":  models.RoleParent,
+}
+
+// Create saves an announcement, publishing it now unless it is scheduled
+// for later
+func (s *announcementService) Create(announcement *models.Announcement, actorID uint) error {
+	now := time.Now().Unix()
+	if announcement.PublishAt == 0 {
+		announcement.PublishAt = now
+	}
+	announcement.PublishedAt = 0
+	announcement.EmailedAt = 0
+	if err := s.validate(announcement, actorID); err != nil {
+		return err
+	}
+	if err := s.repo.Create(announcement); err != nil {
+		return err
+	}
+	if announcement.IsActive && announcement.PublishAt <= now {
+		return s.publish(announcement, now)
+	}
+	return nil
+}
+
+// validate checks an announcement's schedule, priority and targets, and
+// that the actor may address its audience. Teachers may only announce to
+// courses and sections they teach, and to individual users.
+func (s *announcementService) validate(announcement *models.Announcement, actorID uint) error {
+	switch announcement.Priority {
+	case "":
+		announcement.Priority = "normal"
+	case "low", "normal", "high":
+	default:
+		return fmt.Errorf("%w: priority must be low, normal or high", ErrInvalidAnnouncement)
+	}
+	if announcement.ExpiresAt > 0 && announcement.ExpiresAt <= announcement.PublishAt {
+		return fmt.Errorf("%w: expires_at must be after publish_at", ErrInvalidAnnouncement)
+	}
+
+	if len(announcement.Targets) == 0 {
+		if role, ok := legacyAudienceRoles[announcement.Audience]; ok {
+			announcement.Targets = []models.AnnouncementTarget{{Kind: models.AnnouncementTargetRole, Value: string(role)}}
+		} else if announcement.Audience != "" && announcement.Audience != models.AnnouncementAudienceAll {
+			return fmt.Errorf("%w: audience must be all, students, teachers or parents; use targets for anything narrower", ErrInvalidAnnouncement)
+		}
+	}
+	if len(announcement.Targets) == 0 {
+		announcement.Audience = models.AnnouncementAudienceAll
+	} else {
+		announcement.Audience = models.AnnouncementAudienceTargeted
+	}
+
+	actor, err := s.users.FindByID(actorID)
+	if err != nil {
+		return fmt.Errorf("%w: unknown author", ErrAnnouncementForbidden)
+	}
+	if actor.Role != models.RoleAdmin && announcement.Audience == models.AnnouncementAudienceAll {
+		return fmt.Errorf("%w: only admins may announce to everyone", ErrAnnouncementForbidden)
+	}
+
+	seen := map[models.AnnouncementTarget]bool{}
+	targets := announcement.Targets[:0]
+	for _, target := range announcement.Targets {
+		target.ID = 0
+		target.AnnouncementID = 0
+		if err := s.validateTarget(target, actor); err != nil {
+			return err
+		}
+		if !seen[target] {
+			seen[target] = true
+			targets = append(targets, target)
+		}
+	}
+	announcement.Targets = targets
+	return nil
+}
+
+func (s *announcementService) validateTarget(target models.AnnouncementTarget, actor *models.User) error {
+	switch target.Kind {
+	case models.AnnouncementTargetRole:
+		switch models.UserRole(target.Value) {
+		case models.RoleAdmin, models.RoleTeacher, models.RoleStudent, models.RoleParent:
+		default:
+			return fmt.Errorf("%w: unknown role %q", ErrInvalidAnnouncement, target.Value)
+		}
+	case models.AnnouncementTargetGradeLevel:
+		if target.Value == "" {
+			return fmt.Errorf("%w: grade_level targets need a value", ErrInvalidAnnouncement)
+		}
+	case models.AnnouncementTargetCourse, models.AnnouncementTargetSection, models.AnnouncementTargetUser:
+		if id, err := strconv.ParseUint(target.Value, 10, 32); err != nil || id == 0 {
+			return fmt.Errorf("%w: %s targets need an ID", ErrInvalidAnnouncement, target.Kind)
+		}
+		exists, err := s.repo.TargetExists(target)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return fmt.Errorf("%w: %s %s does not exist", ErrInvalidAnnouncement, target.Kind, target.Value)
+		}
+	default:
+		return fmt.Errorf("%w: unknown target kind %q", ErrInvalidAnnouncement, target.Kind)
+	}
+
+	if actor.Role == models.RoleAdmin {
+		return nil
+	}
+	switch target.Kind {
+	case models.AnnouncementTargetUser:
+		return nil
+	case models.AnnouncementTargetCourse, models.AnnouncementTargetSection:
+		teaches, err := s.repo.Teaches(actor.ID, target)
+		if err != nil {
+			return err
+		}
+		if teaches {
+			return nil
+		}
+		return fmt.Errorf("%w: you do not teach %s %s", ErrAnnouncementForbidden, target.Kind, target.Value)
+	}
+	return fmt.Errorf("%w: only admins may target a %s", ErrAnnouncementForbidden, target.Kind)
+}
+
+// publish delivers a due announcement to its audience. Only the call that
+// marks it published pushes and emails it, so a concurrent publish cannot
+// send it twice.
+func (s *announcementService) publish(announcement *models.Announcement, now int64) error {
+	userIDs, err := s.repo.AudienceUserIDs(announcement)
+	if err != nil {
+		return err
+	}
+	if _, err := s.repo.AddRecipients(announcement.ID, userIDs, now); err != nil {
+		return err
+	}
+	claimed, err := s.repo.MarkPublished(announcement.ID, now)
+	if err != nil || !claimed {
+		return err
+	}
+	announcement.PublishedAt = now
+
+	if s.realtime != nil {
+		if announcement.Audience == models.AnnouncementAudienceAll {
+			s.realtime.Broadcast("", realtime.TypeAnnouncementPublished, announcement)
+		} else {
+			s.push(announcement, userIDs)
+		}
+	}
+	if announcement.SendEmail {
+		s.sendEmail(announcement, userIDs, now)
+	}
+	return nil
 }
 
-func NewAnnouncementService(repo repository.AnnouncementRepository) AnnouncementService {
-	return &announcementService{repo: repo}
+func (s *announcementService) push(announcement *models.Announcement, userIDs []uint) {
+	if s.realtime == nil {
+		return
+	}
+	for _, userID := range userIDs {
+		s.realtime.Publish(userID, realtime.TypeAnnouncementPublished, announcement)
+	}
 }
 
-func (s *announcementService) Create(announcement *models.Announcement) error {
-	return s.repo.Create(announcement)
+// sendEmail emails an announcement to those of its recipients with an
+// address. Failures are logged rather than undoing the publish.
+func (s *announcementService) sendEmail(announcement *models.Announcement, userIDs []uint, now int64) {
+	log := s.logger.WithField("announcement_id", announcement.ID)
+	if s.email == nil {
+		log.Warn("Announcement email requested but email is not configured")
+		return
+	}
+	for start := 0; start < len(userIDs); start += announcementEmailBatch {
+		end := start + announcementEmailBatch
+		if end > len(userIDs) {
+			end = len(userIDs)
+		}
+		users, err := s.users.FindByIDs(userIDs[start:end])
+		if err != nil {
+			log.WithError(err).Error("Failed to load announcement recipients")
+			return
+		}
+		recipients := make([]EmailRecipient, 0, len(users))
+		for i := range users {
+			recipients = append(recipients, UserRecipient(&users[i]))
+		}
+		if err := s.email.SendAnnouncementNotification(recipients, announcement.Title, announcement.Content); err != nil {
+			log.WithError(err).Error("Failed to email announcement")
+			return
+		}
+	}
+	if err := s.repo.MarkEmailed(announcement.ID, now); err != nil {
+		log.WithError(err).Warn("Failed to record announcement email")
+	}
+	announcement.EmailedAt = now
+}
+
+// sync brings a published announcement's recipients in line with its
+// audience, returning how many were added. Users who have left the audience
+// keep it only if they have already read it.
+func (s *announcementService) sync(announcement *models.Announcement, now int64) (int64, error) {
+	audience, err := s.repo.AudienceUserIDs(announcement)
+	if err != nil {
+		return 0, err
+	}
+	current, err := s.repo.RecipientUserIDs(announcement.ID)
+	if err != nil {
+		return 0, err
+	}
+	added, removed := diffIDs(current, audience)
+	if _, err := s.repo.RemoveUnreadRecipients(announcement.ID, removed); err != nil {
+		return 0, err
+	}
+	n, err := s.repo.AddRecipients(announcement.ID, added, now)
+	if err != nil {
+		return 0, err
+	}
+	if announcement.Audience != models.AnnouncementAudienceAll {
+		s.push(announcement, added)
+	}
+	return n, nil
+}
+
+// diffIDs returns the IDs in next but not current, and those in current but
+// not next. Both must be sorted.
+func diffIDs(current, next []uint) (added, removed []uint) {
+	i, j := 0, 0
+	for i < len(current) || j < len(next) {
+		switch {
+		case j == len(next) || (i < len(current) && current[i] < next[j]):
+			removed = append(removed, current[i])
+			i++
+		case i == len(current) || next[j] < current[i]:
+			added = append(added, next[j])
+			j++
+		default:
+			i++
+			j++
+		}
+	}
+	return added, removed
 }
 
 func (s *announcementService) GetByID(id uint) (*models.Announcement, error) {
-	return s.repo.FindByID(id)
+	announcement, err := s.repo.FindByID(id)
+	if err != nil {
+		return nil, ErrAnnouncementNotFound
+	}
+	return announcement, nil
 }
 
-func (s *announcementService) GetAll(page, limit int) ([]models.Announcement, int64, error) {
-	return s.repo.FindAll(page, limit)
+// GetAll lists announcements, or one author's if createdBy is set
+func (s *announcementService) GetAll(createdBy uint, page, limit int) ([]models.Announcement, int64, error) {
+	return s.repo.FindAll(createdBy, page, limit)
 }
 
-func (s *announcementService) GetActive(page, limit int) ([]models.Announcement, int64, error) {
-	return s.repo.FindActive(page, limit)
+// GetActive lists the published, unexpired announcements delivered to a
+// user, newest first
+func (s *announcementService) GetActive(userID uint, unreadOnly bool, page, limit int) ([]models.Announcement, int64, error) {
+	return s.repo.FindForRecipient(userID, time.Now().Unix(), unreadOnly, page, limit)
 }
 
 func (s *announcementService) GetByAudience(audience string, page, limit int) ([]models.Announcement, int64, error) {
 	return s.repo.FindByAudience(audience, page, limit)
 }
 
-func (s *announcementService) Update(announcement *models.Announcement) error {
-	return s.repo.Update(announcement)
+// Update saves an announcement. One already published is redelivered to
+// its current audience; one not yet published goes out if it is now due.
+func (s *announcementService) Update(announcement *models.Announcement, actorID uint) error {
+	now := time.Now().Unix()
+	if announcement.PublishAt == 0 {
+		announcement.PublishAt = now
+	}
+	if err := s.validate(announcement, actorID); err != nil {
+		return err
+	}
+	if err := s.repo.Update(announcement); err != nil {
+		return err
+	}
+	if !announcement.IsActive {
+		return nil
+	}
+	if announcement.PublishedAt > 0 {
+		_, err := s.sync(announcement, now)
+		return err
+	}
+	if announcement.PublishAt <= now {
+		return s.publish(announcement, now)
+	}
+	return nil
 }
 
 func (s *announcementService) Delete(id uint) error {
 	return s.repo.Delete(id)
 }
+
+// ExpireDue deactivates announcements past their expiry time
+func (s *announcementService) ExpireDue(now time.Time) (int64, error) {
+	return s.repo.DeactivateExpired(now.Unix())
+}
+
+// PublishDue publishes the scheduled announcements whose time has come
+func (s *announcementService) PublishDue(now time.Time) (int, error) {
+	due, err := s.repo.FindDue(now.Unix())
+	if err != nil {
+		return 0, err
+	}
+	for i := range due {
+		if err := s.publish(&due[i], now.Unix()); err != nil {
+			return i, err
+		}
+	}
+	return len(due), nil
+}
+
+// SyncAudiences delivers live announcements to users who have joined their
+// audience since they were published, such as newly enrolled students
+func (s *announcementService) SyncAudiences(now time.Time) (int64, error) {
+	announcements, err := s.repo.FindLive(now.Unix())
+	if err != nil {
+		return 0, err
+	}
+	var added int64
+	for i := range announcements {
+		n, err := s.sync(&announcements[i], now.Unix())
+		if err != nil {
+			return added, err
+		}
+		added += n
+	}
+	return added, nil
+}
+
+// receipt returns the user's receipt for an announcement they can see
+func (s *announcementService) receipt(id, userID uint) (*models.Announcement, error) {
+	announcement, err := s.repo.FindByID(id)
+	if err != nil || !announcement.IsActive || announcement.PublishedAt == 0 {
+		return nil, ErrAnnouncementNotFound
+	}
+	if _, err := s.repo.FindRecipient(id, userID); err != nil {
+		return nil, ErrAnnouncementNotFound
+	}
+	return announcement, nil
+}
+
+// MarkRead records that the user has read an announcement delivered to them
+func (s *announcementService) MarkRead(id, userID uint) error {
+	if _, err := s.receipt(id, userID); err != nil {
+		return err
+	}
+	_, err := s.repo.MarkRead(id, userID, time.Now().Unix())
+	return err
+}
+
+// Acknowledge records the user's acknowledgement of an announcement that
+// asks for one
+func (s *announcementService) Acknowledge(id, userID uint) error {
+	announcement, err := s.receipt(id, userID)
+	if err != nil {
+		return err
+	}
+	if !announcement.RequiresAcknowledgement {
+		return fmt.Errorf("%w: this announcement does not ask for acknowledgement", ErrInvalidAnnouncement)
+	}
+	_, err = s.repo.Acknowledge(id, userID, time.Now().Unix())
+	return err
+}
+
+// GetReceiptCounts summarizes how many recipients have read and
+// acknowledged an announcement
+func (s *announcementService) GetReceiptCounts(id uint) (*repository.AnnouncementReceiptCounts, error) {
+	if _, err := s.repo.FindByID(id); err != nil {
+		return nil, ErrAnnouncementNotFound
+	}
+	return s.repo.CountReceipts(id)
+}
+
+// GetRecipients lists an announcement's recipients with their receipts.
+// Status may be read, unread, acknowledged or unacknowledged.
+func (s *announcementService) GetRecipients(id uint, status string, page, limit int) ([]models.AnnouncementRecipient, int64, error) {
+	switch status {
+	case "", "read", "unread", "acknowledged", "unacknowledged":
+	default:
+		return nil, 0, fmt.Errorf("%w: status must be read, unread, acknowledged or unacknowledged", ErrInvalidAnnouncement)
+	}
+	if _, err := s.repo.FindByID(id); err != nil {
+		return nil, 0, ErrAnnouncementNotFound
+	}
+	return s.repo.FindRecipients(id, status, page, limit)
+}
@@ -1,7 +1,9 @@
 package service
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"school-management-system/internal/models"
 	"school-management-system/internal/repository"
 	"school-management-system/pkg/logger"
@@ -15,8 +17,9 @@ type AssignmentService interface {
 	GetAssignmentByID(id uint) (*models.Assignment, error)
 	GetAssignmentsByCourse(courseID uint) ([]models.Assignment, error)
 	GetAssignmentsByTeacher(teacherID uint) ([]models.Assignment, error)
-	UpdateAssignment(assignment *models.Assignment) error
-	DeleteAssignment(id uint) error
+	UpdateAssignment(ctx context.Context, assignment *models.Assignment) error
+	DeleteAssignment(ctx context.Context, id uint) error
+	SendDueReminders(now time.Time) (int, error)
 }
 
 type AssignmentSubmissionService interface {
@@ -25,32 +28,38 @@ type AssignmentSubmissionService interface {
 	GetSubmissionsByAssignment(assignmentID uint) ([]models.AssignmentSubmission, error)
 	GetSubmissionsByStudent(studentID uint) ([]models.AssignmentSubmission, error)
 	GetSubmissionByAssignmentAndStudent(assignmentID, studentID uint) (*models.AssignmentSubmission, error)
-	GradeSubmission(submissionID uint, score float64, feedback string) error
+	GradeSubmission(ctx context.Context, submissionID uint, score float64, feedback string) error
 	UpdateSubmission(submission *models.AssignmentSubmission) error
 	DeleteSubmission(id uint) error
 }
 
 type assignmentService struct {
-	assignmentRepo repository.AssignmentRepository
-	logger         *logrus.Logger
+	assignmentRepo   repository.AssignmentRepository
+	gradebookService GradebookService
+	notifier         NotificationDispatcher
+	logger           *logrus.Logger
 }
 
 type assignmentSubmissionService struct {
-	submissionRepo repository.AssignmentSubmissionRepository
-	logger         *logrus.Logger
+	submissionRepo   repository.AssignmentSubmissionRepository
+	gradebookService GradebookService
+	logger           *logrus.Logger
 }
 
-func NewAssignmentService(assignmentRepo repository.AssignmentRepository) AssignmentService {
+func NewAssignmentService(assignmentRepo repository.AssignmentRepository, gradebookService GradebookService, notifier NotificationDispatcher) AssignmentService {
 	return &assignmentService{
-		assignmentRepo: assignmentRepo,
-		logger:         logger.GetLogger(),
+		assignmentRepo:   assignmentRepo,
+		gradebookService: gradebookService,
+		notifier:         notifier,
+		logger:           logger.GetLogger(),
 	}
 }
 
-func NewAssignmentSubmissionService(submissionRepo repository.AssignmentSubmissionRepository) AssignmentSubmissionService {
+func NewAssignmentSubmissionService(submissionRepo repository.AssignmentSubmissionRepository, gradebookService GradebookService) AssignmentSubmissionService {
 	return &assignmentSubmissionService{
-		submissionRepo: submissionRepo,
-		logger:         logger.GetLogger(),
+		submissionRepo:   submissionRepo,
+		gradebookService: gradebookService,
+		logger:           logger.GetLogger(),
 	}
 }
 
@@ -71,6 +80,10 @@ func (s *assignmentService) CreateAssignment(assignment *models.Assignment) erro
 		return errors.New("created by is required")
 	}
 
+	if err := s.gradebookService.ValidateAssignmentCategory(assignment); err != nil {
+		return err
+	}
+
 	assignment.CreatedAt = time.Now()
 
 	err := s.assignmentRepo.Create(assignment)
@@ -113,11 +126,15 @@ func (s *assignmentService) GetAssignmentsByTeacher(teacherID uint) ([]models.As
 	return assignments, nil
 }
 
-func (s *assignmentService) UpdateAssignment(assignment *models.Assignment) error {
+func (s *assignmentService) UpdateAssignment(ctx context.Context, assignment *models.Assignment) error {
 	if assignment.ID == 0 {
 		return errors.New("assignment id is required")
 	}
 
+	if err := s.gradebookService.ValidateAssignmentCategory(assignment); err != nil {
+		return err
+	}
+
 	err := s.assignmentRepo.Update(assignment)
 	if err != nil {
 		s.logger.WithError(err).WithField("id", assignment.ID).Error("Failed to update assignment")
@@ -125,20 +142,85 @@ func (s *assignmentService) UpdateAssignment(assignment *models.Assignment) erro
 	}
 
 	s.logger.WithField("id", assignment.ID).Info("Assignment updated successfully")
+	s.recomputeInBackground(ctx, assignment.CourseID)
 	return nil
 }
 
-func (s *assignmentService) DeleteAssignment(id uint) error {
-	err := s.assignmentRepo.Delete(id)
+func (s *assignmentService) DeleteAssignment(ctx context.Context, id uint) error {
+	assignment, err := s.assignmentRepo.FindByID(id)
+	if err != nil {
+		return errors.New("assignment not found")
+	}
+
+	err = s.assignmentRepo.Delete(id)
 	if err != nil {
 		s.logger.WithError(err).WithField("id", id).Error("Failed to delete assignment")
 		return errors.New("failed to delete assignment")
 	}
 
 	s.logger.WithField("id", id).Info("Assignment deleted successfully")
+	s.recomputeInBackground(ctx, assignment.CourseID)
 	return nil
 }
 
+// assignmentReminderWindow is how long before an assignment is due that
+// students who have not submitted it are reminded
+const assignmentReminderWindow = 24 * time.Hour
+
+// SendDueReminders reminds students of assignments due within a day that
+// they have not submitted, returning how many students were reminded. Each
+// assignment is reminded once.
+func (s *assignmentService) SendDueReminders(now time.Time) (int, error) {
+	assignments, err := s.assignmentRepo.FindDueForReminder(now, now.Add(assignmentReminderWindow))
+	if err != nil {
+		return 0, err
+	}
+
+	reminded := 0
+	for i := range assignments {
+		a := &assignments[i]
+		students, err := s.assignmentRepo.FindStudentsWithoutSubmission(a)
+		if err != nil {
+			return reminded, err
+		}
+		event := &NotificationEvent{
+			Type:    EventAssignmentDue,
+			Title:   "Assignment due soon",
+			Message: fmt.Sprintf("%s for %s is due %s.", a.Title, a.Course.Name, a.DueDate.Format("Monday, January 2 at 15:04 MST")),
+			Data: map[string]interface{}{"assignment_id": a.ID, "course_id": a.CourseID, "course_name": a.Course.Name,
+				"title": a.Title, "due_date": a.DueDate},
+		}
+		for _, student := range students {
+			if student.User.IsActive {
+				event.UserIDs = append(event.UserIDs, student.UserID)
+			}
+		}
+		if s.notifier != nil && len(event.UserIDs) > 0 {
+			if err := s.notifier.Dispatch(event); err != nil {
+				s.logger.WithError(err).WithField("assignment_id", a.ID).Warn("Failed to send assignment reminders")
+				continue
+			}
+			reminded += len(event.UserIDs)
+		}
+		if err := s.assignmentRepo.MarkReminded(a.ID, now); err != nil {
+			return reminded, err
+		}
+	}
+	return reminded, nil
+}
+
+// recomputeInBackground refreshes course grades after the gradebook's shape
+// changed, as grade category changes do: after the request has returned, so
+// it keeps the request's actor but not its cancellation.
+func (s *assignmentService) recomputeInBackground(ctx context.Context, courseID uint) {
+	ctx = context.WithoutCancel(ctx)
+	go func() {
+		if _, err := s.gradebookService.RecomputeCourse(ctx, courseID); err != nil {
+			s.logger.WithError(err).WithField("course_id", courseID).Warn("Failed to recompute gradebook")
+		}
+	}()
+}
+
 // AssignmentSubmission methods
 func (s *assignmentSubmissionService) SubmitAssignment(submission *models.AssignmentSubmission) error {
 	if submission.AssignmentID == 0 {
@@ -224,7 +306,7 @@ func (s *assignmentSubmissionService) GetSubmissionByAssignmentAndStudent(assign
 	return submission, nil
 }
 
-func (s *assignmentSubmissionService) GradeSubmission(submissionID uint, score float64, feedback string) error {
+func (s *assignmentSubmissionService) GradeSubmission(ctx context.Context, submissionID uint, score float64, feedback string) error {
 	submission, err := s.submissionRepo.FindByID(submissionID)
 	if err != nil {
 		s.logger.WithError(err).WithField("id", submissionID).Warn("Submission not found")
@@ -245,6 +327,10 @@ func (s *assignmentSubmissionService) GradeSubmission(submissionID uint, score f
 		"id":    submissionID,
 		"score": score,
 	}).Info("Submission graded successfully")
+
+	if err := s.gradebookService.RecomputeStudent(ctx, submission.Assignment.CourseID, submission.StudentID); err != nil {
+		s.logger.WithError(err).WithField("id", submissionID).Warn("Failed to update course grade from gradebook")
+	}
 	return nil
 }
 
@@ -1,6 +1,7 @@
 package service
 
 import (
+	"context"
 	"time"
 
 	"school-management-system/internal/models"
@@ -9,13 +10,15 @@ import (
 
 // AttendanceAutomationService provides automated attendance tracking
 type AttendanceAutomationService struct {
-	emailService *EmailService
+	emailService    *EmailService
+	guardianService GuardianService
 }
 
 // NewAttendanceAutomationService creates a new service
-func NewAttendanceAutomationService(emailService *EmailService) *AttendanceAutomationService {
+func NewAttendanceAutomationService(emailService *EmailService, guardianService GuardianService) *AttendanceAutomationService {
 	return &AttendanceAutomationService{
-		emailService: emailService,
+		emailService:    emailService,
+		guardianService: guardianService,
 	}
 }
 
@@ -55,34 +58,84 @@ func (aas *AttendanceAutomationService) CheckLowAttendance(studentID uint, cours
 	}
 
 	if percentage < threshold {
-		// Get student info
-		db := database.DB
-		var student models.Student
-		if err := db.First(&student, studentID).Error; err == nil {
-			// Get course info
-			var course models.Course
-			if err := db.First(&course, courseID).Error; err == nil {
-				// Send email alert
-				aas.emailService.SendAttendanceAlert(
-					student.User.Email,
-					student.User.FirstName+" "+student.User.LastName,
-					course.Name,
-					percentage,
-				)
-			}
-		}
+		aas.sendAttendanceAlert(studentID, courseID, percentage)
 		return true, nil
 	}
 
 	return false, nil
 }
 
+// sendAttendanceAlert emails a low attendance alert to the student and
+// their guardians
+func (aas *AttendanceAutomationService) sendAttendanceAlert(studentID, courseID uint, percentage float64) {
+	// Get student info
+	db := database.DB
+	var student models.Student
+	if err := db.Preload("User").First(&student, studentID).Error; err == nil {
+		// Get course info
+		var course models.Course
+		if err := db.First(&course, courseID).Error; err == nil {
+			studentName := student.User.FirstName + " " + student.User.LastName
+
+			// Send email alert
+			aas.emailService.SendAttendanceAlert(
+				UserRecipient(&student.User),
+				studentName,
+				course.Name,
+				percentage,
+			)
+
+			// Copy the alert to the student's guardians
+			aas.emailService.SendGuardianAttendanceAlert(
+				aas.guardianService.NotificationRecipients(studentID),
+				studentName,
+				course.Name,
+				percentage,
+			)
+		}
+	}
+}
+
+// SendLowAttendanceAlerts alerts every student in an active enrollment
+// whose attendance in the course is below threshold, and returns how many
+// alerts were sent. Enrollments with no attendance recorded are skipped.
+func (aas *AttendanceAutomationService) SendLowAttendanceAlerts(threshold float64) (int, error) {
+	db := database.DB
+
+	var rows []struct {
+		StudentID uint
+		CourseID  uint
+		Total     int64
+		Present   int64
+	}
+	err := db.Model(&models.Enrollment{}).
+		Select("enrollments.student_id, enrollments.course_id, COUNT(attendances.id) AS total, "+
+			"COUNT(CASE WHEN attendances.status = 'present' THEN 1 END) AS present").
+		Joins("JOIN attendances ON attendances.student_id = enrollments.student_id AND attendances.course_id = enrollments.course_id").
+		Where("enrollments.status = ?", "active").
+		Group("enrollments.student_id, enrollments.course_id").
+		Scan(&rows).Error
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for _, r := range rows {
+		percentage := float64(r.Present) / float64(r.Total) * 100
+		if percentage < threshold {
+			aas.sendAttendanceAlert(r.StudentID, r.CourseID, percentage)
+			sent++
+		}
+	}
+	return sent, nil
+}
+
 // RecordAttendanceAndCheck records attendance and checks for low attendance
-func (aas *AttendanceAutomationService) RecordAttendanceAndCheck(attendance *models.Attendance, attendanceThreshold float64) error {
+func (aas *AttendanceAutomationService) RecordAttendanceAndCheck(ctx context.Context, attendance *models.Attendance, attendanceThreshold float64) error {
 	db := database.DB
 
 	// Create attendance record
-	if err := db.Create(attendance).Error; err != nil {
+	if err := db.WithContext(ctx).Create(attendance).Error; err != nil {
 		return err
 	}
 
@@ -1,6 +1,7 @@
 package service
 
 import (
+	"context"
 	"errors"
 	"school-management-system/internal/models"
 	"school-management-system/internal/repository"
@@ -11,14 +12,14 @@ import (
 )
 
 type AttendanceService interface {
-	RecordAttendance(attendance *models.Attendance) error
+	RecordAttendance(ctx context.Context, attendance *models.Attendance) error
 	GetAttendanceByID(id uint) (*models.Attendance, error)
 	GetStudentAttendance(studentID uint, page, limit int) ([]models.Attendance, int64, error)
 	GetCourseAttendance(courseID uint, page, limit int) ([]models.Attendance, int64, error)
 	GetStudentCourseAttendance(studentID, courseID uint, page, limit int) ([]models.Attendance, int64, error)
 	GetAllAttendance(page, limit int) ([]models.Attendance, int64, error)
-	UpdateAttendance(attendance *models.Attendance) error
-	DeleteAttendance(id uint) error
+	UpdateAttendance(ctx context.Context, attendance *models.Attendance) error
+	DeleteAttendance(ctx context.Context, id uint) error
 	GetAttendanceInRange(startDate, endDate time.Time) ([]models.Attendance, error)
 	GetStudentAttendanceStats(studentID, courseID uint) (present, absent, late int64, error error)
 	CalculateAttendancePercentage(studentID, courseID uint) (float64, error)
@@ -26,25 +27,27 @@ type AttendanceService interface {
 
 type attendanceService struct {
 	attendanceRepo repository.AttendanceRepository
+	notifier       NotificationDispatcher
 	logger         *logrus.Logger
 }
 
-func NewAttendanceService(attendanceRepo repository.AttendanceRepository) AttendanceService {
+func NewAttendanceService(attendanceRepo repository.AttendanceRepository, notifier NotificationDispatcher) AttendanceService {
 	return &attendanceService{
 		attendanceRepo: attendanceRepo,
+		notifier:       notifier,
 		logger:         logger.GetLogger(),
 	}
 }
 
-func (s *attendanceService) RecordAttendance(attendance *models.Attendance) error {
+func (s *attendanceService) RecordAttendance(ctx context.Context, attendance *models.Attendance) error {
 	if attendance.StudentID == 0 {
 		s.logger.Warn("Student ID is required for attendance")
 		return errors.New("student id is required")
 	}
 
-	if attendance.CourseID == 0 {
-		s.logger.Warn("Course ID is required for attendance")
-		return errors.New("course id is required")
+	if attendance.CourseID == 0 && (attendance.SectionID == nil || *attendance.SectionID == 0) {
+		s.logger.Warn("Course ID or section ID is required for attendance")
+		return errors.New("course id or section id is required")
 	}
 
 	validStatuses := map[string]bool{"present": true, "absent": true, "late": true, "excused": true}
@@ -57,13 +60,14 @@ func (s *attendanceService) RecordAttendance(attendance *models.Attendance) erro
 		attendance.Date = time.Now()
 	}
 
-	err := s.attendanceRepo.Create(attendance)
+	err := s.attendanceRepo.Create(ctx, attendance)
 	if err != nil {
 		s.logger.WithError(err).WithField("student_id", attendance.StudentID).WithField("course_id", attendance.CourseID).Error("Failed to record attendance")
 		return errors.New("failed to record attendance")
 	}
 
 	s.logger.WithField("student_id", attendance.StudentID).WithField("course_id", attendance.CourseID).WithField("status", attendance.Status).Info("Attendance recorded")
+	notifyAbsence(s.notifier, attendance)
 	return nil
 }
 
@@ -145,7 +149,7 @@ func (s *attendanceService) GetAllAttendance(page, limit int) ([]models.Attendan
 	return attendances, total, nil
 }
 
-func (s *attendanceService) UpdateAttendance(attendance *models.Attendance) error {
+func (s *attendanceService) UpdateAttendance(ctx context.Context, attendance *models.Attendance) error {
 	if attendance.ID == 0 {
 		return errors.New("attendance id is required")
 	}
@@ -155,7 +159,7 @@ func (s *attendanceService) UpdateAttendance(attendance *models.Attendance) erro
 		return errors.New("invalid attendance status")
 	}
 
-	err := s.attendanceRepo.Update(attendance)
+	err := s.attendanceRepo.Update(ctx, attendance)
 	if err != nil {
 		s.logger.WithError(err).WithField("id", attendance.ID).Error("Failed to update attendance")
 		return errors.New("failed to update attendance")
@@ -165,8 +169,8 @@ func (s *attendanceService) UpdateAttendance(attendance *models.Attendance) erro
 	return nil
 }
 
-func (s *attendanceService) DeleteAttendance(id uint) error {
-	err := s.attendanceRepo.Delete(id)
+func (s *attendanceService) DeleteAttendance(ctx context.Context, id uint) error {
+	err := s.attendanceRepo.Delete(ctx, id)
 	if err != nil {
 		s.logger.WithError(err).WithField("id", id).Error("Failed to delete attendance")
 		return errors.New("failed to delete attendance")
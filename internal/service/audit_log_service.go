@@ -11,6 +11,7 @@ type AuditLogService interface {
 	GetByUserID(userID uint, page, limit int) ([]models.AuditLog, int64, error)
 	GetByEntity(entity string, entityID uint) ([]models.AuditLog, error)
 	GetAll(page, limit int) ([]models.AuditLog, int64, error)
+	Search(filter repository.AuditLogFilter, page, limit int) ([]models.AuditLog, int64, error)
 	CleanupOldLogs(days int) error
 }
 
@@ -42,6 +43,10 @@ func (s *auditLogService) GetAll(page, limit int) ([]models.AuditLog, int64, err
 	return s.repo.FindAll(page, limit)
 }
 
+func (s *auditLogService) Search(filter repository.AuditLogFilter, page, limit int) ([]models.AuditLog, int64, error) {
+	return s.repo.Search(filter, page, limit)
+}
+
 func (s *auditLogService) CleanupOldLogs(days int) error {
 	return s.repo.DeleteOlderThan(days)
 }
@@ -1,73 +1,299 @@
 package service
 
 import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"school-management-system/internal/models"
 	"school-management-system/internal/repository"
 	"school-management-system/pkg/logger"
+	"school-management-system/pkg/utils"
 	"strings"
 	"time"
 
 	"gorm.io/gorm"
 
 	"github.com/dgrijalva/jwt-go"
+	"github.com/google/uuid"
 	"github.com/sirupsen/logrus"
 )
 
+// TokenPair is what a successful login or refresh returns: a short-lived
+// access token for API calls and a refresh token to obtain the next pair.
+type TokenPair struct {
+	AccessToken  string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"` // access token lifetime in seconds
+}
+
+// ClientInfo identifies where a session was started from.
+type ClientInfo struct {
+	IPAddress string
+	UserAgent string
+}
+
+// LoginResult is the outcome of a login step. Either Tokens is set, or the
+// user must present a second factor using MFAToken.
+type LoginResult struct {
+	Tokens *TokenPair
+	User   *models.User
+	// MFAToken is a short-lived token that can only be exchanged for a
+	// token pair together with a valid TOTP or recovery code
+	MFAToken string
+	// MFAEnrollmentRequired means the user's role requires MFA but they have
+	// not enrolled; they must enroll with MFAToken to finish signing in
+	MFAEnrollmentRequired bool
+	// RecoveryCodes are returned once, when enrollment completes at login
+	RecoveryCodes []string
+}
+
+// AccountOptions configures the password reset and email verification flows.
+type AccountOptions struct {
+	BaseURL                  string // used to build the links sent by email
+	ResetTokenExpiry         time.Duration
+	VerificationTokenExpiry  time.Duration
+	RequireEmailVerification bool // reject logins until the email is verified
+}
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token reuse detected")
+	ErrTokenRevoked        = errors.New("token has been revoked")
+	ErrInvalidAccountToken = errors.New("invalid or expired token")
+	ErrIncorrectPassword   = errors.New("current password is incorrect")
+	ErrEmailNotVerified    = errors.New("email address has not been verified")
+	ErrAlreadyVerified     = errors.New("email address is already verified")
+	ErrInvalidMFAToken     = errors.New("invalid or expired two-factor login token")
+)
+
+const (
+	mfaTokenType   = "mfa_pending"
+	mfaTokenExpiry = 5 * time.Minute
+)
+
 type AuthService interface {
-	Login(email, password string) (string, *models.User, error)
-	Register(user *models.User) error
+	Login(email, password string, client ClientInfo) (*LoginResult, error)
+	BeginMFAEnrollment(mfaToken string) (*MFAEnrollment, error)
+	CompleteMFALogin(mfaToken, code string, client ClientInfo) (*LoginResult, error)
+	Register(ctx context.Context, user *models.User) error
 	GenerateToken(user *models.User) (string, error)
 	ValidateToken(tokenString string) (*jwt.Token, error)
-	RefreshToken(tokenString string) (string, error)
+	RefreshToken(refreshToken string, client ClientInfo) (*TokenPair, error)
+	Logout(claims jwt.MapClaims, refreshToken string) error
+	CheckRevoked(claims jwt.MapClaims) error
+	RevokeUserSessions(userID uint) error
+	PurgeExpiredTokens() (int64, error)
+	RequestPasswordReset(email string) error
+	ResetPassword(ctx context.Context, token, newPassword string) error
+	ChangePassword(ctx context.Context, userID uint, currentPassword, newPassword string) error
+	SendVerificationEmail(userID uint) error
+	VerifyEmail(ctx context.Context, token string) error
 }
 
 type authService struct {
-	userRepo  repository.UserRepository
-	jwtSecret string
-	jwtExpiry int
-	logger    *logrus.Logger
+	userRepo      repository.UserRepository
+	tokenRepo     repository.TokenRepository
+	jwtSecret     string
+	accessExpiry  time.Duration
+	refreshExpiry time.Duration
+	emailService  *EmailService
+	mfaService    MFAService
+	protection    LoginProtectionService
+	policy        *utils.PasswordPolicy
+	account       AccountOptions
+	logger        *logrus.Logger
 }
 
-func NewAuthService(userRepo repository.UserRepository, jwtSecret string, jwtExpiry int) AuthService {
+func NewAuthService(
+	userRepo repository.UserRepository,
+	tokenRepo repository.TokenRepository,
+	emailService *EmailService,
+	mfaService MFAService,
+	protection LoginProtectionService,
+	policy *utils.PasswordPolicy,
+	jwtSecret string,
+	accessExpiry, refreshExpiry time.Duration,
+	account AccountOptions,
+) AuthService {
 	return &authService{
-		userRepo:  userRepo,
-		jwtSecret: jwtSecret,
-		jwtExpiry: jwtExpiry,
-		logger:    logger.GetLogger(),
+		userRepo:      userRepo,
+		tokenRepo:     tokenRepo,
+		jwtSecret:     jwtSecret,
+		accessExpiry:  accessExpiry,
+		refreshExpiry: refreshExpiry,
+		emailService:  emailService,
+		mfaService:    mfaService,
+		protection:    protection,
+		policy:        policy,
+		account:       account,
+		logger:        logger.GetLogger(),
 	}
 }
 
-func (s *authService) Login(email, password string) (string, *models.User, error) {
+func (s *authService) Login(email, password string, client ClientInfo) (*LoginResult, error) {
 	s.logger.WithField("email", email).Info("Login attempt")
 
 	user, err := s.userRepo.FindByEmail(email)
+	attempt := LoginAttempt{Email: email, IPAddress: client.IPAddress}
+	if err == nil {
+		attempt.UserID = user.ID
+	}
+	if err := s.protection.Check(attempt); err != nil {
+		s.logger.WithField("email", email).Warn("Login blocked by lockout")
+		return nil, err
+	}
+
 	if err != nil {
 		s.logger.WithError(err).WithField("email", email).Warn("User not found")
-		return "", nil, errors.New("invalid credentials")
+		s.protection.RecordFailure(attempt, "unknown email")
+		return nil, errors.New("invalid credentials")
 	}
 
 	if !user.CheckPassword(password) {
 		s.logger.WithField("email", email).Warn("Invalid password")
-		return "", nil, errors.New("invalid credentials")
+		s.protection.RecordFailure(attempt, "invalid password")
+		return nil, errors.New("invalid credentials")
 	}
 
 	if !user.IsActive {
 		s.logger.WithField("email", email).Warn("Inactive account login attempt")
-		return "", nil, errors.New("account is inactive")
+		return nil, errors.New("account is inactive")
+	}
+
+	if s.account.RequireEmailVerification && !user.EmailVerified {
+		s.logger.WithField("email", email).Warn("Unverified account login attempt")
+		return nil, ErrEmailNotVerified
+	}
+
+	enabled := s.mfaService.IsEnabled(user.ID)
+	if enabled || s.mfaService.IsRequired(user.Role) {
+		mfaToken, err := s.generateMFAToken(user)
+		if err != nil {
+			s.logger.WithError(err).Error("Failed to generate MFA token")
+			return nil, errors.New("failed to generate token")
+		}
+		s.logger.WithField("email", email).Info("Password accepted; second factor required")
+		return &LoginResult{User: user, MFAToken: mfaToken, MFAEnrollmentRequired: !enabled}, nil
 	}
 
-	token, err := s.GenerateToken(user)
+	pair, err := s.issueTokens(user, uuid.New().String(), client, nil)
 	if err != nil {
 		s.logger.WithError(err).Error("Failed to generate token")
-		return "", nil, errors.New("failed to generate token")
+		return nil, errors.New("failed to generate token")
 	}
 
+	s.protection.RecordSuccess(attempt)
 	s.logger.WithField("email", email).Info("Login successful")
-	return token, user, nil
+	return &LoginResult{Tokens: pair, User: user}, nil
 }
 
-func (s *authService) Register(user *models.User) error {
+// BeginMFAEnrollment starts TOTP enrollment for a user who must enroll
+// before their login can complete.
+func (s *authService) BeginMFAEnrollment(mfaToken string) (*MFAEnrollment, error) {
+	user, _, err := s.parseMFAToken(mfaToken)
+	if err != nil {
+		return nil, err
+	}
+	return s.mfaService.BeginEnrollment(user)
+}
+
+// CompleteMFALogin finishes a login that returned an MFA token. For users
+// enrolling at login the code confirms the enrollment and the recovery codes
+// are returned with the tokens.
+func (s *authService) CompleteMFALogin(mfaToken, code string, client ClientInfo) (*LoginResult, error) {
+	user, claims, err := s.parseMFAToken(mfaToken)
+	if err != nil {
+		return nil, err
+	}
+
+	// Second-factor guesses count towards the same lockout as passwords;
+	// the count is only cleared once the whole login succeeds
+	attempt := LoginAttempt{Email: user.Email, IPAddress: client.IPAddress, UserID: user.ID}
+	if err := s.protection.Check(attempt); err != nil {
+		return nil, err
+	}
+
+	result := &LoginResult{User: user}
+	if s.mfaService.IsEnabled(user.ID) {
+		err = s.mfaService.Verify(user.ID, code)
+	} else {
+		result.RecoveryCodes, err = s.mfaService.ConfirmEnrollment(user.ID, code)
+	}
+	if err != nil {
+		s.logger.WithField("user_id", user.ID).Warn("Invalid second factor")
+		s.protection.RecordFailure(attempt, "invalid second factor")
+		return nil, err
+	}
+
+	// The MFA token is single use
+	if err := s.Logout(claims, ""); err != nil {
+		return nil, err
+	}
+
+	result.Tokens, err = s.issueTokens(user, uuid.New().String(), client, nil)
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to generate token")
+		return nil, errors.New("failed to generate token")
+	}
+
+	s.protection.RecordSuccess(attempt)
+	s.logger.WithField("user_id", user.ID).Info("Login successful with second factor")
+	return result, nil
+}
+
+// generateMFAToken signs the pending-login token with a key derived from the
+// JWT secret, so it can never pass ValidateToken as an access token.
+func (s *authService) generateMFAToken(user *models.User) (string, error) {
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"user_id": user.ID,
+		"typ":     mfaTokenType,
+		"jti":     uuid.New().String(),
+		"exp":     now.Add(mfaTokenExpiry).Unix(),
+		"iat":     now.Unix(),
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString(s.mfaSigningKey())
+}
+
+func (s *authService) parseMFAToken(tokenString string) (*models.User, jwt.MapClaims, error) {
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, errors.New("unexpected signing method")
+		}
+		return s.mfaSigningKey(), nil
+	})
+	if err != nil || !token.Valid {
+		return nil, nil, ErrInvalidMFAToken
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || claims["typ"] != mfaTokenType {
+		return nil, nil, ErrInvalidMFAToken
+	}
+
+	jti, _ := claims["jti"].(string)
+	if revoked, err := s.tokenRepo.IsAccessTokenRevoked(jti); err != nil || revoked {
+		return nil, nil, ErrInvalidMFAToken
+	}
+
+	userID, _ := claims["user_id"].(float64)
+	user, err := s.userRepo.FindByID(uint(userID))
+	if err != nil || !user.IsActive {
+		return nil, nil, ErrInvalidMFAToken
+	}
+	return user, claims, nil
+}
+
+func (s *authService) mfaSigningKey() []byte {
+	return []byte(s.jwtSecret + ":" + mfaTokenType)
+}
+
+func (s *authService) Register(ctx context.Context, user *models.User) error {
 	// Normalize email
 	user.Email = strings.ToLower(strings.TrimSpace(user.Email))
 
@@ -81,29 +307,96 @@ func (s *authService) Register(user *models.User) error {
 		return errors.New("failed to register user")
 	}
 
-	err = s.userRepo.Create(user)
+	if err := s.policy.Validate(user.Password, user.Email); err != nil {
+		return err
+	}
+
+	user.EmailVerified = false
+	user.EmailVerifiedAt = nil
+	err = s.userRepo.Create(ctx, user)
 	if err != nil {
 		s.logger.WithError(err).WithField("email", user.Email).Error("Failed to register user")
 		return errors.New("failed to register user")
 	}
+	s.recordPasswordHistory(user)
+
+	if err := s.sendVerification(user); err != nil {
+		s.logger.WithError(err).WithField("email", user.Email).Warn("Failed to send verification email")
+	}
 
 	s.logger.WithField("email", user.Email).WithField("role", user.Role).Info("User registered successfully")
 	return nil
 }
 
 func (s *authService) GenerateToken(user *models.User) (string, error) {
+	now := time.Now()
 	claims := jwt.MapClaims{
 		"user_id": user.ID,
 		"email":   user.Email,
 		"role":    user.Role,
-		"exp":     time.Now().Add(time.Hour * time.Duration(s.jwtExpiry)).Unix(),
-		"iat":     time.Now().Unix(),
+		"jti":     uuid.New().String(),
+		"exp":     now.Add(s.accessExpiry).Unix(),
+		"iat":     now.Unix(),
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	return token.SignedString([]byte(s.jwtSecret))
 }
 
+// issueTokens creates an access token and a refresh token in the given
+// family. When rotating, previous is the refresh token being replaced.
+func (s *authService) issueTokens(user *models.User, familyID string, client ClientInfo, previous *models.RefreshToken) (*TokenPair, error) {
+	access, err := s.GenerateToken(user)
+	if err != nil {
+		return nil, err
+	}
+
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, err
+	}
+	refresh := base64.RawURLEncoding.EncodeToString(raw)
+
+	now := time.Now()
+	record := &models.RefreshToken{
+		UserID:    user.ID,
+		TokenHash: hashToken(refresh),
+		FamilyID:  familyID,
+		ExpiresAt: now.Add(s.refreshExpiry).Unix(),
+		IPAddress: client.IPAddress,
+		UserAgent: truncate(client.UserAgent, 255),
+		CreatedAt: now.Unix(),
+	}
+
+	if previous == nil {
+		err = s.tokenRepo.CreateRefreshToken(record)
+	} else {
+		err = s.tokenRepo.RotateRefreshToken(previous, record, now.Unix())
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{
+		AccessToken:  access,
+		RefreshToken: refresh,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(s.accessExpiry.Seconds()),
+	}, nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
+
 func (s *authService) ValidateToken(tokenString string) (*jwt.Token, error) {
 	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
@@ -120,22 +413,358 @@ func (s *authService) ValidateToken(tokenString string) (*jwt.Token, error) {
 	return token, nil
 }
 
-func (s *authService) RefreshToken(tokenString string) (string, error) {
-	token, err := s.ValidateToken(tokenString)
+// RefreshToken exchanges a refresh token for a new token pair, rotating the
+// refresh token. Presenting a token that was already rotated revokes every
+// token in its family, since either the client or an attacker holds a copy.
+func (s *authService) RefreshToken(refreshToken string, client ClientInfo) (*TokenPair, error) {
+	record, err := s.tokenRepo.FindRefreshTokenByHash(hashToken(refreshToken))
 	if err != nil {
-		return "", err
+		return nil, ErrInvalidRefreshToken
 	}
 
-	claims, ok := token.Claims.(jwt.MapClaims)
-	if !ok || !token.Valid {
-		return "", errors.New("invalid token claims")
+	now := time.Now().Unix()
+	if record.RevokedAt != 0 {
+		if record.ReplacedByID != nil {
+			s.logger.WithFields(logrus.Fields{
+				"user_id":   record.UserID,
+				"family_id": record.FamilyID,
+				"ip":        client.IPAddress,
+			}).Warn("Rotated refresh token reused; revoking token family")
+			if err := s.tokenRepo.RevokeFamily(record.FamilyID, now); err != nil {
+				s.logger.WithError(err).Error("Failed to revoke refresh token family")
+			}
+			return nil, ErrRefreshTokenReused
+		}
+		return nil, ErrInvalidRefreshToken
+	}
+	if record.ExpiresAt < now {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	user, err := s.userRepo.FindByID(record.UserID)
+	if err != nil || !user.IsActive {
+		s.tokenRepo.RevokeFamily(record.FamilyID, now)
+		return nil, ErrInvalidRefreshToken
+	}
+
+	pair, err := s.issueTokens(user, record.FamilyID, client, record)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// Lost a race with a concurrent refresh of the same token
+			return nil, ErrRefreshTokenReused
+		}
+		s.logger.WithError(err).Error("Failed to rotate refresh token")
+		return nil, errors.New("failed to refresh token")
+	}
+	return pair, nil
+}
+
+// Logout revokes the presented access token and, if given, the refresh
+// token's family.
+func (s *authService) Logout(claims jwt.MapClaims, refreshToken string) error {
+	now := time.Now().Unix()
+
+	if jti, _ := claims["jti"].(string); jti != "" {
+		exp, _ := claims["exp"].(float64)
+		userID, _ := claims["user_id"].(float64)
+		if err := s.tokenRepo.RevokeAccessToken(&models.RevokedToken{
+			JTI:       jti,
+			UserID:    uint(userID),
+			ExpiresAt: int64(exp),
+			CreatedAt: now,
+		}); err != nil {
+			s.logger.WithError(err).Error("Failed to revoke access token")
+			return errors.New("failed to logout")
+		}
+	}
+
+	if refreshToken != "" {
+		record, err := s.tokenRepo.FindRefreshTokenByHash(hashToken(refreshToken))
+		if err == nil {
+			userID, _ := claims["user_id"].(float64)
+			if record.UserID != uint(userID) {
+				return ErrInvalidRefreshToken
+			}
+			if err := s.tokenRepo.RevokeFamily(record.FamilyID, now); err != nil {
+				s.logger.WithError(err).Error("Failed to revoke refresh token")
+				return errors.New("failed to logout")
+			}
+		}
+	}
+	return nil
+}
+
+// CheckRevoked rejects access tokens that were logged out, that predate
+// token IDs, or whose user has been deactivated.
+func (s *authService) CheckRevoked(claims jwt.MapClaims) error {
+	jti, _ := claims["jti"].(string)
+	if jti == "" {
+		return ErrTokenRevoked
+	}
+
+	revoked, err := s.tokenRepo.IsAccessTokenRevoked(jti)
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to check token revocation")
+		return ErrTokenRevoked
+	}
+	if revoked {
+		return ErrTokenRevoked
+	}
+
+	userID, _ := claims["user_id"].(float64)
+	user, err := s.userRepo.FindByID(uint(userID))
+	if err != nil || !user.IsActive {
+		return ErrTokenRevoked
+	}
+	return nil
+}
+
+// RevokeUserSessions revokes every refresh token of a user, e.g. when the
+// account is deactivated. Outstanding access tokens expire on their own and
+// are rejected sooner by CheckRevoked if the user is inactive.
+func (s *authService) RevokeUserSessions(userID uint) error {
+	return s.tokenRepo.RevokeAllForUser(userID, time.Now().Unix())
+}
+
+func (s *authService) PurgeExpiredTokens() (int64, error) {
+	return s.tokenRepo.PurgeExpired(time.Now().Unix())
+}
+
+// RequestPasswordReset emails a single-use reset link. It reports success
+// for unknown or inactive accounts too, so the endpoint cannot be used to
+// find out which emails are registered.
+func (s *authService) RequestPasswordReset(email string) error {
+	email = strings.ToLower(strings.TrimSpace(email))
+	user, err := s.userRepo.FindByEmail(email)
+	if err != nil || !user.IsActive {
+		s.logger.WithField("email", email).Info("Password reset requested for unknown or inactive account")
+		return nil
+	}
+
+	now := time.Now()
+	if err := s.tokenRepo.InvalidateAccountTokens(user.ID, models.TokenPurposePasswordReset, now.Unix()); err != nil {
+		s.logger.WithError(err).Error("Failed to invalidate previous reset tokens")
+		return errors.New("failed to request password reset")
+	}
+
+	token, err := s.issueAccountToken(user.ID, models.TokenPurposePasswordReset, s.account.ResetTokenExpiry)
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to create reset token")
+		return errors.New("failed to request password reset")
 	}
 
-	userID := uint(claims["user_id"].(float64))
+	link := s.account.BaseURL + "/reset-password?token=" + token
+	if err := s.emailService.SendPasswordResetEmail(UserRecipient(user), user.FirstName, link, int(s.account.ResetTokenExpiry.Minutes())); err != nil {
+		s.logger.WithError(err).WithField("email", user.Email).Error("Failed to send reset email")
+		return errors.New("failed to send password reset email")
+	}
+
+	s.logger.WithField("user_id", user.ID).Info("Password reset email sent")
+	return nil
+}
+
+// ResetPassword sets a new password using a token from RequestPasswordReset
+// and signs the user out everywhere.
+func (s *authService) ResetPassword(ctx context.Context, token, newPassword string) error {
+	record, user, err := s.redeemableToken(models.TokenPurposePasswordReset, token)
+	if err != nil {
+		return err
+	}
+	if !user.IsActive {
+		return ErrInvalidAccountToken
+	}
+
+	if err := s.checkNewPassword(user, newPassword); err != nil {
+		return err
+	}
+
+	now := time.Now().Unix()
+	if err := s.tokenRepo.ConsumeAccountToken(record.ID, now); err != nil {
+		return ErrInvalidAccountToken
+	}
+
+	if err := s.setPassword(ctx, user, newPassword); err != nil {
+		return err
+	}
+	s.tokenRepo.InvalidateAccountTokens(user.ID, models.TokenPurposePasswordReset, now)
+
+	// Following the emailed link also proves ownership of the address
+	if !user.EmailVerified {
+		s.markVerified(ctx, user)
+	}
+
+	s.logger.WithField("user_id", user.ID).Info("Password reset")
+	return nil
+}
+
+// ChangePassword sets a new password for a signed-in user after checking
+// the current one.
+func (s *authService) ChangePassword(ctx context.Context, userID uint, currentPassword, newPassword string) error {
 	user, err := s.userRepo.FindByID(userID)
 	if err != nil {
-		return "", errors.New("user not found")
+		return errors.New("user not found")
+	}
+	if !user.CheckPassword(currentPassword) {
+		s.logger.WithField("user_id", userID).Warn("Change password with wrong current password")
+		return ErrIncorrectPassword
+	}
+
+	if err := s.checkNewPassword(user, newPassword); err != nil {
+		return err
+	}
+	if err := s.setPassword(ctx, user, newPassword); err != nil {
+		return err
 	}
 
-	return s.GenerateToken(user)
+	s.logger.WithField("user_id", userID).Info("Password changed")
+	return nil
+}
+
+// SendVerificationEmail (re)sends the email verification link.
+func (s *authService) SendVerificationEmail(userID uint) error {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return errors.New("user not found")
+	}
+	if user.EmailVerified {
+		return ErrAlreadyVerified
+	}
+	if err := s.sendVerification(user); err != nil {
+		s.logger.WithError(err).WithField("user_id", userID).Error("Failed to send verification email")
+		return errors.New("failed to send verification email")
+	}
+	return nil
+}
+
+func (s *authService) VerifyEmail(ctx context.Context, token string) error {
+	record, user, err := s.redeemableToken(models.TokenPurposeEmailVerification, token)
+	if err != nil {
+		return err
+	}
+	if err := s.tokenRepo.ConsumeAccountToken(record.ID, time.Now().Unix()); err != nil {
+		return ErrInvalidAccountToken
+	}
+	if user.EmailVerified {
+		return nil
+	}
+	if err := s.markVerified(ctx, user); err != nil {
+		return errors.New("failed to verify email")
+	}
+	s.logger.WithField("user_id", user.ID).Info("Email verified")
+	return nil
+}
+
+func (s *authService) sendVerification(user *models.User) error {
+	now := time.Now().Unix()
+	if err := s.tokenRepo.InvalidateAccountTokens(user.ID, models.TokenPurposeEmailVerification, now); err != nil {
+		return err
+	}
+	token, err := s.issueAccountToken(user.ID, models.TokenPurposeEmailVerification, s.account.VerificationTokenExpiry)
+	if err != nil {
+		return err
+	}
+	link := s.account.BaseURL + "/api/auth/verify-email?token=" + token
+	return s.emailService.SendVerificationEmail(UserRecipient(user), user.FirstName, link)
+}
+
+func (s *authService) markVerified(ctx context.Context, user *models.User) error {
+	now := time.Now()
+	user.EmailVerified = true
+	user.EmailVerifiedAt = &now
+	return s.userRepo.Update(ctx, user)
+}
+
+// issueAccountToken stores the hash of a new random token and returns the
+// token itself, which is only ever sent to the user.
+func (s *authService) issueAccountToken(userID uint, purpose string, expiry time.Duration) (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(raw)
+
+	now := time.Now()
+	err := s.tokenRepo.CreateAccountToken(&models.AccountToken{
+		UserID:    userID,
+		Purpose:   purpose,
+		TokenHash: hashToken(token),
+		ExpiresAt: now.Add(expiry).Unix(),
+		CreatedAt: now.Unix(),
+	})
+	return token, err
+}
+
+// redeemableToken looks up an unused, unexpired token and its user.
+func (s *authService) redeemableToken(purpose, token string) (*models.AccountToken, *models.User, error) {
+	if token == "" {
+		return nil, nil, ErrInvalidAccountToken
+	}
+	record, err := s.tokenRepo.FindAccountToken(purpose, hashToken(token))
+	if err != nil || record.UsedAt != 0 || record.ExpiresAt < time.Now().Unix() {
+		return nil, nil, ErrInvalidAccountToken
+	}
+	user, err := s.userRepo.FindByID(record.UserID)
+	if err != nil {
+		return nil, nil, ErrInvalidAccountToken
+	}
+	return record, user, nil
+}
+
+// checkNewPassword applies the password policy, including reuse of the
+// current password and of the ones before it.
+func (s *authService) checkNewPassword(user *models.User, newPassword string) error {
+	if err := s.policy.Validate(newPassword, user.Email); err != nil {
+		return err
+	}
+	if user.CheckPassword(newPassword) {
+		return errors.New("new password must be different from the current password")
+	}
+	if s.policy.HistorySize > 0 {
+		history, err := s.tokenRepo.FindPasswordHistory(user.ID, s.policy.HistorySize)
+		if err != nil {
+			s.logger.WithError(err).Error("Failed to load password history")
+			return errors.New("failed to update password")
+		}
+		hashes := make([]string, len(history))
+		for i, h := range history {
+			hashes[i] = h.PasswordHash
+		}
+		if err := s.policy.CheckHistory(newPassword, hashes); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setPassword saves a password that already passed checkNewPassword,
+// records it in the history, signs out every session and notifies the user.
+func (s *authService) setPassword(ctx context.Context, user *models.User, newPassword string) error {
+	user.Password = newPassword
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		s.logger.WithError(err).WithField("user_id", user.ID).Error("Failed to update password")
+		return errors.New("failed to update password")
+	}
+	s.recordPasswordHistory(user)
+
+	if err := s.RevokeUserSessions(user.ID); err != nil {
+		s.logger.WithError(err).WithField("user_id", user.ID).Error("Failed to revoke sessions after password change")
+	}
+	if err := s.emailService.SendPasswordChangedNotification(UserRecipient(user), user.FirstName); err != nil {
+		s.logger.WithError(err).WithField("user_id", user.ID).Warn("Failed to send password changed notification")
+	}
+	return nil
+}
+
+// recordPasswordHistory stores the user's current (hashed) password.
+func (s *authService) recordPasswordHistory(user *models.User) {
+	if s.policy.HistorySize <= 0 {
+		return
+	}
+	if err := s.tokenRepo.AddPasswordHistory(&models.PasswordHistory{
+		UserID:       user.ID,
+		PasswordHash: user.Password,
+		CreatedAt:    time.Now().Unix(),
+	}); err != nil {
+		s.logger.WithError(err).WithField("user_id", user.ID).Warn("Failed to record password history")
+	}
 }
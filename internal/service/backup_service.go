@@ -1,30 +1,230 @@
 package service
 
 import (
+	"errors"
+	"fmt"
+	"os"
+	"school-management-system/internal/backup"
 	"school-management-system/internal/models"
 	"school-management-system/internal/repository"
+	"school-management-system/pkg/logger"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
 )
 
+var ErrBackupRunning = errors.New("a backup is already running")
+
+// BackupOptions configures where backups are written and how long they are
+// kept. The newest RetentionCount completed backups are always kept; older
+// ones are deleted once they are more than RetentionDays old. Zero disables
+// either rule.
+type BackupOptions struct {
+	Dir            string
+	RetentionCount int
+	RetentionDays  int
+}
+
 type BackupService interface {
-	Create(backup *models.Backup) error
+	Start(createdBy *uint, trigger, description string) (*models.Backup, error)
+	Run(createdBy *uint, trigger, description string) (*models.Backup, error)
+	Verify(id uint) (*backup.Manifest, error)
+	ApplyRetention() error
+	MarkInterrupted() error
 	GetByID(id uint) (*models.Backup, error)
 	GetAll(page, limit int) ([]models.Backup, int64, error)
 	GetByStatus(status string) ([]models.Backup, error)
-	Update(backup *models.Backup) error
 	Delete(id uint) error
 	GetLatestCompleted() (*models.Backup, error)
 }
 
 type backupService struct {
-	repo repository.BackupRepository
+	repo    repository.BackupRepository
+	db      *gorm.DB
+	options BackupOptions
+	running sync.Mutex
+	logger  *logrus.Logger
+}
+
+func NewBackupService(repo repository.BackupRepository, db *gorm.DB, options BackupOptions) BackupService {
+	return &backupService{
+		repo:    repo,
+		db:      db,
+		options: options,
+		logger:  logger.GetLogger(),
+	}
+}
+
+// Start records a pending backup and writes it in the background. Only one
+// backup runs at a time.
+func (s *backupService) Start(createdBy *uint, trigger, description string) (*models.Backup, error) {
+	if !s.running.TryLock() {
+		return nil, ErrBackupRunning
+	}
+
+	record, err := s.newRecord(createdBy, trigger, description)
+	if err != nil {
+		s.running.Unlock()
+		return nil, err
+	}
+
+	go func() {
+		defer s.running.Unlock()
+		s.write(record)
+	}()
+	return record, nil
+}
+
+// Run writes a backup and waits for it to finish
+func (s *backupService) Run(createdBy *uint, trigger, description string) (*models.Backup, error) {
+	if !s.running.TryLock() {
+		return nil, ErrBackupRunning
+	}
+	defer s.running.Unlock()
+
+	record, err := s.newRecord(createdBy, trigger, description)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.write(record); err != nil {
+		return record, err
+	}
+	return record, nil
+}
+
+func (s *backupService) newRecord(createdBy *uint, trigger, description string) (*models.Backup, error) {
+	now := time.Now()
+	format := backup.FormatFor(s.db)
+	record := &models.Backup{
+		Description: description,
+		Format:      format,
+		Trigger:     trigger,
+		Status:      models.BackupStatusPending,
+		CreatedBy:   createdBy,
+		CreatedAt:   now.Unix(),
+	}
+	if err := s.repo.Create(record); err != nil {
+		return nil, errors.New("failed to create backup record")
+	}
+
+	record.BackupName = fmt.Sprintf("backup-%s-%d.%s.gz", now.UTC().Format("20060102-150405"), record.ID, extension(format))
+	if err := s.repo.Update(record); err != nil {
+		return nil, errors.New("failed to create backup record")
+	}
+	return record, nil
 }
 
-func NewBackupService(repo repository.BackupRepository) BackupService {
-	return &backupService{repo: repo}
+func (s *backupService) write(record *models.Backup) error {
+	log := s.logger.WithFields(logrus.Fields{"backup_id": record.ID, "format": record.Format})
+	record.Status = models.BackupStatusRunning
+	s.repo.Update(record)
+
+	start := time.Now()
+	result, err := backup.Create(s.db, record.Format, s.options.Dir, record.BackupName)
+	if err != nil {
+		record.Status = models.BackupStatusFailed
+		record.Error = err.Error()
+		s.repo.Update(record)
+		log.WithError(err).Error("Backup failed")
+		return err
+	}
+
+	record.Status = models.BackupStatusCompleted
+	record.Location = result.Path
+	record.Size = result.Size
+	record.Checksum = result.Checksum
+	record.CompletedAt = time.Now().Unix()
+	if err := s.repo.Update(record); err != nil {
+		log.WithError(err).Error("Failed to record completed backup")
+		return err
+	}
+	log.WithFields(logrus.Fields{"size": result.Size, "duration": time.Since(start).String()}).Info("Backup completed")
+
+	if err := s.ApplyRetention(); err != nil {
+		log.WithError(err).Warn("Failed to apply backup retention")
+	}
+	return nil
 }
 
-func (s *backupService) Create(backup *models.Backup) error {
-	return s.repo.Create(backup)
+// Verify checks a completed backup's file against its checksum and reads
+// it through, recording when it was last verified.
+func (s *backupService) Verify(id uint) (*backup.Manifest, error) {
+	record, err := s.repo.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if record.Status != models.BackupStatusCompleted {
+		return nil, errors.New("backup is not completed")
+	}
+
+	manifest, err := backup.Verify(record.Location, record.Checksum)
+	if err != nil {
+		return nil, err
+	}
+	record.VerifiedAt = time.Now().Unix()
+	s.repo.Update(record)
+	return manifest, nil
+}
+
+// ApplyRetention deletes completed backups that fall outside the retention
+// rules, and failed backups older than a day.
+func (s *backupService) ApplyRetention() error {
+	completed, err := s.repo.FindByStatus(models.BackupStatusCompleted)
+	if err != nil {
+		return err
+	}
+
+	cutoff := time.Now().AddDate(0, 0, -s.options.RetentionDays).Unix()
+	for i, b := range completed {
+		// Newest first
+		if s.options.RetentionCount > 0 && i < s.options.RetentionCount {
+			continue
+		}
+		if s.options.RetentionDays > 0 && b.CreatedAt >= cutoff {
+			continue
+		}
+		if s.options.RetentionCount == 0 && s.options.RetentionDays == 0 {
+			break
+		}
+		if err := s.Delete(b.ID); err != nil {
+			return err
+		}
+	}
+
+	failed, err := s.repo.FindByStatus(models.BackupStatusFailed)
+	if err != nil {
+		return err
+	}
+	dayAgo := time.Now().Add(-24 * time.Hour).Unix()
+	for _, b := range failed {
+		if b.CreatedAt < dayAgo {
+			if err := s.Delete(b.ID); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// MarkInterrupted fails backups left pending or running by a previous
+// process, e.g. after a crash.
+func (s *backupService) MarkInterrupted() error {
+	for _, status := range []string{models.BackupStatusPending, models.BackupStatusRunning} {
+		stale, err := s.repo.FindByStatus(status)
+		if err != nil {
+			return err
+		}
+		for i := range stale {
+			stale[i].Status = models.BackupStatusFailed
+			stale[i].Error = "interrupted by server restart"
+			if err := s.repo.Update(&stale[i]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
 }
 
 func (s *backupService) GetByID(id uint) (*models.Backup, error) {
@@ -39,14 +239,30 @@ func (s *backupService) GetByStatus(status string) ([]models.Backup, error) {
 	return s.repo.FindByStatus(status)
 }
 
-func (s *backupService) Update(backup *models.Backup) error {
-	return s.repo.Update(backup)
-}
-
+// Delete removes a backup record and its file
 func (s *backupService) Delete(id uint) error {
+	record, err := s.repo.FindByID(id)
+	if err != nil {
+		return err
+	}
+	if record.Status == models.BackupStatusPending || record.Status == models.BackupStatusRunning {
+		return ErrBackupRunning
+	}
+	if record.Location != "" {
+		if err := os.Remove(record.Location); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
 	return s.repo.Delete(id)
 }
 
 func (s *backupService) GetLatestCompleted() (*models.Backup, error) {
 	return s.repo.GetLatestCompleted()
 }
+
+func extension(format string) string {
+	if format == backup.FormatSQLite {
+		return "db"
+	}
+	return "ndjson"
+}
@@ -0,0 +1,207 @@
+package service
+
+import (
+	"errors"
+	"school-management-system/internal/models"
+	"school-management-system/internal/repository"
+	"school-management-system/pkg/logger"
+
+	"github.com/sirupsen/logrus"
+)
+
+type CourseSectionService interface {
+	CreateSection(section *models.CourseSection) error
+	GetSectionByID(id uint) (*models.CourseSection, error)
+	GetTermSections(termID uint, page, limit int) ([]models.CourseSection, int64, error)
+	GetCourseSections(courseID uint) ([]models.CourseSection, error)
+	GetTeacherSections(teacherID, termID uint) ([]models.CourseSection, error)
+	UpdateSection(section *models.CourseSection) error
+	DeleteSection(id uint) error
+	GetSeatsRemaining(section *models.CourseSection) (int, error)
+}
+
+type courseSectionService struct {
+	sectionRepo repository.CourseSectionRepository
+	courseRepo  repository.CourseRepository
+	termRepo    repository.AcademicTermRepository
+	logger      *logrus.Logger
+}
+
+func NewCourseSectionService(
+	sectionRepo repository.CourseSectionRepository,
+	courseRepo repository.CourseRepository,
+	termRepo repository.AcademicTermRepository,
+) CourseSectionService {
+	return &courseSectionService{
+		sectionRepo: sectionRepo,
+		courseRepo:  courseRepo,
+		termRepo:    termRepo,
+		logger:      logger.GetLogger(),
+	}
+}
+
+func (s *courseSectionService) CreateSection(section *models.CourseSection) error {
+	if section.CourseID == 0 {
+		return errors.New("course id is required")
+	}
+	if section.TermID == 0 {
+		return errors.New("term id is required")
+	}
+
+	course, err := s.courseRepo.FindByID(section.CourseID)
+	if err != nil {
+		return errors.New("course not found")
+	}
+
+	term, err := s.termRepo.FindByID(section.TermID)
+	if err != nil {
+		return errors.New("academic term not found")
+	}
+	if term.Status == models.TermStatusClosed {
+		return errors.New("cannot add sections to a closed term")
+	}
+
+	// Fall back to the course defaults for anything the section doesn't override
+	if section.SectionCode == "" {
+		section.SectionCode = "001"
+	}
+	if section.TeacherID == 0 {
+		section.TeacherID = course.TeacherID
+	}
+	if section.Room == "" {
+		section.Room = course.Room
+	}
+	if section.Schedule == "" {
+		section.Schedule = course.Schedule
+	}
+	if section.Capacity == 0 {
+		section.Capacity = course.MaxStudents
+	}
+	if section.Status == "" {
+		section.Status = "open"
+	}
+	if section.WeeklyHours < 0 {
+		return errors.New("weekly hours cannot be negative")
+	}
+
+	if _, err := s.sectionRepo.FindByCourseTermAndCode(section.CourseID, section.TermID, section.SectionCode); err == nil {
+		s.logger.WithField("course_id", section.CourseID).WithField("term_id", section.TermID).
+			WithField("section_code", section.SectionCode).Warn("Section already exists")
+		return errors.New("section already exists for this course and term")
+	}
+
+	if err := s.sectionRepo.Create(section); err != nil {
+		s.logger.WithError(err).WithField("course_id", section.CourseID).Error("Failed to create course section")
+		return errors.New("failed to create course section")
+	}
+
+	s.logger.WithField("id", section.ID).WithField("course_id", section.CourseID).
+		WithField("term_id", section.TermID).Info("Course section created")
+	return nil
+}
+
+func (s *courseSectionService) GetSectionByID(id uint) (*models.CourseSection, error) {
+	section, err := s.sectionRepo.FindByID(id)
+	if err != nil {
+		s.logger.WithError(err).WithField("id", id).Warn("Course section not found")
+		return nil, errors.New("course section not found")
+	}
+
+	return section, nil
+}
+
+func (s *courseSectionService) GetTermSections(termID uint, page, limit int) ([]models.CourseSection, int64, error) {
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 {
+		limit = 10
+	}
+
+	sections, total, err := s.sectionRepo.FindByTermID(termID, page, limit)
+	if err != nil {
+		s.logger.WithError(err).WithField("term_id", termID).Error("Failed to fetch course sections")
+		return nil, 0, err
+	}
+
+	return sections, total, nil
+}
+
+func (s *courseSectionService) GetCourseSections(courseID uint) ([]models.CourseSection, error) {
+	sections, err := s.sectionRepo.FindByCourseID(courseID)
+	if err != nil {
+		s.logger.WithError(err).WithField("course_id", courseID).Error("Failed to fetch course sections")
+		return nil, err
+	}
+
+	return sections, nil
+}
+
+func (s *courseSectionService) GetTeacherSections(teacherID, termID uint) ([]models.CourseSection, error) {
+	sections, err := s.sectionRepo.FindByTeacherID(teacherID, termID)
+	if err != nil {
+		s.logger.WithError(err).WithField("teacher_id", teacherID).Error("Failed to fetch teacher sections")
+		return nil, err
+	}
+
+	return sections, nil
+}
+
+func (s *courseSectionService) UpdateSection(section *models.CourseSection) error {
+	if section.ID == 0 {
+		return errors.New("section id is required")
+	}
+
+	if section.Capacity < 0 {
+		return errors.New("capacity cannot be negative")
+	}
+	if section.WeeklyHours < 0 {
+		return errors.New("weekly hours cannot be negative")
+	}
+
+	if err := s.sectionRepo.Update(section); err != nil {
+		s.logger.WithError(err).WithField("id", section.ID).Error("Failed to update course section")
+		return errors.New("failed to update course section")
+	}
+
+	s.logger.WithField("id", section.ID).Info("Course section updated")
+	return nil
+}
+
+func (s *courseSectionService) DeleteSection(id uint) error {
+	count, err := s.sectionRepo.CountActiveEnrollments(id)
+	if err != nil {
+		s.logger.WithError(err).WithField("id", id).Error("Failed to count section enrollments")
+		return errors.New("failed to delete course section")
+	}
+	if count > 0 {
+		return errors.New("cannot delete a section with active enrollments")
+	}
+
+	if err := s.sectionRepo.Delete(id); err != nil {
+		s.logger.WithError(err).WithField("id", id).Error("Failed to delete course section")
+		return errors.New("failed to delete course section")
+	}
+
+	s.logger.WithField("id", id).Info("Course section deleted")
+	return nil
+}
+
+// GetSeatsRemaining returns how many more students can enroll. A capacity of
+// zero means the section is unlimited and -1 is returned.
+func (s *courseSectionService) GetSeatsRemaining(section *models.CourseSection) (int, error) {
+	if section.Capacity <= 0 {
+		return -1, nil
+	}
+
+	count, err := s.sectionRepo.CountActiveEnrollments(section.ID)
+	if err != nil {
+		return 0, err
+	}
+
+	remaining := section.Capacity - int(count)
+	if remaining < 0 {
+		remaining = 0
+	}
+	return remaining, nil
+}
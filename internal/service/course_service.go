@@ -43,8 +43,7 @@ func (s *courseService) CreateCourse(course *models.Course) error {
 	}
 
 	// Check if course code already exists
-	existing, _ := s.courseRepo.FindByCourseCode(course.CourseCode)
-	if existing != nil {
+	if _, err := s.courseRepo.FindByCourseCode(course.CourseCode); err == nil {
 		s.logger.WithField("code", course.CourseCode).Warn("Course code already exists")
 		return errors.New("course code already exists")
 	}
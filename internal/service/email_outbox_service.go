@@ -0,0 +1,345 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"school-management-system/internal/mail"
+	"school-management-system/internal/models"
+	"school-management-system/internal/repository"
+	"school-management-system/pkg/logger"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrNoRecipients    = errors.New("email has no recipients")
+	ErrEmailNotFailed  = errors.New("only failed emails can be retried")
+	ErrOutboxNotFound  = errors.New("email not found")
+	errNoMailTransport = errors.New("no mail transport configured")
+)
+
+// OutboxOptions configures email delivery. Zero values take the defaults.
+type OutboxOptions struct {
+	Transport    mail.Transport
+	From         string
+	FromName     string
+	MaxAttempts  int           // delivery attempts before a recipient is dead-lettered; default 8
+	BaseBackoff  time.Duration // wait after the first failed attempt, doubled after each; default 1m
+	MaxBackoff   time.Duration // longest wait between attempts; default 6h
+	PollInterval time.Duration // how often the worker looks for due emails; default 10s
+	LockTTL      time.Duration // how long a worker may hold an email; default 5m
+	BatchSize    int           // emails claimed per pass; default 20
+	Instance     string        // identifies this server instance; defaults to host-pid-random
+}
+
+type EmailOutboxService interface {
+	Enqueue(msg *EmailMessage) (*models.OutboxEmail, error)
+	ProcessDue(now time.Time) (int, error)
+	Start()
+	Stop()
+	GetByID(id uint) (*models.OutboxEmail, error)
+	GetAll(status string, page, limit int) ([]models.OutboxEmail, int64, error)
+	Stats() (map[string]int64, error)
+	Retry(id uint) error
+	PurgeSent(olderThanDays int) (int64, error)
+}
+
+type emailOutboxService struct {
+	repo    repository.OutboxRepository
+	options OutboxOptions
+	logger  *logrus.Logger
+
+	wake    chan struct{}
+	ctx     context.Context
+	cancel  context.CancelFunc
+	mu      sync.Mutex
+	stopped chan struct{}
+}
+
+// NewEmailOutboxService creates the outbox. Emails are stored before they
+// are sent, so nothing queued is lost if the server restarts; the worker
+// picks up where it left off.
+func NewEmailOutboxService(repo repository.OutboxRepository, options OutboxOptions) EmailOutboxService {
+	if options.MaxAttempts <= 0 {
+		options.MaxAttempts = 8
+	}
+	if options.BaseBackoff <= 0 {
+		options.BaseBackoff = time.Minute
+	}
+	if options.MaxBackoff <= 0 {
+		options.MaxBackoff = 6 * time.Hour
+	}
+	if options.PollInterval <= 0 {
+		options.PollInterval = 10 * time.Second
+	}
+	if options.LockTTL <= 0 {
+		options.LockTTL = 5 * time.Minute
+	}
+	if options.BatchSize <= 0 {
+		options.BatchSize = 20
+	}
+	if options.Instance == "" {
+		host, _ := os.Hostname()
+		options.Instance = fmt.Sprintf("%s-%d-%s", host, os.Getpid(), uuid.New().String()[:8])
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &emailOutboxService{
+		repo:    repo,
+		options: options,
+		logger:  logger.GetLogger(),
+		wake:    make(chan struct{}, 1),
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+}
+
+// Enqueue stores an email for delivery to each of its recipients.
+// Duplicate addresses are sent to once.
+func (s *emailOutboxService) Enqueue(msg *EmailMessage) (*models.OutboxEmail, error) {
+	now := time.Now().Unix()
+	email := &models.OutboxEmail{
+		Subject:       msg.Subject,
+		Body:          msg.Body,
+		TextBody:      msg.TextBody,
+		IsHTML:        msg.IsHTML,
+		Status:        models.OutboxStatusPending,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	seen := map[string]bool{}
+	for _, to := range msg.To {
+		address := strings.TrimSpace(to)
+		key := strings.ToLower(address)
+		if address == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		email.Recipients = append(email.Recipients, models.OutboxRecipient{
+			Address: address,
+			Status:  models.RecipientStatusPending,
+		})
+	}
+	if len(email.Recipients) == 0 {
+		return nil, ErrNoRecipients
+	}
+
+	if err := s.repo.Create(email); err != nil {
+		s.logger.WithError(err).WithField("subject", msg.Subject).Error("Failed to queue email")
+		return nil, errors.New("failed to queue email")
+	}
+
+	// Let the worker deliver now rather than at its next poll
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+	return email, nil
+}
+
+// Start runs the delivery worker in the background until Stop. Every
+// server instance may run one.
+func (s *emailOutboxService) Start() {
+	s.mu.Lock()
+	if s.stopped != nil {
+		s.mu.Unlock()
+		return
+	}
+	s.stopped = make(chan struct{})
+	s.mu.Unlock()
+
+	go func() {
+		defer close(s.stopped)
+		ticker := time.NewTicker(s.options.PollInterval)
+		defer ticker.Stop()
+		for {
+			for {
+				n, err := s.ProcessDue(time.Now())
+				if err != nil {
+					s.logger.WithError(err).Warn("Email outbox pass failed")
+				}
+				// A full batch means more may be waiting
+				if err != nil || n < s.options.BatchSize || s.ctx.Err() != nil {
+					break
+				}
+			}
+			select {
+			case <-s.ctx.Done():
+				return
+			case <-ticker.C:
+			case <-s.wake:
+			}
+		}
+	}()
+}
+
+// Stop ends the worker after the email it is delivering, if any
+func (s *emailOutboxService) Stop() {
+	s.cancel()
+	s.mu.Lock()
+	stopped := s.stopped
+	s.mu.Unlock()
+	if stopped != nil {
+		<-stopped
+	}
+}
+
+// ProcessDue delivers the emails that are due, returning how many it
+// handled
+func (s *emailOutboxService) ProcessDue(now time.Time) (int, error) {
+	if s.options.Transport == nil {
+		return 0, errNoMailTransport
+	}
+	emails, err := s.repo.ClaimDue(now.Unix(), now.Add(s.options.LockTTL).Unix(), s.options.Instance, s.options.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+	for i := range emails {
+		if err := s.deliver(&emails[i], now); err != nil {
+			return i, err
+		}
+	}
+	return len(emails), nil
+}
+
+// deliver attempts every pending recipient of an email once
+func (s *emailOutboxService) deliver(email *models.OutboxEmail, now time.Time) error {
+	email.Attempts++
+	outOfAttempts := email.Attempts >= s.options.MaxAttempts
+	log := s.logger.WithFields(logrus.Fields{"email_id": email.ID, "attempt": email.Attempts})
+
+	var lastErr string
+	pending, sent := 0, 0
+	for i := range email.Recipients {
+		r := &email.Recipients[i]
+		if r.Status != models.RecipientStatusPending {
+			if r.Status == models.RecipientStatusSent {
+				sent++
+			}
+			continue
+		}
+
+		r.Attempts++
+		message := &mail.Message{
+			From:      s.options.From,
+			FromName:  s.options.FromName,
+			To:        []string{r.Address},
+			Subject:   email.Subject,
+			Body:      email.Body,
+			IsHTML:    email.IsHTML,
+			TextBody:  email.TextBody,
+			MessageID: fmt.Sprintf("outbox-%d-%d@%s", email.ID, r.ID, senderDomain(s.options.From)),
+			Date:      now,
+		}
+		err := s.options.Transport.Send(s.options.From, r.Address, message.Bytes())
+		switch {
+		case err == nil:
+			r.Status = models.RecipientStatusSent
+			r.SentAt = now.Unix()
+			r.LastError = ""
+			sent++
+		case mail.IsPermanent(err) || outOfAttempts:
+			r.Status = models.RecipientStatusDead
+			r.LastError = err.Error()
+			lastErr = err.Error()
+			log.WithError(err).WithField("recipient", r.Address).Error("Email dead-lettered")
+		default:
+			r.LastError = err.Error()
+			lastErr = err.Error()
+			pending++
+			log.WithError(err).WithField("recipient", r.Address).Warn("Email delivery failed, will retry")
+		}
+		if err := s.repo.UpdateRecipient(r); err != nil {
+			return err
+		}
+	}
+
+	email.LastError = lastErr
+	email.UpdatedAt = now.Unix()
+	switch {
+	case pending > 0:
+		email.Status = models.OutboxStatusPending
+		email.NextAttemptAt = now.Add(backoff(s.options.BaseBackoff, s.options.MaxBackoff, email.Attempts)).Unix()
+	case sent == len(email.Recipients):
+		email.Status = models.OutboxStatusSent
+		email.SentAt = now.Unix()
+	default:
+		email.Status = models.OutboxStatusFailed
+	}
+	return s.repo.Finish(email)
+}
+
+// backoff is the wait after the given number of failed attempts: base
+// after the first, doubled after each further one, and never more than max
+func backoff(base, max time.Duration, attempts int) time.Duration {
+	wait := base
+	for i := 1; i < attempts && wait < max; i++ {
+		wait *= 2
+	}
+	if wait > max {
+		return max
+	}
+	return wait
+}
+
+func (s *emailOutboxService) GetByID(id uint) (*models.OutboxEmail, error) {
+	email, err := s.repo.FindByID(id)
+	if err != nil {
+		return nil, ErrOutboxNotFound
+	}
+	return email, nil
+}
+
+func (s *emailOutboxService) GetAll(status string, page, limit int) ([]models.OutboxEmail, int64, error) {
+	return s.repo.FindAll(status, page, limit)
+}
+
+func (s *emailOutboxService) Stats() (map[string]int64, error) {
+	return s.repo.CountByStatus()
+}
+
+// Retry requeues a failed email, giving its dead recipients a fresh set of
+// attempts. Recipients that already have the email are not sent it again.
+func (s *emailOutboxService) Retry(id uint) error {
+	email, err := s.repo.FindByID(id)
+	if err != nil {
+		return ErrOutboxNotFound
+	}
+	if email.Status != models.OutboxStatusFailed {
+		return ErrEmailNotFailed
+	}
+	if err := s.repo.Requeue(id, time.Now().Unix()); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrEmailNotFailed
+		}
+		return err
+	}
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// PurgeSent deletes delivered emails older than the given number of days.
+// Failed emails are kept for review.
+func (s *emailOutboxService) PurgeSent(olderThanDays int) (int64, error) {
+	if olderThanDays <= 0 {
+		return 0, nil
+	}
+	return s.repo.DeleteSentBefore(time.Now().AddDate(0, 0, -olderThanDays).Unix())
+}
+
+func senderDomain(from string) string {
+	if i := strings.LastIndex(from, "@"); i >= 0 && i < len(from)-1 {
+		return from[i+1:]
+	}
+	return "localhost"
+}
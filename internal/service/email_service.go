@@ -1,187 +1,187 @@
 package service
 
 import (
-	"fmt"
-	"net/smtp"
-	"strings"
+	"school-management-system/internal/mail"
+	"school-management-system/internal/models"
 )
 
-// EmailService handles sending emails
+// EmailService renders emails from their templates in each recipient's
+// locale and queues them in the outbox, which delivers them in the
+// background with retries
 type EmailService struct {
-	smtpHost     string
-	smtpPort     string
-	senderEmail  string
-	senderName   string
-	senderPasswd string
+	outbox    EmailOutboxService
+	templates EmailTemplateService
 }
 
 // NewEmailService creates a new email service
-func NewEmailService(host, port, email, name, passwd string) *EmailService {
-	return &EmailService{
-		smtpHost:     host,
-		smtpPort:     port,
-		senderEmail:  email,
-		senderName:   name,
-		senderPasswd: passwd,
-	}
+func NewEmailService(outbox EmailOutboxService, templates EmailTemplateService) *EmailService {
+	return &EmailService{outbox: outbox, templates: templates}
 }
 
 // EmailMessage represents an email to send
 type EmailMessage struct {
-	To      []string
-	Subject string
-	Body    string
-	IsHTML  bool
+	To       []string
+	Subject  string
+	Body     string
+	IsHTML   bool
+	TextBody string // plain-text alternative to an HTML body, derived from it when empty
+}
+
+// EmailRecipient is an address and the locale to write to it in
+type EmailRecipient struct {
+	Address string
+	Locale  string
+}
+
+// UserRecipient addresses an email to a user in their preferred locale
+func UserRecipient(user *models.User) EmailRecipient {
+	return EmailRecipient{Address: user.Email, Locale: user.Locale}
 }
 
-// Send sends an email message
+// Send queues an email message for delivery. A message without recipients
+// is skipped.
 func (es *EmailService) Send(msg *EmailMessage) error {
-	if es.smtpHost == "" || es.senderEmail == "" {
-		// If SMTP not configured, log and skip (graceful degradation)
+	if len(msg.To) == 0 {
 		return nil
 	}
-
-	// Prepare email headers
-	contentType := "text/plain"
-	if msg.IsHTML {
-		contentType = "text/html"
+	if msg.IsHTML && msg.TextBody == "" {
+		msg.TextBody = mail.HTMLToText(msg.Body)
 	}
+	_, err := es.outbox.Enqueue(msg)
+	if err == ErrNoRecipients {
+		return nil
+	}
+	return err
+}
 
-	headers := fmt.Sprintf(
-		"From: %s <%s>\r\nTo: %s\r\nSubject: %s\r\nMIME-Version: 1.0\r\nContent-Type: %s; charset=UTF-8\r\n\r\n",
-		es.senderName,
-		es.senderEmail,
-		strings.Join(msg.To, ", "),
-		msg.Subject,
-		contentType,
-	)
-
-	fullMessage := headers + msg.Body
-
-	// Send email
-	auth := smtp.PlainAuth("", es.senderEmail, es.senderPasswd, es.smtpHost)
-	addr := es.smtpHost + ":" + es.smtpPort
+// SendTemplate renders the email with the given key for each locale among
+// the recipients and queues one email per locale
+func (es *EmailService) SendTemplate(key string, to []EmailRecipient, data map[string]interface{}) error {
+	var locales []string
+	byLocale := map[string][]string{}
+	for _, r := range to {
+		if r.Address == "" {
+			continue
+		}
+		locale, ok := NormalizeLocale(r.Locale)
+		if !ok {
+			locale = models.DefaultLocale
+		}
+		if _, seen := byLocale[locale]; !seen {
+			locales = append(locales, locale)
+		}
+		byLocale[locale] = append(byLocale[locale], r.Address)
+	}
 
-	return smtp.SendMail(addr, auth, es.senderEmail, msg.To, []byte(fullMessage))
+	for _, locale := range locales {
+		rendered, err := es.templates.Render(key, locale, data)
+		if err != nil {
+			return err
+		}
+		err = es.Send(&EmailMessage{
+			To:       byLocale[locale],
+			Subject:  rendered.Subject,
+			Body:     rendered.HTMLBody,
+			TextBody: rendered.TextBody,
+			IsHTML:   true,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // SendGradeNotification sends email when grade is posted
-func (es *EmailService) SendGradeNotification(studentEmail, studentName, courseName, grade string) error {
-	subject := fmt.Sprintf("New Grade Posted - %s", courseName)
-	body := fmt.Sprintf(`
-<html>
-<body>
-	<h2>Grade Notification</h2>
-	<p>Hi %s,</p>
-	<p>A new grade has been posted for <strong>%s</strong>.</p>
-	<p><strong>Your Grade: %s</strong></p>
-	<p>Please log in to your student portal to view detailed feedback.</p>
-	<br/>
-	<p>Best regards,<br/>School Management System</p>
-</body>
-</html>
-	`, studentName, courseName, grade)
-
-	return es.Send(&EmailMessage{
-		To:      []string{studentEmail},
-		Subject: subject,
-		Body:    body,
-		IsHTML:  true,
+func (es *EmailService) SendGradeNotification(to EmailRecipient, studentName, courseName, grade string) error {
+	return es.SendTemplate(EmailGradePosted, []EmailRecipient{to}, map[string]interface{}{
+		"Name":       studentName,
+		"CourseName": courseName,
+		"Grade":      grade,
 	})
 }
 
 // SendAnnouncementNotification sends email for announcements
-func (es *EmailService) SendAnnouncementNotification(recipients []string, title, content string) error {
-	subject := fmt.Sprintf("New Announcement: %s", title)
-	body := fmt.Sprintf(`
-<html>
-<body>
-	<h2>%s</h2>
-	<p>%s</p>
-	<br/>
-	<p>Best regards,<br/>School Management System</p>
-</body>
-</html>
-	`, title, content)
-
-	return es.Send(&EmailMessage{
-		To:      recipients,
-		Subject: subject,
-		Body:    body,
-		IsHTML:  true,
+func (es *EmailService) SendAnnouncementNotification(recipients []EmailRecipient, title, content string) error {
+	return es.SendTemplate(EmailAnnouncement, recipients, map[string]interface{}{
+		"Title":   title,
+		"Content": content,
 	})
 }
 
 // SendPaymentReminder sends payment due reminder
-func (es *EmailService) SendPaymentReminder(studentEmail, studentName string, amount float64, dueDate string) error {
-	subject := "Payment Due Reminder"
-	body := fmt.Sprintf(`
-<html>
-<body>
-	<h2>Payment Reminder</h2>
-	<p>Hi %s,</p>
-	<p>This is a reminder that your payment is due on <strong>%s</strong>.</p>
-	<p><strong>Amount Due: $%.2f</strong></p>
-	<p>Please make payment through your student portal or contact the finance office.</p>
-	<br/>
-	<p>Best regards,<br/>School Management System</p>
-</body>
-</html>
-	`, studentName, dueDate, amount)
-
-	return es.Send(&EmailMessage{
-		To:      []string{studentEmail},
-		Subject: subject,
-		Body:    body,
-		IsHTML:  true,
+func (es *EmailService) SendPaymentReminder(to EmailRecipient, studentName string, amount float64, dueDate string) error {
+	return es.SendTemplate(EmailPaymentReminder, []EmailRecipient{to}, map[string]interface{}{
+		"Name":    studentName,
+		"Amount":  amount,
+		"DueDate": dueDate,
 	})
 }
 
 // SendEnrollmentApprovalNotification sends enrollment confirmation
-func (es *EmailService) SendEnrollmentApprovalNotification(studentEmail, studentName, courseName string) error {
-	subject := fmt.Sprintf("Enrollment Approved - %s", courseName)
-	body := fmt.Sprintf(`
-<html>
-<body>
-	<h2>Enrollment Approved</h2>
-	<p>Hi %s,</p>
-	<p>Your enrollment in <strong>%s</strong> has been approved!</p>
-	<p>You can now access course materials and participate in classes.</p>
-	<br/>
-	<p>Best regards,<br/>School Management System</p>
-</body>
-</html>
-	`, studentName, courseName)
-
-	return es.Send(&EmailMessage{
-		To:      []string{studentEmail},
-		Subject: subject,
-		Body:    body,
-		IsHTML:  true,
+func (es *EmailService) SendEnrollmentApprovalNotification(to EmailRecipient, studentName, courseName string) error {
+	return es.SendTemplate(EmailEnrollmentApproved, []EmailRecipient{to}, map[string]interface{}{
+		"Name":       studentName,
+		"CourseName": courseName,
 	})
 }
 
 // SendAttendanceAlert sends low attendance alert
-func (es *EmailService) SendAttendanceAlert(studentEmail, studentName, courseName string, attendancePercent float64) error {
-	subject := fmt.Sprintf("Attendance Alert - %s", courseName)
-	body := fmt.Sprintf(`
-<html>
-<body>
-	<h2>Attendance Alert</h2>
-	<p>Hi %s,</p>
-	<p>Your attendance in <strong>%s</strong> is concerning.</p>
-	<p><strong>Current Attendance: %.1f%%</strong></p>
-	<p>Please contact your instructor or the registrar if you need assistance.</p>
-	<br/>
-	<p>Best regards,<br/>School Management System</p>
-</body>
-</html>
-	`, studentName, courseName, attendancePercent)
-
-	return es.Send(&EmailMessage{
-		To:      []string{studentEmail},
-		Subject: subject,
-		Body:    body,
-		IsHTML:  true,
+func (es *EmailService) SendAttendanceAlert(to EmailRecipient, studentName, courseName string, attendancePercent float64) error {
+	return es.SendTemplate(EmailAttendanceAlert, []EmailRecipient{to}, map[string]interface{}{
+		"Name":              studentName,
+		"CourseName":        courseName,
+		"AttendancePercent": attendancePercent,
+	})
+}
+
+// SendGuardianGradeNotification copies a grade notification to a student's guardians
+func (es *EmailService) SendGuardianGradeNotification(guardians []EmailRecipient, studentName, courseName, grade string) error {
+	return es.SendTemplate(EmailGuardianGradePosted, guardians, map[string]interface{}{
+		"StudentName": studentName,
+		"CourseName":  courseName,
+		"Grade":       grade,
+	})
+}
+
+// SendGuardianAttendanceAlert copies a low attendance alert to a student's guardians
+func (es *EmailService) SendGuardianAttendanceAlert(guardians []EmailRecipient, studentName, courseName string, attendancePercent float64) error {
+	return es.SendTemplate(EmailGuardianAttendanceAlert, guardians, map[string]interface{}{
+		"StudentName":       studentName,
+		"CourseName":        courseName,
+		"AttendancePercent": attendancePercent,
+	})
+}
+
+// SendPasswordResetEmail sends a password reset link
+func (es *EmailService) SendPasswordResetEmail(to EmailRecipient, name, resetLink string, validMinutes int) error {
+	return es.SendTemplate(EmailPasswordReset, []EmailRecipient{to}, map[string]interface{}{
+		"Name":         name,
+		"Link":         resetLink,
+		"ValidMinutes": validMinutes,
+	})
+}
+
+// SendVerificationEmail sends an email address verification link
+func (es *EmailService) SendVerificationEmail(to EmailRecipient, name, verifyLink string) error {
+	return es.SendTemplate(EmailVerification, []EmailRecipient{to}, map[string]interface{}{
+		"Name": name,
+		"Link": verifyLink,
+	})
+}
+
+// SendPasswordChangedNotification tells a user their password was changed
+func (es *EmailService) SendPasswordChangedNotification(to EmailRecipient, name string) error {
+	return es.SendTemplate(EmailPasswordChanged, []EmailRecipient{to}, map[string]interface{}{
+		"Name": name,
+	})
+}
+
+// SendAccountLockedNotification tells a user their account was locked after failed logins
+func (es *EmailService) SendAccountLockedNotification(to EmailRecipient, name, ipAddress string, lockMinutes int) error {
+	return es.SendTemplate(EmailAccountLocked, []EmailRecipient{to}, map[string]interface{}{
+		"Name":        name,
+		"IPAddress":   ipAddress,
+		"LockMinutes": lockMinutes,
 	})
 }
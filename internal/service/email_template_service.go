@@ -0,0 +1,358 @@
+package service
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"regexp"
+	"school-management-system/internal/mail"
+	"school-management-system/internal/models"
+	"school-management-system/internal/repository"
+	"school-management-system/pkg/logger"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	ErrEmailTemplateNotFound = errors.New("email template not found")
+	ErrInvalidLocale         = errors.New("locale must be a language code such as en or fr-CA")
+	ErrInvalidEmailTemplate  = errors.New("invalid email template")
+)
+
+// RenderedEmail is an email template filled in for one locale
+type RenderedEmail struct {
+	Locale   string `json:"locale"`
+	Subject  string `json:"subject"`
+	HTMLBody string `json:"html_body"`
+	TextBody string `json:"text_body"`
+}
+
+// EmailTemplateSummary lists an email and the locales it has variants for
+type EmailTemplateSummary struct {
+	Key         string   `json:"key"`
+	Description string   `json:"description"`
+	Locales     []string `json:"locales"`
+}
+
+// EmailTemplateDetail is an email with all of its locale variants
+type EmailTemplateDetail struct {
+	EmailTemplateDefinition
+	Variants []models.EmailTemplate `json:"variants"`
+}
+
+type EmailTemplateService interface {
+	SeedDefaults() error
+	List() ([]EmailTemplateSummary, error)
+	Get(key string) (*EmailTemplateDetail, error)
+	Save(template *models.EmailTemplate, userID uint) error
+	Delete(key, locale string) error
+	Preview(draft *models.EmailTemplate, data map[string]interface{}) (*RenderedEmail, error)
+	Render(key, locale string, data map[string]interface{}) (*RenderedEmail, error)
+}
+
+type emailTemplateService struct {
+	repo        repository.EmailTemplateRepository
+	definitions map[string]EmailTemplateDefinition
+	logger      *logrus.Logger
+}
+
+func NewEmailTemplateService(repo repository.EmailTemplateRepository) EmailTemplateService {
+	definitions := make(map[string]EmailTemplateDefinition, len(emailTemplateDefinitions))
+	for _, d := range emailTemplateDefinitions {
+		definitions[d.Key] = d
+	}
+	return &emailTemplateService{
+		repo:        repo,
+		definitions: definitions,
+		logger:      logger.GetLogger(),
+	}
+}
+
+// SeedDefaults stores the built-in English content of any email that has
+// no default-locale variant yet. Existing variants are left as edited.
+func (s *emailTemplateService) SeedDefaults() error {
+	now := time.Now().Unix()
+	for _, d := range emailTemplateDefinitions {
+		err := s.repo.CreateIfMissing(&models.EmailTemplate{
+			Key:       d.Key,
+			Locale:    models.DefaultLocale,
+			Subject:   d.Subject,
+			HTMLBody:  d.HTMLBody,
+			CreatedAt: now,
+			UpdatedAt: now,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *emailTemplateService) List() ([]EmailTemplateSummary, error) {
+	stored, err := s.repo.FindAll()
+	if err != nil {
+		return nil, err
+	}
+	locales := map[string][]string{}
+	for _, t := range stored {
+		locales[t.Key] = append(locales[t.Key], t.Locale)
+	}
+
+	summaries := make([]EmailTemplateSummary, 0, len(emailTemplateDefinitions))
+	for _, d := range emailTemplateDefinitions {
+		summaries = append(summaries, EmailTemplateSummary{
+			Key:         d.Key,
+			Description: d.Description,
+			Locales:     append([]string{}, locales[d.Key]...),
+		})
+	}
+	return summaries, nil
+}
+
+func (s *emailTemplateService) Get(key string) (*EmailTemplateDetail, error) {
+	definition, ok := s.definitions[key]
+	if !ok {
+		return nil, ErrEmailTemplateNotFound
+	}
+	variants, err := s.repo.FindByKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return &EmailTemplateDetail{EmailTemplateDefinition: definition, Variants: variants}, nil
+}
+
+// Save creates or replaces a locale variant. The templates must parse and
+// render with the email's sample data, so a typo in a field name is
+// caught here rather than when the email is sent.
+func (s *emailTemplateService) Save(template *models.EmailTemplate, userID uint) error {
+	definition, ok := s.definitions[template.Key]
+	if !ok {
+		return ErrEmailTemplateNotFound
+	}
+	locale, ok := NormalizeLocale(template.Locale)
+	if !ok {
+		return ErrInvalidLocale
+	}
+	template.Locale = locale
+	if strings.TrimSpace(template.Subject) == "" || strings.TrimSpace(template.HTMLBody) == "" {
+		return fmt.Errorf("%w: subject and html_body are required", ErrInvalidEmailTemplate)
+	}
+	if _, err := renderEmailTemplate(template, definition.Sample); err != nil {
+		return err
+	}
+
+	now := time.Now().Unix()
+	template.UpdatedBy = &userID
+	template.CreatedAt = now
+	template.UpdatedAt = now
+	return s.repo.Upsert(template)
+}
+
+// Delete removes a locale variant, after which its recipients get the
+// default locale. Deleting the default-locale variant restores the
+// built-in content instead.
+func (s *emailTemplateService) Delete(key, locale string) error {
+	definition, ok := s.definitions[key]
+	if !ok {
+		return ErrEmailTemplateNotFound
+	}
+	locale, ok = NormalizeLocale(locale)
+	if !ok {
+		return ErrInvalidLocale
+	}
+	if locale == models.DefaultLocale {
+		now := time.Now().Unix()
+		return s.repo.Upsert(&models.EmailTemplate{
+			Key:       key,
+			Locale:    locale,
+			Subject:   definition.Subject,
+			HTMLBody:  definition.HTMLBody,
+			CreatedAt: now,
+			UpdatedAt: now,
+		})
+	}
+	if err := s.repo.Delete(key, locale); err != nil {
+		return ErrEmailTemplateNotFound
+	}
+	return nil
+}
+
+// Preview renders a draft with the email's sample data, overridden by any
+// data given. Parts of the draft left empty are taken from the variant
+// the draft's locale would get today.
+func (s *emailTemplateService) Preview(draft *models.EmailTemplate, data map[string]interface{}) (*RenderedEmail, error) {
+	definition, ok := s.definitions[draft.Key]
+	if !ok {
+		return nil, ErrEmailTemplateNotFound
+	}
+	locale, ok := NormalizeLocale(draft.Locale)
+	if !ok {
+		return nil, ErrInvalidLocale
+	}
+
+	preview := *draft
+	preview.Locale = locale
+	if preview.Subject == "" && preview.HTMLBody == "" {
+		// Nothing drafted: preview what the locale gets today
+		preview = *s.variant(definition, locale)
+	} else if preview.Subject == "" || preview.HTMLBody == "" {
+		current := s.variant(definition, locale)
+		if preview.Subject == "" {
+			preview.Subject = current.Subject
+		}
+		if preview.HTMLBody == "" {
+			preview.HTMLBody = current.HTMLBody
+			if preview.TextBody == "" {
+				preview.TextBody = current.TextBody
+			}
+		}
+	}
+
+	merged := make(map[string]interface{}, len(definition.Sample)+len(data))
+	for k, v := range definition.Sample {
+		merged[k] = v
+	}
+	for k, v := range data {
+		merged[k] = v
+	}
+	return renderEmailTemplate(&preview, merged)
+}
+
+// Render fills in the email for a recipient's locale, falling back from
+// a regional locale such as fr-CA to fr and then to the default locale
+func (s *emailTemplateService) Render(key, locale string, data map[string]interface{}) (*RenderedEmail, error) {
+	definition, ok := s.definitions[key]
+	if !ok {
+		return nil, ErrEmailTemplateNotFound
+	}
+
+	template := s.variant(definition, locale)
+	rendered, err := renderEmailTemplate(template, data)
+	if err == nil {
+		return rendered, nil
+	}
+
+	// A stored variant that no longer renders should not stop the email
+	s.logger.WithError(err).WithFields(logrus.Fields{"key": key, "locale": template.Locale}).
+		Error("Email template failed to render, using built-in content")
+	return renderEmailTemplate(&models.EmailTemplate{
+		Key:      key,
+		Locale:   models.DefaultLocale,
+		Subject:  definition.Subject,
+		HTMLBody: definition.HTMLBody,
+	}, data)
+}
+
+// variant returns the stored template the locale resolves to, or the
+// built-in content when there is none
+func (s *emailTemplateService) variant(definition EmailTemplateDefinition, locale string) *models.EmailTemplate {
+	template, err := s.repo.FindVariant(definition.Key, localeFallbacks(locale))
+	if err == nil {
+		return template
+	}
+	return &models.EmailTemplate{
+		Key:      definition.Key,
+		Locale:   models.DefaultLocale,
+		Subject:  definition.Subject,
+		HTMLBody: definition.HTMLBody,
+	}
+}
+
+var localePattern = regexp.MustCompile(`^([a-zA-Z]{2,3})(?:[-_]([a-zA-Z]{2}|[0-9]{3}))?$`)
+
+// NormalizeLocale formats a locale as a lowercase language with an
+// optional uppercase region, e.g. "fr_ca" becomes "fr-CA". An empty
+// locale is the default locale.
+func NormalizeLocale(locale string) (string, bool) {
+	locale = strings.TrimSpace(locale)
+	if locale == "" {
+		return models.DefaultLocale, true
+	}
+	parts := localePattern.FindStringSubmatch(locale)
+	if parts == nil {
+		return "", false
+	}
+	if parts[2] == "" {
+		return strings.ToLower(parts[1]), true
+	}
+	return strings.ToLower(parts[1]) + "-" + strings.ToUpper(parts[2]), true
+}
+
+// localeFallbacks lists the locales to try for a recipient, most specific
+// first
+func localeFallbacks(locale string) []string {
+	normalized, ok := NormalizeLocale(locale)
+	if !ok {
+		return []string{models.DefaultLocale}
+	}
+	fallbacks := []string{normalized}
+	if i := strings.Index(normalized, "-"); i > 0 {
+		fallbacks = append(fallbacks, normalized[:i])
+	}
+	if fallbacks[len(fallbacks)-1] != models.DefaultLocale {
+		fallbacks = append(fallbacks, models.DefaultLocale)
+	}
+	return fallbacks
+}
+
+// nl2br escapes text for HTML and keeps its line breaks, for multi-line
+// content such as announcements
+func nl2br(value interface{}) htmltemplate.HTML {
+	escaped := htmltemplate.HTMLEscapeString(fmt.Sprint(value))
+	return htmltemplate.HTML(strings.ReplaceAll(escaped, "\n", "<br/>\n"))
+}
+
+var (
+	htmlTemplateFuncs = htmltemplate.FuncMap{"nl2br": nl2br}
+	// The text funcs mirror the HTML ones so a text body can be written
+	// like the HTML body
+	textTemplateFuncs = texttemplate.FuncMap{"nl2br": func(value interface{}) string { return fmt.Sprint(value) }}
+)
+
+// renderEmailTemplate executes a template's subject and bodies. Data
+// fields the template uses must be present.
+func renderEmailTemplate(template *models.EmailTemplate, data map[string]interface{}) (*RenderedEmail, error) {
+	invalid := func(part string, err error) error {
+		return fmt.Errorf("%w: %s: %v", ErrInvalidEmailTemplate, part, err)
+	}
+	rendered := &RenderedEmail{Locale: template.Locale}
+
+	subject, err := executeText("subject", template.Subject, data)
+	if err != nil {
+		return nil, invalid("subject", err)
+	}
+	rendered.Subject = strings.Join(strings.Fields(subject), " ")
+
+	htmlTemplate, err := htmltemplate.New("html_body").Option("missingkey=error").Funcs(htmlTemplateFuncs).Parse(template.HTMLBody)
+	if err != nil {
+		return nil, invalid("html_body", err)
+	}
+	var html bytes.Buffer
+	if err := htmlTemplate.Execute(&html, data); err != nil {
+		return nil, invalid("html_body", err)
+	}
+	rendered.HTMLBody = html.String()
+
+	if strings.TrimSpace(template.TextBody) == "" {
+		rendered.TextBody = mail.HTMLToText(rendered.HTMLBody)
+	} else if rendered.TextBody, err = executeText("text_body", template.TextBody, data); err != nil {
+		return nil, invalid("text_body", err)
+	}
+	return rendered, nil
+}
+
+func executeText(name, source string, data map[string]interface{}) (string, error) {
+	t, err := texttemplate.New(name).Option("missingkey=error").Funcs(textTemplateFuncs).Parse(source)
+	if err != nil {
+		return "", err
+	}
+	var out bytes.Buffer
+	if err := t.Execute(&out, data); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
@@ -0,0 +1,183 @@
+package service
+
+// Keys of the emails the system sends
+const (
+	EmailGradePosted             = "grade_posted"
+	EmailGuardianGradePosted     = "guardian_grade_posted"
+	EmailAnnouncement            = "announcement"
+	EmailPaymentReminder         = "payment_reminder"
+	EmailEnrollmentApproved      = "enrollment_approved"
+	EmailAttendanceAlert         = "attendance_alert"
+	EmailGuardianAttendanceAlert = "guardian_attendance_alert"
+	EmailPasswordReset           = "password_reset"
+	EmailVerification            = "email_verification"
+	EmailPasswordChanged         = "password_changed"
+	EmailAccountLocked           = "account_locked"
+	EmailNotification            = "notification"
+)
+
+// EmailTemplateDefinition describes an email the system sends: the data
+// its templates receive, sample values for previews, and the built-in
+// English content the database is seeded with
+type EmailTemplateDefinition struct {
+	Key         string                 `json:"key"`
+	Description string                 `json:"description"`
+	Sample      map[string]interface{} `json:"sample_data"`
+	Subject     string                 `json:"-"`
+	HTMLBody    string                 `json:"-"`
+}
+
+const emailSignature = `
+	<br/>
+	<p>Best regards,<br/>School Management System</p>
+</body>
+</html>`
+
+var emailTemplateDefinitions = []EmailTemplateDefinition{
+	{
+		Key:         EmailGradePosted,
+		Description: "Sent to a student when a grade is posted",
+		Sample:      map[string]interface{}{"Name": "Jane Doe", "CourseName": "Algebra I", "Grade": "A- (91.5%)"},
+		Subject:     "New Grade Posted - {{.CourseName}}",
+		HTMLBody: `<html>
+<body>
+	<h2>Grade Notification</h2>
+	<p>Hi {{.Name}},</p>
+	<p>A new grade has been posted for <strong>{{.CourseName}}</strong>.</p>
+	<p><strong>Your Grade: {{.Grade}}</strong></p>
+	<p>Please log in to your student portal to view detailed feedback.</p>` + emailSignature,
+	},
+	{
+		Key:         EmailGuardianGradePosted,
+		Description: "Copy of a posted grade sent to the student's guardians",
+		Sample:      map[string]interface{}{"StudentName": "Jane Doe", "CourseName": "Algebra I", "Grade": "A- (91.5%)"},
+		Subject:     "New Grade Posted for {{.StudentName}} - {{.CourseName}}",
+		HTMLBody: `<html>
+<body>
+	<h2>Grade Notification</h2>
+	<p>Hello,</p>
+	<p>A new grade has been posted for <strong>{{.StudentName}}</strong> in <strong>{{.CourseName}}</strong>.</p>
+	<p><strong>Grade: {{.Grade}}</strong></p>
+	<p>Please log in to the parent portal to view your child's progress.</p>` + emailSignature,
+	},
+	{
+		Key:         EmailAnnouncement,
+		Description: "An announcement sent by email",
+		Sample:      map[string]interface{}{"Title": "School closed Friday", "Content": "The school will be closed on Friday for staff training.\nClasses resume on Monday."},
+		Subject:     "New Announcement: {{.Title}}",
+		HTMLBody: `<html>
+<body>
+	<h2>{{.Title}}</h2>
+	<p>{{nl2br .Content}}</p>` + emailSignature,
+	},
+	{
+		Key:         EmailPaymentReminder,
+		Description: "Reminder of a payment that is due soon or overdue",
+		Sample:      map[string]interface{}{"Name": "Jane Doe", "Amount": 250.0, "DueDate": "2026-09-30"},
+		Subject:     "Payment Due Reminder",
+		HTMLBody: `<html>
+<body>
+	<h2>Payment Reminder</h2>
+	<p>Hi {{.Name}},</p>
+	<p>This is a reminder that your payment is due on <strong>{{.DueDate}}</strong>.</p>
+	<p><strong>Amount Due: ${{printf "%.2f" .Amount}}</strong></p>
+	<p>Please make payment through your student portal or contact the finance office.</p>` + emailSignature,
+	},
+	{
+		Key:         EmailEnrollmentApproved,
+		Description: "Sent to a student when an enrollment is approved",
+		Sample:      map[string]interface{}{"Name": "Jane Doe", "CourseName": "Algebra I"},
+		Subject:     "Enrollment Approved - {{.CourseName}}",
+		HTMLBody: `<html>
+<body>
+	<h2>Enrollment Approved</h2>
+	<p>Hi {{.Name}},</p>
+	<p>Your enrollment in <strong>{{.CourseName}}</strong> has been approved!</p>
+	<p>You can now access course materials and participate in classes.</p>` + emailSignature,
+	},
+	{
+		Key:         EmailAttendanceAlert,
+		Description: "Sent to a student whose attendance in a course is low",
+		Sample:      map[string]interface{}{"Name": "Jane Doe", "CourseName": "Algebra I", "AttendancePercent": 72.5},
+		Subject:     "Attendance Alert - {{.CourseName}}",
+		HTMLBody: `<html>
+<body>
+	<h2>Attendance Alert</h2>
+	<p>Hi {{.Name}},</p>
+	<p>Your attendance in <strong>{{.CourseName}}</strong> is concerning.</p>
+	<p><strong>Current Attendance: {{printf "%.1f" .AttendancePercent}}%</strong></p>
+	<p>Please contact your instructor or the registrar if you need assistance.</p>` + emailSignature,
+	},
+	{
+		Key:         EmailGuardianAttendanceAlert,
+		Description: "Copy of a low attendance alert sent to the student's guardians",
+		Sample:      map[string]interface{}{"StudentName": "Jane Doe", "CourseName": "Algebra I", "AttendancePercent": 72.5},
+		Subject:     "Attendance Alert for {{.StudentName}} - {{.CourseName}}",
+		HTMLBody: `<html>
+<body>
+	<h2>Attendance Alert</h2>
+	<p>Hello,</p>
+	<p>The attendance of <strong>{{.StudentName}}</strong> in <strong>{{.CourseName}}</strong> is concerning.</p>
+	<p><strong>Current Attendance: {{printf "%.1f" .AttendancePercent}}%</strong></p>
+	<p>Please contact the instructor or the registrar if you have any questions.</p>` + emailSignature,
+	},
+	{
+		Key:         EmailPasswordReset,
+		Description: "Password reset link",
+		Sample:      map[string]interface{}{"Name": "Jane", "Link": "https://school.example.com/reset-password?token=sample", "ValidMinutes": 30},
+		Subject:     "Reset Your Password",
+		HTMLBody: `<html>
+<body>
+	<h2>Password Reset</h2>
+	<p>Hi {{.Name}},</p>
+	<p>We received a request to reset your password. Use the link below to choose a new one:</p>
+	<p><a href="{{.Link}}">Reset your password</a></p>
+	<p>The link expires in {{.ValidMinutes}} minutes and can only be used once. If you did not request a reset, you can ignore this email.</p>` + emailSignature,
+	},
+	{
+		Key:         EmailVerification,
+		Description: "Email address verification link sent on registration",
+		Sample:      map[string]interface{}{"Name": "Jane", "Link": "https://school.example.com/verify-email?token=sample"},
+		Subject:     "Verify Your Email Address",
+		HTMLBody: `<html>
+<body>
+	<h2>Welcome</h2>
+	<p>Hi {{.Name}},</p>
+	<p>Please confirm your email address by following the link below:</p>
+	<p><a href="{{.Link}}">Verify your email</a></p>` + emailSignature,
+	},
+	{
+		Key:         EmailPasswordChanged,
+		Description: "Tells a user their password was changed",
+		Sample:      map[string]interface{}{"Name": "Jane"},
+		Subject:     "Your Password Was Changed",
+		HTMLBody: `<html>
+<body>
+	<h2>Password Changed</h2>
+	<p>Hi {{.Name}},</p>
+	<p>The password for your account was just changed and all other sessions were signed out.</p>
+	<p>If you did not make this change, reset your password immediately and contact the school administrator.</p>` + emailSignature,
+	},
+	{
+		Key:         EmailAccountLocked,
+		Description: "Tells a user their account was locked after failed sign-ins",
+		Sample:      map[string]interface{}{"Name": "Jane", "IPAddress": "203.0.113.7", "LockMinutes": 15},
+		Subject:     "Your Account Was Temporarily Locked",
+		HTMLBody: `<html>
+<body>
+	<h2>Account Locked</h2>
+	<p>Hi {{.Name}},</p>
+	<p>Your account was locked for <strong>{{.LockMinutes}} minutes</strong> after repeated failed sign-in attempts (last from {{.IPAddress}}).</p>
+	<p>If this was not you, we recommend resetting your password once the lock expires. An administrator can also unlock your account.</p>` + emailSignature,
+	},
+	{
+		Key:         EmailNotification,
+		Description: "Notifications without an email of their own, such as absences and assignment reminders",
+		Sample:      map[string]interface{}{"Title": "Marked absent", "Message": "You were marked absent from Algebra I on Monday, September 14."},
+		Subject:     "{{.Title}}",
+		HTMLBody: `<html>
+<body>
+	<h2>{{.Title}}</h2>
+	<p>{{nl2br .Message}}</p>` + emailSignature,
+	},
+}
@@ -0,0 +1,251 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"school-management-system/internal/models"
+	"school-management-system/internal/repository"
+	"time"
+)
+
+var (
+	ErrInvalidTransition = errors.New("invalid enrollment status change")
+	ErrDeadlinePassed    = errors.New("enrollment deadline has passed")
+)
+
+// enrollmentTransitions lists the statuses each status may change to.
+// Rejected, dropped, withdrawn and completed enrollments are final.
+var enrollmentTransitions = map[string][]string{
+	models.EnrollmentRequested:  {models.EnrollmentApproved, models.EnrollmentRejected, models.EnrollmentDropped},
+	models.EnrollmentApproved:   {models.EnrollmentActive, models.EnrollmentRejected, models.EnrollmentDropped},
+	models.EnrollmentWaitlisted: {models.EnrollmentApproved, models.EnrollmentActive, models.EnrollmentDropped},
+	models.EnrollmentActive:     {models.EnrollmentDropped, models.EnrollmentWithdrawn, models.EnrollmentCompleted},
+}
+
+// CanTransition reports whether an enrollment may change between two
+// statuses
+func CanTransition(from, to string) bool {
+	for _, allowed := range enrollmentTransitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// ChangeStatus moves an enrollment to a new status on behalf of an actor,
+// recording the reason and notifying the student. Approving a request takes
+// a seat, or a waitlist place if there is none, and starts the enrollment
+// straight away unless its term is still to come. Dropping is only allowed
+// until the term's drop deadline; after it students withdraw, which records
+// a W grade on their transcript, until the withdrawal deadline.
+func (s *enrollmentService) ChangeStatus(ctx context.Context, id uint, status string, actorID uint, reason string) (*models.Enrollment, error) {
+	enrollment, err := s.enrollmentRepo.FindByID(id)
+	if err != nil {
+		return nil, errors.New("enrollment not found")
+	}
+	from := enrollment.Status
+	if !CanTransition(from, status) {
+		return nil, fmt.Errorf("%w: an enrollment that is %s cannot become %s", ErrInvalidTransition, from, status)
+	}
+
+	now := time.Now()
+	grade, err := s.checkDeadlines(enrollment, status, reason, now)
+	if err != nil {
+		return nil, err
+	}
+	capacity, err := s.seatCapacity(enrollment)
+	if err != nil {
+		return nil, err
+	}
+
+	transition := &models.EnrollmentTransition{FromStatus: from, ToStatus: status, ActorID: actorRef(actorID), Reason: reason}
+	changed, err := s.enrollmentRepo.Transition(ctx, enrollment, transition, capacity, grade)
+	if err == nil && !changed {
+		if status != models.EnrollmentApproved || from == models.EnrollmentWaitlisted {
+			return nil, rejected(RejectionReason{Code: RejectCourseFull, Message: fmt.Sprintf("%s is full", courseLabel(&enrollment.Course))})
+		}
+		// Approved requests wait for a seat like everyone else
+		transition = &models.EnrollmentTransition{FromStatus: from, ToStatus: models.EnrollmentWaitlisted, ActorID: actorRef(actorID),
+			Reason: joinReason(reason, "approved, but the course is full")}
+		_, err = s.enrollmentRepo.Transition(ctx, enrollment, transition, 0, nil)
+	}
+	if err != nil {
+		return nil, s.transitionError(id, err)
+	}
+	s.afterTransition(ctx, enrollment, transition)
+	if grade != nil {
+		s.regenerateTranscript(enrollment.StudentID)
+	}
+
+	if enrollment.Status == models.EnrollmentApproved {
+		started, err := s.termStarted(enrollment, now)
+		if err != nil {
+			return enrollment, err
+		}
+		if started {
+			transition = &models.EnrollmentTransition{FromStatus: models.EnrollmentApproved, ToStatus: models.EnrollmentActive,
+				ActorID: actorRef(actorID), Reason: "term has started"}
+			if _, err := s.enrollmentRepo.Transition(ctx, enrollment, transition, 0, nil); err != nil {
+				return enrollment, s.transitionError(id, err)
+			}
+			s.afterTransition(ctx, enrollment, transition)
+		}
+	}
+	return enrollment, nil
+}
+
+// MigrateLegacyStatuses moves enrollments saved with a status from before
+// the enrollment lifecycle, such as pending, to the status that replaced it,
+// so they can change status like any other. It returns how many moved.
+func (s *enrollmentService) MigrateLegacyStatuses(ctx context.Context) (int64, error) {
+	var moved int64
+	for from, to := range models.LegacyEnrollmentStatuses {
+		n, err := s.enrollmentRepo.RenameStatus(ctx, from, to)
+		if err != nil {
+			return moved, err
+		}
+		moved += n
+	}
+	return moved, nil
+}
+
+// ActivateStarted starts approved enrollments whose term has begun
+func (s *enrollmentService) ActivateStarted(ctx context.Context, now time.Time) (int, error) {
+	enrollments, err := s.enrollmentRepo.FindApprovedStarted(now)
+	if err != nil {
+		return 0, err
+	}
+	activated := 0
+	for i := range enrollments {
+		enrollment := &enrollments[i]
+		transition := &models.EnrollmentTransition{FromStatus: models.EnrollmentApproved, ToStatus: models.EnrollmentActive, Reason: "term has started"}
+		if _, err := s.enrollmentRepo.Transition(ctx, enrollment, transition, 0, nil); err != nil {
+			if errors.Is(err, repository.ErrEnrollmentChanged) {
+				continue
+			}
+			return activated, err
+		}
+		activated++
+		s.afterTransition(ctx, enrollment, transition)
+	}
+	return activated, nil
+}
+
+func (s *enrollmentService) GetHistory(id uint) ([]models.EnrollmentTransition, error) {
+	if _, err := s.enrollmentRepo.FindByID(id); err != nil {
+		return nil, errors.New("enrollment not found")
+	}
+	return s.enrollmentRepo.FindTransitions(id)
+}
+
+// checkDeadlines applies the term's drop and withdrawal deadlines to a
+// student leaving a seat, returning the W grade a late withdrawal records
+func (s *enrollmentService) checkDeadlines(enrollment *models.Enrollment, status, reason string, now time.Time) (*models.Grade, error) {
+	leaving := status == models.EnrollmentDropped || status == models.EnrollmentWithdrawn
+	if !leaving || !models.EnrollmentHoldsSeat(enrollment.Status) || enrollment.TermID == nil {
+		return nil, nil
+	}
+	term, err := s.termRepo.FindByID(*enrollment.TermID)
+	if err != nil {
+		return nil, nil
+	}
+
+	if status == models.EnrollmentDropped {
+		if term.PastDropDeadline(now) {
+			return nil, fmt.Errorf("%w: the last day to drop was %s, withdraw instead",
+				ErrDeadlinePassed, term.DropDeadline.Format("2006-01-02"))
+		}
+		return nil, nil
+	}
+	if term.PastWithdrawalDeadline(now) {
+		return nil, fmt.Errorf("%w: the last day to withdraw was %s",
+			ErrDeadlinePassed, term.WithdrawalDeadline.Format("2006-01-02"))
+	}
+	if !term.PastDropDeadline(now) {
+		return nil, nil
+	}
+	return &models.Grade{
+		StudentID: enrollment.StudentID,
+		CourseID:  enrollment.CourseID,
+		SectionID: enrollment.SectionID,
+		TermID:    enrollment.TermID,
+		Grade:     models.GradeWithdrawn,
+		Remarks:   reason,
+		Source:    models.GradeSourceWithdrawal,
+		GradedAt:  now,
+	}, nil
+}
+
+// seatCapacity returns the number of seats in an enrollment's section, or
+// without one its course
+func (s *enrollmentService) seatCapacity(enrollment *models.Enrollment) (int, error) {
+	if enrollment.SectionID != nil && *enrollment.SectionID != 0 {
+		section, err := s.sectionRepo.FindByID(*enrollment.SectionID)
+		if err != nil {
+			return 0, errors.New("section not found")
+		}
+		return section.Capacity, nil
+	}
+	return enrollment.Course.MaxStudents, nil
+}
+
+// termStarted reports whether an enrollment's term has begun. Enrollments
+// without a term start as soon as they are approved.
+func (s *enrollmentService) termStarted(enrollment *models.Enrollment, now time.Time) (bool, error) {
+	if enrollment.TermID == nil {
+		return true, nil
+	}
+	term, err := s.termRepo.FindByID(*enrollment.TermID)
+	if err != nil {
+		return true, nil
+	}
+	return !now.Before(term.StartDate), nil
+}
+
+// regenerateTranscript rebuilds a student's transcript after a withdrawal
+// recorded a W grade. The withdrawal stands if it fails; the next rebuild
+// catches up.
+func (s *enrollmentService) regenerateTranscript(studentID uint) {
+	if s.transcripts == nil {
+		return
+	}
+	if _, err := s.transcripts.RegenerateForStudent(studentID); err != nil {
+		s.logger.WithError(err).WithField("student_id", studentID).Warn("Failed to regenerate transcript")
+	}
+}
+
+func (s *enrollmentService) transitionError(id uint, err error) error {
+	if errors.Is(err, repository.ErrEnrollmentChanged) {
+		return fmt.Errorf("%w: the enrollment was changed by someone else, reload it and try again", ErrInvalidTransition)
+	}
+	s.logger.WithError(err).WithField("id", id).Error("Failed to change enrollment status")
+	return errors.New("failed to update enrollment")
+}
+
+// afterTransition notifies the student of a change, and offers a seat the
+// student gave up to the waitlist
+func (s *enrollmentService) afterTransition(ctx context.Context, enrollment *models.Enrollment, transition *models.EnrollmentTransition) {
+	s.logger.WithField("id", enrollment.ID).WithField("from", transition.FromStatus).WithField("to", transition.ToStatus).
+		Info("Enrollment status changed")
+	notifyEnrollmentChanged(s.notifier, enrollment, transition)
+	if models.EnrollmentHoldsSeat(transition.FromStatus) && !models.EnrollmentHoldsSeat(transition.ToStatus) &&
+		transition.ToStatus != models.EnrollmentCompleted {
+		s.releaseSeat(ctx, enrollment)
+	}
+}
+
+func actorRef(actorID uint) *uint {
+	if actorID == 0 {
+		return nil
+	}
+	return &actorID
+}
+
+func joinReason(reason, note string) string {
+	if reason == "" {
+		return note
+	}
+	return reason + "; " + note
+}
@@ -0,0 +1,466 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"school-management-system/internal/models"
+	"school-management-system/internal/repository"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Enrollment rejection codes
+const (
+	RejectAlreadyEnrolled     = "already_enrolled"
+	RejectSectionUnavailable  = "section_unavailable"
+	RejectCourseFull          = "course_full"
+	RejectPrerequisiteMissing = "prerequisite_missing"
+	RejectCorequisiteMissing  = "corequisite_missing"
+	RejectScheduleConflict    = "schedule_conflict"
+)
+
+var (
+	ErrInvalidRequisite  = errors.New("invalid requisite")
+	ErrRequisiteNotFound = errors.New("requisite not found")
+)
+
+// RejectionReason is one rule an enrollment broke. CourseID names the
+// missing requisite or the clashing course, and TimetableID the clashing
+// class.
+type RejectionReason struct {
+	Code        string `json:"code"`
+	Message     string `json:"message"`
+	CourseID    uint   `json:"course_id,omitempty"`
+	TimetableID uint   `json:"timetable_id,omitempty"`
+}
+
+// EnrollmentRejectedError lists every rule an enrollment broke
+type EnrollmentRejectedError struct {
+	Reasons []RejectionReason `json:"reasons"`
+}
+
+func (e *EnrollmentRejectedError) Error() string {
+	messages := make([]string, len(e.Reasons))
+	for i, reason := range e.Reasons {
+		messages[i] = reason.Message
+	}
+	return "enrollment rejected: " + strings.Join(messages, "; ")
+}
+
+func rejected(reasons ...RejectionReason) error {
+	return &EnrollmentRejectedError{Reasons: reasons}
+}
+
+// seatTarget is the section or course an enrollment takes a seat in
+type seatTarget struct {
+	course   *models.Course
+	section  *models.CourseSection
+	termID   *uint
+	capacity int
+}
+
+// resolveSeats looks up what an enrollment is for and how many seats it has
+func (s *enrollmentService) resolveSeats(enrollment *models.Enrollment) (*seatTarget, error) {
+	if enrollment.SectionID != nil && *enrollment.SectionID != 0 {
+		section, err := s.sectionRepo.FindByID(*enrollment.SectionID)
+		if err != nil {
+			return nil, errors.New("section not found")
+		}
+		termID := section.TermID
+		return &seatTarget{course: &section.Course, section: section, termID: &termID, capacity: section.Capacity}, nil
+	}
+
+	course, err := s.courseRepo.FindByID(enrollment.CourseID)
+	if err != nil {
+		return nil, errors.New("course not found")
+	}
+	return &seatTarget{course: course, capacity: course.MaxStudents}, nil
+}
+
+// unavailable returns why a section that is not open takes no enrollments
+func (t *seatTarget) unavailable() *RejectionReason {
+	if t.section == nil || t.section.Status == "" || t.section.Status == "open" {
+		return nil
+	}
+	return &RejectionReason{
+		Code:    RejectSectionUnavailable,
+		Message: fmt.Sprintf("section %s is %s", t.section.SectionCode, t.section.Status),
+	}
+}
+
+// checkRequisites returns a reason for each prerequisite the student has
+// not passed, and each co-requisite they have neither passed nor are taking
+// in the same term, counting the courses of the same plan as taken
+func (s *enrollmentService) checkRequisites(studentID uint, target *seatTarget, plan map[uint]bool) ([]RejectionReason, error) {
+	requisites, err := s.requisiteRepo.FindByCourseID(target.course.ID)
+	if err != nil || len(requisites) == 0 {
+		return nil, err
+	}
+	passed, err := s.passedCourses(studentID)
+	if err != nil {
+		return nil, err
+	}
+
+	var taking map[uint]bool
+	var reasons []RejectionReason
+	for _, requisite := range requisites {
+		if passed[requisite.RequiredCourseID] {
+			continue
+		}
+		name := courseLabel(&requisite.RequiredCourse)
+		if requisite.Kind == models.RequisitePrerequisite {
+			reasons = append(reasons, RejectionReason{
+				Code:     RejectPrerequisiteMissing,
+				Message:  fmt.Sprintf("prerequisite %s has not been passed", name),
+				CourseID: requisite.RequiredCourseID,
+			})
+			continue
+		}
+
+		if taking == nil {
+			if taking, err = s.coursesTaking(studentID, target.termID); err != nil {
+				return nil, err
+			}
+		}
+		if !taking[requisite.RequiredCourseID] && !plan[requisite.RequiredCourseID] {
+			reasons = append(reasons, RejectionReason{
+				Code:     RejectCorequisiteMissing,
+				Message:  fmt.Sprintf("co-requisite %s must be passed or taken in the same term", name),
+				CourseID: requisite.RequiredCourseID,
+			})
+		}
+	}
+	return reasons, nil
+}
+
+// passedCourses returns the courses the student has earned credit for,
+// valuing grades on each course's scale, plus transfer credit accepted as
+// equivalent to a course
+func (s *enrollmentService) passedCourses(studentID uint) (map[uint]bool, error) {
+	grades, err := s.gradeRepo.FindAllByStudentID(studentID)
+	if err != nil {
+		return nil, err
+	}
+	passed := map[uint]bool{}
+	for i := range grades {
+		grade := &grades[i]
+		scale := s.scales.ScaleForCourse(&grade.Course)
+		if grade.Grade != "" {
+			if valueOfLetter(scale, grade.Grade).passed {
+				passed[grade.CourseID] = true
+			}
+		} else if grade.MaxScore > 0 {
+			if band := scale.BandForScore(grade.Score / grade.MaxScore * 100); band != nil && band.IsPassing {
+				passed[grade.CourseID] = true
+			}
+		}
+	}
+
+	transfers, err := s.transferRepo.FindByStudentID(studentID)
+	if err != nil {
+		return nil, err
+	}
+	for _, transfer := range transfers {
+		if transfer.EquivalentCourseID == nil {
+			continue
+		}
+		if transfer.Grade == "" || valueOfLetter(s.scales.ScaleForCourse(nil), transfer.Grade).passed {
+			passed[*transfer.EquivalentCourseID] = true
+		}
+	}
+	return passed, nil
+}
+
+// coursesTaking returns the courses the student is actively enrolled in
+// during a term. Without a term every active enrollment counts.
+func (s *enrollmentService) coursesTaking(studentID uint, termID *uint) (map[uint]bool, error) {
+	enrollments, err := s.enrollmentRepo.FindActiveByStudentID(studentID)
+	if err != nil {
+		return nil, err
+	}
+	taking := map[uint]bool{}
+	for _, enrollment := range enrollments {
+		if termID != nil && enrollment.TermID != nil && *enrollment.TermID != *termID {
+			continue
+		}
+		taking[enrollment.CourseID] = true
+	}
+	return taking, nil
+}
+
+// checkSchedule returns a reason for each of the target's classes that
+// overlaps one the student already attends in the same term
+func (s *enrollmentService) checkSchedule(studentID uint, target *seatTarget) ([]RejectionReason, error) {
+	slots, err := s.targetSlots(target)
+	if err != nil || len(slots) == 0 {
+		return nil, err
+	}
+	busy, err := s.enrollmentRepo.FindActiveTimetable(studentID)
+	if err != nil {
+		return nil, err
+	}
+
+	var reasons []RejectionReason
+	for _, slot := range slots {
+		for _, other := range busy {
+			if !slotsOverlap(&slot, &other) {
+				continue
+			}
+			reasons = append(reasons, RejectionReason{
+				Code: RejectScheduleConflict,
+				Message: fmt.Sprintf("%s %s-%s clashes with %s (%s-%s)", slot.DayOfWeek, slot.StartTime, slot.EndTime,
+					courseLabel(&other.Course), other.StartTime, other.EndTime),
+				CourseID:    other.CourseID,
+				TimetableID: other.ID,
+			})
+		}
+	}
+	return reasons, nil
+}
+
+// targetSlots returns the active classes of a section, or of a course's
+// classes that belong to no section
+func (s *enrollmentService) targetSlots(target *seatTarget) ([]models.TimeTable, error) {
+	var all []models.TimeTable
+	var err error
+	if target.section != nil {
+		all, err = s.timetableRepo.FindBySectionID(target.section.ID)
+	} else {
+		all, err = s.timetableRepo.FindByCourseID(target.course.ID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	slots := all[:0]
+	for _, slot := range all {
+		if slot.IsActive && (target.section != nil || slot.SectionID == nil) {
+			slots = append(slots, slot)
+		}
+	}
+	return slots, nil
+}
+
+// slotsOverlap reports whether two classes meet on the same day at
+// overlapping times in the same term. Classes without a term overlap with
+// any term's.
+func slotsOverlap(a, b *models.TimeTable) bool {
+	if a.TermID != nil && b.TermID != nil && *a.TermID != *b.TermID {
+		return false
+	}
+	if !strings.EqualFold(a.DayOfWeek, b.DayOfWeek) {
+		return false
+	}
+	aStart, ok1 := clockMinutes(a.StartTime)
+	aEnd, ok2 := clockMinutes(a.EndTime)
+	bStart, ok3 := clockMinutes(b.StartTime)
+	bEnd, ok4 := clockMinutes(b.EndTime)
+	if !ok1 || !ok2 || !ok3 || !ok4 {
+		return false
+	}
+	return aStart < bEnd && bStart < aEnd
+}
+
+// clockMinutes parses an HH:MM time into minutes after midnight
+func clockMinutes(clock string) (int, bool) {
+	hours, minutes, found := strings.Cut(strings.TrimSpace(clock), ":")
+	if !found {
+		return 0, false
+	}
+	h, err := strconv.Atoi(hours)
+	if err != nil || h < 0 || h > 24 {
+		return 0, false
+	}
+	m, err := strconv.Atoi(minutes)
+	if err != nil || m < 0 || m > 59 {
+		return 0, false
+	}
+	return h*60 + m, true
+}
+
+func courseLabel(course *models.Course) string {
+	if course.CourseCode != "" {
+		return course.CourseCode
+	}
+	return fmt.Sprintf("course %d", course.ID)
+}
+
+// promoteWaitlist fills the free seats of a section, or of a course's
+// enrollments without a section, from its waitlist in order. Students whose
+// timetable now clashes with the class are passed over and keep their
+// place.
+func (s *enrollmentService) promoteWaitlist(ctx context.Context, courseID uint, sectionID *uint) (int, error) {
+	waitlist, err := s.enrollmentRepo.FindWaitlisted(courseID, sectionID)
+	if err != nil || len(waitlist) == 0 {
+		return 0, err
+	}
+	target, err := s.resolveSeats(&models.Enrollment{CourseID: courseID, SectionID: sectionID})
+	if err != nil {
+		return 0, err
+	}
+	if target.unavailable() != nil {
+		// A section that is no longer open keeps its waitlist as it is
+		return 0, nil
+	}
+
+	promoted := 0
+	for i := range waitlist {
+		enrollment := &waitlist[i]
+		conflicts, err := s.checkSchedule(enrollment.StudentID, target)
+		if err != nil {
+			return promoted, err
+		}
+		if len(conflicts) > 0 {
+			s.logger.WithField("enrollment_id", enrollment.ID).Info("Skipped waitlisted student with a schedule conflict")
+			continue
+		}
+		// Until the term starts a promoted student holds the seat as approved,
+		// and is started with everyone else when it does
+		to := models.EnrollmentActive
+		started, err := s.termStarted(enrollment, time.Now())
+		if err != nil {
+			return promoted, err
+		}
+		if !started {
+			to = models.EnrollmentApproved
+		}
+		transition := &models.EnrollmentTransition{FromStatus: models.EnrollmentWaitlisted, ToStatus: to,
+			Reason: "a seat became available"}
+		ok, err := s.enrollmentRepo.Transition(ctx, enrollment, transition, target.capacity, nil)
+		if errors.Is(err, repository.ErrEnrollmentChanged) {
+			continue
+		}
+		if err != nil {
+			return promoted, err
+		}
+		if !ok {
+			break
+		}
+		promoted++
+		s.afterTransition(ctx, enrollment, transition)
+	}
+	return promoted, nil
+}
+
+// PlannedEnrollment is one enrollment of a plan, with what stops it from
+// being made
+type PlannedEnrollment struct {
+	Enrollment *models.Enrollment
+	Course     *models.Course
+	Section    *models.CourseSection
+	Reasons    []RejectionReason
+}
+
+// CheckPlan checks enrollments a student means to make together, such as a
+// registration cart, without making them. Courses in the plan count towards
+// each other's co-requisites, and their classes must not clash with each
+// other's. A full section is only reported without allowWaitlist.
+func (s *enrollmentService) CheckPlan(studentID uint, requests []*models.Enrollment, allowWaitlist bool) ([]PlannedEnrollment, error) {
+	planned := make([]PlannedEnrollment, len(requests))
+	targets := make([]*seatTarget, len(requests))
+	plan := map[uint]bool{}
+	for i, request := range requests {
+		request.StudentID = studentID
+		planned[i].Enrollment = request
+		target, err := s.resolveSeats(request)
+		if err != nil {
+			planned[i].Reasons = append(planned[i].Reasons, RejectionReason{Code: RejectSectionUnavailable, Message: err.Error()})
+			continue
+		}
+		targets[i] = target
+		request.CourseID = target.course.ID
+		planned[i].Course = target.course
+		planned[i].Section = target.section
+		plan[target.course.ID] = true
+	}
+
+	slots := make([][]models.TimeTable, len(requests))
+	for i, target := range targets {
+		if target == nil {
+			continue
+		}
+		var err error
+		if slots[i], err = s.targetSlots(target); err != nil {
+			return nil, err
+		}
+	}
+
+	for i, target := range targets {
+		if target == nil {
+			continue
+		}
+		item := &planned[i]
+		if reason := target.unavailable(); reason != nil {
+			item.Reasons = append(item.Reasons, *reason)
+		}
+		if reason, err := s.checkDuplicate(studentID, target, targets[:i]); err != nil {
+			return nil, err
+		} else if reason != nil {
+			item.Reasons = append(item.Reasons, *reason)
+		}
+		if !allowWaitlist && target.capacity > 0 {
+			taken, err := s.enrollmentRepo.CountSeats(target.course.ID, item.Enrollment.SectionID)
+			if err != nil {
+				return nil, err
+			}
+			if taken >= target.capacity {
+				item.Reasons = append(item.Reasons, RejectionReason{Code: RejectCourseFull, Message: fmt.Sprintf("%s is full", courseLabel(target.course))})
+			}
+		}
+
+		requisites, err := s.checkRequisites(studentID, target, plan)
+		if err != nil {
+			return nil, err
+		}
+		conflicts, err := s.checkSchedule(studentID, target)
+		if err != nil {
+			return nil, err
+		}
+		item.Reasons = append(item.Reasons, requisites...)
+		item.Reasons = append(item.Reasons, conflicts...)
+
+		for j := range targets {
+			if j == i || targets[j] == nil {
+				continue
+			}
+			for _, slot := range slots[i] {
+				for _, other := range slots[j] {
+					if !slotsOverlap(&slot, &other) {
+						continue
+					}
+					item.Reasons = append(item.Reasons, RejectionReason{
+						Code: RejectScheduleConflict,
+						Message: fmt.Sprintf("%s %s-%s clashes with %s (%s-%s), also being added", slot.DayOfWeek, slot.StartTime, slot.EndTime,
+							courseLabel(targets[j].course), other.StartTime, other.EndTime),
+						CourseID:    targets[j].course.ID,
+						TimetableID: other.ID,
+					})
+				}
+			}
+		}
+	}
+	return planned, nil
+}
+
+// checkDuplicate reports a student who already has an enrollment in the
+// target's course that is not over, or is adding another section of it in
+// the same plan
+func (s *enrollmentService) checkDuplicate(studentID uint, target *seatTarget, earlier []*seatTarget) (*RejectionReason, error) {
+	for _, other := range earlier {
+		if other != nil && other.course.ID == target.course.ID {
+			return &RejectionReason{
+				Code:    RejectAlreadyEnrolled,
+				Message: fmt.Sprintf("another section of %s is also being added", courseLabel(target.course)),
+			}, nil
+		}
+	}
+	enrolled, err := s.enrollmentRepo.HasOpenEnrollment(studentID, target.course.ID, target.termID)
+	if err != nil || !enrolled {
+		return nil, err
+	}
+	return &RejectionReason{
+		Code:    RejectAlreadyEnrolled,
+		Message: fmt.Sprintf("student already enrolled in %s", courseLabel(target.course)),
+	}, nil
+}
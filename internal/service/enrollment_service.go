@@ -1,72 +1,240 @@
 package service
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"school-management-system/internal/models"
 	"school-management-system/internal/repository"
 	"school-management-system/pkg/logger"
 	"time"
 
 	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
 )
 
+// EnrollOptions are the choices made when enrolling a student
+type EnrollOptions struct {
+	// AllowWaitlist puts the student on the waitlist of a full course or
+	// section instead of rejecting them
+	AllowWaitlist bool
+	// Override enrolls the student whatever the rules, seats and section
+	// status say. It is for staff making exceptions, and needs a reason.
+	Override bool
+	// MaxCreditHours is the most credit hours EnrollPlan may bring the
+	// student to in a term, counted as the enrollments are saved; 0 for no
+	// limit
+	MaxCreditHours int
+	ActorID        uint
+	Reason         string
+}
+
 type EnrollmentService interface {
-	EnrollStudent(enrollment *models.Enrollment) error
+	EnrollStudent(ctx context.Context, enrollment *models.Enrollment, options EnrollOptions) error
+	CheckPlan(studentID uint, requests []*models.Enrollment, allowWaitlist bool) ([]PlannedEnrollment, error)
+	EnrollPlan(ctx context.Context, enrollments []*models.Enrollment, options EnrollOptions) error
 	GetEnrollmentByID(id uint) (*models.Enrollment, error)
 	GetStudentEnrollments(studentID uint, page, limit int) ([]models.Enrollment, int64, error)
 	GetCourseEnrollments(courseID uint, page, limit int) ([]models.Enrollment, int64, error)
+	GetSectionEnrollments(sectionID uint, page, limit int) ([]models.Enrollment, int64, error)
 	GetAllEnrollments(page, limit int) ([]models.Enrollment, int64, error)
-	UpdateEnrollmentStatus(id uint, status string) error
-	RemoveEnrollment(id uint) error
+	ChangeStatus(ctx context.Context, id uint, status string, actorID uint, reason string) (*models.Enrollment, error)
+	GetHistory(id uint) ([]models.EnrollmentTransition, error)
+	ActivateStarted(ctx context.Context, now time.Time) (int, error)
+	RemoveEnrollment(ctx context.Context, id uint) error
 	CheckEnrollment(studentID, courseID uint) (bool, error)
 	GetCourseEnrollmentCount(courseID uint) (int64, error)
+	GetWaitlistPosition(enrollment *models.Enrollment) (int64, error)
+	PromoteWaitlists(ctx context.Context) (int, error)
+	MigrateLegacyStatuses(ctx context.Context) (int64, error)
+	AddRequisite(requisite *models.CourseRequisite) error
+	GetRequisites(courseID uint) ([]models.CourseRequisite, error)
+	RemoveRequisite(courseID, id uint) error
 }
 
 type enrollmentService struct {
 	enrollmentRepo repository.EnrollmentRepository
+	courseRepo     repository.CourseRepository
+	sectionRepo    repository.CourseSectionRepository
+	termRepo       repository.AcademicTermRepository
+	requisiteRepo  repository.CourseRequisiteRepository
+	timetableRepo  repository.TimeTableRepository
+	gradeRepo      repository.GradeRepository
+	transferRepo   repository.TransferCreditRepository
+	scales         GradingScaleService
+	transcripts    GradeTranscriptService
+	notifier       NotificationDispatcher
 	logger         *logrus.Logger
 }
 
-func NewEnrollmentService(enrollmentRepo repository.EnrollmentRepository) EnrollmentService {
+func NewEnrollmentService(
+	enrollmentRepo repository.EnrollmentRepository,
+	courseRepo repository.CourseRepository,
+	sectionRepo repository.CourseSectionRepository,
+	termRepo repository.AcademicTermRepository,
+	requisiteRepo repository.CourseRequisiteRepository,
+	timetableRepo repository.TimeTableRepository,
+	gradeRepo repository.GradeRepository,
+	transferRepo repository.TransferCreditRepository,
+	scales GradingScaleService,
+	transcripts GradeTranscriptService,
+	notifier NotificationDispatcher,
+) EnrollmentService {
 	return &enrollmentService{
 		enrollmentRepo: enrollmentRepo,
+		courseRepo:     courseRepo,
+		sectionRepo:    sectionRepo,
+		termRepo:       termRepo,
+		requisiteRepo:  requisiteRepo,
+		timetableRepo:  timetableRepo,
+		gradeRepo:      gradeRepo,
+		transferRepo:   transferRepo,
+		scales:         scales,
+		transcripts:    transcripts,
+		notifier:       notifier,
 		logger:         logger.GetLogger(),
 	}
 }
 
-func (s *enrollmentService) EnrollStudent(enrollment *models.Enrollment) error {
+// EnrollStudent enrolls a student in a course or section after checking
+// its prerequisites, co-requisites and the student's timetable. A full
+// section or course puts the student on its waitlist, or without
+// AllowWaitlist rejects them. Broken rules are returned together as an
+// *EnrollmentRejectedError. A requested enrollment is checked the same way
+// but takes no seat until it is approved. Override skips every rule and
+// may record an enrollment in any status.
+func (s *enrollmentService) EnrollStudent(ctx context.Context, enrollment *models.Enrollment, options EnrollOptions) error {
 	if enrollment.StudentID == 0 {
 		s.logger.Warn("Student ID is required for enrollment")
 		return errors.New("student id is required")
 	}
 
-	if enrollment.CourseID == 0 {
-		s.logger.Warn("Course ID is required for enrollment")
-		return errors.New("course id is required")
+	hasSection := enrollment.SectionID != nil && *enrollment.SectionID != 0
+	if enrollment.CourseID == 0 && !hasSection {
+		s.logger.Warn("Course ID or section ID is required for enrollment")
+		return errors.New("course id or section id is required")
+	}
+	switch enrollment.Status {
+	case "":
+		enrollment.Status = models.EnrollmentActive
+	case models.EnrollmentActive, models.EnrollmentRequested:
+	default:
+		// Staff overriding the rules may record an enrollment in any
+		// status, such as a past one
+		if !options.Override || !models.ValidEnrollmentStatus(enrollment.Status) {
+			return errors.New("status must be active or requested")
+		}
+	}
+
+	if options.Override && options.Reason == "" {
+		return errors.New("a reason is required to override enrollment rules")
 	}
 
-	// Check if already enrolled
-	existing, _ := s.enrollmentRepo.FindByStudentAndCourse(enrollment.StudentID, enrollment.CourseID)
-	if existing != nil {
-		s.logger.WithField("student_id", enrollment.StudentID).WithField("course_id", enrollment.CourseID).Warn("Student already enrolled in course")
-		return errors.New("student already enrolled in this course")
+	target, err := s.resolveSeats(enrollment)
+	if err != nil {
+		return err
+	}
+	enrollment.CourseID = target.course.ID
+	log := s.logger.WithField("student_id", enrollment.StudentID).WithField("course_id", enrollment.CourseID)
+
+	capacity := target.capacity
+	if options.Override {
+		capacity = 0
+		log.WithField("actor_id", options.ActorID).WithField("reason", options.Reason).Warn("Enrollment rules overridden")
+	} else {
+		if reason := target.unavailable(); reason != nil {
+			return rejected(*reason)
+		}
+		var reasons []RejectionReason
+		for _, check := range []func(uint, *seatTarget) ([]RejectionReason, error){
+			func(studentID uint, target *seatTarget) ([]RejectionReason, error) {
+				return s.checkRequisites(studentID, target, nil)
+			},
+			s.checkSchedule,
+		} {
+			found, err := check(enrollment.StudentID, target)
+			if err != nil {
+				log.WithError(err).Error("Failed to check enrollment rules")
+				return errors.New("failed to enroll student")
+			}
+			reasons = append(reasons, found...)
+		}
+		if len(reasons) > 0 {
+			log.WithField("reasons", len(reasons)).Warn("Enrollment rejected")
+			return rejected(reasons...)
+		}
 	}
 
 	if enrollment.EnrolledAt.IsZero() {
 		enrollment.EnrolledAt = time.Now()
 	}
+	if enrollment.Status == models.EnrollmentWaitlisted && enrollment.WaitlistedAt == nil {
+		now := time.Now()
+		enrollment.WaitlistedAt = &now
+	}
 
-	if enrollment.Status == "" {
-		enrollment.Status = "active"
+	transition := &models.EnrollmentTransition{ActorID: actorRef(options.ActorID), Reason: options.Reason}
+	saved, err := s.enrollmentRepo.EnrollWithinCapacity(ctx, enrollment, capacity, options.AllowWaitlist, transition)
+	switch {
+	case errors.Is(err, gorm.ErrDuplicatedKey):
+		log.Warn("Student already enrolled in course")
+		return rejected(RejectionReason{Code: RejectAlreadyEnrolled, Message: "student already enrolled in this course"})
+	case err != nil:
+		log.WithError(err).Error("Failed to enroll student")
+		return errors.New("failed to enroll student")
+	case !saved:
+		return rejected(RejectionReason{Code: RejectCourseFull, Message: fmt.Sprintf("%s is full", courseLabel(target.course))})
 	}
 
-	err := s.enrollmentRepo.Create(enrollment)
-	if err != nil {
-		s.logger.WithError(err).WithField("student_id", enrollment.StudentID).WithField("course_id", enrollment.CourseID).Error("Failed to enroll student")
+	log.WithField("status", enrollment.Status).Info("Student enrolled successfully")
+	notifyEnrollmentChanged(s.notifier, enrollment, transition)
+	return nil
+}
+
+// EnrollPlan makes enrollments checked together with CheckPlan, all or
+// none. If any section or course has filled up in the meantime, and
+// AllowWaitlist is unset, none are made and the full one is reported.
+func (s *enrollmentService) EnrollPlan(ctx context.Context, enrollments []*models.Enrollment, options EnrollOptions) error {
+	capacities := make([]int, len(enrollments))
+	transitions := make([]*models.EnrollmentTransition, len(enrollments))
+	courses := make([]*models.Course, len(enrollments))
+	for i, enrollment := range enrollments {
+		target, err := s.resolveSeats(enrollment)
+		if err != nil {
+			return err
+		}
+		enrollment.CourseID = target.course.ID
+		if enrollment.Status == "" {
+			enrollment.Status = models.EnrollmentActive
+		}
+		if enrollment.EnrolledAt.IsZero() {
+			enrollment.EnrolledAt = time.Now()
+		}
+		courses[i] = target.course
+		capacities[i] = target.capacity
+		transitions[i] = &models.EnrollmentTransition{ActorID: actorRef(options.ActorID), Reason: options.Reason}
+	}
+
+	full, err := s.enrollmentRepo.EnrollAllWithinCapacity(ctx, enrollments, capacities, options.AllowWaitlist, options.MaxCreditHours, transitions)
+	switch {
+	case errors.Is(err, gorm.ErrDuplicatedKey):
+		return rejected(RejectionReason{Code: RejectAlreadyEnrolled, Message: "student already enrolled in one of the courses"})
+	case errors.Is(err, repository.ErrCreditLimit):
+		return rejected(RejectionReason{Code: RejectCreditLimit,
+			Message: fmt.Sprintf("registering would bring a term over the limit of %d credit hours", options.MaxCreditHours)})
+	case err != nil:
+		s.logger.WithError(err).Error("Failed to enroll student")
 		return errors.New("failed to enroll student")
+	case full >= 0:
+		return rejected(RejectionReason{Code: RejectCourseFull, Message: fmt.Sprintf("%s is full", courseLabel(courses[full])),
+			CourseID: courses[full].ID})
 	}
 
-	s.logger.WithField("student_id", enrollment.StudentID).WithField("course_id", enrollment.CourseID).Info("Student enrolled successfully")
+	for i, enrollment := range enrollments {
+		s.logger.WithField("student_id", enrollment.StudentID).WithField("course_id", enrollment.CourseID).
+			WithField("status", enrollment.Status).Info("Student enrolled successfully")
+		notifyEnrollmentChanged(s.notifier, enrollment, transitions[i])
+	}
 	return nil
 }
 
@@ -114,6 +282,23 @@ func (s *enrollmentService) GetCourseEnrollments(courseID uint, page, limit int)
 	return enrollments, total, nil
 }
 
+func (s *enrollmentService) GetSectionEnrollments(sectionID uint, page, limit int) ([]models.Enrollment, int64, error) {
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 {
+		limit = 10
+	}
+
+	enrollments, total, err := s.enrollmentRepo.FindBySectionID(sectionID, page, limit)
+	if err != nil {
+		s.logger.WithError(err).WithField("section_id", sectionID).Error("Failed to fetch enrollments")
+		return nil, 0, err
+	}
+
+	return enrollments, total, nil
+}
+
 func (s *enrollmentService) GetAllEnrollments(page, limit int) ([]models.Enrollment, int64, error) {
 	if page < 1 {
 		page = 1
@@ -131,34 +316,33 @@ func (s *enrollmentService) GetAllEnrollments(page, limit int) ([]models.Enrollm
 	return enrollments, total, nil
 }
 
-func (s *enrollmentService) UpdateEnrollmentStatus(id uint, status string) error {
+func (s *enrollmentService) RemoveEnrollment(ctx context.Context, id uint) error {
 	enrollment, err := s.enrollmentRepo.FindByID(id)
 	if err != nil {
 		return errors.New("enrollment not found")
 	}
 
-	enrollment.Status = status
-	err = s.enrollmentRepo.Update(enrollment)
-	if err != nil {
-		s.logger.WithError(err).WithField("id", id).Error("Failed to update enrollment")
-		return errors.New("failed to update enrollment")
-	}
-
-	s.logger.WithField("id", id).WithField("status", status).Info("Enrollment status updated")
-	return nil
-}
-
-func (s *enrollmentService) RemoveEnrollment(id uint) error {
-	err := s.enrollmentRepo.Delete(id)
+	err = s.enrollmentRepo.Delete(ctx, id)
 	if err != nil {
 		s.logger.WithError(err).WithField("id", id).Error("Failed to remove enrollment")
 		return errors.New("failed to remove enrollment")
 	}
 
 	s.logger.WithField("id", id).Info("Enrollment removed")
+	if models.EnrollmentHoldsSeat(enrollment.Status) {
+		s.releaseSeat(ctx, enrollment)
+	}
 	return nil
 }
 
+// releaseSeat promotes from the waitlist of the seat an enrollment gave up.
+// A failure is only logged; the waitlist job retries it.
+func (s *enrollmentService) releaseSeat(ctx context.Context, enrollment *models.Enrollment) {
+	if _, err := s.promoteWaitlist(ctx, enrollment.CourseID, enrollment.SectionID); err != nil {
+		s.logger.WithError(err).WithField("id", enrollment.ID).Error("Failed to promote from the waitlist")
+	}
+}
+
 func (s *enrollmentService) CheckEnrollment(studentID, courseID uint) (bool, error) {
 	enrollment, err := s.enrollmentRepo.FindByStudentAndCourse(studentID, courseID)
 	if err != nil {
@@ -171,3 +355,119 @@ func (s *enrollmentService) CheckEnrollment(studentID, courseID uint) (bool, err
 func (s *enrollmentService) GetCourseEnrollmentCount(courseID uint) (int64, error) {
 	return s.enrollmentRepo.CountByCourseID(courseID)
 }
+
+// GetWaitlistPosition returns where a waitlisted enrollment is in its queue,
+// counting from one, or zero if it is not waitlisted
+func (s *enrollmentService) GetWaitlistPosition(enrollment *models.Enrollment) (int64, error) {
+	if enrollment.Status != models.EnrollmentWaitlisted {
+		return 0, nil
+	}
+	return s.enrollmentRepo.WaitlistPosition(enrollment)
+}
+
+// PromoteWaitlists fills free seats from every waitlist, such as those
+// freed by raising a capacity, and returns how many students got a seat
+func (s *enrollmentService) PromoteWaitlists(ctx context.Context) (int, error) {
+	pools, err := s.enrollmentRepo.FindWaitlistedPools()
+	if err != nil {
+		return 0, err
+	}
+	total := 0
+	for _, pool := range pools {
+		promoted, err := s.promoteWaitlist(ctx, pool.CourseID, pool.SectionID)
+		total += promoted
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+// AddRequisite makes one course require another. A course cannot require
+// itself, or a course that already requires it.
+func (s *enrollmentService) AddRequisite(requisite *models.CourseRequisite) error {
+	if requisite.Kind == "" {
+		requisite.Kind = models.RequisitePrerequisite
+	}
+	if requisite.Kind != models.RequisitePrerequisite && requisite.Kind != models.RequisiteCorequisite {
+		return fmt.Errorf("%w: kind must be prerequisite or corequisite", ErrInvalidRequisite)
+	}
+	if requisite.RequiredCourseID == 0 {
+		return fmt.Errorf("%w: required course id is required", ErrInvalidRequisite)
+	}
+	if requisite.RequiredCourseID == requisite.CourseID {
+		return fmt.Errorf("%w: a course cannot require itself", ErrInvalidRequisite)
+	}
+	if _, err := s.courseRepo.FindByID(requisite.CourseID); err != nil {
+		return errors.New("course not found")
+	}
+	if _, err := s.courseRepo.FindByID(requisite.RequiredCourseID); err != nil {
+		return fmt.Errorf("%w: required course not found", ErrInvalidRequisite)
+	}
+
+	cyclic, err := s.requires(requisite.RequiredCourseID, requisite.CourseID)
+	if err != nil {
+		return err
+	}
+	if cyclic {
+		return fmt.Errorf("%w: the required course already requires this course", ErrInvalidRequisite)
+	}
+
+	requisite.ID = 0
+	requisite.CreatedAt = time.Now().Unix()
+	if err := s.requisiteRepo.Create(requisite); err != nil {
+		if existing, findErr := s.requisiteRepo.FindByCourseID(requisite.CourseID); findErr == nil {
+			for _, other := range existing {
+				if other.RequiredCourseID == requisite.RequiredCourseID {
+					return fmt.Errorf("%w: the course is already required", ErrInvalidRequisite)
+				}
+			}
+		}
+		s.logger.WithError(err).WithField("course_id", requisite.CourseID).Error("Failed to add requisite")
+		return err
+	}
+
+	s.logger.WithField("course_id", requisite.CourseID).WithField("required_course_id", requisite.RequiredCourseID).Info("Course requisite added")
+	return nil
+}
+
+// requires reports whether a course requires another, directly or through
+// the courses it requires
+func (s *enrollmentService) requires(courseID, requiredID uint) (bool, error) {
+	seen := map[uint]bool{courseID: true}
+	queue := []uint{courseID}
+	for len(queue) > 0 {
+		requisites, err := s.requisiteRepo.FindByCourseID(queue[0])
+		if err != nil {
+			return false, err
+		}
+		queue = queue[1:]
+		for _, requisite := range requisites {
+			if requisite.RequiredCourseID == requiredID {
+				return true, nil
+			}
+			if !seen[requisite.RequiredCourseID] {
+				seen[requisite.RequiredCourseID] = true
+				queue = append(queue, requisite.RequiredCourseID)
+			}
+		}
+	}
+	return false, nil
+}
+
+func (s *enrollmentService) GetRequisites(courseID uint) ([]models.CourseRequisite, error) {
+	return s.requisiteRepo.FindByCourseID(courseID)
+}
+
+func (s *enrollmentService) RemoveRequisite(courseID, id uint) error {
+	requisite, err := s.requisiteRepo.FindByID(id)
+	if err != nil || requisite.CourseID != courseID {
+		return ErrRequisiteNotFound
+	}
+	if err := s.requisiteRepo.Delete(id); err != nil {
+		s.logger.WithError(err).WithField("id", id).Error("Failed to remove requisite")
+		return err
+	}
+	s.logger.WithField("id", id).WithField("course_id", courseID).Info("Course requisite removed")
+	return nil
+}
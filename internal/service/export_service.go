@@ -13,12 +13,13 @@ import (
 
 // ExportService handles CSV and report generation
 type ExportService struct {
-	db *gorm.DB
+	db     *gorm.DB
+	scales GradingScaleService
 }
 
 // NewExportService creates a new export service
-func NewExportService(db *gorm.DB) *ExportService {
-	return &ExportService{db: db}
+func NewExportService(db *gorm.DB, scales GradingScaleService) *ExportService {
+	return &ExportService{db: db, scales: scales}
 }
 
 // ExportPaymentsCSV generates CSV of payments
@@ -74,20 +75,36 @@ func (es *ExportService) ExportGradesCSV(courseID uint) ([]byte, error) {
 		return nil, err
 	}
 
+	scale := es.scales.ScaleForCourse(nil)
+	var course models.Course
+	if err := db.First(&course, courseID).Error; err == nil {
+		scale = es.scales.ScaleForCourse(&course)
+	}
+
 	var b bytes.Buffer
 	w := csv.NewWriter(&b)
 
 	// Write header
-	w.Write([]string{"ID", "Student ID", "Course ID", "Score", "Grade", "Graded At"})
+	w.Write([]string{"ID", "Student ID", "Course ID", "Score", "Grade", "Grade Points", "Passing", "Graded At"})
 
 	// Write data
 	for _, g := range grades {
+		points, passing := "", ""
+		if band := scale.BandForLetter(g.Grade); band != nil {
+			points = fmt.Sprintf("%.2f", band.GradePoints)
+			passing = "No"
+			if band.IsPassing {
+				passing = "Yes"
+			}
+		}
 		w.Write([]string{
 			fmt.Sprintf("%d", g.ID),
 			fmt.Sprintf("%d", g.StudentID),
 			fmt.Sprintf("%d", g.CourseID),
 			fmt.Sprintf("%.2f", g.Score),
 			g.Grade,
+			points,
+			passing,
 			g.GradedAt.Format(time.RFC3339),
 		})
 	}
@@ -144,7 +161,7 @@ func (es *ExportService) ExportStudentTranscriptCSV(studentID uint) ([]byte, err
 	db := es.db
 	var transcripts []models.GradeTranscript
 
-	if err := db.Where("student_id = ?", studentID).Order("academic_year DESC, term DESC").Find(&transcripts).Error; err != nil {
+	if err := db.Where("student_id = ?", studentID).Order("year DESC, transcript_semester DESC").Find(&transcripts).Error; err != nil {
 		return nil, err
 	}
 
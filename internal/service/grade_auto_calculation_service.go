@@ -1,159 +1,108 @@
 package service
 
 import (
+	"context"
 	"fmt"
 	"time"
 
 	"school-management-system/internal/models"
 	"school-management-system/pkg/database"
+	"school-management-system/pkg/logger"
 )
 
 // GradeAutoCalculationService handles automatic grade calculations
 type GradeAutoCalculationService struct {
 	gradeTranscriptService GradeTranscriptService
-	emailService           *EmailService
+	gradingScaleService    GradingScaleService
+	notifier               NotificationDispatcher
 }
 
 // NewGradeAutoCalculationService creates a new service
 func NewGradeAutoCalculationService(
 	gradeTranscriptService GradeTranscriptService,
-	emailService *EmailService,
+	gradingScaleService GradingScaleService,
+	notifier NotificationDispatcher,
 ) *GradeAutoCalculationService {
 	return &GradeAutoCalculationService{
 		gradeTranscriptService: gradeTranscriptService,
-		emailService:           emailService,
+		gradingScaleService:    gradingScaleService,
+		notifier:               notifier,
 	}
 }
 
-// CalculateLetterGrade converts numeric score to letter grade
-func (gacs *GradeAutoCalculationService) CalculateLetterGrade(score float64) string {
-	if score >= 90 {
-		return "A"
-	} else if score >= 80 {
-		return "B"
-	} else if score >= 70 {
-		return "C"
-	} else if score >= 60 {
-		return "D"
+// CalculateLetterGrade converts a percentage score to a letter on the given scale
+func (gacs *GradeAutoCalculationService) CalculateLetterGrade(scale *models.GradingScale, score float64) string {
+	if band := scale.BandForScore(score); band != nil {
+		return band.Letter
 	}
-	return "F"
+	return ""
 }
 
-// CalculateGradePoints converts letter grade to grade points
-func (gacs *GradeAutoCalculationService) CalculateGradePoints(letterGrade string) float64 {
-	gradePoints := map[string]float64{
-		"A": 4.0,
-		"B": 3.0,
-		"C": 2.0,
-		"D": 1.0,
-		"F": 0.0,
+// CalculateGradePoints converts a letter on the given scale to grade points
+func (gacs *GradeAutoCalculationService) CalculateGradePoints(scale *models.GradingScale, letterGrade string) float64 {
+	if band := scale.BandForLetter(letterGrade); band != nil {
+		return band.GradePoints
 	}
-	return gradePoints[letterGrade]
+	return 0
 }
 
-// RecordGradeAndAutoCalculate records grade and auto-calculates letter grade
-func (gacs *GradeAutoCalculationService) RecordGradeAndAutoCalculate(grade *models.Grade) error {
-	db := database.DB
-
-	// Auto-calculate letter grade
-	grade.Grade = gacs.CalculateLetterGrade(grade.Score)
-
-	// Save grade
-	if err := db.Create(grade).Error; err != nil {
-		return err
+// scaleForCourse loads the course and resolves its grading scale
+func (gacs *GradeAutoCalculationService) scaleForCourse(courseID uint) *models.GradingScale {
+	var course models.Course
+	if err := database.DB.First(&course, courseID).Error; err != nil {
+		return gacs.gradingScaleService.ScaleForCourse(nil)
 	}
-
-	// Trigger automatic actions
-	go func() {
-		// Get student info for email
-		var student models.Student
-		if err := db.Preload("User").First(&student, grade.StudentID).Error; err == nil {
-			// Get course info
-			var course models.Course
-			if err := db.First(&course, grade.CourseID).Error; err == nil {
-				// Send grade notification email
-				gacs.emailService.SendGradeNotification(
-					student.User.Email,
-					student.User.FirstName+" "+student.User.LastName,
-					course.Name,
-					fmt.Sprintf("%s (%.1f%%)", grade.Grade, grade.Score),
-				)
-			}
-		}
-
-		// Update transcript
-		gacs.updateStudentTranscript(grade.StudentID)
-	}()
-
-	return nil
+	return gacs.gradingScaleService.ScaleForCourse(&course)
 }
 
-// updateStudentTranscript updates student's grade transcript
-func (gacs *GradeAutoCalculationService) updateStudentTranscript(studentID uint) error {
+// RecordGradeAndAutoCalculate records grade and auto-calculates letter grade
+func (gacs *GradeAutoCalculationService) RecordGradeAndAutoCalculate(ctx context.Context, grade *models.Grade) error {
 	db := database.DB
 
-	// Get all grades for student in current term
-	var grades []models.Grade
-	if err := db.Where("student_id = ?", studentID).Find(&grades).Error; err != nil {
-		return err
+	// Grades recorded against a section belong to the section's course
+	if grade.CourseID == 0 && grade.SectionID != nil {
+		var section models.CourseSection
+		if err := db.First(&section, *grade.SectionID).Error; err != nil {
+			return err
+		}
+		grade.CourseID = section.CourseID
 	}
 
-	if len(grades) == 0 {
-		return nil
+	// Auto-calculate letter grade on the course's grading scale
+	percentage := grade.Score
+	if grade.MaxScore > 0 {
+		percentage = grade.Score / grade.MaxScore * 100
+	}
+	grade.Grade = gacs.CalculateLetterGrade(gacs.scaleForCourse(grade.CourseID), percentage)
+	if grade.GradedAt.IsZero() {
+		grade.GradedAt = time.Now()
 	}
 
-	// Calculate GPA
-	var totalGradePoints float64
-	var totalCredits float64
-
-	for _, grade := range grades {
-		// Get course for credits
-		var course models.Course
-		if err := db.First(&course, grade.CourseID).Error; err == nil {
-			gradePoints := gacs.CalculateGradePoints(grade.Grade)
-			totalGradePoints += gradePoints * float64(course.CreditHours)
-			totalCredits += float64(course.CreditHours)
+	// Grades recorded against a course rather than a section are attributed to
+	// the term in session when they were graded.
+	if grade.TermID == nil && (grade.SectionID == nil || *grade.SectionID == 0) {
+		var term models.AcademicTerm
+		if err := db.Where("start_date <= ? AND end_date >= ?", grade.GradedAt, grade.GradedAt).
+			Order("start_date DESC").First(&term).Error; err == nil {
+			grade.TermID = &term.ID
 		}
 	}
 
-	var gpa float64
-	if totalCredits > 0 {
-		gpa = totalGradePoints / totalCredits
+	// Save grade
+	if err := db.WithContext(ctx).Create(grade).Error; err != nil {
+		return err
 	}
 
-	// Update or create transcript
-	var transcript models.GradeTranscript
-	// Determine current semester and year
-	now := time.Now()
-	year := now.Year()
-	semester := "Fall"
-	if now.Month() <= 6 {
-		semester = "Spring"
-	}
+	// Trigger automatic actions
+	go func() {
+		// Tell the student and their guardians
+		notifyGradePosted(gacs.notifier, grade)
 
-	if err := db.Where("student_id = ? AND transcript_semester = ? AND year = ?",
-		studentID, semester, year).First(&transcript).Error; err != nil {
-		// Create new transcript
-		transcript = models.GradeTranscript{
-			StudentID:          studentID,
-			TranscriptSemester: semester,
-			Year:               year,
-			GPA:                gpa,
-			TotalCredits:       totalCredits,
-			EarnedCredits:      totalCredits,
-			GradePointsSum:     totalGradePoints,
-			GeneratedAt:        time.Now().Unix(),
+		// Update transcript
+		if _, err := gacs.gradeTranscriptService.RegenerateForStudent(grade.StudentID); err != nil {
+			logger.GetLogger().WithError(err).WithField("student_id", grade.StudentID).Warn("Failed to regenerate transcript")
 		}
-		db.Create(&transcript)
-	} else {
-		// Update existing transcript
-		transcript.GPA = gpa
-		transcript.TotalCredits = totalCredits
-		transcript.EarnedCredits = totalCredits
-		transcript.GradePointsSum = totalGradePoints
-		transcript.GeneratedAt = time.Now().Unix()
-		db.Save(&transcript)
-	}
+	}()
 
 	return nil
 }
@@ -183,12 +132,9 @@ func (gacs *GradeAutoCalculationService) CalculateClassGradeDistribution(courseI
 		return nil, err
 	}
 
-	distribution := map[string]int{
-		"A": 0,
-		"B": 0,
-		"C": 0,
-		"D": 0,
-		"F": 0,
+	distribution := make(map[string]int)
+	for _, band := range gacs.scaleForCourse(courseID).Bands {
+		distribution[band.Letter] = 0
 	}
 
 	for _, grade := range grades {
@@ -217,9 +163,11 @@ func (gacs *GradeAutoCalculationService) GetStudentGradeStats(studentID uint) (m
 
 	var totalScore float64
 	var aCount, bCount, cCount, dCount, fCount int
+	distribution := make(map[string]int)
 
 	for _, grade := range grades {
 		totalScore += grade.Score
+		distribution[grade.Grade]++
 		switch grade.Grade {
 		case "A":
 			aCount++
@@ -245,6 +193,7 @@ func (gacs *GradeAutoCalculationService) GetStudentGradeStats(studentID uint) (m
 		"c_count":       cCount,
 		"d_count":       dCount,
 		"f_count":       fCount,
+		"distribution":  distribution,
 		"highest_grade": getMaxGrade(grades),
 		"lowest_grade":  getMinGrade(grades),
 	}, nil
@@ -1,6 +1,7 @@
 package service
 
 import (
+	"context"
 	"errors"
 	"school-management-system/internal/models"
 	"school-management-system/internal/repository"
@@ -11,40 +12,49 @@ import (
 )
 
 type GradeService interface {
-	RecordGrade(grade *models.Grade) error
+	RecordGrade(ctx context.Context, grade *models.Grade) error
 	GetGradeByID(id uint) (*models.Grade, error)
 	GetStudentGrades(studentID uint, page, limit int) ([]models.Grade, int64, error)
 	GetCourseGrades(courseID uint, page, limit int) ([]models.Grade, int64, error)
 	GetStudentCourseGrade(studentID, courseID uint) (*models.Grade, error)
 	GetAllGrades(page, limit int) ([]models.Grade, int64, error)
-	UpdateGrade(grade *models.Grade) error
-	DeleteGrade(id uint) error
+	UpdateGrade(ctx context.Context, grade *models.Grade) error
+	DeleteGrade(ctx context.Context, id uint) error
 	GetTeacherGrades(teacherID uint, page, limit int) ([]models.Grade, int64, error)
 	GetGradesInRange(startDate, endDate time.Time) ([]models.Grade, error)
 	CalculateAverageGrade(studentID uint) (float64, error)
 }
 
 type gradeService struct {
-	gradeRepo repository.GradeRepository
-	logger    *logrus.Logger
+	gradeRepo   repository.GradeRepository
+	sectionRepo repository.CourseSectionRepository
+	scales      GradingScaleService
+	transcripts GradeTranscriptService
+	notifier    NotificationDispatcher
+	logger      *logrus.Logger
 }
 
-func NewGradeService(gradeRepo repository.GradeRepository) GradeService {
+func NewGradeService(gradeRepo repository.GradeRepository, sectionRepo repository.CourseSectionRepository, scales GradingScaleService,
+	transcripts GradeTranscriptService, notifier NotificationDispatcher) GradeService {
 	return &gradeService{
-		gradeRepo: gradeRepo,
-		logger:    logger.GetLogger(),
+		gradeRepo:   gradeRepo,
+		sectionRepo: sectionRepo,
+		scales:      scales,
+		transcripts: transcripts,
+		notifier:    notifier,
+		logger:      logger.GetLogger(),
 	}
 }
 
-func (s *gradeService) RecordGrade(grade *models.Grade) error {
+func (s *gradeService) RecordGrade(ctx context.Context, grade *models.Grade) error {
 	if grade.StudentID == 0 {
 		s.logger.Warn("Student ID is required for grade")
 		return errors.New("student id is required")
 	}
 
-	if grade.CourseID == 0 {
-		s.logger.Warn("Course ID is required for grade")
-		return errors.New("course id is required")
+	if grade.CourseID == 0 && (grade.SectionID == nil || *grade.SectionID == 0) {
+		s.logger.Warn("Course ID or section ID is required for grade")
+		return errors.New("course id or section id is required")
 	}
 
 	if grade.GradedBy == 0 {
@@ -60,17 +70,54 @@ func (s *gradeService) RecordGrade(grade *models.Grade) error {
 	if grade.GradedAt.IsZero() {
 		grade.GradedAt = time.Now()
 	}
+	if grade.CourseID == 0 {
+		section, err := s.sectionRepo.FindByID(*grade.SectionID)
+		if err != nil {
+			return errors.New("section not found")
+		}
+		grade.CourseID = section.CourseID
+	}
+	s.fillLetter(grade)
 
-	err := s.gradeRepo.Create(grade)
+	err := s.gradeRepo.Create(ctx, grade)
 	if err != nil {
 		s.logger.WithError(err).WithField("student_id", grade.StudentID).WithField("course_id", grade.CourseID).Error("Failed to record grade")
 		return errors.New("failed to record grade")
 	}
 
 	s.logger.WithField("student_id", grade.StudentID).WithField("course_id", grade.CourseID).WithField("score", grade.Score).Info("Grade recorded")
+	notifyGradePosted(s.notifier, grade)
+	s.regenerateTranscript(grade.StudentID)
 	return nil
 }
 
+// fillLetter gives a grade entered without a letter the one its score earns
+// on the course's grading scale, as imported and auto-calculated grades get
+func (s *gradeService) fillLetter(grade *models.Grade) {
+	if grade.Grade != "" {
+		return
+	}
+	scale, err := s.scales.GetScaleForCourse(grade.CourseID)
+	if err != nil {
+		scale = s.scales.ScaleForCourse(nil)
+	}
+	percentage := grade.Score
+	if grade.MaxScore > 0 {
+		percentage = grade.Score / grade.MaxScore * 100
+	}
+	if band := scale.BandForScore(percentage); band != nil {
+		grade.Grade = band.Letter
+	}
+}
+
+// regenerateTranscript rebuilds a student's transcript after their grades
+// change. The grade change stands if it fails; the next rebuild catches up.
+func (s *gradeService) regenerateTranscript(studentID uint) {
+	if _, err := s.transcripts.RegenerateForStudent(studentID); err != nil {
+		s.logger.WithError(err).WithField("student_id", studentID).Warn("Failed to regenerate transcript")
+	}
+}
+
 func (s *gradeService) GetGradeByID(id uint) (*models.Grade, error) {
 	grade, err := s.gradeRepo.FindByID(id)
 	if err != nil {
@@ -142,7 +189,7 @@ func (s *gradeService) GetAllGrades(page, limit int) ([]models.Grade, int64, err
 	return grades, total, nil
 }
 
-func (s *gradeService) UpdateGrade(grade *models.Grade) error {
+func (s *gradeService) UpdateGrade(ctx context.Context, grade *models.Grade) error {
 	if grade.ID == 0 {
 		return errors.New("grade id is required")
 	}
@@ -152,26 +199,40 @@ func (s *gradeService) UpdateGrade(grade *models.Grade) error {
 		return errors.New("score must be between 0 and max_score")
 	}
 
+	existing, err := s.gradeRepo.FindByID(grade.ID)
+	if err != nil {
+		return errors.New("grade not found")
+	}
+	// Only the result is edited; the student, course and term stay as recorded
+	grade.StudentID, grade.CourseID, grade.SectionID, grade.TermID = existing.StudentID, existing.CourseID, existing.SectionID, existing.TermID
+	grade.Source, grade.GradedBy = existing.Source, existing.GradedBy
 	grade.GradedAt = time.Now()
+	s.fillLetter(grade)
 
-	err := s.gradeRepo.Update(grade)
+	err = s.gradeRepo.Update(ctx, grade)
 	if err != nil {
 		s.logger.WithError(err).WithField("id", grade.ID).Error("Failed to update grade")
 		return errors.New("failed to update grade")
 	}
 
 	s.logger.WithField("id", grade.ID).Info("Grade updated")
+	s.regenerateTranscript(grade.StudentID)
 	return nil
 }
 
-func (s *gradeService) DeleteGrade(id uint) error {
-	err := s.gradeRepo.Delete(id)
+func (s *gradeService) DeleteGrade(ctx context.Context, id uint) error {
+	grade, err := s.gradeRepo.FindByID(id)
+	if err != nil {
+		return errors.New("grade not found")
+	}
+	err = s.gradeRepo.Delete(ctx, id)
 	if err != nil {
 		s.logger.WithError(err).WithField("id", id).Error("Failed to delete grade")
 		return errors.New("failed to delete grade")
 	}
 
 	s.logger.WithField("id", id).Info("Grade deleted")
+	s.regenerateTranscript(grade.StudentID)
 	return nil
 }
 
@@ -1,8 +1,13 @@
 package service
 
 import (
+	"errors"
 	"school-management-system/internal/models"
 	"school-management-system/internal/repository"
+	"school-management-system/pkg/logger"
+	"time"
+
+	"github.com/sirupsen/logrus"
 )
 
 type GradeTranscriptService interface {
@@ -10,18 +15,48 @@ type GradeTranscriptService interface {
 	GetByID(id uint) (*models.GradeTranscript, error)
 	GetByStudentID(studentID uint, page, limit int) ([]models.GradeTranscript, int64, error)
 	GetByStudentAndYear(studentID uint, year int) (*models.GradeTranscript, error)
+	GetByStudentAndTerm(studentID, termID uint) (*models.GradeTranscript, error)
 	Update(transcript *models.GradeTranscript) error
 	Delete(id uint) error
 	GetLatestByStudent(studentID uint) (*models.GradeTranscript, error)
 	CalculateGPA(studentID uint) (float64, error)
+
+	RegenerateForStudent(studentID uint) ([]models.GradeTranscript, error)
+	FinalizeTerm(termID uint) (int, error)
+	GetRepeatPolicy() RepeatPolicy
+
+	AddTransferCredit(credit *models.TransferCredit) error
+	GetTransferCredits(studentID uint) ([]models.TransferCredit, error)
+	DeleteTransferCredit(id uint) error
 }
 
 type gradeTranscriptService struct {
-	repo repository.GradeTranscriptRepository
+	repo         repository.GradeTranscriptRepository
+	gradeRepo    repository.GradeRepository
+	termRepo     repository.AcademicTermRepository
+	transferRepo repository.TransferCreditRepository
+	settingRepo  repository.SystemSettingRepository
+	scaleService GradingScaleService
+	logger       *logrus.Logger
 }
 
-func NewGradeTranscriptService(repo repository.GradeTranscriptRepository) GradeTranscriptService {
-	return &gradeTranscriptService{repo: repo}
+func NewGradeTranscriptService(
+	repo repository.GradeTranscriptRepository,
+	gradeRepo repository.GradeRepository,
+	termRepo repository.AcademicTermRepository,
+	transferRepo repository.TransferCreditRepository,
+	settingRepo repository.SystemSettingRepository,
+	scaleService GradingScaleService,
+) GradeTranscriptService {
+	return &gradeTranscriptService{
+		repo:         repo,
+		gradeRepo:    gradeRepo,
+		termRepo:     termRepo,
+		transferRepo: transferRepo,
+		settingRepo:  settingRepo,
+		scaleService: scaleService,
+		logger:       logger.GetLogger(),
+	}
 }
 
 func (s *gradeTranscriptService) Create(transcript *models.GradeTranscript) error {
@@ -40,6 +75,10 @@ func (s *gradeTranscriptService) GetByStudentAndYear(studentID uint, year int) (
 	return s.repo.FindByStudentAndYear(studentID, year)
 }
 
+func (s *gradeTranscriptService) GetByStudentAndTerm(studentID, termID uint) (*models.GradeTranscript, error) {
+	return s.repo.FindByStudentAndTerm(studentID, termID)
+}
+
 func (s *gradeTranscriptService) Update(transcript *models.GradeTranscript) error {
 	return s.repo.Update(transcript)
 }
@@ -52,13 +91,129 @@ func (s *gradeTranscriptService) GetLatestByStudent(studentID uint) (*models.Gra
 	return s.repo.FindLatestByStudent(studentID)
 }
 
+// CalculateGPA returns the student's cumulative GPA as of their latest term.
 func (s *gradeTranscriptService) CalculateGPA(studentID uint) (float64, error) {
 	transcript, err := s.repo.FindLatestByStudent(studentID)
 	if err != nil {
 		return 0, err
 	}
-	if transcript.TotalCredits == 0 {
-		return 0, nil
+	return transcript.CumulativeGPA, nil
+}
+
+func (s *gradeTranscriptService) GetRepeatPolicy() RepeatPolicy {
+	policy := RepeatPolicy(s.settingRepo.GetValue(SettingRepeatPolicy, string(RepeatPolicyReplace)))
+	if policy != RepeatPolicyAverage {
+		return RepeatPolicyReplace
+	}
+	return policy
+}
+
+// RegenerateForStudent rebuilds every transcript row for the student from
+// their grades and transfer credit. Existing rows are updated in place, new
+// terms get new rows and rows for terms with no remaining activity are removed,
+// all at once and from grades read under the same lock, so regenerations
+// racing from grade writes cannot duplicate rows or save a stale transcript.
+func (s *gradeTranscriptService) RegenerateForStudent(studentID uint) ([]models.GradeTranscript, error) {
+	policy, scales := s.GetRepeatPolicy(), s.scaleLookup()
+	built, err := s.repo.ReplaceForStudent(studentID, func(inputs repository.TranscriptInputs) []models.GradeTranscript {
+		rows := BuildTranscripts(studentID, inputs.Grades, inputs.Terms, inputs.Transfers, policy, scales)
+		now := time.Now().Unix()
+		for i := range rows {
+			rows[i].GeneratedAt = now
+		}
+		return rows
+	})
+	if err != nil {
+		s.logger.WithError(err).WithField("student_id", studentID).Error("Failed to regenerate transcript")
+		return nil, errors.New("failed to regenerate transcript")
+	}
+
+	s.logger.WithField("student_id", studentID).WithField("terms", len(built)).Info("Transcript regenerated")
+	return built, nil
+}
+
+// FinalizeTerm regenerates the transcript of every student with activity in
+// the term. Once the term is closed this marks those rows official.
+func (s *gradeTranscriptService) FinalizeTerm(termID uint) (int, error) {
+	gradeStudents, err := s.gradeRepo.FindStudentIDsByTerm(termID)
+	if err != nil {
+		return 0, err
+	}
+	transferStudents, err := s.transferRepo.FindStudentIDsByTerm(termID)
+	if err != nil {
+		return 0, err
+	}
+
+	seen := make(map[uint]bool)
+	count := 0
+	for _, id := range append(gradeStudents, transferStudents...) {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		if _, err := s.RegenerateForStudent(id); err != nil {
+			return count, err
+		}
+		count++
+	}
+
+	s.logger.WithField("term_id", termID).WithField("students", count).Info("Term transcripts finalized")
+	return count, nil
+}
+
+func (s *gradeTranscriptService) AddTransferCredit(credit *models.TransferCredit) error {
+	if credit.StudentID == 0 {
+		return errors.New("student id is required")
+	}
+	if credit.Institution == "" {
+		return errors.New("institution is required")
+	}
+	if credit.Credits <= 0 {
+		return errors.New("credits must be greater than zero")
+	}
+
+	if err := s.transferRepo.Create(credit); err != nil {
+		s.logger.WithError(err).WithField("student_id", credit.StudentID).Error("Failed to add transfer credit")
+		return errors.New("failed to add transfer credit")
+	}
+
+	_, err := s.RegenerateForStudent(credit.StudentID)
+	return err
+}
+
+func (s *gradeTranscriptService) GetTransferCredits(studentID uint) ([]models.TransferCredit, error) {
+	return s.transferRepo.FindByStudentID(studentID)
+}
+
+func (s *gradeTranscriptService) DeleteTransferCredit(id uint) error {
+	credit, err := s.transferRepo.FindByID(id)
+	if err != nil {
+		return errors.New("transfer credit not found")
+	}
+
+	if err := s.transferRepo.Delete(id); err != nil {
+		s.logger.WithError(err).WithField("id", id).Error("Failed to delete transfer credit")
+		return errors.New("failed to delete transfer credit")
+	}
+
+	_, err = s.RegenerateForStudent(credit.StudentID)
+	return err
+}
+
+// scaleLookup resolves grading scales through the scale service, caching
+// them for the duration of one regeneration.
+func (s *gradeTranscriptService) scaleLookup() ScaleLookup {
+	cache := make(map[uint]*models.GradingScale)
+	return func(course *models.Course) *models.GradingScale {
+		var key uint
+		if course != nil {
+			key = course.ID
+		}
+		if scale, ok := cache[key]; ok {
+			return scale
+		}
+		scale := s.scaleService.ScaleForCourse(course)
+		cache[key] = scale
+		return scale
 	}
-	return transcript.GradePointsSum / transcript.TotalCredits, nil
 }
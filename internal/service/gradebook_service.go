@@ -0,0 +1,511 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"math"
+	"school-management-system/internal/models"
+	"school-management-system/internal/repository"
+	"school-management-system/pkg/logger"
+	"sort"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// GradebookCategoryResult is one student's standing in one category.
+type GradebookCategoryResult struct {
+	CategoryID uint     `json:"category_id"`
+	Name       string   `json:"name"`
+	Weight     float64  `json:"weight"`
+	Percentage *float64 `json:"percentage"` // nil until something is graded
+	Dropped    []uint   `json:"dropped,omitempty"`
+}
+
+// GradebookRow is one student's line in the gradebook matrix.
+type GradebookRow struct {
+	StudentID        uint                      `json:"student_id"`
+	StudentName      string                    `json:"student_name"`
+	Scores           map[uint]*float64         `json:"scores"` // assignment id -> score, nil if not graded
+	Categories       []GradebookCategoryResult `json:"categories"`
+	CoursePercentage *float64                  `json:"course_percentage"`
+	Letter           string                    `json:"letter"`
+}
+
+// Gradebook is the students × assignments matrix for a course.
+type Gradebook struct {
+	CourseID    uint                   `json:"course_id"`
+	Categories  []models.GradeCategory `json:"categories"`
+	Assignments []models.Assignment    `json:"assignments"`
+	Rows        []GradebookRow         `json:"rows"`
+}
+
+// ComputeGradebookRow rolls graded scores (assignment id -> score) up into
+// category and course percentages. Within a category, scores are summed
+// against max scores after dropping the lowest DropLowest percentages (at
+// least one graded assignment is always kept). The course percentage is the
+// weighted mean of the categories that have graded work, so it is a running
+// figure during the term. A course without categories treats all its
+// assignments as one category; when categories exist, uncategorized
+// assignments are shown but not counted.
+func ComputeGradebookRow(categories []models.GradeCategory, assignments []models.Assignment, scores map[uint]float64) GradebookRow {
+	if len(categories) == 0 {
+		categories = []models.GradeCategory{{Name: "All assignments", Weight: 100}}
+	}
+
+	byCategory := make(map[uint][]models.Assignment)
+	for _, a := range assignments {
+		var key uint
+		if a.CategoryID != nil {
+			key = *a.CategoryID
+		}
+		byCategory[key] = append(byCategory[key], a)
+	}
+
+	row := GradebookRow{Scores: make(map[uint]*float64, len(assignments))}
+	for _, a := range assignments {
+		if score, ok := scores[a.ID]; ok {
+			s := score
+			row.Scores[a.ID] = &s
+		} else {
+			row.Scores[a.ID] = nil
+		}
+	}
+
+	var weighted, totalWeight float64
+	for _, cat := range categories {
+		result := GradebookCategoryResult{CategoryID: cat.ID, Name: cat.Name, Weight: cat.Weight}
+
+		type item struct {
+			id         uint
+			score, max float64
+		}
+		var graded []item
+		for _, a := range byCategory[cat.ID] {
+			if score, ok := scores[a.ID]; ok && a.MaxScore > 0 {
+				graded = append(graded, item{a.ID, score, a.MaxScore})
+			}
+		}
+
+		if len(graded) > 0 {
+			sort.SliceStable(graded, func(i, j int) bool {
+				pi, pj := graded[i].score/graded[i].max, graded[j].score/graded[j].max
+				if pi != pj {
+					return pi < pj
+				}
+				return graded[i].id < graded[j].id
+			})
+
+			drop := cat.DropLowest
+			if drop > len(graded)-1 {
+				drop = len(graded) - 1
+			}
+			for _, it := range graded[:drop] {
+				result.Dropped = append(result.Dropped, it.id)
+			}
+
+			var earned, possible float64
+			for _, it := range graded[drop:] {
+				earned += it.score
+				possible += it.max
+			}
+			pct := round2(earned / possible * 100)
+			result.Percentage = &pct
+
+			if cat.Weight > 0 {
+				weighted += cat.Weight * pct
+				totalWeight += cat.Weight
+			}
+		}
+
+		row.Categories = append(row.Categories, result)
+	}
+
+	if totalWeight > 0 {
+		pct := round2(weighted / totalWeight)
+		row.CoursePercentage = &pct
+	}
+	return row
+}
+
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+type GradebookService interface {
+	CreateCategory(ctx context.Context, category *models.GradeCategory) error
+	GetCourseCategories(courseID uint) ([]models.GradeCategory, error)
+	UpdateCategory(ctx context.Context, category *models.GradeCategory) error
+	DeleteCategory(ctx context.Context, id uint) error
+	ValidateAssignmentCategory(assignment *models.Assignment) error
+
+	GetGradebook(courseID uint) (*Gradebook, error)
+	RecomputeStudent(ctx context.Context, courseID, studentID uint) error
+	RecomputeCourse(ctx context.Context, courseID uint) (int, error)
+}
+
+type gradebookService struct {
+	categoryRepo      repository.GradeCategoryRepository
+	assignmentRepo    repository.AssignmentRepository
+	submissionRepo    repository.AssignmentSubmissionRepository
+	enrollmentRepo    repository.EnrollmentRepository
+	gradeRepo         repository.GradeRepository
+	courseRepo        repository.CourseRepository
+	scaleService      GradingScaleService
+	transcriptService GradeTranscriptService
+	logger            *logrus.Logger
+}
+
+func NewGradebookService(
+	categoryRepo repository.GradeCategoryRepository,
+	assignmentRepo repository.AssignmentRepository,
+	submissionRepo repository.AssignmentSubmissionRepository,
+	enrollmentRepo repository.EnrollmentRepository,
+	gradeRepo repository.GradeRepository,
+	courseRepo repository.CourseRepository,
+	scaleService GradingScaleService,
+	transcriptService GradeTranscriptService,
+) GradebookService {
+	return &gradebookService{
+		categoryRepo:      categoryRepo,
+		assignmentRepo:    assignmentRepo,
+		submissionRepo:    submissionRepo,
+		enrollmentRepo:    enrollmentRepo,
+		gradeRepo:         gradeRepo,
+		courseRepo:        courseRepo,
+		scaleService:      scaleService,
+		transcriptService: transcriptService,
+		logger:            logger.GetLogger(),
+	}
+}
+
+func (s *gradebookService) validateCategory(category *models.GradeCategory) error {
+	if category.Name == "" {
+		return errors.New("category name is required")
+	}
+	if category.CourseID == 0 {
+		return errors.New("course id is required")
+	}
+	if category.Weight < 0 || category.Weight > 100 {
+		return errors.New("category weight must be between 0 and 100")
+	}
+	if category.DropLowest < 0 {
+		return errors.New("drop lowest cannot be negative")
+	}
+
+	existing, err := s.categoryRepo.FindByCourseID(category.CourseID)
+	if err != nil {
+		return errors.New("failed to load course categories")
+	}
+	total := category.Weight
+	for _, c := range existing {
+		if c.ID == category.ID {
+			continue
+		}
+		if c.Name == category.Name {
+			return errors.New("a category with this name already exists for the course")
+		}
+		total += c.Weight
+	}
+	if total > 100 {
+		return errors.New("category weights for a course cannot exceed 100")
+	}
+	return nil
+}
+
+func (s *gradebookService) CreateCategory(ctx context.Context, category *models.GradeCategory) error {
+	if _, err := s.courseRepo.FindByID(category.CourseID); err != nil {
+		return errors.New("course not found")
+	}
+	if err := s.validateCategory(category); err != nil {
+		s.logger.WithError(err).WithField("course_id", category.CourseID).Warn("Invalid grade category")
+		return err
+	}
+
+	if err := s.categoryRepo.Create(category); err != nil {
+		s.logger.WithError(err).WithField("course_id", category.CourseID).Error("Failed to create grade category")
+		return errors.New("failed to create grade category")
+	}
+
+	s.logger.WithField("id", category.ID).WithField("course_id", category.CourseID).Info("Grade category created")
+	s.recomputeInBackground(ctx, category.CourseID)
+	return nil
+}
+
+func (s *gradebookService) GetCourseCategories(courseID uint) ([]models.GradeCategory, error) {
+	return s.categoryRepo.FindByCourseID(courseID)
+}
+
+func (s *gradebookService) UpdateCategory(ctx context.Context, category *models.GradeCategory) error {
+	existing, err := s.categoryRepo.FindByID(category.ID)
+	if err != nil {
+		return errors.New("grade category not found")
+	}
+	category.CourseID = existing.CourseID
+	category.CreatedAt = existing.CreatedAt
+
+	if err := s.validateCategory(category); err != nil {
+		s.logger.WithError(err).WithField("id", category.ID).Warn("Invalid grade category")
+		return err
+	}
+
+	if err := s.categoryRepo.Update(category); err != nil {
+		s.logger.WithError(err).WithField("id", category.ID).Error("Failed to update grade category")
+		return errors.New("failed to update grade category")
+	}
+
+	s.recomputeInBackground(ctx, category.CourseID)
+	return nil
+}
+
+func (s *gradebookService) DeleteCategory(ctx context.Context, id uint) error {
+	category, err := s.categoryRepo.FindByID(id)
+	if err != nil {
+		return errors.New("grade category not found")
+	}
+
+	count, err := s.categoryRepo.CountAssignments(id)
+	if err != nil {
+		return errors.New("failed to check category assignments")
+	}
+	if count > 0 {
+		return errors.New("category still has assignments; move them to another category first")
+	}
+
+	if err := s.categoryRepo.Delete(id); err != nil {
+		s.logger.WithError(err).WithField("id", id).Error("Failed to delete grade category")
+		return errors.New("failed to delete grade category")
+	}
+
+	s.recomputeInBackground(ctx, category.CourseID)
+	return nil
+}
+
+// ValidateAssignmentCategory checks that an assignment's category belongs to
+// the assignment's course.
+func (s *gradebookService) ValidateAssignmentCategory(assignment *models.Assignment) error {
+	if assignment.CategoryID == nil || *assignment.CategoryID == 0 {
+		assignment.CategoryID = nil
+		return nil
+	}
+
+	category, err := s.categoryRepo.FindByID(*assignment.CategoryID)
+	if err != nil {
+		return errors.New("grade category not found")
+	}
+	if category.CourseID != assignment.CourseID {
+		return errors.New("grade category belongs to a different course")
+	}
+	return nil
+}
+
+// load fetches everything needed to compute a course's gradebook, with
+// graded scores indexed by student then assignment.
+func (s *gradebookService) load(courseID uint) ([]models.GradeCategory, []models.Assignment, map[uint]map[uint]float64, error) {
+	categories, err := s.categoryRepo.FindByCourseID(courseID)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	assignments, err := s.assignmentRepo.FindByCourseID(courseID)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	submissions, err := s.submissionRepo.FindByCourseID(courseID)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	scores := make(map[uint]map[uint]float64)
+	for _, sub := range submissions {
+		if sub.Score == nil {
+			continue
+		}
+		if scores[sub.StudentID] == nil {
+			scores[sub.StudentID] = make(map[uint]float64)
+		}
+		scores[sub.StudentID][sub.AssignmentID] = *sub.Score
+	}
+
+	return categories, assignments, scores, nil
+}
+
+// offeringAssignments narrows a course's assignments to those of the term
+// and section an enrollment is in, so a student repeating a course is graded
+// only on the attempt the enrollment belongs to.
+func offeringAssignments(assignments []models.Assignment, enrollment *models.Enrollment) []models.Assignment {
+	if enrollment == nil {
+		return assignments
+	}
+	var offered []models.Assignment
+	for _, a := range assignments {
+		if a.InOffering(enrollment.Term, enrollment.SectionID) {
+			offered = append(offered, a)
+		}
+	}
+	return offered
+}
+
+func (s *gradebookService) GetGradebook(courseID uint) (*Gradebook, error) {
+	course, err := s.courseRepo.FindByID(courseID)
+	if err != nil {
+		return nil, errors.New("course not found")
+	}
+
+	categories, assignments, scores, err := s.load(courseID)
+	if err != nil {
+		s.logger.WithError(err).WithField("course_id", courseID).Error("Failed to load gradebook")
+		return nil, errors.New("failed to load gradebook")
+	}
+
+	enrollments, err := s.enrollmentRepo.FindActiveByCourseID(courseID)
+	if err != nil {
+		s.logger.WithError(err).WithField("course_id", courseID).Error("Failed to load course roster")
+		return nil, errors.New("failed to load course roster")
+	}
+
+	scale := s.scaleService.ScaleForCourse(course)
+	book := &Gradebook{CourseID: courseID, Categories: categories, Assignments: assignments}
+	seen := make(map[uint]bool, len(enrollments))
+	for _, e := range enrollments {
+		if seen[e.StudentID] {
+			continue
+		}
+		seen[e.StudentID] = true
+
+		row := ComputeGradebookRow(categories, offeringAssignments(assignments, &e), scores[e.StudentID])
+		row.StudentID = e.StudentID
+		row.StudentName = e.Student.User.FirstName + " " + e.Student.User.LastName
+		if row.CoursePercentage != nil {
+			if band := scale.BandForScore(*row.CoursePercentage); band != nil {
+				row.Letter = band.Letter
+			}
+		}
+		book.Rows = append(book.Rows, row)
+	}
+
+	return book, nil
+}
+
+func (s *gradebookService) RecomputeStudent(ctx context.Context, courseID, studentID uint) error {
+	course, err := s.courseRepo.FindByID(courseID)
+	if err != nil {
+		return errors.New("course not found")
+	}
+
+	categories, assignments, scores, err := s.load(courseID)
+	if err != nil {
+		s.logger.WithError(err).WithField("course_id", courseID).Error("Failed to load gradebook")
+		return errors.New("failed to load gradebook")
+	}
+
+	var enrollment *models.Enrollment
+	if e, err := s.enrollmentRepo.FindCurrentByStudentAndCourse(studentID, courseID); err == nil {
+		enrollment = e
+	}
+
+	return s.saveCourseGrade(ctx, course, enrollment, studentID, categories, assignments, scores[studentID])
+}
+
+func (s *gradebookService) RecomputeCourse(ctx context.Context, courseID uint) (int, error) {
+	course, err := s.courseRepo.FindByID(courseID)
+	if err != nil {
+		return 0, errors.New("course not found")
+	}
+
+	categories, assignments, scores, err := s.load(courseID)
+	if err != nil {
+		s.logger.WithError(err).WithField("course_id", courseID).Error("Failed to load gradebook")
+		return 0, errors.New("failed to load gradebook")
+	}
+
+	enrollments, err := s.enrollmentRepo.FindActiveByCourseID(courseID)
+	if err != nil {
+		s.logger.WithError(err).WithField("course_id", courseID).Error("Failed to load course roster")
+		return 0, errors.New("failed to load course roster")
+	}
+
+	updated := 0
+	for i := range enrollments {
+		e := &enrollments[i]
+		if err := s.saveCourseGrade(ctx, course, e, e.StudentID, categories, assignments, scores[e.StudentID]); err != nil {
+			return updated, err
+		}
+		updated++
+	}
+
+	s.logger.WithField("course_id", courseID).WithField("students", updated).Info("Gradebook recomputed")
+	return updated, nil
+}
+
+// saveCourseGrade writes the gradebook-computed Grade for one student,
+// creating it on the first graded submission.
+func (s *gradebookService) saveCourseGrade(
+	ctx context.Context,
+	course *models.Course,
+	enrollment *models.Enrollment,
+	studentID uint,
+	categories []models.GradeCategory,
+	assignments []models.Assignment,
+	scores map[uint]float64,
+) error {
+	row := ComputeGradebookRow(categories, offeringAssignments(assignments, enrollment), scores)
+	if row.CoursePercentage == nil {
+		return nil
+	}
+
+	var termID, sectionID *uint
+	if enrollment != nil {
+		termID, sectionID = enrollment.TermID, enrollment.SectionID
+	}
+
+	grade, err := s.gradeRepo.FindGradebookGrade(studentID, course.ID, termID)
+	isNew := err != nil
+	if isNew {
+		grade = &models.Grade{
+			StudentID: studentID,
+			CourseID:  course.ID,
+			SectionID: sectionID,
+			TermID:    termID,
+			Source:    models.GradeSourceGradebook,
+		}
+	}
+
+	grade.Score = *row.CoursePercentage
+	grade.MaxScore = 100
+	grade.GradedAt = time.Now()
+	grade.Grade = ""
+	if band := s.scaleService.ScaleForCourse(course).BandForScore(grade.Score); band != nil {
+		grade.Grade = band.Letter
+	}
+
+	if isNew {
+		err = s.gradeRepo.Create(ctx, grade)
+	} else {
+		err = s.gradeRepo.Update(ctx, grade)
+	}
+	if err != nil {
+		s.logger.WithError(err).WithFields(logrus.Fields{
+			"course_id":  course.ID,
+			"student_id": studentID,
+		}).Error("Failed to save gradebook grade")
+		return errors.New("failed to save course grade")
+	}
+
+	if _, err := s.transcriptService.RegenerateForStudent(studentID); err != nil {
+		s.logger.WithError(err).WithField("student_id", studentID).Warn("Failed to regenerate transcript")
+	}
+	return nil
+}
+
+// recomputeInBackground recomputes a course's grades after the request has
+// returned, so it keeps the request's actor but not its cancellation.
+func (s *gradebookService) recomputeInBackground(ctx context.Context, courseID uint) {
+	ctx = context.WithoutCancel(ctx)
+	go func() {
+		if _, err := s.RecomputeCourse(ctx, courseID); err != nil {
+			s.logger.WithError(err).WithField("course_id", courseID).Warn("Failed to recompute gradebook")
+		}
+	}()
+}
@@ -0,0 +1,200 @@
+package service
+
+import (
+	"errors"
+	"school-management-system/internal/models"
+	"school-management-system/internal/repository"
+	"school-management-system/pkg/logger"
+
+	"github.com/sirupsen/logrus"
+)
+
+type GradingScaleService interface {
+	CreateScale(scale *models.GradingScale) error
+	GetScaleByID(id uint) (*models.GradingScale, error)
+	GetAllScales() ([]models.GradingScale, error)
+	UpdateScale(scale *models.GradingScale) error
+	DeleteScale(id uint) error
+	GetScaleForCourse(courseID uint) (*models.GradingScale, error)
+	ScaleForCourse(course *models.Course) *models.GradingScale
+}
+
+type gradingScaleService struct {
+	scaleRepo  repository.GradingScaleRepository
+	courseRepo repository.CourseRepository
+	logger     *logrus.Logger
+}
+
+func NewGradingScaleService(scaleRepo repository.GradingScaleRepository, courseRepo repository.CourseRepository) GradingScaleService {
+	return &gradingScaleService{
+		scaleRepo:  scaleRepo,
+		courseRepo: courseRepo,
+		logger:     logger.GetLogger(),
+	}
+}
+
+func validateGradingScale(scale *models.GradingScale) error {
+	if scale.Name == "" {
+		return errors.New("scale name is required")
+	}
+
+	switch scale.Scope {
+	case models.GradingScopeSchool:
+		scale.Department = ""
+		scale.CourseID = nil
+	case models.GradingScopeDepartment:
+		if scale.Department == "" {
+			return errors.New("department is required for a department scale")
+		}
+		scale.CourseID = nil
+	case models.GradingScopeCourse:
+		if scale.CourseID == nil || *scale.CourseID == 0 {
+			return errors.New("course id is required for a course scale")
+		}
+		scale.Department = ""
+	default:
+		return errors.New("scope must be school, department or course")
+	}
+
+	if len(scale.Bands) == 0 {
+		return errors.New("a grading scale needs at least one band")
+	}
+
+	letters := make(map[string]bool, len(scale.Bands))
+	minimums := make(map[float64]bool, len(scale.Bands))
+	hasFloor := false
+	for _, b := range scale.Bands {
+		if b.Letter == "" {
+			return errors.New("every band needs a letter")
+		}
+		if letters[b.Letter] {
+			return errors.New("duplicate band letter " + b.Letter)
+		}
+		if minimums[b.MinPercentage] {
+			return errors.New("two bands share the same minimum percentage")
+		}
+		if b.MinPercentage < 0 || b.MinPercentage > 100 {
+			return errors.New("band minimum percentage must be between 0 and 100")
+		}
+		if b.GradePoints < 0 {
+			return errors.New("band grade points cannot be negative")
+		}
+		letters[b.Letter] = true
+		minimums[b.MinPercentage] = true
+		if b.MinPercentage == 0 {
+			hasFloor = true
+		}
+	}
+	if !hasFloor {
+		return errors.New("the lowest band must start at 0 so every score maps to a letter")
+	}
+	return nil
+}
+
+// ensureUniqueTarget rejects a second scale for the same school, department
+// or course.
+func (s *gradingScaleService) ensureUniqueTarget(scale *models.GradingScale) error {
+	var existing *models.GradingScale
+	var err error
+	switch scale.Scope {
+	case models.GradingScopeSchool:
+		existing, err = s.scaleRepo.FindSchoolDefault()
+	case models.GradingScopeDepartment:
+		existing, err = s.scaleRepo.FindByDepartment(scale.Department)
+	case models.GradingScopeCourse:
+		if _, cerr := s.courseRepo.FindByID(*scale.CourseID); cerr != nil {
+			return errors.New("course not found")
+		}
+		existing, err = s.scaleRepo.FindByCourseID(*scale.CourseID)
+	}
+	if err == nil && existing.ID != scale.ID {
+		return errors.New("a grading scale is already assigned to this " + scale.Scope)
+	}
+	return nil
+}
+
+func (s *gradingScaleService) CreateScale(scale *models.GradingScale) error {
+	if err := validateGradingScale(scale); err != nil {
+		s.logger.WithError(err).WithField("name", scale.Name).Warn("Invalid grading scale")
+		return err
+	}
+	if err := s.ensureUniqueTarget(scale); err != nil {
+		return err
+	}
+
+	if err := s.scaleRepo.Create(scale); err != nil {
+		s.logger.WithError(err).WithField("name", scale.Name).Error("Failed to create grading scale")
+		return errors.New("failed to create grading scale")
+	}
+
+	s.logger.WithField("id", scale.ID).WithField("scope", scale.Scope).Info("Grading scale created")
+	return nil
+}
+
+func (s *gradingScaleService) GetScaleByID(id uint) (*models.GradingScale, error) {
+	return s.scaleRepo.FindByID(id)
+}
+
+func (s *gradingScaleService) GetAllScales() ([]models.GradingScale, error) {
+	return s.scaleRepo.FindAll()
+}
+
+func (s *gradingScaleService) UpdateScale(scale *models.GradingScale) error {
+	if _, err := s.scaleRepo.FindByID(scale.ID); err != nil {
+		return errors.New("grading scale not found")
+	}
+	if err := validateGradingScale(scale); err != nil {
+		s.logger.WithError(err).WithField("id", scale.ID).Warn("Invalid grading scale")
+		return err
+	}
+	if err := s.ensureUniqueTarget(scale); err != nil {
+		return err
+	}
+
+	if err := s.scaleRepo.Update(scale); err != nil {
+		s.logger.WithError(err).WithField("id", scale.ID).Error("Failed to update grading scale")
+		return errors.New("failed to update grading scale")
+	}
+
+	s.logger.WithField("id", scale.ID).Info("Grading scale updated")
+	return nil
+}
+
+func (s *gradingScaleService) DeleteScale(id uint) error {
+	if _, err := s.scaleRepo.FindByID(id); err != nil {
+		return errors.New("grading scale not found")
+	}
+	if err := s.scaleRepo.Delete(id); err != nil {
+		s.logger.WithError(err).WithField("id", id).Error("Failed to delete grading scale")
+		return errors.New("failed to delete grading scale")
+	}
+	return nil
+}
+
+func (s *gradingScaleService) GetScaleForCourse(courseID uint) (*models.GradingScale, error) {
+	course, err := s.courseRepo.FindByID(courseID)
+	if err != nil {
+		return nil, errors.New("course not found")
+	}
+	return s.ScaleForCourse(course), nil
+}
+
+// ScaleForCourse resolves the scale that applies to a course: the course's
+// own scale, then its department's, then the school's, then the built-in
+// A–F scale. A nil course resolves to the school scale.
+func (s *gradingScaleService) ScaleForCourse(course *models.Course) *models.GradingScale {
+	if course != nil && course.ID != 0 {
+		if scale, err := s.scaleRepo.FindByCourseID(course.ID); err == nil {
+			return scale
+		}
+		if course.Department != "" {
+			if scale, err := s.scaleRepo.FindByDepartment(course.Department); err == nil {
+				return scale
+			}
+		}
+	}
+	if scale, err := s.scaleRepo.FindSchoolDefault(); err == nil {
+		return scale
+	}
+	return models.DefaultGradingScale()
+}
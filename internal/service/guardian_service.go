@@ -0,0 +1,148 @@
+package service
+
+import (
+	"errors"
+	"school-management-system/internal/models"
+	"school-management-system/internal/repository"
+	"school-management-system/pkg/logger"
+
+	"github.com/sirupsen/logrus"
+)
+
+type GuardianService interface {
+	LinkGuardian(guardian *models.Guardian) error
+	GetGuardianByID(id uint) (*models.Guardian, error)
+	GetGuardiansOfStudent(studentID uint) ([]models.Guardian, error)
+	GetChildren(userID uint) ([]models.Guardian, error)
+	UpdateGuardian(guardian *models.Guardian) error
+	UnlinkGuardian(id uint) error
+	NotificationRecipients(studentID uint) []EmailRecipient
+}
+
+type guardianService struct {
+	guardianRepo repository.GuardianRepository
+	userRepo     repository.UserRepository
+	studentRepo  repository.StudentRepository
+	logger       *logrus.Logger
+}
+
+func NewGuardianService(
+	guardianRepo repository.GuardianRepository,
+	userRepo repository.UserRepository,
+	studentRepo repository.StudentRepository,
+) GuardianService {
+	return &guardianService{
+		guardianRepo: guardianRepo,
+		userRepo:     userRepo,
+		studentRepo:  studentRepo,
+		logger:       logger.GetLogger(),
+	}
+}
+
+func validRelationship(relationship string) bool {
+	switch relationship {
+	case models.GuardianMother, models.GuardianFather, models.GuardianGuardian, models.GuardianOther:
+		return true
+	}
+	return false
+}
+
+func (s *guardianService) LinkGuardian(guardian *models.Guardian) error {
+	if !validRelationship(guardian.Relationship) {
+		return errors.New("relationship must be mother, father, guardian or other")
+	}
+
+	user, err := s.userRepo.FindByID(guardian.UserID)
+	if err != nil {
+		return errors.New("user not found")
+	}
+	if user.Role != models.RoleParent {
+		return errors.New("only parent accounts can be linked as guardians")
+	}
+
+	if _, err := s.studentRepo.FindByID(guardian.StudentID); err != nil {
+		return errors.New("student not found")
+	}
+
+	if _, err := s.guardianRepo.FindByUserAndStudent(guardian.UserID, guardian.StudentID); err == nil {
+		return errors.New("guardian is already linked to this student")
+	}
+
+	if err := s.guardianRepo.Create(guardian); err != nil {
+		s.logger.WithError(err).Error("Failed to link guardian")
+		return errors.New("failed to link guardian")
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"user_id":    guardian.UserID,
+		"student_id": guardian.StudentID,
+	}).Info("Guardian linked to student")
+	return nil
+}
+
+func (s *guardianService) GetGuardianByID(id uint) (*models.Guardian, error) {
+	guardian, err := s.guardianRepo.FindByID(id)
+	if err != nil {
+		return nil, errors.New("guardian link not found")
+	}
+	return guardian, nil
+}
+
+func (s *guardianService) GetGuardiansOfStudent(studentID uint) ([]models.Guardian, error) {
+	return s.guardianRepo.FindByStudentID(studentID)
+}
+
+func (s *guardianService) GetChildren(userID uint) ([]models.Guardian, error) {
+	return s.guardianRepo.FindByUserID(userID)
+}
+
+// UpdateGuardian changes the relationship and flags of a link; the linked
+// user and student cannot be changed.
+func (s *guardianService) UpdateGuardian(guardian *models.Guardian) error {
+	if !validRelationship(guardian.Relationship) {
+		return errors.New("relationship must be mother, father, guardian or other")
+	}
+
+	existing, err := s.guardianRepo.FindByID(guardian.ID)
+	if err != nil {
+		return errors.New("guardian link not found")
+	}
+
+	existing.Relationship = guardian.Relationship
+	existing.HasCustody = guardian.HasCustody
+	existing.IsEmergencyContact = guardian.IsEmergencyContact
+	existing.ReceivesNotifications = guardian.ReceivesNotifications
+
+	if err := s.guardianRepo.Update(existing); err != nil {
+		s.logger.WithError(err).Error("Failed to update guardian")
+		return errors.New("failed to update guardian")
+	}
+	*guardian = *existing
+	return nil
+}
+
+func (s *guardianService) UnlinkGuardian(id uint) error {
+	if _, err := s.guardianRepo.FindByID(id); err != nil {
+		return errors.New("guardian link not found")
+	}
+	return s.guardianRepo.Delete(id)
+}
+
+// NotificationRecipients returns the email addresses, with their locales,
+// of the student's guardians who should be copied on the student's
+// notifications.
+func (s *guardianService) NotificationRecipients(studentID uint) []EmailRecipient {
+	guardians, err := s.guardianRepo.FindNotifiableByStudentID(studentID)
+	if err != nil {
+		s.logger.WithError(err).WithField("student_id", studentID).Warn("Failed to load guardians")
+		return nil
+	}
+
+	recipients := make([]EmailRecipient, 0, len(guardians))
+	for _, g := range guardians {
+		if g.User.Email != "" {
+			recipients = append(recipients, UserRecipient(&g.User))
+		}
+	}
+	return recipients
+}
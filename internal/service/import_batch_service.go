@@ -1,30 +1,282 @@
 package service
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
 	"school-management-system/internal/models"
 	"school-management-system/internal/repository"
+	"school-management-system/pkg/logger"
+	"school-management-system/pkg/utils"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrUnknownImportEntity = errors.New("unknown import entity type, expected student, teacher, course, enrollment or grade")
+	ErrImportFileType      = errors.New("unsupported file type, expected .csv or .xlsx")
 )
 
+// importProgressEvery is how many rows are processed between progress
+// updates of the batch row
+const importProgressEvery = 25
+
+// errDryRun rolls back a row's transaction after it was applied successfully
+var errDryRun = errors.New("dry run")
+
+// ImportRowError describes why one row of an import was rejected. Row is
+// the line number in the file, counting the header as row 1; zero means
+// the whole file was rejected.
+type ImportRowError struct {
+	Row     int    `json:"row"`
+	Column  string `json:"column,omitempty"`
+	Value   string `json:"value,omitempty"`
+	Message string `json:"message"`
+}
+
 type ImportBatchService interface {
-	Create(batch *models.ImportBatch) error
+	Start(ctx context.Context, entityType, fileName string, data []byte, dryRun bool, createdBy uint) (*models.ImportBatch, error)
+	Process(ctx context.Context, batch *models.ImportBatch, data []byte) error
+	RowErrors(batch *models.ImportBatch) ([]ImportRowError, error)
+	Columns(entityType string) (required, optional []string, err error)
 	GetByID(id uint) (*models.ImportBatch, error)
 	GetAll(page, limit int) ([]models.ImportBatch, int64, error)
 	GetByStatus(status string, page, limit int) ([]models.ImportBatch, int64, error)
-	Update(batch *models.ImportBatch) error
 	Delete(id uint) error
 	GetRecentByEntityType(entityType string, limit int) ([]models.ImportBatch, error)
 }
 
 type importBatchService struct {
-	repo repository.ImportBatchRepository
+	repo              repository.ImportBatchRepository
+	db                *gorm.DB
+	gradingScales     GradingScaleService
+	transcriptService GradeTranscriptService
+	enrollments       EnrollmentService
+	logger            *logrus.Logger
+	importers         map[string]rowImporter
+}
+
+func NewImportBatchService(repo repository.ImportBatchRepository, db *gorm.DB, gradingScales GradingScaleService, transcriptService GradeTranscriptService, enrollments EnrollmentService) ImportBatchService {
+	s := &importBatchService{
+		repo:              repo,
+		db:                db,
+		gradingScales:     gradingScales,
+		transcriptService: transcriptService,
+		enrollments:       enrollments,
+		logger:            logger.GetLogger(),
+	}
+	s.importers = s.rowImporters()
+	return s
+}
+
+// Start records a pending batch and processes the file in the background.
+// Changes the rows make are attributed to the actor on ctx.
+func (s *importBatchService) Start(ctx context.Context, entityType, fileName string, data []byte, dryRun bool, createdBy uint) (*models.ImportBatch, error) {
+	if _, ok := s.importers[entityType]; !ok {
+		return nil, ErrUnknownImportEntity
+	}
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".csv", ".xlsx":
+	default:
+		return nil, ErrImportFileType
+	}
+
+	now := time.Now().Unix()
+	batch := &models.ImportBatch{
+		EntityType: entityType,
+		FileName:   filepath.Base(fileName),
+		DryRun:     dryRun,
+		Status:     models.ImportStatusPending,
+		CreatedBy:  createdBy,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	if err := s.repo.Create(batch); err != nil {
+		return nil, errors.New("failed to create import batch")
+	}
+
+	// The caller keeps batch; process a copy so the two never race
+	queued := *batch
+	ctx = context.WithoutCancel(ctx)
+	go func() {
+		if err := s.Process(ctx, &queued, data); err != nil {
+			s.logger.WithError(err).WithField("batch_id", queued.ID).Warn("Import failed")
+		}
+	}()
+	return batch, nil
+}
+
+// Process validates and saves every row of the file, recording progress and
+// per-row errors on the batch. Each row is saved in its own transaction, so
+// one bad row does not stop the others; in a dry run every transaction is
+// rolled back.
+func (s *importBatchService) Process(ctx context.Context, batch *models.ImportBatch, data []byte) error {
+	importer, ok := s.importers[batch.EntityType]
+	if !ok {
+		return s.fail(batch, ErrUnknownImportEntity)
+	}
+
+	batch.Status = models.ImportStatusProcessing
+	s.save(batch)
+
+	records, err := utils.ReadSpreadsheet(batch.FileName, data)
+	if err != nil {
+		return s.fail(batch, err)
+	}
+	header, rows := splitHeader(records)
+	if header == nil {
+		return s.fail(batch, errors.New("file has no header row"))
+	}
+	var missing []string
+	for _, col := range importer.required {
+		if _, ok := header[col]; !ok {
+			missing = append(missing, col)
+		}
+	}
+	if len(missing) > 0 {
+		return s.fail(batch, fmt.Errorf("missing required columns: %s", strings.Join(missing, ", ")))
+	}
+
+	batch.TotalRows = len(rows)
+	s.save(batch)
+
+	var rowErrors []ImportRowError
+	seen := map[string]int{}
+	touched := map[uint]bool{}
+	for i, row := range rows {
+		rowErr := s.processRow(ctx, importer, batch, row, seen, touched)
+		if rowErr != nil {
+			rowErrors = append(rowErrors, *rowErr)
+			batch.FailedRows++
+		} else {
+			batch.SuccessRows++
+		}
+		batch.ProcessedRows++
+
+		if (i+1)%importProgressEvery == 0 {
+			s.save(batch)
+		}
+	}
+
+	if len(rowErrors) > 0 {
+		encoded, _ := json.Marshal(rowErrors)
+		batch.Errors = string(encoded)
+	}
+	batch.Status = models.ImportStatusCompleted
+	batch.CompletedAt = time.Now().Unix()
+	s.save(batch)
+
+	// Grades feed transcripts, which are derived data
+	if batch.EntityType == models.ImportEntityGrade && !batch.DryRun {
+		for studentID := range touched {
+			if _, err := s.transcriptService.RegenerateForStudent(studentID); err != nil {
+				s.logger.WithError(err).WithField("student_id", studentID).Warn("Failed to regenerate transcript after import")
+			}
+		}
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"batch_id": batch.ID,
+		"entity":   batch.EntityType,
+		"dry_run":  batch.DryRun,
+		"success":  batch.SuccessRows,
+		"failed":   batch.FailedRows,
+	}).Info("Import completed")
+	return nil
+}
+
+func (s *importBatchService) processRow(ctx context.Context, importer rowImporter, batch *models.ImportBatch, row importRow, seen map[string]int, touched map[uint]bool) *ImportRowError {
+	for _, col := range importer.required {
+		if row.get(col) == "" {
+			return &ImportRowError{Row: row.num, Column: col, Message: "value is required"}
+		}
+	}
+
+	key := importer.key(row)
+	if first, dup := seen[key]; dup {
+		return &ImportRowError{Row: row.num, Column: importer.required[0], Value: row.get(importer.required[0]),
+			Message: fmt.Sprintf("duplicate of row %d", first)}
+	}
+	seen[key] = row.num
+
+	var result importResult
+	var err error
+	if importer.direct {
+		result, err = importer.apply(s.db.WithContext(ctx), batch, row)
+	} else {
+		err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			var err error
+			result, err = importer.apply(tx, batch, row)
+			if err != nil {
+				return err
+			}
+			if batch.DryRun {
+				return errDryRun
+			}
+			return nil
+		})
+	}
+	if err != nil && !errors.Is(err, errDryRun) {
+		var fieldErr *importFieldError
+		if errors.As(err, &fieldErr) {
+			return &ImportRowError{Row: row.num, Column: fieldErr.column, Value: row.get(fieldErr.column), Message: fieldErr.message}
+		}
+		s.logger.WithError(err).WithFields(logrus.Fields{"batch_id": batch.ID, "row": row.num}).Warn("Failed to import row")
+		return &ImportRowError{Row: row.num, Message: "failed to save row"}
+	}
+
+	if result.created {
+		batch.CreatedRows++
+	} else {
+		batch.UpdatedRows++
+	}
+	if result.studentID != 0 {
+		touched[result.studentID] = true
+	}
+	return nil
 }
 
-func NewImportBatchService(repo repository.ImportBatchRepository) ImportBatchService {
-	return &importBatchService{repo: repo}
+func (s *importBatchService) fail(batch *models.ImportBatch, err error) error {
+	encoded, _ := json.Marshal([]ImportRowError{{Message: err.Error()}})
+	batch.Errors = string(encoded)
+	batch.Status = models.ImportStatusFailed
+	batch.CompletedAt = time.Now().Unix()
+	s.save(batch)
+	return err
 }
 
-func (s *importBatchService) Create(batch *models.ImportBatch) error {
-	return s.repo.Create(batch)
+func (s *importBatchService) save(batch *models.ImportBatch) {
+	batch.UpdatedAt = time.Now().Unix()
+	if err := s.repo.Update(batch); err != nil {
+		s.logger.WithError(err).WithField("batch_id", batch.ID).Error("Failed to update import batch")
+	}
+}
+
+// RowErrors decodes the per-row errors recorded on a batch
+func (s *importBatchService) RowErrors(batch *models.ImportBatch) ([]ImportRowError, error) {
+	var rowErrors []ImportRowError
+	if batch.Errors == "" {
+		return rowErrors, nil
+	}
+	if err := json.Unmarshal([]byte(batch.Errors), &rowErrors); err != nil {
+		// Batches from before per-row errors hold plain text
+		return []ImportRowError{{Message: batch.Errors}}, nil
+	}
+	return rowErrors, nil
+}
+
+// Columns lists the columns an import of entityType accepts
+func (s *importBatchService) Columns(entityType string) ([]string, []string, error) {
+	importer, ok := s.importers[entityType]
+	if !ok {
+		return nil, nil, ErrUnknownImportEntity
+	}
+	return importer.required, importer.optional, nil
 }
 
 func (s *importBatchService) GetByID(id uint) (*models.ImportBatch, error) {
@@ -39,10 +291,6 @@ func (s *importBatchService) GetByStatus(status string, page, limit int) ([]mode
 	return s.repo.FindByStatus(status, page, limit)
 }
 
-func (s *importBatchService) Update(batch *models.ImportBatch) error {
-	return s.repo.Update(batch)
-}
-
 func (s *importBatchService) Delete(id uint) error {
 	return s.repo.Delete(id)
 }
@@ -50,3 +298,38 @@ func (s *importBatchService) Delete(id uint) error {
 func (s *importBatchService) GetRecentByEntityType(entityType string, limit int) ([]models.ImportBatch, error) {
 	return s.repo.FindRecentByEntityType(entityType, limit)
 }
+
+// splitHeader takes the first non-empty record as the header, mapping each
+// normalized column name ("Student ID" -> "student_id") to its index, and
+// returns the remaining non-empty records numbered by file line.
+func splitHeader(records [][]string) (map[string]int, []importRow) {
+	var header map[string]int
+	var rows []importRow
+	for i, record := range records {
+		if isBlankRecord(record) {
+			continue
+		}
+		if header == nil {
+			header = map[string]int{}
+			for j, name := range record {
+				name = strings.ToLower(strings.TrimSpace(name))
+				name = strings.Join(strings.Fields(name), "_")
+				if name != "" {
+					header[name] = j
+				}
+			}
+			continue
+		}
+		rows = append(rows, importRow{num: i + 1, values: record, header: header})
+	}
+	return header, rows
+}
+
+func isBlankRecord(record []string) bool {
+	for _, v := range record {
+		if strings.TrimSpace(v) != "" {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,602 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math"
+	"school-management-system/internal/models"
+	"school-management-system/pkg/utils"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// rowImporter saves one kind of record from import rows. Rows are matched
+// to existing records by key, so importing the same file twice updates
+// rather than duplicates.
+type rowImporter struct {
+	required []string
+	optional []string
+	key      func(row importRow) string
+	apply    func(tx *gorm.DB, batch *models.ImportBatch, row importRow) (importResult, error)
+	// direct importers save rows through services that make their own
+	// transactions. They are given the database rather than a row
+	// transaction, and must not write on a dry run.
+	direct bool
+}
+
+type importResult struct {
+	created   bool
+	studentID uint // student whose records changed, if any
+}
+
+// importFieldError rejects a row because of one of its values
+type importFieldError struct {
+	column  string
+	message string
+}
+
+func (e *importFieldError) Error() string {
+	return e.column + ": " + e.message
+}
+
+func fieldError(column, message string) error {
+	return &importFieldError{column: column, message: message}
+}
+
+type importRow struct {
+	num    int
+	values []string
+	header map[string]int
+}
+
+// get returns the trimmed value of a column, or "" if the column is absent
+func (r importRow) get(column string) string {
+	i, ok := r.header[column]
+	if !ok || i >= len(r.values) {
+		return ""
+	}
+	return strings.TrimSpace(r.values[i])
+}
+
+// setIfPresent copies a non-empty column value into updates
+func (r importRow) setIfPresent(updates map[string]interface{}, column, field string) {
+	if v := r.get(column); v != "" {
+		updates[field] = v
+	}
+}
+
+// date parses a column as YYYY-MM-DD or as a spreadsheet date serial
+// number. A blank value gives the zero time.
+func (r importRow) date(column string) (time.Time, error) {
+	v := r.get(column)
+	if v == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse("2006-01-02", v); err == nil {
+		return t, nil
+	}
+	if serial, err := strconv.ParseFloat(v, 64); err == nil && serial > 0 {
+		// Spreadsheet day 0 is 1899-12-30
+		return time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC).AddDate(0, 0, int(serial)), nil
+	}
+	return time.Time{}, fieldError(column, "expected a date as YYYY-MM-DD")
+}
+
+func (r importRow) int(column string) (int, error) {
+	v := r.get(column)
+	if v == "" {
+		return 0, nil
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil || f != math.Trunc(f) || f < 0 {
+		return 0, fieldError(column, "expected a whole number")
+	}
+	return int(f), nil
+}
+
+func (r importRow) float(column string) (float64, error) {
+	v := r.get(column)
+	if v == "" {
+		return 0, nil
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil || f < 0 {
+		return 0, fieldError(column, "expected a non-negative number")
+	}
+	return f, nil
+}
+
+func (s *importBatchService) rowImporters() map[string]rowImporter {
+	return map[string]rowImporter{
+		models.ImportEntityStudent: {
+			required: []string{"student_id", "email", "first_name", "last_name"},
+			optional: []string{"phone", "date_of_birth", "address", "grade_level", "enrollment_date", "parent_name", "parent_phone", "parent_email"},
+			key:      func(r importRow) string { return r.get("student_id") },
+			apply:    s.importStudent,
+		},
+		models.ImportEntityTeacher: {
+			required: []string{"teacher_id", "email", "first_name", "last_name"},
+			optional: []string{"phone", "date_of_birth", "address", "department", "qualification", "hire_date", "salary"},
+			key:      func(r importRow) string { return r.get("teacher_id") },
+			apply:    s.importTeacher,
+		},
+		models.ImportEntityCourse: {
+			required: []string{"course_code", "name"},
+			optional: []string{"description", "credit_hours", "department", "teacher_id", "room", "schedule", "max_students"},
+			key:      func(r importRow) string { return strings.ToUpper(r.get("course_code")) },
+			apply:    s.importCourse,
+		},
+		models.ImportEntityEnrollment: {
+			required: []string{"student_id", "course_code"},
+			optional: []string{"status", "enrolled_at", "override_reason"},
+			key:      func(r importRow) string { return r.get("student_id") + "|" + strings.ToUpper(r.get("course_code")) },
+			apply:    s.importEnrollment,
+			direct:   true,
+		},
+		models.ImportEntityGrade: {
+			required: []string{"student_id", "course_code", "score"},
+			optional: []string{"max_score", "grade", "remarks", "teacher_id", "graded_at", "term"},
+			key: func(r importRow) string {
+				return r.get("student_id") + "|" + strings.ToUpper(r.get("course_code")) + "|" + strings.ToUpper(r.get("term")) + "|" + r.get("graded_at")
+			},
+			apply: s.importGrade,
+		},
+	}
+}
+
+// importUser creates or updates the user account behind a student or
+// teacher. New accounts get a random password; the person signs in for the
+// first time through the forgot-password flow.
+func importUser(tx *gorm.DB, row importRow, userID uint, role models.UserRole) (uint, error) {
+	email := strings.ToLower(row.get("email"))
+	if !utils.ValidateEmail(email) {
+		return 0, fieldError("email", "invalid email address")
+	}
+	if phone := row.get("phone"); phone != "" && !utils.ValidatePhone(phone) {
+		return 0, fieldError("phone", "invalid phone number")
+	}
+	dob, err := row.date("date_of_birth")
+	if err != nil {
+		return 0, err
+	}
+
+	var owner models.User
+	if err := tx.Where("email = ?", email).First(&owner).Error; err == nil && owner.ID != userID {
+		return 0, fieldError("email", "email address is already used by another account")
+	}
+
+	if userID == 0 {
+		raw := make([]byte, 24)
+		if _, err := rand.Read(raw); err != nil {
+			return 0, err
+		}
+		user := &models.User{
+			FirstName:   row.get("first_name"),
+			LastName:    row.get("last_name"),
+			Email:       email,
+			Password:    base64.RawURLEncoding.EncodeToString(raw),
+			Phone:       row.get("phone"),
+			Role:        role,
+			DateOfBirth: dob,
+			Address:     row.get("address"),
+			IsActive:    true,
+		}
+		if err := tx.Omit(clause.Associations).Create(user).Error; err != nil {
+			return 0, err
+		}
+		return user.ID, nil
+	}
+
+	updates := map[string]interface{}{
+		"first_name": row.get("first_name"),
+		"last_name":  row.get("last_name"),
+		"email":      email,
+	}
+	row.setIfPresent(updates, "phone", "phone")
+	row.setIfPresent(updates, "address", "address")
+	if !dob.IsZero() {
+		updates["date_of_birth"] = dob
+	}
+	return userID, tx.Model(&models.User{}).Where("id = ?", userID).Updates(updates).Error
+}
+
+func (s *importBatchService) importStudent(tx *gorm.DB, _ *models.ImportBatch, row importRow) (importResult, error) {
+	enrolled, err := row.date("enrollment_date")
+	if err != nil {
+		return importResult{}, err
+	}
+	if email := row.get("parent_email"); email != "" && !utils.ValidateEmail(email) {
+		return importResult{}, fieldError("parent_email", "invalid email address")
+	}
+
+	var student models.Student
+	err = tx.Where("student_id = ?", row.get("student_id")).First(&student).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return importResult{}, err
+	}
+	exists := err == nil
+
+	userID, err := importUser(tx, row, student.UserID, models.RoleStudent)
+	if err != nil {
+		return importResult{}, err
+	}
+
+	if !exists {
+		if enrolled.IsZero() {
+			enrolled = time.Now()
+		}
+		student = models.Student{
+			UserID:         userID,
+			StudentID:      row.get("student_id"),
+			GradeLevel:     row.get("grade_level"),
+			EnrollmentDate: enrolled,
+			ParentName:     row.get("parent_name"),
+			ParentPhone:    row.get("parent_phone"),
+			ParentEmail:    strings.ToLower(row.get("parent_email")),
+		}
+		if err := tx.Omit(clause.Associations).Create(&student).Error; err != nil {
+			return importResult{}, err
+		}
+		return importResult{created: true, studentID: student.ID}, nil
+	}
+
+	updates := map[string]interface{}{}
+	row.setIfPresent(updates, "grade_level", "grade_level")
+	row.setIfPresent(updates, "parent_name", "parent_name")
+	row.setIfPresent(updates, "parent_phone", "parent_phone")
+	if v := row.get("parent_email"); v != "" {
+		updates["parent_email"] = strings.ToLower(v)
+	}
+	if !enrolled.IsZero() {
+		updates["enrollment_date"] = enrolled
+	}
+	if len(updates) > 0 {
+		if err := tx.Model(&models.Student{}).Where("id = ?", student.ID).Updates(updates).Error; err != nil {
+			return importResult{}, err
+		}
+	}
+	return importResult{studentID: student.ID}, nil
+}
+
+func (s *importBatchService) importTeacher(tx *gorm.DB, _ *models.ImportBatch, row importRow) (importResult, error) {
+	hired, err := row.date("hire_date")
+	if err != nil {
+		return importResult{}, err
+	}
+	salary, err := row.float("salary")
+	if err != nil {
+		return importResult{}, err
+	}
+
+	var teacher models.Teacher
+	err = tx.Where("teacher_id = ?", row.get("teacher_id")).First(&teacher).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return importResult{}, err
+	}
+	exists := err == nil
+
+	userID, err := importUser(tx, row, teacher.UserID, models.RoleTeacher)
+	if err != nil {
+		return importResult{}, err
+	}
+
+	if !exists {
+		if hired.IsZero() {
+			hired = time.Now()
+		}
+		teacher = models.Teacher{
+			UserID:        userID,
+			TeacherID:     row.get("teacher_id"),
+			Department:    row.get("department"),
+			Qualification: row.get("qualification"),
+			HireDate:      hired,
+			Salary:        salary,
+		}
+		if err := tx.Omit(clause.Associations).Create(&teacher).Error; err != nil {
+			return importResult{}, err
+		}
+		return importResult{created: true}, nil
+	}
+
+	updates := map[string]interface{}{}
+	row.setIfPresent(updates, "department", "department")
+	row.setIfPresent(updates, "qualification", "qualification")
+	if !hired.IsZero() {
+		updates["hire_date"] = hired
+	}
+	if row.get("salary") != "" {
+		updates["salary"] = salary
+	}
+	if len(updates) > 0 {
+		if err := tx.Model(&models.Teacher{}).Where("id = ?", teacher.ID).Updates(updates).Error; err != nil {
+			return importResult{}, err
+		}
+	}
+	return importResult{}, nil
+}
+
+func (s *importBatchService) importCourse(tx *gorm.DB, _ *models.ImportBatch, row importRow) (importResult, error) {
+	credits, err := row.int("credit_hours")
+	if err != nil {
+		return importResult{}, err
+	}
+	maxStudents, err := row.int("max_students")
+	if err != nil {
+		return importResult{}, err
+	}
+	var teacherID uint
+	if code := row.get("teacher_id"); code != "" {
+		var teacher models.Teacher
+		if err := tx.Where("teacher_id = ?", code).First(&teacher).Error; err != nil {
+			return importResult{}, fieldError("teacher_id", "no teacher with this ID")
+		}
+		teacherID = teacher.ID
+	}
+
+	code := strings.ToUpper(row.get("course_code"))
+	var course models.Course
+	err = tx.Where("course_code = ?", code).First(&course).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		course = models.Course{
+			CourseCode:  code,
+			Name:        row.get("name"),
+			Description: row.get("description"),
+			CreditHours: credits,
+			Department:  row.get("department"),
+			TeacherID:   teacherID,
+			Room:        row.get("room"),
+			Schedule:    row.get("schedule"),
+			MaxStudents: maxStudents,
+		}
+		if err := tx.Omit(clause.Associations).Create(&course).Error; err != nil {
+			return importResult{}, err
+		}
+		return importResult{created: true}, nil
+	}
+	if err != nil {
+		return importResult{}, err
+	}
+
+	updates := map[string]interface{}{"name": row.get("name")}
+	row.setIfPresent(updates, "description", "description")
+	row.setIfPresent(updates, "department", "department")
+	row.setIfPresent(updates, "room", "room")
+	row.setIfPresent(updates, "schedule", "schedule")
+	if row.get("credit_hours") != "" {
+		updates["credit_hours"] = credits
+	}
+	if row.get("max_students") != "" {
+		updates["max_students"] = maxStudents
+	}
+	if teacherID != 0 {
+		updates["teacher_id"] = teacherID
+	}
+	return importResult{}, tx.Model(&models.Course{}).Where("id = ?", course.ID).Updates(updates).Error
+}
+
+// importStudentAndCourse resolves the student_id and course_code columns
+func importStudentAndCourse(tx *gorm.DB, row importRow) (*models.Student, *models.Course, error) {
+	var student models.Student
+	if err := tx.Where("student_id = ?", row.get("student_id")).First(&student).Error; err != nil {
+		return nil, nil, fieldError("student_id", "no student with this ID")
+	}
+	var course models.Course
+	if err := tx.Where("course_code = ?", strings.ToUpper(row.get("course_code"))).First(&course).Error; err != nil {
+		return nil, nil, fieldError("course_code", "no course with this code")
+	}
+	return &student, &course, nil
+}
+
+// importEnrollment enrolls a student through the enrollment service, so
+// seats, the waitlist, requisites and timetable clashes are checked as for
+// any other enrollment and the change is recorded in its history. A row for
+// a student already enrolled in the course changes that enrollment's status
+// the way staff would. An override_reason enrolls the student whatever the
+// rules say, in any status, and is recorded as the reason. The legacy
+// pending status is read as requested.
+func (s *importBatchService) importEnrollment(db *gorm.DB, batch *models.ImportBatch, row importRow) (importResult, error) {
+	student, course, err := importStudentAndCourse(db, row)
+	if err != nil {
+		return importResult{}, err
+	}
+	status := strings.ToLower(row.get("status"))
+	if legacy, ok := models.LegacyEnrollmentStatuses[status]; ok {
+		status = legacy
+	}
+	if status != "" && !models.ValidEnrollmentStatus(status) {
+		return importResult{}, fieldError("status", "expected requested, approved, waitlisted, active, rejected, dropped, withdrawn or completed")
+	}
+	enrolledAt, err := row.date("enrolled_at")
+	if err != nil {
+		return importResult{}, err
+	}
+	overrideReason := row.get("override_reason")
+	reason := overrideReason
+	if reason == "" {
+		reason = fmt.Sprintf("import batch %d", batch.ID)
+	}
+	ctx := db.Statement.Context
+
+	var existing models.Enrollment
+	err = db.Where("student_id = ? AND course_id = ?", student.ID, course.ID).Order("id DESC").First(&existing).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		if overrideReason == "" && status != "" && status != models.EnrollmentActive && status != models.EnrollmentRequested {
+			return importResult{}, fieldError("status", "only active or requested enrollments can be imported without an override_reason")
+		}
+		enrollment := &models.Enrollment{StudentID: student.ID, CourseID: course.ID, Status: status, EnrolledAt: enrolledAt}
+		options := EnrollOptions{Override: overrideReason != "", ActorID: batch.CreatedBy, Reason: reason}
+		result := importResult{created: true, studentID: student.ID}
+		if batch.DryRun {
+			if options.Override {
+				return result, nil
+			}
+			planned, err := s.enrollments.CheckPlan(student.ID, []*models.Enrollment{enrollment}, false)
+			if err != nil {
+				return importResult{}, err
+			}
+			if reasons := planned[0].Reasons; len(reasons) > 0 {
+				return importResult{}, importEnrollmentError(rejected(reasons...))
+			}
+			return result, nil
+		}
+		if err := s.enrollments.EnrollStudent(ctx, enrollment, options); err != nil {
+			return importResult{}, importEnrollmentError(err)
+		}
+		return result, nil
+	}
+	if err != nil {
+		return importResult{}, err
+	}
+
+	if status != "" && status != existing.Status {
+		if batch.DryRun {
+			if !CanTransition(existing.Status, status) {
+				return importResult{}, fieldError("status", fmt.Sprintf("an enrollment that is %s cannot become %s", existing.Status, status))
+			}
+		} else if _, err := s.enrollments.ChangeStatus(ctx, existing.ID, status, batch.CreatedBy, reason); err != nil {
+			return importResult{}, importEnrollmentError(err)
+		}
+	}
+	if !enrolledAt.IsZero() && !batch.DryRun {
+		if err := db.Model(&models.Enrollment{}).Where("id = ?", existing.ID).Update("enrolled_at", enrolledAt).Error; err != nil {
+			return importResult{}, err
+		}
+	}
+	return importResult{studentID: student.ID}, nil
+}
+
+// importEnrollmentError reports an enrollment the rules refused against the
+// column that caused it
+func importEnrollmentError(err error) error {
+	var rejection *EnrollmentRejectedError
+	switch {
+	case errors.As(err, &rejection):
+		return fieldError("course_code", rejection.Error())
+	case errors.Is(err, ErrInvalidTransition), errors.Is(err, ErrDeadlinePassed):
+		return fieldError("status", err.Error())
+	}
+	return err
+}
+
+// importGrade records a manual grade, replacing an earlier manual grade for
+// the same student, course and term, so a repeated course keeps each
+// attempt. Without a grade column the letter is taken from the course's
+// grading scale.
+func (s *importBatchService) importGrade(tx *gorm.DB, _ *models.ImportBatch, row importRow) (importResult, error) {
+	student, course, err := importStudentAndCourse(tx, row)
+	if err != nil {
+		return importResult{}, err
+	}
+
+	score, err := row.float("score")
+	if err != nil {
+		return importResult{}, err
+	}
+	maxScore := 100.0
+	if row.get("max_score") != "" {
+		if maxScore, err = row.float("max_score"); err != nil || maxScore == 0 {
+			return importResult{}, fieldError("max_score", "expected a positive number")
+		}
+	}
+	if score > maxScore {
+		return importResult{}, fieldError("score", "score must be between 0 and max_score")
+	}
+	gradedAt, err := row.date("graded_at")
+	if err != nil {
+		return importResult{}, err
+	}
+	if gradedAt.IsZero() {
+		gradedAt = time.Now()
+	}
+	termID, err := importGradeTerm(tx, row, gradedAt)
+	if err != nil {
+		return importResult{}, err
+	}
+
+	gradedBy := course.TeacherID
+	if code := row.get("teacher_id"); code != "" {
+		var teacher models.Teacher
+		if err := tx.Where("teacher_id = ?", code).First(&teacher).Error; err != nil {
+			return importResult{}, fieldError("teacher_id", "no teacher with this ID")
+		}
+		gradedBy = teacher.ID
+	}
+	if gradedBy == 0 {
+		return importResult{}, fieldError("teacher_id", "required because the course has no teacher")
+	}
+
+	letter := strings.ToUpper(row.get("grade"))
+	if letter == "" {
+		if band := s.gradingScales.ScaleForCourse(course).BandForScore(score / maxScore * 100); band != nil {
+			letter = band.Letter
+		}
+	}
+
+	values := map[string]interface{}{
+		"grade":     letter,
+		"score":     score,
+		"max_score": maxScore,
+		"graded_by": gradedBy,
+		"graded_at": gradedAt,
+	}
+	row.setIfPresent(values, "remarks", "remarks")
+
+	var grade models.Grade
+	q := tx.Where("student_id = ? AND course_id = ? AND source = ?", student.ID, course.ID, models.GradeSourceManual)
+	if termID != nil {
+		q = q.Where("term_id = ?", *termID)
+	} else {
+		q = q.Where("term_id IS NULL OR term_id = 0")
+	}
+	err = q.First(&grade).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		grade = models.Grade{
+			StudentID: student.ID,
+			CourseID:  course.ID,
+			TermID:    termID,
+			Grade:     letter,
+			Score:     score,
+			MaxScore:  maxScore,
+			Remarks:   row.get("remarks"),
+			Source:    models.GradeSourceManual,
+			GradedBy:  gradedBy,
+			GradedAt:  gradedAt,
+		}
+		if err := tx.Omit(clause.Associations).Create(&grade).Error; err != nil {
+			return importResult{}, err
+		}
+		return importResult{created: true, studentID: student.ID}, nil
+	}
+	if err != nil {
+		return importResult{}, err
+	}
+	return importResult{studentID: student.ID}, tx.Model(&models.Grade{}).Where("id = ?", grade.ID).Updates(values).Error
+}
+
+// importGradeTerm finds the term a grade belongs to: the one named by code
+// or name in the term column, or else the one being taught when the grade
+// was given. A grade given outside every term has none.
+func importGradeTerm(tx *gorm.DB, row importRow, gradedAt time.Time) (*uint, error) {
+	var term models.AcademicTerm
+	if name := row.get("term"); name != "" {
+		if err := tx.Where("UPPER(code) = ? OR name = ?", strings.ToUpper(name), name).First(&term).Error; err != nil {
+			return nil, fieldError("term", "no term with this code or name")
+		}
+		return &term.ID, nil
+	}
+
+	err := tx.Where("start_date <= ? AND end_date >= ?", gradedAt, gradedAt).Order("start_date DESC").First(&term).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &term.ID, nil
+}
@@ -0,0 +1,395 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"school-management-system/internal/models"
+	"school-management-system/internal/repository"
+	"school-management-system/internal/scheduler"
+	"school-management-system/pkg/logger"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrJobNotFound = errors.New("job not found")
+	ErrJobRunning  = errors.New("job is already running")
+)
+
+// schedulerLease is the lease held by the instance that starts scheduled
+// jobs
+const schedulerLease = "job-scheduler"
+
+// JobFunc does one run of a job and returns a short summary of what it did
+type JobFunc func(ctx context.Context) (string, error)
+
+// JobDefinition describes a recurring job. Schedule is a cron expression;
+// an empty schedule means the job only runs when triggered.
+type JobDefinition struct {
+	Name        string
+	Description string
+	Schedule    string
+	Run         JobFunc
+}
+
+// SchedulerOptions tunes the job scheduler. Zero values take the defaults.
+type SchedulerOptions struct {
+	Instance     string        // identifies this server instance; defaults to host-pid-random
+	PollInterval time.Duration // how often due jobs are checked; default 30s
+	LeaseTTL     time.Duration // how long the scheduling lease lasts unrenewed; default 2m
+	StaleAfter   time.Duration // when a run still marked running is presumed dead; default 6h
+}
+
+type JobSchedulerService interface {
+	Register(def JobDefinition) error
+	Start()
+	Stop()
+	Tick(now time.Time) (bool, error)
+	Wait()
+	GetAll() ([]models.ScheduledJob, error)
+	GetByName(name string) (*models.ScheduledJob, error)
+	GetRuns(name string, page, limit int) ([]models.JobRun, int64, error)
+	Trigger(name string, triggeredBy *uint) (*models.JobRun, error)
+	SetPaused(name string, paused bool) (*models.ScheduledJob, error)
+	PurgeRuns(olderThanDays int) (int64, error)
+}
+
+type registeredJob struct {
+	def      JobDefinition
+	schedule *scheduler.Schedule
+}
+
+type jobSchedulerService struct {
+	repo    repository.ScheduledJobRepository
+	options SchedulerOptions
+	logger  *logrus.Logger
+
+	mu      sync.Mutex
+	jobs    map[string]registeredJob
+	ctx     context.Context
+	cancel  context.CancelFunc
+	running sync.WaitGroup
+	stopped chan struct{}
+}
+
+// NewJobSchedulerService creates a scheduler. Every server instance runs
+// one; they share job state through the database, and only the instance
+// holding the scheduling lease starts scheduled runs.
+func NewJobSchedulerService(repo repository.ScheduledJobRepository, options SchedulerOptions) JobSchedulerService {
+	if options.Instance == "" {
+		host, _ := os.Hostname()
+		options.Instance = fmt.Sprintf("%s-%d-%s", host, os.Getpid(), uuid.New().String()[:8])
+	}
+	if options.PollInterval <= 0 {
+		options.PollInterval = 30 * time.Second
+	}
+	if options.LeaseTTL <= 0 {
+		options.LeaseTTL = 2 * time.Minute
+	}
+	if options.StaleAfter <= 0 {
+		options.StaleAfter = 6 * time.Hour
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &jobSchedulerService{
+		repo:    repo,
+		options: options,
+		logger:  logger.GetLogger(),
+		jobs:    map[string]registeredJob{},
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+}
+
+// Register records a job definition, creating its row on first use. The
+// schedule and description always follow the code; whether the job is
+// paused is kept from the database.
+func (s *jobSchedulerService) Register(def JobDefinition) error {
+	var schedule *scheduler.Schedule
+	if def.Schedule != "" {
+		parsed, err := scheduler.Parse(def.Schedule)
+		if err != nil {
+			return fmt.Errorf("job %s: %v", def.Name, err)
+		}
+		schedule = parsed
+	}
+
+	now := time.Now()
+	job, err := s.repo.FindByName(def.Name)
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		job = &models.ScheduledJob{
+			Name:        def.Name,
+			Description: def.Description,
+			Schedule:    def.Schedule,
+			NextRunAt:   nextRun(schedule, now),
+			CreatedAt:   now.Unix(),
+			UpdatedAt:   now.Unix(),
+		}
+		if err := s.repo.Create(job); err != nil {
+			return err
+		}
+	case err != nil:
+		return err
+	default:
+		nextRunAt := job.NextRunAt
+		if job.Schedule != def.Schedule || (schedule != nil && nextRunAt == 0) {
+			nextRunAt = nextRun(schedule, now)
+		}
+		if err := s.repo.Define(def.Name, def.Description, def.Schedule, nextRunAt); err != nil {
+			return err
+		}
+	}
+
+	s.mu.Lock()
+	s.jobs[def.Name] = registeredJob{def: def, schedule: schedule}
+	s.mu.Unlock()
+	return nil
+}
+
+// Start runs the scheduling loop in the background until Stop
+func (s *jobSchedulerService) Start() {
+	s.mu.Lock()
+	if s.stopped != nil {
+		s.mu.Unlock()
+		return
+	}
+	s.stopped = make(chan struct{})
+	s.mu.Unlock()
+
+	go func() {
+		defer close(s.stopped)
+		ticker := time.NewTicker(s.options.PollInterval)
+		defer ticker.Stop()
+		for {
+			if _, err := s.Tick(time.Now()); err != nil {
+				s.logger.WithError(err).Warn("Job scheduler tick failed")
+			}
+			select {
+			case <-s.ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop ends the scheduling loop and gives up the lease so another
+// instance can take over at once. Runs in progress are cancelled through
+// their context; use Wait to wait for them.
+func (s *jobSchedulerService) Stop() {
+	s.cancel()
+	s.mu.Lock()
+	stopped := s.stopped
+	s.mu.Unlock()
+	if stopped != nil {
+		<-stopped
+	}
+	if err := s.repo.ReleaseLease(schedulerLease, s.options.Instance); err != nil {
+		s.logger.WithError(err).Warn("Failed to release job scheduler lease")
+	}
+}
+
+// Wait blocks until every run started by this instance has finished
+func (s *jobSchedulerService) Wait() {
+	s.running.Wait()
+}
+
+// Tick takes or renews the scheduling lease and starts every due job. It
+// reports whether this instance holds the lease.
+func (s *jobSchedulerService) Tick(now time.Time) (bool, error) {
+	leader, err := s.repo.AcquireLease(schedulerLease, s.options.Instance, now.Unix(), now.Add(s.options.LeaseTTL).Unix())
+	if err != nil || !leader {
+		return false, err
+	}
+
+	if err := s.repo.FailStaleRuns(now.Add(-s.options.StaleAfter).Unix()); err != nil {
+		s.logger.WithError(err).Warn("Failed to clean up stale job runs")
+	}
+
+	jobs, err := s.repo.FindAll()
+	if err != nil {
+		return true, err
+	}
+	for i := range jobs {
+		job := &jobs[i]
+		if job.Paused || job.NextRunAt == 0 || job.NextRunAt > now.Unix() {
+			continue
+		}
+		s.mu.Lock()
+		registered, ok := s.jobs[job.Name]
+		s.mu.Unlock()
+		if !ok {
+			// Defined by another version of the server
+			continue
+		}
+		if _, err := s.launch(registered, job, models.JobTriggerScheduled, nil, now); err != nil && !errors.Is(err, ErrJobRunning) {
+			s.logger.WithError(err).WithField("job", job.Name).Error("Failed to start scheduled job")
+		}
+	}
+	return true, nil
+}
+
+// Trigger runs a job now, even if it is paused. It fails with
+// ErrJobRunning if the job is running on any instance.
+func (s *jobSchedulerService) Trigger(name string, triggeredBy *uint) (*models.JobRun, error) {
+	s.mu.Lock()
+	registered, ok := s.jobs[name]
+	s.mu.Unlock()
+	if !ok {
+		return nil, ErrJobNotFound
+	}
+	job, err := s.repo.FindByName(name)
+	if err != nil {
+		return nil, ErrJobNotFound
+	}
+	return s.launch(registered, job, models.JobTriggerManual, triggeredBy, time.Now())
+}
+
+// launch claims the job and runs it in the background
+func (s *jobSchedulerService) launch(registered registeredJob, job *models.ScheduledJob, trigger string, triggeredBy *uint, now time.Time) (*models.JobRun, error) {
+	claimed, err := s.repo.Claim(job.Name, s.options.Instance, now.Unix(), now.Add(-s.options.StaleAfter).Unix())
+	if err != nil {
+		return nil, err
+	}
+	if !claimed {
+		return nil, ErrJobRunning
+	}
+
+	run := &models.JobRun{
+		JobName:     job.Name,
+		Trigger:     trigger,
+		TriggeredBy: triggeredBy,
+		Instance:    s.options.Instance,
+		Status:      models.JobRunRunning,
+		StartedAt:   now.Unix(),
+	}
+	if err := s.repo.CreateRun(run); err != nil {
+		job.LastStatus = models.JobRunFailed
+		s.repo.Finish(job)
+		return nil, err
+	}
+
+	// The caller keeps run; the goroutine works on its own copy
+	record := *run
+	s.running.Add(1)
+	go func() {
+		defer s.running.Done()
+		s.execute(registered, job, &record, trigger)
+	}()
+	return run, nil
+}
+
+func (s *jobSchedulerService) execute(registered registeredJob, job *models.ScheduledJob, run *models.JobRun, trigger string) {
+	log := s.logger.WithFields(logrus.Fields{"job": job.Name, "run_id": run.ID, "trigger": trigger})
+	start := time.Now()
+
+	result, err := func() (result string, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("panic: %v", r)
+			}
+		}()
+		return registered.def.Run(s.ctx)
+	}()
+
+	finished := time.Now()
+	run.FinishedAt = finished.Unix()
+	run.DurationMs = finished.Sub(start).Milliseconds()
+	run.Result = result
+	run.Status = models.JobRunSucceeded
+	if err != nil {
+		run.Status = models.JobRunFailed
+		run.Error = err.Error()
+	}
+	if err := s.repo.UpdateRun(run); err != nil {
+		log.WithError(err).Error("Failed to record job run")
+	}
+
+	job.LastRunAt = run.StartedAt
+	job.LastStatus = run.Status
+	job.LastError = run.Error
+	job.LastDurationMs = run.DurationMs
+	job.UpdatedAt = finished.Unix()
+	// Missed runs are not replayed: the next run is the first one after
+	// this run finished
+	if trigger == models.JobTriggerScheduled || (job.NextRunAt != 0 && job.NextRunAt <= finished.Unix()) {
+		job.NextRunAt = nextRun(registered.schedule, finished)
+	}
+	if err := s.repo.Finish(job); err != nil {
+		log.WithError(err).Error("Failed to release job")
+	}
+
+	if run.Status == models.JobRunFailed {
+		log.WithError(err).Error("Job failed")
+		return
+	}
+	log.WithFields(logrus.Fields{"duration_ms": run.DurationMs, "result": result}).Info("Job completed")
+}
+
+func (s *jobSchedulerService) GetAll() ([]models.ScheduledJob, error) {
+	return s.repo.FindAll()
+}
+
+func (s *jobSchedulerService) GetByName(name string) (*models.ScheduledJob, error) {
+	job, err := s.repo.FindByName(name)
+	if err != nil {
+		return nil, ErrJobNotFound
+	}
+	return job, nil
+}
+
+func (s *jobSchedulerService) GetRuns(name string, page, limit int) ([]models.JobRun, int64, error) {
+	return s.repo.FindRuns(name, page, limit)
+}
+
+// SetPaused stops or resumes a job's scheduled runs. A run already in
+// progress is not interrupted.
+func (s *jobSchedulerService) SetPaused(name string, paused bool) (*models.ScheduledJob, error) {
+	if err := s.repo.SetPaused(name, paused); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrJobNotFound
+		}
+		return nil, err
+	}
+	job, err := s.repo.FindByName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	// Resuming does not replay the runs missed while paused
+	s.mu.Lock()
+	registered, ok := s.jobs[name]
+	s.mu.Unlock()
+	if !paused && ok && registered.schedule != nil && job.NextRunAt < time.Now().Unix() {
+		job.NextRunAt = nextRun(registered.schedule, time.Now())
+		if err := s.repo.Define(name, job.Description, job.Schedule, job.NextRunAt); err != nil {
+			return nil, err
+		}
+	}
+	return job, nil
+}
+
+// PurgeRuns deletes run history older than the given number of days
+func (s *jobSchedulerService) PurgeRuns(olderThanDays int) (int64, error) {
+	if olderThanDays <= 0 {
+		return 0, nil
+	}
+	return s.repo.DeleteRunsOlderThan(time.Now().AddDate(0, 0, -olderThanDays).Unix())
+}
+
+func nextRun(schedule *scheduler.Schedule, after time.Time) int64 {
+	if schedule == nil {
+		return 0
+	}
+	next := schedule.Next(after)
+	if next.IsZero() {
+		return 0
+	}
+	return next.Unix()
+}
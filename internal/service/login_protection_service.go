@@ -0,0 +1,306 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"school-management-system/internal/models"
+	"school-management-system/internal/repository"
+	"school-management-system/pkg/logger"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Audit actions recorded for sign-in attempts
+const (
+	AuditActionLoginSuccess    = "login_success"
+	AuditActionLoginFailure    = "login_failure"
+	AuditActionLoginBlocked    = "login_blocked"
+	AuditActionAccountLocked   = "account_locked"
+	AuditActionAccountUnlocked = "account_unlocked"
+)
+
+// LockoutPolicy configures brute-force protection. Failures are counted
+// within Window; after FreeAttempts failures each further attempt must wait
+// BaseDelay, doubling per failure up to MaxDelay. Reaching MaxFailures locks
+// the account (or IPMaxFailures the IP) for LockDuration.
+type LockoutPolicy struct {
+	MaxFailures   int
+	IPMaxFailures int
+	FreeAttempts  int
+	BaseDelay     time.Duration
+	MaxDelay      time.Duration
+	LockDuration  time.Duration
+	Window        time.Duration
+}
+
+// DefaultLockoutPolicy returns the policy used when nothing is configured
+func DefaultLockoutPolicy() LockoutPolicy {
+	return LockoutPolicy{
+		MaxFailures:   5,
+		IPMaxFailures: 20,
+		FreeAttempts:  2,
+		BaseDelay:     time.Second,
+		MaxDelay:      30 * time.Second,
+		LockDuration:  15 * time.Minute,
+		Window:        15 * time.Minute,
+	}
+}
+
+// LoginBlockedError means a login was refused before the password was
+// checked, because of a lockout or a progressive delay.
+type LoginBlockedError struct {
+	Reason     string
+	RetryAfter time.Duration
+}
+
+func (e *LoginBlockedError) Error() string {
+	return fmt.Sprintf("%s, try again in %d seconds", e.Reason, int(math.Ceil(e.RetryAfter.Seconds())))
+}
+
+// LoginAttempt describes one sign-in attempt
+type LoginAttempt struct {
+	Email     string
+	IPAddress string
+	UserID    uint // zero if the email is unknown
+}
+
+type LoginProtectionService interface {
+	Check(attempt LoginAttempt) error
+	RecordFailure(attempt LoginAttempt, reason string)
+	RecordSuccess(attempt LoginAttempt)
+	GetLocked() ([]models.LoginThrottle, error)
+	UnlockAccount(email string, adminID uint) error
+	UnlockIP(ip string, adminID uint) error
+}
+
+type loginProtectionService struct {
+	repo             repository.LoginThrottleRepository
+	auditRepo        repository.AuditLogRepository
+	notificationRepo repository.NotificationRepository
+	userRepo         repository.UserRepository
+	emailService     *EmailService
+	policy           LockoutPolicy
+	logger           *logrus.Logger
+}
+
+func NewLoginProtectionService(
+	repo repository.LoginThrottleRepository,
+	auditRepo repository.AuditLogRepository,
+	notificationRepo repository.NotificationRepository,
+	userRepo repository.UserRepository,
+	emailService *EmailService,
+	policy LockoutPolicy,
+) LoginProtectionService {
+	return &loginProtectionService{
+		repo:             repo,
+		auditRepo:        auditRepo,
+		notificationRepo: notificationRepo,
+		userRepo:         userRepo,
+		emailService:     emailService,
+		policy:           policy,
+		logger:           logger.GetLogger(),
+	}
+}
+
+// Check refuses an attempt while the account or IP is locked or still
+// inside its progressive delay. Refused attempts are audited but do not
+// count as failures.
+func (s *loginProtectionService) Check(attempt LoginAttempt) error {
+	now := time.Now()
+	checks := []struct {
+		scope string
+		key   string
+	}{
+		{models.ThrottleScopeAccount, normalizeEmail(attempt.Email)},
+		{models.ThrottleScopeIP, attempt.IPAddress},
+	}
+
+	for _, c := range checks {
+		if c.key == "" {
+			continue
+		}
+		throttle, err := s.repo.Find(c.scope, c.key)
+		if err != nil {
+			continue
+		}
+
+		var blocked *LoginBlockedError
+		if throttle.LockedUntil > now.Unix() {
+			blocked = &LoginBlockedError{
+				Reason:     "too many failed login attempts",
+				RetryAfter: time.Unix(throttle.LockedUntil, 0).Sub(now),
+			}
+		} else if c.scope == models.ThrottleScopeAccount && throttle.LastFailureAt >= now.Add(-s.policy.Window).Unix() {
+			next := time.Unix(throttle.LastFailureAt, 0).Add(s.delayAfter(throttle.Failures))
+			if next.After(now) {
+				blocked = &LoginBlockedError{Reason: "login attempted too soon after a failure", RetryAfter: next.Sub(now)}
+			}
+		}
+
+		if blocked != nil {
+			s.audit(attempt, AuditActionLoginBlocked, "failure", map[string]interface{}{
+				"email":  attempt.Email,
+				"scope":  c.scope,
+				"reason": blocked.Reason,
+			})
+			return blocked
+		}
+	}
+	return nil
+}
+
+// RecordFailure counts a failed attempt against the account and the IP and
+// locks either one that reaches its limit.
+func (s *loginProtectionService) RecordFailure(attempt LoginAttempt, reason string) {
+	now := time.Now()
+	windowStart := now.Add(-s.policy.Window).Unix()
+	email := normalizeEmail(attempt.Email)
+
+	s.audit(attempt, AuditActionLoginFailure, "failure", map[string]interface{}{"email": attempt.Email, "reason": reason})
+
+	if email != "" {
+		account, err := s.repo.RecordFailure(models.ThrottleScopeAccount, email, now.Unix(), windowStart)
+		if err != nil {
+			s.logger.WithError(err).Error("Failed to record login failure")
+		} else if account.Failures >= s.policy.MaxFailures && account.LockedUntil <= now.Unix() {
+			s.lock(account, now)
+			s.audit(attempt, AuditActionAccountLocked, "failure", map[string]interface{}{
+				"email":    attempt.Email,
+				"failures": account.Failures,
+			})
+			s.notifyLocked(attempt)
+		}
+	}
+
+	if attempt.IPAddress != "" {
+		ip, err := s.repo.RecordFailure(models.ThrottleScopeIP, attempt.IPAddress, now.Unix(), windowStart)
+		if err != nil {
+			s.logger.WithError(err).Error("Failed to record login failure")
+		} else if ip.Failures >= s.policy.IPMaxFailures && ip.LockedUntil <= now.Unix() {
+			s.lock(ip, now)
+			s.logger.WithField("ip", attempt.IPAddress).Warn("IP address locked out after repeated login failures")
+		}
+	}
+}
+
+// RecordSuccess clears the account's failure count. The IP count is kept so
+// an attacker cannot reset it by signing in to an account of their own.
+func (s *loginProtectionService) RecordSuccess(attempt LoginAttempt) {
+	if err := s.repo.Reset(models.ThrottleScopeAccount, normalizeEmail(attempt.Email)); err != nil {
+		s.logger.WithError(err).Error("Failed to reset login failures")
+	}
+	s.audit(attempt, AuditActionLoginSuccess, "success", map[string]interface{}{"email": attempt.Email})
+}
+
+func (s *loginProtectionService) GetLocked() ([]models.LoginThrottle, error) {
+	return s.repo.FindLocked(time.Now().Unix())
+}
+
+func (s *loginProtectionService) UnlockAccount(email string, adminID uint) error {
+	email = normalizeEmail(email)
+	if _, err := s.repo.Find(models.ThrottleScopeAccount, email); err != nil {
+		return errors.New("account is not locked")
+	}
+	if err := s.repo.Reset(models.ThrottleScopeAccount, email); err != nil {
+		return err
+	}
+
+	attempt := LoginAttempt{Email: email}
+	if user, err := s.userRepo.FindByEmail(email); err == nil {
+		attempt.UserID = user.ID
+	}
+	s.audit(attempt, AuditActionAccountUnlocked, "success", map[string]interface{}{"email": email, "unlocked_by": adminID})
+	s.logger.WithFields(logrus.Fields{"email": email, "admin_id": adminID}).Info("Account unlocked")
+	return nil
+}
+
+func (s *loginProtectionService) UnlockIP(ip string, adminID uint) error {
+	if _, err := s.repo.Find(models.ThrottleScopeIP, ip); err != nil {
+		return errors.New("IP address is not locked")
+	}
+	if err := s.repo.Reset(models.ThrottleScopeIP, ip); err != nil {
+		return err
+	}
+	s.audit(LoginAttempt{IPAddress: ip}, AuditActionAccountUnlocked, "success", map[string]interface{}{"ip": ip, "unlocked_by": adminID})
+	s.logger.WithFields(logrus.Fields{"ip": ip, "admin_id": adminID}).Info("IP address unlocked")
+	return nil
+}
+
+// delayAfter is the wait required after the given number of failures
+func (s *loginProtectionService) delayAfter(failures int) time.Duration {
+	if failures <= s.policy.FreeAttempts {
+		return 0
+	}
+	delay := s.policy.BaseDelay
+	for i := s.policy.FreeAttempts + 1; i < failures && delay < s.policy.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > s.policy.MaxDelay {
+		delay = s.policy.MaxDelay
+	}
+	return delay
+}
+
+func (s *loginProtectionService) lock(throttle *models.LoginThrottle, now time.Time) {
+	until := now.Add(s.policy.LockDuration).Unix()
+	if err := s.repo.Lock(throttle.ID, until); err != nil {
+		s.logger.WithError(err).Error("Failed to lock after login failures")
+		return
+	}
+	throttle.LockedUntil = until
+}
+
+// notifyLocked tells the account owner, if the account exists, that it was
+// locked, both in-app and by email.
+func (s *loginProtectionService) notifyLocked(attempt LoginAttempt) {
+	if attempt.UserID == 0 {
+		return
+	}
+	user, err := s.userRepo.FindByID(attempt.UserID)
+	if err != nil {
+		return
+	}
+
+	minutes := int(s.policy.LockDuration.Minutes())
+	now := time.Now().Unix()
+	if err := s.notificationRepo.Create(&models.Notification{
+		UserID:    user.ID,
+		Title:     "Account temporarily locked",
+		Message:   fmt.Sprintf("Your account was locked for %d minutes after repeated failed sign-in attempts from %s.", minutes, attempt.IPAddress),
+		Type:      "in-app",
+		Subject:   "security",
+		SentAt:    now,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}); err != nil {
+		s.logger.WithError(err).Warn("Failed to create lockout notification")
+	}
+	if err := s.emailService.SendAccountLockedNotification(UserRecipient(user), user.FirstName, attempt.IPAddress, minutes); err != nil {
+		s.logger.WithError(err).Warn("Failed to send lockout email")
+	}
+	s.logger.WithFields(logrus.Fields{"user_id": user.ID, "ip": attempt.IPAddress}).Warn("Account locked after repeated login failures")
+}
+
+func (s *loginProtectionService) audit(attempt LoginAttempt, action, status string, details map[string]interface{}) {
+	value, _ := json.Marshal(details)
+	if err := s.auditRepo.Create(&models.AuditLog{
+		UserID:    attempt.UserID,
+		Action:    action,
+		Entity:    "user",
+		EntityID:  attempt.UserID,
+		NewValue:  string(value),
+		IPAddress: attempt.IPAddress,
+		Status:    status,
+		CreatedAt: time.Now().Unix(),
+	}); err != nil {
+		s.logger.WithError(err).Warn("Failed to write login audit log")
+	}
+}
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
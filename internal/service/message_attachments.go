@@ -0,0 +1,122 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"school-management-system/internal/models"
+	"strings"
+	"time"
+)
+
+func (s *messageService) MaxAttachmentSize() int64 {
+	return s.options.MaxAttachmentSize
+}
+
+// UploadAttachment stores a file for the user to send with a message. The
+// content type is detected from the file itself rather than trusted from
+// the client.
+func (s *messageService) UploadAttachment(userID uint, fileName string, data []byte) (*models.MessageAttachment, error) {
+	if int64(len(data)) > s.options.MaxAttachmentSize {
+		return nil, fmt.Errorf("%w: the limit is %d bytes", ErrAttachmentTooLarge, s.options.MaxAttachmentSize)
+	}
+	if len(data) == 0 {
+		return nil, fmt.Errorf("%w: the file is empty", ErrInvalidMessage)
+	}
+
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	relative := filepath.Join(now.Format("2006"), now.Format("01"), hex.EncodeToString(token))
+	path := filepath.Join(s.options.AttachmentDir, relative)
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(path, data, 0o640); err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256(data)
+	attachment := &models.MessageAttachment{
+		UploadedBy:  userID,
+		FileName:    cleanFileName(fileName),
+		ContentType: http.DetectContentType(data),
+		Size:        int64(len(data)),
+		SHA256:      hex.EncodeToString(sum[:]),
+		StoragePath: relative,
+		CreatedAt:   now.Unix(),
+	}
+	if err := s.repo.CreateAttachment(attachment); err != nil {
+		os.Remove(path)
+		return nil, err
+	}
+	return attachment, nil
+}
+
+// cleanFileName keeps the base name of an uploaded file, which is only ever
+// shown and offered as the download name
+func cleanFileName(name string) string {
+	name = filepath.Base(strings.ReplaceAll(name, "\\", "/"))
+	name = strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f || r == '"' {
+			return -1
+		}
+		return r
+	}, name)
+	name = strings.TrimSpace(name)
+	if len(name) > 255 {
+		name = name[:255]
+	}
+	name = strings.ToValidUTF8(name, "")
+	if name == "" || name == "." || name == "/" {
+		return "attachment"
+	}
+	return name
+}
+
+// GetAttachment returns an attachment the user may download, with the path
+// of its file. Uploads not yet sent are visible only to the uploader, and
+// sent ones to the participants of the message's conversation.
+func (s *messageService) GetAttachment(id, userID uint) (*models.MessageAttachment, string, error) {
+	attachment, err := s.repo.FindAttachmentByID(id)
+	if err != nil {
+		return nil, "", ErrAttachmentNotFound
+	}
+	if attachment.MessageID == 0 {
+		if attachment.UploadedBy != userID {
+			return nil, "", ErrAttachmentNotFound
+		}
+	} else if _, err := s.visibleMessage(attachment.MessageID, userID); err != nil {
+		return nil, "", ErrAttachmentNotFound
+	}
+	return attachment, filepath.Join(s.options.AttachmentDir, attachment.StoragePath), nil
+}
+
+// PurgeUnclaimedAttachments deletes uploads that were never sent with a
+// message
+func (s *messageService) PurgeUnclaimedAttachments(olderThan time.Duration) (int, error) {
+	attachments, err := s.repo.FindUnclaimedAttachments(time.Now().Add(-olderThan).Unix())
+	if err != nil {
+		return 0, err
+	}
+	for i := range attachments {
+		if err := s.repo.DeleteAttachment(attachments[i].ID); err != nil {
+			return i, err
+		}
+		s.removeAttachmentFile(&attachments[i])
+	}
+	return len(attachments), nil
+}
+
+func (s *messageService) removeAttachmentFile(attachment *models.MessageAttachment) {
+	path := filepath.Join(s.options.AttachmentDir, attachment.StoragePath)
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		s.logger.WithError(err).WithField("attachment_id", attachment.ID).Warn("Failed to delete attachment file")
+	}
+}
@@ -0,0 +1,542 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"school-management-system/internal/models"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// GetInbox returns the user's conversations, most recently active first,
+// each with its last message and unread count. Channels of the courses the
+// user has joined since the last sync are added first.
+func (s *messageService) GetInbox(userID uint, page, limit int) ([]ConversationSummary, int64, error) {
+	s.joinCourseChannels(userID)
+
+	conversations, total, err := s.conversations.FindForUser(userID, page, limit)
+	if err != nil || len(conversations) == 0 {
+		return []ConversationSummary{}, total, err
+	}
+	ids := make([]uint, len(conversations))
+	for i, c := range conversations {
+		ids[i] = c.ID
+	}
+	unread, err := s.conversations.CountUnreadByConversation(userID, ids)
+	if err != nil {
+		return nil, 0, err
+	}
+	counts, err := s.conversations.CountActiveParticipants(ids)
+	if err != nil {
+		return nil, 0, err
+	}
+	latest, err := s.repo.FindLatestByConversationIDs(ids)
+	if err != nil {
+		return nil, 0, err
+	}
+	last := make(map[uint]*models.Message, len(latest))
+	for i := range latest {
+		last[latest[i].ConversationID] = &latest[i]
+	}
+
+	summaries := make([]ConversationSummary, 0, len(conversations))
+	for _, conversation := range conversations {
+		if err := s.loadParticipants(&conversation); err != nil {
+			return nil, 0, err
+		}
+		summary := ConversationSummary{
+			Conversation: conversation,
+			LastMessage:  last[conversation.ID],
+			UnreadCount:  unread[conversation.ID],
+		}
+		if !hidesParticipants(&conversation, userID) {
+			summary.ParticipantCount = counts[conversation.ID]
+		}
+		summaries = append(summaries, summary)
+	}
+	return summaries, total, nil
+}
+
+// GetConversationByID returns a conversation the user takes part in
+func (s *messageService) GetConversationByID(conversationID, userID uint) (*models.Conversation, error) {
+	conversation, err := s.participantConversation(conversationID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.loadParticipants(conversation); err != nil {
+		return nil, err
+	}
+	return conversation, nil
+}
+
+// loadParticipants lists who is in a direct or group conversation. Course
+// channels and broadcasts can be large and only carry a count, and
+// broadcast recipients never see each other.
+func (s *messageService) loadParticipants(conversation *models.Conversation) error {
+	if conversation.Kind != models.ConversationDirect && conversation.Kind != models.ConversationGroup {
+		return nil
+	}
+	participants, err := s.conversations.FindActiveParticipants(conversation.ID)
+	conversation.Participants = participants
+	return err
+}
+
+func hidesParticipants(conversation *models.Conversation, userID uint) bool {
+	return conversation.Kind == models.ConversationBroadcast && conversation.CreatedBy != userID
+}
+
+// CreateGroup starts a group conversation owned by its creator
+func (s *messageService) CreateGroup(creatorID uint, title string, userIDs []uint) (*models.Conversation, error) {
+	title = strings.TrimSpace(title)
+	if title == "" || len(title) > 200 {
+		return nil, fmt.Errorf("%w: title is required and at most 200 characters", ErrInvalidMessage)
+	}
+	creator, err := s.users.FindByID(creatorID)
+	if err != nil {
+		return nil, err
+	}
+	members, err := s.groupMembers(creator, userIDs)
+	if err != nil {
+		return nil, err
+	}
+	if len(members) == 0 {
+		return nil, fmt.Errorf("%w: a group needs at least one other participant", ErrInvalidMessage)
+	}
+	if len(members)+1 > maxGroupParticipants {
+		return nil, fmt.Errorf("%w: a group has at most %d participants", ErrInvalidMessage, maxGroupParticipants)
+	}
+
+	now := time.Now().Unix()
+	conversation := &models.Conversation{
+		Kind:          models.ConversationGroup,
+		Title:         title,
+		CreatedBy:     creatorID,
+		LastMessageAt: now,
+		Participants:  []models.ConversationParticipant{{UserID: creatorID, Role: models.ParticipantOwner, JoinedAt: now}},
+	}
+	for _, member := range members {
+		conversation.Participants = append(conversation.Participants, models.ConversationParticipant{
+			UserID: member.ID, Role: models.ParticipantMember, JoinedAt: now,
+		})
+	}
+	if err := s.conversations.Create(conversation); err != nil {
+		return nil, err
+	}
+	return s.GetConversationByID(conversation.ID, creatorID)
+}
+
+// AddParticipants adds users to a group. Only its owner may add users.
+func (s *messageService) AddParticipants(conversationID, actorID uint, userIDs []uint) error {
+	conversation, err := s.participantConversation(conversationID, actorID)
+	if err != nil {
+		return err
+	}
+	if conversation.Kind != models.ConversationGroup {
+		return fmt.Errorf("%w: participants can only be added to groups", ErrInvalidMessage)
+	}
+	participant, err := s.conversations.FindParticipant(conversationID, actorID)
+	if err != nil || participant.Role != models.ParticipantOwner {
+		return fmt.Errorf("%w: only the group's owner can add participants", ErrMessagingForbidden)
+	}
+	actor, err := s.users.FindByID(actorID)
+	if err != nil {
+		return err
+	}
+	members, err := s.groupMembers(actor, userIDs)
+	if err != nil {
+		return err
+	}
+	counts, err := s.conversations.CountActiveParticipants([]uint{conversationID})
+	if err != nil {
+		return err
+	}
+	if counts[conversationID]+int64(len(members)) > maxGroupParticipants {
+		return fmt.Errorf("%w: a group has at most %d participants", ErrInvalidMessage, maxGroupParticipants)
+	}
+
+	// Newcomers see the history, but none of it counts as unread
+	latest, err := s.repo.FindLatestID(conversationID)
+	if err != nil {
+		return err
+	}
+	now := time.Now().Unix()
+	for _, member := range members {
+		err := s.conversations.AddParticipant(&models.ConversationParticipant{
+			ConversationID: conversationID, UserID: member.ID, Role: models.ParticipantMember,
+			LastReadMessageID: latest, JoinedAt: now,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RemoveParticipant takes a user out of a group. Participants may leave,
+// and the owner may remove anyone.
+func (s *messageService) RemoveParticipant(conversationID, actorID, userID uint) error {
+	conversation, err := s.participantConversation(conversationID, actorID)
+	if err != nil {
+		return err
+	}
+	if conversation.Kind != models.ConversationGroup {
+		return fmt.Errorf("%w: participants can only be removed from groups", ErrInvalidMessage)
+	}
+	if actorID != userID {
+		participant, err := s.conversations.FindParticipant(conversationID, actorID)
+		if err != nil || participant.Role != models.ParticipantOwner {
+			return fmt.Errorf("%w: only the group's owner can remove participants", ErrMessagingForbidden)
+		}
+	}
+	if _, err := s.participantConversation(conversationID, userID); err != nil {
+		return ErrRecipientNotFound
+	}
+	return s.conversations.RemoveParticipant(conversationID, userID, time.Now().Unix())
+}
+
+// groupMembers resolves the users to add to a group, leaving out the actor,
+// and applies the moderation rules
+func (s *messageService) groupMembers(actor *models.User, userIDs []uint) ([]*models.User, error) {
+	var members []*models.User
+	for _, id := range uniqueIDs(userIDs) {
+		if id == actor.ID {
+			continue
+		}
+		user, err := s.users.FindByID(id)
+		if err != nil || !user.IsActive {
+			return nil, fmt.Errorf("%w: user %d", ErrRecipientNotFound, id)
+		}
+		if err := s.checkDirect(actor, user); err != nil {
+			return nil, err
+		}
+		members = append(members, user)
+	}
+	return members, nil
+}
+
+// directConversation returns the direct conversation between the sender
+// and another user, starting it if they have never talked
+func (s *messageService) directConversation(sender *models.User, otherID uint) (*models.Conversation, *models.User, error) {
+	if otherID == sender.ID {
+		return nil, nil, fmt.Errorf("%w: you cannot message yourself", ErrInvalidMessage)
+	}
+	other, err := s.users.FindByID(otherID)
+	if err != nil || !other.IsActive {
+		return nil, nil, ErrRecipientNotFound
+	}
+	if err := s.checkDirect(sender, other); err != nil {
+		return nil, nil, err
+	}
+	conversation, err := s.findOrCreateDirect(sender.ID, other.ID)
+	return conversation, other, err
+}
+
+func (s *messageService) findOrCreateDirect(userID1, userID2 uint) (*models.Conversation, error) {
+	key := directKey(userID1, userID2)
+	conversation, err := s.conversations.FindByDirectKey(key)
+	if err == nil || !errors.Is(err, gorm.ErrRecordNotFound) {
+		return conversation, err
+	}
+
+	now := time.Now().Unix()
+	conversation = &models.Conversation{
+		Kind:          models.ConversationDirect,
+		DirectKey:     &key,
+		CreatedBy:     userID1,
+		LastMessageAt: now,
+		Participants:  []models.ConversationParticipant{{UserID: userID1, Role: models.ParticipantMember, JoinedAt: now}},
+	}
+	if userID2 != userID1 {
+		conversation.Participants = append(conversation.Participants,
+			models.ConversationParticipant{UserID: userID2, Role: models.ParticipantMember, JoinedAt: now})
+	}
+	if err := s.conversations.Create(conversation); err != nil {
+		// Started at the same moment by the other user
+		if existing, findErr := s.conversations.FindByDirectKey(key); findErr == nil {
+			return existing, nil
+		}
+		return nil, err
+	}
+	return conversation, nil
+}
+
+func directKey(userID1, userID2 uint) string {
+	if userID1 > userID2 {
+		userID1, userID2 = userID2, userID1
+	}
+	return fmt.Sprintf("%d:%d", userID1, userID2)
+}
+
+// GetCourseChannel returns the channel of a course the user teaches or is
+// enrolled in, bringing its membership up to date
+func (s *messageService) GetCourseChannel(courseID, userID uint) (*models.Conversation, error) {
+	if _, err := s.syncCourseChannel(courseID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrConversationNotFound
+		}
+		return nil, err
+	}
+	channel, err := s.conversations.FindCourseChannel(courseID)
+	if err != nil {
+		return nil, ErrConversationNotFound
+	}
+	return s.GetConversationByID(channel.ID, userID)
+}
+
+// SyncCourseChannels brings the membership of every course channel in line
+// with its course's teachers and active enrollments
+func (s *messageService) SyncCourseChannels() error {
+	channels, err := s.conversations.FindByKind(models.ConversationCourse)
+	if err != nil {
+		return err
+	}
+	var failed int
+	for _, channel := range channels {
+		if channel.CourseID == nil {
+			continue
+		}
+		if _, err := s.syncCourseChannel(*channel.CourseID); err != nil {
+			failed++
+			s.logger.WithError(err).WithField("course_id", *channel.CourseID).Warn("Failed to sync course channel")
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d course channels failed to sync", failed, len(channels))
+	}
+	return nil
+}
+
+// joinCourseChannels makes sure the user is in the channel of every course
+// they teach or are enrolled in. Failures only delay joining until the next
+// sync, so they are logged.
+func (s *messageService) joinCourseChannels(userID uint) {
+	courseIDs, err := s.conversations.CourseIDsForUser(userID)
+	if err != nil {
+		s.logger.WithError(err).WithField("user_id", userID).Warn("Failed to find the user's courses")
+		return
+	}
+	for _, courseID := range courseIDs {
+		if channel, err := s.conversations.FindCourseChannel(courseID); err == nil {
+			if p, err := s.conversations.FindParticipant(channel.ID, userID); err == nil && p.LeftAt == 0 {
+				continue
+			}
+		}
+		if _, err := s.syncCourseChannel(courseID); err != nil {
+			s.logger.WithError(err).WithField("course_id", courseID).Warn("Failed to sync course channel")
+		}
+	}
+}
+
+// syncCourseChannel creates a course's channel if needed and sets its
+// participants: the course's teachers as owners and its actively enrolled
+// students as members
+func (s *messageService) syncCourseChannel(courseID uint) (*models.Conversation, error) {
+	course, err := s.courses.FindByID(courseID)
+	if err != nil {
+		return nil, err
+	}
+	channel, err := s.conversations.FindCourseChannel(courseID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		channel = &models.Conversation{
+			Kind:          models.ConversationCourse,
+			Title:         strings.TrimSpace(course.CourseCode + " " + course.Name),
+			CourseID:      &courseID,
+			LastMessageAt: time.Now().Unix(),
+		}
+		if err := s.conversations.Create(channel); err != nil {
+			if channel, err = s.conversations.FindCourseChannel(courseID); err != nil {
+				return nil, err
+			}
+		}
+	} else if err != nil {
+		return nil, err
+	}
+
+	teachers, err := s.conversations.CourseTeacherUserIDs(courseID)
+	if err != nil {
+		return nil, err
+	}
+	students, err := s.conversations.CourseStudentUserIDs(courseID)
+	if err != nil {
+		return nil, err
+	}
+	return channel, s.setParticipants(channel.ID, teachers, students)
+}
+
+// setParticipants makes owners and members the conversation's participants,
+// removing anyone else
+func (s *messageService) setParticipants(conversationID uint, owners, members []uint) error {
+	want := make(map[uint]string, len(owners)+len(members))
+	for _, id := range members {
+		want[id] = models.ParticipantMember
+	}
+	for _, id := range owners {
+		want[id] = models.ParticipantOwner
+	}
+
+	current, err := s.conversations.FindActiveParticipants(conversationID)
+	if err != nil {
+		return err
+	}
+	now := time.Now().Unix()
+	for _, p := range current {
+		role, ok := want[p.UserID]
+		if !ok {
+			if err := s.conversations.RemoveParticipant(conversationID, p.UserID, now); err != nil {
+				return err
+			}
+			continue
+		}
+		if role == p.Role {
+			delete(want, p.UserID)
+		}
+	}
+	if len(want) == 0 {
+		return nil
+	}
+
+	latest, err := s.repo.FindLatestID(conversationID)
+	if err != nil {
+		return err
+	}
+	for userID, role := range want {
+		err := s.conversations.AddParticipant(&models.ConversationParticipant{
+			ConversationID: conversationID, UserID: userID, Role: role,
+			LastReadMessageID: latest, JoinedAt: now,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Broadcast posts a message from a course's teacher, or an admin, to the
+// course's actively enrolled students or to their guardians. Each teacher
+// has one broadcast conversation per course and audience, whose recipients
+// are refreshed on every post. Recipients' replies go privately to the
+// teacher.
+func (s *messageService) Broadcast(senderID, courseID uint, audience string, content string, attachmentIDs []uint) (*models.Message, error) {
+	if audience != models.AudienceStudents && audience != models.AudienceGuardians {
+		return nil, fmt.Errorf("%w: audience must be %q or %q", ErrInvalidMessage, models.AudienceStudents, models.AudienceGuardians)
+	}
+	sender, err := s.users.FindByID(senderID)
+	if err != nil {
+		return nil, err
+	}
+	course, err := s.courses.FindByID(courseID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: course %d does not exist", ErrInvalidMessage, courseID)
+	}
+	if sender.Role != models.RoleAdmin {
+		teachers, err := s.conversations.CourseTeacherUserIDs(courseID)
+		if err != nil {
+			return nil, err
+		}
+		if !containsID(teachers, senderID) {
+			return nil, fmt.Errorf("%w: only the course's teachers can broadcast to it", ErrMessagingForbidden)
+		}
+	}
+
+	var recipients []uint
+	if audience == models.AudienceStudents {
+		recipients, err = s.conversations.CourseStudentUserIDs(courseID)
+	} else {
+		recipients, err = s.conversations.CourseGuardianUserIDs(courseID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(recipients) == 0 {
+		return nil, fmt.Errorf("%w: the course has no %s to broadcast to", ErrRecipientNotFound, audience)
+	}
+
+	conversation, err := s.conversations.FindBroadcast(senderID, courseID, audience)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		conversation = &models.Conversation{
+			Kind:      models.ConversationBroadcast,
+			Title:     strings.TrimSpace(course.CourseCode+" "+course.Name) + " (" + audience + ")",
+			CourseID:  &courseID,
+			Audience:  audience,
+			CreatedBy: senderID,
+		}
+		if err := s.conversations.Create(conversation); err != nil {
+			return nil, err
+		}
+	} else if err != nil {
+		return nil, err
+	}
+	// The sender may be among the guardians; they stay the owner
+	members := make([]uint, 0, len(recipients))
+	for _, id := range recipients {
+		if id != senderID {
+			members = append(members, id)
+		}
+	}
+	if err := s.setParticipants(conversation.ID, []uint{senderID}, members); err != nil {
+		return nil, err
+	}
+
+	return s.post(conversation, sender, 0, content, nil, attachmentIDs)
+}
+
+func containsID(ids []uint, id uint) bool {
+	for _, candidate := range ids {
+		if candidate == id {
+			return true
+		}
+	}
+	return false
+}
+
+// BackfillConversations moves direct messages stored before conversations
+// existed into direct conversations, carrying over what each side has read.
+// It returns how many messages were moved.
+func (s *messageService) BackfillConversations() (int, error) {
+	var moved int
+	for {
+		messages, err := s.repo.FindUnthreaded(500)
+		if err != nil || len(messages) == 0 {
+			return moved, err
+		}
+		var batch int64
+		done := make(map[string]bool)
+		for _, message := range messages {
+			key := directKey(message.SenderID, message.ReceiverID)
+			if done[key] {
+				continue
+			}
+			done[key] = true
+
+			conversation, err := s.findOrCreateDirect(message.SenderID, message.ReceiverID)
+			if err != nil {
+				return moved, err
+			}
+			n, err := s.repo.AssignConversation(message.SenderID, message.ReceiverID, conversation.ID)
+			if err != nil {
+				return moved, err
+			}
+			batch += n
+			for _, userID := range []uint{message.SenderID, message.ReceiverID} {
+				readID, err := s.repo.LatestReadID(conversation.ID, userID)
+				if err != nil {
+					return moved, err
+				}
+				if _, err := s.conversations.MarkRead(conversation.ID, userID, readID); err != nil {
+					return moved, err
+				}
+			}
+			if latest, err := s.repo.FindLatestByConversationIDs([]uint{conversation.ID}); err == nil && len(latest) == 1 {
+				if err := s.conversations.Touch(conversation.ID, latest[0].CreatedAt); err != nil {
+					return moved, err
+				}
+			}
+		}
+		moved += int(batch)
+		if batch == 0 {
+			return moved, fmt.Errorf("%d messages could not be moved into conversations", len(messages))
+		}
+	}
+}
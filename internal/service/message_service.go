@@ -1,57 +1,508 @@
 package service
 
 import (
+	"errors"
+	"fmt"
 	"school-management-system/internal/models"
+	"school-management-system/internal/realtime"
 	"school-management-system/internal/repository"
+	"school-management-system/pkg/logger"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrMessageNotFound      = errors.New("message not found")
+	ErrConversationNotFound = errors.New("conversation not found")
+	ErrRecipientNotFound    = errors.New("recipient not found")
+	ErrInvalidMessage       = errors.New("invalid message")
+	ErrMessagingForbidden   = errors.New("not allowed")
+	ErrAttachmentNotFound   = errors.New("attachment not found")
+	ErrAttachmentTooLarge   = errors.New("attachment is too large")
 )
 
+// SettingStudentDirectMessages lets students message other students
+// directly and in groups they create. It defaults to "true".
+const SettingStudentDirectMessages = "messaging.student_direct_messages"
+
+const (
+	maxMessageLength      = 10000
+	maxMessageAttachments = 10
+	maxGroupParticipants  = 100
+)
+
+// MessagingOptions configures attachment storage. Zero values take the
+// defaults.
+type MessagingOptions struct {
+	AttachmentDir     string // default "attachments"
+	MaxAttachmentSize int64  // bytes; default 10MB
+}
+
+// SendMessageRequest posts to an existing conversation, or to the direct
+// conversation with ReceiverID when ConversationID is 0
+type SendMessageRequest struct {
+	ConversationID uint   `json:"conversation_id"`
+	ReceiverID     uint   `json:"receiver_id"`
+	Content        string `json:"content"`
+	ReplyToID      *uint  `json:"reply_to_id"`
+	AttachmentIDs  []uint `json:"attachment_ids"`
+}
+
+// ConversationSummary is an inbox entry
+type ConversationSummary struct {
+	models.Conversation
+	ParticipantCount int64           `json:"participant_count,omitempty"`
+	LastMessage      *models.Message `json:"last_message,omitempty"`
+	UnreadCount      int64           `json:"unread_count"`
+}
+
 type MessageService interface {
-	Create(message *models.Message) error
-	GetByID(id uint) (*models.Message, error)
-	GetByReceiverID(receiverID uint, page, limit int) ([]models.Message, int64, error)
-	GetConversation(userID1, userID2 uint, page, limit int) ([]models.Message, int64, error)
-	Update(message *models.Message) error
-	Delete(id uint) error
-	MarkAsRead(id uint) error
+	Send(senderID uint, req SendMessageRequest) (*models.Message, error)
+	Broadcast(senderID, courseID uint, audience string, content string, attachmentIDs []uint) (*models.Message, error)
+	Edit(id, userID uint, content string) (*models.Message, error)
+	Delete(id, userID uint) error
+	MarkAsRead(id, userID uint) error
+	MarkConversationRead(conversationID, userID uint) error
 	CountUnread(userID uint) (int64, error)
+
+	GetInbox(userID uint, page, limit int) ([]ConversationSummary, int64, error)
+	GetConversation(userID1, userID2 uint, page, limit int) ([]models.Message, int64, error)
+	GetConversationByID(conversationID, userID uint) (*models.Conversation, error)
+	GetMessages(conversationID, userID uint, page, limit int) ([]models.Message, int64, error)
+	CreateGroup(creatorID uint, title string, userIDs []uint) (*models.Conversation, error)
+	AddParticipants(conversationID, actorID uint, userIDs []uint) error
+	RemoveParticipant(conversationID, actorID, userID uint) error
+	GetCourseChannel(courseID, userID uint) (*models.Conversation, error)
+	SyncCourseChannels() error
+	BackfillConversations() (int, error)
+
+	UploadAttachment(userID uint, fileName string, data []byte) (*models.MessageAttachment, error)
+	GetAttachment(id, userID uint) (*models.MessageAttachment, string, error)
+	PurgeUnclaimedAttachments(olderThan time.Duration) (int, error)
+	MaxAttachmentSize() int64
 }
 
 type messageService struct {
-	repo repository.MessageRepository
+	repo          repository.MessageRepository
+	conversations repository.ConversationRepository
+	users         repository.UserRepository
+	courses       repository.CourseRepository
+	settings      repository.SystemSettingRepository
+	realtime      RealtimeService
+	options       MessagingOptions
+	logger        *logrus.Logger
+}
+
+func NewMessageService(
+	repo repository.MessageRepository,
+	conversations repository.ConversationRepository,
+	users repository.UserRepository,
+	courses repository.CourseRepository,
+	settings repository.SystemSettingRepository,
+	realtime RealtimeService,
+	options MessagingOptions,
+) MessageService {
+	if options.AttachmentDir == "" {
+		options.AttachmentDir = "attachments"
+	}
+	if options.MaxAttachmentSize <= 0 {
+		options.MaxAttachmentSize = 10 << 20
+	}
+	return &messageService{
+		repo:          repo,
+		conversations: conversations,
+		users:         users,
+		courses:       courses,
+		settings:      settings,
+		realtime:      realtime,
+		options:       options,
+		logger:        logger.GetLogger(),
+	}
+}
+
+// Send posts a message. A participant other than the teacher posting to a
+// broadcast replies privately: the reply goes to their direct conversation
+// with the teacher, pointing back at the broadcast message.
+func (s *messageService) Send(senderID uint, req SendMessageRequest) (*models.Message, error) {
+	sender, err := s.users.FindByID(senderID)
+	if err != nil {
+		return nil, err
+	}
+
+	var conversation *models.Conversation
+	var receiverID uint
+	if req.ConversationID == 0 {
+		if req.ReceiverID == 0 {
+			return nil, fmt.Errorf("%w: receiver_id or conversation_id is required", ErrInvalidMessage)
+		}
+		var receiver *models.User
+		conversation, receiver, err = s.directConversation(sender, req.ReceiverID)
+		if err != nil {
+			return nil, err
+		}
+		receiverID = receiver.ID
+		if req.ReplyToID != nil {
+			if err := s.checkReply(*req.ReplyToID, conversation.ID); err != nil {
+				return nil, err
+			}
+		}
+	} else {
+		conversation, err = s.participantConversation(req.ConversationID, senderID)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case conversation.Kind == models.ConversationBroadcast && conversation.CreatedBy != senderID:
+			broadcastID := conversation.ID
+			replyTo, err := s.broadcastReplyTarget(broadcastID, req.ReplyToID)
+			if err != nil {
+				return nil, err
+			}
+			req.ReplyToID = &replyTo
+			var owner *models.User
+			if conversation, owner, err = s.directConversation(sender, conversation.CreatedBy); err != nil {
+				return nil, err
+			}
+			receiverID = owner.ID
+		case req.ReplyToID != nil:
+			if err := s.checkReply(*req.ReplyToID, conversation.ID); err != nil {
+				return nil, err
+			}
+		}
+		if conversation.Kind == models.ConversationDirect && receiverID == 0 {
+			other, err := s.otherParticipant(conversation.ID, senderID)
+			if err != nil {
+				return nil, err
+			}
+			if err := s.checkDirect(sender, other); err != nil {
+				return nil, err
+			}
+			receiverID = other.ID
+		}
+	}
+
+	return s.post(conversation, sender, receiverID, req.Content, req.ReplyToID, req.AttachmentIDs)
+}
+
+// post stores a message in a conversation and pushes it to the other
+// participants
+func (s *messageService) post(conversation *models.Conversation, sender *models.User, receiverID uint, content string, replyToID *uint, attachmentIDs []uint) (*models.Message, error) {
+	content = strings.TrimSpace(content)
+	if content == "" && len(attachmentIDs) == 0 {
+		return nil, fmt.Errorf("%w: content or an attachment is required", ErrInvalidMessage)
+	}
+	if len(content) > maxMessageLength {
+		return nil, fmt.Errorf("%w: content is longer than %d characters", ErrInvalidMessage, maxMessageLength)
+	}
+	if len(attachmentIDs) > maxMessageAttachments {
+		return nil, fmt.Errorf("%w: at most %d attachments per message", ErrInvalidMessage, maxMessageAttachments)
+	}
+
+	now := time.Now().Unix()
+	message := &models.Message{
+		ConversationID: conversation.ID,
+		SenderID:       sender.ID,
+		ReceiverID:     receiverID,
+		Content:        content,
+		ReplyToID:      replyToID,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+	if err := s.repo.Create(message, uniqueIDs(attachmentIDs)); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("%w: attachments must be your own uploads not yet sent", ErrAttachmentNotFound)
+		}
+		return nil, err
+	}
+	message.Sender = *sender
+	if _, err := s.conversations.MarkRead(conversation.ID, sender.ID, message.ID); err != nil {
+		s.logger.WithError(err).WithField("message_id", message.ID).Warn("Failed to advance sender's read marker")
+	}
+
+	s.publishToParticipants(conversation.ID, sender.ID, realtime.TypeMessageCreated, messageEvent(message))
+	return message, nil
 }
 
-func NewMessageService(repo repository.MessageRepository) MessageService {
-	return &messageService{repo: repo}
+func messageEvent(message *models.Message) map[string]interface{} {
+	attachments := make([]map[string]interface{}, 0, len(message.Attachments))
+	for _, a := range message.Attachments {
+		attachments = append(attachments, map[string]interface{}{
+			"id": a.ID, "file_name": a.FileName, "content_type": a.ContentType, "size": a.Size,
+		})
+	}
+	return map[string]interface{}{
+		"id": message.ID, "conversation_id": message.ConversationID, "sender_id": message.SenderID,
+		"receiver_id": message.ReceiverID, "content": message.Content, "reply_to_id": message.ReplyToID,
+		"attachments": attachments, "created_at": message.CreatedAt,
+	}
 }
 
-func (s *messageService) Create(message *models.Message) error {
-	return s.repo.Create(message)
+// Edit replaces the content of the user's own message
+func (s *messageService) Edit(id, userID uint, content string) (*models.Message, error) {
+	message, err := s.visibleMessage(id, userID)
+	if err != nil {
+		return nil, err
+	}
+	if message.SenderID != userID {
+		return nil, fmt.Errorf("%w: only the sender can edit a message", ErrMessagingForbidden)
+	}
+	content = strings.TrimSpace(content)
+	if content == "" && len(message.Attachments) == 0 {
+		return nil, fmt.Errorf("%w: content is required", ErrInvalidMessage)
+	}
+	if len(content) > maxMessageLength {
+		return nil, fmt.Errorf("%w: content is longer than %d characters", ErrInvalidMessage, maxMessageLength)
+	}
+
+	now := time.Now().Unix()
+	if err := s.repo.UpdateContent(id, content, now); err != nil {
+		return nil, err
+	}
+	message.Content = content
+	message.EditedAt = now
+	message.UpdatedAt = now
+
+	s.publishToParticipants(message.ConversationID, userID, realtime.TypeMessageUpdated, map[string]interface{}{
+		"id": message.ID, "conversation_id": message.ConversationID, "content": content, "edited_at": now,
+	})
+	return message, nil
 }
 
-func (s *messageService) GetByID(id uint) (*models.Message, error) {
-	return s.repo.FindByID(id)
+// Delete soft-deletes a message, leaving a placeholder in the thread. The
+// sender, the conversation's owner and admins may delete a message.
+func (s *messageService) Delete(id, userID uint) error {
+	user, err := s.users.FindByID(userID)
+	if err != nil {
+		return err
+	}
+	var message *models.Message
+	if user.Role == models.RoleAdmin {
+		message, err = s.repo.FindByID(id)
+		if err != nil || message.DeletedAt != 0 {
+			return ErrMessageNotFound
+		}
+	} else {
+		if message, err = s.visibleMessage(id, userID); err != nil {
+			return err
+		}
+		if message.SenderID != userID {
+			participant, err := s.conversations.FindParticipant(message.ConversationID, userID)
+			if err != nil || participant.Role != models.ParticipantOwner {
+				return fmt.Errorf("%w: only the sender or the conversation's owner can delete a message", ErrMessagingForbidden)
+			}
+		}
+	}
+
+	attachments, err := s.repo.SoftDelete(id, userID, time.Now().Unix())
+	if err != nil {
+		return err
+	}
+	for i := range attachments {
+		s.removeAttachmentFile(&attachments[i])
+	}
+
+	s.publishToParticipants(message.ConversationID, userID, realtime.TypeMessageDeleted, map[string]interface{}{
+		"id": message.ID, "conversation_id": message.ConversationID,
+	})
+	return nil
 }
 
-func (s *messageService) GetByReceiverID(receiverID uint, page, limit int) ([]models.Message, int64, error) {
-	return s.repo.FindByReceiverID(receiverID, page, limit)
+// MarkAsRead marks a message, and every earlier one in its conversation,
+// read by the user. In direct and group conversations the sender gets a
+// read receipt. Messages already read are left as they are.
+func (s *messageService) MarkAsRead(id, userID uint) error {
+	message, err := s.repo.FindByID(id)
+	if err != nil {
+		return ErrMessageNotFound
+	}
+	conversation, err := s.participantConversation(message.ConversationID, userID)
+	if err != nil {
+		return ErrMessageNotFound
+	}
+	if message.SenderID == userID {
+		return nil
+	}
+	return s.markRead(conversation, userID, message.ID, []uint{message.SenderID})
+}
+
+// MarkConversationRead marks every message in the conversation read
+func (s *messageService) MarkConversationRead(conversationID, userID uint) error {
+	conversation, err := s.participantConversation(conversationID, userID)
+	if err != nil {
+		return err
+	}
+	latest, err := s.repo.FindLatestID(conversationID)
+	if err != nil || latest == 0 {
+		return err
+	}
+	var notify []uint
+	if receiptsFor(conversation.Kind) {
+		participants, err := s.conversations.FindActiveParticipants(conversationID)
+		if err != nil {
+			return err
+		}
+		for _, p := range participants {
+			if p.UserID != userID {
+				notify = append(notify, p.UserID)
+			}
+		}
+	}
+	return s.markRead(conversation, userID, latest, notify)
+}
+
+func (s *messageService) markRead(conversation *models.Conversation, userID, upToID uint, notify []uint) error {
+	moved, err := s.conversations.MarkRead(conversation.ID, userID, upToID)
+	if err != nil || !moved {
+		return err
+	}
+	readAt := time.Now().Unix()
+	if conversation.Kind == models.ConversationDirect {
+		if err := s.repo.MarkDirectRead(conversation.ID, userID, upToID, readAt); err != nil {
+			return err
+		}
+	}
+	if s.realtime != nil && receiptsFor(conversation.Kind) {
+		for _, recipient := range notify {
+			s.realtime.Publish(recipient, realtime.TypeMessageRead, map[string]interface{}{
+				"id": upToID, "conversation_id": conversation.ID, "reader_id": userID, "read_at": readAt,
+			})
+		}
+	}
+	return nil
+}
+
+// receiptsFor reports whether readers of the kind of conversation send read
+// receipts; in course channels and broadcasts they would only be noise
+func receiptsFor(kind string) bool {
+	return kind == models.ConversationDirect || kind == models.ConversationGroup
+}
+
+func (s *messageService) CountUnread(userID uint) (int64, error) {
+	return s.conversations.CountUnread(userID)
 }
 
+// GetConversation returns the direct messages between two users, oldest
+// first
 func (s *messageService) GetConversation(userID1, userID2 uint, page, limit int) ([]models.Message, int64, error) {
 	return s.repo.FindConversation(userID1, userID2, page, limit)
 }
 
-func (s *messageService) Update(message *models.Message) error {
-	return s.repo.Update(message)
+// GetMessages returns a page of a conversation's messages, newest first
+func (s *messageService) GetMessages(conversationID, userID uint, page, limit int) ([]models.Message, int64, error) {
+	if _, err := s.participantConversation(conversationID, userID); err != nil {
+		return nil, 0, err
+	}
+	return s.repo.FindByConversationID(conversationID, page, limit)
 }
 
-func (s *messageService) Delete(id uint) error {
-	return s.repo.Delete(id)
+// visibleMessage returns a message that is not deleted, in a conversation
+// the user takes part in
+func (s *messageService) visibleMessage(id, userID uint) (*models.Message, error) {
+	message, err := s.repo.FindByID(id)
+	if err != nil || message.DeletedAt != 0 {
+		return nil, ErrMessageNotFound
+	}
+	if _, err := s.participantConversation(message.ConversationID, userID); err != nil {
+		return nil, ErrMessageNotFound
+	}
+	return message, nil
 }
 
-func (s *messageService) MarkAsRead(id uint) error {
-	return s.repo.MarkAsRead(id)
+// participantConversation returns a conversation the user currently takes
+// part in
+func (s *messageService) participantConversation(conversationID, userID uint) (*models.Conversation, error) {
+	participant, err := s.conversations.FindParticipant(conversationID, userID)
+	if err != nil || participant.LeftAt != 0 {
+		return nil, ErrConversationNotFound
+	}
+	conversation, err := s.conversations.FindByID(conversationID)
+	if err != nil {
+		return nil, ErrConversationNotFound
+	}
+	return conversation, nil
 }
 
-func (s *messageService) CountUnread(userID uint) (int64, error) {
-	return s.repo.CountUnread(userID)
+func (s *messageService) checkReply(replyToID, conversationID uint) error {
+	replyTo, err := s.repo.FindByID(replyToID)
+	if err != nil || replyTo.ConversationID != conversationID {
+		return fmt.Errorf("%w: reply_to_id is not a message in this conversation", ErrInvalidMessage)
+	}
+	return nil
+}
+
+// broadcastReplyTarget returns the broadcast message a private reply
+// answers: the one given, or else the latest
+func (s *messageService) broadcastReplyTarget(broadcastID uint, replyToID *uint) (uint, error) {
+	if replyToID != nil {
+		return *replyToID, s.checkReply(*replyToID, broadcastID)
+	}
+	latest, err := s.repo.FindLatestID(broadcastID)
+	if err != nil {
+		return 0, err
+	}
+	if latest == 0 {
+		return 0, fmt.Errorf("%w: nothing has been broadcast yet", ErrInvalidMessage)
+	}
+	return latest, nil
+}
+
+// checkDirect applies the moderation rules to a direct message
+func (s *messageService) checkDirect(sender, receiver *models.User) error {
+	if sender.Role == models.RoleStudent && receiver.Role == models.RoleStudent && !s.studentsMayMessage() {
+		return fmt.Errorf("%w: students cannot message other students", ErrMessagingForbidden)
+	}
+	return nil
+}
+
+func (s *messageService) studentsMayMessage() bool {
+	allowed, err := strconv.ParseBool(s.settings.GetValue(SettingStudentDirectMessages, "true"))
+	return err != nil || allowed
+}
+
+func (s *messageService) otherParticipant(conversationID, userID uint) (*models.User, error) {
+	participants, err := s.conversations.FindActiveParticipants(conversationID)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range participants {
+		if p.UserID != userID {
+			user := p.User
+			return &user, nil
+		}
+	}
+	return nil, ErrRecipientNotFound
+}
+
+// publishToParticipants pushes an event to every current participant but
+// the one who caused it. Push is best effort, like the rest of real-time.
+func (s *messageService) publishToParticipants(conversationID, exceptUserID uint, eventType string, data interface{}) {
+	if s.realtime == nil {
+		return
+	}
+	participants, err := s.conversations.FindActiveParticipants(conversationID)
+	if err != nil {
+		s.logger.WithError(err).WithField("conversation_id", conversationID).Warn("Failed to load participants for push")
+		return
+	}
+	for _, p := range participants {
+		if p.UserID != exceptUserID {
+			s.realtime.Publish(p.UserID, eventType, data)
+		}
+	}
+}
+
+func uniqueIDs(ids []uint) []uint {
+	seen := make(map[uint]bool, len(ids))
+	unique := make([]uint, 0, len(ids))
+	for _, id := range ids {
+		if id != 0 && !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	return unique
 }
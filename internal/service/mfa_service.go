@@ -0,0 +1,264 @@
+package service
+
+import (
+	"crypto/rand"
+	"errors"
+	"school-management-system/internal/models"
+	"school-management-system/internal/repository"
+	"school-management-system/pkg/logger"
+	"school-management-system/pkg/utils"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// SettingMFARequiredRoles lists the roles (comma separated, e.g.
+// "admin,teacher") that must use two-factor authentication to sign in.
+const SettingMFARequiredRoles = "auth.mfa_required_roles"
+
+const recoveryCodeCount = 10
+
+var (
+	ErrMFANotEnrolled     = errors.New("two-factor authentication is not enabled")
+	ErrMFAAlreadyEnabled  = errors.New("two-factor authentication is already enabled")
+	ErrMFAInvalidCode     = errors.New("invalid two-factor authentication code")
+	ErrMFARequiredForRole = errors.New("two-factor authentication is required for this role")
+)
+
+// MFAEnrollment is returned when enrollment starts. The secret is shown to
+// the user once, as text and as a QR code of the provisioning URI.
+type MFAEnrollment struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+}
+
+type MFAStatus struct {
+	Enabled                bool  `json:"enabled"`
+	Required               bool  `json:"required"`
+	RecoveryCodesRemaining int64 `json:"recovery_codes_remaining"`
+}
+
+type MFAService interface {
+	Status(user *models.User) (*MFAStatus, error)
+	IsEnabled(userID uint) bool
+	IsRequired(role models.UserRole) bool
+	BeginEnrollment(user *models.User) (*MFAEnrollment, error)
+	ConfirmEnrollment(userID uint, code string) ([]string, error)
+	Verify(userID uint, code string) error
+	RegenerateRecoveryCodes(userID uint, code string) ([]string, error)
+	Disable(user *models.User, password, code string) error
+	Reset(userID uint) error
+}
+
+type mfaService struct {
+	repo        repository.MFARepository
+	settingRepo repository.SystemSettingRepository
+	issuer      string
+	logger      *logrus.Logger
+}
+
+func NewMFAService(repo repository.MFARepository, settingRepo repository.SystemSettingRepository, issuer string) MFAService {
+	return &mfaService{
+		repo:        repo,
+		settingRepo: settingRepo,
+		issuer:      issuer,
+		logger:      logger.GetLogger(),
+	}
+}
+
+func (s *mfaService) Status(user *models.User) (*MFAStatus, error) {
+	status := &MFAStatus{
+		Enabled:  s.IsEnabled(user.ID),
+		Required: s.IsRequired(user.Role),
+	}
+	if status.Enabled {
+		remaining, err := s.repo.CountUnusedRecoveryCodes(user.ID)
+		if err != nil {
+			return nil, err
+		}
+		status.RecoveryCodesRemaining = remaining
+	}
+	return status, nil
+}
+
+func (s *mfaService) IsEnabled(userID uint) bool {
+	mfa, err := s.repo.FindByUserID(userID)
+	return err == nil && mfa.Enabled
+}
+
+func (s *mfaService) IsRequired(role models.UserRole) bool {
+	for _, r := range strings.Split(s.settingRepo.GetValue(SettingMFARequiredRoles, ""), ",") {
+		if strings.TrimSpace(r) == string(role) {
+			return true
+		}
+	}
+	return false
+}
+
+// BeginEnrollment creates a new secret for the user. Starting again before
+// confirming replaces the previous secret.
+func (s *mfaService) BeginEnrollment(user *models.User) (*MFAEnrollment, error) {
+	mfa, err := s.repo.FindByUserID(user.ID)
+	if err == nil && mfa.Enabled {
+		return nil, ErrMFAAlreadyEnabled
+	}
+	if err != nil {
+		mfa = &models.UserMFA{UserID: user.ID}
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+	mfa.Secret = secret
+	mfa.LastUsedStep = 0
+	if err := s.repo.Save(mfa); err != nil {
+		s.logger.WithError(err).WithField("user_id", user.ID).Error("Failed to save MFA enrollment")
+		return nil, errors.New("failed to start two-factor enrollment")
+	}
+
+	return &MFAEnrollment{
+		Secret:          secret,
+		ProvisioningURI: utils.TOTPProvisioningURI(s.issuer, user.Email, secret),
+	}, nil
+}
+
+// ConfirmEnrollment enables MFA once the user proves their authenticator
+// works, and returns a fresh set of recovery codes.
+func (s *mfaService) ConfirmEnrollment(userID uint, code string) ([]string, error) {
+	mfa, err := s.repo.FindByUserID(userID)
+	if err != nil {
+		return nil, ErrMFANotEnrolled
+	}
+	if mfa.Enabled {
+		return nil, ErrMFAAlreadyEnabled
+	}
+	if err := s.checkTOTP(mfa, code); err != nil {
+		return nil, err
+	}
+
+	codes, err := s.newRecoveryCodes(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	mfa.Enabled = true
+	mfa.EnabledAt = time.Now().Unix()
+	if err := s.repo.Save(mfa); err != nil {
+		s.logger.WithError(err).WithField("user_id", userID).Error("Failed to enable MFA")
+		return nil, errors.New("failed to enable two-factor authentication")
+	}
+
+	s.logger.WithField("user_id", userID).Info("Two-factor authentication enabled")
+	return codes, nil
+}
+
+// Verify accepts either a current TOTP code or an unused recovery code.
+func (s *mfaService) Verify(userID uint, code string) error {
+	mfa, err := s.repo.FindByUserID(userID)
+	if err != nil || !mfa.Enabled {
+		return ErrMFANotEnrolled
+	}
+
+	if err := s.checkTOTP(mfa, code); err == nil {
+		return nil
+	}
+
+	if err := s.repo.UseRecoveryCode(userID, hashToken(normalizeRecoveryCode(code)), time.Now().Unix()); err == nil {
+		s.logger.WithField("user_id", userID).Warn("Recovery code used for two-factor authentication")
+		return nil
+	}
+	return ErrMFAInvalidCode
+}
+
+func (s *mfaService) RegenerateRecoveryCodes(userID uint, code string) ([]string, error) {
+	if err := s.Verify(userID, code); err != nil {
+		return nil, err
+	}
+	return s.newRecoveryCodes(userID)
+}
+
+// Disable turns MFA off for the user after checking both factors. Users
+// whose role requires MFA cannot disable it themselves; an admin can Reset
+// it so they enroll again.
+func (s *mfaService) Disable(user *models.User, password, code string) error {
+	if s.IsRequired(user.Role) {
+		return ErrMFARequiredForRole
+	}
+	if !user.CheckPassword(password) {
+		return ErrIncorrectPassword
+	}
+	if err := s.Verify(user.ID, code); err != nil {
+		return err
+	}
+	if err := s.repo.Delete(user.ID); err != nil {
+		return errors.New("failed to disable two-factor authentication")
+	}
+	s.logger.WithField("user_id", user.ID).Info("Two-factor authentication disabled")
+	return nil
+}
+
+// Reset removes a user's enrollment, e.g. after they lost their device and
+// recovery codes. Their next login starts enrollment again if required.
+func (s *mfaService) Reset(userID uint) error {
+	if err := s.repo.Delete(userID); err != nil {
+		return errors.New("failed to reset two-factor authentication")
+	}
+	s.logger.WithField("user_id", userID).Warn("Two-factor authentication reset by admin")
+	return nil
+}
+
+func (s *mfaService) checkTOTP(mfa *models.UserMFA, code string) error {
+	step, ok := utils.ValidateTOTP(mfa.Secret, code, time.Now(), 1)
+	if !ok {
+		return ErrMFAInvalidCode
+	}
+	if err := s.repo.MarkStepUsed(mfa.UserID, step); err != nil {
+		// Same code already used
+		return ErrMFAInvalidCode
+	}
+	mfa.LastUsedStep = step
+	return nil
+}
+
+// newRecoveryCodes replaces the user's recovery codes and returns the new
+// codes in plain text. They cannot be shown again.
+func (s *mfaService) newRecoveryCodes(userID uint) ([]string, error) {
+	// 32 symbols without look-alikes (l, o, 0, 1), so a byte masked to five
+	// bits picks one uniformly
+	const alphabet = "abcdefghijkmnpqrstuvwxyz23456789"
+
+	now := time.Now().Unix()
+	codes := make([]string, recoveryCodeCount)
+	records := make([]models.MFARecoveryCode, recoveryCodeCount)
+	for i := range codes {
+		raw := make([]byte, 10)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, err
+		}
+		var b strings.Builder
+		for j, c := range raw {
+			if j == 5 {
+				b.WriteByte('-')
+			}
+			b.WriteByte(alphabet[c&31])
+		}
+		codes[i] = b.String()
+		records[i] = models.MFARecoveryCode{
+			UserID:    userID,
+			CodeHash:  hashToken(normalizeRecoveryCode(codes[i])),
+			CreatedAt: now,
+		}
+	}
+
+	if err := s.repo.ReplaceRecoveryCodes(userID, records); err != nil {
+		s.logger.WithError(err).WithField("user_id", userID).Error("Failed to save recovery codes")
+		return nil, errors.New("failed to generate recovery codes")
+	}
+	return codes, nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+}
@@ -0,0 +1,479 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"school-management-system/internal/models"
+	"school-management-system/internal/realtime"
+	"school-management-system/internal/repository"
+	"school-management-system/internal/sms"
+	"school-management-system/pkg/logger"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	ErrUnknownEvent                  = errors.New("unknown notification event")
+	ErrInvalidNotificationPreference = errors.New("invalid notification preferences")
+)
+
+// DispatcherOptions configures notification delivery. Zero values take the
+// defaults.
+type DispatcherOptions struct {
+	SMS            sms.Provider  // default logs messages instead of sending them
+	WebhookTimeout time.Duration // default 10s
+	// AllowPrivateWebhooks permits webhooks to loopback and private
+	// addresses. Off by default so users cannot make the server call
+	// internal services.
+	AllowPrivateWebhooks bool
+	MaxAttempts          int           // delivery attempts before a delivery fails; default 5
+	BaseBackoff          time.Duration // wait after the first failed attempt, doubled after each; default 1m
+	MaxBackoff           time.Duration // longest wait between attempts; default 6h
+	PollInterval         time.Duration // how often the worker looks for due deliveries; default 30s
+	LockTTL              time.Duration // how long a worker may hold a delivery; default 5m
+	BatchSize            int           // deliveries claimed per pass; default 50
+	// Realtime pushes in-app notifications to connected clients; optional
+	Realtime RealtimeService
+}
+
+// NotificationDispatcher fans events out to the channels each user wants
+// them on. In-app notifications are stored straight away; email, SMS and
+// webhook deliveries are queued and sent by a background worker, which
+// holds them through the user's quiet hours and retries failures.
+type NotificationDispatcher interface {
+	Dispatch(event *NotificationEvent) error
+	NotifyStudent(student *models.Student, forStudent, forGuardians *NotificationEvent) error
+	Forward(notification *models.Notification) error
+	ProcessDue(now time.Time) (int, error)
+	Start()
+	Stop()
+	Events() []NotificationEventDefinition
+	GetPreferences(userID uint) (*NotificationPreferences, error)
+	UpdatePreferences(userID uint, update *NotificationPreferencesUpdate) (*NotificationPreferences, error)
+	GetDeliveries(status string, page, limit int) ([]models.NotificationDelivery, int64, error)
+}
+
+type notificationDispatcher struct {
+	notifications repository.NotificationRepository
+	preferences   repository.NotificationPreferenceRepository
+	deliveries    repository.NotificationDeliveryRepository
+	users         repository.UserRepository
+	guardians     repository.GuardianRepository
+	email         *EmailService
+	webhooks      *webhookSender
+	options       DispatcherOptions
+	logger        *logrus.Logger
+
+	wake    chan struct{}
+	ctx     context.Context
+	cancel  context.CancelFunc
+	mu      sync.Mutex
+	stopped chan struct{}
+}
+
+// deliveryPayload is what a queued delivery needs to be sent
+type deliveryPayload struct {
+	Title         string                 `json:"title"`
+	Message       string                 `json:"message"`
+	EmailTemplate string                 `json:"email_template,omitempty"`
+	EmailData     map[string]interface{} `json:"email_data,omitempty"`
+	Data          map[string]interface{} `json:"data,omitempty"`
+}
+
+func NewNotificationDispatcher(
+	notifications repository.NotificationRepository,
+	preferences repository.NotificationPreferenceRepository,
+	deliveries repository.NotificationDeliveryRepository,
+	users repository.UserRepository,
+	guardians repository.GuardianRepository,
+	email *EmailService,
+	options DispatcherOptions,
+) NotificationDispatcher {
+	if options.SMS == nil {
+		options.SMS = sms.LogProvider{}
+	}
+	if options.WebhookTimeout <= 0 {
+		options.WebhookTimeout = 10 * time.Second
+	}
+	if options.MaxAttempts <= 0 {
+		options.MaxAttempts = 5
+	}
+	if options.BaseBackoff <= 0 {
+		options.BaseBackoff = time.Minute
+	}
+	if options.MaxBackoff <= 0 {
+		options.MaxBackoff = 6 * time.Hour
+	}
+	if options.PollInterval <= 0 {
+		options.PollInterval = 30 * time.Second
+	}
+	if options.LockTTL <= 0 {
+		options.LockTTL = 5 * time.Minute
+	}
+	if options.BatchSize <= 0 {
+		options.BatchSize = 50
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &notificationDispatcher{
+		notifications: notifications,
+		preferences:   preferences,
+		deliveries:    deliveries,
+		users:         users,
+		guardians:     guardians,
+		email:         email,
+		webhooks:      newWebhookSender(options.WebhookTimeout, options.AllowPrivateWebhooks),
+		options:       options,
+		logger:        logger.GetLogger(),
+		wake:          make(chan struct{}, 1),
+		ctx:           ctx,
+		cancel:        cancel,
+	}
+}
+
+func findEventDefinition(event string) *NotificationEventDefinition {
+	for i := range notificationEventDefinitions {
+		if notificationEventDefinitions[i].Event == event {
+			return &notificationEventDefinitions[i]
+		}
+	}
+	return nil
+}
+
+func (d *notificationDispatcher) Events() []NotificationEventDefinition {
+	return notificationEventDefinitions
+}
+
+// NotifyStudent sends one event to a student and another to their
+// guardians who receive notifications
+func (d *notificationDispatcher) NotifyStudent(student *models.Student, forStudent, forGuardians *NotificationEvent) error {
+	forStudent.UserIDs = []uint{student.UserID}
+	if err := d.Dispatch(forStudent); err != nil {
+		return err
+	}
+	if forGuardians == nil {
+		return nil
+	}
+	guardians, err := d.guardians.FindNotifiableByStudentID(student.ID)
+	if err != nil {
+		return err
+	}
+	forGuardians.UserIDs = nil
+	for _, g := range guardians {
+		forGuardians.UserIDs = append(forGuardians.UserIDs, g.UserID)
+	}
+	if len(forGuardians.UserIDs) == 0 {
+		return nil
+	}
+	return d.Dispatch(forGuardians)
+}
+
+// Dispatch sends an event to each of its users on the channels they have
+// chosen for it, or the event's default channels
+func (d *notificationDispatcher) Dispatch(event *NotificationEvent) error {
+	definition := findEventDefinition(event.Type)
+	if definition == nil {
+		return fmt.Errorf("%w: %s", ErrUnknownEvent, event.Type)
+	}
+	if len(event.UserIDs) == 0 {
+		return nil
+	}
+	overrides, err := d.preferences.FindEventPreferences(event.UserIDs, event.Type)
+	if err != nil {
+		return err
+	}
+	chosen := map[uint]map[string]bool{}
+	for _, p := range overrides {
+		if chosen[p.UserID] == nil {
+			chosen[p.UserID] = map[string]bool{}
+		}
+		chosen[p.UserID][p.Channel] = p.Enabled
+	}
+	payload, err := json.Marshal(deliveryPayload{
+		Title:         event.Title,
+		Message:       event.Message,
+		EmailTemplate: event.EmailTemplate,
+		EmailData:     event.EmailData,
+		Data:          event.Data,
+	})
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	queued := false
+	seen := map[uint]bool{}
+	for _, userID := range event.UserIDs {
+		if seen[userID] {
+			continue
+		}
+		seen[userID] = true
+		for _, channel := range channelsFor(definition, chosen[userID]) {
+			if channel == models.ChannelInApp {
+				notification := &models.Notification{
+					UserID:  userID,
+					Title:   event.Title,
+					Message: event.Message,
+					Type:    models.ChannelInApp,
+					Subject: definition.Category,
+					Event:   event.Type,
+					SentAt:  now.Unix(),
+				}
+				if err := d.notifications.Create(notification); err != nil {
+					return err
+				}
+				if d.options.Realtime != nil {
+					d.options.Realtime.Publish(userID, realtime.TypeNotificationCreated, notification)
+				}
+				continue
+			}
+			ok, err := d.queue(userID, 0, event.Type, channel, string(payload), now)
+			if err != nil {
+				return err
+			}
+			queued = queued || ok
+		}
+	}
+	if queued {
+		d.wakeWorker()
+	}
+	return nil
+}
+
+// channelsFor applies a user's choices to an event's default channels
+func channelsFor(definition *NotificationEventDefinition, choices map[string]bool) []string {
+	enabled := map[string]bool{}
+	for _, channel := range definition.DefaultChannels {
+		enabled[channel] = true
+	}
+	for channel, on := range choices {
+		enabled[channel] = on
+	}
+	var channels []string
+	for _, channel := range NotificationChannels {
+		if enabled[channel] {
+			channels = append(channels, channel)
+		}
+	}
+	return channels
+}
+
+// queue stores a delivery on an external channel, held until the user's
+// quiet hours end for email and SMS. Webhooks are only queued for users
+// who have set one up.
+func (d *notificationDispatcher) queue(userID, notificationID uint, event, channel, payload string, now time.Time) (bool, error) {
+	settings, err := d.preferences.FindSettings(userID)
+	if err != nil {
+		return false, err
+	}
+	if channel == models.ChannelWebhook && settings.WebhookURL == "" {
+		return false, nil
+	}
+	notBefore := now
+	if channel == models.ChannelEmail || channel == models.ChannelSMS {
+		if until, quiet := settings.QuietUntil(now); quiet {
+			notBefore = until
+		}
+	}
+	delivery := &models.NotificationDelivery{
+		NotificationID: notificationID,
+		UserID:         userID,
+		Event:          event,
+		Channel:        channel,
+		Status:         models.DeliveryStatusPending,
+		NotBefore:      notBefore.Unix(),
+		Payload:        payload,
+	}
+	return true, d.deliveries.Create(delivery)
+}
+
+// Forward sends a stored notification on the channel named by its type,
+// unless the user turned that channel off for general messages. In-app
+// notifications need nothing more.
+func (d *notificationDispatcher) Forward(notification *models.Notification) error {
+	channel := notification.Type
+	if channel != models.ChannelEmail && channel != models.ChannelSMS && channel != models.ChannelWebhook {
+		return nil
+	}
+	event := notification.Event
+	if findEventDefinition(event) == nil {
+		event = EventGeneral
+	}
+	overrides, err := d.preferences.FindEventPreferences([]uint{notification.UserID}, event)
+	if err != nil {
+		return err
+	}
+	for _, p := range overrides {
+		if p.Channel == channel && !p.Enabled {
+			return nil
+		}
+	}
+	payload, err := json.Marshal(deliveryPayload{Title: notification.Title, Message: notification.Message})
+	if err != nil {
+		return err
+	}
+	queued, err := d.queue(notification.UserID, notification.ID, event, channel, string(payload), time.Now())
+	if queued && err == nil {
+		d.wakeWorker()
+	}
+	return err
+}
+
+func (d *notificationDispatcher) wakeWorker() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Start runs the delivery worker in the background until Stop
+func (d *notificationDispatcher) Start() {
+	d.mu.Lock()
+	if d.stopped != nil {
+		d.mu.Unlock()
+		return
+	}
+	d.stopped = make(chan struct{})
+	d.mu.Unlock()
+
+	go func() {
+		defer close(d.stopped)
+		ticker := time.NewTicker(d.options.PollInterval)
+		defer ticker.Stop()
+		for {
+			for {
+				n, err := d.ProcessDue(time.Now())
+				if err != nil {
+					d.logger.WithError(err).Warn("Notification delivery pass failed")
+				}
+				// A full batch means more may be waiting
+				if err != nil || n < d.options.BatchSize || d.ctx.Err() != nil {
+					break
+				}
+			}
+			select {
+			case <-d.ctx.Done():
+				return
+			case <-ticker.C:
+			case <-d.wake:
+			}
+		}
+	}()
+}
+
+// Stop ends the worker after the delivery it is sending, if any
+func (d *notificationDispatcher) Stop() {
+	d.cancel()
+	d.mu.Lock()
+	stopped := d.stopped
+	d.mu.Unlock()
+	if stopped != nil {
+		<-stopped
+	}
+}
+
+// ProcessDue sends the deliveries that are due, returning how many it
+// handled
+func (d *notificationDispatcher) ProcessDue(now time.Time) (int, error) {
+	deliveries, err := d.deliveries.ClaimDue(now.Unix(), now.Add(d.options.LockTTL).Unix(), d.options.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+	for i := range deliveries {
+		if err := d.deliver(&deliveries[i], now); err != nil {
+			return i, err
+		}
+	}
+	return len(deliveries), nil
+}
+
+// errSkipDelivery marks a delivery that cannot be made, such as an SMS to
+// a user without a phone number
+var errSkipDelivery = errors.New("delivery skipped")
+
+// deliver makes one attempt at a delivery and records the outcome
+func (d *notificationDispatcher) deliver(delivery *models.NotificationDelivery, now time.Time) error {
+	log := d.logger.WithFields(logrus.Fields{"delivery_id": delivery.ID, "channel": delivery.Channel})
+	delivery.UpdatedAt = now.Unix()
+
+	user, err := d.users.FindByID(delivery.UserID)
+	if err != nil || !user.IsActive {
+		delivery.Status = models.DeliveryStatusSkipped
+		delivery.LastError = "user not found or inactive"
+		return d.deliveries.Finish(delivery)
+	}
+	settings, err := d.preferences.FindSettings(delivery.UserID)
+	if err != nil {
+		return err
+	}
+	// Quiet hours may have been set since the delivery was queued
+	if delivery.Channel == models.ChannelEmail || delivery.Channel == models.ChannelSMS {
+		if until, quiet := settings.QuietUntil(now); quiet {
+			delivery.NotBefore = until.Unix()
+			return d.deliveries.Finish(delivery)
+		}
+	}
+
+	var payload deliveryPayload
+	if err := json.Unmarshal([]byte(delivery.Payload), &payload); err != nil {
+		delivery.Status = models.DeliveryStatusFailed
+		delivery.LastError = "unreadable payload: " + err.Error()
+		return d.deliveries.Finish(delivery)
+	}
+
+	delivery.Attempts++
+	err = d.send(delivery, &payload, user, settings, now)
+	switch {
+	case err == nil:
+		delivery.Status = models.DeliveryStatusSent
+		delivery.SentAt = now.Unix()
+		delivery.LastError = ""
+	case errors.Is(err, errSkipDelivery):
+		delivery.Status = models.DeliveryStatusSkipped
+		delivery.LastError = err.Error()
+	case delivery.Attempts >= d.options.MaxAttempts:
+		delivery.Status = models.DeliveryStatusFailed
+		delivery.LastError = err.Error()
+		log.WithError(err).Error("Notification delivery failed")
+	default:
+		delivery.LastError = err.Error()
+		delivery.NotBefore = now.Add(backoff(d.options.BaseBackoff, d.options.MaxBackoff, delivery.Attempts)).Unix()
+		log.WithError(err).Warn("Notification delivery failed, will retry")
+	}
+	return d.deliveries.Finish(delivery)
+}
+
+func (d *notificationDispatcher) send(delivery *models.NotificationDelivery, payload *deliveryPayload, user *models.User, settings *models.NotificationSettings, now time.Time) error {
+	switch delivery.Channel {
+	case models.ChannelEmail:
+		if d.email == nil {
+			return fmt.Errorf("%w: email is not configured", errSkipDelivery)
+		}
+		template, data := payload.EmailTemplate, payload.EmailData
+		if template == "" {
+			template = EmailNotification
+			data = map[string]interface{}{"Title": payload.Title, "Message": payload.Message}
+		}
+		return d.email.SendTemplate(template, []EmailRecipient{UserRecipient(user)}, data)
+	case models.ChannelSMS:
+		if user.Phone == "" {
+			return fmt.Errorf("%w: user has no phone number", errSkipDelivery)
+		}
+		ctx, cancel := context.WithTimeout(d.ctx, d.options.WebhookTimeout)
+		defer cancel()
+		return d.options.SMS.Send(ctx, user.Phone, payload.Title+": "+payload.Message)
+	case models.ChannelWebhook:
+		if settings.WebhookURL == "" {
+			return fmt.Errorf("%w: user has no webhook", errSkipDelivery)
+		}
+		return d.webhooks.send(d.ctx, settings, delivery, payload, now)
+	}
+	return fmt.Errorf("%w: unknown channel %q", errSkipDelivery, delivery.Channel)
+}
+
+func (d *notificationDispatcher) GetDeliveries(status string, page, limit int) ([]models.NotificationDelivery, int64, error) {
+	return d.deliveries.FindAll(status, page, limit)
+}
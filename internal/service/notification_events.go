@@ -0,0 +1,203 @@
+package service
+
+import (
+	"fmt"
+	"school-management-system/internal/models"
+	"school-management-system/pkg/database"
+	"school-management-system/pkg/logger"
+	"strings"
+	"time"
+)
+
+// Events the notification dispatcher fans out
+const (
+	EventGradePosted     = "grade_posted"
+	EventAssignmentDue   = "assignment_due"
+	EventAbsenceRecorded = "absence_recorded"
+	EventPaymentDue      = "payment_due"
+	EventPaymentOverdue  = "payment_overdue"
+	EventEnrollment      = "enrollment_changed"
+	EventGeneral         = "general"
+)
+
+// NotificationChannels lists every channel, in the order preferences are
+// shown
+var NotificationChannels = []string{models.ChannelInApp, models.ChannelEmail, models.ChannelSMS, models.ChannelWebhook}
+
+// NotificationEventDefinition describes an event users can set channel
+// preferences for. Category is stored as the subject of its in-app
+// notifications.
+type NotificationEventDefinition struct {
+	Event           string   `json:"event"`
+	Description     string   `json:"description"`
+	Category        string   `json:"-"`
+	DefaultChannels []string `json:"default_channels"`
+}
+
+var notificationEventDefinitions = []NotificationEventDefinition{
+	{EventGradePosted, "A grade is posted for you or your child", "grades", []string{models.ChannelInApp, models.ChannelEmail}},
+	{EventAssignmentDue, "An assignment you have not submitted is due within a day", "assignments", []string{models.ChannelInApp, models.ChannelEmail}},
+	{EventAbsenceRecorded, "You or your child are marked absent", "attendance", []string{models.ChannelInApp, models.ChannelEmail}},
+	{EventPaymentDue, "A payment is due soon, and weekly while it is overdue", "payment", []string{models.ChannelInApp, models.ChannelEmail}},
+	{EventPaymentOverdue, "A payment becomes overdue", "payment", []string{models.ChannelInApp, models.ChannelEmail, models.ChannelSMS}},
+	{EventEnrollment, "Your enrollment in a course is approved, rejected, started or ended", "enrollment", []string{models.ChannelInApp, models.ChannelEmail}},
+	{EventGeneral, "Messages sent to you by school staff", "general", []string{models.ChannelInApp, models.ChannelEmail}},
+}
+
+// NotificationEvent is something that happened which users should hear
+// about. Title and Message are used for in-app, SMS and webhook
+// notifications; email uses EmailTemplate with EmailData, or the generic
+// notification template with the title and message when none is set.
+type NotificationEvent struct {
+	Type          string
+	UserIDs       []uint
+	Title         string
+	Message       string
+	EmailTemplate string
+	EmailData     map[string]interface{}
+	Data          map[string]interface{} // extra fields for webhook receivers
+}
+
+// studentAndCourse loads a student with their user, and the course name
+func studentAndCourse(studentID, courseID uint) (*models.Student, string, error) {
+	db := database.DB
+	var student models.Student
+	if err := db.Preload("User").First(&student, studentID).Error; err != nil {
+		return nil, "", err
+	}
+	var course models.Course
+	if err := db.Select("id", "name").First(&course, courseID).Error; err != nil {
+		return nil, "", err
+	}
+	return &student, course.Name, nil
+}
+
+// notifyGradePosted tells a student and their guardians about a new grade
+func notifyGradePosted(notifier NotificationDispatcher, grade *models.Grade) {
+	if notifier == nil {
+		return
+	}
+	student, courseName, err := studentAndCourse(grade.StudentID, grade.CourseID)
+	if err != nil {
+		logger.GetLogger().WithError(err).WithField("grade_id", grade.ID).Warn("Failed to load grade for notification")
+		return
+	}
+	studentName := student.User.FirstName + " " + student.User.LastName
+	gradeText := fmt.Sprintf("%s (%.1f%%)", grade.Grade, grade.Score)
+	data := map[string]interface{}{"grade_id": grade.ID, "student_id": grade.StudentID, "course_id": grade.CourseID,
+		"course_name": courseName, "grade": grade.Grade, "score": grade.Score}
+
+	err = notifier.NotifyStudent(student, &NotificationEvent{
+		Type:          EventGradePosted,
+		Title:         "New grade posted",
+		Message:       fmt.Sprintf("Your grade for %s is %s.", courseName, gradeText),
+		EmailTemplate: EmailGradePosted,
+		EmailData:     map[string]interface{}{"Name": studentName, "CourseName": courseName, "Grade": gradeText},
+		Data:          data,
+	}, &NotificationEvent{
+		Type:          EventGradePosted,
+		Title:         "New grade for " + studentName,
+		Message:       fmt.Sprintf("%s's grade for %s is %s.", studentName, courseName, gradeText),
+		EmailTemplate: EmailGuardianGradePosted,
+		EmailData:     map[string]interface{}{"StudentName": studentName, "CourseName": courseName, "Grade": gradeText},
+		Data:          data,
+	})
+	if err != nil {
+		logger.GetLogger().WithError(err).WithField("grade_id", grade.ID).Warn("Failed to dispatch grade notification")
+	}
+}
+
+// notifyAbsence tells a student and their guardians the student was
+// marked absent
+func notifyAbsence(notifier NotificationDispatcher, attendance *models.Attendance) {
+	if notifier == nil || attendance.Status != "absent" {
+		return
+	}
+	student, courseName, err := studentAndCourse(attendance.StudentID, attendance.CourseID)
+	if err != nil {
+		logger.GetLogger().WithError(err).WithField("attendance_id", attendance.ID).Warn("Failed to load attendance for notification")
+		return
+	}
+	studentName := student.User.FirstName + " " + student.User.LastName
+	date := attendance.Date.Format("Monday, January 2")
+	data := map[string]interface{}{"attendance_id": attendance.ID, "student_id": attendance.StudentID,
+		"course_id": attendance.CourseID, "course_name": courseName, "date": attendance.Date.Format("2006-01-02")}
+
+	err = notifier.NotifyStudent(student, &NotificationEvent{
+		Type:    EventAbsenceRecorded,
+		Title:   "Marked absent",
+		Message: fmt.Sprintf("You were marked absent from %s on %s.", courseName, date),
+		Data:    data,
+	}, &NotificationEvent{
+		Type:    EventAbsenceRecorded,
+		Title:   studentName + " was marked absent",
+		Message: fmt.Sprintf("%s was marked absent from %s on %s.", studentName, courseName, date),
+		Data:    data,
+	})
+	if err != nil {
+		logger.GetLogger().WithError(err).WithField("attendance_id", attendance.ID).Warn("Failed to dispatch absence notification")
+	}
+}
+
+// enrollmentStatusMessages say what each status change means for the student
+var enrollmentStatusMessages = map[string]string{
+	models.EnrollmentRequested:  "Your request to enroll in %s has been received.",
+	models.EnrollmentApproved:   "Your enrollment in %s has been approved.",
+	models.EnrollmentRejected:   "Your enrollment in %s has been rejected.",
+	models.EnrollmentWaitlisted: "%s is full; you have been added to its waitlist.",
+	models.EnrollmentActive:     "You are now enrolled in %s.",
+	models.EnrollmentDropped:    "You have been dropped from %s.",
+	models.EnrollmentWithdrawn:  "You have withdrawn from %s.",
+	models.EnrollmentCompleted:  "You have completed %s.",
+}
+
+// notifyEnrollmentChanged tells a student their enrollment changed status
+func notifyEnrollmentChanged(notifier NotificationDispatcher, enrollment *models.Enrollment, transition *models.EnrollmentTransition) {
+	if notifier == nil {
+		return
+	}
+	student, courseName, err := studentAndCourse(enrollment.StudentID, enrollment.CourseID)
+	if err != nil {
+		logger.GetLogger().WithError(err).WithField("enrollment_id", enrollment.ID).Warn("Failed to load enrollment for notification")
+		return
+	}
+	message := fmt.Sprintf(enrollmentStatusMessages[transition.ToStatus], courseName)
+	if transition.Reason != "" {
+		message += " Reason: " + strings.TrimSuffix(transition.Reason, ".") + "."
+	}
+
+	err = notifier.NotifyStudent(student, &NotificationEvent{
+		Type:    EventEnrollment,
+		Title:   "Enrollment " + transition.ToStatus,
+		Message: message,
+		Data: map[string]interface{}{"enrollment_id": enrollment.ID, "course_id": enrollment.CourseID, "course_name": courseName,
+			"from_status": transition.FromStatus, "status": transition.ToStatus},
+	}, nil)
+	if err != nil {
+		logger.GetLogger().WithError(err).WithField("enrollment_id", enrollment.ID).Warn("Failed to dispatch enrollment notification")
+	}
+}
+
+// paymentEvent builds the event for a payment reminder or overdue notice
+func paymentEvent(event string, payment *models.Payment, user *models.User) *NotificationEvent {
+	dueDate := time.Unix(payment.DueDate, 0).Format("January 2, 2006")
+	title := "Payment due"
+	amount := fmt.Sprintf("$%.2f", payment.Amount)
+	if payment.Description != "" {
+		amount += " for " + payment.Description
+	}
+	message := fmt.Sprintf("Your payment of %s is due on %s.", amount, dueDate)
+	if event == EventPaymentOverdue || payment.Status == models.PaymentStatusOverdue {
+		title = "Payment overdue"
+		message = fmt.Sprintf("Your payment of %s was due on %s and is now overdue.", amount, dueDate)
+	}
+	return &NotificationEvent{
+		Type:          event,
+		UserIDs:       []uint{user.ID},
+		Title:         title,
+		Message:       message,
+		EmailTemplate: EmailPaymentReminder,
+		EmailData:     map[string]interface{}{"Name": user.FirstName + " " + user.LastName, "Amount": payment.Amount, "DueDate": dueDate},
+		Data:          map[string]interface{}{"payment_id": payment.ID, "amount": payment.Amount, "due_date": payment.DueDate, "status": payment.Status},
+	}
+}
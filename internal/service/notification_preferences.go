@@ -0,0 +1,177 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"school-management-system/internal/models"
+	"strings"
+	"time"
+)
+
+// NotificationPreferences is a user's notification settings with the
+// channels each event is sent on
+type NotificationPreferences struct {
+	Settings *models.NotificationSettings `json:"settings"`
+	Events   []EventChannels              `json:"events"`
+}
+
+// EventChannels says which channels an event is sent to a user on
+type EventChannels struct {
+	Event       string          `json:"event"`
+	Description string          `json:"description"`
+	Channels    map[string]bool `json:"channels"`
+}
+
+// NotificationPreferencesUpdate changes a user's notification settings.
+// Nil fields are left alone; an empty string clears the setting. Events
+// maps event to channel to whether it is wanted.
+type NotificationPreferencesUpdate struct {
+	QuietHoursStart *string                    `json:"quiet_hours_start"`
+	QuietHoursEnd   *string                    `json:"quiet_hours_end"`
+	Timezone        *string                    `json:"timezone"`
+	WebhookURL      *string                    `json:"webhook_url"`
+	Events          map[string]map[string]bool `json:"events"`
+}
+
+func (d *notificationDispatcher) GetPreferences(userID uint) (*NotificationPreferences, error) {
+	settings, err := d.preferences.FindSettings(userID)
+	if err != nil {
+		return nil, err
+	}
+	stored, err := d.preferences.FindPreferences(userID)
+	if err != nil {
+		return nil, err
+	}
+	chosen := map[string]map[string]bool{}
+	for _, p := range stored {
+		if chosen[p.Event] == nil {
+			chosen[p.Event] = map[string]bool{}
+		}
+		chosen[p.Event][p.Channel] = p.Enabled
+	}
+
+	prefs := &NotificationPreferences{Settings: settings}
+	for i := range notificationEventDefinitions {
+		definition := &notificationEventDefinitions[i]
+		channels := map[string]bool{}
+		for _, channel := range NotificationChannels {
+			channels[channel] = false
+		}
+		for _, channel := range channelsFor(definition, chosen[definition.Event]) {
+			channels[channel] = true
+		}
+		prefs.Events = append(prefs.Events, EventChannels{
+			Event:       definition.Event,
+			Description: definition.Description,
+			Channels:    channels,
+		})
+	}
+	return prefs, nil
+}
+
+// UpdatePreferences validates and saves a change to a user's settings. A
+// new webhook secret is generated whenever the webhook URL changes.
+func (d *notificationDispatcher) UpdatePreferences(userID uint, update *NotificationPreferencesUpdate) (*NotificationPreferences, error) {
+	settings, err := d.preferences.FindSettings(userID)
+	if err != nil {
+		return nil, err
+	}
+	if update.QuietHoursStart != nil {
+		settings.QuietHoursStart = strings.TrimSpace(*update.QuietHoursStart)
+	}
+	if update.QuietHoursEnd != nil {
+		settings.QuietHoursEnd = strings.TrimSpace(*update.QuietHoursEnd)
+	}
+	if update.Timezone != nil {
+		settings.Timezone = strings.TrimSpace(*update.Timezone)
+	}
+	if err := validateQuietHours(settings); err != nil {
+		return nil, err
+	}
+	if update.WebhookURL != nil {
+		webhook := strings.TrimSpace(*update.WebhookURL)
+		if webhook != "" {
+			if err := validateWebhookURL(webhook); err != nil {
+				return nil, err
+			}
+		}
+		if webhook != settings.WebhookURL {
+			settings.WebhookURL = webhook
+			settings.WebhookSecret = ""
+			if webhook != "" {
+				raw := make([]byte, 24)
+				if _, err := rand.Read(raw); err != nil {
+					return nil, err
+				}
+				settings.WebhookSecret = hex.EncodeToString(raw)
+			}
+		}
+	}
+
+	var changes []models.NotificationPreference
+	for event, channels := range update.Events {
+		if findEventDefinition(event) == nil {
+			return nil, fmt.Errorf("%w: unknown event %q", ErrInvalidNotificationPreference, event)
+		}
+		for channel, enabled := range channels {
+			if !isNotificationChannel(channel) {
+				return nil, fmt.Errorf("%w: unknown channel %q", ErrInvalidNotificationPreference, channel)
+			}
+			changes = append(changes, models.NotificationPreference{UserID: userID, Event: event, Channel: channel, Enabled: enabled})
+		}
+	}
+
+	settings.UpdatedAt = time.Now().Unix()
+	if err := d.preferences.SaveSettings(settings); err != nil {
+		return nil, err
+	}
+	for i := range changes {
+		changes[i].UpdatedAt = settings.UpdatedAt
+		if err := d.preferences.SetPreference(&changes[i]); err != nil {
+			return nil, err
+		}
+	}
+	return d.GetPreferences(userID)
+}
+
+func isNotificationChannel(channel string) bool {
+	for _, c := range NotificationChannels {
+		if c == channel {
+			return true
+		}
+	}
+	return false
+}
+
+func validateQuietHours(settings *models.NotificationSettings) error {
+	if (settings.QuietHoursStart == "") != (settings.QuietHoursEnd == "") {
+		return fmt.Errorf("%w: quiet_hours_start and quiet_hours_end must be set together", ErrInvalidNotificationPreference)
+	}
+	for _, clock := range []string{settings.QuietHoursStart, settings.QuietHoursEnd} {
+		if clock == "" {
+			continue
+		}
+		if _, err := models.ParseClock(clock); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidNotificationPreference, err)
+		}
+	}
+	if settings.Timezone != "" {
+		if _, err := time.LoadLocation(settings.Timezone); err != nil {
+			return fmt.Errorf("%w: unknown timezone %q", ErrInvalidNotificationPreference, settings.Timezone)
+		}
+	}
+	return nil
+}
+
+func validateWebhookURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%w: webhook_url must be an http or https URL", ErrInvalidNotificationPreference)
+	}
+	if u.User != nil {
+		return fmt.Errorf("%w: webhook_url must not contain credentials", ErrInvalidNotificationPreference)
+	}
+	return nil
+}
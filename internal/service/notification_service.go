@@ -1,10 +1,16 @@
 package service
 
 import (
+	"errors"
 	"school-management-system/internal/models"
+	"school-management-system/internal/realtime"
 	"school-management-system/internal/repository"
+
+	"gorm.io/gorm"
 )
 
+var ErrNotificationNotFound = errors.New("notification not found")
+
 type NotificationService interface {
 	Create(notification *models.Notification) error
 	GetByID(id uint) (*models.Notification, error)
@@ -12,20 +18,36 @@ type NotificationService interface {
 	GetUnread(userID uint) ([]models.Notification, error)
 	Update(notification *models.Notification) error
 	Delete(id uint) error
-	MarkAsRead(id uint) error
+	MarkAsRead(id, userID uint) error
 	MarkAllAsRead(userID uint) error
 }
 
 type notificationService struct {
-	repo repository.NotificationRepository
+	repo       repository.NotificationRepository
+	dispatcher NotificationDispatcher
+	realtime   RealtimeService
 }
 
-func NewNotificationService(repo repository.NotificationRepository) NotificationService {
-	return &notificationService{repo: repo}
+func NewNotificationService(repo repository.NotificationRepository, dispatcher NotificationDispatcher, realtime RealtimeService) NotificationService {
+	return &notificationService{repo: repo, dispatcher: dispatcher, realtime: realtime}
 }
 
+// Create stores a notification and, when its type names an external
+// channel, sends it there too
 func (s *notificationService) Create(notification *models.Notification) error {
-	return s.repo.Create(notification)
+	if notification.Type == "" {
+		notification.Type = models.ChannelInApp
+	}
+	if err := s.repo.Create(notification); err != nil {
+		return err
+	}
+	if s.realtime != nil {
+		s.realtime.Publish(notification.UserID, realtime.TypeNotificationCreated, notification)
+	}
+	if s.dispatcher == nil {
+		return nil
+	}
+	return s.dispatcher.Forward(notification)
 }
 
 func (s *notificationService) GetByID(id uint) (*models.Notification, error) {
@@ -48,10 +70,26 @@ func (s *notificationService) Delete(id uint) error {
 	return s.repo.Delete(id)
 }
 
-func (s *notificationService) MarkAsRead(id uint) error {
-	return s.repo.MarkAsRead(id)
+// MarkAsRead marks one of the user's notifications read
+func (s *notificationService) MarkAsRead(id, userID uint) error {
+	if err := s.repo.MarkAsRead(id, userID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrNotificationNotFound
+		}
+		return err
+	}
+	if s.realtime != nil {
+		s.realtime.Publish(userID, realtime.TypeNotificationRead, map[string]interface{}{"id": id})
+	}
+	return nil
 }
 
 func (s *notificationService) MarkAllAsRead(userID uint) error {
-	return s.repo.MarkAllAsRead(userID)
+	if err := s.repo.MarkAllAsRead(userID); err != nil {
+		return err
+	}
+	if s.realtime != nil {
+		s.realtime.Publish(userID, realtime.TypeNotificationRead, map[string]interface{}{"all": true})
+	}
+	return nil
 }
@@ -0,0 +1,152 @@
+package service
+
+import (
+	"school-management-system/internal/models"
+	"school-management-system/internal/repository"
+	"time"
+)
+
+// ChildAssignment is an assignment in one of the child's courses together
+// with the child's submission, if any.
+type ChildAssignment struct {
+	Assignment models.Assignment            `json:"assignment"`
+	Submission *models.AssignmentSubmission `json:"submission,omitempty"`
+}
+
+// ChildBalance summarises a child's payments.
+type ChildBalance struct {
+	StudentID   uint    `json:"student_id"`
+	Paid        float64 `json:"paid"`
+	Outstanding float64 `json:"outstanding"`
+}
+
+// ParentPortalService gathers the read-only views guardians see of their
+// linked children. Callers are responsible for checking the link.
+type ParentPortalService interface {
+	GetChildGrades(studentID uint) ([]models.Grade, error)
+	GetChildAttendance(studentID uint, page, limit int) ([]models.Attendance, int64, error)
+	GetChildAssignments(studentID uint) ([]ChildAssignment, error)
+	GetChildTimetable(studentID uint) ([]models.TimeTable, error)
+	GetChildBalance(studentID uint) (*ChildBalance, error)
+	GetAnnouncements(userID uint, page, limit int) ([]models.Announcement, int64, error)
+}
+
+type parentPortalService struct {
+	gradeRepo        repository.GradeRepository
+	attendanceRepo   repository.AttendanceRepository
+	enrollmentRepo   repository.EnrollmentRepository
+	assignmentRepo   repository.AssignmentRepository
+	submissionRepo   repository.AssignmentSubmissionRepository
+	timetableRepo    repository.TimeTableRepository
+	paymentRepo      repository.PaymentRepository
+	announcementRepo repository.AnnouncementRepository
+}
+
+func NewParentPortalService(
+	gradeRepo repository.GradeRepository,
+	attendanceRepo repository.AttendanceRepository,
+	enrollmentRepo repository.EnrollmentRepository,
+	assignmentRepo repository.AssignmentRepository,
+	submissionRepo repository.AssignmentSubmissionRepository,
+	timetableRepo repository.TimeTableRepository,
+	paymentRepo repository.PaymentRepository,
+	announcementRepo repository.AnnouncementRepository,
+) ParentPortalService {
+	return &parentPortalService{
+		gradeRepo:        gradeRepo,
+		attendanceRepo:   attendanceRepo,
+		enrollmentRepo:   enrollmentRepo,
+		assignmentRepo:   assignmentRepo,
+		submissionRepo:   submissionRepo,
+		timetableRepo:    timetableRepo,
+		paymentRepo:      paymentRepo,
+		announcementRepo: announcementRepo,
+	}
+}
+
+func (s *parentPortalService) GetChildGrades(studentID uint) ([]models.Grade, error) {
+	return s.gradeRepo.FindAllByStudentID(studentID)
+}
+
+func (s *parentPortalService) GetChildAttendance(studentID uint, page, limit int) ([]models.Attendance, int64, error) {
+	return s.attendanceRepo.FindByStudentID(studentID, page, limit)
+}
+
+// GetChildAssignments lists the assignments of every course the child is
+// actively enrolled in, matched with the child's submissions.
+func (s *parentPortalService) GetChildAssignments(studentID uint) ([]ChildAssignment, error) {
+	enrollments, err := s.enrollmentRepo.FindActiveByStudentID(studentID)
+	if err != nil {
+		return nil, err
+	}
+
+	submissions, err := s.submissionRepo.FindByStudentID(studentID)
+	if err != nil {
+		return nil, err
+	}
+	byAssignment := make(map[uint]*models.AssignmentSubmission, len(submissions))
+	for i := range submissions {
+		byAssignment[submissions[i].AssignmentID] = &submissions[i]
+	}
+
+	result := []ChildAssignment{}
+	seen := make(map[uint]bool)
+	for _, e := range enrollments {
+		if seen[e.CourseID] {
+			continue
+		}
+		seen[e.CourseID] = true
+
+		assignments, err := s.assignmentRepo.FindByCourseID(e.CourseID)
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range assignments {
+			result = append(result, ChildAssignment{Assignment: a, Submission: byAssignment[a.ID]})
+		}
+	}
+	return result, nil
+}
+
+// GetChildTimetable returns the timetable slots of the child's active
+// enrollments: the section's slots when enrolled in a section, otherwise the
+// course's.
+func (s *parentPortalService) GetChildTimetable(studentID uint) ([]models.TimeTable, error) {
+	enrollments, err := s.enrollmentRepo.FindActiveByStudentID(studentID)
+	if err != nil {
+		return nil, err
+	}
+
+	slots := []models.TimeTable{}
+	for _, e := range enrollments {
+		var entries []models.TimeTable
+		if e.SectionID != nil {
+			entries, err = s.timetableRepo.FindBySectionID(*e.SectionID)
+		} else {
+			entries, err = s.timetableRepo.FindByCourseID(e.CourseID)
+		}
+		if err != nil {
+			return nil, err
+		}
+		slots = append(slots, entries...)
+	}
+	return slots, nil
+}
+
+func (s *parentPortalService) GetChildBalance(studentID uint) (*ChildBalance, error) {
+	paid, err := s.paymentRepo.SumByStudent(studentID)
+	if err != nil {
+		return nil, err
+	}
+	outstanding, err := s.paymentRepo.SumOutstandingByStudent(studentID)
+	if err != nil {
+		return nil, err
+	}
+	return &ChildBalance{StudentID: studentID, Paid: paid, Outstanding: outstanding}, nil
+}
+
+// GetAnnouncements returns the live announcements delivered to the parent,
+// including those addressed to their children's courses and grade levels.
+func (s *parentPortalService) GetAnnouncements(userID uint, page, limit int) ([]models.Announcement, int64, error) {
+	return s.announcementRepo.FindForRecipient(userID, time.Now().Unix(), false, page, limit)
+}
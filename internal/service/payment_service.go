@@ -1,32 +1,52 @@
 package service
 
 import (
+	"context"
 	"school-management-system/internal/models"
 	"school-management-system/internal/repository"
+	"school-management-system/pkg/logger"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Reminders go out this many days before a payment is due, and again
+// every overdueReminderInterval while it is overdue
+const (
+	paymentReminderDays     = 3
+	overdueReminderInterval = 7 * 24 * time.Hour
 )
 
 type PaymentService interface {
-	Create(payment *models.Payment) error
+	Create(ctx context.Context, payment *models.Payment) error
 	GetByID(id uint) (*models.Payment, error)
 	GetByStudentID(studentID uint, page, limit int) ([]models.Payment, int64, error)
 	GetByStatus(status string, page, limit int) ([]models.Payment, int64, error)
 	GetAll(page, limit int) ([]models.Payment, int64, error)
-	Update(payment *models.Payment) error
-	Delete(id uint) error
+	Update(ctx context.Context, payment *models.Payment) error
+	Delete(ctx context.Context, id uint) error
 	GetStudentBalance(studentID uint) (float64, error)
 	GetTotalRevenue(status string) (float64, error)
+	MarkOverdue(now time.Time) (int64, error)
+	SendReminders(now time.Time) (int, error)
 }
 
 type paymentService struct {
-	repo repository.PaymentRepository
+	repo     repository.PaymentRepository
+	notifier NotificationDispatcher
+	logger   *logrus.Logger
 }
 
-func NewPaymentService(repo repository.PaymentRepository) PaymentService {
-	return &paymentService{repo: repo}
+func NewPaymentService(repo repository.PaymentRepository, notifier NotificationDispatcher) PaymentService {
+	return &paymentService{
+		repo:     repo,
+		notifier: notifier,
+		logger:   logger.GetLogger(),
+	}
 }
 
-func (s *paymentService) Create(payment *models.Payment) error {
-	return s.repo.Create(payment)
+func (s *paymentService) Create(ctx context.Context, payment *models.Payment) error {
+	return s.repo.Create(ctx, payment)
 }
 
 func (s *paymentService) GetByID(id uint) (*models.Payment, error) {
@@ -45,12 +65,12 @@ func (s *paymentService) GetAll(page, limit int) ([]models.Payment, int64, error
 	return s.repo.FindAll(page, limit)
 }
 
-func (s *paymentService) Update(payment *models.Payment) error {
-	return s.repo.Update(payment)
+func (s *paymentService) Update(ctx context.Context, payment *models.Payment) error {
+	return s.repo.Update(ctx, payment)
 }
 
-func (s *paymentService) Delete(id uint) error {
-	return s.repo.Delete(id)
+func (s *paymentService) Delete(ctx context.Context, id uint) error {
+	return s.repo.Delete(ctx, id)
 }
 
 func (s *paymentService) GetStudentBalance(studentID uint) (float64, error) {
@@ -60,3 +80,43 @@ func (s *paymentService) GetStudentBalance(studentID uint) (float64, error) {
 func (s *paymentService) GetTotalRevenue(status string) (float64, error) {
 	return s.repo.SumByStatus(status)
 }
+
+// MarkOverdue marks pending payments past their due date as overdue
+func (s *paymentService) MarkOverdue(now time.Time) (int64, error) {
+	return s.repo.MarkOverdue(now.Unix())
+}
+
+// SendReminders notifies students about payments due soon or overdue,
+// returning how many reminders were sent. Each payment is reminded once
+// before it is due and then weekly while overdue; the first reminder after
+// a payment becomes overdue is sent as a payment_overdue event.
+func (s *paymentService) SendReminders(now time.Time) (int, error) {
+	payments, err := s.repo.FindDueForReminder(
+		now.AddDate(0, 0, paymentReminderDays).Unix(),
+		now.Add(-overdueReminderInterval).Unix(),
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for _, p := range payments {
+		user := p.Student.User
+		if user.ID == 0 {
+			continue
+		}
+		event := EventPaymentDue
+		if p.Status == models.PaymentStatusOverdue && p.ReminderSentAt == 0 {
+			event = EventPaymentOverdue
+		}
+		if err := s.notifier.Dispatch(paymentEvent(event, &p, &user)); err != nil {
+			s.logger.WithError(err).WithField("payment_id", p.ID).Warn("Failed to send payment reminder")
+		}
+
+		if err := s.repo.MarkReminded(p.ID, now.Unix()); err != nil {
+			return sent, err
+		}
+		sent++
+	}
+	return sent, nil
+}
@@ -0,0 +1,342 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"school-management-system/internal/models"
+	"school-management-system/internal/realtime"
+	"school-management-system/internal/repository"
+	"school-management-system/pkg/logger"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// TypeStreamReset tells a resuming client that events it missed are no
+// longer available, so it should reload what it shows over the REST API
+const TypeStreamReset = "stream.reset"
+
+// RealtimeOptions configures real-time push. Zero values take the defaults.
+type RealtimeOptions struct {
+	Broker      realtime.Broker // carries events between instances; in-memory by default
+	BufferSize  int             // events buffered per connection before it is dropped; default 64
+	ReplayLimit int             // most events replayed to a resuming client; default 500
+}
+
+// RealtimeService pushes notifications, messages, read receipts and
+// announcements to connected users. Every event is stored before it is
+// published, which gives it an id clients can resume from.
+type RealtimeService interface {
+	Publish(userID uint, eventType string, data interface{})
+	Broadcast(role string, eventType string, data interface{})
+	Connect(userID uint, role string, lastEventID uint64) (*realtime.Client, []realtime.Event, error)
+	Disconnect(client *realtime.Client)
+	Start()
+	Stop()
+	Connections() (clients, users int)
+	Purge(olderThan time.Duration) (int64, error)
+}
+
+type realtimeService struct {
+	repo    repository.RealtimeEventRepository
+	hub     *realtime.Hub
+	options RealtimeOptions
+	logger  *logrus.Logger
+
+	mu          sync.Mutex
+	unsubscribe func()
+}
+
+func NewRealtimeService(repo repository.RealtimeEventRepository, options RealtimeOptions) RealtimeService {
+	if options.Broker == nil {
+		options.Broker = realtime.NewMemoryBroker()
+	}
+	if options.ReplayLimit <= 0 {
+		options.ReplayLimit = 500
+	}
+	return &realtimeService{
+		repo:    repo,
+		hub:     realtime.NewHub(options.BufferSize),
+		options: options,
+		logger:  logger.GetLogger(),
+	}
+}
+
+// Publish pushes an event to one user. Push is best effort: failures are
+// logged, and the user still sees the change the next time they load it.
+func (s *realtimeService) Publish(userID uint, eventType string, data interface{}) {
+	if userID == 0 {
+		return
+	}
+	s.publish(userID, "", eventType, data)
+}
+
+// Broadcast pushes an event to every user with the role, or to everyone
+// when role is empty
+func (s *realtimeService) Broadcast(role string, eventType string, data interface{}) {
+	s.publish(0, role, eventType, data)
+}
+
+func (s *realtimeService) publish(userID uint, role, eventType string, data interface{}) {
+	log := s.logger.WithFields(logrus.Fields{"type": eventType, "user_id": userID})
+	payload, err := json.Marshal(data)
+	if err != nil {
+		log.WithError(err).Warn("Failed to encode real-time event")
+		return
+	}
+	record := &models.RealtimeEvent{
+		UserID:    userID,
+		Role:      role,
+		Type:      eventType,
+		Data:      string(payload),
+		CreatedAt: time.Now().Unix(),
+	}
+	if err := s.repo.Create(record); err != nil {
+		log.WithError(err).Warn("Failed to store real-time event")
+		return
+	}
+	if err := s.options.Broker.Publish(context.Background(), toRealtimeEvent(record)); err != nil {
+		log.WithError(err).Warn("Failed to publish real-time event")
+	}
+}
+
+func toRealtimeEvent(record *models.RealtimeEvent) realtime.Event {
+	return realtime.Event{
+		ID:        record.ID,
+		UserID:    record.UserID,
+		Role:      record.Role,
+		Type:      record.Type,
+		Data:      json.RawMessage(record.Data),
+		CreatedAt: record.CreatedAt,
+	}
+}
+
+// Connect registers a connection for the user. With a lastEventID it also
+// returns the events published since, or a single stream.reset event when
+// they can no longer all be replayed. Live events already in the replay may
+// arrive on the client too; skip those with an id not above the replay's
+// last.
+func (s *realtimeService) Connect(userID uint, role string, lastEventID uint64) (*realtime.Client, []realtime.Event, error) {
+	// Register first so nothing published during the replay query is missed
+	client := s.hub.Register(userID, role)
+	if lastEventID == 0 {
+		return client, nil, nil
+	}
+
+	first, err := s.repo.FirstID()
+	if err != nil {
+		s.hub.Unregister(client)
+		return nil, nil, err
+	}
+	records, err := s.repo.FindForUser(userID, role, lastEventID, s.options.ReplayLimit+1)
+	if err != nil {
+		s.hub.Unregister(client)
+		return nil, nil, err
+	}
+	// Events after lastEventID were purged, or there are too many to replay
+	if (first > 0 && first > lastEventID+1) || len(records) > s.options.ReplayLimit {
+		latest, err := s.repo.LatestID()
+		if err != nil {
+			s.hub.Unregister(client)
+			return nil, nil, err
+		}
+		reset := realtime.Event{ID: latest, Type: TypeStreamReset, Data: json.RawMessage("{}"), CreatedAt: time.Now().Unix()}
+		return client, []realtime.Event{reset}, nil
+	}
+
+	replay := make([]realtime.Event, 0, len(records))
+	for i := range records {
+		replay = append(replay, toRealtimeEvent(&records[i]))
+	}
+	return client, replay, nil
+}
+
+func (s *realtimeService) Disconnect(client *realtime.Client) {
+	s.hub.Unregister(client)
+}
+
+// Start delivers events from the broker to this instance's connections
+func (s *realtimeService) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.unsubscribe == nil {
+		s.unsubscribe = s.options.Broker.Subscribe(s.hub.Deliver)
+	}
+}
+
+func (s *realtimeService) Stop() {
+	s.mu.Lock()
+	unsubscribe := s.unsubscribe
+	s.unsubscribe = nil
+	s.mu.Unlock()
+	if unsubscribe != nil {
+		unsubscribe()
+	}
+	if err := s.options.Broker.Close(); err != nil {
+		s.logger.WithError(err).Warn("Failed to close real-time broker")
+	}
+}
+
+func (s *realtimeService) Connections() (clients, users int) {
+	return s.hub.Connections()
+}
+
+// Purge deletes stored events older than the given age. Clients that were
+// away longer are told to reload instead of resuming.
+func (s *realtimeService) Purge(olderThan time.Duration) (int64, error) {
+	if olderThan <= 0 {
+		return 0, nil
+	}
+	return s.repo.DeleteBefore(time.Now().Add(-olderThan).Unix())
+}
+
+// DatabaseBroker carries events between instances through the stored
+// events table: each instance polls for events newer than the last it saw.
+// It needs nothing beyond the shared database, at the cost of up to one
+// poll interval of delay.
+//
+// Event ids are handed out when a row is inserted, not when it commits, so
+// on Postgres an event can become visible after one with a higher id was
+// already delivered. Each poll therefore also re-reads the events created
+// within lateCommitWindow and delivers those it has not seen. Events are
+// delivered once each, mostly in id order; one whose transaction committed
+// late arrives after events with higher ids.
+type DatabaseBroker struct {
+	repo     repository.RealtimeEventRepository
+	interval time.Duration
+	logger   *logrus.Logger
+
+	mu       sync.Mutex
+	handlers map[int]func(realtime.Event)
+	next     int
+	cancel   context.CancelFunc
+	stopped  chan struct{}
+}
+
+// lateCommitWindow is how long after it was created an event that committed
+// out of id order is still picked up
+const lateCommitWindow = 30 * time.Second
+
+// NewDatabaseBroker creates a broker polling every interval, default 1s
+func NewDatabaseBroker(repo repository.RealtimeEventRepository, interval time.Duration) *DatabaseBroker {
+	if interval <= 0 {
+		interval = time.Second
+	}
+	return &DatabaseBroker{repo: repo, interval: interval, logger: logger.GetLogger(), handlers: map[int]func(realtime.Event){}}
+}
+
+// Publish does nothing: the event is already stored, and every instance,
+// this one included, picks it up on its next poll
+func (b *DatabaseBroker) Publish(ctx context.Context, event realtime.Event) error {
+	return nil
+}
+
+func (b *DatabaseBroker) Subscribe(handler func(realtime.Event)) func() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	id := b.next
+	b.next++
+	b.handlers[id] = handler
+	if b.cancel == nil {
+		ctx, cancel := context.WithCancel(context.Background())
+		b.cancel = cancel
+		b.stopped = make(chan struct{})
+		go b.poll(ctx, b.stopped)
+	}
+	return func() {
+		b.mu.Lock()
+		delete(b.handlers, id)
+		b.mu.Unlock()
+	}
+}
+
+func (b *DatabaseBroker) poll(ctx context.Context, stopped chan struct{}) {
+	defer close(stopped)
+	cursor, err := b.repo.LatestID()
+	if err != nil {
+		b.logger.WithError(err).Warn("Failed to read latest real-time event")
+	}
+	// seen holds the creation time of each event in the late commit window
+	// that was delivered, or was already stored when polling started
+	seen := map[uint64]int64{}
+	recent, err := b.repo.FindRecentThrough(cursor, time.Now().Add(-lateCommitWindow).Unix())
+	if err != nil {
+		b.logger.WithError(err).Warn("Failed to read recent real-time events")
+	}
+	for _, r := range recent {
+		seen[r.ID] = r.CreatedAt
+	}
+
+	ticker := time.NewTicker(b.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		since := time.Now().Add(-lateCommitWindow).Unix()
+		late, err := b.repo.FindRecentThrough(cursor, since)
+		if err != nil {
+			b.logger.WithError(err).Warn("Failed to poll real-time events")
+			continue
+		}
+		for i := range late {
+			if _, ok := seen[late[i].ID]; !ok {
+				b.deliver(&late[i])
+				seen[late[i].ID] = late[i].CreatedAt
+			}
+		}
+
+		for {
+			records, err := b.repo.FindAfter(cursor, 500)
+			if err != nil {
+				b.logger.WithError(err).Warn("Failed to poll real-time events")
+				break
+			}
+			for i := range records {
+				b.deliver(&records[i])
+				seen[records[i].ID] = records[i].CreatedAt
+				cursor = records[i].ID
+			}
+			if len(records) < 500 {
+				break
+			}
+		}
+
+		for id, createdAt := range seen {
+			if createdAt < since {
+				delete(seen, id)
+			}
+		}
+	}
+}
+
+// deliver hands a stored event to every subscriber
+func (b *DatabaseBroker) deliver(record *models.RealtimeEvent) {
+	b.mu.Lock()
+	handlers := make([]func(realtime.Event), 0, len(b.handlers))
+	for _, h := range b.handlers {
+		handlers = append(handlers, h)
+	}
+	b.mu.Unlock()
+	event := toRealtimeEvent(record)
+	for _, h := range handlers {
+		h(event)
+	}
+}
+
+// Close stops polling
+func (b *DatabaseBroker) Close() error {
+	b.mu.Lock()
+	cancel, stopped := b.cancel, b.stopped
+	b.cancel = nil
+	b.mu.Unlock()
+	if cancel != nil {
+		cancel()
+		<-stopped
+	}
+	return nil
+}
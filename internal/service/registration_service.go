@@ -0,0 +1,422 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"school-management-system/internal/models"
+	"school-management-system/internal/repository"
+	"school-management-system/pkg/logger"
+	"strconv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// SettingMaxCreditHours is the most credit hours a student may register for
+// in one term; 0 lifts the limit
+const SettingMaxCreditHours = "registration.max_credit_hours"
+
+const defaultMaxCreditHours = 18
+
+// Registration rejection codes, alongside the enrollment ones
+const (
+	RejectRegistrationClosed = "registration_closed"
+	RejectCreditLimit        = "credit_limit"
+	RejectCartEmpty          = "cart_empty"
+)
+
+var (
+	ErrNoOpenTerm          = errors.New("no term is open for registration")
+	ErrCartItemNotFound    = errors.New("section is not in the cart")
+	ErrRegistrationMissing = errors.New("not found")
+	ErrRegistrationInvalid = errors.New("invalid registration")
+)
+
+// CatalogEntry is a section offered for registration with the seats it has
+// left. SeatsRemaining is -1 for a section without a capacity.
+type CatalogEntry struct {
+	models.CourseSection
+	SeatsTaken     int `json:"seats_taken"`
+	SeatsRemaining int `json:"seats_remaining"`
+}
+
+// RegistrationItem is a cart section as checked, and once registered the
+// enrollment made for it
+type RegistrationItem struct {
+	SectionID    uint              `json:"section_id"`
+	CourseID     uint              `json:"course_id"`
+	CourseCode   string            `json:"course_code"`
+	SectionCode  string            `json:"section_code"`
+	TermID       uint              `json:"term_id"`
+	CreditHours  int               `json:"credit_hours"`
+	Reasons      []RejectionReason `json:"reasons,omitempty"`
+	EnrollmentID uint              `json:"enrollment_id,omitempty"`
+	Status       string            `json:"status,omitempty"`
+}
+
+// TermLoad is the credit hours a student would carry in a term after
+// registering
+type TermLoad struct {
+	TermID      uint `json:"term_id"`
+	CreditHours int  `json:"credit_hours"`
+}
+
+// RegistrationResult is the outcome of checking or registering a cart.
+// Reasons holds what is wrong with the cart as a whole.
+type RegistrationResult struct {
+	Valid          bool               `json:"valid"`
+	Items          []RegistrationItem `json:"items"`
+	Reasons        []RejectionReason  `json:"reasons,omitempty"`
+	Terms          []TermLoad         `json:"terms"`
+	MaxCreditHours int                `json:"max_credit_hours"`
+
+	plan []PlannedEnrollment
+}
+
+type RegistrationService interface {
+	Catalog(filter repository.CatalogFilter, page, limit int, now time.Time) (*models.AcademicTerm, []CatalogEntry, int64, error)
+	GetCart(studentID uint) ([]models.RegistrationCartItem, error)
+	AddToCart(studentID, sectionID uint) (*models.RegistrationCartItem, error)
+	RemoveFromCart(studentID, sectionID uint) error
+	Validate(studentID uint, allowWaitlist bool, now time.Time) (*RegistrationResult, error)
+	Register(ctx context.Context, studentID uint, allowWaitlist bool, actorID uint, now time.Time) (*RegistrationResult, error)
+	Override(ctx context.Context, studentID, sectionID, actorID uint, reason string) (*models.Enrollment, error)
+	SetPriority(priority *models.RegistrationPriority) error
+	DeletePriority(termID, studentID uint) error
+}
+
+type registrationService struct {
+	repo        repository.RegistrationRepository
+	termRepo    repository.AcademicTermRepository
+	sectionRepo repository.CourseSectionRepository
+	studentRepo repository.StudentRepository
+	enrollRepo  repository.EnrollmentRepository
+	settings    repository.SystemSettingRepository
+	enrollments EnrollmentService
+	logger      *logrus.Logger
+}
+
+// NewRegistrationService creates the service students register for courses
+// through. Registration makes its enrollments with the enrollment service,
+// so capacity, waitlists and requisites work as for any other enrollment.
+func NewRegistrationService(
+	repo repository.RegistrationRepository,
+	termRepo repository.AcademicTermRepository,
+	sectionRepo repository.CourseSectionRepository,
+	studentRepo repository.StudentRepository,
+	enrollRepo repository.EnrollmentRepository,
+	settings repository.SystemSettingRepository,
+	enrollments EnrollmentService,
+) RegistrationService {
+	return &registrationService{
+		repo:        repo,
+		termRepo:    termRepo,
+		sectionRepo: sectionRepo,
+		studentRepo: studentRepo,
+		enrollRepo:  enrollRepo,
+		settings:    settings,
+		enrollments: enrollments,
+		logger:      logger.GetLogger(),
+	}
+}
+
+// Catalog lists the open sections of a term with their seats remaining.
+// Without a term it lists the term whose registration is open now.
+func (s *registrationService) Catalog(filter repository.CatalogFilter, page, limit int, now time.Time) (*models.AcademicTerm, []CatalogEntry, int64, error) {
+	term, err := s.catalogTerm(filter.TermID, now)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	filter.TermID = term.ID
+
+	sections, total, err := s.repo.FindCatalog(filter, page, limit)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	ids := make([]uint, len(sections))
+	for i := range sections {
+		ids[i] = sections[i].ID
+	}
+	taken, err := s.repo.CountSectionSeats(ids)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	entries := make([]CatalogEntry, len(sections))
+	for i, section := range sections {
+		section.Term = *term
+		entries[i] = CatalogEntry{CourseSection: section, SeatsTaken: taken[section.ID], SeatsRemaining: -1}
+		if section.Capacity > 0 {
+			entries[i].SeatsRemaining = max(section.Capacity-taken[section.ID], 0)
+		}
+	}
+	return term, entries, total, nil
+}
+
+func (s *registrationService) catalogTerm(termID uint, now time.Time) (*models.AcademicTerm, error) {
+	if termID != 0 {
+		term, err := s.termRepo.FindByID(termID)
+		if err != nil {
+			return nil, fmt.Errorf("academic term %w", ErrRegistrationMissing)
+		}
+		return term, nil
+	}
+	terms, err := s.termRepo.FindAllOrdered()
+	if err != nil {
+		return nil, err
+	}
+	for i := range terms {
+		if terms[i].IsRegistrationOpen(now) {
+			return &terms[i], nil
+		}
+	}
+	return nil, ErrNoOpenTerm
+}
+
+func (s *registrationService) GetCart(studentID uint) ([]models.RegistrationCartItem, error) {
+	return s.repo.FindCart(studentID)
+}
+
+// AddToCart puts an open section in a student's cart. Whether the student
+// may register for it is only checked when validating the cart.
+func (s *registrationService) AddToCart(studentID, sectionID uint) (*models.RegistrationCartItem, error) {
+	section, err := s.sectionRepo.FindByID(sectionID)
+	if err != nil {
+		return nil, fmt.Errorf("section %w", ErrRegistrationMissing)
+	}
+	if section.Status != "" && section.Status != "open" {
+		return nil, fmt.Errorf("%w: section %s is %s", ErrRegistrationInvalid, section.SectionCode, section.Status)
+	}
+
+	item := &models.RegistrationCartItem{StudentID: studentID, SectionID: sectionID}
+	added, err := s.repo.AddToCart(item)
+	if err != nil {
+		return nil, err
+	}
+	if !added {
+		return nil, fmt.Errorf("%w: section is already in the cart", ErrRegistrationInvalid)
+	}
+	item.Section = *section
+	return item, nil
+}
+
+func (s *registrationService) RemoveFromCart(studentID, sectionID uint) error {
+	removed, err := s.repo.RemoveFromCart(studentID, sectionID)
+	if err != nil {
+		return err
+	}
+	if !removed {
+		return ErrCartItemNotFound
+	}
+	return nil
+}
+
+// Validate checks a student's whole cart without registering: that each
+// term's registration is open to the student, every section passes the
+// enrollment rules alone and alongside the rest of the cart, and no term
+// goes over the credit hour limit. A full section is only reported without
+// allowWaitlist.
+func (s *registrationService) Validate(studentID uint, allowWaitlist bool, now time.Time) (*RegistrationResult, error) {
+	cart, err := s.repo.FindCart(studentID)
+	if err != nil {
+		return nil, err
+	}
+	result := &RegistrationResult{Items: []RegistrationItem{}, Terms: []TermLoad{}, MaxCreditHours: s.maxCreditHours()}
+	if len(cart) == 0 {
+		result.Reasons = append(result.Reasons, RejectionReason{Code: RejectCartEmpty, Message: "the cart is empty"})
+		return result, nil
+	}
+
+	requests := make([]*models.Enrollment, len(cart))
+	for i := range cart {
+		sectionID := cart[i].SectionID
+		requests[i] = &models.Enrollment{SectionID: &sectionID}
+	}
+	result.plan, err = s.enrollments.CheckPlan(studentID, requests, allowWaitlist)
+	if err != nil {
+		return nil, err
+	}
+
+	windows := map[uint]*RejectionReason{}
+	loads := map[uint]int{}
+	for i, item := range cart {
+		section := &item.Section
+		entry := RegistrationItem{
+			SectionID:   section.ID,
+			CourseID:    section.CourseID,
+			CourseCode:  section.Course.CourseCode,
+			SectionCode: section.SectionCode,
+			TermID:      section.TermID,
+			CreditHours: section.Course.CreditHours,
+		}
+
+		closed, seen := windows[section.TermID]
+		if !seen {
+			if closed, err = s.checkWindow(studentID, &section.Term, now); err != nil {
+				return nil, err
+			}
+			windows[section.TermID] = closed
+			existing, err := s.enrollRepo.FindOpenByStudentAndTerm(studentID, section.TermID)
+			if err != nil {
+				return nil, err
+			}
+			for _, enrollment := range existing {
+				loads[section.TermID] += enrollment.Course.CreditHours
+			}
+			result.Terms = append(result.Terms, TermLoad{TermID: section.TermID})
+		}
+		if closed != nil {
+			entry.Reasons = append(entry.Reasons, *closed)
+		}
+		entry.Reasons = append(entry.Reasons, result.plan[i].Reasons...)
+		loads[section.TermID] += entry.CreditHours
+		result.Items = append(result.Items, entry)
+	}
+
+	for i := range result.Terms {
+		load := &result.Terms[i]
+		load.CreditHours = loads[load.TermID]
+		if result.MaxCreditHours > 0 && load.CreditHours > result.MaxCreditHours {
+			result.Reasons = append(result.Reasons, RejectionReason{
+				Code: RejectCreditLimit,
+				Message: fmt.Sprintf("registering would bring the term to %d credit hours, over the limit of %d",
+					load.CreditHours, result.MaxCreditHours),
+			})
+		}
+	}
+
+	result.Valid = len(result.Reasons) == 0
+	for _, item := range result.Items {
+		if len(item.Reasons) > 0 {
+			result.Valid = false
+		}
+	}
+	return result, nil
+}
+
+// checkWindow returns why a student may not register for a term now, if
+// they may not. A priority time set for the student replaces the term's
+// registration start.
+func (s *registrationService) checkWindow(studentID uint, term *models.AcademicTerm, now time.Time) (*RejectionReason, error) {
+	priority, err := s.repo.FindPriority(term.ID, studentID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		if term.IsRegistrationOpen(now) {
+			return nil, nil
+		}
+		return &RejectionReason{Code: RejectRegistrationClosed, Message: fmt.Sprintf("registration for %s is not open", term.Name)}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case term.IsRegistrationOpenFrom(priority.OpensAt, now):
+		return nil, nil
+	case now.Before(priority.OpensAt):
+		return &RejectionReason{
+			Code:    RejectRegistrationClosed,
+			Message: fmt.Sprintf("registration for %s opens for you at %s", term.Name, priority.OpensAt.Format(time.RFC3339)),
+		}, nil
+	}
+	return &RejectionReason{Code: RejectRegistrationClosed, Message: fmt.Sprintf("registration for %s is not open", term.Name)}, nil
+}
+
+func (s *registrationService) maxCreditHours() int {
+	limit, err := strconv.Atoi(s.settings.GetValue(SettingMaxCreditHours, strconv.Itoa(defaultMaxCreditHours)))
+	if err != nil || limit < 0 {
+		return defaultMaxCreditHours
+	}
+	return limit
+}
+
+// Register validates a student's cart and, if all of it may be registered,
+// enrolls the student in every section at once and empties the cart. If any
+// section cannot be registered none are, and ErrRegistrationInvalid is
+// returned with the result saying why.
+func (s *registrationService) Register(ctx context.Context, studentID uint, allowWaitlist bool, actorID uint, now time.Time) (*RegistrationResult, error) {
+	result, err := s.Validate(studentID, allowWaitlist, now)
+	if err != nil {
+		return nil, err
+	}
+	if !result.Valid {
+		return result, ErrRegistrationInvalid
+	}
+
+	enrollments := make([]*models.Enrollment, len(result.plan))
+	sectionIDs := make([]uint, len(result.plan))
+	for i, planned := range result.plan {
+		enrollments[i] = planned.Enrollment
+		sectionIDs[i] = result.Items[i].SectionID
+	}
+	err = s.enrollments.EnrollPlan(ctx, enrollments, EnrollOptions{AllowWaitlist: allowWaitlist, MaxCreditHours: result.MaxCreditHours,
+		ActorID: actorID, Reason: "registration"})
+	var rejection *EnrollmentRejectedError
+	if errors.As(err, &rejection) {
+		// A seat went, or another registration added credit hours, between
+		// validating and enrolling
+		result.Valid = false
+		result.Reasons = append(result.Reasons, rejection.Reasons...)
+		return result, ErrRegistrationInvalid
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for i, enrollment := range enrollments {
+		result.Items[i].EnrollmentID = enrollment.ID
+		result.Items[i].Status = enrollment.Status
+	}
+	if err := s.repo.ClearCart(studentID, sectionIDs); err != nil {
+		s.logger.WithError(err).WithField("student_id", studentID).Error("Failed to clear registration cart")
+	}
+	s.logger.WithField("student_id", studentID).WithField("sections", len(enrollments)).Info("Student registered")
+	return result, nil
+}
+
+// Override enrolls a student in a section whatever registration windows,
+// credit limits, requisites, clashes and capacity say. It is for staff
+// making exceptions, who must give a reason.
+func (s *registrationService) Override(ctx context.Context, studentID, sectionID, actorID uint, reason string) (*models.Enrollment, error) {
+	if reason == "" {
+		return nil, fmt.Errorf("%w: a reason is required to override registration rules", ErrRegistrationInvalid)
+	}
+	if _, err := s.studentRepo.FindByID(studentID); err != nil {
+		return nil, fmt.Errorf("student %w", ErrRegistrationMissing)
+	}
+
+	enrollment := &models.Enrollment{StudentID: studentID, SectionID: &sectionID}
+	if err := s.enrollments.EnrollStudent(ctx, enrollment, EnrollOptions{Override: true, ActorID: actorID, Reason: reason}); err != nil {
+		return nil, err
+	}
+	if err := s.repo.ClearCart(studentID, []uint{sectionID}); err != nil {
+		s.logger.WithError(err).WithField("student_id", studentID).Error("Failed to clear registration cart")
+	}
+	return enrollment, nil
+}
+
+// SetPriority sets when a student may start registering for a term
+func (s *registrationService) SetPriority(priority *models.RegistrationPriority) error {
+	if priority.OpensAt.IsZero() {
+		return fmt.Errorf("%w: opens_at is required", ErrRegistrationInvalid)
+	}
+	if _, err := s.termRepo.FindByID(priority.TermID); err != nil {
+		return fmt.Errorf("academic term %w", ErrRegistrationMissing)
+	}
+	if _, err := s.studentRepo.FindByID(priority.StudentID); err != nil {
+		return fmt.Errorf("student %w", ErrRegistrationMissing)
+	}
+	return s.repo.SavePriority(priority)
+}
+
+func (s *registrationService) DeletePriority(termID, studentID uint) error {
+	deleted, err := s.repo.DeletePriority(termID, studentID)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return fmt.Errorf("registration priority %w", ErrRegistrationMissing)
+	}
+	return nil
+}
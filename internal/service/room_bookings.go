@@ -0,0 +1,438 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"school-management-system/internal/models"
+	"school-management-system/internal/repository"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Booking conflict codes
+const (
+	BookingClassInRoom         = "class_in_room"
+	BookingRoomTaken           = "room_booked"
+	BookingResourceUnavailable = "resource_unavailable"
+)
+
+const bookingDateLayout = "2006-01-02"
+
+var (
+	ErrBookingNotFound  = errors.New("booking not found")
+	ErrInvalidBooking   = errors.New("invalid booking")
+	ErrBookingCancelled = errors.New("booking is already cancelled")
+)
+
+// BookingConflict is a class or booking a booking would clash with
+type BookingConflict struct {
+	Code        string `json:"code"`
+	Message     string `json:"message"`
+	TimetableID uint   `json:"timetable_id,omitempty"`
+	BookingID   uint   `json:"booking_id,omitempty"`
+}
+
+// BookingConflictError lists everything a booking would clash with
+type BookingConflictError struct {
+	Conflicts []BookingConflict `json:"conflicts"`
+}
+
+func (e *BookingConflictError) Error() string {
+	messages := make([]string, len(e.Conflicts))
+	for i, conflict := range e.Conflicts {
+		messages[i] = conflict.Message
+	}
+	return "booking conflict: " + strings.Join(messages, "; ")
+}
+
+// FreeRoomQuery asks for the rooms free for a stretch of time, either on a
+// Date, around its term's timetable and the bookings that day, or every
+// week on DayOfWeek, around the timetable of TermID (of every term if 0).
+// Rooms must seat MinCapacity, have every one of Features and, if given, be
+// in Building.
+type FreeRoomQuery struct {
+	Date        time.Time
+	DayOfWeek   string
+	TermID      uint
+	StartTime   string
+	EndTime     string
+	MinCapacity int
+	Features    string
+	Building    string
+}
+
+// RoomUtilization is how much a room is used: by the weekly timetable,
+// against the hours of the default teaching week, and by bookings. Rooms
+// named in the timetable but not registered are reported with no RoomID.
+type RoomUtilization struct {
+	RoomID        uint    `json:"room_id,omitempty"`
+	Name          string  `json:"name"`
+	Building      string  `json:"building,omitempty"`
+	Capacity      int     `json:"capacity"`
+	Registered    bool    `json:"registered"`
+	WeeklyClasses int     `json:"weekly_classes"`
+	WeeklyHours   float64 `json:"weekly_hours"`
+	Utilization   float64 `json:"utilization"` // percent of the teaching week
+	SeatFill      float64 `json:"seat_fill"`   // average percent of seats its classes fill
+	Bookings      int     `json:"bookings"`
+	BookedHours   float64 `json:"booked_hours"`
+}
+
+// UtilizationReport is the use of every room in a term's timetable, and of
+// bookings between From and To
+type UtilizationReport struct {
+	TermID    uint              `json:"term_id"`
+	From      time.Time         `json:"from"`
+	To        time.Time         `json:"to"`
+	WeekHours float64           `json:"week_hours"`
+	Rooms     []RoomUtilization `json:"rooms"`
+}
+
+// ParseBookingDate parses a YYYY-MM-DD date
+func ParseBookingDate(value string) (time.Time, error) {
+	date, err := time.Parse(bookingDateLayout, strings.TrimSpace(value))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: dates must be YYYY-MM-DD", ErrInvalidBooking)
+	}
+	return date, nil
+}
+
+// bookingDate is the day of a time, as bookings store it
+func bookingDate(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// Book reserves a room or some of a resource, refusing bookings that clash
+// with a class in the room or another booking, or that ask for more of a
+// resource than is free
+func (s *roomService) Book(booking *models.Booking) error {
+	if booking.RoomID != nil && *booking.RoomID == 0 {
+		booking.RoomID = nil
+	}
+	if booking.ResourceID != nil && *booking.ResourceID == 0 {
+		booking.ResourceID = nil
+	}
+	if (booking.RoomID == nil) == (booking.ResourceID == nil) {
+		return fmt.Errorf("%w: book either a room or a resource", ErrInvalidBooking)
+	}
+	booking.Title = strings.TrimSpace(booking.Title)
+	if booking.Title == "" {
+		return fmt.Errorf("%w: title is required", ErrInvalidBooking)
+	}
+	if booking.Purpose == "" {
+		booking.Purpose = models.BookingOther
+	}
+	if !slices.Contains([]string{models.BookingExam, models.BookingMeeting, models.BookingEvent, models.BookingOther}, booking.Purpose) {
+		return fmt.Errorf("%w: purpose must be exam, meeting, event or other", ErrInvalidBooking)
+	}
+	if booking.Date.IsZero() {
+		return fmt.Errorf("%w: date is required", ErrInvalidBooking)
+	}
+	booking.Date = bookingDate(booking.Date)
+	if booking.Date.Before(bookingDate(time.Now())) {
+		return fmt.Errorf("%w: %s has passed", ErrInvalidBooking, booking.Date.Format(bookingDateLayout))
+	}
+	iv, ok := parseInterval(booking.Date.Weekday().String(), booking.StartTime, booking.EndTime)
+	if !ok {
+		return fmt.Errorf("%w: start_time and end_time must be HH:MM times, start first", ErrInvalidBooking)
+	}
+	booking.StartTime, booking.EndTime = formatClock(iv.start), formatClock(iv.end)
+	booking.Status = models.BookingConfirmed
+
+	var check func(sameDay []models.Booking, classes []models.TimeTable) error
+	if booking.RoomID != nil {
+		room, err := s.repo.FindByID(*booking.RoomID)
+		if err != nil {
+			return ErrRoomNotFound
+		}
+		if !room.IsActive {
+			return fmt.Errorf("%w: %s is not in use", ErrInvalidBooking, room.Name)
+		}
+		if room.Capacity > 0 && booking.Attendees > room.Capacity {
+			return fmt.Errorf("%w: %s seats %d, not %d", ErrInvalidBooking, room.Name, room.Capacity, booking.Attendees)
+		}
+		booking.Quantity = 0
+
+		check = func(sameDay []models.Booking, classes []models.TimeTable) error {
+			var conflicts []BookingConflict
+			for _, class := range classes {
+				if roomKey(class.Classroom) != roomKey(room.Name) {
+					continue
+				}
+				if other, ok := slotInterval(&class); ok && other.overlaps(iv) {
+					conflicts = append(conflicts, BookingConflict{Code: BookingClassInRoom, TimetableID: class.ID,
+						Message: fmt.Sprintf("%s has %s from %s to %s", room.Name, courseLabel(&class.Course), class.StartTime, class.EndTime)})
+				}
+			}
+			for _, other := range sameDay {
+				if bookingsOverlap(booking, &other) {
+					conflicts = append(conflicts, BookingConflict{Code: BookingRoomTaken, BookingID: other.ID,
+						Message: fmt.Sprintf("%s is booked for %s from %s to %s", room.Name, other.Title, other.StartTime, other.EndTime)})
+				}
+			}
+			if len(conflicts) > 0 {
+				return &BookingConflictError{Conflicts: conflicts}
+			}
+			return nil
+		}
+	} else {
+		resource, err := s.resourceRepo.FindByID(*booking.ResourceID)
+		if err != nil {
+			return ErrResourceNotFound
+		}
+		if !resource.IsActive {
+			return fmt.Errorf("%w: %s is not in use", ErrInvalidBooking, resource.Name)
+		}
+		if booking.Quantity == 0 {
+			booking.Quantity = 1
+		}
+		if booking.Quantity < 0 || booking.Quantity > resource.Quantity {
+			return fmt.Errorf("%w: there are %d of %s", ErrInvalidBooking, resource.Quantity, resource.Name)
+		}
+		check = func(sameDay []models.Booking, _ []models.TimeTable) error {
+			taken := 0
+			var conflicts []BookingConflict
+			for _, other := range sameDay {
+				if bookingsOverlap(booking, &other) {
+					taken += other.Quantity
+					conflicts = append(conflicts, BookingConflict{Code: BookingResourceUnavailable, BookingID: other.ID,
+						Message: fmt.Sprintf("%d of %s are booked for %s from %s to %s", other.Quantity, resource.Name, other.Title, other.StartTime, other.EndTime)})
+				}
+			}
+			if taken+booking.Quantity > resource.Quantity {
+				return &BookingConflictError{Conflicts: conflicts}
+			}
+			return nil
+		}
+	}
+
+	if err := s.bookingRepo.CreateChecked(booking, check); err != nil {
+		return err
+	}
+	s.logger.WithField("booking_id", booking.ID).WithField("date", booking.Date.Format(bookingDateLayout)).
+		WithField("booked_by", booking.BookedBy).Info("Booking created")
+	return nil
+}
+
+// bookingsOverlap reports whether two bookings on the same day overlap
+func bookingsOverlap(a, b *models.Booking) bool {
+	start, _ := clockMinutes(a.StartTime)
+	end, _ := clockMinutes(a.EndTime)
+	otherStart, _ := clockMinutes(b.StartTime)
+	otherEnd, _ := clockMinutes(b.EndTime)
+	return start < otherEnd && otherStart < end
+}
+
+// classesOn returns the timetabled classes meeting on a date
+func (s *roomService) classesOn(date time.Time) ([]models.TimeTable, error) {
+	return s.timetableRepo.FindMeetingsOnDate(date)
+}
+
+func (s *roomService) GetBooking(id uint) (*models.Booking, error) {
+	booking, err := s.bookingRepo.FindByID(id)
+	if err != nil {
+		return nil, ErrBookingNotFound
+	}
+	return booking, nil
+}
+
+func (s *roomService) ListBookings(filter repository.BookingFilter, page, limit int) ([]models.Booking, int64, error) {
+	return s.bookingRepo.FindAll(filter, page, limit)
+}
+
+// CancelBooking frees what a booking reserved, keeping it on record
+func (s *roomService) CancelBooking(id, actorID uint) (*models.Booking, error) {
+	if _, err := s.bookingRepo.FindByID(id); err != nil {
+		return nil, ErrBookingNotFound
+	}
+	cancelled, err := s.bookingRepo.Cancel(id, actorID)
+	if err != nil {
+		return nil, err
+	}
+	if !cancelled {
+		return nil, ErrBookingCancelled
+	}
+	s.logger.WithField("booking_id", id).WithField("actor_id", actorID).Info("Booking cancelled")
+	return s.bookingRepo.FindByID(id)
+}
+
+// FindFreeRooms returns the active rooms matching a query that have no
+// class or booking then, smallest first
+func (s *roomService) FindFreeRooms(query FreeRoomQuery) ([]models.Room, error) {
+	day := query.DayOfWeek
+	if !query.Date.IsZero() {
+		query.Date = bookingDate(query.Date)
+		day = query.Date.Weekday().String()
+	}
+	if day == "" {
+		return nil, fmt.Errorf("%w: a date or day_of_week is required", ErrInvalidBooking)
+	}
+	iv, ok := parseInterval(day, query.StartTime, query.EndTime)
+	if !ok {
+		return nil, fmt.Errorf("%w: day_of_week must be a day of the week and start_time and end_time HH:MM times, start first", ErrInvalidBooking)
+	}
+
+	var classes []models.TimeTable
+	var bookings []models.Booking
+	var err error
+	if query.Date.IsZero() {
+		classes, err = s.timetableRepo.FindMeetingsOn(iv.day, query.TermID)
+	} else if classes, err = s.classesOn(query.Date); err == nil {
+		bookings, err = s.bookingRepo.FindConfirmedBetween(query.Date, query.Date)
+	}
+	if err != nil {
+		return nil, err
+	}
+	busyNames := map[string]bool{}
+	for _, class := range classes {
+		if other, ok := slotInterval(&class); ok && other.overlaps(iv) {
+			busyNames[roomKey(class.Classroom)] = true
+		}
+	}
+	slot := &models.Booking{StartTime: formatClock(iv.start), EndTime: formatClock(iv.end)}
+	busyIDs := map[uint]bool{}
+	for _, booking := range bookings {
+		if booking.RoomID != nil && bookingsOverlap(slot, &booking) {
+			busyIDs[*booking.RoomID] = true
+		}
+	}
+
+	rooms, err := s.repo.FindAll(true)
+	if err != nil {
+		return nil, err
+	}
+	free := []models.Room{}
+	for _, room := range rooms {
+		if busyNames[roomKey(room.Name)] || busyIDs[room.ID] {
+			continue
+		}
+		if query.MinCapacity > 0 && room.Capacity < query.MinCapacity {
+			continue
+		}
+		if query.Building != "" && !strings.EqualFold(strings.TrimSpace(query.Building), room.Building) {
+			continue
+		}
+		if room.HasFeatures(query.Features) {
+			free = append(free, room)
+		}
+	}
+	sort.SliceStable(free, func(i, j int) bool { return free[i].Capacity < free[j].Capacity })
+	return free, nil
+}
+
+// Utilization reports how much each room is used by a term's weekly
+// timetable, and by bookings between from and to, the term's dates if zero
+func (s *roomService) Utilization(termID uint, from, to time.Time) (*UtilizationReport, error) {
+	term, err := s.termRepo.FindByID(termID)
+	if err != nil {
+		return nil, fmt.Errorf("term %w", ErrTimetableMissing)
+	}
+	if from.IsZero() {
+		from = term.StartDate
+	}
+	if to.IsZero() {
+		to = term.EndDate
+	}
+	from, to = bookingDate(from), bookingDate(to)
+	if to.Before(from) {
+		return nil, fmt.Errorf("%w: from must not be after to", ErrInvalidBooking)
+	}
+
+	grid := DefaultTimetableGrid()
+	dayStart, _ := clockMinutes(grid.DayStart)
+	dayEnd, _ := clockMinutes(grid.DayEnd)
+	weekMinutes := len(grid.Days) * (dayEnd - dayStart)
+	report := &UtilizationReport{TermID: term.ID, From: from, To: to, WeekHours: float64(weekMinutes) / 60}
+
+	rooms, err := s.repo.FindAll(false)
+	if err != nil {
+		return nil, err
+	}
+	entries := map[string]*RoomUtilization{}
+	byID := map[uint]*RoomUtilization{}
+	for _, room := range rooms {
+		entry := &RoomUtilization{RoomID: room.ID, Name: room.Name, Building: room.Building, Capacity: room.Capacity, Registered: true}
+		entries[roomKey(room.Name)] = entry
+		byID[room.ID] = entry
+	}
+
+	classes, err := s.timetableRepo.FindByTermID(term.ID)
+	if err != nil {
+		return nil, err
+	}
+	enrolled, err := s.enrollmentRepo.FindTermSectionStudents(term.ID)
+	if err != nil {
+		return nil, err
+	}
+	sizes := map[uint]int{}
+	for _, row := range enrolled {
+		sizes[row.SectionID]++
+	}
+
+	minutes := map[*RoomUtilization]int{}
+	fill := map[*RoomUtilization][]float64{}
+	for _, class := range classes {
+		iv, ok := slotInterval(&class)
+		key := roomKey(class.Classroom)
+		if !ok || key == "" {
+			continue
+		}
+		entry := entries[key]
+		if entry == nil {
+			entry = &RoomUtilization{Name: strings.TrimSpace(class.Classroom)}
+			entries[key] = entry
+		}
+		entry.WeeklyClasses++
+		minutes[entry] += iv.end - iv.start
+		if entry.Capacity > 0 && class.SectionID != nil {
+			fill[entry] = append(fill[entry], float64(sizes[*class.SectionID])/float64(entry.Capacity)*100)
+		}
+	}
+
+	bookings, err := s.bookingRepo.FindConfirmedBetween(from, to)
+	if err != nil {
+		return nil, err
+	}
+	booked := map[*RoomUtilization]int{}
+	for _, booking := range bookings {
+		if booking.RoomID == nil || byID[*booking.RoomID] == nil {
+			continue
+		}
+		entry := byID[*booking.RoomID]
+		start, _ := clockMinutes(booking.StartTime)
+		end, _ := clockMinutes(booking.EndTime)
+		entry.Bookings++
+		booked[entry] += end - start
+	}
+
+	report.Rooms = make([]RoomUtilization, 0, len(entries))
+	for _, entry := range entries {
+		entry.WeeklyHours = roundTenth(float64(minutes[entry]) / 60)
+		entry.Utilization = roundTenth(float64(minutes[entry]) / float64(weekMinutes) * 100)
+		if len(fill[entry]) > 0 {
+			total := 0.0
+			for _, percent := range fill[entry] {
+				total += percent
+			}
+			entry.SeatFill = roundTenth(total / float64(len(fill[entry])))
+		}
+		entry.BookedHours = roundTenth(float64(booked[entry]) / 60)
+		report.Rooms = append(report.Rooms, *entry)
+	}
+	sort.Slice(report.Rooms, func(i, j int) bool {
+		a, b := report.Rooms[i], report.Rooms[j]
+		if a.Utilization != b.Utilization {
+			return a.Utilization > b.Utilization
+		}
+		return a.Name < b.Name
+	})
+	return report, nil
+}
+
+// roundTenth rounds to one decimal place
+func roundTenth(value float64) float64 {
+	return math.Round(value*10) / 10
+}
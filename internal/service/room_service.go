@@ -0,0 +1,201 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"school-management-system/internal/models"
+	"school-management-system/internal/repository"
+	"school-management-system/pkg/logger"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	ErrRoomNotFound     = errors.New("room not found")
+	ErrInvalidRoom      = errors.New("invalid room")
+	ErrResourceNotFound = errors.New("resource not found")
+	ErrInvalidResource  = errors.New("invalid resource")
+)
+
+// RoomService manages the registry of rooms and bookable resources, and
+// their bookings
+type RoomService interface {
+	Create(room *models.Room) error
+	GetByID(id uint) (*models.Room, error)
+	List(activeOnly bool) ([]models.Room, error)
+	Update(room *models.Room) error
+	Delete(id uint) error
+
+	CreateResource(resource *models.Resource) error
+	GetResource(id uint) (*models.Resource, error)
+	ListResources(resourceType string, roomID uint) ([]models.Resource, error)
+	UpdateResource(resource *models.Resource) error
+	DeleteResource(id uint) error
+
+	Book(booking *models.Booking) error
+	GetBooking(id uint) (*models.Booking, error)
+	ListBookings(filter repository.BookingFilter, page, limit int) ([]models.Booking, int64, error)
+	CancelBooking(id, actorID uint) (*models.Booking, error)
+	FindFreeRooms(query FreeRoomQuery) ([]models.Room, error)
+	Utilization(termID uint, from, to time.Time) (*UtilizationReport, error)
+}
+
+type roomService struct {
+	repo           repository.RoomRepository
+	resourceRepo   repository.ResourceRepository
+	bookingRepo    repository.BookingRepository
+	timetableRepo  repository.TimeTableRepository
+	termRepo       repository.AcademicTermRepository
+	enrollmentRepo repository.EnrollmentRepository
+	logger         *logrus.Logger
+}
+
+func NewRoomService(
+	repo repository.RoomRepository,
+	resourceRepo repository.ResourceRepository,
+	bookingRepo repository.BookingRepository,
+	timetableRepo repository.TimeTableRepository,
+	termRepo repository.AcademicTermRepository,
+	enrollmentRepo repository.EnrollmentRepository,
+) RoomService {
+	return &roomService{
+		repo:           repo,
+		resourceRepo:   resourceRepo,
+		bookingRepo:    bookingRepo,
+		timetableRepo:  timetableRepo,
+		termRepo:       termRepo,
+		enrollmentRepo: enrollmentRepo,
+		logger:         logger.GetLogger(),
+	}
+}
+
+func (s *roomService) Create(room *models.Room) error {
+	if err := s.validate(room); err != nil {
+		return err
+	}
+	if err := s.repo.Create(room); err != nil {
+		return err
+	}
+	s.logger.WithField("room_id", room.ID).WithField("name", room.Name).Info("Room created")
+	return nil
+}
+
+func (s *roomService) GetByID(id uint) (*models.Room, error) {
+	room, err := s.repo.FindByID(id)
+	if err != nil {
+		return nil, ErrRoomNotFound
+	}
+	return room, nil
+}
+
+func (s *roomService) List(activeOnly bool) ([]models.Room, error) {
+	return s.repo.FindAll(activeOnly)
+}
+
+func (s *roomService) Update(room *models.Room) error {
+	if err := s.validate(room); err != nil {
+		return err
+	}
+	return s.repo.Update(room)
+}
+
+// Delete removes a room from the registry. Classes already timetabled in
+// it keep its name, but a room with bookings to come is kept.
+func (s *roomService) Delete(id uint) error {
+	if _, err := s.repo.FindByID(id); err != nil {
+		return ErrRoomNotFound
+	}
+	upcoming, err := s.bookingRepo.CountUpcomingForRoom(id, bookingDate(time.Now()))
+	if err != nil {
+		return err
+	}
+	if upcoming > 0 {
+		return fmt.Errorf("%w: the room has %d bookings to come; cancel them or deactivate the room instead", ErrInvalidRoom, upcoming)
+	}
+	if err := s.repo.Delete(id); err != nil {
+		return err
+	}
+	s.logger.WithField("room_id", id).Info("Room deleted")
+	return nil
+}
+
+// validate checks a room's name is given and unused and its capacity is
+// not negative, and tidies its feature list
+func (s *roomService) validate(room *models.Room) error {
+	room.Name = strings.TrimSpace(room.Name)
+	if room.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidRoom)
+	}
+	if room.Capacity < 0 {
+		return fmt.Errorf("%w: capacity cannot be negative", ErrInvalidRoom)
+	}
+	if existing, err := s.repo.FindByName(room.Name); err == nil && existing.ID != room.ID {
+		return fmt.Errorf("%w: a room named %s already exists", ErrInvalidRoom, existing.Name)
+	}
+	room.Building = strings.TrimSpace(room.Building)
+	room.Features = strings.Join(models.ParseFeatures(room.Features), ",")
+	return nil
+}
+
+func (s *roomService) CreateResource(resource *models.Resource) error {
+	if err := s.validateResource(resource); err != nil {
+		return err
+	}
+	if err := s.resourceRepo.Create(resource); err != nil {
+		return err
+	}
+	s.logger.WithField("resource_id", resource.ID).WithField("name", resource.Name).Info("Resource created")
+	return nil
+}
+
+func (s *roomService) GetResource(id uint) (*models.Resource, error) {
+	resource, err := s.resourceRepo.FindByID(id)
+	if err != nil {
+		return nil, ErrResourceNotFound
+	}
+	return resource, nil
+}
+
+func (s *roomService) ListResources(resourceType string, roomID uint) ([]models.Resource, error) {
+	return s.resourceRepo.FindAll(resourceType, roomID)
+}
+
+func (s *roomService) UpdateResource(resource *models.Resource) error {
+	if err := s.validateResource(resource); err != nil {
+		return err
+	}
+	return s.resourceRepo.Update(resource)
+}
+
+func (s *roomService) DeleteResource(id uint) error {
+	if _, err := s.resourceRepo.FindByID(id); err != nil {
+		return ErrResourceNotFound
+	}
+	return s.resourceRepo.Delete(id)
+}
+
+// validateResource checks a resource's name is given and unused, that
+// there is at least one of it and that the room it is kept in exists
+func (s *roomService) validateResource(resource *models.Resource) error {
+	resource.Name = strings.TrimSpace(resource.Name)
+	resource.Type = strings.ToLower(strings.TrimSpace(resource.Type))
+	if resource.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidResource)
+	}
+	if resource.Quantity < 1 {
+		return fmt.Errorf("%w: quantity must be at least 1", ErrInvalidResource)
+	}
+	if existing, err := s.resourceRepo.FindByName(resource.Name); err == nil && existing.ID != resource.ID {
+		return fmt.Errorf("%w: a resource named %s already exists", ErrInvalidResource, existing.Name)
+	}
+	if resource.RoomID != nil {
+		if *resource.RoomID == 0 {
+			resource.RoomID = nil
+		} else if _, err := s.repo.FindByID(*resource.RoomID); err != nil {
+			return fmt.Errorf("%w: room %d does not exist", ErrInvalidResource, *resource.RoomID)
+		}
+	}
+	return nil
+}
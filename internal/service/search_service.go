@@ -27,32 +27,11 @@ func NewSearchService(
 	}
 }
 
-// SearchAnnouncementsAdvanced searches announcements with filters
-func (s *SearchService) SearchAnnouncementsAdvanced(query string, audience string, priority string, page int, limit int) ([]models.Announcement, int64, error) {
-	db := database.DB
-	var announcements []models.Announcement
-	var total int64
-
-	q := db.Where("is_active = ?", true)
-
-	if query != "" {
-		q = q.Where("title LIKE ? OR content LIKE ?", "%"+query+"%", "%"+query+"%")
-	}
-	if audience != "" {
-		q = q.Where("audience = ?", audience)
-	}
-	if priority != "" {
-		q = q.Where("priority = ?", priority)
-	}
-
-	q.Model(&models.Announcement{}).Count(&total)
-
-	offset := (page - 1) * limit
-	if err := q.Offset(offset).Limit(limit).Find(&announcements).Error; err != nil {
-		return nil, 0, err
-	}
-
-	return announcements, total, nil
+// SearchAnnouncementsAdvanced searches announcements with filters. A
+// non-zero recipientID limits the results to the live announcements that
+// user received.
+func (s *SearchService) SearchAnnouncementsAdvanced(recipientID uint, query string, audience string, priority string, page int, limit int) ([]models.Announcement, int64, error) {
+	return s.announcementRepo.Search(recipientID, time.Now().Unix(), query, audience, priority, page, limit)
 }
 
 // SearchPayments searches payments with filters
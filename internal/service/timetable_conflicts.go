@@ -0,0 +1,175 @@
+package service
+
+import (
+	"fmt"
+	"school-management-system/internal/models"
+	"sort"
+	"strings"
+)
+
+// Timetable conflict codes
+const (
+	ConflictInvalidTime         = "invalid_time"
+	ConflictTeacherDoubleBooked = "teacher_double_booked"
+	ConflictRoomDoubleBooked    = "room_double_booked"
+	ConflictRoomTooSmall        = "room_too_small"
+	ConflictRoomMissingFeatures = "room_missing_features"
+	ConflictTeacherUnavailable  = "teacher_unavailable"
+	ConflictStudentClash        = "student_clash"
+)
+
+// TimetableConflict is one rule a timetable breaks. TimetableIDs are the
+// classes involved, where they are saved; SectionIDs their sections.
+type TimetableConflict struct {
+	Code         string `json:"code"`
+	Message      string `json:"message"`
+	TimetableIDs []uint `json:"timetable_ids,omitempty"`
+	SectionIDs   []uint `json:"section_ids,omitempty"`
+	Students     int    `json:"students,omitempty"`
+}
+
+// involves reports whether a conflict concerns any of the given sections
+func (c *TimetableConflict) involves(sectionIDs map[uint]bool) bool {
+	for _, id := range c.SectionIDs {
+		if sectionIDs[id] {
+			return true
+		}
+	}
+	return false
+}
+
+// FindTimetableConflicts checks classes against the rules a generated
+// timetable keeps: valid times, nobody double-booked, teachers teaching
+// only when available, and rooms that seat their class and have what it
+// needs. Sections, rooms, availability and clashes come from the problem;
+// its grid and fixed classes are not used.
+func FindTimetableConflicts(slots []models.TimeTable, problem *TimetableProblem) []TimetableConflict {
+	conflicts := []TimetableConflict{}
+	sections := map[uint]*TimetableSection{}
+	for i := range problem.Sections {
+		sections[problem.Sections[i].SectionID] = &problem.Sections[i]
+	}
+	rooms := map[string]*models.Room{}
+	for i := range problem.Rooms {
+		rooms[roomKey(problem.Rooms[i].Name)] = &problem.Rooms[i]
+	}
+	label := func(slot *models.TimeTable) string {
+		if slot.SectionID != nil && sections[*slot.SectionID] != nil {
+			return sections[*slot.SectionID].Label
+		}
+		if slot.Course.CourseCode != "" {
+			return slot.Course.CourseCode
+		}
+		return fmt.Sprintf("course %d", slot.CourseID)
+	}
+	when := func(slot *models.TimeTable) string {
+		return fmt.Sprintf("%s %s-%s", slot.DayOfWeek, slot.StartTime, slot.EndTime)
+	}
+	sectionIDs := func(slots ...*models.TimeTable) []uint {
+		var ids []uint
+		for _, slot := range slots {
+			if slot.SectionID != nil {
+				ids = append(ids, *slot.SectionID)
+			}
+		}
+		return ids
+	}
+	timetableIDs := func(slots ...*models.TimeTable) []uint {
+		var ids []uint
+		for _, slot := range slots {
+			if slot.ID != 0 {
+				ids = append(ids, slot.ID)
+			}
+		}
+		return ids
+	}
+
+	var valid []*models.TimeTable
+	intervals := map[*models.TimeTable]interval{}
+	for i := range slots {
+		slot := &slots[i]
+		if !slot.IsActive {
+			continue
+		}
+		iv, ok := slotInterval(slot)
+		if !ok {
+			conflicts = append(conflicts, TimetableConflict{Code: ConflictInvalidTime,
+				Message:      fmt.Sprintf("%s meets at %q, which is not a day with an HH:MM start before its end", label(slot), when(slot)),
+				TimetableIDs: timetableIDs(slot), SectionIDs: sectionIDs(slot)})
+			continue
+		}
+		valid = append(valid, slot)
+		intervals[slot] = iv
+	}
+
+	for _, slot := range valid {
+		iv := intervals[slot]
+		var section *TimetableSection
+		if slot.SectionID != nil {
+			section = sections[*slot.SectionID]
+		}
+		if room := rooms[roomKey(slot.Classroom)]; room != nil && section != nil {
+			if room.Capacity > 0 && section.Size > room.Capacity {
+				conflicts = append(conflicts, TimetableConflict{Code: ConflictRoomTooSmall,
+					Message:      fmt.Sprintf("%s has %d students but %s seats %d", label(slot), section.Size, room.Name, room.Capacity),
+					TimetableIDs: timetableIDs(slot), SectionIDs: sectionIDs(slot)})
+			}
+			if !room.HasFeatures(section.Features) {
+				conflicts = append(conflicts, TimetableConflict{Code: ConflictRoomMissingFeatures,
+					Message:      fmt.Sprintf("%s needs %s, which %s lacks", label(slot), strings.Join(models.ParseFeatures(section.Features), ", "), room.Name),
+					TimetableIDs: timetableIDs(slot), SectionIDs: sectionIDs(slot)})
+			}
+		}
+		if slot.TeacherID != 0 && !withinAvailability(problem.Availability[slot.TeacherID], iv) {
+			conflicts = append(conflicts, TimetableConflict{Code: ConflictTeacherUnavailable,
+				Message:      fmt.Sprintf("%s on %s is outside the teacher's availability", label(slot), when(slot)),
+				TimetableIDs: timetableIDs(slot), SectionIDs: sectionIDs(slot)})
+		}
+	}
+
+	sort.SliceStable(valid, func(i, j int) bool {
+		a, b := intervals[valid[i]], intervals[valid[j]]
+		if a.day != b.day {
+			return weekdayIndex(a.day) < weekdayIndex(b.day)
+		}
+		return a.start < b.start
+	})
+	for i, a := range valid {
+		for _, b := range valid[i+1:] {
+			ia, ib := intervals[a], intervals[b]
+			if ib.day != ia.day || ib.start >= ia.end {
+				continue
+			}
+			if a.TermID != nil && b.TermID != nil && *a.TermID != *b.TermID {
+				continue
+			}
+			pair := fmt.Sprintf("%s (%s) and %s (%s)", label(a), when(a), label(b), when(b))
+			if a.TeacherID != 0 && a.TeacherID == b.TeacherID {
+				conflicts = append(conflicts, TimetableConflict{Code: ConflictTeacherDoubleBooked,
+					Message:      "the same teacher teaches " + pair,
+					TimetableIDs: timetableIDs(a, b), SectionIDs: sectionIDs(a, b)})
+			}
+			if key := roomKey(a.Classroom); key != "" && key == roomKey(b.Classroom) {
+				conflicts = append(conflicts, TimetableConflict{Code: ConflictRoomDoubleBooked,
+					Message:      fmt.Sprintf("%s holds %s", a.Classroom, pair),
+					TimetableIDs: timetableIDs(a, b), SectionIDs: sectionIDs(a, b)})
+			}
+			if a.SectionID != nil && b.SectionID != nil && *a.SectionID != *b.SectionID {
+				x, y := *a.SectionID, *b.SectionID
+				if x > y {
+					x, y = y, x
+				}
+				if students, ok := problem.Clashes[[2]uint{x, y}]; ok {
+					message := fmt.Sprintf("%d students take both %s", students, pair)
+					if students == 0 {
+						message = "sections grouped together meet at once: " + pair
+					}
+					conflicts = append(conflicts, TimetableConflict{Code: ConflictStudentClash,
+						Message:      message,
+						TimetableIDs: timetableIDs(a, b), SectionIDs: sectionIDs(a, b), Students: students})
+				}
+			}
+		}
+	}
+	return conflicts
+}
@@ -0,0 +1,394 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"school-management-system/internal/models"
+	"school-management-system/internal/repository"
+	"school-management-system/pkg/logger"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	ErrTimetableDraftClosed     = errors.New("timetable draft has already been committed or discarded")
+	ErrTimetableDraftIncomplete = errors.New("timetable draft leaves classes unplaced")
+)
+
+// GenerateTimetableOptions is what to generate a timetable for: the
+// sections of a term, all of them when SectionIDs is empty, in a weekly
+// grid. Each of Groups is a set of sections that must not meet at the same
+// time, such as the required courses of a year group, on top of those
+// sharing students.
+type GenerateTimetableOptions struct {
+	TermID     uint          `json:"term_id"`
+	SectionIDs []uint        `json:"section_ids,omitempty"`
+	Grid       TimetableGrid `json:"grid"`
+	Groups     [][]uint      `json:"groups,omitempty"`
+}
+
+// TimetablePreview is a generated timetable draft with how it scored and
+// the classes it could not place
+type TimetablePreview struct {
+	Draft    *models.TimetableDraft   `json:"draft"`
+	Options  GenerateTimetableOptions `json:"options"`
+	Score    TimetableScore           `json:"score"`
+	Unplaced []UnplacedSession        `json:"unplaced"`
+}
+
+// timetableDraftReport is what a draft keeps of its solution besides its
+// slots
+type timetableDraftReport struct {
+	Score    TimetableScore    `json:"score"`
+	Unplaced []UnplacedSession `json:"unplaced"`
+}
+
+// TimetableGeneratorService generates clash-free weekly timetables for a
+// term's sections as drafts to review, and commits them to the timetable
+type TimetableGeneratorService interface {
+	Generate(options GenerateTimetableOptions, actorID uint) (*TimetablePreview, error)
+	GetDraft(id uint) (*TimetablePreview, error)
+	Commit(id uint, allowPartial bool, actorID uint) (*TimetablePreview, error)
+	Discard(id uint) error
+	Conflicts(termID uint) ([]TimetableConflict, error)
+}
+
+type timetableGeneratorService struct {
+	draftRepo        repository.TimetableDraftRepository
+	timetableRepo    repository.TimeTableRepository
+	termRepo         repository.AcademicTermRepository
+	sectionRepo      repository.CourseSectionRepository
+	enrollmentRepo   repository.EnrollmentRepository
+	roomRepo         repository.RoomRepository
+	availabilityRepo repository.TeacherAvailabilityRepository
+	logger           *logrus.Logger
+}
+
+func NewTimetableGeneratorService(
+	draftRepo repository.TimetableDraftRepository,
+	timetableRepo repository.TimeTableRepository,
+	termRepo repository.AcademicTermRepository,
+	sectionRepo repository.CourseSectionRepository,
+	enrollmentRepo repository.EnrollmentRepository,
+	roomRepo repository.RoomRepository,
+	availabilityRepo repository.TeacherAvailabilityRepository,
+) TimetableGeneratorService {
+	return &timetableGeneratorService{
+		draftRepo:        draftRepo,
+		timetableRepo:    timetableRepo,
+		termRepo:         termRepo,
+		sectionRepo:      sectionRepo,
+		enrollmentRepo:   enrollmentRepo,
+		roomRepo:         roomRepo,
+		availabilityRepo: availabilityRepo,
+		logger:           logger.GetLogger(),
+	}
+}
+
+// Generate solves a timetable for the options' sections around the rest of
+// the term's timetable, and saves it as a draft to preview
+func (s *timetableGeneratorService) Generate(options GenerateTimetableOptions, actorID uint) (*TimetablePreview, error) {
+	defaults := DefaultTimetableGrid()
+	if len(options.Grid.Days) == 0 {
+		options.Grid.Days = defaults.Days
+	}
+	if options.Grid.DayStart == "" {
+		options.Grid.DayStart = defaults.DayStart
+	}
+	if options.Grid.DayEnd == "" {
+		options.Grid.DayEnd = defaults.DayEnd
+	}
+	if options.Grid.PeriodMinutes == 0 {
+		options.Grid.PeriodMinutes = defaults.PeriodMinutes
+	}
+	problem, err := s.buildProblem(&options)
+	if err != nil {
+		return nil, err
+	}
+	if len(problem.Sections) == 0 {
+		return nil, fmt.Errorf("%w: the term has no sections to schedule", ErrInvalidTimetable)
+	}
+
+	solution, err := SolveTimetable(problem)
+	if err != nil {
+		return nil, err
+	}
+	report := timetableDraftReport{Score: solution.Score, Unplaced: solution.Unplaced}
+	if report.Unplaced == nil {
+		report.Unplaced = []UnplacedSession{}
+	}
+	optionsJSON, _ := json.Marshal(options)
+	reportJSON, _ := json.Marshal(report)
+
+	draft := &models.TimetableDraft{
+		TermID:    options.TermID,
+		Status:    models.TimetableDraftPreview,
+		Score:     solution.Score.Total,
+		Options:   string(optionsJSON),
+		Report:    string(reportJSON),
+		CreatedBy: actorID,
+	}
+	for _, slot := range solution.Slots {
+		draft.Slots = append(draft.Slots, models.TimetableDraftSlot{
+			SectionID: *slot.SectionID,
+			CourseID:  slot.CourseID,
+			TeacherID: slot.TeacherID,
+			DayOfWeek: slot.DayOfWeek,
+			StartTime: slot.StartTime,
+			EndTime:   slot.EndTime,
+			Classroom: slot.Classroom,
+		})
+	}
+	if err := s.draftRepo.Create(draft); err != nil {
+		return nil, err
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"draft_id": draft.ID,
+		"term_id":  draft.TermID,
+		"slots":    len(draft.Slots),
+		"unplaced": len(report.Unplaced),
+		"score":    draft.Score,
+	}).Info("Timetable generated")
+	return &TimetablePreview{Draft: draft, Options: options, Score: report.Score, Unplaced: report.Unplaced}, nil
+}
+
+func (s *timetableGeneratorService) GetDraft(id uint) (*TimetablePreview, error) {
+	draft, err := s.draftRepo.FindByID(id)
+	if err != nil {
+		return nil, fmt.Errorf("timetable draft %w", ErrTimetableMissing)
+	}
+	preview := &TimetablePreview{Draft: draft}
+	var report timetableDraftReport
+	if err := json.Unmarshal([]byte(draft.Options), &preview.Options); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(draft.Report), &report); err != nil {
+		return nil, err
+	}
+	preview.Score, preview.Unplaced = report.Score, report.Unplaced
+	return preview, nil
+}
+
+// Commit replaces the timetable of the draft's sections with the draft.
+// A draft that leaves classes unplaced is only committed with allowPartial,
+// and none is if the timetable has changed since so that it now clashes.
+func (s *timetableGeneratorService) Commit(id uint, allowPartial bool, actorID uint) (*TimetablePreview, error) {
+	preview, err := s.GetDraft(id)
+	if err != nil {
+		return nil, err
+	}
+	draft := preview.Draft
+	if draft.Status != models.TimetableDraftPreview {
+		return nil, ErrTimetableDraftClosed
+	}
+	if len(preview.Unplaced) > 0 && !allowPartial {
+		return nil, fmt.Errorf("%w: %d sections have classes left to place", ErrTimetableDraftIncomplete, len(preview.Unplaced))
+	}
+
+	options := preview.Options
+	problem, err := s.buildProblem(&options)
+	if err != nil {
+		return nil, err
+	}
+	termID := draft.TermID
+	drafted := map[uint]bool{}
+	for _, id := range options.SectionIDs {
+		drafted[id] = true
+	}
+	var slots []models.TimeTable
+	for _, slot := range draft.Slots {
+		sectionID := slot.SectionID
+		slots = append(slots, models.TimeTable{
+			CourseID:  slot.CourseID,
+			SectionID: &sectionID,
+			TermID:    &termID,
+			TeacherID: slot.TeacherID,
+			DayOfWeek: slot.DayOfWeek,
+			StartTime: slot.StartTime,
+			EndTime:   slot.EndTime,
+			Classroom: slot.Classroom,
+			IsActive:  true,
+		})
+	}
+
+	var conflicts []TimetableConflict
+	for _, conflict := range FindTimetableConflicts(append(append([]models.TimeTable{}, problem.Fixed...), slots...), problem) {
+		if conflict.involves(drafted) {
+			conflicts = append(conflicts, conflict)
+		}
+	}
+	if len(conflicts) > 0 {
+		return nil, &TimetableConflictError{Conflicts: conflicts}
+	}
+
+	committed, err := s.draftRepo.Commit(draft, options.SectionIDs, slots, time.Now().Unix())
+	if err != nil {
+		return nil, err
+	}
+	if !committed {
+		return nil, ErrTimetableDraftClosed
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"draft_id": draft.ID,
+		"term_id":  draft.TermID,
+		"sections": len(options.SectionIDs),
+		"slots":    len(slots),
+		"actor_id": actorID,
+	}).Info("Timetable draft committed")
+	return preview, nil
+}
+
+func (s *timetableGeneratorService) Discard(id uint) error {
+	if _, err := s.draftRepo.FindByID(id); err != nil {
+		return fmt.Errorf("timetable draft %w", ErrTimetableMissing)
+	}
+	discarded, err := s.draftRepo.Discard(id)
+	if err != nil {
+		return err
+	}
+	if !discarded {
+		return ErrTimetableDraftClosed
+	}
+	return nil
+}
+
+// Conflicts checks a term's timetable, however it was made, against every
+// rule a generated one keeps
+func (s *timetableGeneratorService) Conflicts(termID uint) ([]TimetableConflict, error) {
+	options := GenerateTimetableOptions{TermID: termID}
+	problem, err := s.buildProblem(&options)
+	if err != nil {
+		return nil, err
+	}
+	slots, err := s.timetableRepo.FindByTermID(termID)
+	if err != nil {
+		return nil, err
+	}
+	return FindTimetableConflicts(slots, problem), nil
+}
+
+// buildProblem gathers what a timetable for the options is generated from,
+// resolving the options' sections to those it covers
+func (s *timetableGeneratorService) buildProblem(options *GenerateTimetableOptions) (*TimetableProblem, error) {
+	term, err := s.termRepo.FindByID(options.TermID)
+	if err != nil {
+		return nil, fmt.Errorf("term %w", ErrTimetableMissing)
+	}
+	sections, err := s.sectionRepo.FindScheduledByTermID(term.ID)
+	if err != nil {
+		return nil, err
+	}
+	if len(options.SectionIDs) > 0 {
+		byID := map[uint]models.CourseSection{}
+		for _, section := range sections {
+			byID[section.ID] = section
+		}
+		var chosen []models.CourseSection
+		seen := map[uint]bool{}
+		for _, id := range options.SectionIDs {
+			section, ok := byID[id]
+			if !ok {
+				return nil, fmt.Errorf("section %d %w among the scheduled sections of %s", id, ErrTimetableMissing, term.Name)
+			}
+			if !seen[id] {
+				seen[id] = true
+				chosen = append(chosen, section)
+			}
+		}
+		sections = chosen
+	}
+	options.SectionIDs = make([]uint, len(sections))
+	for i, section := range sections {
+		options.SectionIDs[i] = section.ID
+	}
+
+	period := options.Grid.PeriodMinutes
+	if period <= 0 {
+		period = DefaultTimetableGrid().PeriodMinutes
+	}
+	enrolled, err := s.enrollmentRepo.FindTermSectionStudents(term.ID)
+	if err != nil {
+		return nil, err
+	}
+	sizes := map[uint]int{}
+	taking := map[uint][]uint{}
+	for _, row := range enrolled {
+		sizes[row.SectionID]++
+		taking[row.StudentID] = append(taking[row.StudentID], row.SectionID)
+	}
+
+	problem := &TimetableProblem{Grid: options.Grid, Clashes: map[[2]uint]int{}}
+	scheduled := map[uint]bool{}
+	var teacherIDs []uint
+	for _, section := range sections {
+		hours := section.WeeklyHours
+		if hours == 0 {
+			hours = section.Course.CreditHours
+		}
+		scheduled[section.ID] = true
+		teacherIDs = append(teacherIDs, section.TeacherID)
+		problem.Sections = append(problem.Sections, TimetableSection{
+			SectionID: section.ID,
+			CourseID:  section.CourseID,
+			TeacherID: section.TeacherID,
+			Label:     courseLabel(&section.Course) + "-" + section.SectionCode,
+			Sessions:  (hours*60 + period - 1) / period,
+			Size:      max(section.Capacity, sizes[section.ID]),
+			Features:  section.RoomFeatures,
+			Room:      section.Room,
+		})
+	}
+
+	// Sections sharing students, and those grouped together, must not clash
+	addClash := func(a, b uint, students int) {
+		if a == b {
+			return
+		}
+		if a > b {
+			a, b = b, a
+		}
+		problem.Clashes[[2]uint{a, b}] += students
+	}
+	for _, sectionIDs := range taking {
+		for i, a := range sectionIDs {
+			for _, b := range sectionIDs[i+1:] {
+				addClash(a, b, 1)
+			}
+		}
+	}
+	for _, group := range options.Groups {
+		for i, a := range group {
+			for _, b := range group[i+1:] {
+				addClash(a, b, 0)
+			}
+		}
+	}
+
+	existing, err := s.timetableRepo.FindByTermID(term.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, slot := range existing {
+		teacherIDs = append(teacherIDs, slot.TeacherID)
+		if slot.SectionID == nil || !scheduled[*slot.SectionID] {
+			problem.Fixed = append(problem.Fixed, slot)
+		}
+	}
+
+	if problem.Rooms, err = s.roomRepo.FindAll(true); err != nil {
+		return nil, err
+	}
+	windows, err := s.availabilityRepo.FindByTeacherIDs(teacherIDs)
+	if err != nil {
+		return nil, err
+	}
+	problem.Availability = map[uint][]models.TeacherAvailability{}
+	for _, window := range windows {
+		problem.Availability[window.TeacherID] = append(problem.Availability[window.TeacherID], window)
+	}
+	return problem, nil
+}
@@ -1,30 +1,71 @@
 package service
 
 import (
+	"errors"
+	"fmt"
 	"school-management-system/internal/models"
 	"school-management-system/internal/repository"
+	"strings"
 )
 
+var (
+	ErrInvalidTimetable = errors.New("invalid timetable")
+	ErrTimetableMissing = errors.New("not found")
+)
+
+// TimetableConflictError lists the classes a timetable change would clash
+// with
+type TimetableConflictError struct {
+	Conflicts []TimetableConflict `json:"conflicts"`
+}
+
+func (e *TimetableConflictError) Error() string {
+	messages := make([]string, len(e.Conflicts))
+	for i, conflict := range e.Conflicts {
+		messages[i] = conflict.Message
+	}
+	return "timetable conflict: " + strings.Join(messages, "; ")
+}
+
 type TimeTableService interface {
 	Create(timetable *models.TimeTable) error
 	GetByID(id uint) (*models.TimeTable, error)
 	GetByCourseID(courseID uint) ([]models.TimeTable, error)
+	GetBySectionID(sectionID uint) ([]models.TimeTable, error)
 	GetByTeacherID(teacherID uint) ([]models.TimeTable, error)
 	GetByDayOfWeek(dayOfWeek string) ([]models.TimeTable, error)
 	GetAll() ([]models.TimeTable, error)
 	Update(timetable *models.TimeTable) error
 	Delete(id uint) error
+	GetTeacherAvailability(teacherID uint) ([]models.TeacherAvailability, error)
+	SetTeacherAvailability(teacherID uint, windows []models.TeacherAvailability) error
 }
 
 type timetableService struct {
-	repo repository.TimeTableRepository
+	repo             repository.TimeTableRepository
+	sectionRepo      repository.CourseSectionRepository
+	availabilityRepo repository.TeacherAvailabilityRepository
+	teacherRepo      repository.TeacherRepository
 }
 
-func NewTimeTableService(repo repository.TimeTableRepository) TimeTableService {
-	return &timetableService{repo: repo}
+func NewTimeTableService(
+	repo repository.TimeTableRepository,
+	sectionRepo repository.CourseSectionRepository,
+	availabilityRepo repository.TeacherAvailabilityRepository,
+	teacherRepo repository.TeacherRepository,
+) TimeTableService {
+	return &timetableService{
+		repo:             repo,
+		sectionRepo:      sectionRepo,
+		availabilityRepo: availabilityRepo,
+		teacherRepo:      teacherRepo,
+	}
 }
 
 func (s *timetableService) Create(timetable *models.TimeTable) error {
+	if err := s.check(timetable); err != nil {
+		return err
+	}
 	return s.repo.Create(timetable)
 }
 
@@ -36,6 +77,10 @@ func (s *timetableService) GetByCourseID(courseID uint) ([]models.TimeTable, err
 	return s.repo.FindByCourseID(courseID)
 }
 
+func (s *timetableService) GetBySectionID(sectionID uint) ([]models.TimeTable, error) {
+	return s.repo.FindBySectionID(sectionID)
+}
+
 func (s *timetableService) GetByTeacherID(teacherID uint) ([]models.TimeTable, error) {
 	return s.repo.FindByTeacherID(teacherID)
 }
@@ -49,9 +94,88 @@ func (s *timetableService) GetAll() ([]models.TimeTable, error) {
 }
 
 func (s *timetableService) Update(timetable *models.TimeTable) error {
+	if err := s.check(timetable); err != nil {
+		return err
+	}
 	return s.repo.Update(timetable)
 }
 
 func (s *timetableService) Delete(id uint) error {
 	return s.repo.Delete(id)
 }
+
+// check validates a class's day and times, writing them in their usual
+// form, and makes sure an active class's teacher and classroom are not
+// already booked then
+func (s *timetableService) check(timetable *models.TimeTable) error {
+	iv, ok := slotInterval(timetable)
+	if !ok {
+		return fmt.Errorf("%w: day_of_week must be a day of the week and start_time and end_time HH:MM times, start first", ErrInvalidTimetable)
+	}
+	timetable.DayOfWeek = iv.day
+	timetable.StartTime = formatClock(iv.start)
+	timetable.EndTime = formatClock(iv.end)
+	if !timetable.IsActive {
+		return nil
+	}
+
+	candidate := *timetable
+	if timetable.SectionID != nil && *timetable.SectionID != 0 {
+		section, err := s.sectionRepo.FindByID(*timetable.SectionID)
+		if err != nil {
+			return fmt.Errorf("section %w", ErrTimetableMissing)
+		}
+		termID := section.TermID
+		candidate.TermID = &termID
+	}
+	others, err := s.repo.FindAll()
+	if err != nil {
+		return err
+	}
+
+	var conflicts []TimetableConflict
+	when := fmt.Sprintf("%s %s-%s", candidate.DayOfWeek, candidate.StartTime, candidate.EndTime)
+	for i := range others {
+		other := &others[i]
+		if other.ID == timetable.ID || !other.IsActive || !slotsOverlap(&candidate, other) {
+			continue
+		}
+		ids := []uint{other.ID}
+		if candidate.TeacherID != 0 && other.TeacherID == candidate.TeacherID {
+			conflicts = append(conflicts, TimetableConflict{Code: ConflictTeacherDoubleBooked,
+				Message:      fmt.Sprintf("the teacher already teaches %s at %s %s-%s", courseLabel(&other.Course), other.DayOfWeek, other.StartTime, other.EndTime),
+				TimetableIDs: ids})
+		}
+		if key := roomKey(candidate.Classroom); key != "" && key == roomKey(other.Classroom) {
+			conflicts = append(conflicts, TimetableConflict{Code: ConflictRoomDoubleBooked,
+				Message:      fmt.Sprintf("%s already holds %s during %s", other.Classroom, courseLabel(&other.Course), when),
+				TimetableIDs: ids})
+		}
+	}
+	if len(conflicts) > 0 {
+		return &TimetableConflictError{Conflicts: conflicts}
+	}
+	return nil
+}
+
+func (s *timetableService) GetTeacherAvailability(teacherID uint) ([]models.TeacherAvailability, error) {
+	return s.availabilityRepo.FindByTeacherID(teacherID)
+}
+
+// SetTeacherAvailability replaces the times a teacher can teach. A teacher
+// without any is available all week.
+func (s *timetableService) SetTeacherAvailability(teacherID uint, windows []models.TeacherAvailability) error {
+	if _, err := s.teacherRepo.GetByID(teacherID); err != nil {
+		return fmt.Errorf("teacher %w", ErrTimetableMissing)
+	}
+	for i := range windows {
+		iv, ok := availabilityInterval(&windows[i])
+		if !ok {
+			return fmt.Errorf("%w: availability %d must be a day of the week and HH:MM times, start first", ErrInvalidTimetable, i+1)
+		}
+		windows[i].DayOfWeek = iv.day
+		windows[i].StartTime = formatClock(iv.start)
+		windows[i].EndTime = formatClock(iv.end)
+	}
+	return s.availabilityRepo.Replace(teacherID, windows)
+}
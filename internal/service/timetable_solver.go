@@ -0,0 +1,633 @@
+package service
+
+import (
+	"fmt"
+	"school-management-system/internal/models"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Reasons a generated timetable leaves a section's classes unplaced
+const (
+	UnplacedNoWeeklyHours      = "no_weekly_hours"
+	UnplacedNoSuitableRoom     = "no_suitable_room"
+	UnplacedTeacherUnavailable = "teacher_unavailable"
+	UnplacedNoFreePeriod       = "no_free_period"
+)
+
+// Soft constraint weights of a TimetableScore
+const (
+	sameDayRepeatPenalty   = 10
+	teacherGapPenalty      = 3
+	wastedSeatsPerPenalty  = 10
+	defaultSolverStepLimit = 20000
+)
+
+// TimetableGrid is the week a timetable is generated in: the teaching days,
+// and the periods of PeriodMinutes each day is divided into between
+// DayStart and DayEnd
+type TimetableGrid struct {
+	Days          []string `json:"days"`
+	DayStart      string   `json:"day_start"`
+	DayEnd        string   `json:"day_end"`
+	PeriodMinutes int      `json:"period_minutes"`
+}
+
+// DefaultTimetableGrid is Monday to Friday, 08:00 to 16:00, in hours
+func DefaultTimetableGrid() TimetableGrid {
+	return TimetableGrid{
+		Days:          []string{"Monday", "Tuesday", "Wednesday", "Thursday", "Friday"},
+		DayStart:      "08:00",
+		DayEnd:        "16:00",
+		PeriodMinutes: 60,
+	}
+}
+
+// periods returns the grid's days, normalised, and the start of each
+// period in minutes after midnight
+func (g TimetableGrid) periods() ([]string, []int, error) {
+	if len(g.Days) == 0 {
+		return nil, nil, fmt.Errorf("%w: the grid needs at least one day", ErrInvalidTimetable)
+	}
+	days := make([]string, len(g.Days))
+	for i, day := range g.Days {
+		normalized, ok := normalizeWeekday(day)
+		if !ok {
+			return nil, nil, fmt.Errorf("%w: %q is not a day of the week", ErrInvalidTimetable, day)
+		}
+		days[i] = normalized
+	}
+	start, ok1 := clockMinutes(g.DayStart)
+	end, ok2 := clockMinutes(g.DayEnd)
+	if !ok1 || !ok2 || end <= start {
+		return nil, nil, fmt.Errorf("%w: the day must start and end at HH:MM times, start first", ErrInvalidTimetable)
+	}
+	if g.PeriodMinutes < 15 || g.PeriodMinutes > end-start {
+		return nil, nil, fmt.Errorf("%w: periods must be at least 15 minutes and fit in the day", ErrInvalidTimetable)
+	}
+	var starts []int
+	for at := start; at+g.PeriodMinutes <= end; at += g.PeriodMinutes {
+		starts = append(starts, at)
+	}
+	return days, starts, nil
+}
+
+// TimetableSection is a section to place Sessions periods a week, for Size
+// students, in a room with every one of Features. A Room pins it to that
+// room.
+type TimetableSection struct {
+	SectionID uint
+	CourseID  uint
+	TeacherID uint
+	Label     string
+	Sessions  int
+	Size      int
+	Features  string
+	Room      string
+}
+
+// TimetableProblem is what a timetable is generated from. Fixed classes
+// stay where they are and are worked around. Clashes counts the students
+// shared by two sections, lower section ID first; sections that share
+// students never meet at the same time.
+type TimetableProblem struct {
+	Grid         TimetableGrid
+	Sections     []TimetableSection
+	Rooms        []models.Room
+	Availability map[uint][]models.TeacherAvailability // by teacher
+	Fixed        []models.TimeTable
+	Clashes      map[[2]uint]int
+	StepLimit    int // search steps before settling for a greedy timetable
+}
+
+// UnplacedSession is a section's classes a timetable could not place
+type UnplacedSession struct {
+	SectionID uint   `json:"section_id"`
+	Label     string `json:"label"`
+	Sessions  int    `json:"sessions"`
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+}
+
+// TimetableScore totals the soft constraints a timetable breaks; lower is
+// better
+type TimetableScore struct {
+	Total          int `json:"total"`
+	SameDayRepeats int `json:"same_day_repeats"` // classes of a section on a day it already meets
+	TeacherGaps    int `json:"teacher_gaps"`     // idle periods between a teacher's classes in a day
+	WastedSeats    int `json:"wasted_seats"`     // empty seats in rooms bigger than their class
+}
+
+// TimetableSolution is a generated timetable
+type TimetableSolution struct {
+	Slots    []models.TimeTable
+	Unplaced []UnplacedSession
+	Score    TimetableScore
+}
+
+// SolveTimetable places every class of the problem's sections so that no
+// teacher, room or student is in two places at once, teachers teach only
+// when available, and rooms seat their class and have the features it
+// needs. Among the timetables it tries it prefers sections meeting on
+// different days, teachers without gaps and rooms that fit their class.
+// If it cannot place everything it places what it can and reports the
+// rest.
+func SolveTimetable(problem *TimetableProblem) (*TimetableSolution, error) {
+	days, starts, err := problem.Grid.periods()
+	if err != nil {
+		return nil, err
+	}
+	solver := newTimetableSolver(problem, days, starts)
+
+	var unplaced []UnplacedSession
+	var sessions []*TimetableSection
+	for i := range problem.Sections {
+		section := &problem.Sections[i]
+		if section.Sessions <= 0 {
+			unplaced = append(unplaced, UnplacedSession{SectionID: section.SectionID, Label: section.Label,
+				Code: UnplacedNoWeeklyHours, Message: "no weekly hours are set for the section or its course"})
+			continue
+		}
+		if reason := solver.unplaceable(section); reason != nil {
+			unplaced = append(unplaced, *reason)
+			continue
+		}
+		for n := 0; n < section.Sessions; n++ {
+			sessions = append(sessions, section)
+		}
+	}
+	solver.order(sessions)
+
+	limit := problem.StepLimit
+	if limit <= 0 {
+		limit = defaultSolverStepLimit
+	}
+	if !solver.search(sessions, 0, limit) {
+		solver.reset()
+		unplaced = append(unplaced, solver.greedy(sessions)...)
+	}
+
+	solution := &TimetableSolution{Unplaced: unplaced}
+	for _, p := range solver.placed {
+		sectionID := p.section.SectionID
+		solution.Slots = append(solution.Slots, models.TimeTable{
+			CourseID:  p.section.CourseID,
+			SectionID: &sectionID,
+			TeacherID: p.section.TeacherID,
+			DayOfWeek: p.day,
+			StartTime: formatClock(p.start),
+			EndTime:   formatClock(p.end),
+			Classroom: p.room,
+			IsActive:  true,
+		})
+	}
+	sort.SliceStable(solution.Slots, func(i, j int) bool {
+		a, b := &solution.Slots[i], &solution.Slots[j]
+		if a.DayOfWeek != b.DayOfWeek {
+			return weekdayIndex(a.DayOfWeek) < weekdayIndex(b.DayOfWeek)
+		}
+		if a.StartTime != b.StartTime {
+			return a.StartTime < b.StartTime
+		}
+		return *a.SectionID < *b.SectionID
+	})
+	solution.Score = solver.score()
+	return solution, nil
+}
+
+// interval is a stretch of a day, in minutes after midnight
+type interval struct {
+	day        string
+	start, end int
+}
+
+func (a interval) overlaps(b interval) bool {
+	return a.day == b.day && a.start < b.end && b.start < a.end
+}
+
+func overlapsAny(iv interval, busy []interval) bool {
+	for _, other := range busy {
+		if iv.overlaps(other) {
+			return true
+		}
+	}
+	return false
+}
+
+type placement struct {
+	section *TimetableSection
+	interval
+	room     string
+	capacity int
+}
+
+type timetableSolver struct {
+	problem *TimetableProblem
+	days    []string
+	starts  []int
+	length  int
+	rooms   map[*TimetableSection][]models.Room
+	clashes map[uint][]uint
+
+	fixedTeachers map[uint][]interval
+	fixedRooms    map[string][]interval
+	fixedSections map[uint][]interval
+
+	teachers map[uint][]interval
+	roomBusy map[string][]interval
+	sections map[uint][]interval
+	placed   []placement
+	steps    int
+}
+
+func newTimetableSolver(problem *TimetableProblem, days []string, starts []int) *timetableSolver {
+	s := &timetableSolver{
+		problem:       problem,
+		days:          days,
+		starts:        starts,
+		length:        problem.Grid.PeriodMinutes,
+		rooms:         map[*TimetableSection][]models.Room{},
+		clashes:       map[uint][]uint{},
+		fixedTeachers: map[uint][]interval{},
+		fixedRooms:    map[string][]interval{},
+		fixedSections: map[uint][]interval{},
+	}
+	for pair := range problem.Clashes {
+		s.clashes[pair[0]] = append(s.clashes[pair[0]], pair[1])
+		s.clashes[pair[1]] = append(s.clashes[pair[1]], pair[0])
+	}
+	for _, slot := range problem.Fixed {
+		iv, ok := slotInterval(&slot)
+		if !ok || !slot.IsActive {
+			continue
+		}
+		if slot.TeacherID != 0 {
+			s.fixedTeachers[slot.TeacherID] = append(s.fixedTeachers[slot.TeacherID], iv)
+		}
+		if room := roomKey(slot.Classroom); room != "" {
+			s.fixedRooms[room] = append(s.fixedRooms[room], iv)
+		}
+		if slot.SectionID != nil {
+			s.fixedSections[*slot.SectionID] = append(s.fixedSections[*slot.SectionID], iv)
+		}
+	}
+	for i := range problem.Sections {
+		section := &problem.Sections[i]
+		s.rooms[section] = s.suitableRooms(section)
+	}
+	s.reset()
+	return s
+}
+
+// reset removes every placed class
+func (s *timetableSolver) reset() {
+	s.teachers = copyBusy(s.fixedTeachers)
+	s.roomBusy = copyBusy(s.fixedRooms)
+	s.sections = copyBusy(s.fixedSections)
+	s.placed = nil
+}
+
+func copyBusy[K comparable](busy map[K][]interval) map[K][]interval {
+	copied := make(map[K][]interval, len(busy))
+	for key, intervals := range busy {
+		copied[key] = append([]interval(nil), intervals...)
+	}
+	return copied
+}
+
+// suitableRooms returns the rooms a section may be placed in, smallest
+// first. A section pinned to a room gets only that room, if it is suitable,
+// and without any rooms registered sections are placed without one.
+func (s *timetableSolver) suitableRooms(section *TimetableSection) []models.Room {
+	if section.Room != "" {
+		for _, room := range s.problem.Rooms {
+			if roomKey(room.Name) == roomKey(section.Room) {
+				if (room.Capacity > 0 && room.Capacity < section.Size) || !room.HasFeatures(section.Features) {
+					return nil
+				}
+				return []models.Room{room}
+			}
+		}
+		return []models.Room{{Name: section.Room}}
+	}
+	if len(s.problem.Rooms) == 0 {
+		if len(models.ParseFeatures(section.Features)) > 0 {
+			return nil
+		}
+		return []models.Room{{}}
+	}
+	var rooms []models.Room
+	for _, room := range s.problem.Rooms {
+		if room.Capacity > 0 && room.Capacity < section.Size {
+			continue
+		}
+		if room.HasFeatures(section.Features) {
+			rooms = append(rooms, room)
+		}
+	}
+	sort.SliceStable(rooms, func(i, j int) bool {
+		if rooms[i].Capacity != rooms[j].Capacity {
+			return rooms[i].Capacity < rooms[j].Capacity
+		}
+		return rooms[i].Name < rooms[j].Name
+	})
+	return rooms
+}
+
+// unplaceable reports a section that could not be placed in any timetable
+func (s *timetableSolver) unplaceable(section *TimetableSection) *UnplacedSession {
+	if len(s.rooms[section]) == 0 {
+		message := fmt.Sprintf("no room seats %d", section.Size)
+		if features := models.ParseFeatures(section.Features); len(features) > 0 {
+			message += " with " + strings.Join(features, ", ")
+		}
+		return &UnplacedSession{SectionID: section.SectionID, Label: section.Label, Sessions: section.Sessions,
+			Code: UnplacedNoSuitableRoom, Message: message}
+	}
+	for _, day := range s.days {
+		for _, start := range s.starts {
+			if s.teacherAvailable(section.TeacherID, interval{day, start, start + s.length}) {
+				return nil
+			}
+		}
+	}
+	return &UnplacedSession{SectionID: section.SectionID, Label: section.Label, Sessions: section.Sessions,
+		Code: UnplacedTeacherUnavailable, Message: "the teacher is not available at any time in the timetable"}
+}
+
+// order puts the sessions hardest to place first: those with the fewest
+// free periods and rooms, then the biggest
+func (s *timetableSolver) order(sessions []*TimetableSection) {
+	options := map[*TimetableSection]int{}
+	for _, section := range sessions {
+		if _, ok := options[section]; !ok {
+			options[section] = len(s.candidates(section))
+		}
+	}
+	sort.SliceStable(sessions, func(i, j int) bool {
+		a, b := sessions[i], sessions[j]
+		if options[a] != options[b] {
+			return options[a] < options[b]
+		}
+		if a.Size != b.Size {
+			return a.Size > b.Size
+		}
+		return a.SectionID < b.SectionID
+	})
+}
+
+// teacherAvailable reports whether an interval falls within one of a
+// teacher's availability windows
+func (s *timetableSolver) teacherAvailable(teacherID uint, iv interval) bool {
+	return teacherID == 0 || withinAvailability(s.problem.Availability[teacherID], iv)
+}
+
+// withinAvailability reports whether an interval falls within one of the
+// windows, or there are no windows
+func withinAvailability(windows []models.TeacherAvailability, iv interval) bool {
+	if len(windows) == 0 {
+		return true
+	}
+	for _, window := range windows {
+		if available, ok := availabilityInterval(&window); ok && available.day == iv.day &&
+			available.start <= iv.start && iv.end <= available.end {
+			return true
+		}
+	}
+	return false
+}
+
+// candidates returns every free period and room a session of a section may
+// take, best first
+func (s *timetableSolver) candidates(section *TimetableSection) []placement {
+	var found []placement
+	var costs []int
+	for _, day := range s.days {
+		for _, start := range s.starts {
+			iv := interval{day, start, start + s.length}
+			if !s.free(section, iv) {
+				continue
+			}
+			for _, room := range s.rooms[section] {
+				key := roomKey(room.Name)
+				if key != "" && overlapsAny(iv, s.roomBusy[key]) {
+					continue
+				}
+				found = append(found, placement{section: section, interval: iv, room: room.Name, capacity: room.Capacity})
+				costs = append(costs, s.cost(section, iv, room.Capacity))
+			}
+		}
+	}
+	index := make([]int, len(found))
+	for i := range index {
+		index[i] = i
+	}
+	sort.SliceStable(index, func(i, j int) bool { return costs[index[i]] < costs[index[j]] })
+	sorted := make([]placement, len(found))
+	for i, at := range index {
+		sorted[i] = found[at]
+	}
+	return sorted
+}
+
+// free reports whether a section, its teacher and its students are all
+// free for an interval
+func (s *timetableSolver) free(section *TimetableSection, iv interval) bool {
+	if overlapsAny(iv, s.sections[section.SectionID]) {
+		return false
+	}
+	if section.TeacherID != 0 && (overlapsAny(iv, s.teachers[section.TeacherID]) || !s.teacherAvailable(section.TeacherID, iv)) {
+		return false
+	}
+	for _, other := range s.clashes[section.SectionID] {
+		if overlapsAny(iv, s.sections[other]) {
+			return false
+		}
+	}
+	return true
+}
+
+// cost is how much placing a session would add to the timetable's score,
+// roughly: meeting again on a day, leaving a gap in the teacher's day, and
+// empty seats
+func (s *timetableSolver) cost(section *TimetableSection, iv interval, capacity int) int {
+	cost := 0
+	for _, other := range s.sections[section.SectionID] {
+		if other.day == iv.day {
+			cost += sameDayRepeatPenalty
+			break
+		}
+	}
+	if section.TeacherID != 0 {
+		teaching, adjacent := false, false
+		for _, other := range s.teachers[section.TeacherID] {
+			if other.day == iv.day {
+				teaching = true
+				adjacent = adjacent || other.end == iv.start || iv.end == other.start
+			}
+		}
+		if teaching && !adjacent {
+			cost += teacherGapPenalty
+		}
+	}
+	if capacity > section.Size && section.Size > 0 {
+		cost += (capacity - section.Size) / wastedSeatsPerPenalty
+	}
+	return cost
+}
+
+func (s *timetableSolver) place(p placement) {
+	s.placed = append(s.placed, p)
+	s.sections[p.section.SectionID] = append(s.sections[p.section.SectionID], p.interval)
+	if p.section.TeacherID != 0 {
+		s.teachers[p.section.TeacherID] = append(s.teachers[p.section.TeacherID], p.interval)
+	}
+	if key := roomKey(p.room); key != "" {
+		s.roomBusy[key] = append(s.roomBusy[key], p.interval)
+	}
+}
+
+// unplace removes the last class placed
+func (s *timetableSolver) unplace() {
+	p := s.placed[len(s.placed)-1]
+	s.placed = s.placed[:len(s.placed)-1]
+	pop := func(intervals []interval) []interval { return intervals[:len(intervals)-1] }
+	s.sections[p.section.SectionID] = pop(s.sections[p.section.SectionID])
+	if p.section.TeacherID != 0 {
+		s.teachers[p.section.TeacherID] = pop(s.teachers[p.section.TeacherID])
+	}
+	if key := roomKey(p.room); key != "" {
+		s.roomBusy[key] = pop(s.roomBusy[key])
+	}
+}
+
+// search places sessions from the i'th on, backtracking when one cannot be
+// placed, and gives up after limit steps
+func (s *timetableSolver) search(sessions []*TimetableSection, i, limit int) bool {
+	if i == len(sessions) {
+		return true
+	}
+	for _, candidate := range s.candidates(sessions[i]) {
+		if s.steps >= limit {
+			return false
+		}
+		s.steps++
+		s.place(candidate)
+		if s.search(sessions, i+1, limit) {
+			return true
+		}
+		s.unplace()
+	}
+	return false
+}
+
+// greedy places each session in its best free period in turn, returning
+// those it could not place
+func (s *timetableSolver) greedy(sessions []*TimetableSection) []UnplacedSession {
+	var unplaced []UnplacedSession
+	missing := map[uint]int{}
+	for _, section := range sessions {
+		candidates := s.candidates(section)
+		if len(candidates) > 0 {
+			s.place(candidates[0])
+			continue
+		}
+		if missing[section.SectionID] == 0 {
+			unplaced = append(unplaced, UnplacedSession{SectionID: section.SectionID, Label: section.Label,
+				Code: UnplacedNoFreePeriod, Message: "no period is left when the teacher, a suitable room and the students are all free"})
+		}
+		missing[section.SectionID]++
+	}
+	for i := range unplaced {
+		unplaced[i].Sessions = missing[unplaced[i].SectionID]
+	}
+	return unplaced
+}
+
+// score totals the soft constraints broken by the classes placed
+func (s *timetableSolver) score() TimetableScore {
+	var score TimetableScore
+	meets := map[uint]map[string]int{}
+	teaching := map[uint]map[string][]interval{}
+	for _, p := range s.placed {
+		if meets[p.section.SectionID] == nil {
+			meets[p.section.SectionID] = map[string]int{}
+		}
+		if meets[p.section.SectionID][p.day]++; meets[p.section.SectionID][p.day] > 1 {
+			score.SameDayRepeats++
+		}
+		if p.section.TeacherID != 0 {
+			if teaching[p.section.TeacherID] == nil {
+				teaching[p.section.TeacherID] = map[string][]interval{}
+			}
+			teaching[p.section.TeacherID][p.day] = append(teaching[p.section.TeacherID][p.day], p.interval)
+		}
+		if p.capacity > p.section.Size && p.section.Size > 0 {
+			score.WastedSeats += p.capacity - p.section.Size
+		}
+	}
+	for _, byDay := range teaching {
+		for _, intervals := range byDay {
+			sort.Slice(intervals, func(i, j int) bool { return intervals[i].start < intervals[j].start })
+			for i := 1; i < len(intervals); i++ {
+				if gap := intervals[i].start - intervals[i-1].end; gap > 0 {
+					score.TeacherGaps += gap / s.length
+				}
+			}
+		}
+	}
+	score.Total = score.SameDayRepeats*sameDayRepeatPenalty + score.TeacherGaps*teacherGapPenalty +
+		score.WastedSeats/wastedSeatsPerPenalty
+	return score
+}
+
+// slotInterval returns when a timetable class meets, if its day and times
+// are valid
+func slotInterval(slot *models.TimeTable) (interval, bool) {
+	return parseInterval(slot.DayOfWeek, slot.StartTime, slot.EndTime)
+}
+
+func availabilityInterval(window *models.TeacherAvailability) (interval, bool) {
+	return parseInterval(window.DayOfWeek, window.StartTime, window.EndTime)
+}
+
+func parseInterval(day, start, end string) (interval, bool) {
+	weekday, ok1 := normalizeWeekday(day)
+	from, ok2 := clockMinutes(start)
+	to, ok3 := clockMinutes(end)
+	if !ok1 || !ok2 || !ok3 || to <= from || to > 24*60 {
+		return interval{}, false
+	}
+	return interval{weekday, from, to}, true
+}
+
+// normalizeWeekday returns the English name of a day of the week however
+// it is capitalised
+func normalizeWeekday(day string) (string, bool) {
+	for weekday := time.Sunday; weekday <= time.Saturday; weekday++ {
+		if strings.EqualFold(strings.TrimSpace(day), weekday.String()) {
+			return weekday.String(), true
+		}
+	}
+	return "", false
+}
+
+// weekdayIndex orders days from Monday
+func weekdayIndex(day string) int {
+	for weekday := time.Sunday; weekday <= time.Saturday; weekday++ {
+		if weekday.String() == day {
+			return (int(weekday) + 6) % 7
+		}
+	}
+	return 7
+}
+
+// roomKey is how rooms are told apart: by name, ignoring case and spaces
+func roomKey(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+func formatClock(minutes int) string {
+	return fmt.Sprintf("%02d:%02d", minutes/60, minutes%60)
+}
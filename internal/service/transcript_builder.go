@@ -0,0 +1,261 @@
+package service
+
+import (
+	"sort"
+	"time"
+
+	"school-management-system/internal/models"
+)
+
+// RepeatPolicy controls how multiple attempts at the same course count
+// towards GPA and earned credits.
+type RepeatPolicy string
+
+const (
+	// RepeatPolicyReplace counts only the most recent completed attempt.
+	RepeatPolicyReplace RepeatPolicy = "replace"
+	// RepeatPolicyAverage counts every attempt in the GPA but awards the
+	// course credit only once.
+	RepeatPolicyAverage RepeatPolicy = "average"
+)
+
+// SettingRepeatPolicy is the SystemSetting key holding the RepeatPolicy.
+const SettingRepeatPolicy = "transcript.repeat_policy"
+
+// ScaleLookup returns the grading scale for a course; a nil course asks for
+// the school-wide scale.
+type ScaleLookup func(course *models.Course) *models.GradingScale
+
+// letterValue describes how a recorded letter affects a transcript.
+type letterValue struct {
+	points    float64
+	inGPA     bool // carries grade points
+	passed    bool // earns the course credit
+	attempted bool // counts towards attempted credits
+}
+
+// valueOfLetter values a letter on the scale; the P/NP/I/W markers apply only
+// when the scale does not define the letter itself.
+func valueOfLetter(scale *models.GradingScale, letter string) letterValue {
+	if band := scale.BandForLetter(letter); band != nil {
+		return letterValue{points: band.GradePoints, inGPA: true, passed: band.IsPassing, attempted: true}
+	}
+
+	switch letter {
+	case models.GradePass:
+		return letterValue{passed: true, attempted: true}
+	case models.GradeNoPass, models.GradeWithdrawn:
+		return letterValue{attempted: true}
+	}
+	return letterValue{}
+}
+
+type transcriptAttempt struct {
+	grade   models.Grade
+	term    int // index into the chronologically sorted terms
+	value   letterValue
+	credits float64
+
+	countsInGPA  bool
+	earnsCredits bool
+}
+
+// BuildTranscripts computes one GradeTranscript per term in which the student
+// has grades or transfer credit, in chronological order, with running
+// cumulative totals. Letters are valued on the scale scaleFor returns for
+// each course (the built-in A–F scale if scaleFor is nil). Grades must have
+// their Course preloaded for credit hours; grades without a term are placed by GradedAt and skipped if no term
+// covers that date. Transfer credit without a term is reported first as a
+// separate row with no TermID. The output only depends on the inputs, so
+// regenerating from the same data always yields the same figures.
+func BuildTranscripts(
+	studentID uint,
+	grades []models.Grade,
+	terms []models.AcademicTerm,
+	transfers []models.TransferCredit,
+	policy RepeatPolicy,
+	scaleFor ScaleLookup,
+) []models.GradeTranscript {
+	if scaleFor == nil {
+		fallback := models.DefaultGradingScale()
+		scaleFor = func(*models.Course) *models.GradingScale { return fallback }
+	}
+
+	sorted := make([]models.AcademicTerm, len(terms))
+	copy(sorted, terms)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if !sorted[i].StartDate.Equal(sorted[j].StartDate) {
+			return sorted[i].StartDate.Before(sorted[j].StartDate)
+		}
+		return sorted[i].ID < sorted[j].ID
+	})
+
+	termIndex := make(map[uint]int, len(sorted))
+	for i, t := range sorted {
+		termIndex[t.ID] = i
+	}
+
+	locate := func(termID *uint, at time.Time) (int, bool) {
+		if termID != nil {
+			idx, ok := termIndex[*termID]
+			return idx, ok
+		}
+		for i := range sorted {
+			if sorted[i].Contains(at) {
+				return i, true
+			}
+		}
+		return 0, false
+	}
+
+	var attempts []*transcriptAttempt
+	for _, g := range grades {
+		idx, ok := locate(g.TermID, g.GradedAt)
+		if !ok {
+			continue
+		}
+		attempts = append(attempts, &transcriptAttempt{
+			grade:   g,
+			term:    idx,
+			value:   valueOfLetter(scaleFor(&g.Course), g.Grade),
+			credits: float64(g.Course.CreditHours),
+		})
+	}
+	sort.SliceStable(attempts, func(i, j int) bool {
+		a, b := attempts[i], attempts[j]
+		if a.term != b.term {
+			return a.term < b.term
+		}
+		if !a.grade.GradedAt.Equal(b.grade.GradedAt) {
+			return a.grade.GradedAt.Before(b.grade.GradedAt)
+		}
+		return a.grade.ID < b.grade.ID
+	})
+
+	applyRepeatPolicy(attempts, policy)
+
+	// Bucket -1 holds transfer credit accepted outside any term.
+	type bucket struct {
+		used bool
+		row  models.GradeTranscript
+	}
+	buckets := make([]bucket, len(sorted)+1)
+	at := func(idx int) *bucket { return &buckets[idx+1] }
+
+	for _, a := range attempts {
+		b := at(a.term)
+		b.used = true
+		if a.value.attempted {
+			b.row.TotalCredits += a.credits
+		}
+		if a.countsInGPA {
+			b.row.QualityCredits += a.credits
+			b.row.GradePointsSum += a.value.points * a.credits
+		}
+		if a.earnsCredits {
+			b.row.EarnedCredits += a.credits
+		}
+	}
+
+	var transferYear int
+	for _, tc := range transfers {
+		idx := -1
+		if tc.TermID != nil {
+			i, ok := termIndex[*tc.TermID]
+			if !ok {
+				continue
+			}
+			idx = i
+		} else if transferYear == 0 || tc.CreatedAt.Year() < transferYear {
+			transferYear = tc.CreatedAt.Year()
+		}
+
+		b := at(idx)
+		b.used = true
+		b.row.TotalCredits += tc.Credits
+		b.row.EarnedCredits += tc.Credits
+		b.row.TransferCredits += tc.Credits
+		if v := valueOfLetter(scaleFor(tc.EquivalentCourse), tc.Grade); tc.CountsInGPA && v.inGPA {
+			b.row.QualityCredits += tc.Credits
+			b.row.GradePointsSum += v.points * tc.Credits
+		}
+	}
+
+	var result []models.GradeTranscript
+	var cum models.GradeTranscript
+	for i := range buckets {
+		b := &buckets[i]
+		if !b.used {
+			continue
+		}
+
+		row := b.row
+		row.StudentID = studentID
+		if i == 0 {
+			row.TranscriptSemester = "Transfer Credit"
+			row.Year = transferYear
+			row.IsOfficial = true
+		} else {
+			term := sorted[i-1]
+			termID := term.ID
+			row.TermID = &termID
+			row.TranscriptSemester = term.Name
+			row.Year = term.StartDate.Year()
+			row.IsOfficial = term.Status == models.TermStatusClosed
+		}
+		row.GPA = ratio(row.GradePointsSum, row.QualityCredits)
+
+		cum.TotalCredits += row.TotalCredits
+		cum.EarnedCredits += row.EarnedCredits
+		cum.QualityCredits += row.QualityCredits
+		cum.GradePointsSum += row.GradePointsSum
+
+		row.CumulativeCredits = cum.TotalCredits
+		row.CumulativeEarnedCredits = cum.EarnedCredits
+		row.CumulativeQualityCredits = cum.QualityCredits
+		row.CumulativeGradePoints = cum.GradePointsSum
+		row.CumulativeGPA = ratio(cum.GradePointsSum, cum.QualityCredits)
+
+		result = append(result, row)
+	}
+
+	return result
+}
+
+// applyRepeatPolicy decides, for attempts sorted chronologically, which
+// attempts count towards GPA and which earn credit.
+func applyRepeatPolicy(attempts []*transcriptAttempt, policy RepeatPolicy) {
+	latest := make(map[uint]*transcriptAttempt)
+	earned := make(map[uint]bool)
+
+	for _, a := range attempts {
+		completed := a.value.inGPA || a.value.passed || a.grade.Grade == models.GradeNoPass
+		if !completed {
+			continue
+		}
+
+		switch policy {
+		case RepeatPolicyAverage:
+			a.countsInGPA = a.value.inGPA
+			if a.value.passed && !earned[a.grade.CourseID] {
+				a.earnsCredits = true
+				earned[a.grade.CourseID] = true
+			}
+		default:
+			if prev := latest[a.grade.CourseID]; prev != nil {
+				prev.countsInGPA = false
+				prev.earnsCredits = false
+			}
+			latest[a.grade.CourseID] = a
+			a.countsInGPA = a.value.inGPA
+			a.earnsCredits = a.value.passed
+		}
+	}
+}
+
+func ratio(points, credits float64) float64 {
+	if credits == 0 {
+		return 0
+	}
+	return points / credits
+}
@@ -1,14 +1,15 @@
 package service
 
 import (
+	"context"
 	"school-management-system/internal/models"
 	"school-management-system/internal/repository"
 )
 
 type UserService interface {
 	GetUserByID(id uint) (*models.User, error)
-	UpdateUser(id uint, userData map[string]interface{}) (*models.User, error)
-	DeleteUser(id uint) error
+	UpdateUser(ctx context.Context, id uint, userData map[string]interface{}) (*models.User, error)
+	DeleteUser(ctx context.Context, id uint) error
 	GetAllUsers(page, limit int, role models.UserRole) ([]models.User, int64, error)
 }
 
@@ -24,7 +25,7 @@ func (s *userService) GetUserByID(id uint) (*models.User, error) {
 	return s.userRepo.FindByID(id)
 }
 
-func (s *userService) UpdateUser(id uint, userData map[string]interface{}) (*models.User, error) {
+func (s *userService) UpdateUser(ctx context.Context, id uint, userData map[string]interface{}) (*models.User, error) {
 	user, err := s.userRepo.FindByID(id)
 	if err != nil {
 		return nil, err
@@ -40,13 +41,16 @@ func (s *userService) UpdateUser(id uint, userData map[string]interface{}) (*mod
 	if phone, ok := userData["phone"].(string); ok {
 		user.Phone = phone
 	}
+	if locale, ok := userData["locale"].(string); ok {
+		user.Locale = locale
+	}
 
-	err = s.userRepo.Update(user)
+	err = s.userRepo.Update(ctx, user)
 	return user, err
 }
 
-func (s *userService) DeleteUser(id uint) error {
-	return s.userRepo.Delete(id)
+func (s *userService) DeleteUser(ctx context.Context, id uint) error {
+	return s.userRepo.Delete(ctx, id)
 }
 
 func (s *userService) GetAllUsers(page, limit int, role models.UserRole) ([]models.User, int64, error) {
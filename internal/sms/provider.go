@@ -0,0 +1,95 @@
+// Package sms sends text messages through a pluggable provider.
+package sms
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"school-management-system/pkg/logger"
+)
+
+// Provider sends one text message to a phone number
+type Provider interface {
+	Send(ctx context.Context, to, body string) error
+}
+
+// Message is a text message handed to a provider
+type Message struct {
+	To   string
+	Body string
+}
+
+// FakeProvider records messages instead of sending them, for tests and
+// local development. Set Err to make sends fail.
+type FakeProvider struct {
+	mu       sync.Mutex
+	messages []Message
+	Err      error
+}
+
+func (p *FakeProvider) Send(ctx context.Context, to, body string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.Err != nil {
+		return p.Err
+	}
+	p.messages = append(p.messages, Message{To: to, Body: body})
+	return nil
+}
+
+// Messages returns the messages sent so far
+func (p *FakeProvider) Messages() []Message {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]Message{}, p.messages...)
+}
+
+// LogProvider writes messages to the application log. It is the default
+// when no SMS provider is configured.
+type LogProvider struct{}
+
+func (LogProvider) Send(ctx context.Context, to, body string) error {
+	logger.GetLogger().WithField("to", to).WithField("body", body).Info("SMS (not sent, no provider configured)")
+	return nil
+}
+
+// TwilioProvider sends messages through the Twilio Messages API
+type TwilioProvider struct {
+	AccountSID string
+	AuthToken  string
+	From       string
+	Client     *http.Client
+}
+
+func (p *TwilioProvider) Send(ctx context.Context, to, body string) error {
+	client := p.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	form := url.Values{"To": {to}, "From": {p.From}, "Body": {body}}
+	endpoint := fmt.Sprintf("https://api.twilio.com/2010-04-01/Accounts/%s/Messages.json", url.PathEscape(p.AccountSID))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(p.AccountSID, p.AuthToken)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("twilio returned %s: %s", resp.Status, strings.TrimSpace(string(detail)))
+	}
+	return nil
+}
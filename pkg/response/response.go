@@ -40,6 +40,18 @@ func Created(c *gin.Context, message string, data interface{}) {
 	})
 }
 
+// Accepted sends a 202 Accepted response for work that continues in the
+// background
+func Accepted(c *gin.Context, message string, data interface{}) {
+	c.JSON(http.StatusAccepted, Response{
+		Success:   true,
+		Message:   message,
+		Data:      data,
+		Timestamp: time.Now().UTC(),
+		RequestID: getRequestID(c),
+	})
+}
+
 // Error sends an error response
 func Error(c *gin.Context, err error) {
 	appErr, isAppError := errors.IsAppError(err)
@@ -1,6 +1,14 @@
 package utils
 
-import "golang.org/x/crypto/bcrypt"
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
 
 // HashPassword creates a bcrypt hash of the password
 func HashPassword(password string) (string, error) {
@@ -13,3 +21,85 @@ func CheckPasswordHash(password, hash string) bool {
 	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
 	return err == nil
 }
+
+// Password policy errors
+var (
+	ErrPasswordTooShort = errors.New("password is too short")
+	ErrPasswordTooLong  = errors.New("password is too long")
+	ErrPasswordBreached = errors.New("password appears in a list of breached passwords")
+	ErrPasswordIsEmail  = errors.New("password must not be the same as the email address")
+	ErrPasswordReused   = errors.New("password was used recently")
+)
+
+// PasswordPolicy holds the rules new passwords must satisfy
+type PasswordPolicy struct {
+	MinLength   int
+	MaxLength   int
+	HistorySize int // number of previous passwords that cannot be reused
+	breached    map[string]struct{}
+}
+
+// NewPasswordPolicy creates a policy. breachedListPath points to a text file
+// with one known-breached password per line; an empty path disables the check.
+func NewPasswordPolicy(minLength, historySize int, breachedListPath string) (*PasswordPolicy, error) {
+	policy := &PasswordPolicy{
+		MinLength:   minLength,
+		MaxLength:   72, // bcrypt ignores anything longer
+		HistorySize: historySize,
+		breached:    map[string]struct{}{},
+	}
+	if breachedListPath == "" {
+		return policy, nil
+	}
+
+	file, err := os.Open(breachedListPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open breached password list: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		policy.breached[strings.ToLower(line)] = struct{}{}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read breached password list: %w", err)
+	}
+	return policy, nil
+}
+
+// Validate checks a new password against the length, breached-list and
+// email rules
+func (p *PasswordPolicy) Validate(password, email string) error {
+	if len(password) < p.MinLength {
+		return fmt.Errorf("%w: minimum is %d characters", ErrPasswordTooShort, p.MinLength)
+	}
+	if len(password) > p.MaxLength {
+		return fmt.Errorf("%w: maximum is %d characters", ErrPasswordTooLong, p.MaxLength)
+	}
+	if email != "" && strings.EqualFold(password, email) {
+		return ErrPasswordIsEmail
+	}
+	if _, ok := p.breached[strings.ToLower(password)]; ok {
+		return ErrPasswordBreached
+	}
+	return nil
+}
+
+// CheckHistory rejects a password matching any of the given bcrypt hashes,
+// newest first. Only the first HistorySize hashes are considered.
+func (p *PasswordPolicy) CheckHistory(password string, previousHashes []string) error {
+	for i, hash := range previousHashes {
+		if i >= p.HistorySize {
+			break
+		}
+		if CheckPasswordHash(password, hash) {
+			return ErrPasswordReused
+		}
+	}
+	return nil
+}
@@ -1,35 +1,64 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"log"
 	"os"
 	"school-management-system/internal/config"
 	"school-management-system/internal/models"
 	"school-management-system/pkg/database"
+	"school-management-system/pkg/utils"
+	"time"
 )
 
+// Break-glass tool for when no admin can sign in to use the forgot-password
+// flow. Usage: go run reset_admin.go -email admin@school.com -password '<new>'
 func main() {
+	email := flag.String("email", "admin@school.com", "email of the account to reset")
+	password := flag.String("password", os.Getenv("RESET_PASSWORD"), "new password (or set RESET_PASSWORD)")
+	flag.Parse()
+
+	if *password == "" {
+		log.Fatal("A new password is required: pass -password or set RESET_PASSWORD")
+	}
+
 	os.Setenv("DB_DRIVER", "sqlite")
-	os.Setenv("DB_PATH", "school.db")
+	if os.Getenv("DB_PATH") == "" {
+		os.Setenv("DB_PATH", "school.db")
+	}
 	cfg, err := config.LoadConfig()
 	if err != nil {
 		log.Fatal("Failed to load config:", err)
 	}
+
+	policy, err := utils.NewPasswordPolicy(cfg.PasswordMinLength, cfg.PasswordHistorySize, cfg.PasswordBreachedList)
+	if err != nil {
+		log.Fatal("Failed to load password policy:", err)
+	}
+	if err := policy.Validate(*password, *email); err != nil {
+		log.Fatal("Password rejected: ", err)
+	}
+
 	db, err := database.ConnectDB(cfg)
 	if err != nil {
 		log.Fatal("Failed to connect DB:", err)
 	}
 
 	var user models.User
-	if err := db.Where("email = ?", "admin@school.com").First(&user).Error; err != nil {
-		log.Fatal("Admin user not found:", err)
+	if err := db.Where("email = ?", *email).First(&user).Error; err != nil {
+		log.Fatal("User not found:", err)
 	}
 
-	user.Password = "admin123"
+	user.Password = *password
 	if err := db.Save(&user).Error; err != nil {
 		log.Fatal("Failed to update password:", err)
 	}
 
-	fmt.Println("Admin password reset to admin123")
+	// Sign out existing sessions, as a normal password reset would
+	db.Model(&models.RefreshToken{}).
+		Where("user_id = ? AND revoked_at = 0", user.ID).
+		Update("revoked_at", time.Now().Unix())
+
+	fmt.Printf("Password reset for %s\n", user.Email)
 }
@@ -0,0 +1,175 @@
+package tests
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"school-management-system/internal/models"
+	"school-management-system/internal/repository"
+	"school-management-system/internal/service"
+)
+
+func newSectionServices(t *testing.T) (service.AcademicTermService, service.CourseSectionService) {
+	t.Helper()
+	testDB.AutoMigrate(&models.AcademicTerm{}, &models.CourseSection{}, &models.Enrollment{})
+	terms := repository.NewAcademicTermRepository()
+	return service.NewAcademicTermService(terms, nil),
+		service.NewCourseSectionService(repository.NewCourseSectionRepository(), repository.NewCourseRepository(), terms)
+}
+
+func TestAcademicTermCreate(t *testing.T) {
+	terms, _ := newSectionServices(t)
+	start := time.Date(2031, 9, 1, 0, 0, 0, 0, time.UTC)
+	code := fmt.Sprintf("T%d", time.Now().UnixNano()%1000000000)
+
+	first := &models.AcademicTerm{Name: "Fall", Code: code, StartDate: start, EndDate: start.AddDate(0, 4, 0)}
+	if err := terms.CreateTerm(first); err != nil {
+		t.Fatalf("CreateTerm: %v", err)
+	}
+	if first.Status != models.TermStatusPlanned {
+		t.Errorf("status = %s, want planned", first.Status)
+	}
+	if got, err := terms.GetTermByID(first.ID); err != nil || got.Code != code {
+		t.Errorf("GetTermByID = %+v, %v", got, err)
+	}
+
+	tests := []struct {
+		name string
+		term models.AcademicTerm
+	}{
+		{"no name", models.AcademicTerm{StartDate: start, EndDate: start.AddDate(0, 4, 0)}},
+		{"no dates", models.AcademicTerm{Name: "Fall"}},
+		{"ends before it starts", models.AcademicTerm{Name: "Fall", StartDate: start, EndDate: start.AddDate(0, 0, -1)}},
+		{"unknown status", models.AcademicTerm{Name: "Fall", StartDate: start, EndDate: start.AddDate(0, 4, 0), Status: "someday"}},
+		{"code taken", models.AcademicTerm{Name: "Fall", Code: code, StartDate: start, EndDate: start.AddDate(0, 4, 0)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			term := tt.term
+			if err := terms.CreateTerm(&term); err == nil {
+				t.Errorf("CreateTerm succeeded, want an error")
+			}
+		})
+	}
+}
+
+func TestCourseSectionCreateAndLookup(t *testing.T) {
+	terms, sections := newSectionServices(t)
+	start := time.Date(2031, 9, 1, 0, 0, 0, 0, time.UTC)
+	term := &models.AcademicTerm{Name: "Fall", StartDate: start, EndDate: start.AddDate(0, 4, 0)}
+	closed := &models.AcademicTerm{Name: "Old", StartDate: start.AddDate(-1, 0, 0), EndDate: start.AddDate(-1, 4, 0),
+		Status: models.TermStatusClosed}
+	for _, tm := range []*models.AcademicTerm{term, closed} {
+		if err := terms.CreateTerm(tm); err != nil {
+			t.Fatalf("CreateTerm: %v", err)
+		}
+	}
+	course := &models.Course{Name: "Course", CourseCode: fmt.Sprintf("CS%d", time.Now().UnixNano()), TeacherID: 4,
+		Room: "B2", MaxStudents: 25}
+	if err := testDB.Create(course).Error; err != nil {
+		t.Fatalf("create course: %v", err)
+	}
+
+	section := &models.CourseSection{CourseID: course.ID, TermID: term.ID}
+	if err := sections.CreateSection(section); err != nil {
+		t.Fatalf("CreateSection: %v", err)
+	}
+	if section.SectionCode != "001" || section.TeacherID != 4 || section.Room != "B2" || section.Capacity != 25 ||
+		section.Status != "open" {
+		t.Errorf("section = %+v, want the course's defaults", section)
+	}
+
+	tests := []struct {
+		name    string
+		section models.CourseSection
+	}{
+		{"no course", models.CourseSection{TermID: term.ID}},
+		{"no term", models.CourseSection{CourseID: course.ID}},
+		{"unknown course", models.CourseSection{CourseID: 999999999, TermID: term.ID}},
+		{"unknown term", models.CourseSection{CourseID: course.ID, TermID: 999999999}},
+		{"closed term", models.CourseSection{CourseID: course.ID, TermID: closed.ID}},
+		{"same code", models.CourseSection{CourseID: course.ID, TermID: term.ID, SectionCode: "001"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := tt.section
+			if err := sections.CreateSection(&s); err == nil {
+				t.Errorf("CreateSection succeeded, want an error")
+			}
+		})
+	}
+
+	second := &models.CourseSection{CourseID: course.ID, TermID: term.ID, SectionCode: "002"}
+	if err := sections.CreateSection(second); err != nil {
+		t.Fatalf("CreateSection: %v", err)
+	}
+	repo := repository.NewCourseSectionRepository()
+	if found, err := repo.FindByCourseTermAndCode(course.ID, term.ID, "002"); err != nil || found.ID != second.ID {
+		t.Errorf("FindByCourseTermAndCode = %+v, %v, want section %d", found, err, second.ID)
+	}
+	if _, err := repo.FindByCourseTermAndCode(course.ID, closed.ID, "002"); err == nil {
+		t.Errorf("FindByCourseTermAndCode found a section in the wrong term")
+	}
+	if byCourse, err := sections.GetCourseSections(course.ID); err != nil || len(byCourse) != 2 {
+		t.Errorf("GetCourseSections = %d sections, %v, want 2", len(byCourse), err)
+	}
+	if byTerm, total, err := sections.GetTermSections(term.ID, 1, 10); err != nil || total != 2 || len(byTerm) != 2 {
+		t.Errorf("GetTermSections = %d of %d sections, %v, want 2", len(byTerm), total, err)
+	}
+}
+
+func TestCountActiveEnrollments(t *testing.T) {
+	_, sections := newSectionServices(t)
+	term := newRegistrationTerm(t, -time.Hour, time.Hour)
+	section := newTimetableSection(t, term, newTimetableTeacherID(), 3, 3)
+	for i, status := range []string{models.EnrollmentActive, models.EnrollmentApproved, models.EnrollmentRequested,
+		models.EnrollmentWaitlisted, models.EnrollmentDropped, models.EnrollmentWithdrawn} {
+		testDB.Create(&models.Enrollment{StudentID: uint(i + 1), CourseID: section.CourseID, SectionID: &section.ID,
+			TermID: &term.ID, Status: status, EnrolledAt: time.Now()})
+	}
+	// Keep the approved and waitlisted rows away from other tests' jobs
+	t.Cleanup(func() { testDB.Where("section_id = ?", section.ID).Delete(&models.Enrollment{}) })
+
+	count, err := repository.NewCourseSectionRepository().CountActiveEnrollments(section.ID)
+	if err != nil || count != 2 {
+		t.Errorf("CountActiveEnrollments = %d, %v, want 2", count, err)
+	}
+	if seats, err := sections.GetSeatsRemaining(section); err != nil || seats != 1 {
+		t.Errorf("GetSeatsRemaining = %d, %v, want 1", seats, err)
+	}
+	if err := sections.DeleteSection(section.ID); err == nil {
+		t.Errorf("DeleteSection succeeded with students seated")
+	}
+}
+
+func TestFindByStudentAndSectionMatchesLegacyEnrollments(t *testing.T) {
+	_, _ = newSectionServices(t)
+	term := newRegistrationTerm(t, -time.Hour, time.Hour)
+	otherTerm := newRegistrationTerm(t, -time.Hour, time.Hour)
+	section := newTimetableSection(t, term, newTimetableTeacherID(), 3, 0)
+	zero := uint(0)
+
+	tests := []struct {
+		name   string
+		termID *uint
+		want   bool
+	}{
+		{"same term", &term.ID, true},
+		{"no term", nil, true},
+		{"zero term", &zero, true},
+		{"another term", &otherTerm.ID, false},
+	}
+	repo := repository.NewEnrollmentRepository()
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			studentID := uint(time.Now().UnixNano()%1000000000) + uint(i)
+			testDB.Create(&models.Enrollment{StudentID: studentID, CourseID: section.CourseID, TermID: tt.termID,
+				Status: models.EnrollmentActive, EnrolledAt: time.Now()})
+			_, err := repo.FindByStudentAndSection(studentID, section.ID)
+			if found := err == nil; found != tt.want {
+				t.Errorf("found = %v (%v), want %v", found, err, tt.want)
+			}
+		})
+	}
+}
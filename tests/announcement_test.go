@@ -0,0 +1,419 @@
+package tests
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+
+	"school-management-system/internal/models"
+	"school-management-system/internal/repository"
+	"school-management-system/internal/service"
+)
+
+func newAnnouncementService(t *testing.T, email *service.EmailService) service.AnnouncementService {
+	t.Helper()
+	testDB.AutoMigrate(&models.Announcement{}, &models.AnnouncementTarget{}, &models.AnnouncementRecipient{},
+		&models.CourseSection{}, &models.Enrollment{}, &models.Guardian{})
+	return service.NewAnnouncementService(repository.NewAnnouncementRepository(), repository.NewUserRepository(), email, nil)
+}
+
+// announcementSchool is a course taught in two sections, with a student in
+// each, a third student only in a grade level, and a guardian
+type announcementSchool struct {
+	admin, teacher, otherTeacher  *models.User
+	first, second, third, parent  *models.User
+	course                        *models.Course
+	sectionA, sectionB, otherSect *models.CourseSection
+	gradeNine, gradeTen           string
+}
+
+func newAnnouncementSchool(t *testing.T) *announcementSchool {
+	t.Helper()
+	stamp := time.Now().UnixNano()
+	s := &announcementSchool{
+		admin:     createTestUser(t, models.RoleAdmin, true),
+		gradeNine: fmt.Sprintf("9-%d", stamp%100000),
+		gradeTen:  fmt.Sprintf("10-%d", stamp%100000),
+	}
+
+	teacher := func(i int) (*models.User, *models.Teacher) {
+		user := createTestUser(t, models.RoleTeacher, true)
+		teacher := &models.Teacher{UserID: user.ID, TeacherID: fmt.Sprintf("AT%d-%d", stamp, i)}
+		testDB.Create(teacher)
+		return user, teacher
+	}
+	var teacherA, teacherB *models.Teacher
+	s.teacher, teacherA = teacher(0)
+	s.otherTeacher, teacherB = teacher(1)
+
+	s.course = &models.Course{Name: "Chemistry", CourseCode: fmt.Sprintf("CHM%d", stamp), TeacherID: teacherA.ID}
+	other := &models.Course{Name: "Art", CourseCode: fmt.Sprintf("ART%d", stamp), TeacherID: teacherB.ID}
+	testDB.Create(s.course)
+	testDB.Create(other)
+	s.sectionA = &models.CourseSection{CourseID: s.course.ID, TermID: 1, SectionCode: "001", TeacherID: teacherA.ID}
+	s.sectionB = &models.CourseSection{CourseID: s.course.ID, TermID: 1, SectionCode: "002", TeacherID: teacherA.ID}
+	s.otherSect = &models.CourseSection{CourseID: other.ID, TermID: 1, SectionCode: "001", TeacherID: teacherB.ID}
+	for _, section := range []*models.CourseSection{s.sectionA, s.sectionB, s.otherSect} {
+		if err := testDB.Create(section).Error; err != nil {
+			t.Fatalf("create section: %v", err)
+		}
+	}
+
+	student := func(i int, grade string, section *models.CourseSection) (*models.User, *models.Student) {
+		user := createTestUser(t, models.RoleStudent, true)
+		student := &models.Student{UserID: user.ID, StudentID: fmt.Sprintf("AS%d-%d", stamp, i), GradeLevel: grade}
+		testDB.Create(student)
+		if section != nil {
+			testDB.Create(&models.Enrollment{StudentID: student.ID, CourseID: section.CourseID, SectionID: &section.ID, Status: "active", EnrolledAt: time.Now()})
+		}
+		return user, student
+	}
+	var firstStudent *models.Student
+	s.first, firstStudent = student(0, s.gradeTen, s.sectionA)
+	s.second, _ = student(1, s.gradeNine, s.sectionB)
+	s.third, _ = student(2, s.gradeTen, nil)
+	s.parent = createTestUser(t, models.RoleParent, true)
+	testDB.Create(&models.Guardian{UserID: s.parent.ID, StudentID: firstStudent.ID, Relationship: models.GuardianMother})
+	return s
+}
+
+func (s *announcementSchool) users() []*models.User {
+	return []*models.User{s.admin, s.teacher, s.otherTeacher, s.first, s.second, s.third, s.parent}
+}
+
+func idString(v uint) string {
+	return fmt.Sprint(v)
+}
+
+// seesAnnouncement reports whether an announcement is in the user's feed,
+// with the user's receipt
+func seesAnnouncement(t *testing.T, announcements service.AnnouncementService, userID, announcementID uint) *models.Announcement {
+	t.Helper()
+	feed, _, err := announcements.GetActive(userID, false, 1, 100)
+	if err != nil {
+		t.Fatalf("GetActive: %v", err)
+	}
+	for i := range feed {
+		if feed[i].ID == announcementID {
+			return &feed[i]
+		}
+	}
+	return nil
+}
+
+func TestAnnouncementTargeting(t *testing.T) {
+	announcements := newAnnouncementService(t, nil)
+	s := newAnnouncementSchool(t)
+
+	tests := []struct {
+		name      string
+		targets   []models.AnnouncementTarget
+		guardians bool
+		want      []*models.User
+	}{
+		{"section", []models.AnnouncementTarget{{Kind: models.AnnouncementTargetSection, Value: idString(s.sectionA.ID)}}, false,
+			[]*models.User{s.teacher, s.first}},
+		{"section with guardians", []models.AnnouncementTarget{{Kind: models.AnnouncementTargetSection, Value: idString(s.sectionA.ID)}}, true,
+			[]*models.User{s.teacher, s.first, s.parent}},
+		{"course", []models.AnnouncementTarget{{Kind: models.AnnouncementTargetCourse, Value: idString(s.course.ID)}}, false,
+			[]*models.User{s.teacher, s.first, s.second}},
+		{"grade level", []models.AnnouncementTarget{{Kind: models.AnnouncementTargetGradeLevel, Value: s.gradeTen}}, false,
+			[]*models.User{s.first, s.third}},
+		{"grade level and section", []models.AnnouncementTarget{
+			{Kind: models.AnnouncementTargetGradeLevel, Value: s.gradeNine},
+			{Kind: models.AnnouncementTargetSection, Value: idString(s.otherSect.ID)},
+		}, false, []*models.User{s.otherTeacher, s.second}},
+		{"user", []models.AnnouncementTarget{{Kind: models.AnnouncementTargetUser, Value: idString(s.third.ID)}}, false,
+			[]*models.User{s.third}},
+		{"role", []models.AnnouncementTarget{{Kind: models.AnnouncementTargetRole, Value: string(models.RoleParent)}}, false,
+			[]*models.User{s.parent}},
+		{"everyone", nil, false, s.users()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			announcement := &models.Announcement{Title: tt.name, Content: "Body", IsActive: true, Targets: tt.targets, IncludeGuardians: tt.guardians}
+			if err := announcements.Create(announcement, s.admin.ID); err != nil {
+				t.Fatalf("Create: %v", err)
+			}
+			want := map[uint]bool{}
+			for _, user := range tt.want {
+				want[user.ID] = true
+			}
+			for _, user := range s.users() {
+				if got := seesAnnouncement(t, announcements, user.ID, announcement.ID) != nil; got != want[user.ID] {
+					t.Errorf("%s %d sees it = %v, want %v", user.Role, user.ID, got, want[user.ID])
+				}
+			}
+		})
+	}
+}
+
+func TestAnnouncementValidation(t *testing.T) {
+	announcements := newAnnouncementService(t, nil)
+	s := newAnnouncementSchool(t)
+	now := time.Now().Unix()
+
+	tests := []struct {
+		name      string
+		actor     *models.User
+		audience  string
+		targets   []models.AnnouncementTarget
+		priority  string
+		publishAt int64
+		expiresAt int64
+		wantError error
+	}{
+		{"admin to everyone", s.admin, "all", nil, "", 0, 0, nil},
+		{"legacy role audience", s.admin, "parents", nil, "high", 0, 0, nil},
+		{"teacher to their section", s.teacher, "", []models.AnnouncementTarget{{Kind: models.AnnouncementTargetSection, Value: idString(s.sectionB.ID)}}, "", 0, 0, nil},
+		{"teacher to their course", s.teacher, "", []models.AnnouncementTarget{{Kind: models.AnnouncementTargetCourse, Value: idString(s.course.ID)}}, "", 0, 0, nil},
+		{"teacher to a user", s.teacher, "", []models.AnnouncementTarget{{Kind: models.AnnouncementTargetUser, Value: idString(s.third.ID)}}, "", 0, 0, nil},
+		{"teacher to everyone", s.teacher, "all", nil, "", 0, 0, service.ErrAnnouncementForbidden},
+		{"teacher to another's section", s.teacher, "", []models.AnnouncementTarget{{Kind: models.AnnouncementTargetSection, Value: idString(s.otherSect.ID)}}, "", 0, 0, service.ErrAnnouncementForbidden},
+		{"teacher to a grade level", s.teacher, "", []models.AnnouncementTarget{{Kind: models.AnnouncementTargetGradeLevel, Value: s.gradeNine}}, "", 0, 0, service.ErrAnnouncementForbidden},
+		{"free-form audience", s.admin, "specific_class", nil, "", 0, 0, service.ErrInvalidAnnouncement},
+		{"unknown target kind", s.admin, "", []models.AnnouncementTarget{{Kind: "club", Value: "chess"}}, "", 0, 0, service.ErrInvalidAnnouncement},
+		{"unknown role", s.admin, "", []models.AnnouncementTarget{{Kind: models.AnnouncementTargetRole, Value: "janitor"}}, "", 0, 0, service.ErrInvalidAnnouncement},
+		{"missing course", s.admin, "", []models.AnnouncementTarget{{Kind: models.AnnouncementTargetCourse, Value: "999999"}}, "", 0, 0, service.ErrInvalidAnnouncement},
+		{"bad priority", s.admin, "all", nil, "urgent", 0, 0, service.ErrInvalidAnnouncement},
+		{"expires before publishing", s.admin, "all", nil, "", now + 3600, now + 60, service.ErrInvalidAnnouncement},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			announcement := &models.Announcement{Title: tt.name, Content: "Body", IsActive: true, Audience: tt.audience,
+				Targets: tt.targets, Priority: tt.priority, PublishAt: tt.publishAt, ExpiresAt: tt.expiresAt}
+			err := announcements.Create(announcement, tt.actor.ID)
+			if !errors.Is(err, tt.wantError) {
+				t.Fatalf("Create() error = %v, want %v", err, tt.wantError)
+			}
+		})
+	}
+
+	legacy := &models.Announcement{Title: "Legacy", Content: "Body", IsActive: true, Audience: "parents"}
+	if err := announcements.Create(legacy, s.admin.ID); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if legacy.Audience != models.AnnouncementAudienceTargeted || len(legacy.Targets) != 1 || legacy.Targets[0].Value != string(models.RoleParent) {
+		t.Errorf("legacy audience became %q %+v", legacy.Audience, legacy.Targets)
+	}
+	if legacy.Priority != "normal" {
+		t.Errorf("priority = %q, want normal", legacy.Priority)
+	}
+}
+
+func TestAnnouncementScheduleAndEmail(t *testing.T) {
+	resetOutbox(t)
+	templates := newTemplateService(t)
+	outboxRepo := repository.NewOutboxRepository()
+	email := service.NewEmailService(service.NewEmailOutboxService(outboxRepo, service.OutboxOptions{}), templates)
+	announcements := newAnnouncementService(t, email)
+	s := newAnnouncementSchool(t)
+
+	now := time.Now()
+	announcement := &models.Announcement{
+		Title: "Field trip", Content: "Bring a packed lunch", IsActive: true, SendEmail: true, IncludeGuardians: true,
+		PublishAt: now.Add(time.Hour).Unix(),
+		Targets:   []models.AnnouncementTarget{{Kind: models.AnnouncementTargetSection, Value: idString(s.sectionA.ID)}},
+	}
+	if err := announcements.Create(announcement, s.teacher.ID); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if announcement.PublishedAt != 0 || seesAnnouncement(t, announcements, s.first.ID, announcement.ID) != nil {
+		t.Fatal("scheduled announcement was published straight away")
+	}
+	if _, total, _ := outboxRepo.FindAll("", 1, 10); total != 0 {
+		t.Fatalf("%d emails queued before publishing", total)
+	}
+
+	if _, err := announcements.PublishDue(now.Add(2 * time.Hour)); err != nil {
+		t.Fatalf("PublishDue: %v", err)
+	}
+	if seesAnnouncement(t, announcements, s.first.ID, announcement.ID) == nil {
+		t.Fatal("announcement not delivered once due")
+	}
+	stored, _ := announcements.GetByID(announcement.ID)
+	if stored.PublishedAt == 0 || stored.EmailedAt == 0 {
+		t.Errorf("published_at = %d, emailed_at = %d", stored.PublishedAt, stored.EmailedAt)
+	}
+
+	// Exactly the resolved audience is emailed, and only once
+	if _, err := announcements.PublishDue(now.Add(3 * time.Hour)); err != nil {
+		t.Fatalf("PublishDue: %v", err)
+	}
+	queued, _, _ := outboxRepo.FindAll("", 1, 10)
+	var got []string
+	for _, e := range queued {
+		for _, r := range e.Recipients {
+			got = append(got, r.Address)
+		}
+	}
+	want := []string{s.teacher.Email, s.first.Email, s.parent.Email}
+	sort.Strings(got)
+	sort.Strings(want)
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("emailed %v, want %v", got, want)
+	}
+
+	// Expired announcements leave the feed even before the expiry job runs
+	testDB.Model(&models.Announcement{}).Where("id = ?", announcement.ID).Update("expires_at", now.Add(-time.Minute).Unix())
+	if seesAnnouncement(t, announcements, s.first.ID, announcement.ID) != nil {
+		t.Error("expired announcement still listed")
+	}
+}
+
+func TestAnnouncementReadAndAcknowledge(t *testing.T) {
+	announcements := newAnnouncementService(t, nil)
+	s := newAnnouncementSchool(t)
+
+	slip := &models.Announcement{
+		Title: "Permission slip", Content: "Sign for the museum visit", IsActive: true, RequiresAcknowledgement: true,
+		Targets: []models.AnnouncementTarget{{Kind: models.AnnouncementTargetCourse, Value: idString(s.course.ID)}},
+	}
+	notice := &models.Announcement{
+		Title: "Lab closed", Content: "No lab on Monday", IsActive: true,
+		Targets: []models.AnnouncementTarget{{Kind: models.AnnouncementTargetCourse, Value: idString(s.course.ID)}},
+	}
+	for _, a := range []*models.Announcement{slip, notice} {
+		if err := announcements.Create(a, s.teacher.ID); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+	}
+
+	if err := announcements.MarkRead(notice.ID, s.first.ID); err != nil {
+		t.Fatalf("MarkRead: %v", err)
+	}
+	if err := announcements.Acknowledge(slip.ID, s.second.ID); err != nil {
+		t.Fatalf("Acknowledge: %v", err)
+	}
+	tests := []struct {
+		name      string
+		err       error
+		wantError error
+	}{
+		{"acknowledging a plain notice", announcements.Acknowledge(notice.ID, s.first.ID), service.ErrInvalidAnnouncement},
+		{"reading someone else's announcement", announcements.MarkRead(slip.ID, s.third.ID), service.ErrAnnouncementNotFound},
+		{"acknowledging someone else's announcement", announcements.Acknowledge(slip.ID, s.parent.ID), service.ErrAnnouncementNotFound},
+		{"acknowledging twice", announcements.Acknowledge(slip.ID, s.second.ID), nil},
+	}
+	for _, tt := range tests {
+		if !errors.Is(tt.err, tt.wantError) {
+			t.Errorf("%s: error = %v, want %v", tt.name, tt.err, tt.wantError)
+		}
+	}
+
+	read := seesAnnouncement(t, announcements, s.second.ID, slip.ID)
+	if read == nil || read.ReadAt == 0 || read.AcknowledgedAt == 0 {
+		t.Errorf("acknowledged slip in feed = %+v", read)
+	}
+	unread, _, _ := announcements.GetActive(s.first.ID, true, 1, 100)
+	if len(unread) != 1 || unread[0].ID != slip.ID {
+		t.Errorf("first student's unread = %+v", unread)
+	}
+
+	counts, err := announcements.GetReceiptCounts(slip.ID)
+	if err != nil {
+		t.Fatalf("GetReceiptCounts: %v", err)
+	}
+	if counts.Recipients != 3 || counts.Read != 1 || counts.Acknowledged != 1 {
+		t.Errorf("counts = %+v", counts)
+	}
+	pending, total, err := announcements.GetRecipients(slip.ID, "unacknowledged", 1, 20)
+	if err != nil {
+		t.Fatalf("GetRecipients: %v", err)
+	}
+	if total != 2 || pending[0].User.ID == 0 {
+		t.Errorf("unacknowledged = %d %+v", total, pending)
+	}
+	if _, _, err := announcements.GetRecipients(slip.ID, "late", 1, 20); !errors.Is(err, service.ErrInvalidAnnouncement) {
+		t.Errorf("unknown status: err = %v", err)
+	}
+}
+
+func TestAnnouncementAudienceSync(t *testing.T) {
+	announcements := newAnnouncementService(t, nil)
+	s := newAnnouncementSchool(t)
+
+	announcement := &models.Announcement{
+		Title: "Quiz on Friday", Content: "Chapters 3 and 4", IsActive: true,
+		Targets: []models.AnnouncementTarget{{Kind: models.AnnouncementTargetCourse, Value: idString(s.course.ID)}},
+	}
+	if err := announcements.Create(announcement, s.teacher.ID); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if err := announcements.MarkRead(announcement.ID, s.first.ID); err != nil {
+		t.Fatalf("MarkRead: %v", err)
+	}
+
+	// The third student enrolls, and both section students drop; only the
+	// one who had not read it loses it
+	var third, first, second models.Student
+	testDB.Where("user_id = ?", s.third.ID).First(&third)
+	testDB.Where("user_id = ?", s.first.ID).First(&first)
+	testDB.Where("user_id = ?", s.second.ID).First(&second)
+	testDB.Create(&models.Enrollment{StudentID: third.ID, CourseID: s.course.ID, SectionID: &s.sectionB.ID, Status: "active", EnrolledAt: time.Now()})
+	testDB.Model(&models.Enrollment{}).Where("student_id IN ?", []uint{first.ID, second.ID}).Update("status", "dropped")
+
+	if _, err := announcements.SyncAudiences(time.Now()); err != nil {
+		t.Fatalf("SyncAudiences: %v", err)
+	}
+	for _, tt := range []struct {
+		user *models.User
+		want bool
+	}{{s.third, true}, {s.first, true}, {s.second, false}, {s.teacher, true}} {
+		if got := seesAnnouncement(t, announcements, tt.user.ID, announcement.ID) != nil; got != tt.want {
+			t.Errorf("user %d sees it = %v, want %v", tt.user.ID, got, tt.want)
+		}
+	}
+
+	// Retargeting a published announcement redelivers it
+	announcement.Targets = []models.AnnouncementTarget{{Kind: models.AnnouncementTargetUser, Value: idString(s.parent.ID)}}
+	if err := announcements.Update(announcement, s.teacher.ID); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if seesAnnouncement(t, announcements, s.parent.ID, announcement.ID) == nil || seesAnnouncement(t, announcements, s.third.ID, announcement.ID) != nil {
+		t.Error("retargeted announcement not redelivered")
+	}
+}
+
+func TestAnnouncementSearchOnlyFindsReceived(t *testing.T) {
+	newAnnouncementService(t, nil)
+	search := service.NewSearchService(repository.NewAnnouncementRepository(), repository.NewPaymentRepository(), repository.NewStudentRepository())
+	student := createTestUser(t, models.RoleStudent, true)
+	now := time.Now().Unix()
+	word := fmt.Sprintf("fieldtrip%d", time.Now().UnixNano())
+
+	announcements := []struct {
+		name     string
+		a        models.Announcement
+		received bool
+	}{
+		{"received", models.Announcement{PublishedAt: now - 60}, true},
+		{"not received", models.Announcement{PublishedAt: now - 60}, false},
+		{"not yet published", models.Announcement{PublishAt: now + 3600}, true},
+		{"expired", models.Announcement{PublishedAt: now - 60, ExpiresAt: now - 1}, true},
+	}
+	for i := range announcements {
+		a := &announcements[i].a
+		a.Title, a.Content, a.Audience, a.Priority, a.IsActive = word+" "+announcements[i].name, "Details", "targeted", "normal", true
+		if err := testDB.Create(a).Error; err != nil {
+			t.Fatalf("create announcement: %v", err)
+		}
+		if announcements[i].received {
+			testDB.Create(&models.AnnouncementRecipient{AnnouncementID: a.ID, UserID: student.ID})
+		}
+	}
+
+	found, total, err := search.SearchAnnouncementsAdvanced(student.ID, word, "", "", 1, 20)
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	if total != 1 || len(found) != 1 || found[0].ID != announcements[0].a.ID {
+		t.Errorf("student found %d (%+v), want only the live announcement they received", total, found)
+	}
+
+	if _, total, _ := search.SearchAnnouncementsAdvanced(0, word, "", "", 1, 20); total != int64(len(announcements)) {
+		t.Errorf("admin found %d, want every active announcement", total)
+	}
+}
@@ -0,0 +1,269 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"school-management-system/internal/audit"
+	"school-management-system/internal/models"
+	"school-management-system/internal/repository"
+	"school-management-system/internal/service"
+)
+
+// auditRouter serves minimal grade and user routes behind the audit
+// middleware, acting as the given user. Handlers write with the request
+// context, as services given c.Request.Context() do.
+func auditRouter(actorID uint) *gin.Engine {
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set("user_id", actorID)
+		c.Set("request_id", "req-"+strconv.FormatInt(time.Now().UnixNano(), 10))
+		c.Next()
+	}, audit.Middleware())
+
+	r.POST("/grades", func(c *gin.Context) {
+		var grade models.Grade
+		if err := c.ShouldBindJSON(&grade); err != nil || grade.StudentID == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid grade"})
+			return
+		}
+		testDB.WithContext(c.Request.Context()).Create(&grade)
+		c.JSON(http.StatusCreated, gin.H{"message": "Grade recorded successfully", "grade": grade})
+	})
+	r.PUT("/grades/:id", func(c *gin.Context) {
+		var req struct {
+			Score float64 `json:"score"`
+		}
+		c.ShouldBindJSON(&req)
+		testDB.WithContext(c.Request.Context()).Model(&models.Grade{}).Where("id = ?", c.Param("id")).Update("score", req.Score)
+		c.JSON(http.StatusOK, gin.H{"message": "Grade updated successfully"})
+	})
+	r.DELETE("/grades/:id", func(c *gin.Context) {
+		testDB.WithContext(c.Request.Context()).Delete(&models.Grade{}, c.Param("id"))
+		c.JSON(http.StatusOK, gin.H{"message": "Grade deleted successfully"})
+	})
+	r.PUT("/profile", func(c *gin.Context) {
+		testDB.WithContext(c.Request.Context()).Model(&models.User{}).Where("id = ?", c.GetUint("user_id")).Update("phone", "5550001111")
+		c.JSON(http.StatusOK, gin.H{"message": "Profile updated successfully"})
+	})
+	return r
+}
+
+func auditRequest(r *gin.Engine, method, path, body string) int {
+	req, _ := http.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.RemoteAddr = "203.0.113.7:4321"
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w.Code
+}
+
+func TestAuditTrailRecordsChanges(t *testing.T) {
+	actor := createTestUser(t, models.RoleTeacher, true)
+	r := auditRouter(actor.ID)
+	repo := repository.NewAuditLogRepository()
+
+	if code := auditRequest(r, http.MethodPost, "/grades", `{"student_id":9001,"course_id":7,"grade":"B","score":84}`); code != http.StatusCreated {
+		t.Fatalf("create grade: got %d", code)
+	}
+	var grade models.Grade
+	if err := testDB.Where("student_id = ?", 9001).Last(&grade).Error; err != nil {
+		t.Fatalf("grade not stored: %v", err)
+	}
+	path := "/grades/" + strconv.Itoa(int(grade.ID))
+
+	steps := []struct {
+		method, path, body string
+		status             int
+	}{
+		{http.MethodPut, path, `{"score":91}`, http.StatusOK},
+		{http.MethodPut, path, `{"score":91}`, http.StatusOK}, // unchanged, not audited
+		{http.MethodPost, "/grades", `{"course_id":7}`, http.StatusBadRequest},
+		{http.MethodDelete, path, "", http.StatusOK},
+	}
+	for _, s := range steps {
+		if code := auditRequest(r, s.method, s.path, s.body); code != s.status {
+			t.Fatalf("%s %s: got %d, want %d", s.method, s.path, code, s.status)
+		}
+	}
+
+	logs, err := repo.FindByEntity("grade", grade.ID)
+	if err != nil {
+		t.Fatalf("FindByEntity: %v", err)
+	}
+	// Newest first
+	wantActions := []string{models.AuditActionDelete, models.AuditActionUpdate, models.AuditActionCreate}
+	if len(logs) != len(wantActions) {
+		t.Fatalf("got %d audit entries, want %d", len(logs), len(wantActions))
+	}
+	for i, want := range wantActions {
+		log := logs[i]
+		if log.Action != want {
+			t.Errorf("entry %d: action %q, want %q", i, log.Action, want)
+		}
+		if log.UserID != actor.ID || log.RequestID == "" || log.IPAddress != "203.0.113.7" {
+			t.Errorf("entry %d: missing actor, request ID or IP: %+v", i, log)
+		}
+	}
+
+	var before, after map[string]interface{}
+	json.Unmarshal([]byte(logs[1].OldValue), &before)
+	json.Unmarshal([]byte(logs[1].NewValue), &after)
+	if before["score"] != 84.0 || after["score"] != 91.0 {
+		t.Errorf("update values: old score %v, new score %v", before["score"], after["score"])
+	}
+	if logs[2].OldValue != "" || logs[2].NewValue == "" {
+		t.Errorf("create should only have a new value: %+v", logs[2])
+	}
+	if logs[0].OldValue == "" || logs[0].NewValue != "" {
+		t.Errorf("delete should only have an old value: %+v", logs[0])
+	}
+}
+
+func TestAuditTrailRedactsSecrets(t *testing.T) {
+	user := createTestUser(t, models.RoleStudent, true)
+	r := auditRouter(user.ID)
+
+	if code := auditRequest(r, http.MethodPut, "/profile", `{}`); code != http.StatusOK {
+		t.Fatalf("update profile: got %d", code)
+	}
+
+	// The user's creation, then the profile update
+	logs, err := repository.NewAuditLogRepository().FindByEntity("user", user.ID)
+	if err != nil || len(logs) != 2 || logs[0].Action != models.AuditActionUpdate {
+		t.Fatalf("got %d audit entries (err %v), want a create and an update", len(logs), err)
+	}
+	for _, log := range logs {
+		if strings.Contains(log.OldValue, "$2a$") || strings.Contains(log.NewValue, "$2a$") {
+			t.Errorf("password hash written to the audit log on %s", log.Action)
+		}
+	}
+	if !strings.Contains(logs[0].NewValue, "5550001111") {
+		t.Errorf("new value missing the change: %s", logs[0].NewValue)
+	}
+}
+
+// Changes made outside a request, by jobs or imports, are audited too
+func TestAuditTrailOutsideRequests(t *testing.T) {
+	testDB.AutoMigrate(&models.Payment{})
+	repo := repository.NewAuditLogRepository()
+
+	t.Run("bulk change without an actor is the system's", func(t *testing.T) {
+		payments := []models.Payment{
+			{StudentID: 9101, Amount: 10, Status: models.PaymentStatusPending},
+			{StudentID: 9101, Amount: 20, Status: models.PaymentStatusPending},
+		}
+		if err := testDB.Omit("Student").Create(&payments).Error; err != nil {
+			t.Fatalf("create payments: %v", err)
+		}
+		testDB.Model(&models.Payment{}).Where("student_id = ?", 9101).Update("status", models.PaymentStatusOverdue)
+
+		for _, p := range payments {
+			logs, _ := repo.FindByEntity("payment", p.ID)
+			if len(logs) != 2 || logs[0].Action != models.AuditActionUpdate || logs[1].Action != models.AuditActionCreate {
+				t.Fatalf("payment %d: got %+v, want an update and a create", p.ID, logs)
+			}
+			if logs[0].UserID != 0 || !strings.Contains(logs[0].NewValue, models.PaymentStatusOverdue) {
+				t.Errorf("payment %d: want a system update to overdue, got %+v", p.ID, logs[0])
+			}
+		}
+	})
+
+	t.Run("rolled back change is not logged", func(t *testing.T) {
+		ctx := audit.WithActor(context.Background(), audit.Actor{UserID: 1})
+		var grade models.Grade
+		testDB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			grade = models.Grade{StudentID: 9102, CourseID: 7, Score: 50}
+			if err := tx.Create(&grade).Error; err != nil {
+				return err
+			}
+			return errors.New("roll back")
+		})
+		if logs, _ := repo.FindByEntity("grade", grade.ID); grade.ID == 0 || len(logs) != 0 {
+			t.Errorf("grade %d: got %d audit entries, want 0", grade.ID, len(logs))
+		}
+	})
+
+	t.Run("import is attributed to whoever started it", func(t *testing.T) {
+		actor := createTestUser(t, models.RoleAdmin, true)
+		ctx := audit.WithActor(context.Background(), audit.Actor{UserID: actor.ID, RequestID: "req-import"})
+		email := uniqueEmail("audited_import")
+		batch := &models.ImportBatch{EntityType: models.ImportEntityStudent, FileName: "students.csv", Status: models.ImportStatusPending}
+		repository.NewImportBatchRepository().Create(batch)
+		newImportService(t).Process(ctx, batch, []byte("student_id,email,first_name,last_name\nAUD"+strconv.FormatInt(time.Now().UnixNano(), 10)+","+email+",Ada,Lovelace\n"))
+
+		var user models.User
+		if err := testDB.Where("email = ?", email).First(&user).Error; err != nil {
+			t.Fatalf("student not imported: %v (batch errors %s)", err, batch.Errors)
+		}
+		logs, _ := repo.FindByEntity("user", user.ID)
+		if len(logs) == 0 || logs[len(logs)-1].Action != models.AuditActionCreate {
+			t.Fatalf("got %+v, want the user's creation logged", logs)
+		}
+		if log := logs[len(logs)-1]; log.UserID != actor.ID || log.RequestID != "req-import" {
+			t.Errorf("creation attributed to user %d, request %q", log.UserID, log.RequestID)
+		}
+	})
+}
+
+func TestAuditLogSearchAndRetention(t *testing.T) {
+	testDB.Exec("DELETE FROM audit_logs")
+	svc := service.NewAuditLogService(repository.NewAuditLogRepository())
+
+	now := time.Now()
+	entries := []models.AuditLog{
+		{UserID: 1, Action: models.AuditActionCreate, Entity: "payment", EntityID: 10, CreatedAt: now.Unix()},
+		{UserID: 1, Action: models.AuditActionUpdate, Entity: "payment", EntityID: 10, CreatedAt: now.AddDate(0, 0, -3).Unix()},
+		{UserID: 2, Action: models.AuditActionUpdate, Entity: "enrollment", EntityID: 4, CreatedAt: now.AddDate(0, 0, -10).Unix()},
+		{UserID: 2, Action: models.AuditActionDelete, Entity: "attendance", EntityID: 5, CreatedAt: now.AddDate(0, 0, -400).Unix()},
+	}
+	for i := range entries {
+		if err := svc.Log(&entries[i]); err != nil {
+			t.Fatalf("Log: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		filter repository.AuditLogFilter
+		want   int64
+	}{
+		{"all", repository.AuditLogFilter{}, 4},
+		{"by user", repository.AuditLogFilter{UserID: 2}, 2},
+		{"by entity", repository.AuditLogFilter{Entity: "payment"}, 2},
+		{"by record", repository.AuditLogFilter{Entity: "payment", EntityID: 10, Action: models.AuditActionUpdate}, 1},
+		{"since", repository.AuditLogFilter{From: now.AddDate(0, 0, -5).Unix()}, 2},
+		{"date range", repository.AuditLogFilter{From: now.AddDate(0, 0, -30).Unix(), To: now.AddDate(0, 0, -1).Unix()}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs, total, err := svc.Search(tt.filter, 1, 2)
+			if err != nil {
+				t.Fatalf("Search: %v", err)
+			}
+			if total != tt.want {
+				t.Errorf("total %d, want %d", total, tt.want)
+			}
+			if int64(len(logs)) > 2 || (total > 0 && len(logs) == 0) {
+				t.Errorf("page has %d entries", len(logs))
+			}
+		})
+	}
+
+	if err := svc.CleanupOldLogs(365); err != nil {
+		t.Fatalf("CleanupOldLogs: %v", err)
+	}
+	if _, total, _ := svc.GetAll(1, 10); total != 3 {
+		t.Errorf("after cleanup %d entries remain, want 3", total)
+	}
+}
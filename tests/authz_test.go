@@ -0,0 +1,241 @@
+package tests
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"school-management-system/internal/authz"
+	"school-management-system/internal/models"
+)
+
+// fakeRelations is a small school: teacher 1 teaches course 10 (section 100),
+// teacher 2 teaches course 20. Student 5 is enrolled in section 100, student
+// 6 in course 20. Parent user 30 is linked to student 5.
+type fakeRelations struct{}
+
+func (fakeRelations) StudentIDForUser(userID uint) uint {
+	return map[uint]uint{50: 5, 60: 6}[userID]
+}
+
+func (fakeRelations) TeacherIDForUser(userID uint) uint {
+	return map[uint]uint{11: 1, 12: 2}[userID]
+}
+
+func (fakeRelations) ChildrenOfUser(userID uint) []uint {
+	if userID == 30 {
+		return []uint{5}
+	}
+	return nil
+}
+
+func (fakeRelations) CourseOfSection(sectionID uint) uint {
+	if sectionID == 100 {
+		return 10
+	}
+	return 0
+}
+
+func (fakeRelations) TeachesCourse(teacherID, courseID uint) bool {
+	return (teacherID == 1 && courseID == 10) || (teacherID == 2 && courseID == 20)
+}
+
+func (fakeRelations) TeachesStudent(teacherID, studentID, courseID uint) bool {
+	switch {
+	case teacherID == 1 && studentID == 5:
+		return courseID == 0 || courseID == 10
+	case teacherID == 2 && studentID == 6:
+		return courseID == 0 || courseID == 20
+	}
+	return false
+}
+
+func (fakeRelations) Lookup(kind authz.Kind, id uint) (authz.Resource, bool) {
+	return authz.Resource{Kind: kind}, false
+}
+
+func TestAuthzPolicy(t *testing.T) {
+	p := authz.NewPolicy(fakeRelations{})
+
+	admin := p.SubjectFor(1, models.RoleAdmin)
+	teacher := p.SubjectFor(11, models.RoleTeacher)
+	otherTeacher := p.SubjectFor(12, models.RoleTeacher)
+	student := p.SubjectFor(50, models.RoleStudent)
+	otherStudent := p.SubjectFor(60, models.RoleStudent)
+	parent := p.SubjectFor(30, models.RoleParent)
+	strangerParent := p.SubjectFor(31, models.RoleParent)
+
+	own := func(kind authz.Kind) authz.Resource {
+		return authz.Resource{Kind: kind, StudentID: 5, CourseID: 10}
+	}
+	inSection := func(kind authz.Kind) authz.Resource {
+		return authz.Resource{Kind: kind, StudentID: 5, SectionID: 100}
+	}
+	course := func(kind authz.Kind) authz.Resource {
+		return authz.Resource{Kind: kind, CourseID: 10}
+	}
+	all := func(kind authz.Kind) authz.Resource {
+		return authz.Resource{Kind: kind}
+	}
+
+	tests := []struct {
+		name    string
+		subject authz.Subject
+		action  authz.Action
+		res     authz.Resource
+		want    bool
+	}{
+		// Admins
+		{"admin lists every payment", admin, authz.ActionList, all(authz.KindPayment), true},
+		{"admin deletes a student", admin, authz.ActionDelete, own(authz.KindStudent), true},
+		{"admin creates a course", admin, authz.ActionCreate, all(authz.KindCourse), true},
+
+		// Users
+		{"user reads self", student, authz.ActionRead, authz.Resource{Kind: authz.KindUser, OwnerUserID: 50}, true},
+		{"user updates self", student, authz.ActionUpdate, authz.Resource{Kind: authz.KindUser, OwnerUserID: 50}, true},
+		{"user reads another user", student, authz.ActionRead, authz.Resource{Kind: authz.KindUser, OwnerUserID: 60}, false},
+		{"user deletes self", student, authz.ActionDelete, authz.Resource{Kind: authz.KindUser, OwnerUserID: 50}, false},
+		{"teacher lists users", teacher, authz.ActionList, all(authz.KindUser), false},
+
+		// Students see only their own records
+		{"student reads own grades", student, authz.ActionRead, own(authz.KindGrade), true},
+		{"student reads own attendance", student, authz.ActionRead, own(authz.KindAttendance), true},
+		{"student reads own payments", student, authz.ActionRead, own(authz.KindPayment), true},
+		{"student reads own transcript", student, authz.ActionRead, own(authz.KindTranscript), true},
+		{"student reads another's grades", otherStudent, authz.ActionRead, own(authz.KindGrade), false},
+		{"student reads another's payments", otherStudent, authz.ActionRead, own(authz.KindPayment), false},
+		{"student lists a course's grades", student, authz.ActionList, course(authz.KindGrade), false},
+		{"student lists every student", student, authz.ActionList, all(authz.KindStudent), false},
+		{"student grades self", student, authz.ActionCreate, own(authz.KindGrade), false},
+		{"student updates own profile record", student, authz.ActionUpdate, own(authz.KindStudent), false},
+		{"student enrolls self outside registration", student, authz.ActionCreate, own(authz.KindEnrollment), false},
+		{"student enrolls another", otherStudent, authz.ActionCreate, own(authz.KindEnrollment), false},
+		{"student submits own work", student, authz.ActionCreate, own(authz.KindSubmission), true},
+		{"student submits for another", otherStudent, authz.ActionCreate, own(authz.KindSubmission), false},
+		{"student grades own submission", student, authz.ActionGrade, own(authz.KindSubmission), false},
+
+		// Parents see only linked children
+		{"parent reads child's grades", parent, authz.ActionRead, own(authz.KindGrade), true},
+		{"parent reads child's payments", parent, authz.ActionRead, own(authz.KindPayment), true},
+		{"parent reads child's transcript", parent, authz.ActionRead, own(authz.KindTranscript), true},
+		{"parent reads unlinked student", strangerParent, authz.ActionRead, own(authz.KindGrade), false},
+		{"parent reads other student", parent, authz.ActionRead, authz.Resource{Kind: authz.KindGrade, StudentID: 6}, false},
+		{"parent records attendance", parent, authz.ActionCreate, own(authz.KindAttendance), false},
+		{"parent enrolls child", parent, authz.ActionCreate, own(authz.KindEnrollment), false},
+
+		// Teachers act only on students enrolled in their courses
+		{"teacher grades enrolled student", teacher, authz.ActionCreate, own(authz.KindGrade), true},
+		{"teacher grades through section", teacher, authz.ActionCreate, inSection(authz.KindGrade), true},
+		{"teacher records attendance", teacher, authz.ActionCreate, own(authz.KindAttendance), true},
+		{"teacher grades submission", teacher, authz.ActionGrade, own(authz.KindSubmission), true},
+		{"teacher grades student of another course", otherTeacher, authz.ActionCreate, own(authz.KindGrade), false},
+		{"teacher grades unenrolled student", teacher, authz.ActionCreate, authz.Resource{Kind: authz.KindGrade, StudentID: 6, CourseID: 10}, false},
+		{"teacher grades without a course", teacher, authz.ActionCreate, authz.Resource{Kind: authz.KindGrade, StudentID: 5}, false},
+		{"teacher reads taught student's grades", teacher, authz.ActionRead, own(authz.KindGrade), true},
+		{"teacher reads untaught student's grades", otherTeacher, authz.ActionRead, own(authz.KindGrade), false},
+		{"teacher reads taught student's payments", teacher, authz.ActionRead, own(authz.KindPayment), false},
+		{"teacher lists own course grades", teacher, authz.ActionList, course(authz.KindGrade), true},
+		{"teacher lists section enrollments", teacher, authz.ActionList, authz.Resource{Kind: authz.KindEnrollment, SectionID: 100}, true},
+		{"teacher lists other course grades", otherTeacher, authz.ActionList, course(authz.KindGrade), false},
+		{"teacher lists every grade", teacher, authz.ActionList, all(authz.KindGrade), false},
+		{"teacher enrolls a student", teacher, authz.ActionCreate, own(authz.KindEnrollment), false},
+		{"teacher edits own gradebook", teacher, authz.ActionUpdate, course(authz.KindGradebook), true},
+		{"teacher edits other gradebook", otherTeacher, authz.ActionUpdate, course(authz.KindGradebook), false},
+		{"teacher creates assignment", teacher, authz.ActionCreate, course(authz.KindAssignment), true},
+		{"teacher creates assignment elsewhere", otherTeacher, authz.ActionCreate, course(authz.KindAssignment), false},
+		{"teacher updates rubric", teacher, authz.ActionUpdate, course(authz.KindRubric), true},
+		{"teacher runs reports", teacher, authz.ActionCreate, all(authz.KindReport), false},
+
+		// Shared catalogue
+		{"student reads courses", student, authz.ActionRead, course(authz.KindCourse), true},
+		{"teacher creates course", teacher, authz.ActionCreate, all(authz.KindCourse), false},
+		{"parent reads timetable", parent, authz.ActionList, all(authz.KindTimetable), true},
+		{"teacher edits timetable", teacher, authz.ActionUpdate, all(authz.KindTimetable), false},
+		{"student reads assignments", student, authz.ActionRead, course(authz.KindAssignment), true},
+		{"student creates assignment", student, authz.ActionCreate, course(authz.KindAssignment), false},
+
+		// Announcements
+		{"anyone reads announcements", parent, authz.ActionList, all(authz.KindAnnouncement), true},
+		{"teacher posts announcement", teacher, authz.ActionCreate, all(authz.KindAnnouncement), true},
+		{"student posts announcement", student, authz.ActionCreate, all(authz.KindAnnouncement), false},
+		{"author edits announcement", teacher, authz.ActionUpdate, authz.Resource{Kind: authz.KindAnnouncement, OwnerUserID: 11}, true},
+		{"non-author edits announcement", otherTeacher, authz.ActionUpdate, authz.Resource{Kind: authz.KindAnnouncement, OwnerUserID: 11}, false},
+
+		// Bookings
+		{"anyone lists bookings", student, authz.ActionList, all(authz.KindBooking), true},
+		{"teacher books a room", teacher, authz.ActionCreate, all(authz.KindBooking), true},
+		{"student books a room", student, authz.ActionCreate, all(authz.KindBooking), false},
+		{"booker cancels booking", teacher, authz.ActionUpdate, authz.Resource{Kind: authz.KindBooking, OwnerUserID: 11}, true},
+		{"someone else cancels booking", otherTeacher, authz.ActionUpdate, authz.Resource{Kind: authz.KindBooking, OwnerUserID: 11}, false},
+
+		// Notifications, by recipient user
+		{"teacher notifies own student", teacher, authz.ActionCreate, authz.Resource{Kind: authz.KindNotification, OwnerUserID: 50}, true},
+		{"teacher notifies own student's parent", teacher, authz.ActionCreate, authz.Resource{Kind: authz.KindNotification, OwnerUserID: 30}, true},
+		{"teacher notifies another class's student", teacher, authz.ActionCreate, authz.Resource{Kind: authz.KindNotification, OwnerUserID: 60}, false},
+		{"teacher notifies an unrelated user", teacher, authz.ActionCreate, authz.Resource{Kind: authz.KindNotification, OwnerUserID: 12}, false},
+		{"student notifies a classmate", student, authz.ActionCreate, authz.Resource{Kind: authz.KindNotification, OwnerUserID: 50}, false},
+		{"admin notifies anyone", admin, authz.ActionCreate, authz.Resource{Kind: authz.KindNotification, OwnerUserID: 60}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := p.Can(tt.subject, tt.action, tt.res); got != tt.want {
+				t.Errorf("Can(%s, %s, %+v) = %v, want %v", tt.subject.Role, tt.action, tt.res, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthzSubjectFor(t *testing.T) {
+	p := authz.NewPolicy(fakeRelations{})
+
+	if s := p.SubjectFor(50, models.RoleStudent); s.StudentID != 5 {
+		t.Errorf("expected student 5, got %d", s.StudentID)
+	}
+	if s := p.SubjectFor(11, models.RoleTeacher); s.TeacherID != 1 {
+		t.Errorf("expected teacher 1, got %d", s.TeacherID)
+	}
+	if s := p.SubjectFor(30, models.RoleParent); len(s.Children) != 1 || s.Children[0] != 5 {
+		t.Errorf("expected child 5, got %v", s.Children)
+	}
+	// A student account with no student profile owns nothing.
+	unlinked := p.SubjectFor(99, models.RoleStudent)
+	if p.Can(unlinked, authz.ActionRead, authz.Resource{Kind: authz.KindGrade}) {
+		t.Errorf("unlinked student should not read unscoped grades")
+	}
+}
+
+func TestDBRelationsTeachesStudent(t *testing.T) {
+	testDB.AutoMigrate(&models.CourseSection{}, &models.Enrollment{})
+	rel := authz.NewDBRelations(testDB)
+	teacherID := uint(time.Now().UnixNano()%1000000000) + 2000000
+	course := &models.Course{Name: "Course", CourseCode: fmt.Sprintf("AZ%d", time.Now().UnixNano()), TeacherID: teacherID}
+	if err := testDB.Create(course).Error; err != nil {
+		t.Fatalf("create course: %v", err)
+	}
+
+	tests := []struct {
+		status string
+		want   bool
+	}{
+		{models.EnrollmentActive, true},
+		{models.EnrollmentApproved, true},
+		{models.EnrollmentCompleted, true},
+		{models.EnrollmentRequested, false},
+		{models.EnrollmentWaitlisted, false},
+		{models.EnrollmentDropped, false},
+		{models.EnrollmentRejected, false},
+		{models.EnrollmentWithdrawn, false},
+	}
+	for i, tt := range tests {
+		t.Run(tt.status, func(t *testing.T) {
+			studentID := teacherID + uint(i)
+			testDB.Create(&models.Enrollment{StudentID: studentID, CourseID: course.ID, Status: tt.status, EnrolledAt: time.Now()})
+			if got := rel.TeachesStudent(teacherID, studentID, course.ID); got != tt.want {
+				t.Errorf("TeachesStudent = %v, want %v", got, tt.want)
+			}
+		})
+	}
+	// Keep the approved and waitlisted rows away from other tests' jobs
+	testDB.Where("course_id = ?", course.ID).Delete(&models.Enrollment{})
+}
@@ -0,0 +1,177 @@
+package tests
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	glebarez "github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"school-management-system/internal/backup"
+	"school-management-system/internal/models"
+	"school-management-system/internal/repository"
+	"school-management-system/internal/service"
+)
+
+var backupTestModels = []interface{}{&models.User{}, &models.Course{}, &models.Enrollment{}}
+
+func openBackupTestDB(t *testing.T, path string) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(glebarez.Open(path), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("open %s: %v", path, err)
+	}
+	if err := db.AutoMigrate(backupTestModels...); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return db
+}
+
+func seedBackupTestDB(t *testing.T, db *gorm.DB) {
+	t.Helper()
+	user := &models.User{FirstName: "Ada", LastName: "Lovelace", Email: "ada@example.com", Password: "password123", Role: models.RoleStudent, IsActive: true}
+	if err := db.Create(user).Error; err != nil {
+		t.Fatalf("seed user: %v", err)
+	}
+	for i, code := range []string{"MATH101", "PHYS101", "CHEM101"} {
+		course := &models.Course{CourseCode: code, Name: "Course " + code, CreditHours: 3 + i}
+		if err := db.Create(course).Error; err != nil {
+			t.Fatalf("seed course: %v", err)
+		}
+	}
+}
+
+func TestBackupAndRestore(t *testing.T) {
+	for _, format := range []string{backup.FormatSQLite, backup.FormatJSON} {
+		t.Run(format, func(t *testing.T) {
+			dir := t.TempDir()
+			src := openBackupTestDB(t, filepath.Join(dir, "source.db"))
+			seedBackupTestDB(t, src)
+
+			result, err := backup.Create(src, format, dir, "snapshot.gz")
+			if err != nil {
+				t.Fatalf("Create: %v", err)
+			}
+			if result.Size == 0 || len(result.Checksum) != 64 {
+				t.Fatalf("missing size or checksum: %+v", result)
+			}
+			if _, err := os.Stat(result.Path + ".part"); !os.IsNotExist(err) {
+				t.Error("partial file left behind")
+			}
+
+			manifest, err := backup.Verify(result.Path, result.Checksum)
+			if err != nil {
+				t.Fatalf("Verify: %v", err)
+			}
+			counts := map[string]int64{}
+			for _, table := range manifest.Tables {
+				counts[table.Name] = table.Rows
+			}
+			if counts["users"] != 1 || counts["courses"] != 3 {
+				t.Errorf("manifest counts: %v", counts)
+			}
+
+			target := openBackupTestDB(t, filepath.Join(dir, "target.db"))
+			if _, err := backup.Restore(result.Path, result.Checksum, target); err != nil {
+				t.Fatalf("Restore: %v", err)
+			}
+			var user models.User
+			if err := target.Where("email = ?", "ada@example.com").First(&user).Error; err != nil {
+				t.Fatalf("restored user missing: %v", err)
+			}
+			if !user.CheckPassword("password123") || !user.IsActive {
+				t.Error("restored user does not match the original")
+			}
+			var course models.Course
+			target.Where("course_code = ?", "CHEM101").First(&course)
+			if course.CreditHours != 5 {
+				t.Errorf("restored course credits %d, want 5", course.CreditHours)
+			}
+
+			// Restoring twice would duplicate data
+			if _, err := backup.Restore(result.Path, "", target); !errors.Is(err, backup.ErrTargetNotEmpty) {
+				t.Errorf("restore into a non-empty database: got %v", err)
+			}
+			if _, err := backup.Restore(result.Path, "0000", openBackupTestDB(t, filepath.Join(dir, "other.db"))); err == nil {
+				t.Error("restore with a wrong checksum succeeded")
+			}
+		})
+	}
+}
+
+func TestBackupVerifyDetectsCorruption(t *testing.T) {
+	dir := t.TempDir()
+	src := openBackupTestDB(t, filepath.Join(dir, "source.db"))
+	seedBackupTestDB(t, src)
+
+	result, err := backup.Create(src, backup.FormatJSON, dir, "snapshot.gz")
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	data, _ := os.ReadFile(result.Path)
+	if err := os.WriteFile(result.Path, data[:len(data)/2], 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := backup.Verify(result.Path, result.Checksum); err == nil {
+		t.Error("truncated backup passed verification")
+	}
+	if _, err := backup.Verify(result.Path, ""); err == nil {
+		t.Error("truncated backup passed verification without a checksum")
+	}
+}
+
+func TestBackupServiceRetention(t *testing.T) {
+	testDB.Exec("DELETE FROM backups")
+	dir := t.TempDir()
+	svc := service.NewBackupService(repository.NewBackupRepository(), testDB, service.BackupOptions{
+		Dir:            dir,
+		RetentionCount: 2,
+		RetentionDays:  7,
+	})
+
+	var records []*models.Backup
+	for i := 0; i < 3; i++ {
+		record, err := svc.Run(nil, models.BackupTriggerScheduled, "test")
+		if err != nil {
+			t.Fatalf("Run: %v", err)
+		}
+		if record.Status != models.BackupStatusCompleted || record.Checksum == "" || record.Size == 0 {
+			t.Fatalf("backup not completed: %+v", record)
+		}
+		records = append(records, record)
+	}
+
+	// The oldest is beyond the newest two but still within 7 days
+	if _, err := svc.GetByID(records[0].ID); err != nil {
+		t.Fatal("backup within the retention period was deleted")
+	}
+
+	testDB.Model(&models.Backup{}).Where("id = ?", records[0].ID).Update("created_at", time.Now().AddDate(0, 0, -8).Unix())
+	if err := svc.ApplyRetention(); err != nil {
+		t.Fatalf("ApplyRetention: %v", err)
+	}
+	if _, err := svc.GetByID(records[0].ID); err == nil {
+		t.Error("expired backup was kept")
+	}
+	if _, err := os.Stat(records[0].Location); !os.IsNotExist(err) {
+		t.Error("expired backup file was kept")
+	}
+
+	if _, err := svc.Verify(records[2].ID); err != nil {
+		t.Errorf("Verify: %v", err)
+	}
+	latest, err := svc.GetLatestCompleted()
+	if err != nil || latest.ID != records[2].ID || latest.VerifiedAt == 0 {
+		t.Errorf("latest backup: %+v, %v", latest, err)
+	}
+}
@@ -0,0 +1,248 @@
+package tests
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"school-management-system/internal/mail"
+	"school-management-system/internal/models"
+	"school-management-system/internal/repository"
+	"school-management-system/internal/service"
+)
+
+func newTemplateService(t *testing.T) service.EmailTemplateService {
+	t.Helper()
+	testDB.AutoMigrate(&models.EmailTemplate{})
+	testDB.Exec("DELETE FROM email_templates")
+	svc := service.NewEmailTemplateService(repository.NewEmailTemplateRepository())
+	if err := svc.SeedDefaults(); err != nil {
+		t.Fatalf("SeedDefaults: %v", err)
+	}
+	return svc
+}
+
+func TestHTMLToText(t *testing.T) {
+	tests := []struct {
+		name string
+		html string
+		want string
+	}{
+		{"paragraphs", "<html><body><h2>Hi</h2><p>One\n   two</p><p>Three</p></body></html>", "Hi\n\nOne two\n\nThree"},
+		{"line breaks", "<p>a<br/>b</p>", "a\nb"},
+		{"links keep their address", `<p><a href="https://x.test/r?t=1">Reset</a></p>`, "Reset (https://x.test/r?t=1)"},
+		{"lists", "<ul><li>one</li><li>two</li></ul>", "- one\n- two"},
+		{"entities and styles", "<style>p{color:red}</style><p>Tom &amp; Jerry &lt;3</p>", "Tom & Jerry <3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mail.HTMLToText(tt.html); got != tt.want {
+				t.Errorf("HTMLToText() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeLocale(t *testing.T) {
+	tests := []struct {
+		in, want string
+		ok       bool
+	}{
+		{"", "en", true},
+		{"FR", "fr", true},
+		{"fr_ca", "fr-CA", true},
+		{"es-419", "es-419", true},
+		{"english", "", false},
+		{"en-US-x", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := service.NormalizeLocale(tt.in)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("NormalizeLocale(%q) = %q, %v; want %q, %v", tt.in, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestEmailTemplateEscaping(t *testing.T) {
+	svc := newTemplateService(t)
+
+	rendered, err := svc.Render(service.EmailAnnouncement, "en", map[string]interface{}{
+		"Title":   `Fees <script>alert(1)</script>`,
+		"Content": "Line one & <b>bold</b>\nLine two",
+	})
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	if strings.Contains(rendered.HTMLBody, "<script>") || strings.Contains(rendered.HTMLBody, "<b>") {
+		t.Errorf("announcement was not escaped:\n%s", rendered.HTMLBody)
+	}
+	if !strings.Contains(rendered.HTMLBody, "Line one &amp; &lt;b&gt;bold&lt;/b&gt;<br/>\nLine two") {
+		t.Errorf("expected escaped content with line breaks kept:\n%s", rendered.HTMLBody)
+	}
+	if rendered.Subject != "New Announcement: Fees <script>alert(1)</script>" {
+		t.Errorf("subject should be plain text, got %q", rendered.Subject)
+	}
+	if !strings.Contains(rendered.TextBody, "Line one & <b>bold</b>\nLine two") {
+		t.Errorf("text alternative should carry the content as written:\n%s", rendered.TextBody)
+	}
+}
+
+func TestEmailTemplateLocales(t *testing.T) {
+	svc := newTemplateService(t)
+
+	french := &models.EmailTemplate{
+		Key:      service.EmailGradePosted,
+		Locale:   "FR",
+		Subject:  "Nouvelle note - {{.CourseName}}",
+		HTMLBody: "<p>Bonjour {{.Name}}, note : {{.Grade}}</p>",
+		TextBody: "Bonjour {{.Name}}, note : {{.Grade}}",
+	}
+	if err := svc.Save(french, 1); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if french.Locale != "fr" {
+		t.Errorf("expected locale normalized to fr, got %s", french.Locale)
+	}
+
+	data := map[string]interface{}{"Name": "Zoé", "CourseName": "Chimie", "Grade": "B"}
+	tests := []struct {
+		locale      string
+		wantLocale  string
+		wantSubject string
+	}{
+		{"fr", "fr", "Nouvelle note - Chimie"},
+		{"fr-CA", "fr", "Nouvelle note - Chimie"},
+		{"de", "en", "New Grade Posted - Chimie"},
+		{"", "en", "New Grade Posted - Chimie"},
+		{"not a locale", "en", "New Grade Posted - Chimie"},
+	}
+	for _, tt := range tests {
+		rendered, err := svc.Render(service.EmailGradePosted, tt.locale, data)
+		if err != nil {
+			t.Fatalf("Render(%q): %v", tt.locale, err)
+		}
+		if rendered.Locale != tt.wantLocale || rendered.Subject != tt.wantSubject {
+			t.Errorf("Render(%q) = %s %q, want %s %q", tt.locale, rendered.Locale, rendered.Subject, tt.wantLocale, tt.wantSubject)
+		}
+	}
+
+	invalid := []struct {
+		name     string
+		template models.EmailTemplate
+		wantErr  error
+	}{
+		{"unknown field", models.EmailTemplate{Key: service.EmailGradePosted, Locale: "es", Subject: "Nota", HTMLBody: "{{.Nmae}}"}, service.ErrInvalidEmailTemplate},
+		{"syntax error", models.EmailTemplate{Key: service.EmailGradePosted, Locale: "es", Subject: "Nota {{", HTMLBody: "x"}, service.ErrInvalidEmailTemplate},
+		{"missing body", models.EmailTemplate{Key: service.EmailGradePosted, Locale: "es", Subject: "Nota"}, service.ErrInvalidEmailTemplate},
+		{"bad locale", models.EmailTemplate{Key: service.EmailGradePosted, Locale: "spanish", Subject: "Nota", HTMLBody: "x"}, service.ErrInvalidLocale},
+		{"unknown key", models.EmailTemplate{Key: "nope", Locale: "es", Subject: "Nota", HTMLBody: "x"}, service.ErrEmailTemplateNotFound},
+	}
+	for _, tt := range invalid {
+		if err := svc.Save(&tt.template, 1); !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: Save() error = %v, want %v", tt.name, err, tt.wantErr)
+		}
+	}
+
+	// Editing and then deleting the default locale restores the built-in
+	// content
+	if err := svc.Save(&models.EmailTemplate{Key: service.EmailGradePosted, Locale: "en", Subject: "Grade!", HTMLBody: "<p>{{.Grade}}</p>"}, 1); err != nil {
+		t.Fatalf("Save en: %v", err)
+	}
+	if rendered, _ := svc.Render(service.EmailGradePosted, "en", data); rendered.Subject != "Grade!" {
+		t.Errorf("expected edited subject, got %q", rendered.Subject)
+	}
+	if err := svc.Delete(service.EmailGradePosted, "en"); err != nil {
+		t.Fatalf("Delete en: %v", err)
+	}
+	if err := svc.Delete(service.EmailGradePosted, "fr"); err != nil {
+		t.Fatalf("Delete fr: %v", err)
+	}
+	if rendered, _ := svc.Render(service.EmailGradePosted, "fr", data); rendered.Subject != "New Grade Posted - Chimie" {
+		t.Errorf("expected built-in content after deletes, got %q", rendered.Subject)
+	}
+	if err := svc.Delete(service.EmailGradePosted, "fr"); !errors.Is(err, service.ErrEmailTemplateNotFound) {
+		t.Errorf("expected not found deleting a missing variant, got %v", err)
+	}
+}
+
+func TestEmailTemplatePreview(t *testing.T) {
+	svc := newTemplateService(t)
+
+	// Sample data, with overrides, rendered through a draft
+	rendered, err := svc.Preview(&models.EmailTemplate{
+		Key:      service.EmailPaymentReminder,
+		Locale:   "en",
+		HTMLBody: `<p>{{.Name}} owes ${{printf "%.2f" .Amount}} by {{.DueDate}}</p>`,
+	}, map[string]interface{}{"Name": "Sam <Lee>"})
+	if err != nil {
+		t.Fatalf("Preview: %v", err)
+	}
+	if rendered.Subject != "Payment Due Reminder" {
+		t.Errorf("expected stored subject when the draft has none, got %q", rendered.Subject)
+	}
+	if rendered.HTMLBody != "<p>Sam &lt;Lee&gt; owes $250.00 by 2026-09-30</p>" {
+		t.Errorf("unexpected preview %q", rendered.HTMLBody)
+	}
+	if rendered.TextBody != "Sam <Lee> owes $250.00 by 2026-09-30" {
+		t.Errorf("unexpected text preview %q", rendered.TextBody)
+	}
+
+	// Every built-in template renders with its own sample data
+	list, _ := svc.List()
+	for _, summary := range list {
+		if _, err := svc.Preview(&models.EmailTemplate{Key: summary.Key}, nil); err != nil {
+			t.Errorf("%s: preview failed: %v", summary.Key, err)
+		}
+		if len(summary.Locales) != 1 || summary.Locales[0] != "en" {
+			t.Errorf("%s: expected seeded en variant, got %v", summary.Key, summary.Locales)
+		}
+	}
+}
+
+func TestSendTemplateGroupsByLocale(t *testing.T) {
+	resetOutbox(t)
+	templates := newTemplateService(t)
+	if err := templates.Save(&models.EmailTemplate{
+		Key: service.EmailAnnouncement, Locale: "fr", Subject: "Annonce : {{.Title}}", HTMLBody: "<h2>{{.Title}}</h2><p>{{nl2br .Content}}</p>",
+	}, 1); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	outboxRepo := repository.NewOutboxRepository()
+	emails := service.NewEmailService(service.NewEmailOutboxService(outboxRepo, service.OutboxOptions{}), templates)
+
+	err := emails.SendAnnouncementNotification([]service.EmailRecipient{
+		{Address: "a@example.com", Locale: "en"},
+		{Address: "b@example.com", Locale: "fr-CA"},
+		{Address: "c@example.com"},
+		{Address: ""},
+	}, "Sortie", "Bus at 8")
+	if err != nil {
+		t.Fatalf("SendAnnouncementNotification: %v", err)
+	}
+
+	queued, total, _ := outboxRepo.FindAll("", 1, 10)
+	if total != 2 {
+		t.Fatalf("expected one email per locale, got %d", total)
+	}
+	subjects := map[string]int{}
+	for _, e := range queued {
+		subjects[e.Subject] = len(e.Recipients)
+		if e.TextBody == "" || !e.IsHTML {
+			t.Errorf("%q: expected an HTML email with a text alternative", e.Subject)
+		}
+	}
+	if subjects["New Announcement: Sortie"] != 2 || subjects["Annonce : Sortie"] != 1 {
+		t.Errorf("unexpected grouping %v", subjects)
+	}
+
+	message := (&mail.Message{From: "office@school.test", To: []string{"a@example.com"}, Subject: queued[0].Subject,
+		Body: queued[0].Body, TextBody: queued[0].TextBody, IsHTML: true}).Bytes()
+	for _, want := range []string{"multipart/alternative; boundary=", "Content-Type: text/plain; charset=UTF-8", "Content-Type: text/html; charset=UTF-8"} {
+		if !strings.Contains(string(message), want) {
+			t.Errorf("message missing %q", want)
+		}
+	}
+	if strings.Index(string(message), "text/plain") > strings.Index(string(message), "text/html") {
+		t.Errorf("the HTML part should come last as the preferred alternative")
+	}
+}
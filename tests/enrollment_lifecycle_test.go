@@ -0,0 +1,243 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"school-management-system/internal/models"
+	"school-management-system/internal/service"
+)
+
+func TestEnrollmentTransitions(t *testing.T) {
+	tests := []struct {
+		from, to string
+		want     bool
+	}{
+		{models.EnrollmentRequested, models.EnrollmentApproved, true},
+		{models.EnrollmentRequested, models.EnrollmentRejected, true},
+		{models.EnrollmentRequested, models.EnrollmentActive, false},
+		{models.EnrollmentApproved, models.EnrollmentActive, true},
+		{models.EnrollmentWaitlisted, models.EnrollmentActive, true},
+		{models.EnrollmentWaitlisted, models.EnrollmentApproved, true},
+		{models.EnrollmentActive, models.EnrollmentWithdrawn, true},
+		{models.EnrollmentActive, models.EnrollmentCompleted, true},
+		{models.EnrollmentActive, models.EnrollmentRequested, false},
+		{models.EnrollmentRejected, models.EnrollmentApproved, false},
+		{models.EnrollmentDropped, models.EnrollmentActive, false},
+		{models.EnrollmentCompleted, models.EnrollmentWithdrawn, false},
+		{models.EnrollmentActive, "archived", false},
+	}
+	for _, tt := range tests {
+		if got := service.CanTransition(tt.from, tt.to); got != tt.want {
+			t.Errorf("CanTransition(%q, %q) = %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+// newLifecycleTerm creates a term and an open section of a new course in it
+func newLifecycleTerm(t *testing.T, term *models.AcademicTerm, capacity int) *models.CourseSection {
+	t.Helper()
+	term.Name = "Term"
+	if err := testDB.Create(term).Error; err != nil {
+		t.Fatalf("create term: %v", err)
+	}
+	course := newRulesCourse(t, 0)
+	section := &models.CourseSection{CourseID: course.ID, TermID: term.ID, SectionCode: "001", Capacity: capacity, Status: "open"}
+	if err := testDB.Create(section).Error; err != nil {
+		t.Fatalf("create section: %v", err)
+	}
+	return section
+}
+
+func TestEnrollmentApproval(t *testing.T) {
+	clearDB()
+	svc := newNotifyingEnrollmentService(t, newNotifier(service.DispatcherOptions{}))
+	admin := createTestUser(t, models.RoleAdmin, true)
+	now := time.Now()
+	current := newLifecycleTerm(t, &models.AcademicTerm{StartDate: now.AddDate(0, -1, 0), EndDate: now.AddDate(0, 3, 0)}, 1)
+	future := newLifecycleTerm(t, &models.AcademicTerm{StartDate: now.AddDate(0, 2, 0), EndDate: now.AddDate(0, 6, 0)}, 0)
+
+	request := func(section *models.CourseSection) (*models.Enrollment, *models.Student) {
+		student := newRulesStudent(t)
+		enrollment := &models.Enrollment{StudentID: student.ID, SectionID: &section.ID, Status: models.EnrollmentRequested}
+		if err := svc.EnrollStudent(context.Background(), enrollment, service.EnrollOptions{ActorID: student.UserID}); err != nil {
+			t.Fatalf("request: %v", err)
+		}
+		return enrollment, student
+	}
+
+	// A request for a term under way starts as soon as it is approved
+	first, student := request(current)
+	if first.Status != models.EnrollmentRequested {
+		t.Fatalf("request status %q", first.Status)
+	}
+	approved, err := svc.ChangeStatus(context.Background(), first.ID, models.EnrollmentApproved, admin.ID, "prerequisites checked")
+	if err != nil || approved.Status != models.EnrollmentActive {
+		t.Fatalf("approve: status %q, err %v", approved.Status, err)
+	}
+	history, err := svc.GetHistory(first.ID)
+	if err != nil {
+		t.Fatalf("GetHistory: %v", err)
+	}
+	wantHistory := [][2]string{{"", models.EnrollmentRequested}, {models.EnrollmentRequested, models.EnrollmentApproved}, {models.EnrollmentApproved, models.EnrollmentActive}}
+	if len(history) != len(wantHistory) {
+		t.Fatalf("history has %d entries, want %d", len(history), len(wantHistory))
+	}
+	for i, want := range wantHistory {
+		if history[i].FromStatus != want[0] || history[i].ToStatus != want[1] {
+			t.Errorf("history[%d] = %s -> %s, want %s -> %s", i, history[i].FromStatus, history[i].ToStatus, want[0], want[1])
+		}
+	}
+	if history[0].ActorID == nil || *history[0].ActorID != student.UserID {
+		t.Errorf("request actor = %v, want the student", history[0].ActorID)
+	}
+	if history[1].ActorID == nil || *history[1].ActorID != admin.ID || history[1].Reason != "prerequisites checked" {
+		t.Errorf("approval recorded as %+v", history[1])
+	}
+
+	var notified int64
+	testDB.Model(&models.Notification{}).Where("user_id = ? AND event = ?", student.UserID, service.EventEnrollment).Count(&notified)
+	if notified != 3 {
+		t.Errorf("student got %d enrollment notifications, want 3", notified)
+	}
+
+	// With the only seat taken, the next approval joins the waitlist
+	second, _ := request(current)
+	waitlisted, err := svc.ChangeStatus(context.Background(), second.ID, models.EnrollmentApproved, admin.ID, "")
+	if err != nil || waitlisted.Status != models.EnrollmentWaitlisted {
+		t.Errorf("approve when full: status %q, err %v", waitlisted.Status, err)
+	}
+
+	// A request for a later term stays approved until the term starts
+	later, _ := request(future)
+	if held, err := svc.ChangeStatus(context.Background(), later.ID, models.EnrollmentApproved, admin.ID, ""); err != nil || held.Status != models.EnrollmentApproved {
+		t.Fatalf("approve for later term: status %q, err %v", held.Status, err)
+	}
+	if n, err := svc.ActivateStarted(context.Background(), now); err != nil || n != 0 {
+		t.Errorf("ActivateStarted before the term = %d, %v", n, err)
+	}
+	if n, err := svc.ActivateStarted(context.Background(), now.AddDate(0, 3, 0)); err != nil || n != 1 {
+		t.Errorf("ActivateStarted after the term starts = %d, %v", n, err)
+	}
+	if got := enrollmentStatus(t, later.ID); got != models.EnrollmentActive {
+		t.Errorf("later enrollment is %q, want active", got)
+	}
+
+	rejectedRequest, _ := request(future)
+	if _, err := svc.ChangeStatus(context.Background(), rejectedRequest.ID, models.EnrollmentRejected, admin.ID, "missing paperwork"); err != nil {
+		t.Fatalf("reject: %v", err)
+	}
+	if _, err := svc.ChangeStatus(context.Background(), rejectedRequest.ID, models.EnrollmentApproved, admin.ID, ""); !errors.Is(err, service.ErrInvalidTransition) {
+		t.Errorf("approve after reject = %v, want ErrInvalidTransition", err)
+	}
+}
+
+func TestWaitlistPromotionBeforeTerm(t *testing.T) {
+	svc := newEnrollmentService(t)
+	now := time.Now()
+	section := newLifecycleTerm(t, &models.AcademicTerm{StartDate: now.AddDate(0, 2, 0), EndDate: now.AddDate(0, 6, 0)}, 1)
+
+	approve := func() *models.Enrollment {
+		enrollment := &models.Enrollment{StudentID: newRulesStudent(t).ID, SectionID: &section.ID, Status: models.EnrollmentRequested}
+		if err := svc.EnrollStudent(context.Background(), enrollment, service.EnrollOptions{}); err != nil {
+			t.Fatalf("request: %v", err)
+		}
+		approved, err := svc.ChangeStatus(context.Background(), enrollment.ID, models.EnrollmentApproved, 0, "")
+		if err != nil {
+			t.Fatalf("approve: %v", err)
+		}
+		return approved
+	}
+	seated, queued := approve(), approve()
+	if seated.Status != models.EnrollmentApproved || queued.Status != models.EnrollmentWaitlisted {
+		t.Fatalf("statuses %q and %q, want approved and waitlisted", seated.Status, queued.Status)
+	}
+
+	// Approving from the waitlist needs a free seat, and keeps the place in line without one
+	_, err := svc.ChangeStatus(context.Background(), queued.ID, models.EnrollmentApproved, 0, "")
+	if codes := rejectionCodes(t, err); len(codes) != 1 || codes[0] != service.RejectCourseFull {
+		t.Errorf("approve from a full waitlist: codes %v, want [%s]", codes, service.RejectCourseFull)
+	}
+	if history, _ := svc.GetHistory(queued.ID); len(history) != 2 {
+		t.Errorf("waitlisted enrollment has %d transitions, want 2", len(history))
+	}
+
+	// The freed seat is held for the next in line until the term starts
+	if _, err := svc.ChangeStatus(context.Background(), seated.ID, models.EnrollmentDropped, 0, ""); err != nil {
+		t.Fatalf("drop: %v", err)
+	}
+	if got := enrollmentStatus(t, queued.ID); got != models.EnrollmentApproved {
+		t.Errorf("promoted before the term to %q, want approved", got)
+	}
+	if _, err := svc.ActivateStarted(context.Background(), now.AddDate(0, 3, 0)); err != nil {
+		t.Fatalf("ActivateStarted: %v", err)
+	}
+	if got := enrollmentStatus(t, queued.ID); got != models.EnrollmentActive {
+		t.Errorf("promoted enrollment is %q once the term started, want active", got)
+	}
+}
+
+func TestEnrollmentDeadlines(t *testing.T) {
+	clearDB()
+	svc := newEnrollmentService(t)
+	now := time.Now()
+	day := func(offset int) time.Time {
+		return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC).AddDate(0, 0, offset)
+	}
+
+	tests := []struct {
+		name       string
+		drop       time.Time
+		withdrawal time.Time
+		status     string
+		wantErr    error
+		wantGrade  bool
+	}{
+		{"drop before the deadline", day(3), day(30), models.EnrollmentDropped, nil, false},
+		{"drop on the last day", day(0), day(30), models.EnrollmentDropped, nil, false},
+		{"drop after the deadline", day(-3), day(30), models.EnrollmentDropped, service.ErrDeadlinePassed, false},
+		{"withdraw before the drop deadline", day(3), day(30), models.EnrollmentWithdrawn, nil, false},
+		{"withdraw after the drop deadline", day(-3), day(30), models.EnrollmentWithdrawn, nil, true},
+		{"withdraw after the withdrawal deadline", day(-30), day(-3), models.EnrollmentWithdrawn, service.ErrDeadlinePassed, false},
+		{"no deadlines", time.Time{}, time.Time{}, models.EnrollmentDropped, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			section := newLifecycleTerm(t, &models.AcademicTerm{StartDate: day(-40), EndDate: day(60),
+				DropDeadline: tt.drop, WithdrawalDeadline: tt.withdrawal}, 0)
+			student := newRulesStudent(t)
+			enrollment := &models.Enrollment{StudentID: student.ID, SectionID: &section.ID}
+			if err := svc.EnrollStudent(context.Background(), enrollment, withWaitlist); err != nil {
+				t.Fatalf("enroll: %v", err)
+			}
+
+			_, err := svc.ChangeStatus(context.Background(), enrollment.ID, tt.status, 0, "moving away")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ChangeStatus = %v, want %v", err, tt.wantErr)
+			}
+			wantStatus := tt.status
+			if tt.wantErr != nil {
+				wantStatus = models.EnrollmentActive
+			}
+			if got := enrollmentStatus(t, enrollment.ID); got != wantStatus {
+				t.Errorf("status %q, want %q", got, wantStatus)
+			}
+
+			var grades []models.Grade
+			testDB.Where("student_id = ? AND course_id = ?", student.ID, section.CourseID).Find(&grades)
+			if gotGrade := len(grades) == 1 && grades[0].Grade == models.GradeWithdrawn; gotGrade != tt.wantGrade {
+				t.Errorf("W grade recorded = %v, want %v (grades %+v)", gotGrade, tt.wantGrade, grades)
+			}
+			if tt.wantGrade && (grades[0].TermID == nil || *grades[0].TermID != section.TermID) {
+				t.Errorf("W grade term = %v, want %d", grades[0].TermID, section.TermID)
+			}
+			var transcripts int64
+			testDB.Model(&models.GradeTranscript{}).Where("student_id = ? AND term_id = ?", student.ID, section.TermID).Count(&transcripts)
+			if gotTranscript := transcripts > 0; gotTranscript != tt.wantGrade {
+				t.Errorf("term on transcript = %v, want %v", gotTranscript, tt.wantGrade)
+			}
+		})
+	}
+}
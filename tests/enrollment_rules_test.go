@@ -0,0 +1,355 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"school-management-system/internal/models"
+	"school-management-system/internal/repository"
+	"school-management-system/internal/service"
+
+	"gorm.io/gorm"
+)
+
+var withWaitlist = service.EnrollOptions{AllowWaitlist: true}
+
+func newEnrollmentService(t *testing.T) service.EnrollmentService {
+	t.Helper()
+	return newNotifyingEnrollmentService(t, nil)
+}
+
+func newNotifyingEnrollmentService(t *testing.T, notifier service.NotificationDispatcher) service.EnrollmentService {
+	t.Helper()
+	testDB.AutoMigrate(&models.Enrollment{}, &models.EnrollmentTransition{}, &models.CourseRequisite{}, &models.CourseSection{},
+		&models.AcademicTerm{}, &models.TimeTable{}, &models.Grade{}, &models.TransferCredit{}, &models.GradingScale{}, &models.GradingBand{})
+
+	courses := repository.NewCourseRepository()
+	return service.NewEnrollmentService(repository.NewEnrollmentRepository(), courses, repository.NewCourseSectionRepository(),
+		repository.NewAcademicTermRepository(), repository.NewCourseRequisiteRepository(), repository.NewTimeTableRepository(),
+		repository.NewGradeRepository(), repository.NewTransferCreditRepository(),
+		service.NewGradingScaleService(repository.NewGradingScaleRepository(), courses), newTranscriptService(t), notifier)
+}
+
+func newRulesStudent(t *testing.T) *models.Student {
+	t.Helper()
+	user := createTestUser(t, models.RoleStudent, true)
+	student := &models.Student{UserID: user.ID, StudentID: fmt.Sprintf("ER%d", time.Now().UnixNano())}
+	if err := testDB.Create(student).Error; err != nil {
+		t.Fatalf("create student: %v", err)
+	}
+	return student
+}
+
+func newRulesCourse(t *testing.T, maxStudents int) *models.Course {
+	t.Helper()
+	course := &models.Course{Name: "Course", CourseCode: fmt.Sprintf("ER%d", time.Now().UnixNano()), MaxStudents: maxStudents}
+	if err := testDB.Create(course).Error; err != nil {
+		t.Fatalf("create course: %v", err)
+	}
+	return course
+}
+
+// rejectionCodes returns the codes of a rejected enrollment, failing the
+// test for any other error
+func rejectionCodes(t *testing.T, err error) []string {
+	t.Helper()
+	if err == nil {
+		return nil
+	}
+	var rejection *service.EnrollmentRejectedError
+	if !errors.As(err, &rejection) {
+		t.Fatalf("expected a rejection, got %v", err)
+	}
+	codes := make([]string, len(rejection.Reasons))
+	for i, reason := range rejection.Reasons {
+		codes[i] = reason.Code
+	}
+	return codes
+}
+
+func enrollmentStatus(t *testing.T, id uint) string {
+	t.Helper()
+	var enrollment models.Enrollment
+	if err := testDB.First(&enrollment, id).Error; err != nil {
+		t.Fatalf("load enrollment: %v", err)
+	}
+	return enrollment.Status
+}
+
+func TestEnrollmentCapacityAndWaitlist(t *testing.T) {
+	clearDB()
+	svc := newEnrollmentService(t)
+	course := newRulesCourse(t, 2)
+	term := &models.AcademicTerm{Name: "Term", StartDate: time.Now().AddDate(0, -1, 0), EndDate: time.Now().AddDate(0, 3, 0)}
+	testDB.Create(term)
+	section := &models.CourseSection{CourseID: course.ID, TermID: term.ID, SectionCode: "001", Capacity: 1, Status: "open"}
+	testDB.Create(section)
+
+	students := make([]*models.Student, 4)
+	for i := range students {
+		students[i] = newRulesStudent(t)
+	}
+
+	// The course has two seats; the next two students queue in order
+	enrollments := make([]*models.Enrollment, 4)
+	wantStatus := []string{models.EnrollmentActive, models.EnrollmentActive, models.EnrollmentWaitlisted, models.EnrollmentWaitlisted}
+	for i, student := range students {
+		enrollments[i] = &models.Enrollment{StudentID: student.ID, CourseID: course.ID}
+		if err := svc.EnrollStudent(context.Background(), enrollments[i], withWaitlist); err != nil {
+			t.Fatalf("enroll %d: %v", i, err)
+		}
+		if enrollments[i].Status != wantStatus[i] {
+			t.Errorf("enrollment %d: status %q, want %q", i, enrollments[i].Status, wantStatus[i])
+		}
+	}
+	for i, want := range []int64{0, 0, 1, 2} {
+		if got, _ := svc.GetWaitlistPosition(enrollments[i]); got != want {
+			t.Errorf("enrollment %d: waitlist position %d, want %d", i, got, want)
+		}
+	}
+
+	late := newRulesStudent(t)
+	err := svc.EnrollStudent(context.Background(), &models.Enrollment{StudentID: late.ID, CourseID: course.ID}, service.EnrollOptions{})
+	if codes := rejectionCodes(t, err); len(codes) != 1 || codes[0] != service.RejectCourseFull {
+		t.Errorf("without waitlist: codes %v, want [%s]", codes, service.RejectCourseFull)
+	}
+
+	err = svc.EnrollStudent(context.Background(), &models.Enrollment{StudentID: students[2].ID, CourseID: course.ID}, withWaitlist)
+	if codes := rejectionCodes(t, err); len(codes) != 1 || codes[0] != service.RejectAlreadyEnrolled {
+		t.Errorf("duplicate: codes %v, want [%s]", codes, service.RejectAlreadyEnrolled)
+	}
+
+	// Dropping promotes the first in line, removing promotes the next
+	if _, err := svc.ChangeStatus(context.Background(), enrollments[0].ID, models.EnrollmentDropped, 0, ""); err != nil {
+		t.Fatalf("drop: %v", err)
+	}
+	if got := enrollmentStatus(t, enrollments[2].ID); got != models.EnrollmentActive {
+		t.Errorf("first in line after drop: %q, want active", got)
+	}
+	if got := enrollmentStatus(t, enrollments[3].ID); got != models.EnrollmentWaitlisted {
+		t.Errorf("second in line after drop: %q, want waitlisted", got)
+	}
+	if err := svc.RemoveEnrollment(context.Background(), enrollments[1].ID); err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+	if got := enrollmentStatus(t, enrollments[3].ID); got != models.EnrollmentActive {
+		t.Errorf("second in line after remove: %q, want active", got)
+	}
+
+	// Sections count their own seats
+	first := &models.Enrollment{StudentID: students[0].ID, SectionID: &section.ID}
+	if err := svc.EnrollStudent(context.Background(), first, withWaitlist); err != nil || first.Status != models.EnrollmentActive {
+		t.Fatalf("section enroll: status %q, err %v", first.Status, err)
+	}
+	if first.CourseID != course.ID {
+		t.Errorf("section enrollment course %d, want %d", first.CourseID, course.ID)
+	}
+	second := &models.Enrollment{StudentID: late.ID, SectionID: &section.ID}
+	if err := svc.EnrollStudent(context.Background(), second, withWaitlist); err != nil || second.Status != models.EnrollmentWaitlisted {
+		t.Fatalf("full section: status %q, err %v", second.Status, err)
+	}
+
+	// Raising the capacity lets the job promote the waitlist
+	testDB.Model(section).Update("capacity", 2)
+	promoted, err := svc.PromoteWaitlists(context.Background())
+	if err != nil || promoted != 1 {
+		t.Fatalf("PromoteWaitlists = %d, %v; want 1", promoted, err)
+	}
+	if got := enrollmentStatus(t, second.ID); got != models.EnrollmentActive {
+		t.Errorf("after capacity raise: %q, want active", got)
+	}
+
+	testDB.Model(section).Update("status", "closed")
+	err = svc.EnrollStudent(context.Background(), &models.Enrollment{StudentID: students[1].ID, SectionID: &section.ID}, withWaitlist)
+	if codes := rejectionCodes(t, err); len(codes) != 1 || codes[0] != service.RejectSectionUnavailable {
+		t.Errorf("closed section: codes %v, want [%s]", codes, service.RejectSectionUnavailable)
+	}
+
+	// Section enrollments do not take the course's own seats
+	if _, err := svc.ChangeStatus(context.Background(), enrollments[3].ID, models.EnrollmentDropped, 0, ""); err != nil {
+		t.Fatalf("drop: %v", err)
+	}
+	courseLevel := &models.Enrollment{StudentID: newRulesStudent(t).ID, CourseID: course.ID}
+	if err := svc.EnrollStudent(context.Background(), courseLevel, service.EnrollOptions{}); err != nil || courseLevel.Status != models.EnrollmentActive {
+		t.Errorf("course seat beside full sections: status %q, err %v", courseLevel.Status, err)
+	}
+}
+
+func TestEnrollmentLegacyDuplicate(t *testing.T) {
+	svc := newEnrollmentService(t)
+	repo := repository.NewEnrollmentRepository()
+	course := newRulesCourse(t, 0)
+	section := &models.CourseSection{CourseID: course.ID, TermID: 1, SectionCode: "001", Status: "open"}
+	testDB.Create(section)
+
+	// Enrolled before terms existed, with no term
+	student, other := newRulesStudent(t), newRulesStudent(t)
+	for _, s := range []*models.Student{student, other} {
+		testDB.Create(&models.Enrollment{StudentID: s.ID, CourseID: course.ID, Status: models.EnrollmentActive, EnrolledAt: time.Now()})
+	}
+
+	err := svc.EnrollStudent(context.Background(), &models.Enrollment{StudentID: student.ID, SectionID: &section.ID}, service.EnrollOptions{})
+	if codes := rejectionCodes(t, err); len(codes) != 1 || codes[0] != service.RejectAlreadyEnrolled {
+		t.Errorf("enroll: codes %v, want [%s]", codes, service.RejectAlreadyEnrolled)
+	}
+	enrollment := &models.Enrollment{StudentID: other.ID, SectionID: &section.ID, Status: models.EnrollmentActive, EnrolledAt: time.Now()}
+	if _, err := repo.EnrollWithinCapacity(context.Background(), enrollment, 0, false, &models.EnrollmentTransition{}); !errors.Is(err, gorm.ErrDuplicatedKey) {
+		t.Errorf("EnrollWithinCapacity err = %v, want a duplicate", err)
+	}
+}
+
+func TestEnrollmentRequisites(t *testing.T) {
+	clearDB()
+	svc := newEnrollmentService(t)
+	intro := newRulesCourse(t, 0)
+	lab := newRulesCourse(t, 0)
+	advanced := newRulesCourse(t, 0)
+
+	for _, requisite := range []*models.CourseRequisite{
+		{CourseID: advanced.ID, RequiredCourseID: intro.ID},
+		{CourseID: advanced.ID, RequiredCourseID: lab.ID, Kind: models.RequisiteCorequisite},
+	} {
+		if err := svc.AddRequisite(requisite); err != nil {
+			t.Fatalf("add requisite: %v", err)
+		}
+	}
+
+	invalid := []struct {
+		name      string
+		requisite models.CourseRequisite
+	}{
+		{"self", models.CourseRequisite{CourseID: intro.ID, RequiredCourseID: intro.ID}},
+		{"cycle", models.CourseRequisite{CourseID: intro.ID, RequiredCourseID: advanced.ID}},
+		{"duplicate", models.CourseRequisite{CourseID: advanced.ID, RequiredCourseID: intro.ID}},
+		{"kind", models.CourseRequisite{CourseID: lab.ID, RequiredCourseID: intro.ID, Kind: "suggested"}},
+	}
+	for _, tt := range invalid {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := svc.AddRequisite(&tt.requisite); !errors.Is(err, service.ErrInvalidRequisite) {
+				t.Errorf("AddRequisite = %v, want ErrInvalidRequisite", err)
+			}
+		})
+	}
+
+	tests := []struct {
+		name   string
+		grade  string // grade earned in the prerequisite, if any
+		inLab  bool   // enrolled in the co-requisite
+		passed bool   // passed the co-requisite
+		want   []string
+	}{
+		{"nothing", "", false, false, []string{service.RejectPrerequisiteMissing, service.RejectCorequisiteMissing}},
+		{"failed prerequisite", "F", true, false, []string{service.RejectPrerequisiteMissing}},
+		{"taking co-requisite", "B", true, false, nil},
+		{"passed co-requisite", "P", false, true, nil},
+		{"missing co-requisite", "A", false, false, []string{service.RejectCorequisiteMissing}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			student := newRulesStudent(t)
+			if tt.grade != "" {
+				testDB.Create(&models.Grade{StudentID: student.ID, CourseID: intro.ID, Grade: tt.grade, GradedAt: time.Now()})
+			}
+			if tt.inLab {
+				if err := svc.EnrollStudent(context.Background(), &models.Enrollment{StudentID: student.ID, CourseID: lab.ID}, withWaitlist); err != nil {
+					t.Fatalf("enroll in lab: %v", err)
+				}
+			}
+			if tt.passed {
+				testDB.Create(&models.Grade{StudentID: student.ID, CourseID: lab.ID, Grade: "C", GradedAt: time.Now()})
+			}
+
+			err := svc.EnrollStudent(context.Background(), &models.Enrollment{StudentID: student.ID, CourseID: advanced.ID}, withWaitlist)
+			codes := rejectionCodes(t, err)
+			if fmt.Sprint(codes) != fmt.Sprint(tt.want) {
+				t.Errorf("codes %v, want %v", codes, tt.want)
+			}
+		})
+	}
+
+	// Transfer credit accepted as the prerequisite counts as passing it
+	student := newRulesStudent(t)
+	testDB.Create(&models.TransferCredit{StudentID: student.ID, Institution: "College", EquivalentCourseID: &intro.ID, Grade: "B"})
+	testDB.Create(&models.Grade{StudentID: student.ID, CourseID: lab.ID, Grade: "B", GradedAt: time.Now()})
+	if err := svc.EnrollStudent(context.Background(), &models.Enrollment{StudentID: student.ID, CourseID: advanced.ID}, withWaitlist); err != nil {
+		t.Errorf("with transfer credit: %v", err)
+	}
+
+	requisites, _ := svc.GetRequisites(advanced.ID)
+	if len(requisites) != 2 || requisites[0].Kind != models.RequisitePrerequisite {
+		t.Fatalf("GetRequisites = %+v", requisites)
+	}
+	if err := svc.RemoveRequisite(intro.ID, requisites[0].ID); !errors.Is(err, service.ErrRequisiteNotFound) {
+		t.Errorf("remove from another course = %v, want ErrRequisiteNotFound", err)
+	}
+	if err := svc.RemoveRequisite(advanced.ID, requisites[0].ID); err != nil {
+		t.Errorf("RemoveRequisite: %v", err)
+	}
+}
+
+func TestEnrollmentScheduleConflicts(t *testing.T) {
+	clearDB()
+	svc := newEnrollmentService(t)
+	math := newRulesCourse(t, 0)
+	physics := newRulesCourse(t, 0)
+	art := newRulesCourse(t, 0)
+	mathSection := &models.CourseSection{CourseID: math.ID, TermID: 1, SectionCode: "001", Status: "open"}
+	physicsSection := &models.CourseSection{CourseID: physics.ID, TermID: 1, SectionCode: "001", Status: "open"}
+	laterPhysics := &models.CourseSection{CourseID: physics.ID, TermID: 2, SectionCode: "001", Status: "open"}
+	for _, section := range []*models.CourseSection{mathSection, physicsSection, laterPhysics} {
+		testDB.Create(section)
+	}
+
+	slot := func(course *models.Course, section *models.CourseSection, day, start, end string) {
+		timetable := &models.TimeTable{CourseID: course.ID, DayOfWeek: day, StartTime: start, EndTime: end, IsActive: true}
+		if section != nil {
+			timetable.SectionID = &section.ID
+		}
+		if err := testDB.Create(timetable).Error; err != nil {
+			t.Fatalf("create timetable: %v", err)
+		}
+	}
+	slot(math, mathSection, "Monday", "09:00", "10:30")
+	slot(physics, physicsSection, "Monday", "10:00", "11:00")
+	slot(physics, laterPhysics, "Monday", "10:00", "11:00")
+	slot(art, nil, "Monday", "11:00", "12:00")
+
+	student := newRulesStudent(t)
+	if err := svc.EnrollStudent(context.Background(), &models.Enrollment{StudentID: student.ID, SectionID: &mathSection.ID}, withWaitlist); err != nil {
+		t.Fatalf("enroll in math: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		request models.Enrollment
+		want    []string
+	}{
+		{"overlapping section", models.Enrollment{CourseID: physics.ID, SectionID: &physicsSection.ID}, []string{service.RejectScheduleConflict}},
+		{"other term", models.Enrollment{CourseID: physics.ID, SectionID: &laterPhysics.ID}, nil},
+		{"back to back", models.Enrollment{CourseID: art.ID}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := tt.request
+			request.StudentID = student.ID
+			err := svc.EnrollStudent(context.Background(), &request, withWaitlist)
+			codes := rejectionCodes(t, err)
+			if fmt.Sprint(codes) != fmt.Sprint(tt.want) {
+				t.Errorf("codes %v, want %v", codes, tt.want)
+			}
+		})
+	}
+
+	var rejection *service.EnrollmentRejectedError
+	err := svc.EnrollStudent(context.Background(), &models.Enrollment{StudentID: newRulesStudent(t).ID, SectionID: &physicsSection.ID}, withWaitlist)
+	if err != nil {
+		t.Fatalf("unrelated student: %v", err)
+	}
+	err = svc.EnrollStudent(context.Background(), &models.Enrollment{StudentID: student.ID, SectionID: &physicsSection.ID}, withWaitlist)
+	if !errors.As(err, &rejection) || rejection.Reasons[0].CourseID != math.ID || rejection.Reasons[0].TimetableID == 0 {
+		t.Errorf("conflict should name the math class, got %v", err)
+	}
+}
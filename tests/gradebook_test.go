@@ -0,0 +1,102 @@
+package tests
+
+import (
+	"context"
+	"school-management-system/internal/models"
+	"school-management-system/internal/repository"
+	"school-management-system/internal/service"
+	"testing"
+	"time"
+)
+
+func newGradebookService(t *testing.T) service.GradebookService {
+	t.Helper()
+	transcripts := newTranscriptService(t)
+	testDB.AutoMigrate(&models.GradeCategory{}, &models.Assignment{}, &models.AssignmentSubmission{},
+		&models.CourseSection{}, &models.Enrollment{})
+	scales := service.NewGradingScaleService(repository.NewGradingScaleRepository(), repository.NewCourseRepository())
+	return service.NewGradebookService(repository.NewGradeCategoryRepository(), repository.NewAssignmentRepository(),
+		repository.NewAssignmentSubmissionRepository(), repository.NewEnrollmentRepository(), repository.NewGradeRepository(),
+		repository.NewCourseRepository(), scales, transcripts)
+}
+
+func TestGradebookRepeatedCourse(t *testing.T) {
+	svc := newGradebookService(t)
+	student := newRulesStudent(t)
+	course := newTranscriptCourse(t)
+	now := time.Now()
+
+	first := &models.AcademicTerm{Name: "First", StartDate: now.AddDate(-1, 0, 0), EndDate: now.AddDate(0, -8, 0)}
+	second := &models.AcademicTerm{Name: "Second", StartDate: now.AddDate(0, -1, 0), EndDate: now.AddDate(0, 3, 0)}
+	for _, term := range []*models.AcademicTerm{first, second} {
+		if err := testDB.Create(term).Error; err != nil {
+			t.Fatalf("create term: %v", err)
+		}
+	}
+	var sections []*models.CourseSection
+	for _, term := range []*models.AcademicTerm{first, second} {
+		section := &models.CourseSection{CourseID: course.ID, TermID: term.ID, SectionCode: "001", Capacity: 10, Status: "open"}
+		if err := testDB.Create(section).Error; err != nil {
+			t.Fatalf("create section: %v", err)
+		}
+		sections = append(sections, section)
+	}
+
+	// The failed first attempt is completed; the retake is in progress
+	for i, status := range []string{models.EnrollmentCompleted, models.EnrollmentActive} {
+		enrollment := &models.Enrollment{StudentID: student.ID, CourseID: course.ID, SectionID: &sections[i].ID,
+			Status: status, EnrolledAt: now.AddDate(0, -12+10*i, 0)}
+		if err := testDB.Create(enrollment).Error; err != nil {
+			t.Fatalf("create enrollment: %v", err)
+		}
+	}
+
+	// Work from the first attempt, one piece of it from before assignments
+	// had a term, and the retake's work
+	assignments := []struct {
+		assignment *models.Assignment
+		score      float64
+	}{
+		{&models.Assignment{CourseID: course.ID, SectionID: &sections[0].ID, DueDate: first.StartDate.AddDate(0, 1, 0)}, 20},
+		{&models.Assignment{CourseID: course.ID, DueDate: first.StartDate.AddDate(0, 2, 0)}, 30},
+		{&models.Assignment{CourseID: course.ID, SectionID: &sections[1].ID, DueDate: second.StartDate.AddDate(0, 0, 14)}, 90},
+	}
+	for _, a := range assignments {
+		a.assignment.Title, a.assignment.MaxScore, a.assignment.CreatedBy = "Work", 100, 1
+		if err := testDB.Create(a.assignment).Error; err != nil {
+			t.Fatalf("create assignment: %v", err)
+		}
+		score := a.score
+		if err := testDB.Create(&models.AssignmentSubmission{AssignmentID: a.assignment.ID, StudentID: student.ID,
+			Score: &score, Status: "graded"}).Error; err != nil {
+			t.Fatalf("create submission: %v", err)
+		}
+	}
+	if assignments[0].assignment.TermID == nil || *assignments[0].assignment.TermID != first.ID {
+		t.Fatalf("assignment term = %v, want the first term from its section", assignments[0].assignment.TermID)
+	}
+
+	if err := svc.RecomputeStudent(context.Background(), course.ID, student.ID); err != nil {
+		t.Fatalf("recompute: %v", err)
+	}
+
+	var grades []models.Grade
+	testDB.Where("student_id = ? AND course_id = ? AND source = ?", student.ID, course.ID, models.GradeSourceGradebook).Find(&grades)
+	if len(grades) != 1 {
+		t.Fatalf("got %d gradebook grades, want 1", len(grades))
+	}
+	if grades[0].TermID == nil || *grades[0].TermID != second.ID {
+		t.Errorf("grade term = %v, want the retake's term %d", grades[0].TermID, second.ID)
+	}
+	if grades[0].Score != 90 {
+		t.Errorf("grade score = %v, want 90 from the retake's work only", grades[0].Score)
+	}
+
+	book, err := svc.GetGradebook(course.ID)
+	if err != nil {
+		t.Fatalf("gradebook: %v", err)
+	}
+	if len(book.Rows) != 1 || book.Rows[0].CoursePercentage == nil || *book.Rows[0].CoursePercentage != 90 {
+		t.Errorf("gradebook rows = %+v, want the retake at 90%%", book.Rows)
+	}
+}
@@ -0,0 +1,80 @@
+package tests
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"school-management-system/internal/models"
+	"school-management-system/internal/repository"
+	"school-management-system/internal/service"
+)
+
+func createTestUser(t *testing.T, role models.UserRole, active bool) *models.User {
+	t.Helper()
+	user := &models.User{
+		FirstName: "Test",
+		LastName:  string(role),
+		Email:     uniqueEmail(string(role)),
+		Password:  "password123",
+		Role:      role,
+		IsActive:  true,
+	}
+	if err := testDB.Create(user).Error; err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+	if !active {
+		testDB.Model(user).Update("is_active", false)
+	}
+	return user
+}
+
+func TestGuardianLinksAndNotifications(t *testing.T) {
+	testDB.AutoMigrate(&models.Guardian{})
+
+	studentUser := createTestUser(t, models.RoleStudent, true)
+	student := &models.Student{UserID: studentUser.ID, StudentID: fmt.Sprintf("G%d", time.Now().UnixNano())}
+	if err := testDB.Create(student).Error; err != nil {
+		t.Fatalf("failed to create student: %v", err)
+	}
+
+	mother := createTestUser(t, models.RoleParent, true)
+	father := createTestUser(t, models.RoleParent, true)
+	inactive := createTestUser(t, models.RoleParent, false)
+
+	svc := service.NewGuardianService(repository.NewGuardianRepository(), repository.NewUserRepository(), repository.NewStudentRepository())
+
+	tests := []struct {
+		name     string
+		guardian models.Guardian
+		wantErr  bool
+	}{
+		{"parent links", models.Guardian{UserID: mother.ID, StudentID: student.ID, Relationship: models.GuardianMother, ReceivesNotifications: true}, false},
+		{"opted out parent links", models.Guardian{UserID: father.ID, StudentID: student.ID, Relationship: models.GuardianFather}, false},
+		{"inactive parent links", models.Guardian{UserID: inactive.ID, StudentID: student.ID, Relationship: models.GuardianGuardian, ReceivesNotifications: true}, false},
+		{"duplicate link", models.Guardian{UserID: mother.ID, StudentID: student.ID, Relationship: models.GuardianMother}, true},
+		{"non-parent account", models.Guardian{UserID: studentUser.ID, StudentID: student.ID, Relationship: models.GuardianOther}, true},
+		{"unknown relationship", models.Guardian{UserID: father.ID, StudentID: student.ID, Relationship: "uncle"}, true},
+		{"unknown student", models.Guardian{UserID: father.ID, StudentID: 999999, Relationship: models.GuardianFather}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := tt.guardian
+			err := svc.LinkGuardian(&g)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("LinkGuardian() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	recipients := svc.NotificationRecipients(student.ID)
+	if len(recipients) != 1 || recipients[0].Address != mother.Email {
+		t.Errorf("expected only %s to be notified, got %v", mother.Email, recipients)
+	}
+
+	children, err := svc.GetChildren(mother.ID)
+	if err != nil || len(children) != 1 || children[0].StudentID != student.ID {
+		t.Errorf("expected mother to see one child, got %v (err %v)", children, err)
+	}
+}
@@ -0,0 +1,363 @@
+package tests
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"school-management-system/internal/models"
+	"school-management-system/internal/repository"
+	"school-management-system/internal/service"
+	"school-management-system/pkg/utils"
+)
+
+func newImportService(t *testing.T) service.ImportBatchService {
+	t.Helper()
+	testDB.AutoMigrate(&models.ImportBatch{}, &models.GradingScale{}, &models.GradingBand{},
+		&models.GradeTranscript{}, &models.AcademicTerm{}, &models.TransferCredit{})
+
+	scales := service.NewGradingScaleService(repository.NewGradingScaleRepository(), repository.NewCourseRepository())
+	transcripts := service.NewGradeTranscriptService(repository.NewGradeTranscriptRepository(), repository.NewGradeRepository(),
+		repository.NewAcademicTermRepository(), repository.NewTransferCreditRepository(), repository.NewSystemSettingRepository(), scales)
+	return service.NewImportBatchService(repository.NewImportBatchRepository(), testDB, scales, transcripts, newEnrollmentService(t))
+}
+
+// runImport records a batch and processes it synchronously
+func runImport(t *testing.T, svc service.ImportBatchService, entity, fileName string, data []byte, dryRun bool) *models.ImportBatch {
+	t.Helper()
+	batch := &models.ImportBatch{EntityType: entity, FileName: fileName, DryRun: dryRun, Status: models.ImportStatusPending}
+	if err := repository.NewImportBatchRepository().Create(batch); err != nil {
+		t.Fatalf("create batch: %v", err)
+	}
+	svc.Process(context.Background(), batch, data)
+	return batch
+}
+
+// buildXLSX writes a minimal workbook holding rows as inline strings
+func buildXLSX(t *testing.T, rows [][]string) []byte {
+	t.Helper()
+	var sheet strings.Builder
+	sheet.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+	for i, row := range rows {
+		fmt.Fprintf(&sheet, `<row r="%d">`, i+1)
+		for j, v := range row {
+			fmt.Fprintf(&sheet, `<c r="%c%d" t="inlineStr"><is><t>%s</t></is></c>`, 'A'+j, i+1, v)
+		}
+		sheet.WriteString(`</row>`)
+	}
+	sheet.WriteString(`</sheetData></worksheet>`)
+
+	parts := map[string]string{
+		"xl/workbook.xml":            `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets><sheet name="Sheet1" sheetId="1" r:id="rId1"/></sheets></workbook>`,
+		"xl/_rels/workbook.xml.rels": `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/></Relationships>`,
+		"xl/worksheets/sheet1.xml":   sheet.String(),
+	}
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range parts {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatalf("zip: %v", err)
+		}
+		w.Write([]byte(content))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("zip: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestReadSpreadsheet(t *testing.T) {
+	want := [][]string{{"student_id", "email"}, {"S1", "a@example.com"}}
+	tests := []struct {
+		name    string
+		file    string
+		data    []byte
+		wantErr bool
+	}{
+		{"csv", "students.csv", []byte("student_id,email\nS1,a@example.com\n"), false},
+		{"csv with byte order mark", "students.CSV", []byte("\xef\xbb\xbfstudent_id,email\nS1,a@example.com\n"), false},
+		{"xlsx", "students.xlsx", buildXLSX(t, want), false},
+		{"corrupt xlsx", "students.xlsx", []byte("not a zip"), true},
+		{"unsupported type", "students.txt", []byte("student_id,email"), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows, err := utils.ReadSpreadsheet(tt.file, tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if fmt.Sprint(rows) != fmt.Sprint(want) {
+				t.Errorf("rows = %v, want %v", rows, want)
+			}
+		})
+	}
+}
+
+func TestStudentImport(t *testing.T) {
+	svc := newImportService(t)
+	prefix := fmt.Sprintf("IMP%d", time.Now().UnixNano())
+	csv := fmt.Sprintf("Student ID,Email,First Name,Last Name,Grade Level\n"+
+		"%[1]s-1,%[1]s-1@example.com,Ada,Lovelace,10\n"+
+		"%[1]s-2,%[1]s-2@example.com,Alan,Turing,11\n"+
+		"%[1]s-3,not-an-email,Grace,Hopper,12\n"+
+		"%[1]s-1,%[1]s-1b@example.com,Ada,Again,10\n"+
+		"%[1]s-4,,Edsger,Dijkstra,9\n", prefix)
+
+	countStudents := func() int64 {
+		var n int64
+		testDB.Model(&models.Student{}).Where("student_id LIKE ?", prefix+"%").Count(&n)
+		return n
+	}
+
+	tests := []struct {
+		name                     string
+		dryRun                   bool
+		wantCreated, wantUpdated int
+		wantFailed               int
+		wantStudents             int64
+	}{
+		{"dry run saves nothing", true, 2, 0, 3, 0},
+		{"first import creates", false, 2, 0, 3, 2},
+		{"re-import updates in place", false, 0, 2, 3, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			batch := runImport(t, svc, models.ImportEntityStudent, "students.csv", []byte(csv), tt.dryRun)
+			if batch.Status != models.ImportStatusCompleted {
+				t.Fatalf("status = %s, errors %s", batch.Status, batch.Errors)
+			}
+			if batch.TotalRows != 5 || batch.ProcessedRows != 5 {
+				t.Errorf("total %d processed %d, want 5", batch.TotalRows, batch.ProcessedRows)
+			}
+			if batch.CreatedRows != tt.wantCreated || batch.UpdatedRows != tt.wantUpdated || batch.FailedRows != tt.wantFailed {
+				t.Errorf("created %d updated %d failed %d, want %d %d %d", batch.CreatedRows, batch.UpdatedRows, batch.FailedRows,
+					tt.wantCreated, tt.wantUpdated, tt.wantFailed)
+			}
+			if got := countStudents(); got != tt.wantStudents {
+				t.Errorf("students = %d, want %d", got, tt.wantStudents)
+			}
+
+			rowErrors, _ := svc.RowErrors(batch)
+			byRow := map[int]service.ImportRowError{}
+			for _, e := range rowErrors {
+				byRow[e.Row] = e
+			}
+			if e := byRow[4]; e.Column != "email" {
+				t.Errorf("row 4 error = %+v, want invalid email", e)
+			}
+			if e := byRow[5]; !strings.Contains(e.Message, "duplicate of row 2") {
+				t.Errorf("row 5 error = %+v, want duplicate", e)
+			}
+			if e := byRow[6]; e.Column != "email" || e.Message != "value is required" {
+				t.Errorf("row 6 error = %+v, want required email", e)
+			}
+		})
+	}
+}
+
+func TestImportRejectsMissingColumns(t *testing.T) {
+	svc := newImportService(t)
+	batch := runImport(t, svc, models.ImportEntityCourse, "courses.csv", []byte("name,credit_hours\nAlgebra,3\n"), false)
+	if batch.Status != models.ImportStatusFailed || !strings.Contains(batch.Errors, "course_code") {
+		t.Errorf("status %s errors %s, want failed for missing course_code", batch.Status, batch.Errors)
+	}
+}
+
+func TestCourseAndGradeImportFromXLSX(t *testing.T) {
+	svc := newImportService(t)
+	suffix := time.Now().UnixNano()
+	teacherCode := fmt.Sprintf("T%d", suffix)
+	courseCode := fmt.Sprintf("X%d", suffix)
+	studentCode := fmt.Sprintf("S%d", suffix)
+
+	steps := []struct {
+		entity string
+		rows   [][]string
+	}{
+		{models.ImportEntityTeacher, [][]string{
+			{"teacher_id", "email", "first_name", "last_name", "department"},
+			{teacherCode, strings.ToLower(teacherCode) + "@example.com", "Katherine", "Johnson", "Math"},
+		}},
+		{models.ImportEntityStudent, [][]string{
+			{"student_id", "email", "first_name", "last_name"},
+			{studentCode, strings.ToLower(studentCode) + "@example.com", "Mary", "Jackson"},
+		}},
+		{models.ImportEntityCourse, [][]string{
+			{"course_code", "name", "credit_hours", "teacher_id"},
+			{strings.ToLower(courseCode), "Orbital Mechanics", "4", teacherCode},
+		}},
+		{models.ImportEntityEnrollment, [][]string{
+			{"student_id", "course_code", "enrolled_at"},
+			{studentCode, courseCode, "45000"},
+		}},
+		{models.ImportEntityGrade, [][]string{
+			{"student_id", "course_code", "score", "max_score"},
+			{studentCode, courseCode, "45", "50"},
+		}},
+	}
+	for _, step := range steps {
+		batch := runImport(t, svc, step.entity, step.entity+".xlsx", buildXLSX(t, step.rows), false)
+		if batch.Status != models.ImportStatusCompleted || batch.CreatedRows != 1 {
+			t.Fatalf("%s import: status %s created %d errors %s", step.entity, batch.Status, batch.CreatedRows, batch.Errors)
+		}
+	}
+
+	var course models.Course
+	testDB.Where("course_code = ?", courseCode).First(&course)
+	if course.TeacherID == 0 || course.CreditHours != 4 {
+		t.Errorf("course = %+v, want teacher and 4 credit hours", course)
+	}
+
+	var enrollment models.Enrollment
+	testDB.Where("course_id = ?", course.ID).First(&enrollment)
+	if got := enrollment.EnrolledAt.Format("2006-01-02"); got != "2023-03-15" {
+		t.Errorf("enrolled_at = %s, want 2023-03-15 from the date serial", got)
+	}
+
+	var grade models.Grade
+	testDB.Where("course_id = ?", course.ID).First(&grade)
+	if grade.Grade == "" || grade.GradedBy != course.TeacherID || grade.MaxScore != 50 {
+		t.Errorf("grade = %+v, want a letter graded by the course teacher", grade)
+	}
+}
+
+func TestEnrollmentImportFollowsRules(t *testing.T) {
+	svc := newImportService(t)
+	enrollments := newEnrollmentService(t)
+	course := newRulesCourse(t, 1)
+	seated, full, excepted := newRulesStudent(t), newRulesStudent(t), newRulesStudent(t)
+	if err := enrollments.EnrollStudent(context.Background(), &models.Enrollment{StudentID: seated.ID, CourseID: course.ID}, service.EnrollOptions{}); err != nil {
+		t.Fatalf("enroll: %v", err)
+	}
+
+	csv := fmt.Sprintf("student_id,course_code,status,override_reason\n%s,%s,,\n%s,%s,completed,Transferred record\n%s,%s,dropped,\n",
+		full.StudentID, course.CourseCode, excepted.StudentID, course.CourseCode, seated.StudentID, course.CourseCode)
+
+	for _, dryRun := range []bool{true, false} {
+		batch := runImport(t, svc, models.ImportEntityEnrollment, "enrollments.csv", []byte(csv), dryRun)
+		if batch.SuccessRows != 2 || batch.FailedRows != 1 {
+			t.Fatalf("dry run %v: %d succeeded and %d failed, want 2 and 1: %s", dryRun, batch.SuccessRows, batch.FailedRows, batch.Errors)
+		}
+		if !strings.Contains(batch.Errors, `"column":"course_code"`) || !strings.Contains(batch.Errors, "is full") {
+			t.Errorf("dry run %v: errors %s, want the full course reported", dryRun, batch.Errors)
+		}
+	}
+
+	var count int64
+	testDB.Model(&models.Enrollment{}).Where("student_id = ?", full.ID).Count(&count)
+	if count != 0 {
+		t.Errorf("student beyond capacity has %d enrollments, want none", count)
+	}
+
+	var excepting models.Enrollment
+	if err := testDB.Where("student_id = ? AND course_id = ?", excepted.ID, course.ID).First(&excepting).Error; err != nil {
+		t.Fatalf("overridden enrollment: %v", err)
+	}
+	history, _ := enrollments.GetHistory(excepting.ID)
+	if excepting.Status != models.EnrollmentCompleted || len(history) != 1 || history[0].Reason != "Transferred record" {
+		t.Errorf("overridden enrollment %s with history %+v, want completed with the override reason", excepting.Status, history)
+	}
+
+	var dropped models.Enrollment
+	testDB.Where("student_id = ? AND course_id = ?", seated.ID, course.ID).First(&dropped)
+	history, _ = enrollments.GetHistory(dropped.ID)
+	if dropped.Status != models.EnrollmentDropped || len(history) != 2 || history[1].ToStatus != models.EnrollmentDropped {
+		t.Errorf("enrollment %s with history %+v, want dropped through a recorded transition", dropped.Status, history)
+	}
+}
+
+func TestImportedEnrollmentChangesStatus(t *testing.T) {
+	svc := newImportService(t)
+	enrollments := newEnrollmentService(t)
+	course := newRulesCourse(t, 0)
+	student, legacy := newRulesStudent(t), newRulesStudent(t)
+
+	csv := fmt.Sprintf("student_id,course_code,status\n%s,%s,pending\n", student.StudentID, course.CourseCode)
+	batch := runImport(t, svc, models.ImportEntityEnrollment, "enrollments.csv", []byte(csv), false)
+	if batch.SuccessRows != 1 {
+		t.Fatalf("import: %s", batch.Errors)
+	}
+	var imported models.Enrollment
+	testDB.Where("student_id = ? AND course_id = ?", student.ID, course.ID).First(&imported)
+	if imported.Status != models.EnrollmentRequested {
+		t.Fatalf("imported status %q, want pending read as requested", imported.Status)
+	}
+	if _, err := enrollments.ChangeStatus(context.Background(), imported.ID, models.EnrollmentApproved, 0, ""); err != nil {
+		t.Errorf("approve imported enrollment: %v", err)
+	}
+
+	// Enrollments stored as pending before the lifecycle are moved too
+	stored := &models.Enrollment{StudentID: legacy.ID, CourseID: course.ID, Status: "pending", EnrolledAt: time.Now()}
+	testDB.Create(stored)
+	if n, err := enrollments.MigrateLegacyStatuses(context.Background()); err != nil || n < 1 {
+		t.Fatalf("MigrateLegacyStatuses = %d, %v", n, err)
+	}
+	if _, err := enrollments.ChangeStatus(context.Background(), stored.ID, models.EnrollmentApproved, 0, ""); err != nil {
+		t.Errorf("approve migrated enrollment: %v", err)
+	}
+}
+
+func TestGradeImportKeepsRepeatedAttempts(t *testing.T) {
+	svc := newImportService(t)
+	student := newRulesStudent(t)
+	course := newTranscriptCourse(t)
+	testDB.Model(course).Update("teacher_id", 1)
+
+	stamp := time.Now().UnixNano()
+	first := &models.AcademicTerm{Name: "Spring 2019", Code: fmt.Sprintf("SP%d", stamp),
+		StartDate: time.Date(2019, 1, 7, 0, 0, 0, 0, time.UTC), EndDate: time.Date(2019, 5, 31, 0, 0, 0, 0, time.UTC)}
+	second := &models.AcademicTerm{Name: "Fall 2019", Code: fmt.Sprintf("FA%d", stamp),
+		StartDate: time.Date(2019, 8, 26, 0, 0, 0, 0, time.UTC), EndDate: time.Date(2019, 12, 20, 0, 0, 0, 0, time.UTC)}
+	for _, term := range []*models.AcademicTerm{first, second} {
+		if err := testDB.Create(term).Error; err != nil {
+			t.Fatalf("create term: %v", err)
+		}
+	}
+
+	imports := []struct {
+		name    string
+		csv     string
+		created int
+	}{
+		{"first attempt by term code", fmt.Sprintf("student_id,course_code,score,term\n%s,%s,40,%s\n",
+			student.StudentID, course.CourseCode, strings.ToLower(first.Code)), 1},
+		{"retake by grading date", fmt.Sprintf("student_id,course_code,score,graded_at\n%s,%s,88,2019-12-02\n",
+			student.StudentID, course.CourseCode), 1},
+		{"first attempt corrected", fmt.Sprintf("student_id,course_code,score,term\n%s,%s,45,%s\n",
+			student.StudentID, course.CourseCode, first.Name), 0},
+	}
+	for _, tt := range imports {
+		batch := runImport(t, svc, models.ImportEntityGrade, "grades.csv", []byte(tt.csv), false)
+		if batch.SuccessRows != 1 || batch.CreatedRows != tt.created {
+			t.Fatalf("%s: success %d created %d errors %s", tt.name, batch.SuccessRows, batch.CreatedRows, batch.Errors)
+		}
+	}
+
+	var grades []models.Grade
+	testDB.Where("student_id = ? AND course_id = ?", student.ID, course.ID).Order("id").Find(&grades)
+	if len(grades) != 2 {
+		t.Fatalf("got %d grades, want one per attempt", len(grades))
+	}
+	for i, want := range []struct {
+		termID uint
+		score  float64
+	}{{first.ID, 45}, {second.ID, 88}} {
+		if grades[i].TermID == nil || *grades[i].TermID != want.termID || grades[i].Score != want.score {
+			t.Errorf("grade %d = term %v score %v, want term %d score %v", i, grades[i].TermID, grades[i].Score, want.termID, want.score)
+		}
+	}
+
+	unknown := fmt.Sprintf("student_id,course_code,score,term\n%s,%s,40,NOPE%d\n", student.StudentID, course.CourseCode, stamp)
+	if batch := runImport(t, svc, models.ImportEntityGrade, "grades.csv", []byte(unknown), false); batch.FailedRows != 1 ||
+		!strings.Contains(batch.Errors, "no term") {
+		t.Errorf("unknown term: failed %d errors %s, want the term column rejected", batch.FailedRows, batch.Errors)
+	}
+}
@@ -7,12 +7,14 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 
+	"school-management-system/internal/audit"
 	"school-management-system/internal/config"
 	"school-management-system/internal/handlers"
 	"school-management-system/internal/middleware"
@@ -20,6 +22,7 @@ import (
 	"school-management-system/internal/repository"
 	"school-management-system/internal/service"
 	"school-management-system/pkg/database"
+	"school-management-system/pkg/utils"
 )
 
 var (
@@ -41,13 +44,24 @@ func clearDB() {
 	}
 }
 
+// TestMain removes the scratch database once the tests are done
+func TestMain(m *testing.M) {
+	code := m.Run()
+	os.Remove(filepath.Join(os.TempDir(), fmt.Sprintf("sms-test-%d.db", os.Getpid())))
+	os.Exit(code)
+}
+
 func init() {
 	// Suppress Gin debug output during tests
 	gin.SetMode(gin.TestMode)
 
-	// Set up SQLite in-memory database for testing
+	// Set up a scratch SQLite database for testing. It is a file rather than
+	// :memory:, where every pooled connection would be a new, empty database,
+	// so that goroutines share it; busy_timeout makes writers queue.
+	dbPath := filepath.Join(os.TempDir(), fmt.Sprintf("sms-test-%d.db", os.Getpid()))
+	os.Remove(dbPath)
 	os.Setenv("DB_DRIVER", "sqlite")
-	os.Setenv("DB_PATH", ":memory:")
+	os.Setenv("DB_PATH", dbPath+"?_pragma=busy_timeout(10000)&_pragma=synchronous(OFF)")
 
 	cfg, _ := config.LoadConfig()
 
@@ -68,7 +82,25 @@ func init() {
 		&models.Enrollment{},
 		&models.Grade{},
 		&models.Attendance{},
+		&models.RefreshToken{},
+		&models.RevokedToken{},
+		&models.AccountToken{},
+		&models.PasswordHistory{},
+		&models.SystemSetting{},
+		&models.UserMFA{},
+		&models.MFARecoveryCode{},
+		&models.LoginThrottle{},
+		&models.AuditLog{},
+		&models.Notification{},
+		&models.Backup{},
+		&models.OutboxEmail{},
+		&models.OutboxRecipient{},
+		&models.EmailTemplate{},
+		&models.NotificationPreference{},
+		&models.NotificationSettings{},
+		&models.NotificationDelivery{},
 	)
+	audit.Register(testDB)
 
 	// Setup router
 	testRouter = gin.New()
@@ -77,20 +109,44 @@ func init() {
 
 	// Initialize repositories and services
 	userRepo := repository.NewUserRepository()
-	authService := service.NewAuthService(userRepo, cfg.JWTSecret, cfg.JWTExpiry)
+	policy, _ := utils.NewPasswordPolicy(cfg.PasswordMinLength, cfg.PasswordHistorySize, "")
+	emailService := newQueuedEmailService()
+	mfaService := service.NewMFAService(repository.NewMFARepository(), repository.NewSystemSettingRepository(), cfg.MFAIssuer)
+	protection := service.NewLoginProtectionService(repository.NewLoginThrottleRepository(), repository.NewAuditLogRepository(),
+		repository.NewNotificationRepository(), userRepo, emailService, service.DefaultLockoutPolicy())
+	authService := service.NewAuthService(userRepo, repository.NewTokenRepository(),
+		emailService, mfaService, protection, policy, cfg.JWTSecret,
+		time.Duration(cfg.JWTAccessExpiry)*time.Minute, time.Duration(cfg.JWTRefreshExpiry)*time.Hour,
+		service.AccountOptions{
+			BaseURL:                 cfg.AppBaseURL,
+			ResetTokenExpiry:        30 * time.Minute,
+			VerificationTokenExpiry: 48 * time.Hour,
+		})
 	userService := service.NewUserService(userRepo)
 
 	// Initialize handlers
 	authHandler := handlers.NewAuthHandler(authService)
-	userHandler := handlers.NewUserHandler(userService)
+	userHandler := handlers.NewUserHandler(userService, authService)
+	mfaHandler := handlers.NewMFAHandler(mfaService, userService, authService)
 
 	// Setup routes
 	testRouter.POST("/api/auth/register", authHandler.Register)
 	testRouter.POST("/api/auth/login", authHandler.Login)
+	testRouter.POST("/api/auth/refresh", authHandler.Refresh)
+	testRouter.POST("/api/auth/forgot-password", authHandler.ForgotPassword)
+	testRouter.POST("/api/auth/reset-password", authHandler.ResetPassword)
+	testRouter.GET("/api/auth/verify-email", authHandler.VerifyEmail)
+	testRouter.POST("/api/auth/mfa/enroll", authHandler.EnrollMFA)
+	testRouter.POST("/api/auth/mfa/verify", authHandler.VerifyMFA)
 
 	api := testRouter.Group("/api")
 	api.Use(middleware.AuthMiddleware(authService))
 	{
+		api.POST("/auth/logout", authHandler.Logout)
+		api.POST("/auth/change-password", authHandler.ChangePassword)
+		api.POST("/auth/mfa/setup", mfaHandler.Setup)
+		api.POST("/auth/mfa/enable", mfaHandler.Enable)
+		api.POST("/auth/mfa/disable", mfaHandler.Disable)
 		api.GET("/profile", userHandler.GetProfile)
 		api.PUT("/profile", userHandler.UpdateProfile)
 		api.GET("/users", userHandler.GetAllUsers)
@@ -277,3 +333,104 @@ func TestUnauthorizedAccess(t *testing.T) {
 		t.Errorf("Expected status %d, got %d", http.StatusUnauthorized, w.Code)
 	}
 }
+
+func doJSON(method, path string, payload interface{}, token string) *httptest.ResponseRecorder {
+	var body []byte
+	if payload != nil {
+		body, _ = json.Marshal(payload)
+	}
+	req := httptest.NewRequest(method, path, bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+	}
+	w := httptest.NewRecorder()
+	testRouter.ServeHTTP(w, req)
+	return w
+}
+
+func registerAndLogin(t *testing.T, prefix string) map[string]interface{} {
+	t.Helper()
+	email := uniqueEmail(prefix)
+	doJSON("POST", "/api/auth/register", map[string]interface{}{
+		"first_name": "Token",
+		"last_name":  "Test",
+		"email":      email,
+		"password":   "password123",
+		"role":       "student",
+	}, "")
+
+	w := doJSON("POST", "/api/auth/login", map[string]interface{}{"email": email, "password": "password123"}, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("login failed: %d %s", w.Code, w.Body.String())
+	}
+	var resp map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	return resp
+}
+
+func TestRefreshTokenRotation(t *testing.T) {
+	clearDB()
+	login := registerAndLogin(t, "refresh")
+	first, _ := login["refresh_token"].(string)
+	if first == "" {
+		t.Fatalf("expected refresh_token in login response")
+	}
+
+	w := doJSON("POST", "/api/auth/refresh", map[string]interface{}{"refresh_token": first}, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected refresh to succeed, got %d", w.Code)
+	}
+	var rotated map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &rotated)
+	second, _ := rotated["refresh_token"].(string)
+	if second == "" || second == first {
+		t.Fatalf("expected a new refresh token, got %q", second)
+	}
+	if access, _ := rotated["token"].(string); doJSON("GET", "/api/profile", nil, access).Code != http.StatusOK {
+		t.Errorf("expected the refreshed access token to work")
+	}
+
+	// Replaying the rotated token is reuse: it fails and revokes the family.
+	if w := doJSON("POST", "/api/auth/refresh", map[string]interface{}{"refresh_token": first}, ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("expected reuse to be rejected, got %d", w.Code)
+	}
+	if w := doJSON("POST", "/api/auth/refresh", map[string]interface{}{"refresh_token": second}, ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("expected the family to be revoked after reuse, got %d", w.Code)
+	}
+}
+
+func TestLogoutRevokesTokens(t *testing.T) {
+	clearDB()
+	login := registerAndLogin(t, "logout")
+	access, _ := login["token"].(string)
+	refresh, _ := login["refresh_token"].(string)
+
+	if w := doJSON("POST", "/api/auth/logout", map[string]interface{}{"refresh_token": refresh}, access); w.Code != http.StatusOK {
+		t.Fatalf("expected logout to succeed, got %d", w.Code)
+	}
+	if w := doJSON("GET", "/api/profile", nil, access); w.Code != http.StatusUnauthorized {
+		t.Errorf("expected revoked access token to be rejected, got %d", w.Code)
+	}
+	if w := doJSON("POST", "/api/auth/refresh", map[string]interface{}{"refresh_token": refresh}, ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("expected revoked refresh token to be rejected, got %d", w.Code)
+	}
+}
+
+func TestDeactivatedUserIsCutOff(t *testing.T) {
+	clearDB()
+	login := registerAndLogin(t, "deactivate")
+	access, _ := login["token"].(string)
+	user, _ := login["user"].(map[string]interface{})
+	userID, _ := user["id"].(float64)
+
+	testDB.Model(&models.User{}).Where("id = ?", uint(userID)).Update("is_active", false)
+
+	if w := doJSON("GET", "/api/profile", nil, access); w.Code != http.StatusUnauthorized {
+		t.Errorf("expected deactivated user's token to be rejected, got %d", w.Code)
+	}
+	refresh, _ := login["refresh_token"].(string)
+	if w := doJSON("POST", "/api/auth/refresh", map[string]interface{}{"refresh_token": refresh}, ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("expected deactivated user's refresh to be rejected, got %d", w.Code)
+	}
+}
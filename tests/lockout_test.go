@@ -0,0 +1,146 @@
+package tests
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"school-management-system/internal/models"
+	"school-management-system/internal/repository"
+	"school-management-system/internal/service"
+	"school-management-system/pkg/utils"
+)
+
+func newProtectedAuthService(lockout service.LockoutPolicy) (service.AuthService, service.LoginProtectionService) {
+	userRepo := repository.NewUserRepository()
+	emailService := newQueuedEmailService()
+	protection := service.NewLoginProtectionService(repository.NewLoginThrottleRepository(), repository.NewAuditLogRepository(),
+		repository.NewNotificationRepository(), userRepo, emailService, lockout)
+	policy, _ := utils.NewPasswordPolicy(8, 0, "")
+	auth := service.NewAuthService(userRepo, repository.NewTokenRepository(), emailService,
+		service.NewMFAService(repository.NewMFARepository(), repository.NewSystemSettingRepository(), "Test"),
+		protection, policy, "test-secret", time.Minute, time.Hour, service.AccountOptions{})
+	return auth, protection
+}
+
+func uniqueIP() string {
+	n := time.Now().UnixNano()
+	return fmt.Sprintf("10.%d.%d.%d", (n>>16)&0xff, (n>>8)&0xff, n&0xff)
+}
+
+func TestAccountLockout(t *testing.T) {
+	lockout := service.DefaultLockoutPolicy()
+	lockout.MaxFailures = 3
+	lockout.FreeAttempts = 100 // no progressive delay in this test
+	auth, protection := newProtectedAuthService(lockout)
+
+	user := createTestUser(t, models.RoleStudent, true)
+	client := service.ClientInfo{IPAddress: uniqueIP()}
+
+	for i := 0; i < 3; i++ {
+		if _, err := auth.Login(user.Email, "wrong-password", client); err == nil {
+			t.Fatalf("expected wrong password to fail")
+		}
+	}
+
+	// Locked: even the right password is refused, from any IP
+	_, err := auth.Login(user.Email, "password123", service.ClientInfo{IPAddress: uniqueIP()})
+	var blocked *service.LoginBlockedError
+	if !errors.As(err, &blocked) || blocked.RetryAfter <= 0 {
+		t.Fatalf("expected account to be locked, got %v", err)
+	}
+
+	var notifications int64
+	testDB.Model(&models.Notification{}).Where("user_id = ?", user.ID).Count(&notifications)
+	if notifications != 1 {
+		t.Errorf("expected one lockout notification, got %d", notifications)
+	}
+
+	tests := []struct {
+		action string
+		want   int64
+	}{
+		{service.AuditActionLoginFailure, 3},
+		{service.AuditActionAccountLocked, 1},
+		{service.AuditActionLoginBlocked, 1},
+	}
+	for _, tt := range tests {
+		var count int64
+		testDB.Model(&models.AuditLog{}).Where("user_id = ? AND action = ?", user.ID, tt.action).Count(&count)
+		if count != tt.want {
+			t.Errorf("expected %d %s audit entries, got %d", tt.want, tt.action, count)
+		}
+	}
+
+	locked, _ := protection.GetLocked()
+	found := false
+	for _, l := range locked {
+		found = found || (l.Scope == models.ThrottleScopeAccount && l.Key == user.Email)
+	}
+	if !found {
+		t.Errorf("expected the account in the locked list")
+	}
+
+	if err := protection.UnlockAccount(user.Email, 1); err != nil {
+		t.Fatalf("unlock failed: %v", err)
+	}
+	if _, err := auth.Login(user.Email, "password123", client); err != nil {
+		t.Errorf("expected login after unlock, got %v", err)
+	}
+}
+
+func TestUnknownAccountsLockToo(t *testing.T) {
+	lockout := service.DefaultLockoutPolicy()
+	lockout.MaxFailures = 2
+	lockout.FreeAttempts = 100
+	auth, _ := newProtectedAuthService(lockout)
+
+	email := uniqueEmail("ghost")
+	for i := 0; i < 3; i++ {
+		auth.Login(email, "whatever-password", service.ClientInfo{IPAddress: uniqueIP()})
+	}
+	_, err := auth.Login(email, "whatever-password", service.ClientInfo{IPAddress: uniqueIP()})
+	var blocked *service.LoginBlockedError
+	if !errors.As(err, &blocked) {
+		t.Errorf("expected unknown accounts to lock like real ones, got %v", err)
+	}
+}
+
+func TestProgressiveDelayAndIPLockout(t *testing.T) {
+	lockout := service.DefaultLockoutPolicy()
+	lockout.FreeAttempts = 1
+	lockout.BaseDelay = time.Hour
+	lockout.MaxDelay = 2 * time.Hour
+	lockout.IPMaxFailures = 3
+	auth, protection := newProtectedAuthService(lockout)
+
+	user := createTestUser(t, models.RoleStudent, true)
+	ip := uniqueIP()
+
+	auth.Login(user.Email, "wrong-password", service.ClientInfo{IPAddress: ip})
+	auth.Login(user.Email, "wrong-password", service.ClientInfo{IPAddress: ip})
+
+	_, err := auth.Login(user.Email, "password123", service.ClientInfo{IPAddress: uniqueIP()})
+	var blocked *service.LoginBlockedError
+	if !errors.As(err, &blocked) || blocked.RetryAfter < 59*time.Minute {
+		t.Fatalf("expected a progressive delay after two failures, got %v", err)
+	}
+
+	// A distributed attack from one IP against many accounts trips the IP limit
+	auth.Login(uniqueEmail("spray"), "wrong-password", service.ClientInfo{IPAddress: ip})
+	other := createTestUser(t, models.RoleStudent, true)
+	if _, err := auth.Login(other.Email, "password123", service.ClientInfo{IPAddress: ip}); !errors.As(err, &blocked) {
+		t.Errorf("expected the IP to be locked, got %v", err)
+	}
+	if _, err := auth.Login(other.Email, "password123", service.ClientInfo{IPAddress: uniqueIP()}); err != nil {
+		t.Errorf("expected the account to work from another IP, got %v", err)
+	}
+
+	if err := protection.UnlockIP(ip, 1); err != nil {
+		t.Fatalf("unlock IP failed: %v", err)
+	}
+	if _, err := auth.Login(other.Email, "password123", service.ClientInfo{IPAddress: ip}); err != nil {
+		t.Errorf("expected login after unlocking the IP, got %v", err)
+	}
+}
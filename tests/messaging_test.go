@@ -0,0 +1,378 @@
+package tests
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"school-management-system/internal/models"
+	"school-management-system/internal/repository"
+	"school-management-system/internal/service"
+)
+
+func newMessageService(t *testing.T, realtime service.RealtimeService) service.MessageService {
+	t.Helper()
+	testDB.AutoMigrate(&models.Conversation{}, &models.ConversationParticipant{}, &models.Message{},
+		&models.MessageAttachment{}, &models.SystemSetting{}, &models.Guardian{}, &models.Enrollment{})
+	return service.NewMessageService(repository.NewMessageRepository(), repository.NewConversationRepository(),
+		repository.NewUserRepository(), repository.NewCourseRepository(), repository.NewSystemSettingRepository(),
+		realtime, service.MessagingOptions{AttachmentDir: t.TempDir(), MaxAttachmentSize: 1024})
+}
+
+func setStudentDirectMessages(t *testing.T, value string) {
+	t.Helper()
+	testDB.Where("key = ?", service.SettingStudentDirectMessages).Delete(&models.SystemSetting{})
+	testDB.Create(&models.SystemSetting{Key: service.SettingStudentDirectMessages, Value: value})
+	t.Cleanup(func() {
+		testDB.Where("key = ?", service.SettingStudentDirectMessages).Delete(&models.SystemSetting{})
+	})
+}
+
+func inboxEntry(t *testing.T, messages service.MessageService, userID, conversationID uint) *service.ConversationSummary {
+	t.Helper()
+	inbox, _, err := messages.GetInbox(userID, 1, 100)
+	if err != nil {
+		t.Fatalf("GetInbox: %v", err)
+	}
+	for i := range inbox {
+		if inbox[i].ID == conversationID {
+			return &inbox[i]
+		}
+	}
+	return nil
+}
+
+func TestDirectMessageModeration(t *testing.T) {
+	messages := newMessageService(t, nil)
+	student := createTestUser(t, models.RoleStudent, true)
+	classmate := createTestUser(t, models.RoleStudent, true)
+	teacher := createTestUser(t, models.RoleTeacher, true)
+	inactive := createTestUser(t, models.RoleTeacher, false)
+
+	tests := []struct {
+		name      string
+		setting   string
+		from, to  uint
+		wantError error
+	}{
+		{"student to student by default", "", student.ID, classmate.ID, nil},
+		{"student to student when allowed", "true", student.ID, classmate.ID, nil},
+		{"student to student when turned off", "false", student.ID, classmate.ID, service.ErrMessagingForbidden},
+		{"student to teacher when turned off", "false", student.ID, teacher.ID, nil},
+		{"teacher to student when turned off", "false", teacher.ID, student.ID, nil},
+		{"to yourself", "", student.ID, student.ID, service.ErrInvalidMessage},
+		{"to an inactive user", "", student.ID, inactive.ID, service.ErrRecipientNotFound},
+		{"to nobody", "", student.ID, 0, service.ErrInvalidMessage},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.setting != "" {
+				setStudentDirectMessages(t, tt.setting)
+			}
+			msg, err := messages.Send(tt.from, service.SendMessageRequest{ReceiverID: tt.to, Content: "hi"})
+			if !errors.Is(err, tt.wantError) {
+				t.Fatalf("Send() error = %v, want %v", err, tt.wantError)
+			}
+			if err == nil && (msg.ConversationID == 0 || msg.ReceiverID != tt.to) {
+				t.Errorf("message = %+v", msg)
+			}
+		})
+	}
+
+	// Turning the setting off also stops replies in an existing conversation
+	first, _ := messages.Send(student.ID, service.SendMessageRequest{ReceiverID: classmate.ID, Content: "before"})
+	setStudentDirectMessages(t, "false")
+	_, err := messages.Send(classmate.ID, service.SendMessageRequest{ConversationID: first.ConversationID, Content: "after"})
+	if !errors.Is(err, service.ErrMessagingForbidden) {
+		t.Errorf("reply after turning off: err = %v", err)
+	}
+	if _, err := messages.CreateGroup(student.ID, "Study group", []uint{classmate.ID}); !errors.Is(err, service.ErrMessagingForbidden) {
+		t.Errorf("student group with a student after turning off: err = %v", err)
+	}
+}
+
+func TestGroupConversation(t *testing.T) {
+	messages := newMessageService(t, nil)
+	owner := createTestUser(t, models.RoleTeacher, true)
+	member := createTestUser(t, models.RoleStudent, true)
+	other := createTestUser(t, models.RoleStudent, true)
+	outsider := createTestUser(t, models.RoleStudent, true)
+
+	if _, err := messages.CreateGroup(owner.ID, " ", []uint{member.ID}); !errors.Is(err, service.ErrInvalidMessage) {
+		t.Errorf("group without a title: err = %v", err)
+	}
+	if _, err := messages.CreateGroup(owner.ID, "Alone", []uint{owner.ID}); !errors.Is(err, service.ErrInvalidMessage) {
+		t.Errorf("group without other participants: err = %v", err)
+	}
+	group, err := messages.CreateGroup(owner.ID, "Robotics club", []uint{member.ID, other.ID, member.ID})
+	if err != nil {
+		t.Fatalf("CreateGroup: %v", err)
+	}
+	if len(group.Participants) != 3 {
+		t.Fatalf("participants = %d, want 3", len(group.Participants))
+	}
+
+	for _, content := range []string{"Meeting on Friday", "Bring your kits"} {
+		if _, err := messages.Send(owner.ID, service.SendMessageRequest{ConversationID: group.ID, Content: content}); err != nil {
+			t.Fatalf("Send: %v", err)
+		}
+	}
+	if _, err := messages.Send(outsider.ID, service.SendMessageRequest{ConversationID: group.ID, Content: "let me in"}); !errors.Is(err, service.ErrConversationNotFound) {
+		t.Errorf("outsider posting: err = %v", err)
+	}
+
+	entry := inboxEntry(t, messages, member.ID, group.ID)
+	if entry == nil || entry.UnreadCount != 2 || entry.LastMessage == nil || entry.LastMessage.Content != "Bring your kits" || entry.ParticipantCount != 3 {
+		t.Fatalf("member's inbox entry = %+v", entry)
+	}
+	if entry := inboxEntry(t, messages, owner.ID, group.ID); entry == nil || entry.UnreadCount != 0 {
+		t.Errorf("sender's own messages are unread: %+v", entry)
+	}
+	if err := messages.MarkConversationRead(group.ID, member.ID); err != nil {
+		t.Fatalf("MarkConversationRead: %v", err)
+	}
+	if n, _ := messages.CountUnread(member.ID); n != 0 {
+		t.Errorf("unread after reading = %d", n)
+	}
+
+	// Only the owner manages participants; anyone may leave
+	if err := messages.AddParticipants(group.ID, member.ID, []uint{outsider.ID}); !errors.Is(err, service.ErrMessagingForbidden) {
+		t.Errorf("member adding: err = %v", err)
+	}
+	if err := messages.AddParticipants(group.ID, owner.ID, []uint{outsider.ID}); err != nil {
+		t.Fatalf("AddParticipants: %v", err)
+	}
+	if n, _ := messages.CountUnread(outsider.ID); n != 0 {
+		t.Errorf("history counts as unread for a newcomer: %d", n)
+	}
+	if err := messages.RemoveParticipant(group.ID, member.ID, other.ID); !errors.Is(err, service.ErrMessagingForbidden) {
+		t.Errorf("member removing another: err = %v", err)
+	}
+	if err := messages.RemoveParticipant(group.ID, member.ID, member.ID); err != nil {
+		t.Fatalf("leaving: %v", err)
+	}
+	if _, _, err := messages.GetMessages(group.ID, member.ID, 1, 20); !errors.Is(err, service.ErrConversationNotFound) {
+		t.Errorf("reading after leaving: err = %v", err)
+	}
+}
+
+func TestCourseChannelAndBroadcast(t *testing.T) {
+	messages := newMessageService(t, nil)
+	stamp := time.Now().UnixNano()
+	teacherUser := createTestUser(t, models.RoleTeacher, true)
+	teacher := &models.Teacher{UserID: teacherUser.ID, TeacherID: fmt.Sprintf("T%d", stamp)}
+	testDB.Create(teacher)
+	course := &models.Course{Name: "Biology", CourseCode: fmt.Sprintf("BIO%d", stamp), TeacherID: teacher.ID}
+	testDB.Create(course)
+
+	var studentUsers []*models.User
+	for i, status := range []string{"active", "active", "dropped"} {
+		user := createTestUser(t, models.RoleStudent, true)
+		student := &models.Student{UserID: user.ID, StudentID: fmt.Sprintf("BIO%d-%d", stamp, i)}
+		testDB.Create(student)
+		testDB.Create(&models.Enrollment{StudentID: student.ID, CourseID: course.ID, Status: status, EnrolledAt: time.Now()})
+		studentUsers = append(studentUsers, user)
+		if i == 0 {
+			parent := createTestUser(t, models.RoleParent, true)
+			testDB.Create(&models.Guardian{UserID: parent.ID, StudentID: student.ID, Relationship: models.GuardianMother})
+			studentUsers = append(studentUsers, parent)
+		}
+	}
+	first, parent, second, dropped := studentUsers[0], studentUsers[1], studentUsers[2], studentUsers[3]
+
+	// The channel appears in the inbox of everyone in the course
+	channel, err := messages.GetCourseChannel(course.ID, first.ID)
+	if err != nil {
+		t.Fatalf("GetCourseChannel: %v", err)
+	}
+	if _, err := messages.GetCourseChannel(course.ID, dropped.ID); !errors.Is(err, service.ErrConversationNotFound) {
+		t.Errorf("dropped student sees the channel: err = %v", err)
+	}
+	if _, err := messages.Send(teacherUser.ID, service.SendMessageRequest{ConversationID: channel.ID, Content: "Lab safety quiz tomorrow"}); err != nil {
+		t.Fatalf("teacher posting to channel: %v", err)
+	}
+	entry := inboxEntry(t, messages, second.ID, channel.ID)
+	if entry == nil || entry.Kind != models.ConversationCourse || entry.UnreadCount != 1 || entry.ParticipantCount != 3 {
+		t.Fatalf("second student's channel entry = %+v", entry)
+	}
+
+	// Dropping the course removes the student on the next sync
+	testDB.Model(&models.Enrollment{}).Where("course_id = ? AND student_id = (SELECT id FROM students WHERE user_id = ?)", course.ID, second.ID).Update("status", "dropped")
+	if err := messages.SyncCourseChannels(); err != nil {
+		t.Fatalf("SyncCourseChannels: %v", err)
+	}
+	if entry := inboxEntry(t, messages, second.ID, channel.ID); entry != nil {
+		t.Errorf("dropped student still has the channel")
+	}
+
+	// Broadcasts
+	if _, err := messages.Broadcast(first.ID, course.ID, models.AudienceStudents, "hello", nil); !errors.Is(err, service.ErrMessagingForbidden) {
+		t.Errorf("student broadcasting: err = %v", err)
+	}
+	if _, err := messages.Broadcast(teacherUser.ID, course.ID, "everyone", "hello", nil); !errors.Is(err, service.ErrInvalidMessage) {
+		t.Errorf("unknown audience: err = %v", err)
+	}
+	toGuardians, err := messages.Broadcast(teacherUser.ID, course.ID, models.AudienceGuardians, "Parent evening on Monday", nil)
+	if err != nil {
+		t.Fatalf("Broadcast: %v", err)
+	}
+	if entry := inboxEntry(t, messages, parent.ID, toGuardians.ConversationID); entry == nil || entry.UnreadCount != 1 || entry.ParticipantCount != 0 {
+		t.Errorf("guardian's broadcast entry = %+v", entry)
+	}
+	if entry := inboxEntry(t, messages, first.ID, toGuardians.ConversationID); entry != nil {
+		t.Errorf("student received the guardians' broadcast")
+	}
+
+	// A reply goes privately to the teacher
+	reply, err := messages.Send(parent.ID, service.SendMessageRequest{ConversationID: toGuardians.ConversationID, Content: "We will be there"})
+	if err != nil {
+		t.Fatalf("replying to broadcast: %v", err)
+	}
+	if reply.ConversationID == toGuardians.ConversationID || reply.ReceiverID != teacherUser.ID || reply.ReplyToID == nil || *reply.ReplyToID != toGuardians.ID {
+		t.Errorf("reply = %+v, want a direct message to the teacher replying to %d", reply, toGuardians.ID)
+	}
+	if entry := inboxEntry(t, messages, teacherUser.ID, reply.ConversationID); entry == nil || entry.Kind != models.ConversationDirect || entry.UnreadCount != 1 {
+		t.Errorf("teacher's reply entry = %+v", entry)
+	}
+	if msgs, _, _ := messages.GetMessages(toGuardians.ConversationID, parent.ID, 1, 20); len(msgs) != 1 {
+		t.Errorf("broadcast thread has %d messages, want only the broadcast", len(msgs))
+	}
+}
+
+func TestMessageEditDeleteAndAttachments(t *testing.T) {
+	messages := newMessageService(t, nil)
+	sender := createTestUser(t, models.RoleTeacher, true)
+	receiver := createTestUser(t, models.RoleStudent, true)
+	outsider := createTestUser(t, models.RoleStudent, true)
+
+	if _, err := messages.UploadAttachment(sender.ID, "big.bin", make([]byte, 2048)); !errors.Is(err, service.ErrAttachmentTooLarge) {
+		t.Errorf("oversized upload: err = %v", err)
+	}
+	upload, err := messages.UploadAttachment(sender.ID, "../../etc/Worksheet 1.pdf", []byte("%PDF-1.4 worksheet"))
+	if err != nil {
+		t.Fatalf("UploadAttachment: %v", err)
+	}
+	if upload.FileName != "Worksheet 1.pdf" || upload.ContentType != "application/pdf" {
+		t.Errorf("upload = %+v", upload)
+	}
+	if _, _, err := messages.GetAttachment(upload.ID, receiver.ID); !errors.Is(err, service.ErrAttachmentNotFound) {
+		t.Errorf("unsent upload visible to another user: err = %v", err)
+	}
+	// Someone else's upload cannot be attached
+	if _, err := messages.Send(outsider.ID, service.SendMessageRequest{ReceiverID: receiver.ID, AttachmentIDs: []uint{upload.ID}}); !errors.Is(err, service.ErrAttachmentNotFound) {
+		t.Errorf("attaching another user's upload: err = %v", err)
+	}
+
+	msg, err := messages.Send(sender.ID, service.SendMessageRequest{ReceiverID: receiver.ID, AttachmentIDs: []uint{upload.ID}})
+	if err != nil {
+		t.Fatalf("Send with attachment: %v", err)
+	}
+	if len(msg.Attachments) != 1 {
+		t.Fatalf("attachments = %d", len(msg.Attachments))
+	}
+	_, path, err := messages.GetAttachment(upload.ID, receiver.ID)
+	if err != nil {
+		t.Fatalf("receiver downloading: %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "%PDF-1.4 worksheet" {
+		t.Errorf("stored file = %q", data)
+	}
+	if _, _, err := messages.GetAttachment(upload.ID, outsider.ID); !errors.Is(err, service.ErrAttachmentNotFound) {
+		t.Errorf("outsider downloading: err = %v", err)
+	}
+
+	// Editing
+	tests := []struct {
+		name    string
+		userID  uint
+		content string
+		want    error
+	}{
+		{"by the receiver", receiver.ID, "changed", service.ErrMessagingForbidden},
+		{"by an outsider", outsider.ID, "changed", service.ErrMessageNotFound},
+		{"by the sender", sender.ID, "  Please read before class  ", nil},
+	}
+	for _, tt := range tests {
+		t.Run("edit "+tt.name, func(t *testing.T) {
+			if _, err := messages.Edit(msg.ID, tt.userID, tt.content); !errors.Is(err, tt.want) {
+				t.Errorf("Edit() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+	var stored models.Message
+	testDB.First(&stored, msg.ID)
+	if stored.Content != "Please read before class" || stored.EditedAt == 0 {
+		t.Errorf("after edit: %+v", stored)
+	}
+
+	// Deleting leaves a placeholder, removes the file and clears the unread
+	if err := messages.Delete(msg.ID, receiver.ID); !errors.Is(err, service.ErrMessagingForbidden) {
+		t.Errorf("receiver deleting: err = %v", err)
+	}
+	if err := messages.Delete(msg.ID, sender.ID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	testDB.First(&stored, msg.ID)
+	if stored.Content != "" || stored.DeletedAt == 0 || stored.DeletedBy != sender.ID {
+		t.Errorf("after delete: %+v", stored)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("attachment file still there: %v", err)
+	}
+	if n, _ := messages.CountUnread(receiver.ID); n != 0 {
+		t.Errorf("deleted message counts as unread")
+	}
+	if _, err := messages.Edit(msg.ID, sender.ID, "again"); !errors.Is(err, service.ErrMessageNotFound) {
+		t.Errorf("editing a deleted message: err = %v", err)
+	}
+
+	// Uploads never sent are purged
+	stale, _ := messages.UploadAttachment(sender.ID, "notes.txt", []byte("draft"))
+	testDB.Model(stale).Update("created_at", time.Now().Add(-48*time.Hour).Unix())
+	if n, err := messages.PurgeUnclaimedAttachments(24 * time.Hour); err != nil || n < 1 {
+		t.Errorf("PurgeUnclaimedAttachments() = %d, %v", n, err)
+	}
+	if _, _, err := messages.GetAttachment(stale.ID, sender.ID); !errors.Is(err, service.ErrAttachmentNotFound) {
+		t.Errorf("purged upload still there: err = %v", err)
+	}
+}
+
+func TestBackfillConversations(t *testing.T) {
+	messages := newMessageService(t, nil)
+	alice := createTestUser(t, models.RoleTeacher, true)
+	bob := createTestUser(t, models.RoleStudent, true)
+
+	// Messages stored before conversations existed
+	now := time.Now().Unix()
+	legacy := []models.Message{
+		{SenderID: alice.ID, ReceiverID: bob.ID, Content: "one", IsRead: true, ReadAt: now, CreatedAt: now - 30},
+		{SenderID: bob.ID, ReceiverID: alice.ID, Content: "two", CreatedAt: now - 20},
+		{SenderID: alice.ID, ReceiverID: bob.ID, Content: "three", CreatedAt: now - 10},
+	}
+	for i := range legacy {
+		testDB.Omit("Sender", "Receiver", "Attachments").Create(&legacy[i])
+	}
+
+	if n, err := messages.BackfillConversations(); err != nil || n < 3 {
+		t.Fatalf("BackfillConversations() = %d, %v", n, err)
+	}
+	var moved models.Message
+	testDB.First(&moved, legacy[0].ID)
+	if moved.ConversationID == 0 {
+		t.Fatal("message not moved into a conversation")
+	}
+	for _, tt := range []struct {
+		user   *models.User
+		unread int64
+	}{{alice, 1}, {bob, 1}} {
+		entry := inboxEntry(t, messages, tt.user.ID, moved.ConversationID)
+		if entry == nil || entry.UnreadCount != tt.unread || entry.LastMessage.Content != "three" || entry.LastMessageAt != now-10 {
+			t.Errorf("user %d entry = %+v", tt.user.ID, entry)
+		}
+	}
+	if n, err := messages.BackfillConversations(); err != nil || n != 0 {
+		t.Errorf("second backfill = %d, %v", n, err)
+	}
+}
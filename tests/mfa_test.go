@@ -0,0 +1,196 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"school-management-system/internal/models"
+	"school-management-system/internal/service"
+	"school-management-system/pkg/utils"
+)
+
+func TestTOTPCode(t *testing.T) {
+	// RFC 6238 appendix B vectors for the SHA-1 key "12345678901234567890",
+	// truncated to six digits
+	secret := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+
+	for _, tt := range tests {
+		got, err := utils.TOTPCode(secret, utils.TOTPStep(time.Unix(tt.unix, 0)))
+		if err != nil || got != tt.want {
+			t.Errorf("TOTPCode(t=%d) = %s, %v; want %s", tt.unix, got, err, tt.want)
+		}
+	}
+
+	now := time.Unix(1234567890, 0)
+	if _, ok := utils.ValidateTOTP(secret, "005924", now.Add(30*time.Second), 1); !ok {
+		t.Errorf("expected a code from the previous step to be accepted")
+	}
+	if _, ok := utils.ValidateTOTP(secret, "005924", now.Add(90*time.Second), 1); ok {
+		t.Errorf("expected a code outside the skew window to be rejected")
+	}
+}
+
+func clearMFA() {
+	testDB.Exec("DELETE FROM user_mfa")
+	testDB.Exec("DELETE FROM mfa_recovery_codes")
+	testDB.Exec("DELETE FROM system_settings")
+}
+
+func totpAt(t *testing.T, secret string, offset int64) string {
+	t.Helper()
+	code, err := utils.TOTPCode(secret, utils.TOTPStep(time.Now())+offset)
+	if err != nil {
+		t.Fatalf("failed to compute code: %v", err)
+	}
+	return code
+}
+
+func TestMFALoginFlow(t *testing.T) {
+	clearDB()
+	clearMFA()
+	login := registerAndLogin(t, "mfa")
+	access, _ := login["token"].(string)
+	user, _ := login["user"].(map[string]interface{})
+	email, _ := user["email"].(string)
+
+	w := doJSON("POST", "/api/auth/mfa/setup", nil, access)
+	if w.Code != http.StatusOK {
+		t.Fatalf("setup failed: %d %s", w.Code, w.Body.String())
+	}
+	var setup map[string]interface{}
+	decode(w, &setup)
+	secret, _ := setup["secret"].(string)
+
+	if w := doJSON("POST", "/api/auth/mfa/enable", map[string]interface{}{"code": "000000"}, access); w.Code != http.StatusBadRequest {
+		t.Errorf("expected a wrong code to be rejected, got %d", w.Code)
+	}
+	enrollCode := totpAt(t, secret, 0)
+	w = doJSON("POST", "/api/auth/mfa/enable", map[string]interface{}{"code": enrollCode}, access)
+	if w.Code != http.StatusOK {
+		t.Fatalf("enable failed: %d %s", w.Code, w.Body.String())
+	}
+	var enabled struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}
+	decode(w, &enabled)
+	if len(enabled.RecoveryCodes) != 10 {
+		t.Fatalf("expected 10 recovery codes, got %d", len(enabled.RecoveryCodes))
+	}
+
+	mfaToken := func() string {
+		w := doJSON("POST", "/api/auth/login", map[string]interface{}{"email": email, "password": "password123"}, "")
+		var resp map[string]interface{}
+		decode(w, &resp)
+		if resp["mfa_required"] != true || resp["token"] != nil {
+			t.Fatalf("expected login to require a second factor, got %s", w.Body.String())
+		}
+		token, _ := resp["mfa_token"].(string)
+		return token
+	}
+
+	first := mfaToken()
+	if w := doJSON("GET", "/api/profile", nil, first); w.Code != http.StatusUnauthorized {
+		t.Errorf("expected the mfa token to be refused as an access token, got %d", w.Code)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		code  string
+		want  int
+	}{
+		{"wrong code", first, "123456", http.StatusUnauthorized},
+		{"replayed enrollment code", first, enrollCode, http.StatusUnauthorized},
+		{"next code", first, totpAt(t, secret, 1), http.StatusOK},
+		{"mfa token is single use", first, totpAt(t, secret, 1), http.StatusUnauthorized},
+		{"recovery code", mfaToken(), enabled.RecoveryCodes[0], http.StatusOK},
+		{"recovery code is single use", mfaToken(), enabled.RecoveryCodes[0], http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := doJSON("POST", "/api/auth/mfa/verify", map[string]interface{}{"mfa_token": tt.token, "code": tt.code}, "")
+			if w.Code != tt.want {
+				t.Errorf("expected %d, got %d: %s", tt.want, w.Code, w.Body.String())
+			}
+		})
+	}
+
+	// Disabling needs the password and a second factor
+	if w := doJSON("POST", "/api/auth/mfa/disable", map[string]interface{}{"password": "wrong-password", "code": enabled.RecoveryCodes[1]}, access); w.Code != http.StatusBadRequest {
+		t.Errorf("expected disable with wrong password to fail, got %d", w.Code)
+	}
+	if w := doJSON("POST", "/api/auth/mfa/disable", map[string]interface{}{"password": "password123", "code": enabled.RecoveryCodes[1]}, access); w.Code != http.StatusOK {
+		t.Errorf("expected disable to succeed, got %d: %s", w.Code, w.Body.String())
+	}
+	if code := loginStatus(email, "password123"); code != http.StatusOK {
+		t.Errorf("expected login without second factor after disabling, got %d", code)
+	}
+}
+
+func TestMFARequiredForRole(t *testing.T) {
+	clearDB()
+	clearMFA()
+	defer clearMFA()
+	testDB.Create(&models.SystemSetting{Key: service.SettingMFARequiredRoles, Value: "admin"})
+
+	admin := createTestUser(t, models.RoleAdmin, true)
+
+	w := doJSON("POST", "/api/auth/login", map[string]interface{}{"email": admin.Email, "password": "password123"}, "")
+	var resp map[string]interface{}
+	decode(w, &resp)
+	if resp["mfa_enrollment_required"] != true {
+		t.Fatalf("expected admin to be made to enroll, got %s", w.Body.String())
+	}
+	mfaToken, _ := resp["mfa_token"].(string)
+
+	// Students are not affected by the admin requirement
+	loginResp := registerAndLogin(t, "student")
+	if loginResp["token"] == nil {
+		t.Errorf("expected student login to complete without a second factor")
+	}
+
+	if w := doJSON("POST", "/api/auth/mfa/verify", map[string]interface{}{"mfa_token": mfaToken, "code": "123456"}, ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("expected verify before enrollment to fail, got %d", w.Code)
+	}
+
+	w = doJSON("POST", "/api/auth/mfa/enroll", map[string]interface{}{"mfa_token": mfaToken}, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("enroll failed: %d %s", w.Code, w.Body.String())
+	}
+	var enrollment map[string]interface{}
+	decode(w, &enrollment)
+	secret, _ := enrollment["secret"].(string)
+
+	w = doJSON("POST", "/api/auth/mfa/verify", map[string]interface{}{"mfa_token": mfaToken, "code": totpAt(t, secret, 0)}, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected enrollment at login to complete, got %d: %s", w.Code, w.Body.String())
+	}
+	decode(w, &resp)
+	if resp["token"] == nil || resp["recovery_codes"] == nil {
+		t.Errorf("expected tokens and recovery codes, got %s", w.Body.String())
+	}
+	access, _ := resp["token"].(string)
+
+	if w := doJSON("POST", "/api/auth/mfa/disable", map[string]interface{}{"password": "password123", "code": totpAt(t, secret, 1)}, access); w.Code != http.StatusForbidden {
+		t.Errorf("expected admin to be unable to disable required MFA, got %d", w.Code)
+	}
+}
+
+func decode(w *httptest.ResponseRecorder, v interface{}) {
+	json.Unmarshal(w.Body.Bytes(), v)
+}
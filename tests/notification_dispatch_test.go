@@ -0,0 +1,405 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"school-management-system/internal/models"
+	"school-management-system/internal/repository"
+	"school-management-system/internal/service"
+	"school-management-system/internal/sms"
+)
+
+func newNotifier(options service.DispatcherOptions) service.NotificationDispatcher {
+	testDB.AutoMigrate(&models.Notification{}, &models.NotificationPreference{}, &models.NotificationSettings{},
+		&models.NotificationDelivery{}, &models.Guardian{})
+	return service.NewNotificationDispatcher(repository.NewNotificationRepository(), repository.NewNotificationPreferenceRepository(),
+		repository.NewNotificationDeliveryRepository(), repository.NewUserRepository(), repository.NewGuardianRepository(),
+		newQueuedEmailService(), options)
+}
+
+func userDeliveries(t *testing.T, userID uint) []models.NotificationDelivery {
+	t.Helper()
+	var deliveries []models.NotificationDelivery
+	testDB.Where("user_id = ?", userID).Order("id").Find(&deliveries)
+	return deliveries
+}
+
+func TestQuietUntil(t *testing.T) {
+	day := time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC)
+	at := func(h, m int) time.Time { return day.Add(time.Duration(h)*time.Hour + time.Duration(m)*time.Minute) }
+
+	tests := []struct {
+		name      string
+		settings  models.NotificationSettings
+		now       time.Time
+		wantQuiet bool
+		wantUntil time.Time
+	}{
+		{"no quiet hours", models.NotificationSettings{}, at(23, 0), false, time.Time{}},
+		{"inside daytime window", models.NotificationSettings{QuietHoursStart: "12:00", QuietHoursEnd: "13:30"}, at(12, 15), true, at(13, 30)},
+		{"after daytime window", models.NotificationSettings{QuietHoursStart: "12:00", QuietHoursEnd: "13:30"}, at(13, 30), false, time.Time{}},
+		{"late evening across midnight", models.NotificationSettings{QuietHoursStart: "22:00", QuietHoursEnd: "07:00"}, at(23, 0), true, at(31, 0)},
+		{"early morning across midnight", models.NotificationSettings{QuietHoursStart: "22:00", QuietHoursEnd: "07:00"}, at(6, 59), true, at(7, 0)},
+		{"daytime outside overnight window", models.NotificationSettings{QuietHoursStart: "22:00", QuietHoursEnd: "07:00"}, at(12, 0), false, time.Time{}},
+		{"user timezone", models.NotificationSettings{QuietHoursStart: "22:00", QuietHoursEnd: "07:00", Timezone: "America/New_York"}, at(3, 0), true, at(11, 0)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			until, quiet := tt.settings.QuietUntil(tt.now)
+			if quiet != tt.wantQuiet || !until.Equal(tt.wantUntil) {
+				t.Errorf("QuietUntil() = %v, %v, want %v, %v", until, quiet, tt.wantUntil, tt.wantQuiet)
+			}
+		})
+	}
+}
+
+func TestNotificationFanOut(t *testing.T) {
+	provider := &sms.FakeProvider{}
+	notifier := newNotifier(service.DispatcherOptions{SMS: provider})
+	resetOutbox(t)
+	testDB.Exec("DELETE FROM notification_deliveries")
+
+	tests := []struct {
+		name         string
+		event        string
+		preferences  map[string]map[string]bool
+		wantInApp    bool
+		wantChannels []string
+	}{
+		{"defaults", service.EventGradePosted, nil, true, []string{models.ChannelEmail}},
+		{"overdue payments default to sms", service.EventPaymentOverdue, nil, true, []string{models.ChannelEmail, models.ChannelSMS}},
+		{"email turned off", service.EventGradePosted, map[string]map[string]bool{service.EventGradePosted: {models.ChannelEmail: false}}, true, nil},
+		{"sms turned on", service.EventAbsenceRecorded, map[string]map[string]bool{service.EventAbsenceRecorded: {models.ChannelSMS: true}}, true, []string{models.ChannelEmail, models.ChannelSMS}},
+		{"other event's preference ignored", service.EventGradePosted, map[string]map[string]bool{service.EventPaymentDue: {models.ChannelEmail: false}}, true, []string{models.ChannelEmail}},
+		{"in-app turned off", service.EventGradePosted, map[string]map[string]bool{service.EventGradePosted: {models.ChannelInApp: false}}, false, []string{models.ChannelEmail}},
+		{"webhook without url", service.EventGradePosted, map[string]map[string]bool{service.EventGradePosted: {models.ChannelWebhook: true}}, true, []string{models.ChannelEmail}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := createTestUser(t, models.RoleStudent, true)
+			testDB.Model(user).Update("phone", "+15550100")
+			if tt.preferences != nil {
+				if _, err := notifier.UpdatePreferences(user.ID, &service.NotificationPreferencesUpdate{Events: tt.preferences}); err != nil {
+					t.Fatalf("UpdatePreferences: %v", err)
+				}
+			}
+
+			err := notifier.Dispatch(&service.NotificationEvent{Type: tt.event, UserIDs: []uint{user.ID}, Title: "Heads up", Message: "Something happened"})
+			if err != nil {
+				t.Fatalf("Dispatch: %v", err)
+			}
+
+			var inApp int64
+			testDB.Model(&models.Notification{}).Where("user_id = ? AND event = ? AND type = ?", user.ID, tt.event, models.ChannelInApp).Count(&inApp)
+			if (inApp == 1) != tt.wantInApp {
+				t.Errorf("%d in-app notifications, want in-app = %v", inApp, tt.wantInApp)
+			}
+			var channels []string
+			for _, d := range userDeliveries(t, user.ID) {
+				channels = append(channels, d.Channel)
+			}
+			if fmt.Sprint(channels) != fmt.Sprint(tt.wantChannels) {
+				t.Errorf("queued channels = %v, want %v", channels, tt.wantChannels)
+			}
+		})
+	}
+
+	if _, err := notifier.ProcessDue(time.Now()); err != nil {
+		t.Fatalf("ProcessDue: %v", err)
+	}
+	var pending int64
+	testDB.Model(&models.NotificationDelivery{}).Where("status = ?", models.DeliveryStatusPending).Count(&pending)
+	if pending != 0 {
+		t.Errorf("%d deliveries still pending", pending)
+	}
+	if got := len(provider.Messages()); got != 2 {
+		t.Errorf("%d text messages sent, want 2", got)
+	} else if msg := provider.Messages()[0]; msg.To != "+15550100" || msg.Body != "Heads up: Something happened" {
+		t.Errorf("text message = %+v", msg)
+	}
+	var queued int64
+	testDB.Model(&models.OutboxEmail{}).Where("subject = ?", "Heads up").Count(&queued)
+	if queued != 6 {
+		t.Errorf("%d emails queued, want 6", queued)
+	}
+}
+
+func TestNotificationQuietHours(t *testing.T) {
+	provider := &sms.FakeProvider{}
+	notifier := newNotifier(service.DispatcherOptions{SMS: provider})
+	user := createTestUser(t, models.RoleStudent, true)
+	testDB.Model(user).Update("phone", "+15550101")
+
+	// Quiet from an hour ago until an hour from now, in UTC
+	now := time.Now().UTC()
+	start, end := now.Add(-time.Hour).Format("15:04"), now.Add(time.Hour).Format("15:04")
+	_, err := notifier.UpdatePreferences(user.ID, &service.NotificationPreferencesUpdate{
+		QuietHoursStart: &start,
+		QuietHoursEnd:   &end,
+		Events:          map[string]map[string]bool{service.EventGeneral: {models.ChannelSMS: true, models.ChannelEmail: false}},
+	})
+	if err != nil {
+		t.Fatalf("UpdatePreferences: %v", err)
+	}
+
+	if err := notifier.Dispatch(&service.NotificationEvent{Type: service.EventGeneral, UserIDs: []uint{user.ID}, Title: "Late", Message: "Quiet please"}); err != nil {
+		t.Fatalf("Dispatch: %v", err)
+	}
+	deliveries := userDeliveries(t, user.ID)
+	if len(deliveries) != 1 || deliveries[0].NotBefore <= now.Unix() {
+		t.Fatalf("deliveries = %+v, want one held until quiet hours end", deliveries)
+	}
+	var inApp int64
+	testDB.Model(&models.Notification{}).Where("user_id = ?", user.ID).Count(&inApp)
+	if inApp != 1 {
+		t.Errorf("%d in-app notifications, want 1 during quiet hours", inApp)
+	}
+
+	if _, err := notifier.ProcessDue(now); err != nil {
+		t.Fatalf("ProcessDue: %v", err)
+	}
+	if got := len(provider.Messages()); got != 0 {
+		t.Fatalf("%d messages sent during quiet hours", got)
+	}
+	if _, err := notifier.ProcessDue(now.Add(61 * time.Minute)); err != nil {
+		t.Fatalf("ProcessDue: %v", err)
+	}
+	if got := len(provider.Messages()); got != 1 {
+		t.Errorf("%d messages sent after quiet hours, want 1", got)
+	}
+}
+
+func TestNotificationWebhook(t *testing.T) {
+	type received struct {
+		header http.Header
+		body   []byte
+	}
+	var requests []received
+	failures := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests = append(requests, received{r.Header.Clone(), body})
+		if failures > 0 {
+			failures--
+			http.Error(w, "try later", http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	notifier := newNotifier(service.DispatcherOptions{AllowPrivateWebhooks: true, MaxAttempts: 2, BaseBackoff: 2 * time.Hour, MaxBackoff: 30 * time.Minute})
+	user := createTestUser(t, models.RoleParent, true)
+	webhook := server.URL + "/hook"
+	prefs, err := notifier.UpdatePreferences(user.ID, &service.NotificationPreferencesUpdate{
+		WebhookURL: &webhook,
+		Events:     map[string]map[string]bool{service.EventPaymentDue: {models.ChannelWebhook: true, models.ChannelEmail: false}},
+	})
+	if err != nil {
+		t.Fatalf("UpdatePreferences: %v", err)
+	}
+	secret := prefs.Settings.WebhookSecret
+	if len(secret) != 48 {
+		t.Fatalf("webhook secret %q not generated", secret)
+	}
+
+	event := &service.NotificationEvent{Type: service.EventPaymentDue, UserIDs: []uint{user.ID}, Title: "Payment due",
+		Message: "Pay up", Data: map[string]interface{}{"payment_id": 7}}
+	if err := notifier.Dispatch(event); err != nil {
+		t.Fatalf("Dispatch: %v", err)
+	}
+	now := time.Now()
+	if _, err := notifier.ProcessDue(now); err != nil {
+		t.Fatalf("ProcessDue: %v", err)
+	}
+	if len(requests) != 1 {
+		t.Fatalf("%d webhook requests, want 1", len(requests))
+	}
+	r := requests[0]
+	if want := service.WebhookSignature(secret, r.header.Get("X-Webhook-Timestamp"), r.body); r.header.Get("X-Webhook-Signature") != want {
+		t.Errorf("signature = %q, want %q", r.header.Get("X-Webhook-Signature"), want)
+	}
+	var payload map[string]interface{}
+	if err := json.Unmarshal(r.body, &payload); err != nil {
+		t.Fatalf("webhook body: %v", err)
+	}
+	if payload["event"] != service.EventPaymentDue || payload["title"] != "Payment due" || r.header.Get("X-Webhook-Event") != service.EventPaymentDue {
+		t.Errorf("webhook payload = %s", r.body)
+	}
+
+	// A receiver that keeps failing exhausts the delivery's attempts
+	failures = 2
+	if err := notifier.Dispatch(event); err != nil {
+		t.Fatalf("Dispatch: %v", err)
+	}
+	for i, want := range []string{models.DeliveryStatusPending, models.DeliveryStatusFailed} {
+		now = now.Add(time.Hour)
+		if _, err := notifier.ProcessDue(now); err != nil {
+			t.Fatalf("ProcessDue: %v", err)
+		}
+		deliveries := userDeliveries(t, user.ID)
+		last := deliveries[len(deliveries)-1]
+		if last.Status != want || last.Attempts != i+1 || !strings.Contains(last.LastError, "503") {
+			t.Errorf("attempt %d: status %s, attempts %d, error %q; want %s", i+1, last.Status, last.Attempts, last.LastError, want)
+		}
+		// The retry waits MaxBackoff rather than the longer BaseBackoff
+		if want == models.DeliveryStatusPending && last.NotBefore != now.Add(30*time.Minute).Unix() {
+			t.Errorf("retry at %d, want %d", last.NotBefore, now.Add(30*time.Minute).Unix())
+		}
+	}
+
+	// Without AllowPrivateWebhooks the loopback receiver is refused
+	strict := newNotifier(service.DispatcherOptions{MaxAttempts: 1})
+	if err := strict.Dispatch(event); err != nil {
+		t.Fatalf("Dispatch: %v", err)
+	}
+	before := len(requests)
+	if _, err := strict.ProcessDue(now.Add(time.Hour)); err != nil {
+		t.Fatalf("ProcessDue: %v", err)
+	}
+	deliveries := userDeliveries(t, user.ID)
+	if last := deliveries[len(deliveries)-1]; last.Status != models.DeliveryStatusFailed || !strings.Contains(last.LastError, "not public") {
+		t.Errorf("private webhook delivery = %s %q, want failed as not public", last.Status, last.LastError)
+	}
+	if len(requests) != before {
+		t.Error("request reached a private address")
+	}
+}
+
+func TestNotificationPreferencesValidation(t *testing.T) {
+	notifier := newNotifier(service.DispatcherOptions{})
+	user := createTestUser(t, models.RoleStudent, true)
+	str := func(s string) *string { return &s }
+
+	tests := []struct {
+		name    string
+		update  service.NotificationPreferencesUpdate
+		wantErr bool
+	}{
+		{"quiet hours", service.NotificationPreferencesUpdate{QuietHoursStart: str("21:30"), QuietHoursEnd: str("06:45"), Timezone: str("Europe/Paris")}, false},
+		{"clear quiet hours", service.NotificationPreferencesUpdate{QuietHoursStart: str(""), QuietHoursEnd: str("")}, false},
+		{"start without end", service.NotificationPreferencesUpdate{QuietHoursStart: str("22:00")}, true},
+		{"bad clock", service.NotificationPreferencesUpdate{QuietHoursStart: str("25:00"), QuietHoursEnd: str("07:00")}, true},
+		{"unknown timezone", service.NotificationPreferencesUpdate{Timezone: str("Mars/Olympus")}, true},
+		{"webhook", service.NotificationPreferencesUpdate{WebhookURL: str("https://example.com/hook")}, false},
+		{"webhook not http", service.NotificationPreferencesUpdate{WebhookURL: str("ftp://example.com/hook")}, true},
+		{"unknown event", service.NotificationPreferencesUpdate{Events: map[string]map[string]bool{"birthday": {models.ChannelEmail: true}}}, true},
+		{"unknown channel", service.NotificationPreferencesUpdate{Events: map[string]map[string]bool{service.EventGradePosted: {"pigeon": true}}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := notifier.UpdatePreferences(user.ID, &tt.update)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("UpdatePreferences() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, service.ErrInvalidNotificationPreference) {
+				t.Errorf("error %v is not ErrInvalidNotificationPreference", err)
+			}
+		})
+	}
+
+	prefs, err := notifier.GetPreferences(user.ID)
+	if err != nil {
+		t.Fatalf("GetPreferences: %v", err)
+	}
+	if prefs.Settings.QuietHoursStart != "" || prefs.Settings.Timezone != "Europe/Paris" || prefs.Settings.WebhookURL != "https://example.com/hook" {
+		t.Errorf("settings = %+v", prefs.Settings)
+	}
+}
+
+func TestAbsenceNotifiesStudentAndGuardians(t *testing.T) {
+	notifier := newNotifier(service.DispatcherOptions{})
+	studentUser := createTestUser(t, models.RoleStudent, true)
+	student := &models.Student{UserID: studentUser.ID, StudentID: fmt.Sprintf("ABS%d", time.Now().UnixNano())}
+	if err := testDB.Create(student).Error; err != nil {
+		t.Fatalf("create student: %v", err)
+	}
+	course := &models.Course{Name: "Chemistry", CourseCode: fmt.Sprintf("CHM%d", time.Now().UnixNano())}
+	if err := testDB.Create(course).Error; err != nil {
+		t.Fatalf("create course: %v", err)
+	}
+	parent := createTestUser(t, models.RoleParent, true)
+	optedOut := createTestUser(t, models.RoleParent, true)
+	testDB.Create(&models.Guardian{UserID: parent.ID, StudentID: student.ID, Relationship: models.GuardianMother, ReceivesNotifications: true})
+	testDB.Create(&models.Guardian{UserID: optedOut.ID, StudentID: student.ID, Relationship: models.GuardianFather})
+
+	svc := service.NewAttendanceService(repository.NewAttendanceRepository(), notifier)
+	for _, status := range []string{"present", "absent"} {
+		if err := svc.RecordAttendance(context.Background(), &models.Attendance{StudentID: student.ID, CourseID: course.ID, Status: status}); err != nil {
+			t.Fatalf("RecordAttendance(%s): %v", status, err)
+		}
+	}
+
+	for _, tt := range []struct {
+		user *models.User
+		want int64
+	}{{studentUser, 1}, {parent, 1}, {optedOut, 0}} {
+		var count int64
+		testDB.Model(&models.Notification{}).Where("user_id = ? AND event = ?", tt.user.ID, service.EventAbsenceRecorded).Count(&count)
+		if count != tt.want {
+			t.Errorf("user %s: %d absence notifications, want %d", tt.user.Email, count, tt.want)
+		}
+	}
+	var notification models.Notification
+	testDB.Where("user_id = ? AND event = ?", parent.ID, service.EventAbsenceRecorded).First(&notification)
+	if !strings.Contains(notification.Message, "Chemistry") || notification.Subject != "attendance" {
+		t.Errorf("guardian notification = %+v", notification)
+	}
+}
+
+func TestAssignmentDueReminders(t *testing.T) {
+	testDB.AutoMigrate(&models.Assignment{}, &models.AssignmentSubmission{}, &models.Enrollment{})
+	notifier := newNotifier(service.DispatcherOptions{})
+	course := &models.Course{Name: "Biology", CourseCode: fmt.Sprintf("BIO%d", time.Now().UnixNano())}
+	if err := testDB.Create(course).Error; err != nil {
+		t.Fatalf("create course: %v", err)
+	}
+	var students []*models.Student
+	for i, status := range []string{"active", "active", "dropped"} {
+		user := createTestUser(t, models.RoleStudent, true)
+		student := &models.Student{UserID: user.ID, StudentID: fmt.Sprintf("DUE%d-%d", time.Now().UnixNano(), i)}
+		if err := testDB.Create(student).Error; err != nil {
+			t.Fatalf("create student: %v", err)
+		}
+		testDB.Create(&models.Enrollment{StudentID: student.ID, CourseID: course.ID, Status: status, EnrolledAt: time.Now()})
+		students = append(students, student)
+	}
+
+	now := time.Now()
+	dueSoon := &models.Assignment{CourseID: course.ID, Title: "Lab report", DueDate: now.Add(12 * time.Hour)}
+	dueLater := &models.Assignment{CourseID: course.ID, Title: "Essay", DueDate: now.Add(72 * time.Hour)}
+	for _, a := range []*models.Assignment{dueSoon, dueLater} {
+		if err := testDB.Create(a).Error; err != nil {
+			t.Fatalf("create assignment: %v", err)
+		}
+	}
+	submitted := now
+	testDB.Create(&models.AssignmentSubmission{AssignmentID: dueSoon.ID, StudentID: students[1].ID, SubmittedAt: &submitted, Status: "submitted"})
+
+	svc := service.NewAssignmentService(repository.NewAssignmentRepository(), nil, notifier)
+	for i, want := range []int{1, 0} {
+		n, err := svc.SendDueReminders(now)
+		if err != nil {
+			t.Fatalf("SendDueReminders: %v", err)
+		}
+		if n != want {
+			t.Errorf("pass %d: %d students reminded, want %d", i, n, want)
+		}
+	}
+	for i, want := range []int64{1, 0, 0} {
+		var count int64
+		testDB.Model(&models.Notification{}).Where("user_id = ? AND event = ?", students[i].UserID, service.EventAssignmentDue).Count(&count)
+		if count != want {
+			t.Errorf("student %d: %d reminders, want %d", i, count, want)
+		}
+	}
+}
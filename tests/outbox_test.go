@@ -0,0 +1,221 @@
+package tests
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"school-management-system/internal/mail"
+	"school-management-system/internal/models"
+	"school-management-system/internal/repository"
+	"school-management-system/internal/service"
+)
+
+// newQueuedEmailService returns an email service whose outbox is never
+// processed, so tests queue emails without sending them
+func newQueuedEmailService() *service.EmailService {
+	return service.NewEmailService(service.NewEmailOutboxService(repository.NewOutboxRepository(), service.OutboxOptions{}),
+		service.NewEmailTemplateService(repository.NewEmailTemplateRepository()))
+}
+
+func resetOutbox(t *testing.T) {
+	t.Helper()
+	testDB.AutoMigrate(&models.OutboxEmail{}, &models.OutboxRecipient{})
+	testDB.Exec("DELETE FROM outbox_recipients")
+	testDB.Exec("DELETE FROM outbox_emails")
+}
+
+// fakeTransport fails the first failures[address] sends to an address, or
+// every send when the count is negative
+type fakeTransport struct {
+	failures  map[string]int
+	permanent bool
+	delivered map[string]int
+}
+
+func (f *fakeTransport) Send(from, to string, message []byte) error {
+	if n := f.failures[to]; n != 0 {
+		f.failures[to] = n - 1
+		err := errors.New("451 mailbox busy")
+		if f.permanent {
+			return &mail.PermanentError{Err: errors.New("550 no such user")}
+		}
+		return err
+	}
+	f.delivered[to]++
+	return nil
+}
+
+func TestOutboxMaildirDelivery(t *testing.T) {
+	resetOutbox(t)
+	dir := t.TempDir()
+	outbox := service.NewEmailOutboxService(repository.NewOutboxRepository(), service.OutboxOptions{
+		Transport: &mail.MaildirTransport{Dir: dir},
+		From:      "office@school.test",
+		FromName:  "School Office",
+	})
+
+	email, err := outbox.Enqueue(&service.EmailMessage{
+		To:      []string{"parent@example.com", "Parent@example.com ", "student@example.com", ""},
+		Subject: "Report cards — term 1",
+		Body:    "<p>Report cards are ready.</p>",
+		IsHTML:  true,
+	})
+	if err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+	if len(email.Recipients) != 2 {
+		t.Fatalf("expected duplicate recipients merged to 2, got %d", len(email.Recipients))
+	}
+
+	if n, err := outbox.ProcessDue(time.Now()); err != nil || n != 1 {
+		t.Fatalf("ProcessDue = %d, %v; want 1 email", n, err)
+	}
+	stored, _ := outbox.GetByID(email.ID)
+	if stored.Status != models.OutboxStatusSent || stored.SentAt == 0 {
+		t.Fatalf("expected sent email, got %s", stored.Status)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "new", "*"))
+	if len(files) != 2 {
+		t.Fatalf("expected 2 messages in maildir, got %d", len(files))
+	}
+	content, _ := os.ReadFile(files[0])
+	for _, want := range []string{"Delivered-To: ", "From: \"School Office\" <office@school.test>", "Subject: =?utf-8?q?", "Content-Type: text/html"} {
+		if !strings.Contains(string(content), want) {
+			t.Errorf("message missing %q:\n%s", want, content)
+		}
+	}
+
+	if _, err := outbox.Enqueue(&service.EmailMessage{To: []string{" "}}); !errors.Is(err, service.ErrNoRecipients) {
+		t.Errorf("expected ErrNoRecipients, got %v", err)
+	}
+}
+
+func TestOutboxRetries(t *testing.T) {
+	tests := []struct {
+		name         string
+		failures     int // sends to bad@ that fail; negative fails forever
+		permanent    bool
+		wantStatus   string
+		wantAttempts int
+		wantBadSent  int
+	}{
+		{"transient failure is retried", 2, false, models.OutboxStatusSent, 3, 1},
+		{"dead-lettered after max attempts", -1, false, models.OutboxStatusFailed, 4, 0},
+		{"permanent failure is not retried", 1, true, models.OutboxStatusFailed, 1, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetOutbox(t)
+			transport := &fakeTransport{
+				failures:  map[string]int{"bad@example.com": tt.failures},
+				permanent: tt.permanent,
+				delivered: map[string]int{},
+			}
+			outbox := service.NewEmailOutboxService(repository.NewOutboxRepository(), service.OutboxOptions{
+				Transport:   transport,
+				From:        "office@school.test",
+				MaxAttempts: 4,
+				BaseBackoff: time.Minute,
+				MaxBackoff:  time.Hour,
+			})
+			email, err := outbox.Enqueue(&service.EmailMessage{To: []string{"good@example.com", "bad@example.com"}, Subject: "Hello"})
+			if err != nil {
+				t.Fatalf("Enqueue: %v", err)
+			}
+
+			now := time.Now()
+			for pass := 0; pass < 10; pass++ {
+				if _, err := outbox.ProcessDue(now); err != nil {
+					t.Fatalf("ProcessDue: %v", err)
+				}
+				stored, _ := outbox.GetByID(email.ID)
+				if stored.Status != models.OutboxStatusPending {
+					break
+				}
+				// Backoff doubles after each failed attempt
+				wantWait := time.Minute << (stored.Attempts - 1)
+				if got := time.Unix(stored.NextAttemptAt, 0).Sub(now.Truncate(time.Second)); got != wantWait {
+					t.Fatalf("attempt %d: next attempt in %v, want %v", stored.Attempts, got, wantWait)
+				}
+				if n, _ := outbox.ProcessDue(now.Add(wantWait - time.Second)); n != 0 {
+					t.Fatalf("email retried before its backoff elapsed")
+				}
+				now = time.Unix(stored.NextAttemptAt, 0)
+			}
+
+			stored, _ := outbox.GetByID(email.ID)
+			if stored.Status != tt.wantStatus || stored.Attempts != tt.wantAttempts {
+				t.Fatalf("got status %s after %d attempts, want %s after %d", stored.Status, stored.Attempts, tt.wantStatus, tt.wantAttempts)
+			}
+			if transport.delivered["good@example.com"] != 1 || transport.delivered["bad@example.com"] != tt.wantBadSent {
+				t.Fatalf("unexpected deliveries %v", transport.delivered)
+			}
+			if tt.wantStatus != models.OutboxStatusFailed {
+				return
+			}
+			if stored.LastError == "" {
+				t.Errorf("expected last error recorded")
+			}
+
+			// Once the address is fixed, an admin retry reaches only the
+			// dead recipient
+			transport.failures["bad@example.com"] = 0
+			if err := outbox.Retry(email.ID); err != nil {
+				t.Fatalf("Retry: %v", err)
+			}
+			if _, err := outbox.ProcessDue(time.Now()); err != nil {
+				t.Fatalf("ProcessDue: %v", err)
+			}
+			stored, _ = outbox.GetByID(email.ID)
+			if stored.Status != models.OutboxStatusSent {
+				t.Fatalf("expected sent after retry, got %s", stored.Status)
+			}
+			if transport.delivered["good@example.com"] != 1 || transport.delivered["bad@example.com"] != 1 {
+				t.Fatalf("unexpected deliveries after retry %v", transport.delivered)
+			}
+			if err := outbox.Retry(email.ID); !errors.Is(err, service.ErrEmailNotFailed) {
+				t.Errorf("expected ErrEmailNotFailed retrying a sent email, got %v", err)
+			}
+		})
+	}
+}
+
+func TestOutboxSurvivesRestart(t *testing.T) {
+	resetOutbox(t)
+	repo := repository.NewOutboxRepository()
+
+	// The first server queues an email and claims it, then dies mid-send
+	first := service.NewEmailOutboxService(repo, service.OutboxOptions{})
+	email, err := first.Enqueue(&service.EmailMessage{To: []string{"parent@example.com"}, Subject: "Queued"})
+	if err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+	now := time.Now()
+	if claimed, err := repo.ClaimDue(now.Unix(), now.Add(5*time.Minute).Unix(), "crashed", 10); err != nil || len(claimed) != 1 {
+		t.Fatalf("ClaimDue = %d, %v", len(claimed), err)
+	}
+
+	transport := &fakeTransport{delivered: map[string]int{}}
+	second := service.NewEmailOutboxService(repo, service.OutboxOptions{Transport: transport, LockTTL: 5 * time.Minute})
+	if n, _ := second.ProcessDue(now); n != 0 {
+		t.Fatalf("email taken while another instance holds it")
+	}
+	if n, err := second.ProcessDue(now.Add(6 * time.Minute)); err != nil || n != 1 {
+		t.Fatalf("ProcessDue after lock expiry = %d, %v; want 1", n, err)
+	}
+	stored, _ := second.GetByID(email.ID)
+	if stored.Status != models.OutboxStatusSent || transport.delivered["parent@example.com"] != 1 {
+		t.Fatalf("expected delivery after restart, got %s %v", stored.Status, transport.delivered)
+	}
+
+	purged, err := second.PurgeSent(1)
+	if err != nil || purged != 0 {
+		t.Fatalf("PurgeSent removed a fresh email: %d, %v", purged, err)
+	}
+}
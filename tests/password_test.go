@@ -0,0 +1,189 @@
+package tests
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"school-management-system/internal/models"
+	"school-management-system/pkg/utils"
+)
+
+func TestPasswordPolicy(t *testing.T) {
+	policy, err := utils.NewPasswordPolicy(8, 2, "../data/breached_passwords.txt")
+	if err != nil {
+		t.Fatalf("failed to load policy: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		password string
+		email    string
+		wantErr  error
+	}{
+		{"valid", "correct horse battery", "a@example.com", nil},
+		{"too short", "short1", "a@example.com", utils.ErrPasswordTooShort},
+		{"too long", string(make([]byte, 73)), "a@example.com", utils.ErrPasswordTooLong},
+		{"breached", "Password123", "a@example.com", utils.ErrPasswordBreached},
+		{"same as email", "me@example.com", "ME@example.com", utils.ErrPasswordIsEmail},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := policy.Validate(tt.password, tt.email)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Validate() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	oldest, _ := utils.HashPassword("oldest-password")
+	older, _ := utils.HashPassword("older-password")
+	newest, _ := utils.HashPassword("newest-password")
+	history := []string{newest, older, oldest}
+
+	if err := policy.CheckHistory("older-password", history); !errors.Is(err, utils.ErrPasswordReused) {
+		t.Errorf("expected recent password to be rejected, got %v", err)
+	}
+	if err := policy.CheckHistory("oldest-password", history); err != nil {
+		t.Errorf("expected password outside the history window to be allowed, got %v", err)
+	}
+}
+
+// insertAccountToken stores a known token so tests can redeem it without
+// reading the email that would carry it.
+func insertAccountToken(t *testing.T, userID uint, purpose, token string, expiresAt int64) {
+	t.Helper()
+	sum := sha256.Sum256([]byte(token))
+	if err := testDB.Create(&models.AccountToken{
+		UserID:    userID,
+		Purpose:   purpose,
+		TokenHash: hex.EncodeToString(sum[:]),
+		ExpiresAt: expiresAt,
+		CreatedAt: time.Now().Unix(),
+	}).Error; err != nil {
+		t.Fatalf("failed to insert token: %v", err)
+	}
+}
+
+func loginStatus(email, password string) int {
+	return doJSON("POST", "/api/auth/login", map[string]interface{}{"email": email, "password": password}, "").Code
+}
+
+func TestPasswordResetFlow(t *testing.T) {
+	clearDB()
+	login := registerAndLogin(t, "reset")
+	user, _ := login["user"].(map[string]interface{})
+	userID := uint(user["id"].(float64))
+	email, _ := user["email"].(string)
+	refresh, _ := login["refresh_token"].(string)
+
+	// Unknown emails get the same answer as known ones
+	if w := doJSON("POST", "/api/auth/forgot-password", map[string]interface{}{"email": "nobody@example.com"}, ""); w.Code != http.StatusOK {
+		t.Errorf("expected 200 for unknown email, got %d", w.Code)
+	}
+	if w := doJSON("POST", "/api/auth/forgot-password", map[string]interface{}{"email": email}, ""); w.Code != http.StatusOK {
+		t.Errorf("expected 200 for known email, got %d", w.Code)
+	}
+
+	insertAccountToken(t, userID, models.TokenPurposePasswordReset, "expired-"+email, time.Now().Add(-time.Minute).Unix())
+	insertAccountToken(t, userID, models.TokenPurposePasswordReset, "valid-"+email, time.Now().Add(time.Hour).Unix())
+	insertAccountToken(t, userID, models.TokenPurposeEmailVerification, "verify-"+email, time.Now().Add(time.Hour).Unix())
+
+	tests := []struct {
+		name     string
+		token    string
+		password string
+		want     int
+	}{
+		{"expired token", "expired-" + email, "new-password-1", http.StatusBadRequest},
+		{"wrong purpose", "verify-" + email, "new-password-1", http.StatusBadRequest},
+		{"unknown token", "nope", "new-password-1", http.StatusBadRequest},
+		{"weak password keeps token usable", "valid-" + email, "short", http.StatusBadRequest},
+		{"current password rejected", "valid-" + email, "password123", http.StatusBadRequest},
+		{"valid reset", "valid-" + email, "new-password-1", http.StatusOK},
+		{"token is single use", "valid-" + email, "new-password-2", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := doJSON("POST", "/api/auth/reset-password", map[string]interface{}{"token": tt.token, "new_password": tt.password}, "")
+			if w.Code != tt.want {
+				t.Errorf("expected %d, got %d: %s", tt.want, w.Code, w.Body.String())
+			}
+		})
+	}
+
+	if code := loginStatus(email, "password123"); code != http.StatusUnauthorized {
+		t.Errorf("expected old password to stop working, got %d", code)
+	}
+	if code := loginStatus(email, "new-password-1"); code != http.StatusOK {
+		t.Errorf("expected new password to work, got %d", code)
+	}
+	if w := doJSON("POST", "/api/auth/refresh", map[string]interface{}{"refresh_token": refresh}, ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("expected sessions to be revoked by the reset, got %d", w.Code)
+	}
+}
+
+func TestChangePassword(t *testing.T) {
+	clearDB()
+	login := registerAndLogin(t, "change")
+	access, _ := login["token"].(string)
+	user, _ := login["user"].(map[string]interface{})
+	email, _ := user["email"].(string)
+
+	tests := []struct {
+		name    string
+		current string
+		next    string
+		want    int
+	}{
+		{"wrong current password", "wrong-password", "another-password", http.StatusUnauthorized},
+		{"too short", "password123", "short", http.StatusBadRequest},
+		{"same as current", "password123", "password123", http.StatusBadRequest},
+		{"valid change", "password123", "another-password", http.StatusOK},
+		{"reuse of previous password", "another-password", "password123", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := doJSON("POST", "/api/auth/change-password", map[string]interface{}{"current_password": tt.current, "new_password": tt.next}, access)
+			if w.Code != tt.want {
+				t.Errorf("expected %d, got %d: %s", tt.want, w.Code, w.Body.String())
+			}
+		})
+	}
+
+	if code := loginStatus(email, "another-password"); code != http.StatusOK {
+		t.Errorf("expected changed password to work, got %d", code)
+	}
+}
+
+func TestEmailVerification(t *testing.T) {
+	clearDB()
+	login := registerAndLogin(t, "verify")
+	user, _ := login["user"].(map[string]interface{})
+	if verified, _ := user["email_verified"].(bool); verified {
+		t.Fatalf("expected a new registration to be unverified")
+	}
+	userID := uint(user["id"].(float64))
+	email, _ := user["email"].(string)
+
+	insertAccountToken(t, userID, models.TokenPurposeEmailVerification, "verify-"+email, time.Now().Add(time.Hour).Unix())
+
+	if w := doJSON("GET", "/api/auth/verify-email?token=verify-"+email, nil, ""); w.Code != http.StatusOK {
+		t.Fatalf("expected verification to succeed, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := doJSON("GET", "/api/auth/verify-email?token=verify-"+email, nil, ""); w.Code != http.StatusBadRequest {
+		t.Errorf("expected a used verification token to be rejected, got %d", w.Code)
+	}
+
+	var stored models.User
+	testDB.First(&stored, userID)
+	if !stored.EmailVerified || stored.EmailVerifiedAt == nil {
+		t.Errorf("expected user to be marked verified")
+	}
+}
@@ -0,0 +1,340 @@
+package tests
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"school-management-system/internal/handlers"
+	"school-management-system/internal/models"
+	"school-management-system/internal/realtime"
+	"school-management-system/internal/repository"
+	"school-management-system/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newRealtimeService(t *testing.T, options service.RealtimeOptions) service.RealtimeService {
+	t.Helper()
+	testDB.AutoMigrate(&models.RealtimeEvent{})
+	svc := service.NewRealtimeService(repository.NewRealtimeEventRepository(), options)
+	svc.Start()
+	t.Cleanup(svc.Stop)
+	return svc
+}
+
+func receive(t *testing.T, client *realtime.Client) (realtime.Event, bool) {
+	t.Helper()
+	select {
+	case event := <-client.Events():
+		return event, true
+	case <-time.After(2 * time.Second):
+		return realtime.Event{}, false
+	}
+}
+
+func TestRealtimeHubRouting(t *testing.T) {
+	hub := realtime.NewHub(2)
+	student := hub.Register(1, "student")
+	studentTab := hub.Register(1, "student")
+	teacher := hub.Register(2, "teacher")
+
+	tests := []struct {
+		name  string
+		event realtime.Event
+		want  map[*realtime.Client]bool
+	}{
+		{"to one user, every connection", realtime.Event{ID: 1, UserID: 1}, map[*realtime.Client]bool{student: true, studentTab: true}},
+		{"broadcast to everyone", realtime.Event{ID: 2}, map[*realtime.Client]bool{student: true, studentTab: true, teacher: true}},
+		{"broadcast to a role", realtime.Event{ID: 3, Role: "teacher"}, map[*realtime.Client]bool{teacher: true}},
+		{"to a user not connected", realtime.Event{ID: 4, UserID: 9}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hub.Deliver(tt.event)
+			for _, c := range []*realtime.Client{student, studentTab, teacher} {
+				select {
+				case got := <-c.Events():
+					if !tt.want[c] || got.ID != tt.event.ID {
+						t.Errorf("user %d got event %d", c.UserID, got.ID)
+					}
+				default:
+					if tt.want[c] {
+						t.Errorf("user %d did not get event %d", c.UserID, tt.event.ID)
+					}
+				}
+			}
+		})
+	}
+
+	// A client that stops reading is dropped once its buffer is full
+	for id := uint64(10); id < 13; id++ {
+		hub.Deliver(realtime.Event{ID: id, UserID: 2})
+	}
+	select {
+	case <-teacher.Done():
+	default:
+		t.Error("slow client was not dropped")
+	}
+	if clients, users := hub.Connections(); clients != 2 || users != 1 {
+		t.Errorf("Connections() = %d, %d; want 2, 1", clients, users)
+	}
+}
+
+func TestRealtimeResume(t *testing.T) {
+	svc := newRealtimeService(t, service.RealtimeOptions{ReplayLimit: 3})
+	testDB.Exec("DELETE FROM realtime_events")
+	// Ids restart after the delete; resume from an event that is no one's
+	svc.Broadcast("nobody", realtime.TypeAnnouncementPublished, nil)
+	var filler models.RealtimeEvent
+	testDB.Order("id").First(&filler)
+	base := filler.ID
+
+	svc.Publish(1, realtime.TypeNotificationCreated, map[string]int{"n": 1})
+	svc.Publish(2, realtime.TypeNotificationCreated, map[string]int{"n": 2})
+	svc.Broadcast("student", realtime.TypeAnnouncementPublished, map[string]int{"n": 3})
+	svc.Broadcast("teacher", realtime.TypeAnnouncementPublished, map[string]int{"n": 4})
+	svc.Publish(1, realtime.TypeMessageCreated, map[string]int{"n": 5})
+
+	tests := []struct {
+		name   string
+		userID uint
+		role   string
+		after  uint64
+		want   []string
+	}{
+		{"fresh connection", 1, "student", 0, nil},
+		{"student from the start", 1, "student", base, []string{`{"n":1}`, `{"n":3}`, `{"n":5}`}},
+		{"student after a broadcast", 1, "student", base + 3, []string{`{"n":5}`}},
+		{"teacher from the start", 2, "teacher", base, []string{`{"n":2}`, `{"n":4}`}},
+		{"up to date", 1, "student", base + 5, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, replay, err := svc.Connect(tt.userID, tt.role, tt.after)
+			if err != nil {
+				t.Fatalf("Connect: %v", err)
+			}
+			defer svc.Disconnect(client)
+			var got []string
+			for _, e := range replay {
+				got = append(got, string(e.Data))
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("replay = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// More events than the replay limit, or events already purged, reset
+	// the stream instead
+	for i := 0; i < 3; i++ {
+		svc.Publish(1, realtime.TypeNotificationCreated, map[string]int{"n": 6 + i})
+	}
+	testDB.Model(&models.RealtimeEvent{}).Where("id <= ?", base+2).Update("created_at", time.Now().Add(-48*time.Hour).Unix())
+	for _, tt := range []struct {
+		name  string
+		purge bool
+		after uint64
+	}{{"too many to replay", false, base + 1}, {"purged", true, base + 1}} {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.purge {
+				if n, err := svc.Purge(24 * time.Hour); err != nil || n != 3 {
+					t.Fatalf("Purge() = %d, %v; want 3 deleted", n, err)
+				}
+			}
+			client, replay, err := svc.Connect(1, "student", tt.after)
+			if err != nil {
+				t.Fatalf("Connect: %v", err)
+			}
+			defer svc.Disconnect(client)
+			if len(replay) != 1 || replay[0].Type != service.TypeStreamReset || replay[0].ID != base+8 {
+				t.Errorf("replay = %+v, want a single stream.reset at the latest id", replay)
+			}
+		})
+	}
+}
+
+func TestRealtimeMessagesAndReceipts(t *testing.T) {
+	svc := newRealtimeService(t, service.RealtimeOptions{})
+	sender := createTestUser(t, models.RoleTeacher, true)
+	receiver := createTestUser(t, models.RoleStudent, true)
+	messages := newMessageService(t, svc)
+
+	senderClient, _, _ := svc.Connect(sender.ID, "teacher", 0)
+	receiverClient, _, _ := svc.Connect(receiver.ID, "student", 0)
+	defer svc.Disconnect(senderClient)
+	defer svc.Disconnect(receiverClient)
+
+	msg, err := messages.Send(sender.ID, service.SendMessageRequest{ReceiverID: receiver.ID, Content: "See me after class"})
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	event, ok := receive(t, receiverClient)
+	if !ok || event.Type != realtime.TypeMessageCreated || !strings.Contains(string(event.Data), "See me after class") {
+		t.Fatalf("receiver got %+v, %v", event, ok)
+	}
+
+	outsider := createTestUser(t, models.RoleStudent, true)
+	if err := messages.MarkAsRead(msg.ID, outsider.ID); !errors.Is(err, service.ErrMessageNotFound) {
+		t.Errorf("outsider marking read: err = %v, want ErrMessageNotFound", err)
+	}
+	if err := messages.MarkAsRead(msg.ID, receiver.ID); err != nil {
+		t.Fatalf("MarkAsRead: %v", err)
+	}
+	event, ok = receive(t, senderClient)
+	if !ok || event.Type != realtime.TypeMessageRead {
+		t.Fatalf("sender got %+v, %v; want a read receipt", event, ok)
+	}
+	var receipt struct {
+		ID       uint  `json:"id"`
+		ReaderID uint  `json:"reader_id"`
+		ReadAt   int64 `json:"read_at"`
+	}
+	json.Unmarshal(event.Data, &receipt)
+	var stored models.Message
+	testDB.First(&stored, msg.ID)
+	if receipt.ID != msg.ID || receipt.ReaderID != receiver.ID || receipt.ReadAt == 0 || stored.ReadAt != receipt.ReadAt {
+		t.Errorf("receipt = %+v, stored read_at = %d", receipt, stored.ReadAt)
+	}
+
+	// Reading again sends no second receipt
+	if err := messages.MarkAsRead(msg.ID, receiver.ID); err != nil {
+		t.Fatalf("MarkAsRead again: %v", err)
+	}
+	select {
+	case e := <-senderClient.Events():
+		t.Errorf("second receipt %+v", e)
+	default:
+	}
+}
+
+func TestRealtimeDatabaseBroker(t *testing.T) {
+	// Two instances sharing a database, each with its own hub
+	testDB.AutoMigrate(&models.RealtimeEvent{})
+	repo := repository.NewRealtimeEventRepository()
+	a := newRealtimeService(t, service.RealtimeOptions{Broker: service.NewDatabaseBroker(repo, 20*time.Millisecond)})
+	b := newRealtimeService(t, service.RealtimeOptions{Broker: service.NewDatabaseBroker(repo, 20*time.Millisecond)})
+	time.Sleep(50 * time.Millisecond)
+
+	client, _, _ := b.Connect(42, "parent", 0)
+	defer b.Disconnect(client)
+	a.Publish(42, realtime.TypeNotificationCreated, map[string]string{"title": "From another instance"})
+	event, ok := receive(t, client)
+	if !ok || !strings.Contains(string(event.Data), "From another instance") {
+		t.Fatalf("got %+v, %v", event, ok)
+	}
+}
+
+func TestRealtimeStream(t *testing.T) {
+	svc := newRealtimeService(t, service.RealtimeOptions{})
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/events/stream", func(c *gin.Context) {
+		c.Set("user_id", uint(77))
+		c.Set("user_role", "student")
+	}, handlers.NewRealtimeHandler(svc, 50*time.Millisecond).Stream)
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	svc.Publish(77, realtime.TypeNotificationCreated, map[string]string{"title": "missed"})
+	var missed models.RealtimeEvent
+	testDB.Where("user_id = ?", 77).Order("id DESC").First(&missed)
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/events/stream", nil)
+	req.Header.Set("Last-Event-ID", strconv.FormatUint(missed.ID-1, 10))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+
+	lines := make(chan string)
+	go func() {
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+		close(lines)
+	}()
+	expect := func(want string) {
+		t.Helper()
+		timeout := time.After(2 * time.Second)
+		for {
+			select {
+			case line, ok := <-lines:
+				if !ok {
+					t.Fatalf("stream closed waiting for %q", want)
+				}
+				if line == want {
+					return
+				}
+			case <-timeout:
+				t.Fatalf("timed out waiting for %q", want)
+			}
+		}
+	}
+
+	expect("id: " + strconv.FormatUint(missed.ID, 10))
+	expect(`data: {"title":"missed"}`)
+	expect(": heartbeat")
+	svc.Publish(77, realtime.TypeNotificationCreated, map[string]string{"title": "live"})
+	expect("event: " + realtime.TypeNotificationCreated)
+	expect(`data: {"title":"live"}`)
+}
+
+func TestRealtimeDatabaseBrokerLateCommit(t *testing.T) {
+	testDB.AutoMigrate(&models.RealtimeEvent{})
+	repo := repository.NewRealtimeEventRepository()
+	broker := service.NewDatabaseBroker(repo, 20*time.Millisecond)
+	defer broker.Close()
+
+	received := make(chan realtime.Event, 10)
+	broker.Subscribe(func(e realtime.Event) { received <- e })
+	time.Sleep(50 * time.Millisecond)
+
+	// An event whose id was handed out first but whose transaction committed
+	// after a later one was already delivered
+	latest, _ := repo.LatestID()
+	later := &models.RealtimeEvent{ID: latest + 10, UserID: 5, Type: "later"}
+	early := &models.RealtimeEvent{ID: latest + 5, UserID: 5, Type: "committed late"}
+	if err := repo.Create(later); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	var got []string
+	collect := func(n int) {
+		t.Helper()
+		for len(got) < n {
+			select {
+			case e := <-received:
+				got = append(got, e.Type)
+			case <-time.After(2 * time.Second):
+				t.Fatalf("got %v, waiting for %d events", got, n)
+			}
+		}
+	}
+	collect(1)
+	if err := repo.Create(early); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	collect(2)
+
+	time.Sleep(100 * time.Millisecond)
+	select {
+	case e := <-received:
+		t.Errorf("event %q delivered again", e.Type)
+	default:
+	}
+	if got[0] != "later" || got[1] != "committed late" {
+		t.Errorf("got %v, want the late commit delivered after the later event", got)
+	}
+}
@@ -0,0 +1,345 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"school-management-system/internal/models"
+	"school-management-system/internal/repository"
+	"school-management-system/internal/service"
+)
+
+func newRegistrationService(t *testing.T) service.RegistrationService {
+	t.Helper()
+	enrollments := newEnrollmentService(t)
+	testDB.AutoMigrate(&models.RegistrationCartItem{}, &models.RegistrationPriority{}, &models.SystemSetting{})
+	return service.NewRegistrationService(repository.NewRegistrationRepository(), repository.NewAcademicTermRepository(),
+		repository.NewCourseSectionRepository(), repository.NewStudentRepository(), repository.NewEnrollmentRepository(),
+		repository.NewSystemSettingRepository(), enrollments)
+}
+
+// newRegistrationTerm creates a term whose registration runs between the
+// given offsets from now
+func newRegistrationTerm(t *testing.T, opens, closes time.Duration) *models.AcademicTerm {
+	t.Helper()
+	now := time.Now()
+	term := &models.AcademicTerm{Name: "Term", StartDate: now.AddDate(0, 1, 0), EndDate: now.AddDate(0, 5, 0),
+		RegistrationStart: now.Add(opens), RegistrationEnd: now.Add(closes)}
+	if err := testDB.Create(term).Error; err != nil {
+		t.Fatalf("create term: %v", err)
+	}
+	return term
+}
+
+// newRegistrationSection creates a section of a new course in a term,
+// meeting on Monday at the given times
+func newRegistrationSection(t *testing.T, term *models.AcademicTerm, credits, capacity int, start, end string) *models.CourseSection {
+	t.Helper()
+	course := &models.Course{Name: "Course", CourseCode: fmt.Sprintf("RG%d", time.Now().UnixNano()), CreditHours: credits}
+	if err := testDB.Create(course).Error; err != nil {
+		t.Fatalf("create course: %v", err)
+	}
+	section := &models.CourseSection{CourseID: course.ID, TermID: term.ID, SectionCode: "001", Capacity: capacity, Status: "open"}
+	if err := testDB.Create(section).Error; err != nil {
+		t.Fatalf("create section: %v", err)
+	}
+	slot := &models.TimeTable{CourseID: course.ID, SectionID: &section.ID, TermID: &term.ID, DayOfWeek: "Monday",
+		StartTime: start, EndTime: end, IsActive: true}
+	if err := testDB.Create(slot).Error; err != nil {
+		t.Fatalf("create timetable: %v", err)
+	}
+	return section
+}
+
+func fillCart(t *testing.T, svc service.RegistrationService, studentID uint, sections ...*models.CourseSection) {
+	t.Helper()
+	for _, section := range sections {
+		if _, err := svc.AddToCart(studentID, section.ID); err != nil {
+			t.Fatalf("add section %d to cart: %v", section.ID, err)
+		}
+	}
+}
+
+// resultCodes returns every rejection code in a registration result, the
+// cart's own first
+func resultCodes(result *service.RegistrationResult) []string {
+	var codes []string
+	for _, reason := range result.Reasons {
+		codes = append(codes, reason.Code)
+	}
+	for _, item := range result.Items {
+		for _, reason := range item.Reasons {
+			codes = append(codes, reason.Code)
+		}
+	}
+	return codes
+}
+
+func setMaxCreditHours(t *testing.T, value string) {
+	t.Helper()
+	testDB.Where("key = ?", service.SettingMaxCreditHours).Delete(&models.SystemSetting{})
+	testDB.Create(&models.SystemSetting{Key: service.SettingMaxCreditHours, Value: value})
+	t.Cleanup(func() {
+		testDB.Where("key = ?", service.SettingMaxCreditHours).Delete(&models.SystemSetting{})
+	})
+}
+
+func TestRegistrationWindows(t *testing.T) {
+	clearDB()
+	svc := newRegistrationService(t)
+	day := 24 * time.Hour
+
+	tests := []struct {
+		name       string
+		opens      time.Duration
+		closes     time.Duration
+		priority   time.Duration // from now; 0 for none
+		closed     bool          // the term is closed and has no registration dates
+		wantClosed bool
+	}{
+		{"window open", -day, day, 0, false, false},
+		{"window not yet open", day, 2 * day, 0, false, true},
+		{"window over", -2 * day, -day, 0, false, true},
+		{"early priority", day, 2 * day, -time.Hour, false, false},
+		{"late priority", -day, day, time.Hour, false, true},
+		{"priority after the window closes", -2 * day, -day, -3 * day, false, true},
+		{"priority in a closed term", -day, day, -time.Hour, true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			term := newRegistrationTerm(t, tt.opens, tt.closes)
+			if tt.closed {
+				testDB.Model(term).Updates(map[string]interface{}{"status": models.TermStatusClosed,
+					"registration_start": time.Time{}, "registration_end": time.Time{}})
+			}
+			section := newRegistrationSection(t, term, 3, 0, "09:00", "10:00")
+			student := newRulesStudent(t)
+			if tt.priority != 0 {
+				priority := &models.RegistrationPriority{TermID: term.ID, StudentID: student.ID, OpensAt: time.Now().Add(tt.priority)}
+				if err := svc.SetPriority(priority); err != nil {
+					t.Fatalf("SetPriority: %v", err)
+				}
+			}
+			fillCart(t, svc, student.ID, section)
+
+			result, err := svc.Validate(student.ID, true, time.Now())
+			if err != nil {
+				t.Fatalf("Validate: %v", err)
+			}
+			if closed := slices.Contains(resultCodes(result), service.RejectRegistrationClosed); closed != tt.wantClosed {
+				t.Errorf("registration closed = %v, want %v (%+v)", closed, tt.wantClosed, result)
+			}
+			if result.Valid == tt.wantClosed {
+				t.Errorf("valid = %v", result.Valid)
+			}
+		})
+	}
+}
+
+func TestRegistrationCartAndCatalog(t *testing.T) {
+	clearDB()
+	svc := newRegistrationService(t)
+	term := newRegistrationTerm(t, -time.Hour, time.Hour)
+	limited := newRegistrationSection(t, term, 3, 2, "09:00", "10:00")
+	unlimited := newRegistrationSection(t, term, 3, 0, "11:00", "12:00")
+	closed := newRegistrationSection(t, term, 3, 0, "13:00", "14:00")
+	testDB.Model(closed).Update("status", "closed")
+
+	taken := newRulesStudent(t)
+	testDB.Create(&models.Enrollment{StudentID: taken.ID, CourseID: limited.CourseID, SectionID: &limited.ID, Status: models.EnrollmentActive})
+
+	_, entries, total, err := svc.Catalog(repository.CatalogFilter{TermID: term.ID}, 1, 20, time.Now())
+	if err != nil {
+		t.Fatalf("Catalog: %v", err)
+	}
+	if total != 2 {
+		t.Fatalf("catalog lists %d sections, want the 2 open ones", total)
+	}
+	remaining := map[uint]int{}
+	for _, entry := range entries {
+		remaining[entry.ID] = entry.SeatsRemaining
+	}
+	if remaining[limited.ID] != 1 || remaining[unlimited.ID] != -1 {
+		t.Errorf("seats remaining = %v, want 1 for the limited section and -1 for the unlimited one", remaining)
+	}
+
+	student := newRulesStudent(t)
+	fillCart(t, svc, student.ID, limited, unlimited)
+	if _, err := svc.AddToCart(student.ID, limited.ID); !errors.Is(err, service.ErrRegistrationInvalid) {
+		t.Errorf("adding a section twice = %v, want ErrRegistrationInvalid", err)
+	}
+	if _, err := svc.AddToCart(student.ID, closed.ID); !errors.Is(err, service.ErrRegistrationInvalid) {
+		t.Errorf("adding a closed section = %v, want ErrRegistrationInvalid", err)
+	}
+
+	if err := svc.RemoveFromCart(student.ID, limited.ID); err != nil {
+		t.Fatalf("RemoveFromCart: %v", err)
+	}
+	if err := svc.RemoveFromCart(student.ID, limited.ID); !errors.Is(err, service.ErrCartItemNotFound) {
+		t.Errorf("removing twice = %v, want ErrCartItemNotFound", err)
+	}
+	cart, err := svc.GetCart(student.ID)
+	if err != nil || len(cart) != 1 || cart[0].SectionID != unlimited.ID || cart[0].Section.Course.ID != unlimited.CourseID {
+		t.Errorf("cart = %+v, %v; want the unlimited section with its course", cart, err)
+	}
+}
+
+func TestRegistrationValidateAndRegister(t *testing.T) {
+	clearDB()
+	svc := newRegistrationService(t)
+	setMaxCreditHours(t, "9")
+	term := newRegistrationTerm(t, -time.Hour, time.Hour)
+
+	morning := newRegistrationSection(t, term, 3, 0, "09:00", "10:00")
+	clashing := newRegistrationSection(t, term, 3, 0, "09:30", "10:30")
+	lab := newRegistrationSection(t, term, 1, 0, "13:00", "14:00")
+	lecture := newRegistrationSection(t, term, 4, 0, "11:00", "12:00")
+	heavy := newRegistrationSection(t, term, 6, 0, "15:00", "16:00")
+	full := newRegistrationSection(t, term, 3, 1, "17:00", "18:00")
+	testDB.Create(&models.CourseRequisite{CourseID: lecture.CourseID, RequiredCourseID: lab.CourseID, Kind: models.RequisiteCorequisite})
+	holder := newRulesStudent(t)
+	testDB.Create(&models.Enrollment{StudentID: holder.ID, CourseID: full.CourseID, SectionID: &full.ID, Status: models.EnrollmentActive})
+
+	tests := []struct {
+		name       string
+		cart       []*models.CourseSection
+		waitlist   bool
+		wantCodes  []string
+		wantStatus []string
+	}{
+		{"clashing sections", []*models.CourseSection{morning, clashing}, true,
+			[]string{service.RejectScheduleConflict, service.RejectScheduleConflict}, nil},
+		{"over the credit limit", []*models.CourseSection{morning, heavy, lab}, true,
+			[]string{service.RejectCreditLimit}, nil},
+		{"co-requisite alone", []*models.CourseSection{lecture}, true,
+			[]string{service.RejectCorequisiteMissing}, nil},
+		{"co-requisite in the cart", []*models.CourseSection{lecture, lab, morning}, true,
+			nil, []string{models.EnrollmentActive, models.EnrollmentActive, models.EnrollmentActive}},
+		{"full section without waitlist", []*models.CourseSection{morning, full}, false,
+			[]string{service.RejectCourseFull}, nil},
+		{"full section with waitlist", []*models.CourseSection{morning, full}, true,
+			nil, []string{models.EnrollmentActive, models.EnrollmentWaitlisted}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			student := newRulesStudent(t)
+			fillCart(t, svc, student.ID, tt.cart...)
+
+			result, err := svc.Register(context.Background(), student.ID, tt.waitlist, student.UserID, time.Now())
+			var enrolled int64
+			testDB.Model(&models.Enrollment{}).Where("student_id = ?", student.ID).Count(&enrolled)
+			cart, _ := svc.GetCart(student.ID)
+
+			if tt.wantCodes != nil {
+				if !errors.Is(err, service.ErrRegistrationInvalid) {
+					t.Fatalf("Register = %v, want ErrRegistrationInvalid", err)
+				}
+				if codes := resultCodes(result); !slices.Equal(codes, tt.wantCodes) {
+					t.Errorf("codes = %v, want %v", codes, tt.wantCodes)
+				}
+				if enrolled != 0 || len(cart) != len(tt.cart) {
+					t.Errorf("after a failed registration: %d enrollments and %d in the cart, want 0 and %d", enrolled, len(cart), len(tt.cart))
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Register: %v (%+v)", err, result)
+			}
+			for i, item := range result.Items {
+				if item.EnrollmentID == 0 || item.Status != tt.wantStatus[i] {
+					t.Errorf("item %d: enrollment %d %q, want %q", i, item.EnrollmentID, item.Status, tt.wantStatus[i])
+				}
+			}
+			if int(enrolled) != len(tt.cart) || len(cart) != 0 {
+				t.Errorf("after registering: %d enrollments and %d in the cart, want %d and 0", enrolled, len(cart), len(tt.cart))
+			}
+		})
+	}
+}
+
+// Registrations made at the same time are held to the credit hour limit
+// together, not each against the hours saved before either
+func TestRegistrationCreditLimitConcurrent(t *testing.T) {
+	clearDB()
+	enrollments := newEnrollmentService(t)
+	term := newRegistrationTerm(t, -time.Hour, time.Hour)
+	sections := []*models.CourseSection{
+		newRegistrationSection(t, term, 3, 0, "09:00", "10:00"),
+		newRegistrationSection(t, term, 3, 0, "11:00", "12:00"),
+	}
+	student := newRulesStudent(t)
+
+	errs := make([]error, len(sections))
+	var wg sync.WaitGroup
+	for i, section := range sections {
+		wg.Add(1)
+		go func(i int, sectionID uint) {
+			defer wg.Done()
+			plan := []*models.Enrollment{{StudentID: student.ID, SectionID: &sectionID}}
+			errs[i] = enrollments.EnrollPlan(context.Background(), plan, service.EnrollOptions{MaxCreditHours: 5})
+		}(i, section.ID)
+	}
+	wg.Wait()
+
+	var saved int
+	for _, err := range errs {
+		if err == nil {
+			saved++
+		} else if codes := rejectionCodes(t, err); !slices.Equal(codes, []string{service.RejectCreditLimit}) {
+			t.Errorf("codes %v, want [%s]", codes, service.RejectCreditLimit)
+		}
+	}
+	var count int64
+	testDB.Model(&models.Enrollment{}).Where("student_id = ?", student.ID).Count(&count)
+	if saved != 1 || count != 1 {
+		t.Errorf("%d registrations succeeded and %d enrollments saved, want 1 of each", saved, count)
+	}
+}
+
+func TestRegistrationOverride(t *testing.T) {
+	clearDB()
+	svc := newRegistrationService(t)
+	admin := createTestUser(t, models.RoleAdmin, true)
+	closedTerm := newRegistrationTerm(t, -48*time.Hour, -24*time.Hour)
+	section := newRegistrationSection(t, closedTerm, 3, 1, "09:00", "10:00")
+	required := newRulesCourse(t, 0)
+	testDB.Create(&models.CourseRequisite{CourseID: section.CourseID, RequiredCourseID: required.ID, Kind: models.RequisitePrerequisite})
+	holder := newRulesStudent(t)
+	testDB.Create(&models.Enrollment{StudentID: holder.ID, CourseID: section.CourseID, SectionID: &section.ID, Status: models.EnrollmentActive})
+
+	student := newRulesStudent(t)
+	fillCart(t, svc, student.ID, section)
+	if result, err := svc.Register(context.Background(), student.ID, false, student.UserID, time.Now()); !errors.Is(err, service.ErrRegistrationInvalid) {
+		t.Fatalf("Register = %v (%+v), want it rejected", err, result)
+	}
+
+	if _, err := svc.Override(context.Background(), student.ID, section.ID, admin.ID, ""); !errors.Is(err, service.ErrRegistrationInvalid) {
+		t.Errorf("override without a reason = %v, want ErrRegistrationInvalid", err)
+	}
+	enrollment, err := svc.Override(context.Background(), student.ID, section.ID, admin.ID, "approved by the department head")
+	if err != nil {
+		t.Fatalf("Override: %v", err)
+	}
+	if enrollment.Status != models.EnrollmentActive {
+		t.Errorf("overridden enrollment is %q, want active", enrollment.Status)
+	}
+	var transition models.EnrollmentTransition
+	testDB.Where("enrollment_id = ?", enrollment.ID).First(&transition)
+	if transition.ActorID == nil || *transition.ActorID != admin.ID || transition.Reason != "approved by the department head" {
+		t.Errorf("override recorded as %+v", transition)
+	}
+	if cart, _ := svc.GetCart(student.ID); len(cart) != 0 {
+		t.Errorf("cart still has %d sections", len(cart))
+	}
+
+	_, err = svc.Override(context.Background(), student.ID, section.ID, admin.ID, "again")
+	if codes := rejectionCodes(t, err); !slices.Equal(codes, []string{service.RejectAlreadyEnrolled}) {
+		t.Errorf("overriding twice: codes %v, want already_enrolled", codes)
+	}
+}
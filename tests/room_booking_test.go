@@ -0,0 +1,402 @@
+package tests
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"school-management-system/internal/models"
+	"school-management-system/internal/repository"
+	"school-management-system/internal/service"
+)
+
+func newRoomService(t *testing.T) service.RoomService {
+	t.Helper()
+	testDB.AutoMigrate(&models.AcademicTerm{}, &models.CourseSection{}, &models.Enrollment{}, &models.TimeTable{},
+		&models.Room{}, &models.Resource{}, &models.Booking{})
+	return service.NewRoomService(repository.NewRoomRepository(), repository.NewResourceRepository(), repository.NewBookingRepository(),
+		repository.NewTimeTableRepository(), repository.NewAcademicTermRepository(), repository.NewEnrollmentRepository())
+}
+
+// newBookingRoom registers an active room with a name no other test uses
+func newBookingRoom(t *testing.T, building string, capacity int, features string) *models.Room {
+	t.Helper()
+	room := &models.Room{Name: fmt.Sprintf("Room %d", time.Now().UnixNano()), Building: building, Capacity: capacity,
+		Features: features, IsActive: true}
+	if err := testDB.Create(room).Error; err != nil {
+		t.Fatalf("create room: %v", err)
+	}
+	return room
+}
+
+// firstMonday returns the first Monday of a term
+func firstMonday(term *models.AcademicTerm) time.Time {
+	day := time.Date(term.StartDate.Year(), term.StartDate.Month(), term.StartDate.Day(), 0, 0, 0, 0, time.UTC)
+	for day.Weekday() != time.Monday || day.Before(term.StartDate) {
+		day = day.AddDate(0, 0, 1)
+	}
+	return day
+}
+
+// scheduleClass timetables a new section of a term in a room on Mondays
+func scheduleClass(t *testing.T, term *models.AcademicTerm, room *models.Room, start, end string) *models.CourseSection {
+	t.Helper()
+	section := newTimetableSection(t, term, newTimetableTeacherID(), 1, 0)
+	slot := &models.TimeTable{CourseID: section.CourseID, SectionID: &section.ID, TermID: &term.ID, DayOfWeek: "Monday",
+		StartTime: start, EndTime: end, Classroom: room.Name, IsActive: true}
+	if err := testDB.Create(slot).Error; err != nil {
+		t.Fatalf("create timetable: %v", err)
+	}
+	return section
+}
+
+func uintPtr(value uint) *uint {
+	return &value
+}
+
+func TestRoomBooking(t *testing.T) {
+	rooms := newRoomService(t)
+	term := newRegistrationTerm(t, -time.Hour, time.Hour)
+	monday := firstMonday(term)
+	room := newBookingRoom(t, "", 30, "projector")
+	scheduleClass(t, term, room, "09:00", "10:00")
+	resource := &models.Resource{Name: fmt.Sprintf("Projector %d", time.Now().UnixNano()), Type: "Projector", Quantity: 2, IsActive: true}
+	if err := rooms.CreateResource(resource); err != nil {
+		t.Fatalf("CreateResource: %v", err)
+	}
+	if resource.Type != "projector" {
+		t.Errorf("type = %q, want projector", resource.Type)
+	}
+
+	meeting := &models.Booking{RoomID: &room.ID, Title: "Staff meeting", Purpose: models.BookingMeeting, Date: monday,
+		StartTime: "11:00", EndTime: "12:00", BookedBy: 1}
+	if err := rooms.Book(meeting); err != nil {
+		t.Fatalf("Book: %v", err)
+	}
+	lent := &models.Booking{ResourceID: &resource.ID, Title: "Exam", Date: monday, StartTime: "13:00", EndTime: "14:00", BookedBy: 1}
+	if err := rooms.Book(lent); err != nil {
+		t.Fatalf("Book: %v", err)
+	}
+	if lent.Quantity != 1 || lent.Purpose != models.BookingOther {
+		t.Errorf("booked %d for %q, want 1 for other", lent.Quantity, lent.Purpose)
+	}
+
+	tests := []struct {
+		name      string
+		booking   models.Booking
+		wantErr   error
+		wantCodes []string
+	}{
+		{"neither room nor resource", models.Booking{Title: "Exam", Date: monday, StartTime: "14:00", EndTime: "15:00"},
+			service.ErrInvalidBooking, nil},
+		{"both room and resource", models.Booking{RoomID: &room.ID, ResourceID: &resource.ID, Title: "Exam", Date: monday,
+			StartTime: "14:00", EndTime: "15:00"}, service.ErrInvalidBooking, nil},
+		{"no title", models.Booking{RoomID: &room.ID, Date: monday, StartTime: "14:00", EndTime: "15:00"},
+			service.ErrInvalidBooking, nil},
+		{"unknown purpose", models.Booking{RoomID: &room.ID, Title: "Party", Purpose: "party", Date: monday, StartTime: "14:00",
+			EndTime: "15:00"}, service.ErrInvalidBooking, nil},
+		{"in the past", models.Booking{RoomID: &room.ID, Title: "Exam", Date: time.Now().AddDate(0, 0, -2), StartTime: "14:00",
+			EndTime: "15:00"}, service.ErrInvalidBooking, nil},
+		{"ends before it starts", models.Booking{RoomID: &room.ID, Title: "Exam", Date: monday, StartTime: "15:00", EndTime: "14:00"},
+			service.ErrInvalidBooking, nil},
+		{"too many attendees", models.Booking{RoomID: &room.ID, Title: "Exam", Attendees: 31, Date: monday, StartTime: "14:00",
+			EndTime: "15:00"}, service.ErrInvalidBooking, nil},
+		{"unknown room", models.Booking{RoomID: uintPtr(999999999), Title: "Exam", Date: monday, StartTime: "14:00", EndTime: "15:00"},
+			service.ErrRoomNotFound, nil},
+		{"more than there are", models.Booking{ResourceID: &resource.ID, Quantity: 3, Title: "Exam", Date: monday, StartTime: "15:00",
+			EndTime: "16:00"}, service.ErrInvalidBooking, nil},
+		{"during a class", models.Booking{RoomID: &room.ID, Title: "Exam", Date: monday, StartTime: "9:30", EndTime: "10:30"},
+			nil, []string{service.BookingClassInRoom}},
+		{"during a class and a booking", models.Booking{RoomID: &room.ID, Title: "Exam", Date: monday, StartTime: "09:00",
+			EndTime: "12:00"}, nil, []string{service.BookingClassInRoom, service.BookingRoomTaken}},
+		{"during a booking", models.Booking{RoomID: &room.ID, Title: "Exam", Date: monday, StartTime: "11:30", EndTime: "12:30"},
+			nil, []string{service.BookingRoomTaken}},
+		{"too few left", models.Booking{ResourceID: &resource.ID, Quantity: 2, Title: "Exam", Date: monday, StartTime: "13:30",
+			EndTime: "14:30"}, nil, []string{service.BookingResourceUnavailable}},
+		{"between the class and the booking", models.Booking{RoomID: &room.ID, Title: "Exam", Attendees: 30, Date: monday,
+			StartTime: "10:00", EndTime: "11:00"}, nil, nil},
+		{"a day without the class", models.Booking{RoomID: &room.ID, Title: "Exam", Date: monday.AddDate(0, 0, 1), StartTime: "09:00",
+			EndTime: "10:00"}, nil, nil},
+		{"the rest of the resource", models.Booking{ResourceID: &resource.ID, Title: "Exam", Date: monday, StartTime: "13:30",
+			EndTime: "14:30"}, nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			booking := tt.booking
+			booking.BookedBy = 1
+			err := rooms.Book(&booking)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if tt.wantCodes == nil {
+				if err != nil {
+					t.Fatalf("Book: %v", err)
+				}
+				if _, err := rooms.CancelBooking(booking.ID, 1); err != nil {
+					t.Fatalf("CancelBooking: %v", err)
+				}
+				return
+			}
+			var conflict *service.BookingConflictError
+			if !errors.As(err, &conflict) {
+				t.Fatalf("err = %v, want a BookingConflictError", err)
+			}
+			var codes []string
+			for _, c := range conflict.Conflicts {
+				codes = append(codes, c.Code)
+			}
+			if !slices.Equal(codes, tt.wantCodes) {
+				t.Errorf("codes = %v, want %v", codes, tt.wantCodes)
+			}
+		})
+	}
+
+	// A room with bookings to come stays, and a cancelled booking frees it
+	if err := rooms.Delete(room.ID); !errors.Is(err, service.ErrInvalidRoom) {
+		t.Errorf("Delete = %v, want ErrInvalidRoom", err)
+	}
+	cancelled, err := rooms.CancelBooking(meeting.ID, 2)
+	if err != nil {
+		t.Fatalf("CancelBooking: %v", err)
+	}
+	if cancelled.Status != models.BookingCancelled || cancelled.CancelledBy != 2 {
+		t.Errorf("booking %s by %d, want cancelled by 2", cancelled.Status, cancelled.CancelledBy)
+	}
+	if _, err := rooms.CancelBooking(meeting.ID, 2); !errors.Is(err, service.ErrBookingCancelled) {
+		t.Errorf("second CancelBooking = %v, want ErrBookingCancelled", err)
+	}
+	again := &models.Booking{RoomID: &room.ID, Title: "Staff meeting", Date: monday, StartTime: "11:00", EndTime: "12:00", BookedBy: 1}
+	if err := rooms.Book(again); err != nil {
+		t.Errorf("Book after cancelling: %v", err)
+	}
+
+	listed, total, err := rooms.ListBookings(repository.BookingFilter{RoomID: room.ID, IncludeCancelled: true}, 1, 20)
+	if err != nil {
+		t.Fatalf("ListBookings: %v", err)
+	}
+	if total != 4 || len(listed) != 4 {
+		t.Errorf("listed %d of %d bookings, want 4", len(listed), total)
+	}
+}
+
+// Bookings made at the same time are checked one at a time, so only as
+// many as there is room for are saved and the rest are refused as clashes
+func TestRoomBookingConcurrent(t *testing.T) {
+	rooms := newRoomService(t)
+	term := newRegistrationTerm(t, -time.Hour, time.Hour)
+	monday := firstMonday(term)
+	room := newBookingRoom(t, "", 0, "")
+	resource := &models.Resource{Name: fmt.Sprintf("Laptop %d", time.Now().UnixNano()), Type: "laptop", Quantity: 2, IsActive: true}
+	if err := rooms.CreateResource(resource); err != nil {
+		t.Fatalf("CreateResource: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		booking   models.Booking
+		wantSaved int
+	}{
+		{"room", models.Booking{RoomID: &room.ID, Title: "Exam", Date: monday, StartTime: "14:00", EndTime: "15:00"}, 1},
+		{"resource", models.Booking{ResourceID: &resource.ID, Title: "Exam", Date: monday, StartTime: "14:00", EndTime: "15:00"}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := make([]error, 6)
+			var wg sync.WaitGroup
+			for i := range errs {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					booking := tt.booking
+					errs[i] = rooms.Book(&booking)
+				}(i)
+			}
+			wg.Wait()
+
+			saved := 0
+			for _, err := range errs {
+				var conflict *service.BookingConflictError
+				switch {
+				case err == nil:
+					saved++
+				case !errors.As(err, &conflict):
+					t.Errorf("Book: %v, want a booking or a clash", err)
+				}
+			}
+			if saved != tt.wantSaved {
+				t.Errorf("%d bookings saved, want %d", saved, tt.wantSaved)
+			}
+		})
+	}
+}
+
+// A booking's check runs under the room's lock, so a slow check cannot let
+// a clashing booking in
+func TestBookingCheckHoldsRoom(t *testing.T) {
+	newRoomService(t)
+	bookings := repository.NewBookingRepository()
+	room := newBookingRoom(t, "", 0, "")
+	day := time.Now().AddDate(0, 0, 7).Truncate(24 * time.Hour)
+
+	errFull := errors.New("room taken")
+	errs := make([]error, 4)
+	var wg sync.WaitGroup
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			booking := &models.Booking{RoomID: &room.ID, Title: "Exam", Date: day, StartTime: "14:00", EndTime: "15:00",
+				Status: models.BookingConfirmed}
+			errs[i] = bookings.CreateChecked(booking, func(sameDay []models.Booking, _ []models.TimeTable) error {
+				time.Sleep(20 * time.Millisecond)
+				if len(sameDay) > 0 {
+					return errFull
+				}
+				return nil
+			})
+		}(i)
+	}
+	wg.Wait()
+
+	saved := 0
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			saved++
+		case !errors.Is(err, errFull):
+			t.Errorf("CreateChecked: %v, want a booking or the check's refusal", err)
+		}
+	}
+	if saved != 1 {
+		t.Errorf("%d bookings saved, want 1", saved)
+	}
+}
+
+func TestFindFreeRooms(t *testing.T) {
+	rooms := newRoomService(t)
+	term := newRegistrationTerm(t, -time.Hour, time.Hour)
+	monday := firstMonday(term)
+	building := fmt.Sprintf("Block %d", time.Now().UnixNano())
+	small := newBookingRoom(t, building, 20, "")
+	lab := newBookingRoom(t, building, 40, "lab,projector")
+	hall := newBookingRoom(t, building, 60, "projector")
+	closed := newBookingRoom(t, building, 80, "")
+	testDB.Model(closed).Update("is_active", false)
+	newBookingRoom(t, building+" annex", 10, "")
+
+	scheduleClass(t, term, small, "09:00", "10:00")
+	if err := rooms.Book(&models.Booking{RoomID: &lab.ID, Title: "Exam", Date: monday, StartTime: "09:30", EndTime: "10:30"}); err != nil {
+		t.Fatalf("Book: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		query   service.FreeRoomQuery
+		want    []uint
+		wantErr error
+	}{
+		{"around a class and a booking", service.FreeRoomQuery{Date: monday, StartTime: "09:00", EndTime: "10:00"},
+			[]uint{hall.ID}, nil},
+		{"after both", service.FreeRoomQuery{Date: monday, StartTime: "10:30", EndTime: "11:00"},
+			[]uint{small.ID, lab.ID, hall.ID}, nil},
+		{"every week ignores bookings", service.FreeRoomQuery{DayOfWeek: "monday", TermID: term.ID, StartTime: "09:00", EndTime: "10:00"},
+			[]uint{lab.ID, hall.ID}, nil},
+		{"another day", service.FreeRoomQuery{Date: monday.AddDate(0, 0, 1), StartTime: "09:00", EndTime: "10:00"},
+			[]uint{small.ID, lab.ID, hall.ID}, nil},
+		{"seats enough", service.FreeRoomQuery{Date: monday, StartTime: "10:30", EndTime: "11:00", MinCapacity: 30},
+			[]uint{lab.ID, hall.ID}, nil},
+		{"has the features", service.FreeRoomQuery{Date: monday, StartTime: "10:30", EndTime: "11:00", Features: "Projector"},
+			[]uint{lab.ID, hall.ID}, nil},
+		{"no day", service.FreeRoomQuery{StartTime: "09:00", EndTime: "10:00"}, nil, service.ErrInvalidBooking},
+		{"bad time", service.FreeRoomQuery{Date: monday, StartTime: "9am", EndTime: "10:00"}, nil, service.ErrInvalidBooking},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query := tt.query
+			query.Building = building
+			free, err := rooms.FindFreeRooms(query)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("FindFreeRooms: %v", err)
+			}
+			var ids []uint
+			for _, room := range free {
+				ids = append(ids, room.ID)
+			}
+			if !slices.Equal(ids, tt.want) {
+				t.Errorf("free rooms = %v, want %v", ids, tt.want)
+			}
+		})
+	}
+}
+
+func TestRoomUtilization(t *testing.T) {
+	rooms := newRoomService(t)
+	term := newRegistrationTerm(t, -time.Hour, time.Hour)
+	monday := firstMonday(term)
+	busy := newBookingRoom(t, "", 20, "")
+	idle := newBookingRoom(t, "", 10, "")
+	unregistered := &models.Room{Name: fmt.Sprintf("Portacabin %d", time.Now().UnixNano())}
+
+	section := scheduleClass(t, term, busy, "09:00", "11:00")
+	scheduleClass(t, term, busy, "13:00", "14:00")
+	scheduleClass(t, term, unregistered, "09:00", "10:00")
+	for i := 0; i < 5; i++ {
+		testDB.Create(&models.Enrollment{StudentID: uint(i + 1), CourseID: section.CourseID, SectionID: &section.ID, TermID: &term.ID,
+			Status: "active", EnrolledAt: time.Now()})
+	}
+	if err := rooms.Book(&models.Booking{RoomID: &idle.ID, Title: "Exam", Date: monday, StartTime: "14:00", EndTime: "15:30"}); err != nil {
+		t.Fatalf("Book: %v", err)
+	}
+
+	report, err := rooms.Utilization(term.ID, time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatalf("Utilization: %v", err)
+	}
+	termStart := time.Date(term.StartDate.Year(), term.StartDate.Month(), term.StartDate.Day(), 0, 0, 0, 0, time.UTC)
+	if report.WeekHours != 40 || !report.From.Equal(termStart) {
+		t.Errorf("%v hour weeks from %v, want 40 from %v", report.WeekHours, report.From, termStart)
+	}
+	got := map[string]service.RoomUtilization{}
+	for _, room := range report.Rooms {
+		got[room.Name] = room
+	}
+
+	tests := []struct {
+		name string
+		room string
+		want service.RoomUtilization
+	}{
+		{"timetabled", busy.Name, service.RoomUtilization{RoomID: busy.ID, Name: busy.Name, Capacity: 20, Registered: true,
+			WeeklyClasses: 2, WeeklyHours: 3, Utilization: 7.5, SeatFill: 12.5}},
+		{"booked only", idle.Name, service.RoomUtilization{RoomID: idle.ID, Name: idle.Name, Capacity: 10, Registered: true,
+			Bookings: 1, BookedHours: 1.5}},
+		{"not registered", unregistered.Name, service.RoomUtilization{Name: unregistered.Name, WeeklyClasses: 1, WeeklyHours: 1,
+			Utilization: 2.5}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if room, ok := got[tt.room]; !ok || room != tt.want {
+				t.Errorf("got %+v, want %+v", room, tt.want)
+			}
+		})
+	}
+
+	if _, err := rooms.Utilization(999999999, time.Time{}, time.Time{}); !errors.Is(err, service.ErrTimetableMissing) {
+		t.Errorf("missing term err = %v, want ErrTimetableMissing", err)
+	}
+	if _, err := rooms.Utilization(term.ID, monday, monday.AddDate(0, 0, -1)); !errors.Is(err, service.ErrInvalidBooking) {
+		t.Errorf("reversed dates err = %v, want ErrInvalidBooking", err)
+	}
+}
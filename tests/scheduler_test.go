@@ -0,0 +1,275 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"school-management-system/internal/models"
+	"school-management-system/internal/repository"
+	"school-management-system/internal/scheduler"
+	"school-management-system/internal/service"
+)
+
+func TestCronNext(t *testing.T) {
+	// Wednesday 2026-01-14 10:07
+	from := time.Date(2026, 1, 14, 10, 7, 30, 0, time.UTC)
+	tests := []struct {
+		spec string
+		want time.Time
+	}{
+		{"* * * * *", time.Date(2026, 1, 14, 10, 8, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2026, 1, 14, 10, 15, 0, 0, time.UTC)},
+		{"5 * * * *", time.Date(2026, 1, 14, 11, 5, 0, 0, time.UTC)},
+		{"0 8 * * *", time.Date(2026, 1, 15, 8, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2026, 1, 15, 0, 0, 0, 0, time.UTC)},
+		{"0 7 * * mon", time.Date(2026, 1, 19, 7, 0, 0, 0, time.UTC)},
+		{"0 9 * * 1-5", time.Date(2026, 1, 15, 9, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2026, 1, 18, 0, 0, 0, 0, time.UTC)},
+		{"30 2 1 feb,mar *", time.Date(2026, 2, 1, 2, 30, 0, 0, time.UTC)},
+		{"0 12 29 2 *", time.Date(2028, 2, 29, 12, 0, 0, 0, time.UTC)},
+		// Either day field matches when both are restricted
+		{"0 0 20 * fri", time.Date(2026, 1, 16, 0, 0, 0, 0, time.UTC)},
+		{"0 0 30 2 *", time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			schedule, err := scheduler.Parse(tt.spec)
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			if got := schedule.Next(from); !got.Equal(tt.want) {
+				t.Errorf("Next = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCronParseErrors(t *testing.T) {
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "* * * 13 *", "*/0 * * * *", "5-1 * * * *", "* * * * funday"} {
+		if _, err := scheduler.Parse(spec); err == nil {
+			t.Errorf("Parse(%q) succeeded, want error", spec)
+		}
+	}
+}
+
+func newTestScheduler(instance string) service.JobSchedulerService {
+	testDB.AutoMigrate(&models.ScheduledJob{}, &models.JobRun{}, &models.SchedulerLease{})
+	return service.NewJobSchedulerService(repository.NewScheduledJobRepository(), service.SchedulerOptions{Instance: instance})
+}
+
+func TestJobSchedulerRunsDueJobsOnce(t *testing.T) {
+	suffix := time.Now().UnixNano()
+	first := newTestScheduler(fmt.Sprintf("first-%d", suffix))
+	second := newTestScheduler(fmt.Sprintf("second-%d", suffix))
+	defer first.Stop()
+	defer second.Stop()
+
+	var calls int32
+	job := service.JobDefinition{
+		Name:     fmt.Sprintf("test.count_%d", suffix),
+		Schedule: "* * * * *",
+		Run: func(ctx context.Context) (string, error) {
+			atomic.AddInt32(&calls, 1)
+			return "counted", nil
+		},
+	}
+	for _, s := range []service.JobSchedulerService{first, second} {
+		if err := s.Register(job); err != nil {
+			t.Fatalf("Register: %v", err)
+		}
+	}
+
+	due := time.Now().Add(2 * time.Minute)
+	if leader, err := first.Tick(due); err != nil || !leader {
+		t.Fatalf("first Tick = %v, %v; want the lease", leader, err)
+	}
+	if leader, _ := second.Tick(due); leader {
+		t.Fatal("second instance took a held lease")
+	}
+	first.Wait()
+
+	if got := atomic.LoadInt32(&calls); got != 1 {
+		t.Fatalf("job ran %d times, want 1", got)
+	}
+	runs, total, _ := first.GetRuns(job.Name, 1, 10)
+	if total != 1 || runs[0].Status != models.JobRunSucceeded || runs[0].Result != "counted" || runs[0].Trigger != models.JobTriggerScheduled {
+		t.Fatalf("runs = %+v", runs)
+	}
+	stored, _ := first.GetByName(job.Name)
+	if stored.RunningSince != 0 || stored.LastStatus != models.JobRunSucceeded || stored.NextRunAt <= time.Now().Unix() {
+		t.Errorf("job after run = %+v", stored)
+	}
+
+	// Paused jobs are skipped by the schedule but can still be triggered
+	if _, err := first.SetPaused(job.Name, true); err != nil {
+		t.Fatalf("SetPaused: %v", err)
+	}
+	first.Tick(time.Now().Add(time.Hour))
+	first.Wait()
+	if got := atomic.LoadInt32(&calls); got != 1 {
+		t.Fatalf("paused job ran, calls = %d", got)
+	}
+	run, err := second.Trigger(job.Name, nil)
+	if err != nil {
+		t.Fatalf("Trigger: %v", err)
+	}
+	second.Wait()
+	if got := atomic.LoadInt32(&calls); got != 2 || run.Trigger != models.JobTriggerManual {
+		t.Errorf("manual trigger: calls = %d, run = %+v", got, run)
+	}
+
+	// Stopping gives up the lease
+	first.Stop()
+	if leader, _ := second.Tick(time.Now()); !leader {
+		t.Error("second instance did not take over a released lease")
+	}
+}
+
+func TestJobSchedulerRecordsFailures(t *testing.T) {
+	suffix := time.Now().UnixNano()
+	s := newTestScheduler(fmt.Sprintf("failures-%d", suffix))
+	defer s.Stop()
+
+	release := make(chan struct{})
+	tests := []struct {
+		name    string
+		run     service.JobFunc
+		wantErr string
+	}{
+		{"error", func(ctx context.Context) (string, error) { return "", errors.New("mail server down") }, "mail server down"},
+		{"panic", func(ctx context.Context) (string, error) { panic("nil map") }, "panic: nil map"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name := fmt.Sprintf("test.%s_%d", tt.name, suffix)
+			if err := s.Register(service.JobDefinition{Name: name, Run: tt.run}); err != nil {
+				t.Fatalf("Register: %v", err)
+			}
+			if _, err := s.Trigger(name, nil); err != nil {
+				t.Fatalf("Trigger: %v", err)
+			}
+			s.Wait()
+
+			runs, _, _ := s.GetRuns(name, 1, 10)
+			if len(runs) != 1 || runs[0].Status != models.JobRunFailed || runs[0].Error != tt.wantErr {
+				t.Errorf("runs = %+v", runs)
+			}
+			job, _ := s.GetByName(name)
+			if job.LastStatus != models.JobRunFailed || job.NextRunAt != 0 {
+				t.Errorf("job = %+v, want failed and no schedule", job)
+			}
+		})
+	}
+
+	t.Run("already running", func(t *testing.T) {
+		name := fmt.Sprintf("test.slow_%d", suffix)
+		s.Register(service.JobDefinition{Name: name, Run: func(ctx context.Context) (string, error) {
+			<-release
+			return "", nil
+		}})
+		if _, err := s.Trigger(name, nil); err != nil {
+			t.Fatalf("Trigger: %v", err)
+		}
+		if _, err := s.Trigger(name, nil); !errors.Is(err, service.ErrJobRunning) {
+			t.Errorf("second Trigger err = %v, want ErrJobRunning", err)
+		}
+		close(release)
+		s.Wait()
+	})
+
+	if _, err := s.Trigger("test.unknown", nil); !errors.Is(err, service.ErrJobNotFound) {
+		t.Errorf("unknown job err = %v, want ErrJobNotFound", err)
+	}
+	if err := s.Register(service.JobDefinition{Name: "test.bad", Schedule: "every day"}); err == nil {
+		t.Error("registered a job with an invalid schedule")
+	}
+}
+
+func TestPaymentAutomation(t *testing.T) {
+	testDB.AutoMigrate(&models.Payment{}, &models.Notification{})
+	user := createTestUser(t, models.RoleStudent, true)
+	student := &models.Student{UserID: user.ID, StudentID: fmt.Sprintf("PAY%d", time.Now().UnixNano())}
+	if err := testDB.Create(student).Error; err != nil {
+		t.Fatalf("create student: %v", err)
+	}
+
+	now := time.Now()
+	payments := []*models.Payment{
+		{StudentID: student.ID, Amount: 100, Status: models.PaymentStatusPending, DueDate: now.AddDate(0, 0, -1).Unix()},
+		{StudentID: student.ID, Amount: 200, Status: models.PaymentStatusPending, DueDate: now.AddDate(0, 0, 2).Unix()},
+		{StudentID: student.ID, Amount: 300, Status: models.PaymentStatusPending, DueDate: now.AddDate(0, 0, 10).Unix()},
+		{StudentID: student.ID, Amount: 400, Status: models.PaymentStatusPaid, DueDate: now.AddDate(0, 0, -5).Unix()},
+	}
+	for _, p := range payments {
+		if err := testDB.Create(p).Error; err != nil {
+			t.Fatalf("create payment: %v", err)
+		}
+	}
+
+	svc := service.NewPaymentService(repository.NewPaymentRepository(), newNotifier(service.DispatcherOptions{}))
+	if _, err := svc.MarkOverdue(now); err != nil {
+		t.Fatalf("MarkOverdue: %v", err)
+	}
+	wantStatus := []string{models.PaymentStatusOverdue, models.PaymentStatusPending, models.PaymentStatusPending, models.PaymentStatusPaid}
+	for i, p := range payments {
+		var stored models.Payment
+		testDB.First(&stored, p.ID)
+		if stored.Status != wantStatus[i] {
+			t.Errorf("payment %d status = %s, want %s", i, stored.Status, wantStatus[i])
+		}
+	}
+
+	// The overdue payment and the one due in two days are reminded once
+	for i, want := range []int64{2, 2} {
+		if _, err := svc.SendReminders(now); err != nil {
+			t.Fatalf("SendReminders: %v", err)
+		}
+		var count int64
+		testDB.Model(&models.Notification{}).Where("user_id = ? AND subject = ?", user.ID, "payment").Count(&count)
+		if count != want {
+			t.Errorf("pass %d: %d reminders, want %d", i, count, want)
+		}
+	}
+
+	// Overdue payments are reminded again a week later
+	if _, err := svc.SendReminders(now.Add(8 * 24 * time.Hour)); err != nil {
+		t.Fatalf("SendReminders: %v", err)
+	}
+	var overdue models.Payment
+	testDB.First(&overdue, payments[0].ID)
+	if overdue.ReminderSentAt != now.Add(8*24*time.Hour).Unix() {
+		t.Errorf("overdue payment not reminded again, reminder_sent_at = %d", overdue.ReminderSentAt)
+	}
+}
+
+func TestAnnouncementExpiry(t *testing.T) {
+	testDB.AutoMigrate(&models.Announcement{})
+	now := time.Now()
+	expired := &models.Announcement{Title: "Old", IsActive: true, ExpiresAt: now.Add(-time.Minute).Unix()}
+	current := &models.Announcement{Title: "Current", IsActive: true, ExpiresAt: now.Add(time.Hour).Unix()}
+	forever := &models.Announcement{Title: "Forever", IsActive: true}
+	for _, a := range []*models.Announcement{expired, current, forever} {
+		if err := testDB.Create(a).Error; err != nil {
+			t.Fatalf("create announcement: %v", err)
+		}
+	}
+
+	svc := service.NewAnnouncementService(repository.NewAnnouncementRepository(), repository.NewUserRepository(), nil, nil)
+	if _, err := svc.ExpireDue(now); err != nil {
+		t.Fatalf("ExpireDue: %v", err)
+	}
+	for _, tt := range []struct {
+		a    *models.Announcement
+		want bool
+	}{{expired, false}, {current, true}, {forever, true}} {
+		var stored models.Announcement
+		testDB.First(&stored, tt.a.ID)
+		if stored.IsActive != tt.want {
+			t.Errorf("%s active = %v, want %v", tt.a.Title, stored.IsActive, tt.want)
+		}
+	}
+}
@@ -1,3 +1,282 @@
 package tests
 
-// Placeholder for service tests. Add tests here.
+import (
+	"testing"
+	"time"
+
+	"school-management-system/internal/models"
+	"school-management-system/internal/service"
+)
+
+func transcriptFixture() ([]models.AcademicTerm, []models.Grade) {
+	fall := models.AcademicTerm{
+		ID:        1,
+		Name:      "Fall 2024",
+		StartDate: time.Date(2024, 9, 1, 0, 0, 0, 0, time.UTC),
+		EndDate:   time.Date(2024, 12, 20, 0, 0, 0, 0, time.UTC),
+		Status:    models.TermStatusClosed,
+	}
+	spring := models.AcademicTerm{
+		ID:        2,
+		Name:      "Spring 2025",
+		StartDate: time.Date(2025, 1, 10, 0, 0, 0, 0, time.UTC),
+		EndDate:   time.Date(2025, 5, 20, 0, 0, 0, 0, time.UTC),
+		Status:    models.TermStatusActive,
+	}
+	fallID, springID := fall.ID, spring.ID
+
+	math := models.Course{ID: 10, CreditHours: 3}
+	art := models.Course{ID: 11, CreditHours: 2}
+	lab := models.Course{ID: 12, CreditHours: 1}
+
+	grades := []models.Grade{
+		{ID: 1, StudentID: 7, CourseID: math.ID, Course: math, TermID: &fallID, Grade: "F", GradedAt: fall.EndDate},
+		{ID: 2, StudentID: 7, CourseID: art.ID, Course: art, TermID: &fallID, Grade: models.GradePass, GradedAt: fall.EndDate},
+		{ID: 3, StudentID: 7, CourseID: math.ID, Course: math, TermID: &springID, Grade: "B", GradedAt: spring.EndDate},
+		{ID: 4, StudentID: 7, CourseID: lab.ID, Course: lab, TermID: &springID, Grade: models.GradeIncomplete, GradedAt: spring.EndDate},
+	}
+
+	// Terms are deliberately out of order; the builder sorts them.
+	return []models.AcademicTerm{spring, fall}, grades
+}
+
+func TestBuildTranscripts(t *testing.T) {
+	terms, grades := transcriptFixture()
+	transfers := []models.TransferCredit{
+		{ID: 1, StudentID: 7, Institution: "City College", Credits: 4, Grade: "A", CountsInGPA: false,
+			CreatedAt: time.Date(2024, 8, 1, 0, 0, 0, 0, time.UTC)},
+	}
+
+	tests := []struct {
+		name          string
+		policy        service.RepeatPolicy
+		wantTermGPA   []float64
+		wantCumGPA    float64
+		wantEarned    float64
+		wantAttempted float64
+	}{
+		{
+			name:   "replace drops the failed attempt",
+			policy: service.RepeatPolicyReplace,
+			// Fall: F replaced, P has no points. Spring: B over 3 credits.
+			wantTermGPA:   []float64{0, 0, 3.0},
+			wantCumGPA:    3.0,
+			wantEarned:    4 + 2 + 3,
+			wantAttempted: 4 + 5 + 3,
+		},
+		{
+			name:          "average keeps both attempts",
+			policy:        service.RepeatPolicyAverage,
+			wantTermGPA:   []float64{0, 0, 3.0},
+			wantCumGPA:    1.5,
+			wantEarned:    4 + 2 + 3,
+			wantAttempted: 4 + 5 + 3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows := service.BuildTranscripts(7, grades, terms, transfers, tt.policy, nil)
+			if len(rows) != len(tt.wantTermGPA) {
+				t.Fatalf("expected %d rows, got %d", len(tt.wantTermGPA), len(rows))
+			}
+
+			if rows[0].TermID != nil || rows[0].TransferCredits != 4 {
+				t.Errorf("expected leading transfer row with 4 credits, got %+v", rows[0])
+			}
+			if *rows[1].TermID != 1 || *rows[2].TermID != 2 {
+				t.Errorf("terms not in chronological order")
+			}
+			if !rows[1].IsOfficial || rows[2].IsOfficial {
+				t.Errorf("only the closed term should be official")
+			}
+
+			for i, want := range tt.wantTermGPA {
+				if rows[i].GPA != want {
+					t.Errorf("row %d: expected GPA %.2f, got %.2f", i, want, rows[i].GPA)
+				}
+			}
+
+			last := rows[len(rows)-1]
+			if last.CumulativeGPA != tt.wantCumGPA {
+				t.Errorf("expected cumulative GPA %.2f, got %.2f", tt.wantCumGPA, last.CumulativeGPA)
+			}
+			if last.CumulativeEarnedCredits != tt.wantEarned {
+				t.Errorf("expected %.0f earned credits, got %.0f", tt.wantEarned, last.CumulativeEarnedCredits)
+			}
+			if last.CumulativeCredits != tt.wantAttempted {
+				t.Errorf("expected %.0f attempted credits, got %.0f", tt.wantAttempted, last.CumulativeCredits)
+			}
+		})
+	}
+}
+
+func TestBuildTranscriptsIsDeterministic(t *testing.T) {
+	terms, grades := transcriptFixture()
+
+	first := service.BuildTranscripts(7, grades, terms, nil, service.RepeatPolicyReplace, nil)
+	second := service.BuildTranscripts(7, grades, terms, nil, service.RepeatPolicyReplace, nil)
+
+	if len(first) != len(second) {
+		t.Fatalf("row count differs between runs")
+	}
+	for i := range first {
+		if first[i].CumulativeGPA != second[i].CumulativeGPA || first[i].EarnedCredits != second[i].EarnedCredits {
+			t.Errorf("row %d differs between runs", i)
+		}
+	}
+}
+
+func ibScale() *models.GradingScale {
+	return &models.GradingScale{
+		Name:  "IB 1-7",
+		Scope: models.GradingScopeSchool,
+		Bands: []models.GradingBand{
+			{Letter: "7", MinPercentage: 85, GradePoints: 7, IsPassing: true},
+			{Letter: "6", MinPercentage: 72, GradePoints: 6, IsPassing: true},
+			{Letter: "5", MinPercentage: 58, GradePoints: 5, IsPassing: true},
+			{Letter: "4", MinPercentage: 45, GradePoints: 4, IsPassing: true},
+			{Letter: "3", MinPercentage: 32, GradePoints: 3, IsPassing: false},
+			{Letter: "2", MinPercentage: 18, GradePoints: 2, IsPassing: false},
+			{Letter: "1", MinPercentage: 0, GradePoints: 1, IsPassing: false},
+		},
+	}
+}
+
+func TestGradingScaleBandForScore(t *testing.T) {
+	tests := []struct {
+		name   string
+		scale  *models.GradingScale
+		score  float64
+		letter string
+	}{
+		{"default top band", models.DefaultGradingScale(), 95, "A"},
+		{"default boundary", models.DefaultGradingScale(), 80, "B"},
+		{"default failing", models.DefaultGradingScale(), 59.9, "F"},
+		{"ib top band", ibScale(), 90, "7"},
+		{"ib boundary", ibScale(), 45, "4"},
+		{"ib floor", ibScale(), 0, "1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			band := tt.scale.BandForScore(tt.score)
+			if band == nil || band.Letter != tt.letter {
+				t.Errorf("expected %s for %.1f, got %+v", tt.letter, tt.score, band)
+			}
+		})
+	}
+}
+
+func TestBuildTranscriptsUsesCourseScale(t *testing.T) {
+	term := models.AcademicTerm{
+		ID:        1,
+		Name:      "Term 1",
+		StartDate: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+		EndDate:   time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC),
+	}
+	termID := term.ID
+	course := models.Course{ID: 20, CreditHours: 2}
+	grades := []models.Grade{
+		{ID: 1, StudentID: 3, CourseID: course.ID, Course: course, TermID: &termID, Grade: "6", GradedAt: term.EndDate},
+		{ID: 2, StudentID: 3, CourseID: 21, Course: models.Course{ID: 21, CreditHours: 2}, TermID: &termID, Grade: "3", GradedAt: term.EndDate},
+	}
+
+	scale := ibScale()
+	rows := service.BuildTranscripts(3, grades, []models.AcademicTerm{term}, nil, service.RepeatPolicyReplace,
+		func(*models.Course) *models.GradingScale { return scale })
+
+	if len(rows) != 1 {
+		t.Fatalf("expected 1 row, got %d", len(rows))
+	}
+	if rows[0].GPA != 4.5 {
+		t.Errorf("expected GPA 4.5 on the IB scale, got %.2f", rows[0].GPA)
+	}
+	if rows[0].EarnedCredits != 2 {
+		t.Errorf("a failing IB 3 should not earn credit, got %.0f earned", rows[0].EarnedCredits)
+	}
+}
+
+func TestComputeGradebookRow(t *testing.T) {
+	hw, quiz := uint(1), uint(2)
+	categories := []models.GradeCategory{
+		{ID: hw, Name: "Homework", Weight: 40, DropLowest: 1},
+		{ID: quiz, Name: "Quizzes", Weight: 60},
+	}
+	assignments := []models.Assignment{
+		{ID: 10, CategoryID: &hw, MaxScore: 10},
+		{ID: 11, CategoryID: &hw, MaxScore: 10},
+		{ID: 12, CategoryID: &hw, MaxScore: 20},
+		{ID: 20, CategoryID: &quiz, MaxScore: 50},
+		{ID: 30, MaxScore: 100}, // uncategorized: shown, not counted
+	}
+
+	pct := func(v float64) *float64 { return &v }
+
+	tests := []struct {
+		name        string
+		scores      map[uint]float64
+		wantHW      *float64
+		wantQuiz    *float64
+		wantCourse  *float64
+		wantDropped []uint
+	}{
+		{
+			name:   "nothing graded",
+			scores: map[uint]float64{},
+		},
+		{
+			name:        "single homework is never dropped",
+			scores:      map[uint]float64{10: 5},
+			wantHW:      pct(50),
+			wantCourse:  pct(50),
+			wantDropped: nil,
+		},
+		{
+			name:        "lowest homework dropped and weights applied",
+			scores:      map[uint]float64{10: 2, 11: 9, 12: 16, 20: 40, 30: 0},
+			wantHW:      pct(83.33), // (9+16)/(10+20)
+			wantQuiz:    pct(80),
+			wantCourse:  pct(81.33), // 0.4*83.33 + 0.6*80
+			wantDropped: []uint{10},
+		},
+	}
+
+	same := func(a, b *float64) bool {
+		if a == nil || b == nil {
+			return a == b
+		}
+		return *a == *b
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			row := service.ComputeGradebookRow(categories, assignments, tt.scores)
+
+			if len(row.Scores) != len(assignments) {
+				t.Errorf("expected a cell per assignment, got %d", len(row.Scores))
+			}
+			if !same(row.Categories[0].Percentage, tt.wantHW) {
+				t.Errorf("homework: expected %v, got %v", tt.wantHW, row.Categories[0].Percentage)
+			}
+			if !same(row.Categories[1].Percentage, tt.wantQuiz) {
+				t.Errorf("quizzes: expected %v, got %v", tt.wantQuiz, row.Categories[1].Percentage)
+			}
+			if !same(row.CoursePercentage, tt.wantCourse) {
+				t.Errorf("course: expected %v, got %v", tt.wantCourse, row.CoursePercentage)
+			}
+			if len(row.Categories[0].Dropped) != len(tt.wantDropped) {
+				t.Errorf("expected dropped %v, got %v", tt.wantDropped, row.Categories[0].Dropped)
+			}
+		})
+	}
+}
+
+func TestComputeGradebookRowWithoutCategories(t *testing.T) {
+	assignments := []models.Assignment{{ID: 1, MaxScore: 10}, {ID: 2, MaxScore: 30}}
+	row := service.ComputeGradebookRow(nil, assignments, map[uint]float64{1: 10, 2: 20})
+
+	if row.CoursePercentage == nil || *row.CoursePercentage != 75 {
+		t.Errorf("expected 75%% over all assignments, got %v", row.CoursePercentage)
+	}
+}
@@ -0,0 +1,510 @@
+package tests
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"school-management-system/internal/models"
+	"school-management-system/internal/repository"
+	"school-management-system/internal/service"
+)
+
+// smallGrid is two days of four one hour periods
+func smallGrid() service.TimetableGrid {
+	return service.TimetableGrid{Days: []string{"Monday", "Tuesday"}, DayStart: "09:00", DayEnd: "13:00", PeriodMinutes: 60}
+}
+
+func solveProblem(t *testing.T, problem *service.TimetableProblem) *service.TimetableSolution {
+	t.Helper()
+	solution, err := service.SolveTimetable(problem)
+	if err != nil {
+		t.Fatalf("SolveTimetable: %v", err)
+	}
+	if conflicts := service.FindTimetableConflicts(append(problem.Fixed, solution.Slots...), problem); len(conflicts) > 0 {
+		t.Fatalf("generated timetable has conflicts: %+v", conflicts)
+	}
+	return solution
+}
+
+func slotsOf(solution *service.TimetableSolution, sectionID uint) []models.TimeTable {
+	var slots []models.TimeTable
+	for _, slot := range solution.Slots {
+		if *slot.SectionID == sectionID {
+			slots = append(slots, slot)
+		}
+	}
+	return slots
+}
+
+func unplacedCodes(solution *service.TimetableSolution) []string {
+	var codes []string
+	for _, unplaced := range solution.Unplaced {
+		codes = append(codes, unplaced.Code)
+	}
+	return codes
+}
+
+func TestSolveTimetable(t *testing.T) {
+	tests := []struct {
+		name         string
+		problem      service.TimetableProblem
+		wantUnplaced []string
+		check        func(t *testing.T, solution *service.TimetableSolution)
+	}{
+		{
+			name: "one teacher's sections never overlap",
+			problem: service.TimetableProblem{Sections: []service.TimetableSection{
+				{SectionID: 1, TeacherID: 7, Sessions: 3},
+				{SectionID: 2, TeacherID: 7, Sessions: 3},
+			}},
+		},
+		{
+			name: "rooms seat their class and have its features",
+			problem: service.TimetableProblem{
+				Rooms: []models.Room{{Name: "Small", Capacity: 10}, {Name: "Hall", Capacity: 100}, {Name: "Lab", Capacity: 30, Features: "lab"}},
+				Sections: []service.TimetableSection{
+					{SectionID: 1, TeacherID: 1, Sessions: 2, Size: 50},
+					{SectionID: 2, TeacherID: 2, Sessions: 2, Size: 20, Features: "Lab"},
+					{SectionID: 3, TeacherID: 3, Sessions: 2, Size: 8},
+				},
+			},
+			check: func(t *testing.T, solution *service.TimetableSolution) {
+				want := map[uint]string{1: "Hall", 2: "Lab", 3: "Small"}
+				for _, slot := range solution.Slots {
+					if slot.Classroom != want[*slot.SectionID] {
+						t.Errorf("section %d in %q, want %q", *slot.SectionID, slot.Classroom, want[*slot.SectionID])
+					}
+				}
+			},
+		},
+		{
+			name: "teachers teach only when available",
+			problem: service.TimetableProblem{
+				Sections: []service.TimetableSection{{SectionID: 1, TeacherID: 4, Sessions: 2}},
+				Availability: map[uint][]models.TeacherAvailability{
+					4: {{TeacherID: 4, DayOfWeek: "tuesday", StartTime: "10:00", EndTime: "12:00"}},
+				},
+			},
+			check: func(t *testing.T, solution *service.TimetableSolution) {
+				for _, slot := range solution.Slots {
+					if slot.DayOfWeek != "Tuesday" || slot.StartTime < "10:00" || slot.EndTime > "12:00" {
+						t.Errorf("class at %s %s-%s, outside availability", slot.DayOfWeek, slot.StartTime, slot.EndTime)
+					}
+				}
+			},
+		},
+		{
+			name: "sections sharing students meet at different times",
+			problem: service.TimetableProblem{
+				Sections: []service.TimetableSection{
+					{SectionID: 1, TeacherID: 1, Sessions: 4},
+					{SectionID: 2, TeacherID: 2, Sessions: 4},
+				},
+				Clashes: map[[2]uint]int{{1, 2}: 12},
+			},
+		},
+		{
+			name: "fixed classes are worked around",
+			problem: service.TimetableProblem{
+				Rooms:    []models.Room{{Name: "R1", Capacity: 30}},
+				Sections: []service.TimetableSection{{SectionID: 1, TeacherID: 1, Sessions: 7, Size: 20}},
+				Fixed: []models.TimeTable{{CourseID: 9, TeacherID: 2, DayOfWeek: "Monday", StartTime: "09:00",
+					EndTime: "10:00", Classroom: "r1", IsActive: true}},
+			},
+			check: func(t *testing.T, solution *service.TimetableSolution) {
+				if got := len(solution.Slots); got != 7 {
+					t.Errorf("placed %d classes, want 7", got)
+				}
+			},
+		},
+		{
+			name: "classes of a section spread across days",
+			problem: service.TimetableProblem{Sections: []service.TimetableSection{
+				{SectionID: 1, TeacherID: 1, Sessions: 2},
+			}},
+			check: func(t *testing.T, solution *service.TimetableSolution) {
+				slots := slotsOf(solution, 1)
+				if len(slots) != 2 || slots[0].DayOfWeek == slots[1].DayOfWeek {
+					t.Errorf("classes %+v, want one each day", slots)
+				}
+				if solution.Score.SameDayRepeats != 0 {
+					t.Errorf("same day repeats = %d, want 0", solution.Score.SameDayRepeats)
+				}
+			},
+		},
+		{
+			name: "what cannot be placed is reported",
+			problem: service.TimetableProblem{
+				Rooms: []models.Room{{Name: "Small", Capacity: 10}},
+				Sections: []service.TimetableSection{
+					{SectionID: 1, TeacherID: 1, Sessions: 0},
+					{SectionID: 2, TeacherID: 2, Sessions: 1, Size: 40},
+					{SectionID: 3, TeacherID: 3, Sessions: 1, Size: 5},
+					{SectionID: 4, TeacherID: 4, Sessions: 9, Size: 5},
+				},
+				Availability: map[uint][]models.TeacherAvailability{
+					3: {{TeacherID: 3, DayOfWeek: "Friday", StartTime: "09:00", EndTime: "12:00"}},
+				},
+			},
+			wantUnplaced: []string{service.UnplacedNoWeeklyHours, service.UnplacedNoSuitableRoom,
+				service.UnplacedTeacherUnavailable, service.UnplacedNoFreePeriod},
+			check: func(t *testing.T, solution *service.TimetableSolution) {
+				if got := len(slotsOf(solution, 4)); got != 8 {
+					t.Errorf("placed %d classes of section 4, want the 8 periods there are", got)
+				}
+				if last := solution.Unplaced[len(solution.Unplaced)-1]; last.Sessions != 1 {
+					t.Errorf("section 4 has %d unplaced classes, want 1", last.Sessions)
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.problem.Grid = smallGrid()
+			solution := solveProblem(t, &tt.problem)
+			if got := unplacedCodes(solution); !slices.Equal(got, tt.wantUnplaced) {
+				t.Fatalf("unplaced = %v, want %v", got, tt.wantUnplaced)
+			}
+			if tt.check != nil {
+				tt.check(t, solution)
+			}
+		})
+	}
+}
+
+func TestSolveTimetableRejectsInvalidGrid(t *testing.T) {
+	tests := []struct {
+		name string
+		grid service.TimetableGrid
+	}{
+		{"no days", service.TimetableGrid{DayStart: "09:00", DayEnd: "12:00", PeriodMinutes: 60}},
+		{"unknown day", service.TimetableGrid{Days: []string{"Funday"}, DayStart: "09:00", DayEnd: "12:00", PeriodMinutes: 60}},
+		{"day ends before it starts", service.TimetableGrid{Days: []string{"Monday"}, DayStart: "12:00", DayEnd: "09:00", PeriodMinutes: 60}},
+		{"period longer than the day", service.TimetableGrid{Days: []string{"Monday"}, DayStart: "09:00", DayEnd: "10:00", PeriodMinutes: 90}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := service.SolveTimetable(&service.TimetableProblem{Grid: tt.grid})
+			if !errors.Is(err, service.ErrInvalidTimetable) {
+				t.Fatalf("err = %v, want ErrInvalidTimetable", err)
+			}
+		})
+	}
+}
+
+func newTimetableServices(t *testing.T) (service.TimeTableService, service.TimetableGeneratorService) {
+	t.Helper()
+	testDB.AutoMigrate(&models.AcademicTerm{}, &models.CourseSection{}, &models.Enrollment{}, &models.TimeTable{}, &models.Teacher{},
+		&models.Room{}, &models.TeacherAvailability{}, &models.TimetableDraft{}, &models.TimetableDraftSlot{})
+	timetables, sections, availability := repository.NewTimeTableRepository(), repository.NewCourseSectionRepository(),
+		repository.NewTeacherAvailabilityRepository()
+	return service.NewTimeTableService(timetables, sections, availability, repository.NewTeacherRepository()),
+		service.NewTimetableGeneratorService(repository.NewTimetableDraftRepository(), timetables, repository.NewAcademicTermRepository(),
+			sections, repository.NewEnrollmentRepository(), repository.NewRoomRepository(), availability)
+}
+
+// newTimetableTeacherID returns a teacher ID no other test uses
+func newTimetableTeacherID() uint {
+	return uint(time.Now().UnixNano()%1000000000) + 1000000
+}
+
+// newTimetableSection creates a section of a new course in a term
+func newTimetableSection(t *testing.T, term *models.AcademicTerm, teacherID uint, hours, capacity int) *models.CourseSection {
+	t.Helper()
+	course := &models.Course{Name: "Course", CourseCode: fmt.Sprintf("TT%d", time.Now().UnixNano()), CreditHours: hours}
+	if err := testDB.Create(course).Error; err != nil {
+		t.Fatalf("create course: %v", err)
+	}
+	section := &models.CourseSection{CourseID: course.ID, TermID: term.ID, SectionCode: "001", TeacherID: teacherID,
+		Capacity: capacity, Status: "open"}
+	if err := testDB.Create(section).Error; err != nil {
+		t.Fatalf("create section: %v", err)
+	}
+	return section
+}
+
+func TestTimetableManualChecks(t *testing.T) {
+	timetables, _ := newTimetableServices(t)
+	term := newRegistrationTerm(t, -time.Hour, time.Hour)
+	otherTerm := newRegistrationTerm(t, -time.Hour, time.Hour)
+	teacherID := newTimetableTeacherID()
+	room := fmt.Sprintf("Room %d", time.Now().UnixNano())
+	section := newTimetableSection(t, term, teacherID, 3, 0)
+	otherSection := newTimetableSection(t, otherTerm, teacherID, 3, 0)
+
+	existing := &models.TimeTable{SectionID: &section.ID, TeacherID: teacherID, DayOfWeek: "monday", StartTime: "9:00",
+		EndTime: "10:00", Classroom: room, IsActive: true}
+	if err := timetables.Create(existing); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if existing.DayOfWeek != "Monday" || existing.StartTime != "09:00" {
+		t.Errorf("saved %s %s, want Monday 09:00", existing.DayOfWeek, existing.StartTime)
+	}
+
+	tests := []struct {
+		name      string
+		slot      models.TimeTable
+		wantErr   error
+		wantCodes []string
+	}{
+		{"bad day", models.TimeTable{TeacherID: teacherID, DayOfWeek: "Someday", StartTime: "11:00", EndTime: "12:00", IsActive: true},
+			service.ErrInvalidTimetable, nil},
+		{"bad time", models.TimeTable{TeacherID: teacherID, DayOfWeek: "Monday", StartTime: "11:75", EndTime: "12:00", IsActive: true},
+			service.ErrInvalidTimetable, nil},
+		{"ends before it starts", models.TimeTable{TeacherID: teacherID, DayOfWeek: "Monday", StartTime: "12:00", EndTime: "11:00", IsActive: true},
+			service.ErrInvalidTimetable, nil},
+		{"teacher double-booked", models.TimeTable{SectionID: &section.ID, TeacherID: teacherID, DayOfWeek: "Monday", StartTime: "09:30",
+			EndTime: "10:30", IsActive: true}, nil, []string{service.ConflictTeacherDoubleBooked}},
+		{"room double-booked", models.TimeTable{SectionID: &section.ID, TeacherID: teacherID + 1, DayOfWeek: "Monday", StartTime: "09:00",
+			EndTime: "09:30", Classroom: room, IsActive: true}, nil, []string{service.ConflictRoomDoubleBooked}},
+		{"both", models.TimeTable{SectionID: &section.ID, TeacherID: teacherID, DayOfWeek: "Monday", StartTime: "09:00",
+			EndTime: "10:00", Classroom: room, IsActive: true}, nil,
+			[]string{service.ConflictTeacherDoubleBooked, service.ConflictRoomDoubleBooked}},
+		{"back to back", models.TimeTable{SectionID: &section.ID, TeacherID: teacherID, DayOfWeek: "Monday", StartTime: "10:00",
+			EndTime: "11:00", Classroom: room, IsActive: true}, nil, nil},
+		{"another term", models.TimeTable{SectionID: &otherSection.ID, TeacherID: teacherID, DayOfWeek: "Monday", StartTime: "09:00",
+			EndTime: "10:00", Classroom: room, IsActive: true}, nil, nil},
+		{"inactive", models.TimeTable{SectionID: &section.ID, TeacherID: teacherID, DayOfWeek: "Monday", StartTime: "09:00",
+			EndTime: "10:00", Classroom: room}, nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			slot := tt.slot
+			err := timetables.Create(&slot)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			var conflict *service.TimetableConflictError
+			if tt.wantCodes == nil {
+				if err != nil {
+					t.Fatalf("Create: %v", err)
+				}
+				testDB.Delete(&slot)
+				return
+			}
+			if !errors.As(err, &conflict) {
+				t.Fatalf("err = %v, want a TimetableConflictError", err)
+			}
+			var codes []string
+			for _, c := range conflict.Conflicts {
+				codes = append(codes, c.Code)
+				if !slices.Equal(c.TimetableIDs, []uint{existing.ID}) {
+					t.Errorf("conflict with %v, want [%d]", c.TimetableIDs, existing.ID)
+				}
+			}
+			if !slices.Equal(codes, tt.wantCodes) {
+				t.Errorf("codes = %v, want %v", codes, tt.wantCodes)
+			}
+		})
+	}
+
+	// Moving a class onto its own time is not a clash with itself
+	existing.EndTime = "10:30"
+	if err := timetables.Update(existing); err != nil {
+		t.Errorf("Update: %v", err)
+	}
+}
+
+func TestTimetableGenerateAndCommit(t *testing.T) {
+	_, generator := newTimetableServices(t)
+	term := newRegistrationTerm(t, -time.Hour, time.Hour)
+	teacherID := newTimetableTeacherID()
+	first := newTimetableSection(t, term, teacherID, 2, 20)
+	second := newTimetableSection(t, term, teacherID, 2, 20)
+	third := newTimetableSection(t, term, teacherID+1, 1, 0)
+
+	student := newRulesStudent(t)
+	for _, section := range []*models.CourseSection{first, third} {
+		enrollment := &models.Enrollment{StudentID: student.ID, CourseID: section.CourseID, SectionID: &section.ID, TermID: &term.ID,
+			Status: models.EnrollmentActive, EnrolledAt: time.Now()}
+		if err := testDB.Create(enrollment).Error; err != nil {
+			t.Fatalf("create enrollment: %v", err)
+		}
+	}
+	stale := &models.TimeTable{SectionID: &first.ID, DayOfWeek: "Friday", StartTime: "15:00", EndTime: "16:00", IsActive: true}
+	if err := testDB.Create(stale).Error; err != nil {
+		t.Fatalf("create timetable: %v", err)
+	}
+
+	preview, err := generator.Generate(service.GenerateTimetableOptions{TermID: term.ID, Grid: smallGrid()}, 1)
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	if len(preview.Unplaced) != 0 || len(preview.Draft.Slots) != 5 {
+		t.Fatalf("placed %d classes with %v unplaced, want 5 and none", len(preview.Draft.Slots), preview.Unplaced)
+	}
+	if !slices.Equal(preview.Options.SectionIDs, []uint{first.ID, second.ID, third.ID}) {
+		t.Errorf("sections = %v, want all three", preview.Options.SectionIDs)
+	}
+	if got, _ := timetableCount(first.ID); got != 1 {
+		t.Fatalf("previewing changed the timetable: section has %d classes", got)
+	}
+
+	fetched, err := generator.GetDraft(preview.Draft.ID)
+	if err != nil || fetched.Score != preview.Score || len(fetched.Draft.Slots) != 5 {
+		t.Fatalf("GetDraft = %+v, %v", fetched, err)
+	}
+
+	if _, err := generator.Commit(preview.Draft.ID, false, 1); err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+	for _, section := range []*models.CourseSection{first, second, third} {
+		want := int64(2)
+		if section == third {
+			want = 1
+		}
+		if got, _ := timetableCount(section.ID); got != want {
+			t.Errorf("section %d has %d classes, want %d", section.ID, got, want)
+		}
+	}
+	if _, err := generator.Commit(preview.Draft.ID, false, 1); !errors.Is(err, service.ErrTimetableDraftClosed) {
+		t.Errorf("second Commit err = %v, want ErrTimetableDraftClosed", err)
+	}
+
+	conflicts, err := generator.Conflicts(term.ID)
+	if err != nil {
+		t.Fatalf("Conflicts: %v", err)
+	}
+	for _, conflict := range conflicts {
+		if slices.ContainsFunc(conflict.SectionIDs, func(id uint) bool { return id == first.ID || id == second.ID || id == third.ID }) {
+			t.Errorf("committed timetable has conflict %+v", conflict)
+		}
+	}
+}
+
+func timetableCount(sectionID uint) (int64, error) {
+	var count int64
+	err := testDB.Model(&models.TimeTable{}).Where("section_id = ?", sectionID).Count(&count).Error
+	return count, err
+}
+
+func TestTimetableDraftCommitChecks(t *testing.T) {
+	_, generator := newTimetableServices(t)
+	term := newRegistrationTerm(t, -time.Hour, time.Hour)
+	teacherID := newTimetableTeacherID()
+	section := newTimetableSection(t, term, teacherID, 9, 0)
+
+	// Nine hours do not fit in eight periods
+	partial, err := generator.Generate(service.GenerateTimetableOptions{TermID: term.ID, SectionIDs: []uint{section.ID}, Grid: smallGrid()}, 1)
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	if codes := []string{partial.Unplaced[0].Code}; len(partial.Unplaced) != 1 || codes[0] != service.UnplacedNoFreePeriod {
+		t.Fatalf("unplaced = %+v, want one no_free_period", partial.Unplaced)
+	}
+	if _, err := generator.Commit(partial.Draft.ID, false, 1); !errors.Is(err, service.ErrTimetableDraftIncomplete) {
+		t.Errorf("Commit err = %v, want ErrTimetableDraftIncomplete", err)
+	}
+	if err := generator.Discard(partial.Draft.ID); err != nil {
+		t.Fatalf("Discard: %v", err)
+	}
+	if _, err := generator.Commit(partial.Draft.ID, true, 1); !errors.Is(err, service.ErrTimetableDraftClosed) {
+		t.Errorf("Commit after discard err = %v, want ErrTimetableDraftClosed", err)
+	}
+
+	// A draft made stale by a class added since is refused
+	testDB.Model(section).Update("weekly_hours", 1)
+	draft, err := generator.Generate(service.GenerateTimetableOptions{TermID: term.ID, SectionIDs: []uint{section.ID}, Grid: smallGrid()}, 1)
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	placed := draft.Draft.Slots[0]
+	other := newTimetableSection(t, term, teacherID, 1, 0)
+	blocking := &models.TimeTable{SectionID: &other.ID, TeacherID: teacherID, DayOfWeek: placed.DayOfWeek, StartTime: placed.StartTime,
+		EndTime: placed.EndTime, IsActive: true}
+	if err := testDB.Create(blocking).Error; err != nil {
+		t.Fatalf("create timetable: %v", err)
+	}
+	var conflict *service.TimetableConflictError
+	if _, err := generator.Commit(draft.Draft.ID, false, 1); !errors.As(err, &conflict) ||
+		conflict.Conflicts[0].Code != service.ConflictTeacherDoubleBooked {
+		t.Fatalf("Commit err = %v, want a teacher double-booking", err)
+	}
+
+	if _, err := generator.GetDraft(999999); !errors.Is(err, service.ErrTimetableMissing) {
+		t.Errorf("GetDraft err = %v, want ErrTimetableMissing", err)
+	}
+	if _, err := generator.Generate(service.GenerateTimetableOptions{TermID: term.ID, SectionIDs: []uint{999999}}, 1); !errors.Is(err, service.ErrTimetableMissing) {
+		t.Errorf("Generate err = %v, want ErrTimetableMissing", err)
+	}
+}
+
+func TestTimetableConflictReport(t *testing.T) {
+	_, generator := newTimetableServices(t)
+	term := newRegistrationTerm(t, -time.Hour, time.Hour)
+	teacherID := newTimetableTeacherID()
+	room := &models.Room{Name: fmt.Sprintf("Lab %d", time.Now().UnixNano()), Capacity: 10, Features: "lab", IsActive: true}
+	if err := testDB.Create(room).Error; err != nil {
+		t.Fatalf("create room: %v", err)
+	}
+	big := newTimetableSection(t, term, teacherID, 1, 30)
+	testDB.Model(big).Update("room_features", "lab,sink")
+	other := newTimetableSection(t, term, teacherID, 1, 0)
+	testDB.Create(&models.TeacherAvailability{TeacherID: teacherID, DayOfWeek: "Monday", StartTime: "09:00", EndTime: "12:00"})
+
+	for _, slot := range []*models.TimeTable{
+		{SectionID: &big.ID, TeacherID: teacherID, DayOfWeek: "Monday", StartTime: "11:00", EndTime: "13:00", Classroom: room.Name, IsActive: true},
+		{SectionID: &other.ID, TeacherID: teacherID, DayOfWeek: "Monday", StartTime: "11:30", EndTime: "12:00", IsActive: true},
+		{SectionID: &other.ID, DayOfWeek: "Monday", StartTime: "late", EndTime: "later", IsActive: true},
+	} {
+		if err := testDB.Create(slot).Error; err != nil {
+			t.Fatalf("create timetable: %v", err)
+		}
+	}
+
+	conflicts, err := generator.Conflicts(term.ID)
+	if err != nil {
+		t.Fatalf("Conflicts: %v", err)
+	}
+	var codes []string
+	for _, conflict := range conflicts {
+		if slices.Contains(conflict.SectionIDs, big.ID) || slices.Contains(conflict.SectionIDs, other.ID) {
+			codes = append(codes, conflict.Code)
+		}
+	}
+	want := []string{service.ConflictInvalidTime, service.ConflictRoomTooSmall, service.ConflictRoomMissingFeatures,
+		service.ConflictTeacherUnavailable, service.ConflictTeacherDoubleBooked}
+	if !slices.Equal(codes, want) {
+		t.Errorf("codes = %v, want %v", codes, want)
+	}
+}
+
+func TestTeacherAvailability(t *testing.T) {
+	timetables, _ := newTimetableServices(t)
+	user := createTestUser(t, models.RoleTeacher, true)
+	teacher := &models.Teacher{UserID: user.ID, TeacherID: fmt.Sprintf("TA%d", time.Now().UnixNano())}
+	if err := testDB.Create(teacher).Error; err != nil {
+		t.Fatalf("create teacher: %v", err)
+	}
+
+	invalid := []models.TeacherAvailability{{DayOfWeek: "Monday", StartTime: "12:00", EndTime: "09:00"}}
+	if err := timetables.SetTeacherAvailability(teacher.ID, invalid); !errors.Is(err, service.ErrInvalidTimetable) {
+		t.Errorf("err = %v, want ErrInvalidTimetable", err)
+	}
+	if err := timetables.SetTeacherAvailability(999999, nil); !errors.Is(err, service.ErrTimetableMissing) {
+		t.Errorf("err = %v, want ErrTimetableMissing", err)
+	}
+
+	windows := []models.TeacherAvailability{{DayOfWeek: "monday", StartTime: "9:00", EndTime: "12:00"},
+		{DayOfWeek: "Wednesday", StartTime: "13:00", EndTime: "15:30"}}
+	if err := timetables.SetTeacherAvailability(teacher.ID, windows); err != nil {
+		t.Fatalf("SetTeacherAvailability: %v", err)
+	}
+	saved, err := timetables.GetTeacherAvailability(teacher.ID)
+	if err != nil || len(saved) != 2 || saved[0].DayOfWeek != "Monday" || saved[0].StartTime != "09:00" {
+		t.Fatalf("GetTeacherAvailability = %+v, %v", saved, err)
+	}
+
+	if err := timetables.SetTeacherAvailability(teacher.ID, nil); err != nil {
+		t.Fatalf("SetTeacherAvailability: %v", err)
+	}
+	if saved, _ := timetables.GetTeacherAvailability(teacher.ID); len(saved) != 0 {
+		t.Errorf("availability = %+v after clearing, want none", saved)
+	}
+}
@@ -0,0 +1,152 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"school-management-system/internal/models"
+	"school-management-system/internal/repository"
+	"school-management-system/internal/service"
+)
+
+func newTranscriptService(t *testing.T) service.GradeTranscriptService {
+	t.Helper()
+	testDB.AutoMigrate(&models.Grade{}, &models.GradeTranscript{}, &models.AcademicTerm{}, &models.TransferCredit{},
+		&models.GradingScale{}, &models.GradingBand{}, &models.SystemSetting{})
+	scales := service.NewGradingScaleService(repository.NewGradingScaleRepository(), repository.NewCourseRepository())
+	return service.NewGradeTranscriptService(repository.NewGradeTranscriptRepository(), repository.NewGradeRepository(),
+		repository.NewAcademicTermRepository(), repository.NewTransferCreditRepository(), repository.NewSystemSettingRepository(), scales)
+}
+
+// newTranscriptCourse creates a three credit course no other test uses
+func newTranscriptCourse(t *testing.T) *models.Course {
+	t.Helper()
+	course := &models.Course{Name: "Course", CourseCode: fmt.Sprintf("TR%d", time.Now().UnixNano()), CreditHours: 3}
+	if err := testDB.Create(course).Error; err != nil {
+		t.Fatalf("create course: %v", err)
+	}
+	return course
+}
+
+func TestRegenerateTranscriptConcurrently(t *testing.T) {
+	transcripts := newTranscriptService(t)
+	student := newRulesStudent(t)
+	term := newRegistrationTerm(t, -time.Hour, time.Hour)
+	course := newTranscriptCourse(t)
+	if err := testDB.Create(&models.Grade{StudentID: student.ID, CourseID: course.ID, TermID: &term.ID, Grade: "A",
+		GradedAt: time.Now()}).Error; err != nil {
+		t.Fatalf("create grade: %v", err)
+	}
+	// A duplicate left behind by an earlier race is cleaned up
+	for i := 0; i < 2; i++ {
+		testDB.Create(&models.GradeTranscript{StudentID: student.ID, TermID: &term.ID})
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := transcripts.RegenerateForStudent(student.ID); err != nil {
+				errs <- err
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("RegenerateForStudent: %v", err)
+	}
+
+	var rows []models.GradeTranscript
+	testDB.Where("student_id = ?", student.ID).Find(&rows)
+	if len(rows) != 1 {
+		t.Fatalf("%d transcript rows, want 1", len(rows))
+	}
+	if rows[0].GPA != 4 {
+		t.Errorf("GPA = %v, want 4", rows[0].GPA)
+	}
+}
+
+func newGradeService(t *testing.T, transcripts service.GradeTranscriptService) service.GradeService {
+	t.Helper()
+	testDB.AutoMigrate(&models.CourseSection{})
+	scales := service.NewGradingScaleService(repository.NewGradingScaleRepository(), repository.NewCourseRepository())
+	return service.NewGradeService(repository.NewGradeRepository(), repository.NewCourseSectionRepository(), scales, transcripts, nil)
+}
+
+func TestGradeChangesRegenerateTranscript(t *testing.T) {
+	transcripts := newTranscriptService(t)
+	grades := newGradeService(t, transcripts)
+	student := newRulesStudent(t)
+	term := newRegistrationTerm(t, -time.Hour, time.Hour)
+	course := newTranscriptCourse(t)
+
+	gpa := func() (float64, int) {
+		t.Helper()
+		var rows []models.GradeTranscript
+		testDB.Where("student_id = ?", student.ID).Find(&rows)
+		if len(rows) == 0 {
+			return 0, 0
+		}
+		return rows[0].GPA, len(rows)
+	}
+
+	grade := &models.Grade{StudentID: student.ID, CourseID: course.ID, TermID: &term.ID, Grade: "A", Score: 95, MaxScore: 100, GradedBy: 1}
+	if err := grades.RecordGrade(context.Background(), grade); err != nil {
+		t.Fatalf("RecordGrade: %v", err)
+	}
+	if got, rows := gpa(); got != 4 || rows != 1 {
+		t.Errorf("after recording: GPA %v in %d rows, want 4 in 1", got, rows)
+	}
+
+	if err := grades.UpdateGrade(context.Background(), &models.Grade{ID: grade.ID, Grade: "C", Score: 75, MaxScore: 100}); err != nil {
+		t.Fatalf("UpdateGrade: %v", err)
+	}
+	if got, _ := gpa(); got != 2 {
+		t.Errorf("after updating: GPA %v, want 2", got)
+	}
+	var stored models.Grade
+	testDB.First(&stored, grade.ID)
+	if stored.StudentID != student.ID || stored.TermID == nil || *stored.TermID != term.ID {
+		t.Errorf("updated grade %+v lost its student or term", stored)
+	}
+
+	if err := grades.DeleteGrade(context.Background(), grade.ID); err != nil {
+		t.Fatalf("DeleteGrade: %v", err)
+	}
+	if _, rows := gpa(); rows != 0 {
+		t.Errorf("after deleting: %d transcript rows, want none", rows)
+	}
+}
+
+func TestManualGradeLetterFromScale(t *testing.T) {
+	grades := newGradeService(t, newTranscriptService(t))
+	student := newRulesStudent(t)
+	course := newTranscriptCourse(t)
+
+	grade := &models.Grade{StudentID: student.ID, CourseID: course.ID, Score: 45, MaxScore: 50, GradedBy: 1}
+	if err := grades.RecordGrade(context.Background(), grade); err != nil {
+		t.Fatalf("RecordGrade: %v", err)
+	}
+	if grade.Grade != "A" {
+		t.Errorf("recorded letter %q, want A for 90%%", grade.Grade)
+	}
+
+	update := &models.Grade{ID: grade.ID, Score: 36, MaxScore: 50}
+	if err := grades.UpdateGrade(context.Background(), update); err != nil {
+		t.Fatalf("UpdateGrade: %v", err)
+	}
+	if update.Grade != "C" {
+		t.Errorf("updated letter %q, want C for 72%%", update.Grade)
+	}
+
+	given := &models.Grade{StudentID: student.ID, CourseID: course.ID, Score: 45, MaxScore: 50, Grade: "P", GradedBy: 1}
+	if err := grades.RecordGrade(context.Background(), given); err != nil || given.Grade != "P" {
+		t.Errorf("letter given: %q, %v; want it kept", given.Grade, err)
+	}
+}